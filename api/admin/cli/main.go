@@ -1,22 +1,32 @@
 package main
 
 import (
-	"crypto/rand"
-	"encoding/base32"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/wissance/Ferrum/managers"
 	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/wissance/Ferrum/api/admin/cli/operations"
 	"github.com/wissance/Ferrum/config"
 	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	appErrs "github.com/wissance/Ferrum/errors"
 	"github.com/wissance/Ferrum/logging"
+	"github.com/wissance/Ferrum/services"
+	"github.com/wissance/Ferrum/utils/hashing"
+	"github.com/wissance/Ferrum/utils/random"
 	sf "github.com/wissance/stringFormatter"
 )
 
 const defaultConfig = "./config_w_redis.json"
+const defaultPasswordMinLength = 8
 
 var (
 	argConfigFile = flag.String("config", defaultConfig, "")
@@ -47,12 +57,20 @@ func main() {
 
 	isInvalidOperation := operation != operations.GetOperation && operation != operations.CreateOperation &&
 		operation != operations.DeleteOperation && operation != operations.UpdateOperation &&
-		operation != operations.ChangePassword && operation != operations.ResetPassword
+		operation != operations.ChangePassword && operation != operations.ResetPassword &&
+		operation != operations.CreateInitialAccessToken && operation != operations.UnlockUser &&
+		operation != operations.EnrollOtp && operation != operations.RemoveOtp &&
+		operation != operations.SendExecuteActionsEmail && operation != operations.EnableOperation &&
+		operation != operations.DisableOperation && operation != operations.SetValidity &&
+		operation != operations.GetConsents && operation != operations.RevokeConsent &&
+		operation != operations.ImportRealm && operation != operations.ExportRealm
 	if isInvalidOperation {
 		log.Fatalf("bad Operation \"%s\"", operation)
 	}
 	// If there is a password change or password collection, it is not necessary to specify Resource
-	if !(operation == operations.ChangePassword || operation == operations.ResetPassword) {
+	if !(operation == operations.ChangePassword || operation == operations.ResetPassword || operation == operations.UnlockUser ||
+		operation == operations.EnrollOtp || operation == operations.RemoveOtp || operation == operations.SendExecuteActionsEmail ||
+		operation == operations.SetValidity || operation == operations.GetConsents || operation == operations.RevokeConsent) {
 		isInvalidResource := resource != operations.RealmResource && resource != operations.ClientResource && resource != operations.UserResource
 		if isInvalidResource {
 			log.Fatalf("bad Resource \"%s\"", resource)
@@ -114,9 +132,8 @@ func main() {
 				log.Fatalf("json.Unmarshal failed: %s", err)
 			}
 			user := data.CreateUser(userNew)
-			if err := manager.CreateUser(params, user); err != nil {
-				log.Fatalf("CreateUser failed: %s", err)
-			}
+			hashUserPassword(manager, params, nil, user)
+			checkUserStored("CreateUser", manager.CreateUser(params, user))
 			fmt.Println(sf.Format("User: \"{0}\" successfully created", user.GetUsername()))
 
 		case operations.RealmResource:
@@ -180,9 +197,12 @@ func main() {
 				log.Fatalf("json.Unmarshal failed: %s", err)
 			}
 			user := data.CreateUser(newUser)
-			if err := manager.UpdateUser(params, resourceId, user); err != nil {
-				log.Fatalf("UpdateUser failed: %s", err)
+			storedUser, err := manager.GetUser(params, resourceId)
+			if err != nil {
+				log.Fatalf("GetUser failed: %s", err)
 			}
+			hashUserPassword(manager, params, storedUser, user)
+			checkUserStored("UpdateUser", manager.UpdateUser(params, resourceId, user))
 			fmt.Println(sf.Format("User: \"{0}\" successfully updated", user.GetUsername(), params))
 
 		case operations.RealmResource:
@@ -208,15 +228,21 @@ func main() {
 			if resourceId == "" {
 				log.Fatalf("Not specified Resource_id")
 			}
-			// TODO(SIA)  Moving password verification to another location
-			if len(value) < 8 {
-				log.Fatalf("Password length must be greater than 8")
-			}
 			password := string(value)
+			realm, err := manager.GetRealm(params)
+			if err != nil {
+				log.Fatalf("GetRealm failed: %s", err)
+			}
+			user, err := manager.GetUser(params, resourceId)
+			if err != nil {
+				log.Fatalf("GetUser failed: %s", err)
+			}
+			checkPasswordPolicy(realm, user, password)
 			passwordManager := manager.(PasswordManager)
 			if err := passwordManager.SetPassword(params, resourceId, password); err != nil {
 				log.Fatalf("SetPassword failed: %s", err)
 			}
+			setPasswordRequiredAction(manager, params, resourceId, false)
 			fmt.Printf("Password successfully changed")
 
 		default:
@@ -235,32 +261,433 @@ func main() {
 			if resourceId == "" {
 				log.Fatalf("Not specified ResourceId")
 			}
-			password := getRandPassword()
+			var resetParams resetPasswordParams
+			if len(value) > 0 {
+				if err := json.Unmarshal(value, &resetParams); err != nil {
+					log.Fatalf("json.Unmarshal failed: %s", err)
+				}
+			}
+			realm, err := manager.GetRealm(params)
+			if err != nil {
+				log.Fatalf("GetRealm failed: %s", err)
+			}
+			password, err := services.GeneratePassword(getPasswordPolicy(realm))
+			if err != nil {
+				log.Fatalf("GeneratePassword failed: %s", err)
+			}
 			passwordManager := manager.(PasswordManager)
 			if err := passwordManager.SetPassword(params, resourceId, password); err != nil {
 				log.Fatalf("SetPassword failed: %s", err)
 			}
+			setPasswordRequiredAction(manager, params, resourceId, resetParams.Temporary)
+			if resetParams.Temporary {
+				fmt.Printf("New temporary password (user must change it on login): %s", password)
+				return
+			}
 			fmt.Printf("New password: %s", password)
 
 		default:
 			log.Fatalf("Bad Resource")
 		}
 
+		return
+	case operations.UnlockUser:
+		if resource != operations.UserResource && resource != "" {
+			log.Fatalf("Bad Resource")
+		}
+		if params == "" {
+			log.Fatalf("Not specified Params")
+		}
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		err = manager.DeleteLoginFailures(params, data.UserLoginFailuresKey(resourceId))
+		if err != nil {
+			if !errors.As(err, &appErrs.EmptyNotFoundErr) {
+				log.Fatalf("DeleteLoginFailures failed: %s", err)
+			}
+			fmt.Println(sf.Format("User: \"{0}\" is not locked", resourceId))
+			return
+		}
+		fmt.Println(sf.Format("User: \"{0}\" successfully unlocked", resourceId))
+
+		return
+	case operations.EnrollOtp, operations.RemoveOtp:
+		if resource != operations.UserResource && resource != "" {
+			log.Fatalf("Bad Resource")
+		}
+		if params == "" {
+			log.Fatalf("Not specified Params")
+		}
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		realm, err := manager.GetRealm(params)
+		if err != nil {
+			log.Fatalf("GetRealm failed: %s", err)
+		}
+		user, err := manager.GetUser(params, resourceId)
+		if err != nil {
+			log.Fatalf("GetUser failed: %s", err)
+		}
+		if operation == operations.RemoveOtp {
+			if err = user.SetOtpCredential(nil); err != nil {
+				log.Fatalf("SetOtpCredential failed: %s", err)
+			}
+			if err = manager.UpdateUser(params, resourceId, user); err != nil {
+				log.Fatalf("UpdateUser failed: %s", err)
+			}
+			fmt.Println(sf.Format("OTP of user: \"{0}\" successfully removed", resourceId))
+			return
+		}
+		credential, uri, recoveryCodes, err := services.CreateOtpCredential(realm, resourceId)
+		if err != nil {
+			log.Fatalf("CreateOtpCredential failed: %s", err)
+		}
+		if err = user.SetOtpCredential(credential); err != nil {
+			log.Fatalf("SetOtpCredential failed: %s", err)
+		}
+		if _, err = data.RemoveRequiredAction(user, data.ConfigureTotpAction); err != nil {
+			log.Fatalf("RemoveRequiredAction failed: %s", err)
+		}
+		if err = manager.UpdateUser(params, resourceId, user); err != nil {
+			log.Fatalf("UpdateUser failed: %s", err)
+		}
+		fmt.Println(sf.Format("OTP of user: \"{0}\" successfully enrolled", resourceId))
+		fmt.Println(sf.Format("Authenticator app uri: {0}", uri))
+		fmt.Println(sf.Format("Recovery codes (each could be used once instead of OTP): {0}", strings.Join(recoveryCodes, " ")))
+
+		return
+	case operations.SendExecuteActionsEmail:
+		if resource != operations.UserResource && resource != "" {
+			log.Fatalf("Bad Resource")
+		}
+		if params == "" {
+			log.Fatalf("Not specified Params")
+		}
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		if cfg.Mail == nil {
+			log.Fatalf("Mail is not configured (\"mail\" config section)")
+		}
+		var actionsRequest dto.ExecuteActionsEmailRequest
+		if len(value) == 0 {
+			log.Fatalf("Not specified Value")
+		}
+		if err := json.Unmarshal(value, &actionsRequest); err != nil {
+			log.Fatalf("json.Unmarshal failed: %s", err)
+		}
+		realm, err := manager.GetRealm(params)
+		if err != nil {
+			log.Fatalf("GetRealm failed: %s", err)
+		}
+		user, err := manager.GetUser(params, resourceId)
+		if err != nil {
+			log.Fatalf("GetUser failed: %s", err)
+		}
+		sender, err := services.CreateMailSender(cfg.Mail, logger)
+		if err != nil {
+			log.Fatalf("CreateMailSender failed: %s", err)
+		}
+		emailActions := services.CreateEmailActionService(sender, &manager, cfg.Mail.GetLinkBaseUrl(&cfg.ServerCfg), logger)
+		if check := emailActions.SendExecuteActionsEmail(realm, user, actionsRequest.Actions, actionsRequest.Lifespan); check != nil {
+			log.Fatalf("SendExecuteActionsEmail failed: %s %s", check.Msg, check.Description)
+		}
+		fmt.Println(sf.Format("Execute actions email was sent to user: \"{0}\"", resourceId))
+
+		return
+	case operations.EnableOperation, operations.DisableOperation:
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		enabled := operation == operations.EnableOperation
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		switch resource {
+		case operations.ClientResource:
+			client, err := manager.GetClient(params, resourceId)
+			if err != nil {
+				log.Fatalf("GetClient failed: %s", err)
+			}
+			client.Enabled = &enabled
+			if err := manager.UpdateClient(params, resourceId, *client); err != nil {
+				log.Fatalf("UpdateClient failed: %s", err)
+			}
+			fmt.Println(sf.Format("Client: \"{0}\" successfully {1}", resourceId, state))
+
+		case operations.UserResource:
+			user, err := manager.GetUser(params, resourceId)
+			if err != nil {
+				log.Fatalf("GetUser failed: %s", err)
+			}
+			if err := user.SetEnabled(enabled); err != nil {
+				log.Fatalf("SetEnabled failed: %s", err)
+			}
+			checkUserStored("UpdateUser", manager.UpdateUser(params, resourceId, user))
+			fmt.Println(sf.Format("User: \"{0}\" successfully {1}", resourceId, state))
+
+		case operations.RealmResource:
+			realm, err := manager.GetRealm(resourceId)
+			if err != nil {
+				log.Fatalf("GetRealm failed: %s", err)
+			}
+			realm.Enabled = &enabled
+			if err := manager.UpdateRealm(resourceId, *realm); err != nil {
+				log.Fatalf("UpdateRealm failed: %s", err)
+			}
+			fmt.Println(sf.Format("Realm: \"{0}\" successfully {1}", resourceId, state))
+		}
+
+		return
+	case operations.SetValidity:
+		if resource != operations.UserResource && resource != "" {
+			log.Fatalf("Bad Resource")
+		}
+		if params == "" {
+			log.Fatalf("Not specified Params")
+		}
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		var validity userValidityParams
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &validity); err != nil {
+				log.Fatalf("json.Unmarshal failed: %s", err)
+			}
+		}
+		user, err := manager.GetUser(params, resourceId)
+		if err != nil {
+			log.Fatalf("GetUser failed: %s", err)
+		}
+		if err = user.SetValidity(validity.NotBefore, validity.ValidUntil); err != nil {
+			log.Fatalf("SetValidity failed: %s", err)
+		}
+		if err = manager.UpdateUser(params, resourceId, user); err != nil {
+			log.Fatalf("UpdateUser failed: %s", err)
+		}
+		fmt.Println(sf.Format("Validity of user: \"{0}\" successfully set", resourceId))
+
+		return
+	case operations.GetConsents, operations.RevokeConsent:
+		if resource != operations.UserResource && resource != "" {
+			log.Fatalf("Bad Resource")
+		}
+		if params == "" {
+			log.Fatalf("Not specified Params")
+		}
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		user, err := manager.GetUser(params, resourceId)
+		if err != nil {
+			log.Fatalf("GetUser failed: %s", err)
+		}
+		if operation == operations.GetConsents {
+			consents, err := json.Marshal(user.GetConsents())
+			if err != nil {
+				log.Fatalf("json.Marshal failed: %s", err)
+			}
+			fmt.Println(string(consents))
+			return
+		}
+		// value is a client id, consent is revoked the same way as user revokes it via account consents endpoint
+		clientId := string(value)
+		realm, err := manager.GetRealm(params)
+		if err != nil {
+			log.Fatalf("GetRealm failed: %s", err)
+		}
+		security := services.CreateSecurityService(&manager, nil, logger)
+		if check := security.RevokeConsent(realm, user, clientId); check != nil {
+			log.Fatalf("RevokeConsent failed: %s %s", check.Msg, check.Description)
+		}
+		fmt.Println(sf.Format("Consent of user: \"{0}\" to client \"{1}\" successfully revoked", resourceId, clientId))
+
+		return
+	case operations.CreateInitialAccessToken:
+		if resource != operations.RealmResource {
+			log.Fatalf("Bad Resource")
+		}
+		if resourceId == "" {
+			log.Fatalf("Not specified ResourceId")
+		}
+		var tokenParams initialAccessTokenParams
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &tokenParams); err != nil {
+				log.Fatalf("json.Unmarshal failed: %s", err)
+			}
+		}
+		realm, err := manager.GetRealm(resourceId)
+		if err != nil {
+			log.Fatalf("GetRealm failed: %s", err)
+		}
+		token, err := random.GenerateToken(32)
+		if err != nil {
+			log.Fatalf("GenerateToken failed: %s", err)
+		}
+		initialAccessToken := data.InitialAccessToken{ID: uuid.New(), TokenHash: hashing.HashToken(token), Created: time.Now(),
+			Count: tokenParams.Count}
+		if tokenParams.Expiration > 0 {
+			initialAccessToken.Expires = initialAccessToken.Created.Add(time.Duration(tokenParams.Expiration) * time.Second)
+		}
+		realm.InitialAccessTokens = append(realm.InitialAccessTokens, initialAccessToken)
+		if err := manager.UpdateRealm(resourceId, *realm); err != nil {
+			log.Fatalf("UpdateRealm failed: %s", err)
+		}
+		fmt.Printf("Initial access token: %s", token)
+
+		return
+	case operations.ImportRealm, operations.ExportRealm:
+		if resource != operations.RealmResource {
+			log.Fatalf("Bad Resource")
+		}
+		// value is a path to KeyCloak realm export file
+		if len(value) == 0 {
+			log.Fatalf("Not specified Value")
+		}
+		admin := services.CreateAdminService(&manager, nil, cfg.ServerCfg.GetAdminRealm(), logger)
+		var report *dto.RealmMigrationReport
+		if operation == operations.ImportRealm {
+			fileData, err := os.ReadFile(string(value))
+			if err != nil {
+				log.Fatalf("ReadFile failed: %s", err)
+			}
+			var representation dto.RealmExportRepresentation
+			if err := json.Unmarshal(fileData, &representation); err != nil {
+				log.Fatalf("json.Unmarshal failed: %s", err)
+			}
+			var check *data.OperationError
+			if report, check = admin.ImportRealm(&representation); check != nil {
+				log.Fatalf("ImportRealm failed: %s %s", check.Msg, check.Description)
+			}
+			fmt.Println(sf.Format("Realm: \"{0}\" successfully imported ({1} clients, {2} users, {3} roles, {4} groups)",
+				report.Realm, report.Clients, report.Users, report.Roles, report.Groups))
+		} else {
+			if resourceId == "" {
+				log.Fatalf("Not specified ResourceId")
+			}
+			query := dto.RealmExportQuery{Clients: true, GroupsAndRoles: true, Users: true}
+			representation, exportReport, check := admin.ExportRealm(resourceId, &query)
+			if check != nil {
+				log.Fatalf("ExportRealm failed: %s %s", check.Msg, check.Description)
+			}
+			fileData, err := json.MarshalIndent(representation, "", "  ")
+			if err != nil {
+				log.Fatalf("json.Marshal failed: %s", err)
+			}
+			if err = os.WriteFile(string(value), fileData, 0600); err != nil {
+				log.Fatalf("WriteFile failed: %s", err)
+			}
+			report = exportReport
+			fmt.Println(sf.Format("Realm: \"{0}\" successfully exported ({1} clients, {2} users, {3} roles, {4} groups)",
+				report.Realm, report.Clients, report.Users, report.Roles, report.Groups))
+		}
+		for _, skipped := range report.Skipped {
+			fmt.Println(sf.Format("  skipped {0} \"{1}\": {2}", skipped.Resource, skipped.Name, skipped.Feature))
+		}
+
 		return
 	default:
 		log.Fatalf("Bad Operation")
 	}
 }
 
-func getRandPassword() string {
-	// TODO(SIA) Move password generation to another location
-	randomBytes := make([]byte, 32)
-	_, err := rand.Read(randomBytes)
+// initialAccessTokenParams is a --value of create_initial_access_token operation, expiration in seconds (0 - never expires),
+// count is a number of clients that could be registered (0 - unlimited)
+type initialAccessTokenParams struct {
+	Expiration int `json:"expiration"`
+	Count      int `json:"count"`
+}
+
+// userValidityParams is a --value of set_validity operation, times are in RFC 3339 format, omitted time removes bound
+type userValidityParams struct {
+	NotBefore  time.Time `json:"not_before"`
+	ValidUntil time.Time `json:"valid_until"`
+}
+
+// resetPasswordParams is an optional --value of reset_password operation, temporary password must be changed by user on login
+// (user gets UPDATE_PASSWORD required action)
+type resetPasswordParams struct {
+	Temporary bool `json:"temporary"`
+}
+
+// setPasswordRequiredAction adds UPDATE_PASSWORD required action to user with temporary password and removes it otherwise
+// (password that administrator set is not temporary), like KeyCloak does on password reset
+func setPasswordRequiredAction(manager managers.DataContext, realmName string, userName string, temporary bool) {
+	user, err := manager.GetUser(realmName, userName)
+	if err != nil {
+		log.Fatalf("GetUser failed: %s", err)
+	}
+	changed := temporary
+	if temporary {
+		err = data.AddRequiredAction(user, data.UpdatePasswordAction)
+	} else {
+		changed, err = data.RemoveRequiredAction(user, data.UpdatePasswordAction)
+	}
+	if err != nil {
+		log.Fatalf("Required actions update failed: %s", err)
+	}
+	if !changed {
+		return
+	}
+	if err = manager.UpdateUser(realmName, userName, user); err != nil {
+		log.Fatalf("UpdateUser failed: %s", err)
+	}
+}
+
+// getPasswordPolicy returns realm password policy, realm without policy requires password at least 8 characters long
+func getPasswordPolicy(realm *data.Realm) *data.PasswordPolicy {
+	if realm.PasswordPolicy != nil {
+		return realm.PasswordPolicy
+	}
+	return &data.PasswordPolicy{MinLength: defaultPasswordMinLength}
+}
+
+// checkPasswordPolicy stops CLI with list of password policy violations if password does not satisfy realm password policy
+func checkPasswordPolicy(realm *data.Realm, user data.User, password string) {
+	if check := services.CheckPasswordPolicy(getPasswordPolicy(realm), user, password); check != nil {
+		log.Fatalf("Password does not satisfy realm password policy: %s", check.Description)
+	}
+}
+
+// hashUserPassword replaces password in plain text from user json with a hash, password is checked against realm password policy
+/* Parameters:
+ *    - manager - data context
+ *    - realmName - name of user realm
+ *    - storedUser - user before update (nil on user creation), password history of stored user is checked
+ *    - user - new user data
+ */
+func hashUserPassword(manager managers.DataContext, realmName string, storedUser data.User, user data.User) {
+	password := user.GetPassword()
+	if len(password) == 0 || hashing.IsPasswordHash(password) {
+		return
+	}
+	realm, err := manager.GetRealm(realmName)
 	if err != nil {
-		log.Fatalf("rand.Read failed: %s", err)
+		log.Fatalf("GetRealm failed: %s", err)
+	}
+	if storedUser != nil {
+		checkPasswordPolicy(realm, storedUser, password)
+	} else {
+		checkPasswordPolicy(realm, user, password)
+	}
+	if err = user.SetPassword(password); err != nil {
+		log.Fatalf("SetPassword failed: %s", err)
+	}
+}
+
+// checkUserStored stops with error if user wasn't created or updated, violations of realm user profile are printed one per line
+func checkUserStored(operation string, err error) {
+	if err == nil {
+		return
+	}
+	var validationErr appErrs.UserValidationError
+	if errors.As(err, &validationErr) {
+		for _, v := range validationErr.Violations {
+			fmt.Println(sf.Format("  {0}: {1}", v.Attribute, v.Description))
+		}
 	}
-	str := base32.StdEncoding.EncodeToString(randomBytes)
-	const length = 8
-	password := str[:length]
-	return password
+	log.Fatalf("%s failed: %s", operation, err)
 }
@@ -11,10 +11,22 @@ const (
 type OperationType string
 
 const (
-	GetOperation    OperationType = "get"
-	CreateOperation               = "create"
-	DeleteOperation               = "delete"
-	UpdateOperation               = "update"
-	ChangePassword                = "change_password"
-	ResetPassword                 = "reset_password"
+	GetOperation             OperationType = "get"
+	CreateOperation                        = "create"
+	DeleteOperation                        = "delete"
+	UpdateOperation                        = "update"
+	ChangePassword                         = "change_password"
+	ResetPassword                          = "reset_password"
+	CreateInitialAccessToken               = "create_initial_access_token"
+	UnlockUser                             = "unlock_user"
+	EnrollOtp                              = "enroll_otp"
+	RemoveOtp                              = "remove_otp"
+	SendExecuteActionsEmail                = "send_execute_actions_email"
+	EnableOperation                        = "enable"
+	DisableOperation                       = "disable"
+	SetValidity                            = "set_validity"
+	GetConsents                            = "get_consents"
+	RevokeConsent                          = "revoke_consent"
+	ImportRealm                            = "import"
+	ExportRealm                            = "export"
 )
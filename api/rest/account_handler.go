@@ -0,0 +1,314 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/services"
+)
+
+// GetAccount this function is a Http Request Handler that returns profile of user that owns access token
+// @Summary Returns user profile
+// @Description Returns user info attributes (the same as userinfo endpoint returns)
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {object} interface{}
+// @Failure 401 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account [get]
+// @Router /realms/{realm}/account [get]
+func (wCtx *WebApiContext) GetAccount(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	_, user, status, errDetails := wCtx.readAccount(respWriter, request, "Account")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	info := user.GetUserInfo()
+	afterHandle(&respWriter, http.StatusOK, &info)
+}
+
+// UpdateAccount this function is a Http Request Handler that updates profile of user that owns access token
+// @Summary Updates user profile
+// @Description Changes user info attributes that request contains (empty value removes attribute), attributes are checked
+// @Description against realm user profile, username can't be changed, email change resets email_verified
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body map[string]string true "Changed attributes"
+// @Success 200 {object} interface{}
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account [post]
+// @Router /realms/{realm}/account [post]
+func (wCtx *WebApiContext) UpdateAccount(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, user, status, errDetails := wCtx.readAccount(respWriter, request, "Account update")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	attributes := map[string]string{}
+	if err := json.NewDecoder(request.Body).Decode(&attributes); err != nil {
+		wCtx.Logger.Debug("Account update: body is bad (unable to unmarshal to attributes map)")
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForAccountMsg})
+		return
+	}
+	if check := (*wCtx.Security).UpdateAccountProfile(realmPtr, user, attributes); check != nil {
+		afterHandle(&respWriter, getAccountErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	info := user.GetUserInfo()
+	afterHandle(&respWriter, http.StatusOK, &info)
+}
+
+// ChangeAccountPassword this function is a Http Request Handler that changes password of user that owns access token
+// @Summary Changes user password
+// @Description Changes password if current password is valid, new password is checked against realm password policy
+// @Tags account
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body dto.PasswordChangeRequest true "Current and new passwords"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/credentials/password [post]
+// @Router /realms/{realm}/account/credentials/password [post]
+func (wCtx *WebApiContext) ChangeAccountPassword(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, user, status, errDetails := wCtx.readAccount(respWriter, request, "Account password change")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	passwordChange := dto.PasswordChangeRequest{}
+	if err := json.NewDecoder(request.Body).Decode(&passwordChange); err != nil {
+		wCtx.Logger.Debug("Account password change: body is bad (unable to unmarshal to dto.PasswordChangeRequest)")
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForAccountMsg})
+		return
+	}
+	if check := (*wCtx.Security).ChangePassword(realmPtr, user, &passwordChange, getClientAddress(request)); check != nil {
+		afterHandle(&respWriter, getAccountErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// GetAccountCredentials this function is a Http Request Handler that returns credentials of user that owns access token
+// @Summary Returns user credentials
+// @Description Returns password, OTP and passkeys of user without secret data
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {array} dto.AccountCredential
+// @Failure 401 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/credentials [get]
+// @Router /realms/{realm}/account/credentials [get]
+func (wCtx *WebApiContext) GetAccountCredentials(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	_, user, status, errDetails := wCtx.readAccount(respWriter, request, "Account credentials")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	credentials := services.GetAccountCredentials(user)
+	afterHandle(&respWriter, http.StatusOK, &credentials)
+}
+
+// EnrollAccountOtp this function is a Http Request Handler that sets up OTP of user that owns access token
+// @Summary Sets up user OTP
+// @Description Creates TOTP credential, otp_uri and recovery codes are returned only once. User that already has OTP must
+// @Description remove it first
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 201 {object} dto.OtpEnrollment
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/credentials/otp [post]
+// @Router /realms/{realm}/account/credentials/otp [post]
+func (wCtx *WebApiContext) EnrollAccountOtp(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, user, status, errDetails := wCtx.readAccount(respWriter, request, "Account OTP enrollment")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	enrollment, check := (*wCtx.Security).EnrollOtp(realmPtr, user)
+	if check != nil {
+		afterHandle(&respWriter, getAccountErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusCreated, enrollment)
+}
+
+// RemoveAccountOtp this function is a Http Request Handler that removes OTP of user that owns access token
+// @Summary Removes user OTP
+// @Description Removes TOTP credential unless realm OTP policy requires it for user
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/credentials/otp [delete]
+// @Router /realms/{realm}/account/credentials/otp [delete]
+func (wCtx *WebApiContext) RemoveAccountOtp(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, user, status, errDetails := wCtx.readAccount(respWriter, request, "Account OTP removal")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if check := (*wCtx.Security).RemoveOtp(realmPtr, user); check != nil {
+		afterHandle(&respWriter, getAccountErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// GetAccountSessions this function is a Http Request Handler that returns sessions of user that owns access token
+// @Summary Returns user sessions
+// @Description Returns active sessions of user, session of access token is marked as current
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {array} dto.AccountSession
+// @Failure 401 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/sessions [get]
+// @Router /realms/{realm}/account/sessions [get]
+func (wCtx *WebApiContext) GetAccountSessions(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, session, status, errDetails := wCtx.readAccountSession(respWriter, request, "Account sessions")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	userSessions := (*wCtx.Security).GetUserSessions(realmPtr.Name, session.UserId)
+	sessions := make([]dto.AccountSession, 0, len(userSessions))
+	for i := range userSessions {
+		s := &userSessions[i]
+		sessions = append(sessions, dto.AccountSession{Id: s.Id.String(), Started: s.Started.Unix(),
+			Expires: services.GetSessionExpiration(s).Unix(), Browser: len(s.BrowserLogins) > 0, Current: s.Id == session.Id})
+	}
+	afterHandle(&respWriter, http.StatusOK, &sessions)
+}
+
+// SignOutAccountSessions this function is a Http Request Handler that signs out sessions of user that owns access token
+// @Summary Signs out user sessions
+// @Description Signs out all other sessions of user, session of access token is also signed out if current is true (KeyCloak
+// @Description account API behaviour)
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param current query bool false "Sign out session of access token too"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/sessions [delete]
+// @Router /realms/{realm}/account/sessions [delete]
+func (wCtx *WebApiContext) SignOutAccountSessions(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, session, status, errDetails := wCtx.readAccountSession(respWriter, request, "Account sessions sign out")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	current, _ := strconv.ParseBool(request.URL.Query().Get("current"))
+	for _, s := range (*wCtx.Security).GetUserSessions(realmPtr.Name, session.UserId) {
+		if s.Id != session.Id || current {
+			(*wCtx.Security).DeleteUserSession(realmPtr.Name, session.UserId, s.Id)
+		}
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// SignOutAccountSession this function is a Http Request Handler that signs out one session of user that owns access token
+// @Summary Signs out user session
+// @Description Signs out session, tokens and browser login of session become invalid
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param sessionId path string true "Session id"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/sessions/{sessionId} [delete]
+// @Router /realms/{realm}/account/sessions/{sessionId} [delete]
+func (wCtx *WebApiContext) SignOutAccountSession(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realmPtr, session, status, errDetails := wCtx.readAccountSession(respWriter, request, "Account session sign out")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	sessionId, err := uuid.Parse(mux.Vars(request)[globals.SessionIdPathVar])
+	if err != nil || !(*wCtx.Security).DeleteUserSession(realmPtr.Name, session.UserId, sessionId) {
+		afterHandle(&respWriter, http.StatusNotFound, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.SessionNotFoundDesc})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// readAccount reads realm and user that owns access token of account API request
+func (wCtx *WebApiContext) readAccount(respWriter http.ResponseWriter, request *http.Request, operation string) (*data.Realm, data.User,
+	int, *dto.ErrorDetails) {
+	realmPtr, status, errDetails := wCtx.readRealm(mux.Vars(request)[globals.RealmPathVar], operation)
+	if errDetails != nil {
+		return nil, nil, status, errDetails
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, realmPtr, operation)
+	if errDetails != nil {
+		return nil, nil, status, errDetails
+	}
+	return realmPtr, user, http.StatusOK, nil
+}
+
+// readAccountSession reads realm and session of access token of account API request
+func (wCtx *WebApiContext) readAccountSession(respWriter http.ResponseWriter, request *http.Request, operation string) (*data.Realm,
+	*data.UserSession, int, *dto.ErrorDetails) {
+	realmPtr, status, errDetails := wCtx.readRealm(mux.Vars(request)[globals.RealmPathVar], operation)
+	if errDetails != nil {
+		return nil, nil, status, errDetails
+	}
+	session, _, status, errDetails := wCtx.readAuthenticatedSession(respWriter, request, realmPtr, operation)
+	if errDetails != nil {
+		return nil, nil, status, errDetails
+	}
+	return realmPtr, session, http.StatusOK, nil
+}
+
+// getAccountErrorStatus returns http status of account operation error
+func getAccountErrorStatus(check *data.OperationError) int {
+	switch {
+	case check.Description == errors.OtpAlreadyConfiguredDesc:
+		return http.StatusConflict
+	case check.Description == errors.OtpNotFoundDesc:
+		return http.StatusNotFound
+	case check.Description == errors.OtpRequiredByPolicyDesc:
+		return http.StatusForbidden
+	case check.Msg == errors.ServiceIsUnavailable:
+		return http.StatusServiceUnavailable
+	case check.Msg == errors.OtherAppError:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,62 @@
+package rest
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/console"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/globals"
+)
+
+// adminConsoleSecurityPolicy allows console to load own scripts and styles only and forbids showing console in frames
+const adminConsoleSecurityPolicy = "default-src 'self'; frame-ancestors 'none'"
+
+// GetAdminConsoleResource this function is a Http Request Handler that returns admin web console page or static file
+// @Summary Returns admin console
+// @Description Returns file of admin web console that is embedded in binary, console root returns console page
+// @Tags admin
+// @Param resource path string false "Resource path"
+// @Success 200
+// @Failure 404
+// @Router /auth/admin/console/{resource} [get]
+// @Router /admin/console/{resource} [get]
+func (wCtx *WebApiContext) GetAdminConsoleResource(respWriter http.ResponseWriter, request *http.Request) {
+	name := mux.Vars(request)[globals.ResourcePathVar]
+	file, err := console.OpenResource(name)
+	if err != nil {
+		http.NotFound(respWriter, request)
+		return
+	}
+	defer file.Close()
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		http.NotFound(respWriter, request)
+		return
+	}
+	if len(name) == 0 {
+		name = console.IndexPage
+	}
+	respWriter.Header().Set("Cache-Control", "no-cache")
+	respWriter.Header().Set("X-Frame-Options", "DENY")
+	respWriter.Header().Set("Content-Security-Policy", adminConsoleSecurityPolicy)
+	http.ServeContent(respWriter, request, name, time.Time{}, content)
+}
+
+// GetAdminConsoleConfig this function is a Http Request Handler that returns admin web console configuration
+// @Summary Returns admin console configuration
+// @Description Returns admin realm, client that console logs in with and paths of token endpoint and Admin REST API
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dto.AdminConsoleConfig
+// @Router /auth/admin/console/config.json [get]
+// @Router /admin/console/config.json [get]
+func (wCtx *WebApiContext) GetAdminConsoleConfig(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	basePath := getBasePath(request)
+	consoleConfig := dto.AdminConsoleConfig{Realm: wCtx.AdminRealm, ClientId: wCtx.AdminConsoleClientId,
+		TokenUrl: basePath + "/realms/" + wCtx.AdminRealm + "/protocol/openid-connect/token", AdminUrl: basePath + "/admin"}
+	afterHandle(&respWriter, http.StatusOK, &consoleConfig)
+}
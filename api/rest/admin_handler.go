@@ -0,0 +1,591 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	locationHeader = "Location"
+	warningHeader  = "Warning"
+)
+
+// GetAdminRealms this function is a Http Request Handler that returns all realms
+// @Summary Returns realms
+// @Description Returns realms that administrator could query (all realms for user with admin role), administrator
+// @Description authenticates with access token of admin realm user
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Success 200 {array} dto.RealmRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Router /auth/admin/realms [get]
+// @Router /admin/realms [get]
+func (wCtx *WebApiContext) GetAdminRealms(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	permissions, status, errDetails := wCtx.authorizeAdmin(respWriter, request, "Admin realms read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	realms, check := (*wCtx.Admin).GetRealms()
+	permitted := make([]dto.RealmRepresentation, 0, len(realms))
+	for _, realm := range realms {
+		if permissions.HasRealmPermission(realm.Realm, data.QueryRealmsPermission) {
+			permitted = append(permitted, realm)
+		}
+	}
+	afterAdminHandle(&respWriter, http.StatusOK, &permitted, check)
+}
+
+// CreateAdminRealm this function is a Http Request Handler that creates realm
+// @Summary Creates realm
+// @Description Creates realm (user with admin role only), body could be a KeyCloak realm export with clients, users (with hashed
+// @Description credentials), realm roles and groups like KeyCloak realm import does, Location header contains url of created realm
+// @Description and body contains import report with KeyCloak features that were skipped
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param function body dto.RealmExportRepresentation true "Realm"
+// @Success 201 {object} dto.RealmMigrationReport
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms [post]
+// @Router /admin/realms [post]
+func (wCtx *WebApiContext) CreateAdminRealm(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin realm create"
+	permissions, status, errDetails := wCtx.authorizeAdmin(respWriter, request, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if !permissions.IsGlobalAdmin() {
+		afterHandle(&respWriter, http.StatusForbidden, wCtx.getAdminPermissionError(data.AdminRole, "", operation))
+		return
+	}
+	representation := dto.RealmExportRepresentation{}
+	if errDetails := wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	report, check := (*wCtx.Admin).ImportRealm(&representation)
+	if check == nil {
+		wCtx.setCreatedLocation(respWriter, request, representation.Realm)
+	}
+	afterAdminHandle(&respWriter, http.StatusCreated, report, check)
+}
+
+// GetAdminRealm this function is a Http Request Handler that returns realm
+// @Summary Returns realm
+// @Description Returns realm settings (disabled realm is also returned)
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {object} dto.RealmRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm} [get]
+// @Router /admin/realms/{realm} [get]
+func (wCtx *WebApiContext) GetAdminRealm(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.QueryRealmsPermission, "Admin realm read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation, check := (*wCtx.Admin).GetRealm(realm)
+	afterAdminHandle(&respWriter, http.StatusOK, representation, check)
+}
+
+// UpdateAdminRealm this function is a Http Request Handler that changes realm settings
+// @Summary Updates realm
+// @Description Changes realm settings that body contains, realm could be renamed (except admin realm)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body dto.RealmRepresentation true "Realm"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm} [put]
+// @Router /admin/realms/{realm} [put]
+func (wCtx *WebApiContext) UpdateAdminRealm(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin realm update"
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageRealmPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.RealmRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).UpdateRealm(realm, &representation))
+}
+
+// DeleteAdminRealm this function is a Http Request Handler that removes realm
+// @Summary Removes realm
+// @Description Removes realm with clients and users, admin realm can't be removed
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm} [delete]
+// @Router /admin/realms/{realm} [delete]
+func (wCtx *WebApiContext) DeleteAdminRealm(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.RealmAdminPermission, "Admin realm delete")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).DeleteRealm(realm))
+}
+
+// ExportAdminRealm this function is a Http Request Handler that exports realm in KeyCloak realm export format
+// @Summary Exports realm
+// @Description Returns realm settings in KeyCloak realm export format (KeyCloak partial export), exportClients adds clients with
+// @Description secrets, exportGroupsAndRoles adds realm roles and groups and exportUsers (Ferrum extension) adds users with password
+// @Description hashes, Ferrum features that KeyCloak realm export can't contain are listed in Warning headers (code 299)
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param exportClients query bool false "Export clients"
+// @Param exportGroupsAndRoles query bool false "Export realm roles and groups"
+// @Param exportUsers query bool false "Export users"
+// @Success 200 {object} dto.RealmExportRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/partial-export [post]
+// @Router /admin/realms/{realm}/partial-export [post]
+func (wCtx *WebApiContext) ExportAdminRealm(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.RealmAdminPermission, "Admin realm export")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	values := request.URL.Query()
+	query := dto.RealmExportQuery{}
+	query.Clients, _ = strconv.ParseBool(values.Get("exportClients"))
+	query.GroupsAndRoles, _ = strconv.ParseBool(values.Get("exportGroupsAndRoles"))
+	query.Users, _ = strconv.ParseBool(values.Get("exportUsers"))
+	representation, report, check := (*wCtx.Admin).ExportRealm(realm, &query)
+	if check == nil {
+		for _, skipped := range report.Skipped {
+			respWriter.Header().Add(warningHeader, "299 - "+strconv.Quote(sf.Format("{0} {1}: {2}", skipped.Resource, skipped.Name,
+				skipped.Feature)))
+		}
+	}
+	afterAdminHandle(&respWriter, http.StatusOK, representation, check)
+}
+
+// GetAdminClients this function is a Http Request Handler that returns realm clients
+// @Summary Returns clients
+// @Description Returns realm clients that administrator could query (without secrets), clientId query parameter returns only
+// @Description client with such name
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param clientId query string false "Client name"
+// @Success 200 {array} dto.ClientRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients [get]
+// @Router /admin/realms/{realm}/clients [get]
+func (wCtx *WebApiContext) GetAdminClients(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin clients read"
+	permissions, status, errDetails := wCtx.authorizeAdmin(respWriter, request, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	realm := mux.Vars(request)[globals.RealmPathVar]
+	if !permissions.HasAnyClientPermission(realm, data.QueryClientsPermission) {
+		afterHandle(&respWriter, http.StatusForbidden, wCtx.getAdminPermissionError(data.QueryClientsPermission, realm, operation))
+		return
+	}
+	clients, check := (*wCtx.Admin).GetClients(realm, request.URL.Query().Get(globals.ClientIdPathVar))
+	permitted := make([]dto.ClientRepresentation, 0, len(clients))
+	for _, client := range clients {
+		if permissions.HasClientPermission(realm, client.ClientId, data.QueryClientsPermission) {
+			permitted = append(permitted, client)
+		}
+	}
+	afterAdminHandle(&respWriter, http.StatusOK, &permitted, check)
+}
+
+// CreateAdminClient this function is a Http Request Handler that creates realm client
+// @Summary Creates client
+// @Description Creates client, confidential client without secret gets generated secret, Location header contains url of
+// @Description created client
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body dto.ClientRepresentation true "Client"
+// @Success 201
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients [post]
+// @Router /admin/realms/{realm}/clients [post]
+func (wCtx *WebApiContext) CreateAdminClient(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin client create"
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageClientsPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.ClientRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	id, check := (*wCtx.Admin).CreateClient(realm, &representation)
+	wCtx.afterAdminCreate(respWriter, request, id.String(), check)
+}
+
+// GetAdminClient this function is a Http Request Handler that returns realm client
+// @Summary Returns client
+// @Description Returns client by identifier (id, not clientId) with secret of confidential client
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "Client identifier"
+// @Success 200 {object} dto.ClientRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients/{id} [get]
+// @Router /admin/realms/{realm}/clients/{id} [get]
+func (wCtx *WebApiContext) GetAdminClient(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminClientRequest(respWriter, request, data.ViewClientsPermission, "Admin client read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	client, check := (*wCtx.Admin).GetClient(realm, id)
+	// clients list doesn't contain secrets, administrator who could view client sees its secret
+	if check == nil {
+		if secret, secretCheck := (*wCtx.Admin).GetClientSecret(realm, id); secretCheck == nil {
+			client.Secret = &secret.Value
+		}
+	}
+	afterAdminHandle(&respWriter, http.StatusOK, client, check)
+}
+
+// UpdateAdminClient this function is a Http Request Handler that changes realm client
+// @Summary Updates client
+// @Description Changes client settings that body contains
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "Client identifier"
+// @Param function body dto.ClientRepresentation true "Client"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients/{id} [put]
+// @Router /admin/realms/{realm}/clients/{id} [put]
+func (wCtx *WebApiContext) UpdateAdminClient(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin client update"
+	realm, id, status, errDetails := wCtx.readAdminClientRequest(respWriter, request, data.ManageClientsPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.ClientRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).UpdateClient(realm, id, &representation))
+}
+
+// DeleteAdminClient this function is a Http Request Handler that removes realm client
+// @Summary Removes client
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "Client identifier"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients/{id} [delete]
+// @Router /admin/realms/{realm}/clients/{id} [delete]
+func (wCtx *WebApiContext) DeleteAdminClient(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminClientRequest(respWriter, request, data.ManageClientsPermission, "Admin client delete")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).DeleteClient(realm, id))
+}
+
+// GetAdminClientSecret this function is a Http Request Handler that returns client secret
+// @Summary Returns client secret
+// @Description Returns secret of confidential client that authenticates with secret
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "Client identifier"
+// @Success 200 {object} dto.CredentialRepresentation
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients/{id}/client-secret [get]
+// @Router /admin/realms/{realm}/clients/{id}/client-secret [get]
+func (wCtx *WebApiContext) GetAdminClientSecret(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminClientRequest(respWriter, request, data.ViewClientsPermission, "Admin client secret read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	secret, check := (*wCtx.Admin).GetClientSecret(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, secret, check)
+}
+
+// RegenerateAdminClientSecret this function is a Http Request Handler that sets new generated client secret
+// @Summary Regenerates client secret
+// @Description Sets new generated secret of confidential client, previous secret becomes invalid
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "Client identifier"
+// @Success 200 {object} dto.CredentialRepresentation
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/clients/{id}/client-secret [post]
+// @Router /admin/realms/{realm}/clients/{id}/client-secret [post]
+func (wCtx *WebApiContext) RegenerateAdminClientSecret(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminClientRequest(respWriter, request, data.ManageClientsPermission, "Admin client secret regenerate")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	secret, check := (*wCtx.Admin).RegenerateClientSecret(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, secret, check)
+}
+
+// authorizeAdmin checks that request has access token of admin realm user and evaluates user admin permissions
+/* Parameters:
+ *    - respWriter - response writer, WWW-Authenticate header is set on DPoP error
+ *    - request - http request with Authorization header
+ *    - operation - handler name for logging
+ * Returns: admin permissions (user effective roles, see data.CreateAdminPermissions), http status and error details (user
+ * without any admin permission is not an administrator)
+ */
+func (wCtx *WebApiContext) authorizeAdmin(respWriter http.ResponseWriter, request *http.Request, operation string) (*data.AdminPermissions,
+	int, *dto.ErrorDetails) {
+	adminRealm, status, errDetails := wCtx.readRealm(wCtx.AdminRealm, operation)
+	if errDetails != nil {
+		return nil, status, errDetails
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, adminRealm, operation)
+	if errDetails != nil {
+		return nil, status, errDetails
+	}
+	permissions := data.CreateAdminPermissions(wCtx.AdminRealm, adminRealm.GetEffectiveRoles(user))
+	if !permissions.HasAnyPermission() {
+		wCtx.Logger.Debug(sf.Format("{0}: user \"{1}\" is not an administrator", operation, user.GetUsername()))
+		return nil, http.StatusForbidden, &dto.ErrorDetails{Msg: errors.AccessDeniedMsg, Description: errors.AdminRoleRequiredDesc}
+	}
+	return permissions, http.StatusOK, nil
+}
+
+// readAdminRequest authorizes admin request and returns name of realm that administrator manages (realm path variable),
+// administrator must have permission in this realm
+func (wCtx *WebApiContext) readAdminRequest(respWriter http.ResponseWriter, request *http.Request, permission string,
+	operation string) (string, int, *dto.ErrorDetails) {
+	permissions, status, errDetails := wCtx.authorizeAdmin(respWriter, request, operation)
+	if errDetails != nil {
+		return "", status, errDetails
+	}
+	realm := mux.Vars(request)[globals.RealmPathVar]
+	if !permissions.HasRealmPermission(realm, permission) {
+		return "", http.StatusForbidden, wCtx.getAdminPermissionError(permission, realm, operation)
+	}
+	return realm, http.StatusOK, nil
+}
+
+// readAdminObjectRequest authorizes admin request and returns managed realm name and object identifier (pathVar path variable),
+// invalid identifier means that object doesn't exist (notFoundDesc)
+func (wCtx *WebApiContext) readAdminObjectRequest(respWriter http.ResponseWriter, request *http.Request, permission string,
+	pathVar string, notFoundDesc string, operation string) (string, uuid.UUID, int, *dto.ErrorDetails) {
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, permission, operation)
+	if errDetails != nil {
+		return "", uuid.Nil, status, errDetails
+	}
+	id, errDetails := readAdminPathId(request, pathVar, notFoundDesc)
+	if errDetails != nil {
+		return "", uuid.Nil, http.StatusNotFound, errDetails
+	}
+	return realm, id, http.StatusOK, nil
+}
+
+// readAdminClientRequest authorizes admin request to client (id path variable) and returns managed realm name and client
+// identifier, administrator must have permission in realm or permission for this client (client is found by identifier
+// to get its clientId, client that administrator can't access is reported as forbidden whether it exists or not)
+func (wCtx *WebApiContext) readAdminClientRequest(respWriter http.ResponseWriter, request *http.Request, permission string,
+	operation string) (string, uuid.UUID, int, *dto.ErrorDetails) {
+	permissions, status, errDetails := wCtx.authorizeAdmin(respWriter, request, operation)
+	if errDetails != nil {
+		return "", uuid.Nil, status, errDetails
+	}
+	realm := mux.Vars(request)[globals.RealmPathVar]
+	if !permissions.HasAnyClientPermission(realm, permission) {
+		return "", uuid.Nil, http.StatusForbidden, wCtx.getAdminPermissionError(permission, realm, operation)
+	}
+	id, errDetails := readAdminPathId(request, globals.IdPathVar, errors.ClientNotFoundDesc)
+	if permissions.HasRealmPermission(realm, permission) {
+		if errDetails != nil {
+			return "", uuid.Nil, http.StatusNotFound, errDetails
+		}
+		return realm, id, http.StatusOK, nil
+	}
+	if errDetails == nil {
+		client, check := (*wCtx.Admin).GetClient(realm, id)
+		if check == nil && permissions.HasClientPermission(realm, client.ClientId, permission) {
+			return realm, id, http.StatusOK, nil
+		}
+	}
+	return "", uuid.Nil, http.StatusForbidden, wCtx.getAdminPermissionError(permission, realm, operation)
+}
+
+// readAdminRolesRequest authorizes admin request and returns managed realm name, object (user or group) identifier and roles
+// from body, see readAdminObjectRequest
+func (wCtx *WebApiContext) readAdminRolesRequest(respWriter http.ResponseWriter, request *http.Request, permission string,
+	pathVar string, notFoundDesc string, operation string) (string, uuid.UUID, []dto.RoleRepresentation, int, *dto.ErrorDetails) {
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, permission, pathVar, notFoundDesc, operation)
+	if errDetails != nil {
+		return "", uuid.Nil, nil, status, errDetails
+	}
+	var roles []dto.RoleRepresentation
+	if errDetails = wCtx.readAdminBody(request, &roles, operation); errDetails != nil {
+		return "", uuid.Nil, nil, http.StatusBadRequest, errDetails
+	}
+	return realm, id, roles, http.StatusOK, nil
+}
+
+// getAdminPermissionError returns error details of admin request that administrator has no permission for
+func (wCtx *WebApiContext) getAdminPermissionError(permission string, realm string, operation string) *dto.ErrorDetails {
+	wCtx.Logger.Debug(sf.Format("{0}: administrator has no permission \"{1}\" in realm \"{2}\"", operation, permission, realm))
+	return &dto.ErrorDetails{Msg: errors.AccessDeniedMsg, Description: sf.Format(errors.AdminPermissionRequiredDesc, permission)}
+}
+
+// readAdminPathId parses identifier path variable, invalid identifier means that object doesn't exist (notFoundDesc)
+func readAdminPathId(request *http.Request, pathVar string, notFoundDesc string) (uuid.UUID, *dto.ErrorDetails) {
+	id, err := uuid.Parse(mux.Vars(request)[pathVar])
+	if err != nil {
+		return uuid.Nil, &dto.ErrorDetails{Msg: errors.NotFoundMsg, Description: notFoundDesc}
+	}
+	return id, nil
+}
+
+// readAdminBody unmarshalls json body of admin request to body
+func (wCtx *WebApiContext) readAdminBody(request *http.Request, body interface{}, operation string) *dto.ErrorDetails {
+	if err := json.NewDecoder(request.Body).Decode(body); err != nil {
+		wCtx.Logger.Debug(sf.Format("{0}: body is bad: {1}", operation, err.Error()))
+		return &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForAdminMsg}
+	}
+	return nil
+}
+
+// setCreatedLocation sets Location header with url of created object (request url + object identifier) like KeyCloak does
+func (wCtx *WebApiContext) setCreatedLocation(respWriter http.ResponseWriter, request *http.Request, id string) {
+	respWriter.Header().Set(locationHeader, sf.Format("{0}://{1}{2}/{3}", wCtx.Schema, wCtx.Address,
+		strings.TrimSuffix(request.URL.Path, "/"), id))
+}
+
+// afterAdminCreate writes response of object create: 201 with Location header or error
+func (wCtx *WebApiContext) afterAdminCreate(respWriter http.ResponseWriter, request *http.Request, id string, check *data.OperationError) {
+	if check == nil {
+		wCtx.setCreatedLocation(respWriter, request, id)
+	}
+	afterAdminHandle(&respWriter, http.StatusCreated, nil, check)
+}
+
+// afterAdminHandle writes result of admin operation with status or operation error
+func afterAdminHandle(respWriter *http.ResponseWriter, status int, result interface{}, check *data.OperationError) {
+	if check != nil {
+		afterHandle(respWriter, getAdminErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(respWriter, status, result)
+}
+
+// getAdminErrorStatus returns http status of admin operation error
+func getAdminErrorStatus(check *data.OperationError) int {
+	switch check.Msg {
+	case errors.NotFoundMsg:
+		return http.StatusNotFound
+	case errors.ConflictMsg:
+		return http.StatusConflict
+	case errors.AccessDeniedMsg:
+		return http.StatusForbidden
+	case errors.NotSupportedMsg:
+		return http.StatusNotImplemented
+	case errors.ServiceIsUnavailable:
+		return http.StatusServiceUnavailable
+	case errors.OtherAppError:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
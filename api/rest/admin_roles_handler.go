@@ -0,0 +1,424 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+// GetAdminRoles this function is a Http Request Handler that returns realm roles
+// @Summary Returns realm roles
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {array} dto.RoleRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/roles [get]
+// @Router /admin/realms/{realm}/roles [get]
+func (wCtx *WebApiContext) GetAdminRoles(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ViewRealmPermission, "Admin roles read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	roles, check := (*wCtx.Admin).GetRoles(realm)
+	afterAdminHandle(&respWriter, http.StatusOK, &roles, check)
+}
+
+// CreateAdminRole this function is a Http Request Handler that creates realm role
+// @Summary Creates realm role
+// @Description Creates realm role, Location header contains url of created role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body dto.RoleRepresentation true "Role"
+// @Success 201
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/roles [post]
+// @Router /admin/realms/{realm}/roles [post]
+func (wCtx *WebApiContext) CreateAdminRole(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin role create"
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageRealmPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.RoleRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	wCtx.afterAdminCreate(respWriter, request, representation.Name, (*wCtx.Admin).CreateRole(realm, &representation))
+}
+
+// GetAdminRole this function is a Http Request Handler that returns realm role by name
+// @Summary Returns realm role
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param roleName path string true "Role name"
+// @Success 200 {object} dto.RoleRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/roles/{roleName} [get]
+// @Router /admin/realms/{realm}/roles/{roleName} [get]
+func (wCtx *WebApiContext) GetAdminRole(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ViewRealmPermission, "Admin role read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	role, check := (*wCtx.Admin).GetRole(realm, mux.Vars(request)[globals.RoleNamePathVar])
+	afterAdminHandle(&respWriter, http.StatusOK, role, check)
+}
+
+// UpdateAdminRole this function is a Http Request Handler that changes realm role
+// @Summary Updates realm role
+// @Description Changes role name and description, renamed role is renamed in users and groups
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param roleName path string true "Role name"
+// @Param function body dto.RoleRepresentation true "Role"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/roles/{roleName} [put]
+// @Router /admin/realms/{realm}/roles/{roleName} [put]
+func (wCtx *WebApiContext) UpdateAdminRole(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin role update"
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageRealmPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.RoleRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	check := (*wCtx.Admin).UpdateRole(realm, mux.Vars(request)[globals.RoleNamePathVar], &representation)
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, check)
+}
+
+// DeleteAdminRole this function is a Http Request Handler that removes realm role
+// @Summary Removes realm role
+// @Description Removes realm role, role is also removed from users and groups
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param roleName path string true "Role name"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/roles/{roleName} [delete]
+// @Router /admin/realms/{realm}/roles/{roleName} [delete]
+func (wCtx *WebApiContext) DeleteAdminRole(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageRealmPermission, "Admin role delete")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).DeleteRole(realm, mux.Vars(request)[globals.RoleNamePathVar]))
+}
+
+// GetAdminRoleUsers this function is a Http Request Handler that returns users that have realm role
+// @Summary Returns role users
+// @Description Returns users that have role directly assigned (not via groups)
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param roleName path string true "Role name"
+// @Success 200 {array} dto.UserRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/roles/{roleName}/users [get]
+// @Router /admin/realms/{realm}/roles/{roleName}/users [get]
+func (wCtx *WebApiContext) GetAdminRoleUsers(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ViewUsersPermission, "Admin role users read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	users, check := (*wCtx.Admin).GetRoleUsers(realm, mux.Vars(request)[globals.RoleNamePathVar])
+	afterAdminHandle(&respWriter, http.StatusOK, &users, check)
+}
+
+// GetAdminGroups this function is a Http Request Handler that returns realm groups
+// @Summary Returns groups
+// @Description Returns realm groups, search query parameter returns groups which names contain it
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param search query string false "Group name"
+// @Success 200 {array} dto.GroupRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups [get]
+// @Router /admin/realms/{realm}/groups [get]
+func (wCtx *WebApiContext) GetAdminGroups(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.QueryGroupsPermission, "Admin groups read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	groups, check := (*wCtx.Admin).GetGroups(realm, request.URL.Query().Get("search"))
+	afterAdminHandle(&respWriter, http.StatusOK, &groups, check)
+}
+
+// CreateAdminGroup this function is a Http Request Handler that creates realm group
+// @Summary Creates group
+// @Description Creates group with realm roles and attributes, Location header contains url of created group
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body dto.GroupRepresentation true "Group"
+// @Success 201
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups [post]
+// @Router /admin/realms/{realm}/groups [post]
+func (wCtx *WebApiContext) CreateAdminGroup(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin group create"
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageUsersPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.GroupRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	id, check := (*wCtx.Admin).CreateGroup(realm, &representation)
+	wCtx.afterAdminCreate(respWriter, request, id.String(), check)
+}
+
+// GetAdminGroup this function is a Http Request Handler that returns realm group
+// @Summary Returns group
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Success 200 {object} dto.GroupRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId} [get]
+// @Router /admin/realms/{realm}/groups/{groupId} [get]
+func (wCtx *WebApiContext) GetAdminGroup(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.QueryGroupsPermission, globals.GroupIdPathVar, errors.GroupNotFoundDesc,
+		"Admin group read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	group, check := (*wCtx.Admin).GetGroup(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, group, check)
+}
+
+// UpdateAdminGroup this function is a Http Request Handler that changes realm group
+// @Summary Updates group
+// @Description Changes group name and attributes, renamed group is renamed in user memberships
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Param function body dto.GroupRepresentation true "Group"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId} [put]
+// @Router /admin/realms/{realm}/groups/{groupId} [put]
+func (wCtx *WebApiContext) UpdateAdminGroup(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin group update"
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.GroupIdPathVar, errors.GroupNotFoundDesc,
+		operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.GroupRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).UpdateGroup(realm, id, &representation))
+}
+
+// DeleteAdminGroup this function is a Http Request Handler that removes realm group
+// @Summary Removes group
+// @Description Removes group, members leave removed group
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId} [delete]
+// @Router /admin/realms/{realm}/groups/{groupId} [delete]
+func (wCtx *WebApiContext) DeleteAdminGroup(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.GroupIdPathVar, errors.GroupNotFoundDesc,
+		"Admin group delete")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).DeleteGroup(realm, id))
+}
+
+// GetAdminGroupMembers this function is a Http Request Handler that returns members of group
+// @Summary Returns group members
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Success 200 {array} dto.UserRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId}/members [get]
+// @Router /admin/realms/{realm}/groups/{groupId}/members [get]
+func (wCtx *WebApiContext) GetAdminGroupMembers(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.GroupIdPathVar, errors.GroupNotFoundDesc,
+		"Admin group members read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	users, check := (*wCtx.Admin).GetGroupMembers(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, &users, check)
+}
+
+// GetAdminGroupRealmRoles this function is a Http Request Handler that returns realm roles of group
+// @Summary Returns group realm roles
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Success 200 {array} dto.RoleRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId}/role-mappings/realm [get]
+// @Router /admin/realms/{realm}/groups/{groupId}/role-mappings/realm [get]
+func (wCtx *WebApiContext) GetAdminGroupRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.GroupIdPathVar, errors.GroupNotFoundDesc,
+		"Admin group realm roles read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	roles, check := (*wCtx.Admin).GetGroupRealmRoles(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, &roles, check)
+}
+
+// AddAdminGroupRealmRoles this function is a Http Request Handler that assigns realm roles to group
+// @Summary Adds group realm roles
+// @Description Assigns realm roles to group, members of group get these roles
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Param function body []dto.RoleRepresentation true "Roles"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId}/role-mappings/realm [post]
+// @Router /admin/realms/{realm}/groups/{groupId}/role-mappings/realm [post]
+func (wCtx *WebApiContext) AddAdminGroupRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, roles, status, errDetails := wCtx.readAdminRolesRequest(respWriter, request, data.ManageUsersPermission, globals.GroupIdPathVar,
+		errors.GroupNotFoundDesc, "Admin group realm roles add")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).AddGroupRealmRoles(realm, id, roles))
+}
+
+// RemoveAdminGroupRealmRoles this function is a Http Request Handler that removes realm roles of group
+// @Summary Removes group realm roles
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param groupId path string true "Group identifier"
+// @Param function body []dto.RoleRepresentation true "Roles"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/groups/{groupId}/role-mappings/realm [delete]
+// @Router /admin/realms/{realm}/groups/{groupId}/role-mappings/realm [delete]
+func (wCtx *WebApiContext) RemoveAdminGroupRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, roles, status, errDetails := wCtx.readAdminRolesRequest(respWriter, request, data.ManageUsersPermission, globals.GroupIdPathVar,
+		errors.GroupNotFoundDesc, "Admin group realm roles remove")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).RemoveGroupRealmRoles(realm, id, roles))
+}
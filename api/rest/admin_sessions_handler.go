@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/services"
+)
+
+// GetAdminUserSessions this function is a Http Request Handler that returns active sessions of realm user
+// @Summary Returns user sessions
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {array} dto.UserSessionRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/sessions [get]
+// @Router /admin/realms/{realm}/users/{id}/sessions [get]
+func (wCtx *WebApiContext) GetAdminUserSessions(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, userId, user, status, errDetails := wCtx.readAdminUserRequest(respWriter, request, data.ViewUsersPermission, "Admin user sessions read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	userSessions := (*wCtx.Security).GetUserSessions(realm, userId)
+	sessions := make([]dto.UserSessionRepresentation, 0, len(userSessions))
+	for i := range userSessions {
+		s := &userSessions[i]
+		sessions = append(sessions, dto.UserSessionRepresentation{Id: s.Id.String(), Username: user.Username, UserId: user.Id,
+			Start: s.Started.UnixMilli(), Expires: services.GetSessionExpiration(s).UnixMilli(), Browser: len(s.BrowserLogins) > 0})
+	}
+	afterHandle(&respWriter, http.StatusOK, &sessions)
+}
+
+// DeleteAdminUserSession this function is a Http Request Handler that signs out one session of realm user
+// @Summary Signs out user session
+// @Description Removes user session, tokens and browser login of session become invalid
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param sessionId path string true "Session id"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/sessions/{sessionId} [delete]
+// @Router /admin/realms/{realm}/users/{id}/sessions/{sessionId} [delete]
+func (wCtx *WebApiContext) DeleteAdminUserSession(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, userId, _, status, errDetails := wCtx.readAdminUserRequest(respWriter, request, data.ManageUsersPermission, "Admin user session sign out")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	sessionId, err := uuid.Parse(mux.Vars(request)[globals.SessionIdPathVar])
+	if err != nil || !(*wCtx.Security).DeleteUserSession(realm, userId, sessionId) {
+		afterHandle(&respWriter, http.StatusNotFound, &dto.ErrorDetails{Msg: errors.NotFoundMsg, Description: errors.SessionNotFoundDesc})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// LogoutAdminUser this function is a Http Request Handler that signs out all sessions of realm user
+// @Summary Signs out user
+// @Description Removes all user sessions (KeyCloak admin API logout)
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/logout [post]
+// @Router /admin/realms/{realm}/users/{id}/logout [post]
+func (wCtx *WebApiContext) LogoutAdminUser(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, userId, _, status, errDetails := wCtx.readAdminUserRequest(respWriter, request, data.ManageUsersPermission, "Admin user logout")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	for _, s := range (*wCtx.Security).GetUserSessions(realm, userId) {
+		(*wCtx.Security).DeleteUserSession(realm, userId, s.Id)
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// GetAdminEvents this function is a Http Request Handler that returns user events of realm
+// @Summary Returns events
+// @Description Returns page of latest user events (logins, token refreshes and their errors) sorted from newest, events are
+// @Description stored in memory and are lost on server restart
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param type query []string false "Event types" collectionFormat(multi)
+// @Param client query string false "Client id"
+// @Param user query string false "User identifier"
+// @Param first query int false "Index of first event"
+// @Param max query int false "Maximum number of events (100 by default)"
+// @Success 200 {array} dto.EventRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/events [get]
+// @Router /admin/realms/{realm}/events [get]
+func (wCtx *WebApiContext) GetAdminEvents(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ViewEventsPermission, "Admin events read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if _, check := (*wCtx.Admin).GetRealm(realm); check != nil {
+		afterAdminHandle(&respWriter, http.StatusOK, nil, check)
+		return
+	}
+	events := make([]dto.EventRepresentation, 0)
+	if wCtx.Events != nil {
+		query := getEventsQuery(request)
+		events = (*wCtx.Events).GetEvents(realm, &query)
+	}
+	afterHandle(&respWriter, http.StatusOK, &events)
+}
+
+// readAdminUserRequest authorizes admin request to user (id path variable) and returns managed realm name, user identifier and user
+func (wCtx *WebApiContext) readAdminUserRequest(respWriter http.ResponseWriter, request *http.Request, permission string,
+	operation string) (string, uuid.UUID, *dto.UserRepresentation, int, *dto.ErrorDetails) {
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, permission, globals.IdPathVar, errors.UserNotFoundDesc,
+		operation)
+	if errDetails != nil {
+		return "", uuid.Nil, nil, status, errDetails
+	}
+	user, check := (*wCtx.Admin).GetUser(realm, id)
+	if check != nil {
+		return "", uuid.Nil, nil, getAdminErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	return realm, id, user, http.StatusOK, nil
+}
+
+func getEventsQuery(request *http.Request) dto.EventsQuery {
+	values := request.URL.Query()
+	query := dto.EventsQuery{Types: values["type"], Client: values.Get("client"), User: values.Get("user"), Max: defaultAdminPageSize}
+	query.First, _ = strconv.Atoi(values.Get("first"))
+	if max, err := strconv.Atoi(values.Get("max")); err == nil {
+		query.Max = max
+	}
+	return query
+}
@@ -0,0 +1,567 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+// defaultAdminPageSize is a number of users that users list returns if max query parameter is not set, KeyCloak uses same value
+const defaultAdminPageSize = 100
+
+// GetAdminUsers this function is a Http Request Handler that returns realm users
+// @Summary Returns users
+// @Description Returns page of users sorted by username, filters match substring (case-insensitive) unless exact is true
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param search query string false "Username, email, first or last name"
+// @Param username query string false "Username"
+// @Param email query string false "Email"
+// @Param firstName query string false "First name"
+// @Param lastName query string false "Last name"
+// @Param exact query bool false "Exact match of filters"
+// @Param first query int false "Index of first user"
+// @Param max query int false "Maximum number of users (100 by default)"
+// @Success 200 {array} dto.UserRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users [get]
+// @Router /admin/realms/{realm}/users [get]
+func (wCtx *WebApiContext) GetAdminUsers(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.QueryUsersPermission, "Admin users read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	query := getUsersQuery(request)
+	users, check := (*wCtx.Admin).GetUsers(realm, &query)
+	afterAdminHandle(&respWriter, http.StatusOK, &users, check)
+}
+
+// CountAdminUsers this function is a Http Request Handler that returns number of realm users
+// @Summary Returns number of users
+// @Description Returns number of users that match filters (the same as users list has)
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {integer} int
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/count [get]
+// @Router /admin/realms/{realm}/users/count [get]
+func (wCtx *WebApiContext) CountAdminUsers(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.QueryUsersPermission, "Admin users count")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	query := getUsersQuery(request)
+	count, check := (*wCtx.Admin).CountUsers(realm, &query)
+	afterAdminHandle(&respWriter, http.StatusOK, &count, check)
+}
+
+// CreateAdminUser this function is a Http Request Handler that creates realm user
+// @Summary Creates user
+// @Description Creates user with password credentials, realm roles and groups of body, Location header contains url of
+// @Description created user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param function body dto.UserRepresentation true "User"
+// @Success 201
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users [post]
+// @Router /admin/realms/{realm}/users [post]
+func (wCtx *WebApiContext) CreateAdminUser(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin user create"
+	realm, status, errDetails := wCtx.readAdminRequest(respWriter, request, data.ManageUsersPermission, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.UserRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	id, check := (*wCtx.Admin).CreateUser(realm, &representation)
+	wCtx.afterAdminCreate(respWriter, request, id.String(), check)
+}
+
+// GetAdminUser this function is a Http Request Handler that returns realm user
+// @Summary Returns user
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {object} dto.UserRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id} [get]
+// @Router /admin/realms/{realm}/users/{id} [get]
+func (wCtx *WebApiContext) GetAdminUser(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	user, check := (*wCtx.Admin).GetUser(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, user, check)
+}
+
+// UpdateAdminUser this function is a Http Request Handler that changes realm user
+// @Summary Updates user
+// @Description Changes user fields that body contains, attributes (if present) replace all user attributes, email change
+// @Description resets email verification
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param function body dto.UserRepresentation true "User"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id} [put]
+// @Router /admin/realms/{realm}/users/{id} [put]
+func (wCtx *WebApiContext) UpdateAdminUser(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin user update"
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	representation := dto.UserRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &representation, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).UpdateUser(realm, id, &representation))
+}
+
+// DeleteAdminUser this function is a Http Request Handler that removes realm user
+// @Summary Removes user
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id} [delete]
+// @Router /admin/realms/{realm}/users/{id} [delete]
+func (wCtx *WebApiContext) DeleteAdminUser(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user delete")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).DeleteUser(realm, id))
+}
+
+// ResetAdminUserPassword this function is a Http Request Handler that sets user password
+// @Summary Resets user password
+// @Description Sets password that is checked against realm password policy, temporary password requires user to change it on next login
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param function body dto.CredentialRepresentation true "Password credential"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/reset-password [put]
+// @Router /admin/realms/{realm}/users/{id}/reset-password [put]
+func (wCtx *WebApiContext) ResetAdminUserPassword(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin user password reset"
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	credential := dto.CredentialRepresentation{}
+	if errDetails = wCtx.readAdminBody(request, &credential, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).ResetPassword(realm, id, &credential))
+}
+
+// GetAdminUserCredentials this function is a Http Request Handler that returns user credentials
+// @Summary Returns user credentials
+// @Description Returns password, OTP and passkeys of user without secret data
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {array} dto.CredentialRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/credentials [get]
+// @Router /admin/realms/{realm}/users/{id}/credentials [get]
+func (wCtx *WebApiContext) GetAdminUserCredentials(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user credentials read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	credentials, check := (*wCtx.Admin).GetUserCredentials(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, &credentials, check)
+}
+
+// DeleteAdminUserCredential this function is a Http Request Handler that removes user credential
+// @Summary Removes user credential
+// @Description Removes user OTP (credential id is otp) or passkey, password can't be removed
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param credentialId path string true "Credential identifier"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/credentials/{credentialId} [delete]
+// @Router /admin/realms/{realm}/users/{id}/credentials/{credentialId} [delete]
+func (wCtx *WebApiContext) DeleteAdminUserCredential(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user credential delete")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	credentialId := mux.Vars(request)[globals.CredentialIdPathVar]
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).DeleteUserCredential(realm, id, credentialId))
+}
+
+// SendAdminExecuteActionsEmail this function is a Http Request Handler that sends email with actions that user must execute
+// @Summary Sends execute actions email
+// @Description Sends link that requires user to execute actions (VERIFY_EMAIL, UPDATE_PASSWORD), lifespan is a link lifetime in seconds
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param lifespan query int false "Link lifetime (seconds)"
+// @Param function body []string true "Actions"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 503 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/execute-actions-email [put]
+// @Router /admin/realms/{realm}/users/{id}/execute-actions-email [put]
+func (wCtx *WebApiContext) SendAdminExecuteActionsEmail(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	operation := "Admin execute actions email"
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc, operation)
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	var actions []string
+	if errDetails = wCtx.readAdminBody(request, &actions, operation); errDetails != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, errDetails)
+		return
+	}
+	lifespan, _ := strconv.Atoi(request.URL.Query().Get("lifespan"))
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).SendExecuteActionsEmail(realm, id, actions, lifespan))
+}
+
+// SendAdminVerifyEmail this function is a Http Request Handler that sends email verification link to user
+// @Summary Sends verify email
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 503 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/send-verify-email [put]
+// @Router /admin/realms/{realm}/users/{id}/send-verify-email [put]
+func (wCtx *WebApiContext) SendAdminVerifyEmail(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin verify email")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).SendVerifyEmail(realm, id))
+}
+
+// GetAdminUserGroups this function is a Http Request Handler that returns groups that user is member of
+// @Summary Returns user groups
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {array} dto.GroupRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/groups [get]
+// @Router /admin/realms/{realm}/users/{id}/groups [get]
+func (wCtx *WebApiContext) GetAdminUserGroups(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user groups read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	groups, check := (*wCtx.Admin).GetUserGroups(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, &groups, check)
+}
+
+// JoinAdminUserGroup this function is a Http Request Handler that makes user a member of group
+// @Summary Adds user to group
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param groupId path string true "Group identifier"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/groups/{groupId} [put]
+// @Router /admin/realms/{realm}/users/{id}/groups/{groupId} [put]
+func (wCtx *WebApiContext) JoinAdminUserGroup(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user group join")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	groupId, errDetails := readAdminPathId(request, globals.GroupIdPathVar, errors.GroupNotFoundDesc)
+	if errDetails != nil {
+		afterHandle(&respWriter, http.StatusNotFound, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).JoinGroup(realm, id, groupId))
+}
+
+// LeaveAdminUserGroup this function is a Http Request Handler that removes user from group
+// @Summary Removes user from group
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param groupId path string true "Group identifier"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/groups/{groupId} [delete]
+// @Router /admin/realms/{realm}/users/{id}/groups/{groupId} [delete]
+func (wCtx *WebApiContext) LeaveAdminUserGroup(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user group leave")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	groupId, errDetails := readAdminPathId(request, globals.GroupIdPathVar, errors.GroupNotFoundDesc)
+	if errDetails != nil {
+		afterHandle(&respWriter, http.StatusNotFound, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).LeaveGroup(realm, id, groupId))
+}
+
+// GetAdminUserRoleMappings this function is a Http Request Handler that returns role mappings of user
+// @Summary Returns user role mappings
+// @Description Returns realm roles that are directly assigned to user
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {object} dto.MappingsRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/role-mappings [get]
+// @Router /admin/realms/{realm}/users/{id}/role-mappings [get]
+func (wCtx *WebApiContext) GetAdminUserRoleMappings(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user role mappings read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	mappings, check := (*wCtx.Admin).GetUserRoleMappings(realm, id)
+	afterAdminHandle(&respWriter, http.StatusOK, mappings, check)
+}
+
+// GetAdminUserRealmRoles this function is a Http Request Handler that returns realm roles that are directly assigned to user
+// @Summary Returns user realm roles
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {array} dto.RoleRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/role-mappings/realm [get]
+// @Router /admin/realms/{realm}/users/{id}/role-mappings/realm [get]
+func (wCtx *WebApiContext) GetAdminUserRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	wCtx.getAdminUserRealmRoles(respWriter, request, false)
+}
+
+// GetAdminUserEffectiveRealmRoles this function is a Http Request Handler that returns effective realm roles of user
+// @Summary Returns user effective realm roles
+// @Description Returns realm roles that are assigned to user directly and via groups
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Success 200 {array} dto.RoleRepresentation
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/role-mappings/realm/composite [get]
+// @Router /admin/realms/{realm}/users/{id}/role-mappings/realm/composite [get]
+func (wCtx *WebApiContext) GetAdminUserEffectiveRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	wCtx.getAdminUserRealmRoles(respWriter, request, true)
+}
+
+// AddAdminUserRealmRoles this function is a Http Request Handler that assigns realm roles to user
+// @Summary Adds user realm roles
+// @Description Assigns realm roles to user, roles are found by name (or by id if name is empty)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param function body []dto.RoleRepresentation true "Roles"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/role-mappings/realm [post]
+// @Router /admin/realms/{realm}/users/{id}/role-mappings/realm [post]
+func (wCtx *WebApiContext) AddAdminUserRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, roles, status, errDetails := wCtx.readAdminRolesRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user realm roles add")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).AddUserRealmRoles(realm, id, roles))
+}
+
+// RemoveAdminUserRealmRoles this function is a Http Request Handler that removes realm roles of user
+// @Summary Removes user realm roles
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param id path string true "User identifier"
+// @Param function body []dto.RoleRepresentation true "Roles"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/admin/realms/{realm}/users/{id}/role-mappings/realm [delete]
+// @Router /admin/realms/{realm}/users/{id}/role-mappings/realm [delete]
+func (wCtx *WebApiContext) RemoveAdminUserRealmRoles(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, id, roles, status, errDetails := wCtx.readAdminRolesRequest(respWriter, request, data.ManageUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user realm roles remove")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	afterAdminHandle(&respWriter, http.StatusNoContent, nil, (*wCtx.Admin).RemoveUserRealmRoles(realm, id, roles))
+}
+
+func (wCtx *WebApiContext) getAdminUserRealmRoles(respWriter http.ResponseWriter, request *http.Request, effective bool) {
+	beforeHandle(&respWriter)
+	realm, id, status, errDetails := wCtx.readAdminObjectRequest(respWriter, request, data.ViewUsersPermission, globals.IdPathVar, errors.UserNotFoundDesc,
+		"Admin user realm roles read")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	roles, check := (*wCtx.Admin).GetUserRealmRoles(realm, id, effective)
+	afterAdminHandle(&respWriter, http.StatusOK, &roles, check)
+}
+
+// getUsersQuery reads users list filters and paging parameters from request query
+func getUsersQuery(request *http.Request) dto.UsersQuery {
+	values := request.URL.Query()
+	query := dto.UsersQuery{Search: values.Get("search"), Username: values.Get("username"), Email: values.Get("email"),
+		FirstName: values.Get("firstName"), LastName: values.Get("lastName"), Max: defaultAdminPageSize}
+	query.Exact, _ = strconv.ParseBool(values.Get("exact"))
+	query.First, _ = strconv.Atoi(values.Get("first"))
+	if max, err := strconv.Atoi(values.Get("max")); err == nil {
+		query.Max = max
+	}
+	return query
+}
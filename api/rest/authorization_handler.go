@@ -0,0 +1,369 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const loginActionPath = "login-actions/authenticate"
+
+// Authorize this function is a Http Request Handler that is an OpenId Connect authorization endpoint (authorization code flow)
+// @Summary Starts browser login
+// @Description Shows login form or, if user is already logged in (identity cookie), redirects to redirect_uri with code
+// @Description (consent screen is shown before redirect if client requires consent)
+// @Tags authorization
+// @Produce html
+// @Param realm path string true "Realm"
+// @Param client_id query string true "Client id"
+// @Param response_type query string true "code"
+// @Param redirect_uri query string true "Registered client redirect uri"
+// @Param prompt query string false "none, login, consent or create (registration form)"
+// @Param max_age query int false "Max seconds since user entered credentials"
+// @Param login_hint query string false "Username"
+// @Success 200
+// @Success 302
+// @Failure 400
+// @Router /auth/realms/{realm}/protocol/openid-connect/auth [get]
+// @Router /auth/realms/{realm}/protocol/openid-connect/auth [post]
+// @Router /realms/{realm}/protocol/openid-connect/auth [get]
+// @Router /realms/{realm}/protocol/openid-connect/auth [post]
+func (wCtx *WebApiContext) Authorize(respWriter http.ResponseWriter, request *http.Request) {
+	/* Request parameters are passed via query string (GET) or x-www-form-urlencoded body (POST) or are taken from pushed
+	 * authorization request (request_uri). Errors are passed to client redirect_uri (error and state parameters) only
+	 * if redirect_uri is registered for client, otherwise user sees error page
+	 */
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Authorization")
+	if errDetails != nil {
+		wCtx.writeLoginPage(respWriter, request, nil, request.URL.Query().Get("ui_locales"), status,
+			&loginPage{Realm: realm, Error: errDetails.Msg})
+		return
+	}
+	authRequest := dto.AuthorizationRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&authRequest, request.Form)
+	}
+	if err != nil {
+		wCtx.Logger.Debug("Authorization: request is bad (unable to unmarshal to dto.AuthorizationRequest)")
+		wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusBadRequest,
+			&loginPage{Realm: realm, Error: errors.BadBodyForAuthorizationMsg})
+		return
+	}
+	client := findRealmClient(realmPtr, authRequest.ClientId)
+	if client == nil || !client.IsEnabled() {
+		wCtx.Logger.Debug("Authorization: client doesn't exist or is disabled")
+		wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusBadRequest,
+			&loginPage{Realm: realm, Error: errors.InvalidClientMsg})
+		return
+	}
+	resolvedRequest, check := wCtx.resolveAuthorizationRequest(realm, client, &authRequest)
+	if check != nil {
+		if len(authRequest.RequestUri) == 0 && client.IsRedirectUriAllowed(authRequest.RedirectUri) {
+			wCtx.redirectWithError(respWriter, request, &authRequest, check)
+			return
+		}
+		wCtx.Logger.Debug(sf.Format("Authorization: invalid authorization request: {0}", check.Msg))
+		wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusBadRequest,
+			&loginPage{Realm: realm, Error: getErrorText(check)})
+		return
+	}
+	if check = validateLoginRequest(resolvedRequest); check != nil {
+		wCtx.redirectWithError(respWriter, request, resolvedRequest, check)
+		return
+	}
+
+	// silent re-authentication: user that has logged in (identity cookie) gets code without login form
+	session, user := wCtx.readSsoSession(request, realmPtr)
+	if session != nil && !isSsoSessionAcceptable(session, user, resolvedRequest) {
+		session = nil
+	}
+	isNonePrompt := isValueSupported(strings.Fields(resolvedRequest.Prompt), globals.NonePrompt)
+	if realmPtr.RegistrationAllowed && isValueSupported(strings.Fields(resolvedRequest.Prompt), globals.CreatePrompt) {
+		wCtx.showRegistrationPage(respWriter, request, realmPtr, client, resolvedRequest, nil, http.StatusOK, nil)
+		return
+	}
+	if session == nil {
+		if isNonePrompt {
+			wCtx.redirectWithError(respWriter, request, resolvedRequest,
+				&data.OperationError{Msg: errors.LoginRequiredMsg, Description: errors.LoginRequiredDesc})
+			return
+		}
+		wCtx.showLoginPage(respWriter, request, realmPtr, client, resolvedRequest, resolvedRequest.LoginHint, http.StatusOK, nil)
+		return
+	}
+	if len(user.GetRequiredActions()) > 0 {
+		if isNonePrompt {
+			wCtx.redirectWithError(respWriter, request, resolvedRequest,
+				&data.OperationError{Msg: errors.InteractionRequiredMsg, Description: errors.AccountNotSetUpDesc})
+			return
+		}
+		wCtx.showLoginPage(respWriter, request, realmPtr, client, resolvedRequest, user.GetUsername(), http.StatusOK, nil)
+		return
+	}
+	wCtx.completeLogin(respWriter, request, realmPtr, client, resolvedRequest, session, user)
+}
+
+// AuthenticateLogin this function is a Http Request Handler that checks credentials from browser login form
+// @Summary Browser login form submission
+// @Description Checks user credentials, starts browser login (identity cookie) and redirects to client redirect_uri with code
+// @Description or shows consent screen
+// @Tags authorization
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param realm path string true "Realm"
+// @Param client_id formData string true "Client id"
+// @Param request_uri formData string true "Login form reference"
+// @Param username formData string true "Username"
+// @Param password formData string true "Password"
+// @Param totp formData string false "One-time password"
+// @Success 200
+// @Success 302
+// @Failure 400
+// @Failure 401
+// @Router /auth/realms/{realm}/login-actions/authenticate [post]
+// @Router /realms/{realm}/login-actions/authenticate [post]
+func (wCtx *WebApiContext) AuthenticateLogin(respWriter http.ResponseWriter, request *http.Request) {
+	/* Login form keeps only reference (request_uri) to authorization request that is stored on server, reference is a
+	 * one-time value, therefore form shown after failed login contains new reference
+	 */
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Browser login")
+	if errDetails != nil {
+		wCtx.writeLoginPage(respWriter, request, nil, "", status, &loginPage{Realm: realm, Error: errDetails.Msg})
+		return
+	}
+	loginRequest := dto.LoginFormRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&loginRequest, request.PostForm)
+	}
+	var authRequest *dto.AuthorizationRequest
+	if err == nil {
+		authRequest = (*wCtx.Security).GetPushedAuthorizationRequest(realm, loginRequest.ClientId, loginRequest.RequestUri)
+	}
+	client := findRealmClient(realmPtr, loginRequest.ClientId)
+	if authRequest == nil || client == nil || !client.IsEnabled() {
+		wCtx.Logger.Debug("Browser login: login form is expired or client is disabled")
+		wCtx.writeLoginPage(respWriter, request, realmPtr, "", http.StatusBadRequest, &loginPage{Realm: realm, Error: errors.LoginPageExpiredDesc})
+		return
+	}
+
+	tokenGenerationData := dto.TokenGenerationData{ClientId: loginRequest.ClientId, Username: loginRequest.Username,
+		Password: loginRequest.Password, Totp: loginRequest.Totp, ClientAddress: getClientAddress(request)}
+	if check := (*wCtx.Security).CheckCredentials(&tokenGenerationData, realmPtr); check != nil {
+		wCtx.Logger.Debug("Browser login: invalid user credentials (username, password or one-time password)")
+		wCtx.showLoginPage(respWriter, request, realmPtr, client, authRequest, loginRequest.Username, http.StatusUnauthorized, check)
+		return
+	}
+	user := (*wCtx.Security).GetCurrentUserByName(realm, loginRequest.Username)
+	if len(user.GetRequiredActions()) > 0 {
+		wCtx.Logger.Debug(sf.Format("Browser login: user \"{0}\" has pending required actions", user.GetUsername()))
+		check := &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.AccountNotSetUpDesc}
+		wCtx.showLoginPage(respWriter, request, realmPtr, client, authRequest, loginRequest.Username, http.StatusBadRequest, check)
+		return
+	}
+	session := wCtx.startSsoSession(respWriter, request, realmPtr, authRequest, user)
+	if session == nil {
+		return
+	}
+	wCtx.completeLogin(respWriter, request, realmPtr, client, authRequest, session, user)
+}
+
+// checkAuthorizationCodeGrant validates authorization code grant (grant_type=authorization_code) on token endpoint
+/* Parameters:
+ *    - realmPtr - realm
+ *    - tokenGenerationData - token request with client credentials, code, redirect_uri and code_verifier (PKCE)
+ * Returns: user that logged in or http status with error details
+ */
+func (wCtx *WebApiContext) checkAuthorizationCodeGrant(realmPtr *data.Realm, tokenGenerationData *dto.TokenGenerationData) (data.User, int, *dto.ErrorDetails) {
+	check := (*wCtx.Security).Validate(tokenGenerationData, realmPtr)
+	if check != nil {
+		wCtx.Logger.Debug("New token issue: client data is invalid (client_id or client_secret)")
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	code, check := (*wCtx.Security).ExchangeAuthorizationCode(realmPtr.Name, tokenGenerationData.ClientId, tokenGenerationData.Code,
+		tokenGenerationData.RedirectUri, tokenGenerationData.CodeVerifier)
+	if check != nil {
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	user := (*wCtx.Security).GetCurrentUserById(realmPtr.Name, code.UserId)
+	if user == nil {
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidUserCredentialsDesc}
+	}
+	return user, http.StatusOK, nil
+}
+
+// showLoginPage stores authorization request until user submits login form and renders form, check is an error of previous attempt
+func (wCtx *WebApiContext) showLoginPage(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm, client *data.Client,
+	authRequest *dto.AuthorizationRequest, userName string, status int, check *data.OperationError) {
+	page := loginPage{Realm: realmPtr.Name, ClientName: client.DisplayName, ClientId: client.Name, Username: userName,
+		ActionUrl: getRealmPath(request, realmPtr.Name) + loginActionPath}
+	if len(page.ClientName) == 0 {
+		page.ClientName = client.Name
+	}
+	page.RequestUri = (*wCtx.Security).StorePushedAuthorizationRequest(realmPtr.Name, authRequest, globals.LoginPageExpiration)
+	if realmPtr.RegistrationAllowed {
+		// registration link references own copy of request because login form reference is a one-time value
+		params := url.Values{}
+		params.Set("client_id", client.Name)
+		params.Set("request_uri", (*wCtx.Security).StorePushedAuthorizationRequest(realmPtr.Name, authRequest, globals.LoginPageExpiration))
+		page.RegistrationUrl = getRealmPath(request, realmPtr.Name) + registrationActionPath + "?" + params.Encode()
+	}
+	if check != nil {
+		page.Error = getErrorText(check)
+		page.OtpRequired = check.Description == errors.OtpRequiredDesc || check.Description == errors.InvalidOtpDesc
+	}
+	wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, status, &page)
+}
+
+// startSsoSession starts browser login of user and sets identity cookie, nil session means that error page was already written
+func (wCtx *WebApiContext) startSsoSession(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm,
+	authRequest *dto.AuthorizationRequest, user data.User) *data.UserSession {
+	identity, err := (*wCtx.Security).StartSsoSession(realmPtr, user.GetId())
+	if err != nil {
+		wCtx.Logger.Error(sf.Format("Browser login: unable to start SSO session: {0}", err.Error()))
+		wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusInternalServerError,
+			&loginPage{Realm: realmPtr.Name, Error: errors.OtherAppError})
+		return nil
+	}
+	http.SetCookie(respWriter, &http.Cookie{Name: globals.IdentityCookie, Value: identity, Path: getRealmPath(request, realmPtr.Name),
+		MaxAge: realmPtr.GetSsoSessionLifespan(), HttpOnly: true, Secure: strings.EqualFold(wCtx.Schema, "https"),
+		SameSite: http.SameSiteLaxMode})
+	return (*wCtx.Security).GetSession(realmPtr.Name, user.GetId())
+}
+
+// readSsoSession returns session of identity cookie and its user if browser login is not expired and user could log in
+func (wCtx *WebApiContext) readSsoSession(request *http.Request, realmPtr *data.Realm) (*data.UserSession, data.User) {
+	cookie, err := request.Cookie(globals.IdentityCookie)
+	if err != nil {
+		return nil, nil
+	}
+	session := (*wCtx.Security).GetSessionByIdentity(realmPtr.Name, cookie.Value)
+	if session == nil {
+		return nil, nil
+	}
+	user := (*wCtx.Security).GetCurrentUserById(realmPtr.Name, session.UserId)
+	if user == nil || (*wCtx.Security).CheckUserState(realmPtr, user) != nil {
+		return nil, nil
+	}
+	return session, user
+}
+
+// redirectWithCode issues authorization code for session user and redirects user agent to client redirect_uri
+func (wCtx *WebApiContext) redirectWithCode(respWriter http.ResponseWriter, request *http.Request, realm string,
+	authRequest *dto.AuthorizationRequest, session *data.UserSession) {
+	code := data.AuthorizationCode{ClientId: authRequest.ClientId, RedirectUri: authRequest.RedirectUri, UserId: session.UserId,
+		Scope: authRequest.Scope, Nonce: authRequest.Nonce, CodeChallenge: authRequest.CodeChallenge,
+		CodeChallengeMethod: authRequest.CodeChallengeMethod, AuthTime: session.AuthTime}
+	if len(code.CodeChallenge) > 0 && len(code.CodeChallengeMethod) == 0 {
+		code.CodeChallengeMethod = data.PlainCodeChallengeMethod
+	}
+	params := url.Values{}
+	params.Set("code", (*wCtx.Security).StoreAuthorizationCode(realm, &code, globals.AuthorizationCodeExpiration))
+	params.Set("session_state", session.Id.String())
+	wCtx.redirectToClient(respWriter, request, authRequest, params)
+}
+
+// isSsoSessionAcceptable checks whether browser login could be used for authorization request without login form
+func isSsoSessionAcceptable(session *data.UserSession, user data.User, authRequest *dto.AuthorizationRequest) bool {
+	if isValueSupported(strings.Fields(authRequest.Prompt), globals.LoginPrompt) {
+		return false
+	}
+	if len(authRequest.LoginHint) > 0 && authRequest.LoginHint != user.GetUsername() {
+		return false
+	}
+	if len(authRequest.MaxAge) > 0 {
+		maxAge, _ := strconv.Atoi(authRequest.MaxAge)
+		if session.AuthTime.Add(time.Second * time.Duration(maxAge)).Before(time.Now()) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLoginRequest checks authorization request parameters that only authorization endpoint uses (prompt, max_age, PKCE)
+func validateLoginRequest(authRequest *dto.AuthorizationRequest) *data.OperationError {
+	prompts := strings.Fields(authRequest.Prompt)
+	if isValueSupported(prompts, globals.NonePrompt) && len(prompts) > 1 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.InvalidPromptDesc}
+	}
+	if len(authRequest.MaxAge) > 0 {
+		if maxAge, err := strconv.Atoi(authRequest.MaxAge); err != nil || maxAge < 0 {
+			return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.InvalidMaxAgeDesc}
+		}
+	}
+	if !data.IsCodeChallengeMethodSupported(authRequest.CodeChallengeMethod) {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedCodeChallengeMethodDesc}
+	}
+	return nil
+}
+
+// redirectWithError passes authorization error to client redirect_uri (redirect_uri must be already validated)
+func (wCtx *WebApiContext) redirectWithError(respWriter http.ResponseWriter, request *http.Request, authRequest *dto.AuthorizationRequest, check *data.OperationError) {
+	params := url.Values{}
+	params.Set("error", check.Msg)
+	if len(check.Description) > 0 {
+		params.Set("error_description", check.Description)
+	}
+	wCtx.redirectToClient(respWriter, request, authRequest, params)
+}
+
+// redirectToClient redirects user agent to redirect_uri with params and state in query (default) or in fragment (response_mode=fragment)
+func (wCtx *WebApiContext) redirectToClient(respWriter http.ResponseWriter, request *http.Request, authRequest *dto.AuthorizationRequest, params url.Values) {
+	if len(authRequest.State) > 0 {
+		params.Set("state", authRequest.State)
+	}
+	redirectUri, err := url.Parse(authRequest.RedirectUri)
+	if err != nil {
+		wCtx.writeLoginPage(respWriter, request, nil, authRequest.UiLocales, http.StatusBadRequest,
+			&loginPage{Realm: mux.Vars(request)[globals.RealmPathVar], Error: errors.InvalidRedirectUriDesc})
+		return
+	}
+	if authRequest.ResponseMode == globals.FragmentResponseMode {
+		redirectUri.Fragment = params.Encode()
+	} else {
+		query := redirectUri.Query()
+		for key, values := range params {
+			query[key] = values
+		}
+		redirectUri.RawQuery = query.Encode()
+	}
+	respWriter.Header().Set("Cache-Control", "no-store")
+	http.Redirect(respWriter, request, redirectUri.String(), http.StatusFound)
+}
+
+// getRealmPath returns realm path prefix of request (/auth/realms/{realm}/ or /realms/{realm}/), identity cookie is limited by it
+func getRealmPath(request *http.Request, realm string) string {
+	realmPath := "/realms/" + realm + "/"
+	index := strings.Index(request.URL.Path, realmPath)
+	if index < 0 {
+		return realmPath
+	}
+	return request.URL.Path[:index] + realmPath
+}
+
+// getErrorText returns text that is shown to user on login page
+func getErrorText(check *data.OperationError) string {
+	if len(check.Description) > 0 {
+		return check.Description
+	}
+	return check.Msg
+}
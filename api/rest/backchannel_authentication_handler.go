@@ -0,0 +1,209 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// BackChannelAuthenticate this function is a Http Request Handler that starts Client-Initiated Backchannel Authentication (CIBA)
+// @Summary Starts CIBA authentication of user identified by login_hint
+// @Description Starts CIBA authentication of user identified by login_hint
+// @Tags authorization
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param function body dto.BackChannelAuthenticationRequest true "Backchannel authentication request"
+// @Param realm path string true "Realm"
+// @Success 200 {object} dto.BackChannelAuthenticationResult
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/ciba/auth [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/ciba/auth [post]
+func (wCtx *WebApiContext) BackChannelAuthenticate(respWriter http.ResponseWriter, request *http.Request) {
+	/* Client sends POST request of type x-www-form-urlencoded with client_id, client_secret (for Confidential clients), scope
+	 * (must contain openid), login_hint (username), optional binding_message and client_notification_token (required in ping mode).
+	 * Server notifies user authentication device and returns auth_req_id that client uses on token endpoint
+	 */
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Backchannel authentication")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if wCtx.BackChannelAuth == nil {
+		wCtx.Logger.Debug("Backchannel authentication: CIBA is not configured")
+		result := dto.ErrorDetails{Msg: errors.UnauthorizedClientMsg, Description: errors.CibaNotEnabledDesc}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	authRequest := dto.BackChannelAuthenticationRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&authRequest, request.PostForm)
+	}
+	if err != nil {
+		wCtx.Logger.Debug("Backchannel authentication: body is bad (unable to unmarshal to dto.BackChannelAuthenticationRequest)")
+		result := dto.ErrorDetails{Msg: errors.BadBodyForAuthorizationMsg}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	clientId, check := wCtx.authenticateClient(request, realmPtr)
+	if check != nil {
+		wCtx.Logger.Debug("Backchannel authentication: invalid client credentials")
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, http.StatusUnauthorized, &result)
+		return
+	}
+	client := findRealmClient(realmPtr, clientId)
+	check = validateBackChannelRequest(client, &authRequest)
+	if check != nil {
+		wCtx.Logger.Debug(sf.Format("Backchannel authentication: invalid request: {0}", check.Description))
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	user := (*wCtx.Security).GetCurrentUserByName(realmPtr.Name, authRequest.LoginHint)
+	if user == nil {
+		wCtx.Logger.Debug("Backchannel authentication: unknown user")
+		result := dto.ErrorDetails{Msg: errors.UnknownUserIdMsg, Description: errors.InvalidUserCredentialsDesc}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	cibaRequest := data.BackChannelAuthenticationRequest{
+		ClientId: client.Name, UserId: user.GetId(), Username: user.GetUsername(), Scope: authRequest.Scope,
+		BindingMessage: authRequest.BindingMessage, DeliveryMode: client.BackChannelTokenDeliveryMode,
+		ClientNotificationToken: authRequest.ClientNotificationToken, NotificationEndpoint: client.BackChannelClientNotificationEndpoint,
+	}
+	callbackUri := sf.Format("{0}/protocol/openid-connect/ext/ciba/auth/callback", wCtx.getRealmIssuer(realm))
+	err = (*wCtx.BackChannelAuth).StartAuthentication(realm, &cibaRequest, authRequest.RequestedExpiry, callbackUri)
+	if err != nil {
+		wCtx.Logger.Error(sf.Format("Backchannel authentication: unable to start authentication: {0}", err.Error()))
+		result := dto.ErrorDetails{Msg: errors.ServiceIsUnavailable, Description: errors.DeviceNotificationFailedDesc}
+		afterHandle(&respWriter, http.StatusServiceUnavailable, &result)
+		return
+	}
+	expiresIn := int(time.Until(cibaRequest.Expired).Round(time.Second).Seconds())
+	result := dto.BackChannelAuthenticationResult{AuthReqId: cibaRequest.AuthReqId, ExpiresIn: expiresIn, Interval: cibaRequest.Interval}
+	afterHandle(&respWriter, http.StatusOK, &result)
+}
+
+// BackChannelAuthenticationCallback this function is a Http Request Handler that receives user decision from authentication device
+// @Summary Receives user CIBA decision from authentication device
+// @Description Receives user CIBA decision from authentication device
+// @Tags authorization
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer CALLBACK_TOKEN"
+// @Param function body dto.AuthenticationDeviceCallback true "User decision"
+// @Param realm path string true "Realm"
+// @Success 200
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/ciba/auth/callback [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/ciba/auth/callback [post]
+func (wCtx *WebApiContext) BackChannelAuthenticationCallback(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	_, status, errDetails := wCtx.readRealm(realm, "Backchannel authentication callback")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if wCtx.BackChannelAuth == nil {
+		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.CibaNotEnabledDesc}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	callbackToken, ok := getBearerToken(request)
+	if !ok {
+		wCtx.Logger.Debug("Backchannel authentication callback: expected Bearer authorization")
+		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.InvalidRequestDesc}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	callback := dto.AuthenticationDeviceCallback{}
+	if err := json.NewDecoder(request.Body).Decode(&callback); err != nil {
+		wCtx.Logger.Debug("Backchannel authentication callback: body is bad (unable to unmarshal to dto.AuthenticationDeviceCallback)")
+		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	check := (*wCtx.BackChannelAuth).CompleteAuthentication(realm, callbackToken, data.BackChannelAuthenticationStatus(callback.Status))
+	if check != nil {
+		wCtx.Logger.Debug(sf.Format("Backchannel authentication callback: {0}", check.Description))
+		status = http.StatusBadRequest
+		if check.Msg == errors.InvalidTokenMsg {
+			status = http.StatusUnauthorized
+		}
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, status, &result)
+		return
+	}
+	afterHandle(&respWriter, http.StatusOK, nil)
+}
+
+// checkBackChannelGrant validates CIBA grant (grant_type=urn:openid:params:grant-type:ciba) on token endpoint
+/* Parameters:
+ *    - realmPtr - realm
+ *    - tokenGenerationData - token request with client credentials and auth_req_id
+ * Returns: user that approved request or http status with error details (authorization_pending, slow_down, access_denied, ...)
+ */
+func (wCtx *WebApiContext) checkBackChannelGrant(realmPtr *data.Realm, tokenGenerationData *dto.TokenGenerationData) (data.User, int, *dto.ErrorDetails) {
+	check := (*wCtx.Security).Validate(tokenGenerationData, realmPtr)
+	if check != nil {
+		wCtx.Logger.Debug("New token issue: client data is invalid (client_id or client_secret)")
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	if wCtx.BackChannelAuth == nil {
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.UnauthorizedClientMsg, Description: errors.CibaNotEnabledDesc}
+	}
+	cibaRequest, check := (*wCtx.BackChannelAuth).PollAuthentication(realmPtr.Name, tokenGenerationData.ClientId, tokenGenerationData.AuthReqId)
+	if check != nil {
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	user := (*wCtx.Security).GetCurrentUserById(realmPtr.Name, cibaRequest.UserId)
+	if user == nil {
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidUserCredentialsDesc}
+	}
+	return user, http.StatusOK, nil
+}
+
+// validateBackChannelRequest checks that client is allowed to use CIBA and request contains all required parameters
+func validateBackChannelRequest(client *data.Client, authRequest *dto.BackChannelAuthenticationRequest) *data.OperationError {
+	if client == nil {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCredentialDesc}
+	}
+	if client.BackChannelTokenDeliveryMode != data.PollDeliveryMode && client.BackChannelTokenDeliveryMode != data.PingDeliveryMode {
+		return &data.OperationError{Msg: errors.UnauthorizedClientMsg, Description: errors.CibaNotAllowedDesc}
+	}
+	if !isValueSupported(strings.Fields(authRequest.Scope), globals.OpenIdScope) {
+		return &data.OperationError{Msg: errors.InvalidScopeMsg, Description: errors.OpenIdScopeRequiredDesc}
+	}
+	if len(authRequest.LoginHint) == 0 {
+		return &data.OperationError{Msg: errors.UnknownUserIdMsg, Description: errors.InvalidUserCredentialsDesc}
+	}
+	if client.BackChannelTokenDeliveryMode == data.PingDeliveryMode &&
+		(len(authRequest.ClientNotificationToken) == 0 || len(client.BackChannelClientNotificationEndpoint) == 0) {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.NotificationTokenRequiredDesc}
+	}
+	return nil
+}
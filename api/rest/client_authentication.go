@@ -0,0 +1,146 @@
+package rest
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	basicAuthorization         = "Basic"
+	clientIdFormKey            = "client_id"
+	clientSecretFormKey        = "client_secret"
+	clientAssertionTypeFormKey = "client_assertion_type"
+	clientAssertionFormKey     = "client_assertion"
+)
+
+// readClientAuthentication completes client authentication data (that was taken from a request body) with request data
+/* Client could authenticate with client_secret_post (client_id && client_secret in body), client_secret_basic (Authorization: Basic header),
+ * client_secret_jwt or private_key_jwt (client_assertion in body), tls_client_auth or self_signed_tls_client_auth (client certificate),
+ * but must not use more than one method. This function takes client_id && client_secret from Basic Authorization header, client
+ * certificate and address from connection and sets audiences that client_assertion could be issued for
+ * Parameters:
+ *    - request - http request (form should be already parsed)
+ *    - realm - name of a realm
+ *    - clientData - client authentication data from request body
+ * Returns: nil if request client authentication is consistent, otherwise error with description
+ */
+func (wCtx *WebApiContext) readClientAuthentication(request *http.Request, realm string, clientData *dto.TokenGenerationData) *data.OperationError {
+	issuer := wCtx.getRealmIssuer(realm)
+	clientData.ClientAssertionAudiences = []string{
+		issuer, sf.Format("{0}/protocol/openid-connect/token", issuer),
+		sf.Format("{0}://{1}{2}", wCtx.Schema, wCtx.Address, request.URL.Path),
+	}
+	clientData.ClientCertificate = getClientCertificate(request)
+	clientData.ClientAddress = getClientAddress(request)
+	authorization := request.Header.Get(authorizationHeader)
+	if !strings.HasPrefix(authorization, basicAuthorization+" ") {
+		return nil
+	}
+	if len(clientData.ClientSecret) > 0 || len(clientData.ClientAssertion) > 0 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.MultipleClientAuthMethodsDesc}
+	}
+	clientId, clientSecret, ok := decodeBasicCredentials(strings.TrimPrefix(authorization, basicAuthorization+" "))
+	if !ok || (len(clientData.ClientId) > 0 && clientData.ClientId != clientId) {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCredentialDesc}
+	}
+	clientData.ClientId = clientId
+	clientData.ClientSecret = clientSecret
+	return nil
+}
+
+// authenticateClient authenticates client of PAR, CIBA and introspection requests with any supported method
+/* Parameters:
+ *    - request - http request with already parsed form
+ *    - realmPtr - realm
+ * Returns: authenticated client id and nil or error if client authentication failed
+ */
+func (wCtx *WebApiContext) authenticateClient(request *http.Request, realmPtr *data.Realm) (string, *data.OperationError) {
+	clientData := dto.TokenGenerationData{
+		ClientId: request.PostForm.Get(clientIdFormKey), ClientSecret: request.PostForm.Get(clientSecretFormKey),
+		ClientAssertionType: request.PostForm.Get(clientAssertionTypeFormKey), ClientAssertion: request.PostForm.Get(clientAssertionFormKey),
+	}
+	check := wCtx.readClientAuthentication(request, realmPtr.Name, &clientData)
+	if check == nil {
+		check = (*wCtx.Security).Validate(&clientData, realmPtr)
+	}
+	return clientData.ClientId, check
+}
+
+// decodeBasicCredentials decodes base64({client_id}:{client_secret}), client_id and client_secret are form-urlencoded (RFC 6749 section 2.3.1)
+func decodeBasicCredentials(value string) (string, string, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", "", false
+	}
+	pair := strings.SplitN(string(decoded), ":", 2)
+	if len(pair) != 2 {
+		return "", "", false
+	}
+	clientId, idErr := url.QueryUnescape(pair[0])
+	clientSecret, secretErr := url.QueryUnescape(pair[1])
+	if idErr != nil || secretErr != nil {
+		// not all clients encode credentials, therefore use them as is
+		return pair[0], pair[1], true
+	}
+	return clientId, clientSecret, true
+}
+
+// getClientCertificate returns client certificate that was presented on TLS handshake or nil
+func getClientCertificate(request *http.Request) *x509.Certificate {
+	if request.TLS == nil || len(request.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	return request.TLS.PeerCertificates[0]
+}
+
+// getClientAddress returns ip address of request remote side (X-Forwarded-For is not trusted because any client could set it)
+func getClientAddress(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// getCertificateConfirmation returns confirmation that binds access token to client certificate if client requires such binding (RFC 8705)
+/* Parameters:
+ *    - request - token request
+ *    - client - client that requests token (could be nil, i.e. client is unknown)
+ * Returns: confirmation (nil if client does not require binding) or error if client requires binding but has not presented certificate
+ */
+func getCertificateConfirmation(request *http.Request, client *data.Client) (*data.TokenConfirmation, *data.OperationError) {
+	if client == nil || !client.TlsClientCertificateBoundAccessTokens {
+		return nil, nil
+	}
+	certificate := getClientCertificate(request)
+	if certificate == nil {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.ClientCertificateRequiredDesc}
+	}
+	return data.CreateCertificateConfirmation(certificate), nil
+}
+
+// getClientAuthMethods returns client authentication methods that are supported by token, introspection, PAR and CIBA endpoints,
+// mutual TLS methods are supported only if listener requests client certificates
+func (wCtx *WebApiContext) getClientAuthMethods() []string {
+	methods := []string{globals.ClientSecretBasicAuthMethod, globals.ClientSecretPostAuthMethod, globals.ClientSecretJwtAuthMethod,
+		globals.PrivateKeyJwtAuthMethod}
+	if wCtx.MutualTls {
+		methods = append(methods, globals.TlsClientAuthMethod, globals.SelfSignedTlsClientAuthMethod)
+	}
+	return methods
+}
+
+// getClientAuthSigningAlgorithms returns algorithms that client could use to sign client_assertion
+func getClientAuthSigningAlgorithms() []string {
+	return []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+}
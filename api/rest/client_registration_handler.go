@@ -0,0 +1,474 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	e "errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/utils/hashing"
+	"github.com/wissance/Ferrum/utils/random"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	clientSecretSize            = 24
+	registrationAccessTokenSize = 32
+)
+
+// RegisterClient this function is a Http Request Handler that registers new realm client dynamically (RFC 7591)
+// @Summary Registers new client
+// @Description Registers new client, requires initial access token issued by realm administrator
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer INITIAL_ACCESS_TOKEN"
+// @Param function body dto.ClientRegistrationMetadata true "Client metadata"
+// @Param realm path string true "Realm"
+// @Success 201 {object} dto.ClientRegistrationMetadata
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/clients-registrations/openid-connect [post]
+// @Router /realms/{realm}/clients-registrations/openid-connect [post]
+func (wCtx *WebApiContext) RegisterClient(respWriter http.ResponseWriter, request *http.Request) {
+	/* Client sends POST request with JSON metadata and Authorization: Bearer {initial_access_token}, server creates client
+	 * with generated client_id (and client_secret for confidential clients), consumes one usage of initial access token and
+	 * returns registration_access_token that allows to manage registration via registration_client_uri
+	 */
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Client registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	tokenIndex := -1
+	initialAccessToken, ok := getBearerToken(request)
+	if ok {
+		tokenIndex = findInitialAccessToken(realmPtr, initialAccessToken)
+	}
+	if tokenIndex < 0 {
+		wCtx.Logger.Debug("Client registration: initial access token is invalid")
+		result := dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidInitialAccessTokenDesc}
+		afterHandle(&respWriter, http.StatusUnauthorized, &result)
+		return
+	}
+	metadata := dto.ClientRegistrationMetadata{}
+	if err := json.NewDecoder(request.Body).Decode(&metadata); err != nil {
+		wCtx.Logger.Debug("Client registration: body is bad (unable to unmarshal to dto.ClientRegistrationMetadata)")
+		result := dto.ErrorDetails{Msg: errors.InvalidClientMetadataMsg, Description: errors.BadBodyForClientRegistrationDesc}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	check := wCtx.validateClientMetadata(&metadata)
+	if check != nil {
+		wCtx.Logger.Debug(sf.Format("Client registration: invalid metadata: {0}", check.Description))
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	client := data.Client{ID: uuid.New()}
+	client.Name = client.ID.String()
+	registrationAccessToken, err := applyClientMetadata(&client, &metadata)
+	if err != nil {
+		wCtx.Logger.Error(sf.Format("Client registration: unable to generate client secrets: {0}", err.Error()))
+		afterHandle(&respWriter, http.StatusInternalServerError, &dto.ErrorDetails{Msg: errors.OtherAppError})
+		return
+	}
+	// initial access token usage is stored (atomically, parallel registrations are counted) prior to client creation,
+	// so token can't be used more than Count times
+	used, err := (*wCtx.DataProvider).UseInitialAccessToken(realm, realmPtr.InitialAccessTokens[tokenIndex].ID)
+	if err == nil && !used {
+		wCtx.Logger.Debug("Client registration: initial access token was exhausted by parallel registration")
+		result := dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidInitialAccessTokenDesc}
+		afterHandle(&respWriter, http.StatusUnauthorized, &result)
+		return
+	}
+	if err == nil {
+		err = (*wCtx.DataProvider).CreateClient(realm, client)
+	}
+	if err != nil {
+		status, errDetails = wCtx.getDataOperationError("Client registration", err)
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	wCtx.Logger.Info(sf.Format("Client registration: client \"{0}\" was registered in realm \"{1}\"", client.Name, realm))
+	result := wCtx.createClientMetadata(realm, &client)
+	result.RegistrationAccessToken = registrationAccessToken
+	result.ClientIdIssuedAt = time.Now().Unix()
+	afterHandle(&respWriter, http.StatusCreated, &result)
+}
+
+// GetClientRegistration this function is a Http Request Handler that returns dynamically registered client metadata (RFC 7592)
+// @Summary Returns registered client metadata
+// @Description Returns registered client metadata
+// @Tags clients
+// @Produce json
+// @Param Authorization header string true "Bearer REGISTRATION_ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param clientId path string true "Client id"
+// @Success 200 {object} dto.ClientRegistrationMetadata
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/clients-registrations/openid-connect/{clientId} [get]
+// @Router /realms/{realm}/clients-registrations/openid-connect/{clientId} [get]
+func (wCtx *WebApiContext) GetClientRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, client, status, errDetails := wCtx.readRegisteredClient(request, "Get client registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	result := wCtx.createClientMetadata(realm, client)
+	afterHandle(&respWriter, http.StatusOK, &result)
+}
+
+// UpdateClientRegistration this function is a Http Request Handler that replaces dynamically registered client metadata (RFC 7592)
+// @Summary Updates registered client metadata
+// @Description Replaces registered client metadata, registration access token is rotated
+// @Tags clients
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer REGISTRATION_ACCESS_TOKEN"
+// @Param function body dto.ClientRegistrationMetadata true "Client metadata"
+// @Param realm path string true "Realm"
+// @Param clientId path string true "Client id"
+// @Success 200 {object} dto.ClientRegistrationMetadata
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/clients-registrations/openid-connect/{clientId} [put]
+// @Router /realms/{realm}/clients-registrations/openid-connect/{clientId} [put]
+func (wCtx *WebApiContext) UpdateClientRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	/* Client sends full metadata (including client_id and, optionally, current client_secret), fields that are omitted
+	 * are removed from registration. New registration_access_token is issued, previous one becomes invalid
+	 */
+	beforeHandle(&respWriter)
+	realm, client, status, errDetails := wCtx.readRegisteredClient(request, "Update client registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	metadata := dto.ClientRegistrationMetadata{}
+	if err := json.NewDecoder(request.Body).Decode(&metadata); err != nil {
+		wCtx.Logger.Debug("Update client registration: body is bad (unable to unmarshal to dto.ClientRegistrationMetadata)")
+		result := dto.ErrorDetails{Msg: errors.InvalidClientMetadataMsg, Description: errors.BadBodyForClientRegistrationDesc}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	check := wCtx.validateClientMetadata(&metadata)
+	if check == nil && metadata.ClientId != client.Name {
+		check = &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.ClientIdMismatchDesc}
+	}
+	if check == nil && len(metadata.ClientSecret) > 0 &&
+		subtle.ConstantTimeCompare([]byte(metadata.ClientSecret), []byte(client.Auth.Value)) != 1 {
+		check = &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.ClientSecretMismatchDesc}
+	}
+	if check != nil {
+		wCtx.Logger.Debug(sf.Format("Update client registration: invalid metadata: {0}", check.Description))
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	updatedClient := *client
+	registrationAccessToken, err := applyClientMetadata(&updatedClient, &metadata)
+	if err == nil {
+		err = (*wCtx.DataProvider).UpdateClient(realm, client.Name, updatedClient)
+	}
+	if err != nil {
+		status, errDetails = wCtx.getDataOperationError("Update client registration", err)
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	result := wCtx.createClientMetadata(realm, &updatedClient)
+	result.RegistrationAccessToken = registrationAccessToken
+	afterHandle(&respWriter, http.StatusOK, &result)
+}
+
+// DeleteClientRegistration this function is a Http Request Handler that removes dynamically registered client (RFC 7592)
+// @Summary Removes registered client
+// @Description Removes registered client
+// @Tags clients
+// @Param Authorization header string true "Bearer REGISTRATION_ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param clientId path string true "Client id"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/clients-registrations/openid-connect/{clientId} [delete]
+// @Router /realms/{realm}/clients-registrations/openid-connect/{clientId} [delete]
+func (wCtx *WebApiContext) DeleteClientRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	realm, client, status, errDetails := wCtx.readRegisteredClient(request, "Delete client registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if err := (*wCtx.DataProvider).DeleteClient(realm, client.Name); err != nil {
+		status, errDetails = wCtx.getDataOperationError("Delete client registration", err)
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	wCtx.Logger.Info(sf.Format("Client registration: client \"{0}\" was removed from realm \"{1}\"", client.Name, realm))
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// readRegisteredClient reads realm and client from request path and checks client registration access token
+/* If client does not exist server responds same way as for invalid token (RFC 7592 section 2), so token owner can't
+ * find out which clients exist
+ * Parameters:
+ *    - request - http request with realm and clientId path variables and Authorization: Bearer {registration_access_token}
+ *    - operation - handler name for logging
+ * Returns: realm name, client, http status and error details (nil if client was successfully read)
+ */
+func (wCtx *WebApiContext) readRegisteredClient(request *http.Request, operation string) (string, *data.Client, int, *dto.ErrorDetails) {
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, operation)
+	if errDetails != nil {
+		return realm, nil, status, errDetails
+	}
+	client := findRealmClient(realmPtr, vars[globals.ClientIdPathVar])
+	registrationAccessToken, ok := getBearerToken(request)
+	if !ok || client == nil || !hashing.CheckTokenHash(registrationAccessToken, client.RegistrationAccessTokenHash) {
+		wCtx.Logger.Debug(sf.Format("{0}: registration access token is invalid", operation))
+		return realm, nil, http.StatusUnauthorized, &dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidRegistrationAccessTokenDesc}
+	}
+	return realm, client, http.StatusOK, nil
+}
+
+// createClientMetadata converts data.Client to client registration response (without registration access token that is not stored)
+func (wCtx *WebApiContext) createClientMetadata(realm string, client *data.Client) dto.ClientRegistrationMetadata {
+	metadata := dto.ClientRegistrationMetadata{
+		ClientId:                              client.Name,
+		RegistrationClientUri:                 sf.Format("{0}/clients-registrations/openid-connect/{1}", wCtx.getRealmIssuer(realm), client.Name),
+		ClientName:                            client.DisplayName,
+		RedirectUris:                          client.RedirectUris,
+		TokenEndpointAuthMethod:               globals.NoneAuthMethod,
+		RequirePushedAuthorizationRequests:    client.RequirePar,
+		BackChannelTokenDeliveryMode:          string(client.BackChannelTokenDeliveryMode),
+		BackChannelClientNotificationEndpoint: client.BackChannelClientNotificationEndpoint,
+		TlsClientCertificateBoundAccessTokens: client.TlsClientCertificateBoundAccessTokens,
+		DPoPBoundAccessTokens:                 client.DPoPBoundAccessTokens,
+	}
+	if client.Type == data.Confidential {
+		switch client.Auth.Type {
+		case data.PrivateKeyJwt:
+			metadata.TokenEndpointAuthMethod = globals.PrivateKeyJwtAuthMethod
+			metadata.Jwks = client.Auth.Jwks
+		case data.ClientSecretJwt:
+			metadata.TokenEndpointAuthMethod = globals.ClientSecretJwtAuthMethod
+		case data.TlsClientAuth:
+			metadata.TokenEndpointAuthMethod = globals.TlsClientAuthMethod
+			metadata.TlsClientAuthSubjectDn = client.Auth.TlsSubjectDn
+			metadata.TlsClientAuthSanDns = client.Auth.TlsSanDns
+			metadata.TlsClientAuthSanUri = client.Auth.TlsSanUri
+		case data.SelfSignedTlsClientAuth:
+			metadata.TokenEndpointAuthMethod = globals.SelfSignedTlsClientAuthMethod
+			metadata.Jwks = client.Auth.Jwks
+		default:
+			metadata.TokenEndpointAuthMethod = globals.ClientSecretBasicAuthMethod
+		}
+		if client.Auth.IsSecretBased() {
+			// secret never expires
+			var secretExpiresAt int64
+			metadata.ClientSecret = client.Auth.Value
+			metadata.ClientSecretExpiresAt = &secretExpiresAt
+		}
+	}
+	return metadata
+}
+
+// getDataOperationError converts DataContext write operation error to http status and error details
+func (wCtx *WebApiContext) getDataOperationError(operation string, err error) (int, *dto.ErrorDetails) {
+	if e.As(err, &errors.ErrDataSourceNotAvailable) {
+		wCtx.Logger.Error("Data provider not available")
+		return http.StatusServiceUnavailable, &dto.ErrorDetails{Msg: errors.ServiceIsUnavailable}
+	}
+	wCtx.Logger.Error(sf.Format("{0}: data operation failed: {1}", operation, err.Error()))
+	return http.StatusInternalServerError, &dto.ErrorDetails{Msg: errors.OtherAppError}
+}
+
+// applyClientMetadata assigns client settings from metadata, generates client secret (if client became confidential)
+// and new registration access token
+/* Parameters:
+ *    - client - newly creating or updating client
+ *    - metadata - validated client metadata
+ * Returns: new registration access token (client stores only hash) or error if secrets generation failed
+ */
+func applyClientMetadata(client *data.Client, metadata *dto.ClientRegistrationMetadata) (string, error) {
+	client.DisplayName = metadata.ClientName
+	client.RedirectUris = metadata.RedirectUris
+	client.RequirePar = metadata.RequirePushedAuthorizationRequests
+	client.BackChannelTokenDeliveryMode = data.BackChannelTokenDeliveryMode(metadata.BackChannelTokenDeliveryMode)
+	client.BackChannelClientNotificationEndpoint = metadata.BackChannelClientNotificationEndpoint
+	client.TlsClientCertificateBoundAccessTokens = metadata.TlsClientCertificateBoundAccessTokens
+	client.DPoPBoundAccessTokens = metadata.DPoPBoundAccessTokens
+	switch metadata.TokenEndpointAuthMethod {
+	case globals.NoneAuthMethod:
+		client.Type = data.Public
+		client.Auth = data.Authentication{}
+	case globals.PrivateKeyJwtAuthMethod:
+		client.Type = data.Confidential
+		client.Auth = data.Authentication{Type: data.PrivateKeyJwt, Jwks: metadata.Jwks}
+	case globals.TlsClientAuthMethod:
+		client.Type = data.Confidential
+		client.Auth = data.Authentication{Type: data.TlsClientAuth, TlsSubjectDn: metadata.TlsClientAuthSubjectDn,
+			TlsSanDns: metadata.TlsClientAuthSanDns, TlsSanUri: metadata.TlsClientAuthSanUri}
+	case globals.SelfSignedTlsClientAuthMethod:
+		client.Type = data.Confidential
+		client.Auth = data.Authentication{Type: data.SelfSignedTlsClientAuth, Jwks: metadata.Jwks}
+	default:
+		authType := data.ClientIdAndSecrets
+		if metadata.TokenEndpointAuthMethod == globals.ClientSecretJwtAuthMethod {
+			authType = data.ClientSecretJwt
+		}
+		// client keeps its secret if it was already issued
+		secret := client.Auth.Value
+		if client.Type != data.Confidential || !client.Auth.IsSecretBased() || len(secret) == 0 {
+			var err error
+			if secret, err = random.GenerateToken(clientSecretSize); err != nil {
+				return "", err
+			}
+		}
+		client.Type = data.Confidential
+		client.Auth = data.Authentication{Type: authType, Value: secret}
+	}
+	registrationAccessToken, err := random.GenerateToken(registrationAccessTokenSize)
+	if err != nil {
+		return "", err
+	}
+	client.RegistrationAccessTokenHash = hashing.HashToken(registrationAccessToken)
+	return registrationAccessToken, nil
+}
+
+// validateClientMetadata checks client metadata values, unsupported metadata fields are ignored (RFC 7591 section 2)
+func (wCtx *WebApiContext) validateClientMetadata(metadata *dto.ClientRegistrationMetadata) *data.OperationError {
+	if len(metadata.TokenEndpointAuthMethod) == 0 {
+		// default value according to RFC 7591
+		metadata.TokenEndpointAuthMethod = globals.ClientSecretBasicAuthMethod
+	}
+	if metadata.TokenEndpointAuthMethod != globals.NoneAuthMethod && !isValueSupported(wCtx.getClientAuthMethods(), metadata.TokenEndpointAuthMethod) {
+		return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.UnsupportedAuthMethodDesc}
+	}
+	switch metadata.TokenEndpointAuthMethod {
+	case globals.PrivateKeyJwtAuthMethod:
+		if !isJwksValid(metadata.Jwks) {
+			return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.JwksRequiredDesc}
+		}
+	case globals.SelfSignedTlsClientAuthMethod:
+		if !isJwksValid(metadata.Jwks) {
+			return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.JwksRequiredForTlsDesc}
+		}
+	case globals.TlsClientAuthMethod:
+		if len(metadata.TlsClientAuthSubjectDn) == 0 && len(metadata.TlsClientAuthSanDns) == 0 && len(metadata.TlsClientAuthSanUri) == 0 {
+			return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.TlsSubjectRequiredDesc}
+		}
+	}
+	for _, redirectUri := range metadata.RedirectUris {
+		parsedUri, err := url.Parse(redirectUri)
+		if err != nil || !parsedUri.IsAbs() || len(parsedUri.Fragment) > 0 {
+			return &data.OperationError{Msg: errors.InvalidRedirectUriMsg, Description: errors.RedirectUriFormatDesc}
+		}
+		if !isRedirectUriSchemeAllowed(parsedUri) {
+			return &data.OperationError{Msg: errors.InvalidRedirectUriMsg, Description: errors.RedirectUriSchemeDesc}
+		}
+	}
+	deliveryMode := data.BackChannelTokenDeliveryMode(metadata.BackChannelTokenDeliveryMode)
+	if len(deliveryMode) > 0 && deliveryMode != data.PollDeliveryMode && deliveryMode != data.PingDeliveryMode {
+		return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.UnsupportedDeliveryModeDesc}
+	}
+	if deliveryMode == data.PingDeliveryMode && len(metadata.BackChannelClientNotificationEndpoint) == 0 {
+		return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.NotificationEndpointRequiredDesc}
+	}
+	if len(metadata.BackChannelClientNotificationEndpoint) > 0 && !isNotificationEndpointAllowed(metadata.BackChannelClientNotificationEndpoint) {
+		return &data.OperationError{Msg: errors.InvalidClientMetadataMsg, Description: errors.NotificationEndpointFormatDesc}
+	}
+	return nil
+}
+
+// isRedirectUriSchemeAllowed checks that redirect uri uses https, http with loopback host or private-use scheme of native
+// application (reverse domain name, RFC 8252), therefore javascript:, data: and other schemes without dot are refused
+func isRedirectUriSchemeAllowed(redirectUri *url.URL) bool {
+	switch strings.ToLower(redirectUri.Scheme) {
+	case "https":
+		return len(redirectUri.Host) > 0
+	case "http":
+		return isLoopbackHost(redirectUri.Hostname())
+	}
+	return strings.Contains(redirectUri.Scheme, ".")
+}
+
+// isNotificationEndpointAllowed checks that notification endpoint is https uri of public host, server sends ping notifications
+// to this endpoint, therefore loopback and private network hosts (including names that are resolved to them) are refused
+func isNotificationEndpointAllowed(endpoint string) bool {
+	parsedUri, err := url.Parse(endpoint)
+	if err != nil || strings.ToLower(parsedUri.Scheme) != "https" || len(parsedUri.Hostname()) == 0 || len(parsedUri.Fragment) > 0 {
+		return false
+	}
+	host := parsedUri.Hostname()
+	if isLoopbackHost(host) {
+		return false
+	}
+	addresses := []net.IP{net.ParseIP(host)}
+	if addresses[0] == nil {
+		if addresses, err = net.LookupIP(host); err != nil {
+			return false
+		}
+	}
+	for _, address := range addresses {
+		if address.IsLoopback() || address.IsPrivate() || address.IsUnspecified() || address.IsLinkLocalUnicast() ||
+			address.IsLinkLocalMulticast() || address.IsMulticast() {
+			return false
+		}
+	}
+	return true
+}
+
+// isLoopbackHost checks whether host is localhost name or loopback ip address
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isJwksValid checks that jwks has at least one key and all keys are valid public keys
+func isJwksValid(jwks *data.JsonWebKeySet) bool {
+	if jwks == nil || len(jwks.Keys) == 0 {
+		return false
+	}
+	for i := range jwks.Keys {
+		if _, err := jwks.Keys[i].GetPublicKey(); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// findInitialAccessToken returns index of active realm initial access token that corresponds to token value or -1
+func findInitialAccessToken(realm *data.Realm, token string) int {
+	now := time.Now()
+	for i := range realm.InitialAccessTokens {
+		initialToken := &realm.InitialAccessTokens[i]
+		if initialToken.IsActive(now) && hashing.CheckTokenHash(token, initialToken.TokenHash) {
+			return i
+		}
+	}
+	return -1
+}
@@ -3,6 +3,7 @@ package rest
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 )
 
 const (
@@ -14,6 +15,7 @@ type tokenType string
 const (
 	BearerToken  tokenType = "Bearer"
 	RefreshToken tokenType = "Refresh"
+	DPoPToken    tokenType = "DPoP"
 )
 
 // beforeHandle
@@ -44,3 +46,16 @@ func afterHandle(respWriter *http.ResponseWriter, statusCode int, data interface
 		}
 	}
 }
+
+// getBearerToken extracts token from Authorization header with value Bearer {token}
+/* Parameters:
+ *     - request - http request
+ * Returns token and true if header has expected format, otherwise empty string and false
+ */
+func getBearerToken(request *http.Request) (string, bool) {
+	parts := strings.Split(request.Header.Get(authorizationHeader), " ")
+	if len(parts) != 2 || parts[0] != string(BearerToken) || len(parts[1]) == 0 {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,181 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const consentActionPath = "login-actions/consent"
+
+// SubmitConsent this function is a Http Request Handler that accepts user decision on consent screen
+// @Summary Consent screen submission
+// @Description Stores granted scopes and redirects to client redirect_uri with code or with access_denied error
+// @Tags authorization
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param realm path string true "Realm"
+// @Param client_id formData string true "Client id"
+// @Param request_uri formData string true "Consent form reference"
+// @Param accept formData bool false "true if user granted requested scopes"
+// @Success 302
+// @Failure 400
+// @Router /auth/realms/{realm}/login-actions/consent [post]
+// @Router /realms/{realm}/login-actions/consent [post]
+func (wCtx *WebApiContext) SubmitConsent(respWriter http.ResponseWriter, request *http.Request) {
+	/* Consent form like login form keeps only one-time reference to authorization request, user that consents is a user
+	 * of browser login (identity cookie), reference is accepted only from session that was shown consent form
+	 */
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Consent")
+	if errDetails != nil {
+		wCtx.writeLoginPage(respWriter, request, nil, "", status, &loginPage{Realm: realm, Error: errDetails.Msg})
+		return
+	}
+	consentRequest := dto.ConsentFormRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&consentRequest, request.PostForm)
+	}
+	session, user := wCtx.readSsoSession(request, realmPtr)
+	var authRequest *dto.AuthorizationRequest
+	if err == nil && session != nil {
+		authRequest = (*wCtx.Security).GetConsentRequest(realm, consentRequest.ClientId, consentRequest.RequestUri, session.Id)
+	}
+	client := findRealmClient(realmPtr, consentRequest.ClientId)
+	if authRequest == nil || client == nil || !client.IsEnabled() || session == nil {
+		wCtx.Logger.Debug("Consent: consent form is expired, client is disabled or user is not logged in")
+		wCtx.writeLoginPage(respWriter, request, realmPtr, "", http.StatusBadRequest, &loginPage{Realm: realm, Error: errors.LoginPageExpiredDesc})
+		return
+	}
+	if !consentRequest.Accept {
+		wCtx.Logger.Debug(sf.Format("Consent: user \"{0}\" denied access of client \"{1}\"", user.GetUsername(), client.Name))
+		wCtx.redirectWithError(respWriter, request, authRequest, &data.OperationError{Msg: errors.AccessDeniedMsg, Description: errors.AccessDeniedDesc})
+		return
+	}
+	if check := (*wCtx.Security).GrantConsent(realmPtr, user, client.Name, authRequest.Scope); check != nil {
+		wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusInternalServerError,
+			&loginPage{Realm: realm, Error: getErrorText(check)})
+		return
+	}
+	wCtx.redirectWithCode(respWriter, request, realm, authRequest, session)
+}
+
+// GetAccountConsents this function is a Http Request Handler that returns consents of user that owns access token
+// @Summary Returns user consents
+// @Description Returns clients and scopes that user granted on consent screen
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {array} data.UserConsent
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/consents [get]
+// @Router /realms/{realm}/account/consents [get]
+func (wCtx *WebApiContext) GetAccountConsents(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Account consents")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, realmPtr, "Account consents")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	consents := user.GetConsents()
+	afterHandle(&respWriter, http.StatusOK, &consents)
+}
+
+// RevokeAccountConsent this function is a Http Request Handler that revokes consent of user that owns access token
+// @Summary Revokes user consent
+// @Description Removes consent of client, next browser login to client shows consent screen again
+// @Tags account
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Param clientId path string true "Client id"
+// @Success 204
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/account/consents/{clientId} [delete]
+// @Router /realms/{realm}/account/consents/{clientId} [delete]
+// @Router /auth/realms/{realm}/account/applications/{clientId}/consent [delete]
+// @Router /realms/{realm}/account/applications/{clientId}/consent [delete]
+func (wCtx *WebApiContext) RevokeAccountConsent(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Account consent revocation")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, realmPtr, "Account consent revocation")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if check := (*wCtx.Security).RevokeConsent(realmPtr, user, vars[globals.ClientIdPathVar]); check != nil {
+		afterHandle(&respWriter, getConsentErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// completeLogin redirects logged-in user to client with code, client that requires consent gets code only after user granted
+// requested scopes (consent screen is shown if they were not granted before or if prompt is consent)
+func (wCtx *WebApiContext) completeLogin(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm, client *data.Client,
+	authRequest *dto.AuthorizationRequest, session *data.UserSession, user data.User) {
+	if client.ConsentRequired {
+		prompts := strings.Fields(authRequest.Prompt)
+		if isValueSupported(prompts, globals.ConsentPrompt) || !data.IsConsentGranted(user, client.Name, authRequest.Scope) {
+			if isValueSupported(prompts, globals.NonePrompt) {
+				wCtx.redirectWithError(respWriter, request, authRequest,
+					&data.OperationError{Msg: errors.ConsentRequiredMsg, Description: errors.ConsentRequiredDesc})
+				return
+			}
+			wCtx.showConsentPage(respWriter, request, realmPtr, client, authRequest, session, user)
+			return
+		}
+	}
+	wCtx.redirectWithCode(respWriter, request, realmPtr.Name, authRequest, session)
+}
+
+// showConsentPage stores authorization request (bound to user session) until user submits consent form and renders form with
+// requested scopes
+func (wCtx *WebApiContext) showConsentPage(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm, client *data.Client,
+	authRequest *dto.AuthorizationRequest, session *data.UserSession, user data.User) {
+	page := loginPage{Realm: realmPtr.Name, ClientName: client.DisplayName, ClientId: client.Name, Username: user.GetUsername(),
+		ActionUrl: getRealmPath(request, realmPtr.Name) + consentActionPath, Consent: true, Scopes: strings.Fields(authRequest.Scope)}
+	if len(page.ClientName) == 0 {
+		page.ClientName = client.Name
+	}
+	page.RequestUri = (*wCtx.Security).StoreConsentRequest(realmPtr.Name, authRequest, session.Id, globals.LoginPageExpiration)
+	wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusOK, &page)
+}
+
+// getConsentErrorStatus returns http status of consent operation error
+func getConsentErrorStatus(check *data.OperationError) int {
+	switch {
+	case check.Description == errors.ConsentNotFoundDesc:
+		return http.StatusNotFound
+	case check.Msg == errors.ServiceIsUnavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -5,15 +5,32 @@ import (
 	"github.com/wissance/Ferrum/logging"
 	"github.com/wissance/Ferrum/managers"
 	"github.com/wissance/Ferrum/services"
+	"github.com/wissance/Ferrum/themes"
 )
 
 // WebApiContext is a central Application logic processor manages from Web via HTTP/HTTPS
 type WebApiContext struct {
-	Address        string
-	Schema         string
-	DataProvider   *managers.DataContext
-	AuthDefs       *data.AuthenticationDefs
-	Security       *services.SecurityService
+	Address string
+	Schema  string
+	// MutualTls is true if HTTPS listener requests client certificates (RFC 8705)
+	MutualTls    bool
+	DataProvider *managers.DataContext
+	AuthDefs     *data.AuthenticationDefs
+	Security     *services.SecurityService
+	// BackChannelAuth is nil if CIBA is not configured
+	BackChannelAuth *services.BackChannelAuthenticationService
+	// EmailActions is nil if mail is not configured
+	EmailActions *services.EmailActionService
+	// AdminRealm is a realm of administrators, Admin REST API accepts only access tokens of this realm users with admin role
+	AdminRealm string
+	// Admin implements Admin REST API operations
+	Admin *services.AdminService
+	// Events stores user events (logins, token refreshes) that admin console and Admin REST API show
+	Events *services.EventService
+	// AdminConsoleClientId is a client of admin realm that admin console logs in with (empty if admin console is disabled)
+	AdminConsoleClientId string
+	// Themes renders login pages with realm theme
+	Themes         *themes.ThemeManager
 	TokenGenerator *services.JwtGenerator
 	Logger         *logging.AppLogger
 }
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+// SendVerifyEmail this function is a Http Request Handler that sends email verification link to user
+// @Summary Sends email verification link
+// @Description Sends link that sets email_verified to user email, user must be authenticated with own access token
+// @Tags email
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Failure 503 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/email/verify [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/email/verify [post]
+func (wCtx *WebApiContext) SendVerifyEmail(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Send verify email")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, realmPtr, "Send verify email")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if wCtx.EmailActions == nil {
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc})
+		return
+	}
+	if check := (*wCtx.EmailActions).SendVerifyEmail(realmPtr, user); check != nil {
+		afterHandle(&respWriter, getActionErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// ResetCredentials this function is a Http Request Handler that sends reset password link (forgot password)
+// @Summary Sends reset password link
+// @Description Sends link that allows to set new password, response doesn't depend on user existence
+// @Tags email
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param realm path string true "Realm"
+// @Param username formData string true "Username"
+// @Success 204
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/login-actions/reset-credentials [post]
+// @Router /realms/{realm}/login-actions/reset-credentials [post]
+func (wCtx *WebApiContext) ResetCredentials(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Reset credentials")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if wCtx.EmailActions == nil {
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc})
+		return
+	}
+	resetRequest := dto.ResetCredentialsRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&resetRequest, request.PostForm)
+	}
+	if err != nil || len(resetRequest.Username) == 0 {
+		wCtx.Logger.Debug("Reset credentials: body is bad (username is required)")
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.BadBodyForEmailActionMsg})
+		return
+	}
+	if check := (*wCtx.EmailActions).SendResetPasswordEmail(realmPtr, resetRequest.Username); check != nil {
+		afterHandle(&respWriter, getActionErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusNoContent, nil)
+}
+
+// ExecuteActionToken this function is a Http Request Handler that executes actions of email link
+// @Summary Executes email link actions
+// @Description Executes link actions (VERIFY_EMAIL, UPDATE_PASSWORD), GET is a link itself, POST passes new password
+// @Tags email
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param realm path string true "Realm"
+// @Param key query string false "Link key (GET)"
+// @Param key formData string false "Link key (POST)"
+// @Param password formData string false "New password (UPDATE_PASSWORD action)"
+// @Success 200 {object} dto.ActionTokenResult
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/login-actions/action-token [get]
+// @Router /auth/realms/{realm}/login-actions/action-token [post]
+// @Router /realms/{realm}/login-actions/action-token [get]
+// @Router /realms/{realm}/login-actions/action-token [post]
+func (wCtx *WebApiContext) ExecuteActionToken(respWriter http.ResponseWriter, request *http.Request) {
+	/* Link with UPDATE_PASSWORD action opened with GET returns "New password is required" error and remains valid,
+	 * client should POST key with password
+	 */
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Execute action token")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	if wCtx.EmailActions == nil {
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc})
+		return
+	}
+	actionRequest := dto.ActionTokenRequest{Key: request.URL.Query().Get("key")}
+	if request.Method == http.MethodPost {
+		err := request.ParseForm()
+		if err == nil {
+			decoder := schema.NewDecoder()
+			decoder.IgnoreUnknownKeys(true)
+			err = decoder.Decode(&actionRequest, request.PostForm)
+		}
+		if err != nil {
+			wCtx.Logger.Debug("Execute action token: body is bad (unable to unmarshal to dto.ActionTokenRequest)")
+			afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.BadBodyForEmailActionMsg})
+			return
+		}
+	}
+	result, check := (*wCtx.EmailActions).ExecuteActionToken(realmPtr, actionRequest.Key, actionRequest.Password)
+	if check != nil {
+		afterHandle(&respWriter, getActionErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusOK, result)
+}
+
+// getActionErrorStatus returns http status of email action or required action error
+func getActionErrorStatus(check *data.OperationError) int {
+	switch check.Msg {
+	case errors.ServiceIsUnavailable:
+		return http.StatusServiceUnavailable
+	case errors.OtherAppError:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,42 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/wissance/Ferrum/dto"
+)
+
+// dataSourceHealthCheck is a name of readiness check of data source availability
+const dataSourceHealthCheck = "Data source connection"
+
+// GetLiveness this function is a Http Request Handler that returns server liveness status
+// @Summary Returns liveness status
+// @Description Returns UP status while server process serves requests
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.HealthStatus
+// @Router /health [get]
+// @Router /health/live [get]
+func (wCtx *WebApiContext) GetLiveness(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	afterHandle(&respWriter, http.StatusOK, &dto.HealthStatus{Status: dto.HealthStatusUp, Checks: []dto.HealthCheck{}})
+}
+
+// GetReadiness this function is a Http Request Handler that returns server readiness status
+// @Summary Returns readiness status
+// @Description Returns UP status if data source is available, otherwise DOWN status with 503
+// @Tags health
+// @Produce json
+// @Success 200 {object} dto.HealthStatus
+// @Failure 503 {object} dto.HealthStatus
+// @Router /health/ready [get]
+func (wCtx *WebApiContext) GetReadiness(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	status := http.StatusOK
+	dataSource := dto.HealthCheck{Name: dataSourceHealthCheck, Status: dto.HealthStatusUp}
+	if !(*wCtx.DataProvider).IsAvailable() {
+		status = http.StatusServiceUnavailable
+		dataSource.Status = dto.HealthStatusDown
+	}
+	afterHandle(&respWriter, status, &dto.HealthStatus{Status: dataSource.Status, Checks: []dto.HealthCheck{dataSource}})
+}
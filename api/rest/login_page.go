@@ -0,0 +1,135 @@
+package rest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/themes"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const acceptLanguageHeader = "Accept-Language"
+
+// loginPage is a model of server-rendered login form, page without ActionUrl is an error page (i.e. invalid authorization request)
+// or an info page (Info is a message key), page with Consent is a consent screen that shows requested Scopes, page with
+// Registration is a registration form with user profile Attributes
+type loginPage struct {
+	Realm           string
+	ClientName      string
+	ActionUrl       string
+	ClientId        string
+	RequestUri      string
+	Username        string
+	OtpRequired     bool
+	RegistrationUrl string
+	Consent         bool
+	Scopes          []string
+	Registration    bool
+	Attributes      []pageAttribute
+	Info            string
+	Error           string
+}
+
+// pageAttribute is a registration form field of user profile attribute
+type pageAttribute struct {
+	Name     string
+	Value    string
+	Required bool
+	Email    bool
+}
+
+// GetThemeResource this function is a Http Request Handler that returns static resource (css, image, script) of login pages theme
+// @Summary Returns theme static resource
+// @Description Returns file from theme resources directory, file that theme doesn't have is taken from default theme
+// @Tags authorization
+// @Param theme path string true "Theme"
+// @Param resource path string true "Resource path"
+// @Success 200
+// @Failure 404
+// @Router /auth/resources/{theme}/{resource} [get]
+// @Router /resources/{theme}/{resource} [get]
+func (wCtx *WebApiContext) GetThemeResource(respWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	theme := wCtx.Themes.GetTheme(vars[globals.ThemePathVar])
+	file, err := theme.OpenResource(vars[globals.ResourcePathVar])
+	if err != nil {
+		http.NotFound(respWriter, request)
+		return
+	}
+	defer file.Close()
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		http.NotFound(respWriter, request)
+		return
+	}
+	var modified time.Time
+	if info, statErr := file.Stat(); statErr == nil {
+		modified = info.ModTime()
+	}
+	respWriter.Header().Set("Cache-Control", "public, max-age=3600")
+	http.ServeContent(respWriter, request, vars[globals.ResourcePathVar], modified, content)
+}
+
+// writeLoginPage renders login page (or error page) with realm theme, page must not be cached or shown in frames of other sites (clickjacking)
+/* Parameters:
+ *     - respWriter - gorilla/mux response writer
+ *     - request - http request, Accept-Language header is used if uiLocales don't match theme locales
+ *     - realmPtr - realm that page is shown for (nil if realm wasn't read, default theme is used)
+ *     - uiLocales - ui_locales parameter of authorization request
+ *     - statusCode - http response status
+ *     - page - page model
+ * Returns nothing
+ */
+func (wCtx *WebApiContext) writeLoginPage(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm, uiLocales string,
+	statusCode int, page *loginPage) {
+	themeName := ""
+	if realmPtr != nil {
+		themeName = realmPtr.LoginTheme
+	}
+	theme := wCtx.Themes.GetTheme(themeName)
+	templateName := page.getTemplate()
+	locale := theme.SelectLocale(uiLocales, request.Header.Get(acceptLanguageHeader))
+	resourcesUrl := getBasePath(request) + "/resources/" + theme.Name
+	var content bytes.Buffer
+	if err := theme.Render(&content, templateName, locale, resourcesUrl, page); err != nil {
+		wCtx.Logger.Error(sf.Format("Login page rendering with theme \"{0}\" failed: {1}", theme.Name, err.Error()))
+		respWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	respWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	respWriter.Header().Set("Content-Language", locale)
+	respWriter.Header().Set("Cache-Control", "no-store")
+	respWriter.Header().Set("X-Frame-Options", "DENY")
+	respWriter.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	respWriter.WriteHeader(statusCode)
+	_, _ = respWriter.Write(content.Bytes())
+}
+
+// getTemplate returns theme template of page
+func (page *loginPage) getTemplate() string {
+	switch {
+	case len(page.ActionUrl) == 0 && len(page.Info) > 0:
+		return themes.InfoTemplate
+	case len(page.ActionUrl) == 0:
+		return themes.ErrorTemplate
+	case page.Consent:
+		return themes.ConsentTemplate
+	case page.Registration:
+		return themes.RegistrationTemplate
+	}
+	return themes.LoginTemplate
+}
+
+// getBasePath returns /auth if request was sent to KeyCloak-like path (/auth/realms/...) or empty string for /realms/...
+func getBasePath(request *http.Request) string {
+	if strings.HasPrefix(request.URL.Path, "/auth/") {
+		return "/auth"
+	}
+	return ""
+}
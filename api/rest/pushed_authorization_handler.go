@@ -0,0 +1,141 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+// PushAuthorizationRequest this function is a Http Request Handler that is responsible for Pushed Authorization Requests (RFC 9126)
+// @Summary Pushes authorization request parameters and returns request_uri
+// @Description Pushes authorization request parameters and returns request_uri
+// @Tags authorization
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param function body dto.AuthorizationRequest true "Authorization request parameters"
+// @Param realm path string true "Realm"
+// @Success 201 {object} dto.PushedAuthorizationResult
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/par/request [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/par/request [post]
+func (wCtx *WebApiContext) PushAuthorizationRequest(respWriter http.ResponseWriter, request *http.Request) {
+	/* Client sends POST request of type x-www-form-urlencoded with all authorization request parameters (client_id, response_type,
+	 * redirect_uri, scope, state, ...) and client authentication (client_secret for Confidential clients). Server validates parameters
+	 * and returns request_uri that should be passed to authorization endpoint together with client_id. Client could use any supported
+	 * authentication method (see readClientAuthentication)
+	 */
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Pushed authorization request")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+
+	authRequest := dto.AuthorizationRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&authRequest, request.PostForm)
+	}
+	if err != nil {
+		wCtx.Logger.Debug("Pushed authorization request: body is bad (unable to unmarshal to dto.AuthorizationRequest)")
+		result := dto.ErrorDetails{Msg: errors.BadBodyForAuthorizationMsg}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	clientId, check := wCtx.authenticateClient(request, realmPtr)
+	if check != nil {
+		wCtx.Logger.Debug("Pushed authorization request: invalid client credentials")
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, http.StatusUnauthorized, &result)
+		return
+	}
+
+	authRequest.ClientId = clientId
+	client := findRealmClient(realmPtr, authRequest.ClientId)
+	check = wCtx.validateAuthorizationRequest(client, &authRequest)
+	if check == nil && len(authRequest.RequestUri) > 0 {
+		check = &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.RequestUriNotAllowedDesc}
+	}
+	if check != nil {
+		wCtx.Logger.Debug("Pushed authorization request: invalid authorization request parameters")
+		result := dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+
+	// client secret is not an authorization request parameter, we don't keep it
+	authRequest.ClientSecret = ""
+	lifetime := globals.PushedAuthorizationRequestExpiration
+	requestUri := (*wCtx.Security).StorePushedAuthorizationRequest(realm, &authRequest, lifetime)
+	result := dto.PushedAuthorizationResult{RequestUri: requestUri, ExpiresIn: lifetime}
+	afterHandle(&respWriter, http.StatusCreated, &result)
+}
+
+// resolveAuthorizationRequest returns authorization request parameters that authorization endpoint should use
+/* If request_uri was passed, parameters are taken from the pushed request (RFC 9126), otherwise they are used as is,
+ * but only if client doesn't require PAR (data.Client RequirePar)
+ * Parameters:
+ *    - realm - name of a realm
+ *    - client - client that starts authorization
+ *    - authRequest - parameters passed to authorization endpoint
+ * Returns: resolved request or error
+ */
+func (wCtx *WebApiContext) resolveAuthorizationRequest(realm string, client *data.Client,
+	authRequest *dto.AuthorizationRequest) (*dto.AuthorizationRequest, *data.OperationError) {
+	if len(authRequest.RequestUri) == 0 {
+		if client.RequirePar {
+			return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.ParRequiredDesc}
+		}
+		return authRequest, wCtx.validateAuthorizationRequest(client, authRequest)
+	}
+	pushedRequest := (*wCtx.Security).GetPushedAuthorizationRequest(realm, client.Name, authRequest.RequestUri)
+	if pushedRequest == nil {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestUriMsg, Description: errors.InvalidRequestUriDesc}
+	}
+	return pushedRequest, nil
+}
+
+// validateAuthorizationRequest checks authorization request parameters that are common for authorization and PAR endpoints
+func (wCtx *WebApiContext) validateAuthorizationRequest(client *data.Client, authRequest *dto.AuthorizationRequest) *data.OperationError {
+	if client == nil {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCredentialDesc}
+	}
+	if !isValueSupported(wCtx.AuthDefs.SupportedResponseTypes, authRequest.ResponseType) {
+		return &data.OperationError{Msg: errors.UnsupportedResponseTypeMsg}
+	}
+	if !client.IsRedirectUriAllowed(authRequest.RedirectUri) {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.InvalidRedirectUriDesc}
+	}
+	return nil
+}
+
+// findRealmClient searches client by name (client_id) in already read realm
+func findRealmClient(realm *data.Realm, clientId string) *data.Client {
+	for i := range realm.Clients {
+		if realm.Clients[i].Name == clientId {
+			return &realm.Clients[i]
+		}
+	}
+	return nil
+}
+
+func isValueSupported(supported []string, value string) bool {
+	for _, v := range supported {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
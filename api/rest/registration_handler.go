@@ -0,0 +1,241 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	registrationActionPath = "login-actions/registration"
+	verifyEmailSentInfo    = "verifyEmailSent"
+)
+
+// RegisterUser this function is a Http Request Handler that creates user from self-service registration request
+// @Summary Registers user
+// @Description Creates user with realm user profile attributes and password, realm must allow registration. If realm requires
+// @Description email verification user gets VERIFY_EMAIL required action and verification link is sent
+// @Tags registration
+// @Accept json
+// @Produce json
+// @Param function body dto.RegistrationRequest true "User attributes and password"
+// @Param realm path string true "Realm"
+// @Success 201 {object} dto.RegistrationResult
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 403 {string} dto.ErrorDetails
+// @Failure 409 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/registrations [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/registrations [post]
+func (wCtx *WebApiContext) RegisterUser(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	registration := dto.RegistrationRequest{}
+	if err := json.NewDecoder(request.Body).Decode(&registration); err != nil {
+		wCtx.Logger.Debug("Registration: body is bad (unable to unmarshal to dto.RegistrationRequest)")
+		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForRegistrationMsg}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	user, check := (*wCtx.Security).RegisterUser(realmPtr, &registration)
+	if check != nil {
+		afterHandle(&respWriter, getRegistrationErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	wCtx.sendRegistrationVerifyEmail(realmPtr, user)
+	result := dto.RegistrationResult{Id: user.GetId().String(), Username: user.GetUsername(), RequiredActions: user.GetRequiredActions()}
+	afterHandle(&respWriter, http.StatusCreated, &result)
+}
+
+// ShowRegistration this function is a Http Request Handler that shows browser registration form (link on login page)
+// @Summary Shows registration form
+// @Description Shows registration form for authorization request that login form was shown for
+// @Tags registration
+// @Produce html
+// @Param realm path string true "Realm"
+// @Param client_id query string true "Client id"
+// @Param request_uri query string true "Registration form reference"
+// @Success 200
+// @Failure 400
+// @Router /auth/realms/{realm}/login-actions/registration [get]
+// @Router /realms/{realm}/login-actions/registration [get]
+func (wCtx *WebApiContext) ShowRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, client, authRequest := wCtx.readRegistrationRequest(respWriter, request, realm, request.URL.Query().Get("client_id"),
+		request.URL.Query().Get("request_uri"))
+	if authRequest == nil {
+		return
+	}
+	wCtx.showRegistrationPage(respWriter, request, realmPtr, client, authRequest, nil, http.StatusOK, nil)
+}
+
+// SubmitRegistration this function is a Http Request Handler that creates user from browser registration form
+// @Summary Browser registration form submission
+// @Description Creates user, starts browser login and redirects to client redirect_uri with code, if realm requires email
+// @Description verification shows page that asks user to open verification link
+// @Tags registration
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param realm path string true "Realm"
+// @Param client_id formData string true "Client id"
+// @Param request_uri formData string true "Registration form reference"
+// @Param password formData string true "Password"
+// @Param password_confirm formData string true "Password confirmation"
+// @Success 200
+// @Success 302
+// @Failure 400
+// @Router /auth/realms/{realm}/login-actions/registration [post]
+// @Router /realms/{realm}/login-actions/registration [post]
+func (wCtx *WebApiContext) SubmitRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	/* User profile attributes are form fields with attribute names, form shown after failed registration keeps entered
+	 * attributes (but not password) and contains new reference
+	 */
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	formRequest := dto.RegistrationFormRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&formRequest, request.PostForm)
+	}
+	if err != nil {
+		wCtx.Logger.Debug("Registration: registration form is bad (unable to unmarshal to dto.RegistrationFormRequest)")
+		wCtx.writeLoginPage(respWriter, request, nil, "", http.StatusBadRequest, &loginPage{Realm: realm, Error: errors.LoginPageExpiredDesc})
+		return
+	}
+	realmPtr, client, authRequest := wCtx.readRegistrationRequest(respWriter, request, realm, formRequest.ClientId, formRequest.RequestUri)
+	if authRequest == nil {
+		return
+	}
+	registration := dto.RegistrationRequest{Attributes: map[string]string{}, Password: formRequest.Password}
+	for _, attribute := range getRegistrationAttributes(realmPtr.GetUserProfile()) {
+		registration.Attributes[attribute.Name] = request.PostForm.Get(attribute.Name)
+	}
+	if formRequest.Password != formRequest.PasswordConfirm {
+		check := &data.OperationError{Msg: errors.InvalidPasswordMsg, Description: errors.PasswordConfirmMismatchDesc}
+		wCtx.showRegistrationPage(respWriter, request, realmPtr, client, authRequest, registration.Attributes, http.StatusBadRequest, check)
+		return
+	}
+	user, check := (*wCtx.Security).RegisterUser(realmPtr, &registration)
+	if check != nil {
+		wCtx.showRegistrationPage(respWriter, request, realmPtr, client, authRequest, registration.Attributes, getRegistrationErrorStatus(check), check)
+		return
+	}
+	if data.HasRequiredAction(user, data.VerifyEmailAction) {
+		wCtx.sendRegistrationVerifyEmail(realmPtr, user)
+		wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, http.StatusOK,
+			&loginPage{Realm: realm, Username: user.GetUsername(), Info: verifyEmailSentInfo})
+		return
+	}
+	session := wCtx.startSsoSession(respWriter, request, realmPtr, authRequest, user)
+	if session == nil {
+		return
+	}
+	wCtx.completeLogin(respWriter, request, realmPtr, client, authRequest, session, user)
+}
+
+// readRegistrationRequest reads realm and authorization request that registration form was shown for, nil authRequest means
+// that error page was already written
+func (wCtx *WebApiContext) readRegistrationRequest(respWriter http.ResponseWriter, request *http.Request, realm string, clientId string,
+	requestUri string) (*data.Realm, *data.Client, *dto.AuthorizationRequest) {
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Registration")
+	if errDetails != nil {
+		wCtx.writeLoginPage(respWriter, request, nil, "", status, &loginPage{Realm: realm, Error: errDetails.Msg})
+		return nil, nil, nil
+	}
+	if !realmPtr.RegistrationAllowed {
+		wCtx.Logger.Debug("Registration: realm doesn't allow registration")
+		wCtx.writeLoginPage(respWriter, request, realmPtr, "", http.StatusForbidden, &loginPage{Realm: realm, Error: errors.RegistrationNotAllowedDesc})
+		return nil, nil, nil
+	}
+	authRequest := (*wCtx.Security).GetPushedAuthorizationRequest(realm, clientId, requestUri)
+	client := findRealmClient(realmPtr, clientId)
+	if authRequest == nil || client == nil || !client.IsEnabled() {
+		wCtx.Logger.Debug("Registration: registration form is expired or client is disabled")
+		wCtx.writeLoginPage(respWriter, request, realmPtr, "", http.StatusBadRequest, &loginPage{Realm: realm, Error: errors.LoginPageExpiredDesc})
+		return nil, nil, nil
+	}
+	return realmPtr, client, authRequest
+}
+
+// showRegistrationPage stores authorization request until user submits registration form and renders form with user profile
+// attributes (filled with attributes values), check is an error of previous attempt
+func (wCtx *WebApiContext) showRegistrationPage(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm, client *data.Client,
+	authRequest *dto.AuthorizationRequest, attributes map[string]string, status int, check *data.OperationError) {
+	page := loginPage{Realm: realmPtr.Name, ClientName: client.DisplayName, ClientId: client.Name, Registration: true,
+		ActionUrl: getRealmPath(request, realmPtr.Name) + registrationActionPath}
+	if len(page.ClientName) == 0 {
+		page.ClientName = client.Name
+	}
+	for _, attribute := range getRegistrationAttributes(realmPtr.GetUserProfile()) {
+		page.Attributes = append(page.Attributes, pageAttribute{Name: attribute.Name, Value: attributes[attribute.Name],
+			Required: attribute.Required || attribute.Name == data.UsernameAttribute || (attribute.Name == data.EmailAttribute && realmPtr.VerifyEmail),
+			Email:    attribute.Email})
+	}
+	page.RequestUri = (*wCtx.Security).StorePushedAuthorizationRequest(realmPtr.Name, authRequest, globals.LoginPageExpiration)
+	if check != nil {
+		page.Error = getErrorText(check)
+	}
+	wCtx.writeLoginPage(respWriter, request, realmPtr, authRequest.UiLocales, status, &page)
+}
+
+// sendRegistrationVerifyEmail sends email verification link to just registered user, registration doesn't fail if link
+// wasn't sent because user could request it again via required action endpoint
+func (wCtx *WebApiContext) sendRegistrationVerifyEmail(realm *data.Realm, user data.User) {
+	if !data.HasRequiredAction(user, data.VerifyEmailAction) {
+		return
+	}
+	if wCtx.EmailActions == nil {
+		wCtx.Logger.Warn(sf.Format("Registration: realm \"{0}\" requires email verification, but mail is not configured", realm.Name))
+		return
+	}
+	if check := (*wCtx.EmailActions).SendVerifyEmail(realm, user); check != nil {
+		wCtx.Logger.Warn(sf.Format("Registration: verification link was not sent to user \"{0}\": {1}", user.GetUsername(), check.Description))
+	}
+}
+
+// getRegistrationAttributes returns user profile attributes that registration form shows, username is always shown first,
+// read-only attributes are not shown
+func getRegistrationAttributes(profile *data.UserProfile) []data.UserProfileAttribute {
+	attributes := make([]data.UserProfileAttribute, 0, len(profile.Attributes)+1)
+	if username := profile.FindAttribute(data.UsernameAttribute); username != nil {
+		attributes = append(attributes, *username)
+	} else {
+		attributes = append(attributes, data.UserProfileAttribute{Name: data.UsernameAttribute, Required: true})
+	}
+	for _, attribute := range profile.Attributes {
+		if attribute.Name != data.UsernameAttribute && !attribute.ReadOnly {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// getRegistrationErrorStatus returns http status of registration error
+func getRegistrationErrorStatus(check *data.OperationError) int {
+	switch {
+	case check.Description == errors.UsernameExistsDesc:
+		return http.StatusConflict
+	case check.Description == errors.RegistrationNotAllowedDesc:
+		return http.StatusForbidden
+	case check.Msg == errors.ServiceIsUnavailable:
+		return http.StatusServiceUnavailable
+	case check.Msg == errors.OtherAppError:
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,121 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// ExecuteRequiredAction this function is a Http Request Handler that executes user required action that blocks login
+// @Summary Executes user required action
+// @Description Executes pending UPDATE_PASSWORD, CONFIGURE_TOTP, TERMS_AND_CONDITIONS action or sends VERIFY_EMAIL link
+// @Tags users
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param realm path string true "Realm"
+// @Param client_id formData string true "Client id (client authenticates like on token endpoint)"
+// @Param username formData string true "Username"
+// @Param password formData string true "Password"
+// @Param totp formData string false "One-time password (user with OTP configured)"
+// @Param action formData string true "Required action"
+// @Param new_password formData string false "New password (UPDATE_PASSWORD action)"
+// @Param accept formData bool false "Terms acceptance (TERMS_AND_CONDITIONS action)"
+// @Success 200 {object} dto.RequiredActionResult
+// @Success 202 {object} dto.RequiredActionResult
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/login-actions/required-action [post]
+// @Router /realms/{realm}/login-actions/required-action [post]
+func (wCtx *WebApiContext) ExecuteRequiredAction(respWriter http.ResponseWriter, request *http.Request) {
+	/* User that got "Account is not fully set up" on token request authenticates here with the same parameters and executes
+	 * actions one by one. Expired password (UPDATE_PASSWORD) and not configured OTP (CONFIGURE_TOTP) don't fail authentication
+	 * of a request with corresponding action. VERIFY_EMAIL only sends link (202), action is satisfied when user opens link
+	 */
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "Execute required action")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	credentials := dto.TokenGenerationData{}
+	actionRequest := dto.RequiredActionRequest{}
+	err := request.ParseForm()
+	if err == nil {
+		decoder := schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		err = decoder.Decode(&credentials, request.PostForm)
+		if err == nil {
+			err = decoder.Decode(&actionRequest, request.PostForm)
+		}
+	}
+	if err != nil || len(actionRequest.Action) == 0 {
+		wCtx.Logger.Debug("Execute required action: body is bad (action is required)")
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.BadBodyForRequiredActionMsg})
+		return
+	}
+	if check := wCtx.readClientAuthentication(request, realm, &credentials); check != nil {
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	if check := (*wCtx.Security).Validate(&credentials, realmPtr); check != nil {
+		wCtx.Logger.Debug("Execute required action: client data is invalid (client_id or client_secret)")
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	if check := (*wCtx.Security).CheckCredentials(&credentials, realmPtr); check != nil && !isRequiredActionCheck(check, actionRequest.Action) {
+		wCtx.Logger.Debug("Execute required action: invalid user credentials (username, password or one-time password)")
+		afterHandle(&respWriter, http.StatusUnauthorized, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	user := (*wCtx.Security).GetCurrentUserByName(realmPtr.Name, credentials.Username)
+	if user == nil {
+		afterHandle(&respWriter, http.StatusUnauthorized, &dto.ErrorDetails{Msg: errors.InvalidUserCredentialsMsg,
+			Description: errors.InvalidUserCredentialsDesc})
+		return
+	}
+	if actionRequest.Action == data.VerifyEmailAction {
+		wCtx.sendRequiredVerifyEmail(respWriter, realmPtr, user)
+		return
+	}
+	result, check := (*wCtx.Security).ExecuteRequiredAction(realmPtr, user, &actionRequest)
+	if check != nil {
+		afterHandle(&respWriter, getActionErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusOK, result)
+}
+
+// sendRequiredVerifyEmail sends email verification link to user that has VERIFY_EMAIL required action
+func (wCtx *WebApiContext) sendRequiredVerifyEmail(respWriter http.ResponseWriter, realm *data.Realm, user data.User) {
+	if !data.HasRequiredAction(user, data.VerifyEmailAction) {
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.ActionNotRequiredDesc})
+		return
+	}
+	if wCtx.EmailActions == nil {
+		afterHandle(&respWriter, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc})
+		return
+	}
+	if check := (*wCtx.EmailActions).SendVerifyEmail(realm, user); check != nil {
+		afterHandle(&respWriter, getActionErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	wCtx.Logger.Debug(sf.Format("Execute required action: verification link was sent to user \"{0}\"", user.GetUsername()))
+	result := dto.RequiredActionResult{Username: user.GetUsername(), RequiredActions: user.GetRequiredActions()}
+	afterHandle(&respWriter, http.StatusAccepted, &result)
+}
+
+// isRequiredActionCheck checks whether credentials check failed only because of state that action fixes (password or OTP),
+// both errors are returned only after password was checked
+func isRequiredActionCheck(check *data.OperationError, action string) bool {
+	return (check.Description == errors.PasswordExpiredDesc && action == data.UpdatePasswordAction) ||
+		(check.Description == errors.OtpNotConfiguredDesc && action == data.ConfigureTotpAction)
+}
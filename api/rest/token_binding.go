@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const wwwAuthenticateHeader = "WWW-Authenticate"
+
+// getTokenBinding returns confirmation (cnf claim) of tokens that are issued on token request
+/* Access token could be bound to client certificate (RFC 8705) and to DPoP proof key (RFC 9449). On refresh new tokens keep
+ * binding of previous tokens: DPoP proof must be signed with the same key (certificate is checked before refresh)
+ * Parameters:
+ *    - request - token request
+ *    - client - client that requests token (could be nil)
+ *    - previous - confirmation of refreshed tokens (nil on new token request)
+ * Returns: confirmation (nil if tokens are not bound) or error if binding is required but proof is invalid or missing
+ */
+func (wCtx *WebApiContext) getTokenBinding(request *http.Request, client *data.Client, previous *data.TokenConfirmation) (*data.TokenConfirmation, *data.OperationError) {
+	confirmation := data.TokenConfirmation{}
+	if previous.IsCertificateBound() {
+		confirmation.X509Thumbprint = previous.X509Thumbprint
+	} else {
+		certificateConfirmation, check := getCertificateConfirmation(request, client)
+		if check != nil {
+			return nil, check
+		}
+		if certificateConfirmation != nil {
+			confirmation.X509Thumbprint = certificateConfirmation.X509Thumbprint
+		}
+	}
+	proofs := request.Header.Values(globals.DPoPHeader)
+	switch {
+	case len(proofs) > 1:
+		return nil, &data.OperationError{Msg: errors.InvalidDPoPProofMsg, Description: errors.InvalidDPoPProofDesc}
+	case len(proofs) == 1:
+		thumbprint, check := (*wCtx.Security).CheckDPoPProof(proofs[0], request.Method, wCtx.getRequestUris(request), "")
+		if check != nil {
+			return nil, check
+		}
+		if previous.IsDPoPBound() && previous.JwkThumbprint != thumbprint {
+			return nil, &data.OperationError{Msg: errors.InvalidDPoPProofMsg, Description: errors.DPoPKeyMismatchDesc}
+		}
+		confirmation.JwkThumbprint = thumbprint
+	case previous.IsDPoPBound() || (client != nil && client.DPoPBoundAccessTokens):
+		return nil, &data.OperationError{Msg: errors.InvalidDPoPProofMsg, Description: errors.DPoPProofRequiredDesc}
+	}
+	if !confirmation.IsCertificateBound() && !confirmation.IsDPoPBound() {
+		return nil, nil
+	}
+	return &confirmation, nil
+}
+
+// checkTokenPossession checks that client that sends access token possesses certificate or DPoP key that token is bound to
+/* DPoP-bound token must be sent with DPoP authorization scheme and DPoP proof with hash of access token (ath), DPoP scheme is
+ * not allowed for tokens that are not bound to DPoP key
+ * Parameters:
+ *    - respWriter - response writer, WWW-Authenticate header is set on DPoP error
+ *    - request - protected resource request
+ *    - scheme - authorization scheme (Bearer or DPoP)
+ *    - accessToken - access token from authorization header
+ *    - confirmation - access token binding
+ * Returns: nil if client possesses token, otherwise error
+ */
+func (wCtx *WebApiContext) checkTokenPossession(respWriter http.ResponseWriter, request *http.Request, scheme string, accessToken string,
+	confirmation *data.TokenConfirmation) *data.OperationError {
+	if confirmation.IsCertificateBound() && !confirmation.MatchesCertificate(getClientCertificate(request)) {
+		return &data.OperationError{Msg: errors.InvalidTokenMsg, Description: errors.CertificateBindingFailedDesc}
+	}
+	if !confirmation.IsDPoPBound() && scheme != string(DPoPToken) {
+		return nil
+	}
+	check := wCtx.checkDPoPProofOfPossession(request, scheme, accessToken, confirmation)
+	if check != nil {
+		errorCode := "invalid_token"
+		if check.Msg == errors.InvalidDPoPProofMsg {
+			errorCode = errors.InvalidDPoPProofMsg
+		}
+		respWriter.Header().Set(wwwAuthenticateHeader, sf.Format("{0} error=\"{1}\", algs=\"{2}\"", string(DPoPToken), errorCode,
+			strings.Join(getDPoPSigningAlgorithms(), " ")))
+	}
+	return check
+}
+
+func (wCtx *WebApiContext) checkDPoPProofOfPossession(request *http.Request, scheme string, accessToken string,
+	confirmation *data.TokenConfirmation) *data.OperationError {
+	if !confirmation.IsDPoPBound() {
+		return &data.OperationError{Msg: errors.InvalidTokenMsg, Description: errors.TokenIsNotDPoPBoundDesc}
+	}
+	if scheme != string(DPoPToken) {
+		return &data.OperationError{Msg: errors.InvalidTokenMsg, Description: errors.DPoPProofRequiredDesc}
+	}
+	proofs := request.Header.Values(globals.DPoPHeader)
+	if len(proofs) != 1 {
+		return &data.OperationError{Msg: errors.InvalidDPoPProofMsg, Description: errors.DPoPProofRequiredDesc}
+	}
+	thumbprint, check := (*wCtx.Security).CheckDPoPProof(proofs[0], request.Method, wCtx.getRequestUris(request), accessToken)
+	if check != nil {
+		return check
+	}
+	if thumbprint != confirmation.JwkThumbprint {
+		return &data.OperationError{Msg: errors.InvalidDPoPProofMsg, Description: errors.DPoPKeyMismatchDesc}
+	}
+	return nil
+}
+
+// getRequestUris returns request uri as client sees it, all routes are available with and without /auth prefix therefore both
+// variants are returned
+func (wCtx *WebApiContext) getRequestUris(request *http.Request) []string {
+	baseUri := sf.Format("{0}://{1}", wCtx.Schema, wCtx.Address)
+	path := strings.TrimPrefix(request.URL.Path, "/auth/")
+	if path == request.URL.Path {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return []string{sf.Format("{0}/{1}", baseUri, path), sf.Format("{0}/auth/{1}", baseUri, path)}
+}
+
+// getDPoPSigningAlgorithms returns algorithms that client could use to sign DPoP proof (only asymmetric algorithms are allowed)
+func getDPoPSigningAlgorithms() []string {
+	return []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+}
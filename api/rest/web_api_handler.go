@@ -1,7 +1,6 @@
 package rest
 
 import (
-	"encoding/base64"
 	e "errors"
 	"net/http"
 	"strings"
@@ -33,7 +32,7 @@ import (
 // @Router /realms/{realm}/protocol/openid-connect/token [post]
 func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request *http.Request) {
 	/* For issue new token user should send POST request of type x-www-from-urlencoded with following pairs key=value
-	 * grant_type=password (password only supported), client_id (data.Client name), if client is Confidential also client_secret,
+	 * grant_type=password (password, authorization_code, passkey and CIBA grants are supported), client_id (data.Client name), if client is Confidential also client_secret,
 	 * scope=profile email, username and password
 	 * For refreshing existing token user should send POST request of type x-www-from-urlencoded with following
 	 * pairs key=value client_id, client_secret (if data.Client is Confidential), grant_type=refresh_token and refresh_token itself
@@ -66,6 +65,10 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 					result = dto.ErrorDetails{Msg: sf.Format(errors.OtherAppError, realm)}
 				}
 			}
+		} else if !realmPtr.IsEnabled() {
+			status = http.StatusForbidden
+			wCtx.Logger.Debug("New token issue: realm is disabled")
+			result = dto.ErrorDetails{Msg: errors.AccessDeniedMsg, Description: errors.RealmDisabledDesc}
 		} else {
 			tokenGenerationData := dto.TokenGenerationData{}
 			err := request.ParseForm()
@@ -81,9 +84,15 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 					status = http.StatusBadRequest
 					wCtx.Logger.Debug("New token issue: body is bad (unable to unmarshal to dto.TokenGenerationData)")
 					result = dto.ErrorDetails{Msg: errors.BadBodyForTokenGenerationMsg}
+				} else if check := wCtx.readClientAuthentication(request, realm, &tokenGenerationData); check != nil {
+					status = http.StatusBadRequest
+					wCtx.Logger.Debug("New token issue: client authentication data is inconsistent")
+					result = dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
 				} else {
 					var currentUser data.User
 					var userId uuid.UUID
+					// confirmation binds access token to client certificate (RFC 8705)
+					var confirmation *data.TokenConfirmation
 					issueTokens := false
 					// 0. Check whether we deal with issuing a new token or refresh previous one
 					isRefresh := isTokenRefreshRequest(&tokenGenerationData)
@@ -93,8 +102,15 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 						if session == nil {
 							status = http.StatusUnauthorized
 							result = dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.TokenIsNotActive}
+						} else if session.Confirmation.IsCertificateBound() &&
+							!session.Confirmation.MatchesCertificate(tokenGenerationData.ClientCertificate) {
+							// refresh token could be used only with the same certificate that tokens are bound to
+							status = http.StatusBadRequest
+							wCtx.Logger.Debug("New token issue: refresh request client certificate does not match token binding")
+							result = dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.CertificateBindingFailedDesc}
 						} else {
 							userId = session.UserId
+							confirmation = session.Confirmation
 							sessionExpired, refreshExpired := (*wCtx.Security).CheckSessionAndRefreshExpired(realm, userId)
 							if sessionExpired {
 								// session expired, should request new one
@@ -116,6 +132,36 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 
 						}
 
+					} else if tokenGenerationData.GrantType == globals.WebAuthnGrantType {
+						// passkey login: client passes navigator.credentials.get() result
+						var errDetails *dto.ErrorDetails
+						currentUser, status, errDetails = wCtx.checkWebAuthnGrant(realmPtr, &tokenGenerationData)
+						if errDetails != nil {
+							result = errDetails
+						} else {
+							userId = currentUser.GetId()
+							issueTokens = true
+						}
+					} else if tokenGenerationData.GrantType == globals.AuthorizationCodeGrantType {
+						// browser login: client exchanges code that authorization endpoint passed to redirect_uri
+						var errDetails *dto.ErrorDetails
+						currentUser, status, errDetails = wCtx.checkAuthorizationCodeGrant(realmPtr, &tokenGenerationData)
+						if errDetails != nil {
+							result = errDetails
+						} else {
+							userId = currentUser.GetId()
+							issueTokens = true
+						}
+					} else if tokenGenerationData.GrantType == globals.CibaGrantType {
+						// CIBA: client polls for tokens with auth_req_id
+						var errDetails *dto.ErrorDetails
+						currentUser, status, errDetails = wCtx.checkBackChannelGrant(realmPtr, &tokenGenerationData)
+						if errDetails != nil {
+							result = errDetails
+						} else {
+							userId = currentUser.GetId()
+							issueTokens = true
+						}
 					} else {
 						check := (*wCtx.Security).Validate(&tokenGenerationData, realmPtr)
 						// 1. Pair client_id && client_secret validation
@@ -125,9 +171,9 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 							result = dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
 						} else {
 							// 2. User credentials validation
-							check = (*wCtx.Security).CheckCredentials(&tokenGenerationData, realmPtr.Name)
+							check = (*wCtx.Security).CheckCredentials(&tokenGenerationData, realmPtr)
 							if check != nil {
-								wCtx.Logger.Debug("New token issue: invalid user credentials (username or password)")
+								wCtx.Logger.Debug("New token issue: invalid user credentials (username, password or one-time password)")
 								status = http.StatusUnauthorized
 								result = dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
 							} else {
@@ -137,6 +183,38 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 							}
 						}
 					}
+					if issueTokens {
+						// user could be disabled after login (refresh) or could log in without password (passkey, CIBA)
+						if check := (*wCtx.Security).CheckUserState(realmPtr, currentUser); check != nil {
+							status = http.StatusBadRequest
+							result = dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+							issueTokens = false
+						} else if client := findRealmClient(realmPtr, tokenGenerationData.ClientId); client != nil && !client.IsEnabled() {
+							// refresh request doesn't authenticate client, therefore client state is checked here
+							status = http.StatusBadRequest
+							wCtx.Logger.Debug("New token issue: client is disabled")
+							result = dto.ErrorDetails{Msg: errors.InvalidClientMsg, Description: errors.ClientDisabledDesc}
+							issueTokens = false
+						}
+					}
+					if issueTokens && !isRefresh && len(currentUser.GetRequiredActions()) > 0 {
+						// login completes only after user executes pending required actions (login-actions/required-action)
+						status = http.StatusBadRequest
+						wCtx.Logger.Debug(sf.Format("New token issue: user \"{0}\" has pending required actions", currentUser.GetUsername()))
+						result = dto.ErrorDetails{Msg: errors.InvalidUserCredentialsMsg, Description: errors.AccountNotSetUpDesc}
+						issueTokens = false
+					}
+					if issueTokens {
+						// on refresh confirmation contains binding of previous tokens
+						var check *data.OperationError
+						confirmation, check = wCtx.getTokenBinding(request, findRealmClient(realmPtr, tokenGenerationData.ClientId), confirmation)
+						if check != nil {
+							status = http.StatusBadRequest
+							wCtx.Logger.Debug(sf.Format("New token issue: token binding failed: {0}", check.Description))
+							result = dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+							issueTokens = false
+						}
+					}
 					if issueTokens {
 						// 3. Create access token && refresh token
 						duration := realmPtr.TokenExpiration
@@ -144,6 +222,7 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 						refreshDuration := realmPtr.RefreshTokenExpiration
 						// 4. Save session
 						sessionId := (*wCtx.Security).StartOrUpdateSession(realm, userId, duration, refresh)
+						(*wCtx.Security).AssignTokenConfirmation(realm, userId, confirmation)
 						session := (*wCtx.Security).GetSession(realm, userId)
 						// 5. Generate new tokens
 						accessToken := wCtx.TokenGenerator.GenerateJwtAccessToken(wCtx.getRealmBaseUrl(realm), string(BearerToken),
@@ -152,12 +231,17 @@ func (wCtx *WebApiContext) IssueNewToken(respWriter http.ResponseWriter, request
 							globals.ProfileEmailScope, session)
 						(*wCtx.Security).AssignTokens(realm, userId, &accessToken, &refreshToken)
 						// 6. Assign token to result
+						issuedTokenType := BearerToken
+						if confirmation.IsDPoPBound() {
+							issuedTokenType = DPoPToken
+						}
 						result = dto.Token{
 							AccessToken: accessToken, Expires: duration, RefreshToken: refreshToken,
-							RefreshExpires: refreshDuration, TokenType: string(BearerToken), NotBeforePolicy: 0, Session: sessionId.String(),
+							RefreshExpires: refreshDuration, TokenType: string(issuedTokenType), NotBeforePolicy: 0, Session: sessionId.String(),
 						}
 
 					}
+					wCtx.addTokenEvent(realm, &tokenGenerationData, isRefresh, userId, result)
 				}
 			}
 		}
@@ -210,16 +294,20 @@ func (wCtx *WebApiContext) GetUserInfo(respWriter http.ResponseWriter, request *
 					result = dto.ErrorDetails{Msg: sf.Format(errors.OtherAppError, realm)}
 				}
 			}
+		} else if !realmPtr.IsEnabled() {
+			status = http.StatusForbidden
+			wCtx.Logger.Debug("Get userinfo: realm is disabled")
+			result = dto.ErrorDetails{Msg: errors.AccessDeniedMsg, Description: errors.RealmDisabledDesc}
 		} else {
 			// Just get access token,  find user + session
 			authorization := request.Header.Get(authorizationHeader)
-			parts := strings.Split(authorization, " ")
-			if parts[0] != string(BearerToken) {
-				wCtx.Logger.Debug("Get userinfo: expected only Bearer authorization yet")
+			scheme, accessToken, _ := strings.Cut(authorization, " ")
+			if (scheme != string(BearerToken) && scheme != string(DPoPToken)) || len(accessToken) == 0 {
+				wCtx.Logger.Debug("Get userinfo: expected only Bearer or DPoP authorization yet")
 				status = http.StatusBadRequest
 				result = dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.InvalidRequestDesc}
 			} else {
-				session := (*wCtx.Security).GetSessionByAccessToken(realm, &parts[1])
+				session := (*wCtx.Security).GetSessionByAccessToken(realm, &accessToken)
 				if session == nil {
 					wCtx.Logger.Debug("Get userinfo: invalid token")
 					status = http.StatusUnauthorized
@@ -229,10 +317,19 @@ func (wCtx *WebApiContext) GetUserInfo(respWriter http.ResponseWriter, request *
 						status = http.StatusUnauthorized
 						wCtx.Logger.Debug("Get userinfo: token expired")
 						result = dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidTokenDesc}
+					} else if check := wCtx.checkTokenPossession(respWriter, request, scheme, accessToken, session.Confirmation); check != nil {
+						status = http.StatusUnauthorized
+						wCtx.Logger.Debug(sf.Format("Get userinfo: token possession check failed: {0}", check.Description))
+						result = dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
 					} else {
 						user, _ := (*wCtx.DataProvider).GetUserById(realmPtr.Name, session.UserId)
 						status = http.StatusOK
-						if user != nil {
+						if user != nil && (*wCtx.Security).CheckUserState(realmPtr, user) != nil {
+							// tokens of disabled user are not active
+							status = http.StatusUnauthorized
+							wCtx.Logger.Debug("Get userinfo: user is disabled or is not valid")
+							result = dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidTokenDesc}
+						} else if user != nil {
 							result = user.GetUserInfo()
 						}
 					}
@@ -296,28 +393,23 @@ func (wCtx *WebApiContext) Introspect(respWriter http.ResponseWriter, request *h
 		afterHandle(&respWriter, status, &result)
 		return
 	}
-	authorization := request.Header.Get(authorizationHeader)
-	parts := strings.Split(authorization, " ")
-	if parts[0] != "Basic" {
-		status := http.StatusBadRequest
-		wCtx.Logger.Debug(sf.Format("Introspect: Basic value not provided in Authorization header value - \"{0}\"", parts[0]))
-		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.InvalidRequestDesc}
-		afterHandle(&respWriter, status, &result)
+	if !realmPtr.IsEnabled() {
+		wCtx.Logger.Debug("Introspect: realm is disabled")
+		result := dto.ErrorDetails{Msg: errors.AccessDeniedMsg, Description: errors.RealmDisabledDesc}
+		afterHandle(&respWriter, http.StatusForbidden, &result)
 		return
 	}
-	basicString, err := base64.StdEncoding.DecodeString(parts[1])
-	if err != nil {
+	// client could authenticate with Basic Authorization header or with any other supported method
+	_ = request.ParseForm()
+	isBasic := strings.HasPrefix(request.Header.Get(authorizationHeader), basicAuthorization+" ")
+	if !isBasic && len(request.PostForm.Get(clientIdFormKey)) == 0 && len(request.PostForm.Get(clientAssertionFormKey)) == 0 {
 		status := http.StatusBadRequest
-		wCtx.Logger.Debug(sf.Format("Introspect: invalid client credentials encoding, should be base64, decoding error: {0}", err.Error()))
-		result := dto.ErrorDetails{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCredentialDesc}
+		wCtx.Logger.Debug("Introspect: client authentication was not provided")
+		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.InvalidRequestDesc}
 		afterHandle(&respWriter, status, &result)
 		return
 	}
-	secretPair := strings.Split(string(basicString), ":")
-	checkResult := (*wCtx.Security).Validate(&dto.TokenGenerationData{
-		ClientSecret: secretPair[1],
-		ClientId:     secretPair[0],
-	}, realmPtr)
+	_, checkResult := wCtx.authenticateClient(request, realmPtr)
 	if checkResult != nil {
 		status := http.StatusUnauthorized
 		wCtx.Logger.Debug("Introspect: invalid client credentials")
@@ -335,12 +427,22 @@ func (wCtx *WebApiContext) Introspect(respWriter http.ResponseWriter, request *h
 		return
 	}
 	active := !session.Expired.Before(time.Now())
+	if active {
+		// token of user that was disabled (or became not valid) after login is not active
+		user := (*wCtx.Security).GetCurrentUserById(realmPtr.Name, session.UserId)
+		active = user != nil && (*wCtx.Security).CheckUserState(realmPtr, user) == nil
+	}
 	status := http.StatusOK
 	authTokenType := string(BearerToken)
+	if session.Confirmation.IsDPoPBound() {
+		// resource server must check DPoP proof with cnf.jkt (RFC 9449 section 6.2)
+		authTokenType = string(DPoPToken)
+	}
 	result := dto.IntrospectTokenResult{
 		Active: active,
 		Type:   authTokenType,
 		Exp:    realmPtr.TokenExpiration,
+		Cnf:    session.Confirmation,
 	}
 	afterHandle(&respWriter, status, &result)
 }
@@ -389,29 +491,115 @@ func (wCtx *WebApiContext) GetOpenIdConfiguration(respWriter http.ResponseWriter
 				}
 			}
 		} else {
-			realmPath := sf.Format("auth/realms/{0}", realm)
 			protocolPath := "protocol/openid-connect"
-			// What is important is that server could be behind reverse proxy
-			fullAddress := sf.Format("{0}://{1}", wCtx.Schema, wCtx.Address)
 			openIdConfig := dto.OpenIdConfiguration{}
-			openIdConfig.Issuer = sf.Format("{0}/{1}", fullAddress, realmPath)
+			openIdConfig.Issuer = wCtx.getRealmIssuer(realm)
 			openIdConfig.TokenEndpoint = sf.Format("{0}/{1}/token", openIdConfig.Issuer, protocolPath)
 			openIdConfig.IntrospectionEndpoint = sf.Format("{0}/{1}/introspect", openIdConfig.Issuer, protocolPath)
 			openIdConfig.UserInfoEndpoint = sf.Format("{0}/{1}/userinfo", openIdConfig.Issuer, protocolPath)
 			openIdConfig.AuthorizationEndpoint = sf.Format("{0}/{1}/auth", openIdConfig.Issuer, protocolPath)
+			openIdConfig.PushedAuthorizationRequestEndpoint = sf.Format("{0}/{1}/ext/par/request", openIdConfig.Issuer, protocolPath)
+			openIdConfig.RegistrationEndpoint = sf.Format("{0}/clients-registrations/openid-connect", openIdConfig.Issuer)
+			if wCtx.BackChannelAuth != nil {
+				openIdConfig.BackChannelAuthenticationEndpoint = sf.Format("{0}/{1}/ext/ciba/auth", openIdConfig.Issuer, protocolPath)
+				openIdConfig.BackChannelTokenDeliveryModesSupported = []string{string(data.PollDeliveryMode), string(data.PingDeliveryMode)}
+			}
 			// TODO(UMV): assign other endpoint as soon
 			openIdConfig.ClaimsSupported = wCtx.AuthDefs.SupportedClaims
 			openIdConfig.ClaimTypesSupported = wCtx.AuthDefs.SupportedClaimTypes
 			openIdConfig.GrantTypesSupported = wCtx.AuthDefs.SupportedGrantTypes
-			openIdConfig.CodeChallengeMethodsSupported = []string{}
+			openIdConfig.CodeChallengeMethodsSupported = []string{data.PlainCodeChallengeMethod, data.S256CodeChallengeMethod}
 			openIdConfig.ResponseModesSupported = wCtx.AuthDefs.SupportedResponses
 			openIdConfig.ResponseTypesSupported = wCtx.AuthDefs.SupportedResponseTypes
+			openIdConfig.TokenEndpointAuthMethodsSupported = wCtx.getClientAuthMethods()
+			openIdConfig.TokenEndpointAuthSigningAlgValuesSupported = getClientAuthSigningAlgorithms()
+			openIdConfig.IntrospectionEndpointAuthMethodsSupported = wCtx.getClientAuthMethods()
+			openIdConfig.TlsClientCertificateBoundAccessToken = wCtx.MutualTls
+			openIdConfig.DPoPSigningAlgValuesSupported = getDPoPSigningAlgorithms()
 			result = openIdConfig
 		}
 	}
 	afterHandle(&respWriter, status, &result)
 }
 
+// readRealm gets realm from DataProvider and converts read error to http status and error details
+/* This function is a common part of all realm handlers: realm must exist and data provider should be available
+ * Parameters:
+ *    - realm - name of a realm
+ *    - operation - handler name for logging
+ * Returns: realm (nil if error occurred), http status and error details (nil if realm was successfully read)
+ */
+func (wCtx *WebApiContext) readRealm(realm string, operation string) (*data.Realm, int, *dto.ErrorDetails) {
+	if len(realm) == 0 {
+		wCtx.Logger.Debug(sf.Format("{0}: realm wasn't provided", operation))
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.RealmNotProviderMsg}
+	}
+	realmPtr, realmReadErr := (*wCtx.DataProvider).GetRealm(realm)
+	if realmReadErr != nil {
+		if e.As(realmReadErr, &errors.ErrDataSourceNotAvailable) {
+			wCtx.Logger.Error("Data provider not available")
+			return nil, http.StatusServiceUnavailable, &dto.ErrorDetails{Msg: errors.ServiceIsUnavailable}
+		}
+		if e.As(realmReadErr, &errors.EmptyNotFoundErr) {
+			wCtx.Logger.Debug(sf.Format("{0}: realm doesn't exist", operation))
+			return nil, http.StatusNotFound, &dto.ErrorDetails{Msg: sf.Format(errors.RealmDoesNotExistsTemplate, realm)}
+		}
+		wCtx.Logger.Error(sf.Format("Other error occurred: {0}", realmReadErr.Error()))
+		return nil, http.StatusInternalServerError, &dto.ErrorDetails{Msg: sf.Format(errors.OtherAppError, realm)}
+	}
+	if !realmPtr.IsEnabled() {
+		wCtx.Logger.Debug(sf.Format("{0}: realm is disabled", operation))
+		return nil, http.StatusForbidden, &dto.ErrorDetails{Msg: errors.AccessDeniedMsg, Description: errors.RealmDisabledDesc}
+	}
+	return realmPtr, http.StatusOK, nil
+}
+
+// readAuthenticatedUser returns user that owns access token from Authorization header (Bearer or DPoP scheme)
+/* This function is a common part of handlers that users call with their own access token (like userinfo)
+ * Parameters:
+ *    - respWriter - response writer, WWW-Authenticate header is set on DPoP error
+ *    - request - http request with Authorization header
+ *    - realmPtr - realm, token owner must be enabled and valid at this time
+ *    - operation - handler name for logging
+ * Returns: user (nil if error occurred), http status and error details (nil if token is valid)
+ */
+func (wCtx *WebApiContext) readAuthenticatedUser(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm,
+	operation string) (data.User, int, *dto.ErrorDetails) {
+	_, user, status, errDetails := wCtx.readAuthenticatedSession(respWriter, request, realmPtr, operation)
+	return user, status, errDetails
+}
+
+// readAuthenticatedSession returns session of access token from Authorization header and user that owns session, see
+// readAuthenticatedUser for parameters
+func (wCtx *WebApiContext) readAuthenticatedSession(respWriter http.ResponseWriter, request *http.Request, realmPtr *data.Realm,
+	operation string) (*data.UserSession, data.User, int, *dto.ErrorDetails) {
+	realm := realmPtr.Name
+	scheme, accessToken, _ := strings.Cut(request.Header.Get(authorizationHeader), " ")
+	if (scheme != string(BearerToken) && scheme != string(DPoPToken)) || len(accessToken) == 0 {
+		wCtx.Logger.Debug(sf.Format("{0}: expected only Bearer or DPoP authorization", operation))
+		return nil, nil, http.StatusUnauthorized, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.InvalidRequestDesc}
+	}
+	session := (*wCtx.Security).GetSessionByAccessToken(realm, &accessToken)
+	if session == nil || session.Expired.Before(time.Now()) {
+		wCtx.Logger.Debug(sf.Format("{0}: invalid or expired token", operation))
+		return nil, nil, http.StatusUnauthorized, &dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidTokenDesc}
+	}
+	if check := wCtx.checkTokenPossession(respWriter, request, scheme, accessToken, session.Confirmation); check != nil {
+		wCtx.Logger.Debug(sf.Format("{0}: token possession check failed: {1}", operation, check.Description))
+		return nil, nil, http.StatusUnauthorized, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	user := (*wCtx.Security).GetCurrentUserById(realm, session.UserId)
+	if user == nil || (*wCtx.Security).CheckUserState(realmPtr, user) != nil {
+		return nil, nil, http.StatusUnauthorized, &dto.ErrorDetails{Msg: errors.InvalidTokenMsg, Description: errors.InvalidTokenDesc}
+	}
+	return session, user, http.StatusOK, nil
+}
+
+// getRealmIssuer returns full realm url, what is important is that server could be behind reverse proxy
+func (wCtx *WebApiContext) getRealmIssuer(realm string) string {
+	return sf.Format("{0}://{1}/auth/realms/{2}", wCtx.Schema, wCtx.Address, realm)
+}
+
 func (wCtx *WebApiContext) getRealmBaseUrl(realm string) string {
 	return sf.Format("/{0}/{1}/auth/realms/{2}", wCtx.Schema, wCtx.Address, realm)
 }
@@ -423,6 +611,49 @@ func isTokenRefreshRequest(tokenIssueData *dto.TokenGenerationData) bool {
 	return true
 }
 
+// addTokenEvent records user event of token request: refresh request is REFRESH_TOKEN, authorization code exchange is
+// CODE_TO_TOKEN, other grants are LOGIN, failed request has error event type (i.e. LOGIN_ERROR)
+func (wCtx *WebApiContext) addTokenEvent(realm string, tokenGenerationData *dto.TokenGenerationData, isRefresh bool, userId uuid.UUID,
+	result interface{}) {
+	if wCtx.Events == nil {
+		return
+	}
+	eventType := data.LoginEvent
+	if isRefresh {
+		eventType = data.RefreshTokenEvent
+	} else if tokenGenerationData.GrantType == globals.AuthorizationCodeGrantType {
+		eventType = data.CodeToTokenEvent
+	}
+	event := data.Event{Time: time.Now(), Type: eventType, Realm: realm, ClientId: tokenGenerationData.ClientId, UserId: userId,
+		IpAddress: tokenGenerationData.ClientAddress, Details: map[string]string{"grant_type": tokenGenerationData.GrantType}}
+	if len(tokenGenerationData.Username) > 0 {
+		event.Details["username"] = tokenGenerationData.Username
+	}
+	var errDetails *dto.ErrorDetails
+	switch value := result.(type) {
+	case dto.Token:
+		event.SessionId, _ = uuid.Parse(value.Session)
+	case dto.ErrorDetails:
+		errDetails = &value
+	case *dto.ErrorDetails:
+		errDetails = value
+	}
+	if errDetails != nil {
+		event.Type = eventType.GetErrorType()
+		event.Error = errDetails.Msg
+		if len(errDetails.Description) > 0 {
+			event.Details["reason"] = errDetails.Description
+		}
+		// user of failed login is known if username is correct
+		if userId == uuid.Nil && len(tokenGenerationData.Username) > 0 {
+			if user := (*wCtx.Security).GetCurrentUserByName(realm, tokenGenerationData.Username); user != nil {
+				event.UserId = user.GetId()
+			}
+		}
+	}
+	(*wCtx.Events).AddEvent(&event)
+}
+
 // reserved for future use
 func getUserIP(r *http.Request) string {
 	IPAddress := r.Header.Get("X-Real-Ip")
@@ -0,0 +1,171 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// StartWebAuthnRegistration this function is a Http Request Handler that issues passkey registration options
+// @Summary Issues passkey registration options
+// @Description Issues options for navigator.credentials.create(), user must be authenticated with own access token
+// @Tags webauthn
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param realm path string true "Realm"
+// @Success 200 {object} dto.PublicKeyCredentialCreationOptions
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/webauthn/register/options [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/webauthn/register/options [post]
+func (wCtx *WebApiContext) StartWebAuthnRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "WebAuthn registration options")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, realmPtr, "WebAuthn registration options")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	options, check := (*wCtx.Security).StartWebAuthnRegistration(realmPtr, user)
+	if check != nil {
+		afterHandle(&respWriter, getWebAuthnErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusOK, options)
+}
+
+// FinishWebAuthnRegistration this function is a Http Request Handler that verifies registration response and stores passkey
+// @Summary Registers passkey
+// @Description Verifies navigator.credentials.create() result and adds passkey to user credentials
+// @Tags webauthn
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ACCESS_TOKEN"
+// @Param function body dto.WebAuthnRegistration true "Registration response"
+// @Param realm path string true "Realm"
+// @Success 201 {object} dto.PublicKeyCredentialDescriptor
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 401 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/webauthn/register [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/webauthn/register [post]
+func (wCtx *WebApiContext) FinishWebAuthnRegistration(respWriter http.ResponseWriter, request *http.Request) {
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "WebAuthn registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	user, status, errDetails := wCtx.readAuthenticatedUser(respWriter, request, realmPtr, "WebAuthn registration")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	registration := dto.WebAuthnRegistration{}
+	if err := json.NewDecoder(request.Body).Decode(&registration); err != nil {
+		wCtx.Logger.Debug("WebAuthn registration: body is bad (unable to unmarshal to dto.WebAuthnRegistration)")
+		result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForWebAuthnMsg}
+		afterHandle(&respWriter, http.StatusBadRequest, &result)
+		return
+	}
+	check := (*wCtx.Security).FinishWebAuthnRegistration(realmPtr, user, &registration)
+	if check != nil {
+		afterHandle(&respWriter, getWebAuthnErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	result := dto.PublicKeyCredentialDescriptor{Type: registration.Credential.Type, Id: registration.Credential.Id}
+	afterHandle(&respWriter, http.StatusCreated, &result)
+}
+
+// StartWebAuthnLogin this function is a Http Request Handler that issues passkey login options
+// @Summary Issues passkey login options
+// @Description Issues options for navigator.credentials.get(), without username any discoverable passkey could be used
+// @Tags webauthn
+// @Accept json
+// @Produce json
+// @Param function body dto.WebAuthnLoginOptionsRequest false "User that logs in"
+// @Param realm path string true "Realm"
+// @Success 200 {object} dto.PublicKeyCredentialRequestOptions
+// @Failure 400 {string} dto.ErrorDetails
+// @Failure 404 {string} dto.ErrorDetails
+// @Router /auth/realms/{realm}/protocol/openid-connect/ext/webauthn/login/options [post]
+// @Router /realms/{realm}/protocol/openid-connect/ext/webauthn/login/options [post]
+func (wCtx *WebApiContext) StartWebAuthnLogin(respWriter http.ResponseWriter, request *http.Request) {
+	/* Assertion (navigator.credentials.get() result) is exchanged for tokens on token endpoint with
+	 * grant_type=urn:ferrum:params:grant-type:webauthn and webauthn_assertion={assertion json}
+	 */
+	beforeHandle(&respWriter)
+	vars := mux.Vars(request)
+	realm := vars[globals.RealmPathVar]
+	realmPtr, status, errDetails := wCtx.readRealm(realm, "WebAuthn login options")
+	if errDetails != nil {
+		afterHandle(&respWriter, status, errDetails)
+		return
+	}
+	optionsRequest := dto.WebAuthnLoginOptionsRequest{}
+	if request.ContentLength != 0 {
+		if err := json.NewDecoder(request.Body).Decode(&optionsRequest); err != nil {
+			wCtx.Logger.Debug("WebAuthn login options: body is bad (unable to unmarshal to dto.WebAuthnLoginOptionsRequest)")
+			result := dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForWebAuthnMsg}
+			afterHandle(&respWriter, http.StatusBadRequest, &result)
+			return
+		}
+	}
+	options, check := (*wCtx.Security).StartWebAuthnLogin(realmPtr, optionsRequest.Username)
+	if check != nil {
+		afterHandle(&respWriter, getWebAuthnErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description})
+		return
+	}
+	afterHandle(&respWriter, http.StatusOK, options)
+}
+
+// checkWebAuthnGrant validates passkey login grant (grant_type=urn:ferrum:params:grant-type:webauthn) on token endpoint
+/* Parameters:
+ *    - realmPtr - realm
+ *    - tokenGenerationData - token request with client credentials and webauthn_assertion
+ * Returns: user that owns passkey or http status with error details
+ */
+func (wCtx *WebApiContext) checkWebAuthnGrant(realmPtr *data.Realm, tokenGenerationData *dto.TokenGenerationData) (data.User, int, *dto.ErrorDetails) {
+	check := (*wCtx.Security).Validate(tokenGenerationData, realmPtr)
+	if check != nil {
+		wCtx.Logger.Debug("New token issue: client data is invalid (client_id or client_secret)")
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	assertion := dto.PublicKeyCredential{}
+	if err := json.Unmarshal([]byte(tokenGenerationData.WebAuthnAssertion), &assertion); err != nil {
+		wCtx.Logger.Debug("New token issue: webauthn_assertion is not a valid PublicKeyCredential json")
+		return nil, http.StatusBadRequest, &dto.ErrorDetails{Msg: errors.InvalidRequestMsg, Description: errors.BadBodyForWebAuthnMsg}
+	}
+	user, check := (*wCtx.Security).CheckWebAuthnAssertion(realmPtr, &assertion, tokenGenerationData.ClientAddress)
+	if check != nil {
+		wCtx.Logger.Debug(sf.Format("New token issue: passkey login failed: {0}", check.Description))
+		return nil, getWebAuthnErrorStatus(check), &dto.ErrorDetails{Msg: check.Msg, Description: check.Description}
+	}
+	return user, http.StatusOK, nil
+}
+
+// getWebAuthnErrorStatus returns http status of WebAuthn operation error
+func getWebAuthnErrorStatus(check *data.OperationError) int {
+	switch check.Msg {
+	case errors.ServiceIsUnavailable:
+		return http.StatusServiceUnavailable
+	case errors.InvalidUserCredentialsMsg:
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
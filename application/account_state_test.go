@@ -0,0 +1,119 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const (
+	testStateRealm   = "staterealm"
+	testStateUserId  = "667ff6a7-3f6b-449b-a217-6fc5d9ac0b01"
+	testStateClient2 = "test-state-client-2"
+)
+
+func createAccountStateServerData() *data.ServerData {
+	disabled := false
+	user := createTestHashingUser(testAuthUser, testStateUserId, map[string]interface{}{"password": testAuthUserPassword})
+	return &data.ServerData{Realms: []data.Realm{
+		{Name: testStateRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+			Clients: []data.Client{
+				{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+				{Name: testStateClient2, Type: data.Confidential, Enabled: &disabled,
+					Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+			},
+			Users: []interface{}{user},
+		},
+	}}
+}
+
+func TestDisabledUser(t *testing.T) {
+	app := createTestApp(t, createAccountStateServerData())
+	response := issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword)
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	assert.True(t, introspectStateToken(t, app, token.AccessToken))
+
+	updateStateUser(t, app, func(user data.User) { require.NoError(t, user.SetEnabled(false)) })
+	checkErrorDetails(t, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword), http.StatusUnauthorized,
+		errors.UserDisabledDesc)
+	// wrong password doesn't reveal account state
+	checkErrorDetails(t, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, "wrong"), http.StatusUnauthorized,
+		errors.InvalidUserCredentialsDesc)
+	form := url.Values{"client_id": {testClient1}, "client_secret": {testClient1Secret}, "grant_type": {globals.RefreshTokenGrantType},
+		"refresh_token": {token.RefreshToken}}
+	checkErrorDetails(t, doFormRequest(t, app, "/auth/realms/"+testStateRealm+"/protocol/openid-connect/token", form, nil),
+		http.StatusBadRequest, errors.UserDisabledDesc)
+	assert.False(t, introspectStateToken(t, app, token.AccessToken))
+	request := httptest.NewRequest(http.MethodGet, "/auth/realms/"+testStateRealm+"/protocol/openid-connect/userinfo", nil)
+	request.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	response = httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	updateStateUser(t, app, func(user data.User) { require.NoError(t, user.SetEnabled(true)) })
+	assert.Equal(t, http.StatusOK, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword).Code)
+}
+
+func TestUserValidity(t *testing.T) {
+	app := createTestApp(t, createAccountStateServerData())
+	now := time.Now()
+	updateStateUser(t, app, func(user data.User) { require.NoError(t, user.SetValidity(now.Add(time.Hour), time.Time{})) })
+	checkErrorDetails(t, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword), http.StatusUnauthorized,
+		errors.UserNotValidDesc)
+	updateStateUser(t, app, func(user data.User) { require.NoError(t, user.SetValidity(time.Time{}, now.Add(-time.Minute))) })
+	checkErrorDetails(t, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword), http.StatusUnauthorized,
+		errors.UserNotValidDesc)
+	updateStateUser(t, app, func(user data.User) {
+		require.NoError(t, user.SetValidity(now.Add(-time.Minute), now.Add(time.Hour)))
+	})
+	assert.Equal(t, http.StatusOK, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword).Code)
+}
+
+func TestDisabledClientAndRealm(t *testing.T) {
+	serverData := createAccountStateServerData()
+	app := createTestApp(t, serverData)
+	form := url.Values{"client_id": {testStateClient2}, "client_secret": {testClient1Secret}, "grant_type": {globals.PasswordGrantType},
+		"username": {testAuthUser}, "password": {testAuthUserPassword}}
+	checkErrorDetails(t, doFormRequest(t, app, "/auth/realms/"+testStateRealm+"/protocol/openid-connect/token", form, nil),
+		http.StatusBadRequest, errors.ClientDisabledDesc)
+	// disabled client state is not visible with wrong secret
+	form.Set("client_secret", "wrong")
+	checkErrorDetails(t, doFormRequest(t, app, "/auth/realms/"+testStateRealm+"/protocol/openid-connect/token", form, nil),
+		http.StatusBadRequest, errors.InvalidClientCredentialDesc)
+
+	disabled := false
+	serverData.Realms[0].Enabled = &disabled
+	app = createTestApp(t, serverData)
+	checkErrorDetails(t, issuePasswordGrantToken(t, app, testStateRealm, testAuthUser, testAuthUserPassword), http.StatusForbidden,
+		errors.RealmDisabledDesc)
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, "/auth/realms/"+testStateRealm+"/protocol/openid-connect/ext/email/verify", "", ""),
+		http.StatusForbidden, errors.RealmDisabledDesc)
+}
+
+func updateStateUser(t *testing.T, app *Application, update func(user data.User)) {
+	user, err := (*app.dataProvider).GetUser(testStateRealm, testAuthUser)
+	require.NoError(t, err)
+	update(user)
+	require.NoError(t, (*app.dataProvider).UpdateUser(testStateRealm, testAuthUser, user))
+}
+
+func introspectStateToken(t *testing.T, app *Application, token string) bool {
+	form := url.Values{"client_id": {testClient1}, "client_secret": {testClient1Secret}, "token": {token}}
+	response := doFormRequest(t, app, "/auth/realms/"+testStateRealm+"/protocol/openid-connect/token/introspect", form, nil)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var result dto.IntrospectTokenResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	return result.Active
+}
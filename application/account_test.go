@@ -0,0 +1,214 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	testAccountRealm    = "accountrealm"
+	testAccountPassword = "Acc0untPassw0rd"
+	testAccountPath     = "/auth/realms/" + testAccountRealm + "/account"
+)
+
+func TestAccountProfile(t *testing.T) {
+	app := createAccountTestApp(t, nil)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+	response := doJsonRequest(t, app, http.MethodGet, testAccountPath, "", token)
+	info := readAccountInfo(t, response)
+	assert.Equal(t, testAuthUser, info["preferred_username"])
+	assert.Equal(t, true, info["email_verified"])
+
+	// changed attributes are stored, email change resets verification, empty value removes attribute
+	response = doJsonRequest(t, app, http.MethodPost, testAccountPath, `{"given_name": " Ivan ", "email": "new@ferrum.test", "family_name": ""}`, token)
+	info = readAccountInfo(t, response)
+	assert.Equal(t, "Ivan", info["given_name"])
+	assert.Equal(t, "new@ferrum.test", info["email"])
+	assert.Equal(t, false, info["email_verified"])
+	assert.NotContains(t, info, "family_name")
+	user, err := (*app.dataProvider).GetUser(testAccountRealm, testAuthUser)
+	require.NoError(t, err)
+	assert.Equal(t, "new@ferrum.test", user.GetEmail())
+
+	testCases := []struct {
+		name                string
+		body                string
+		expectedDescription string
+	}{
+		{name: "username", body: `{"preferred_username": "other"}`, expectedDescription: sf.Format(errors.AttributeReadOnlyDesc, "preferred_username")},
+		{name: "reserved", body: `{"sub": "` + uuid.New().String() + `"}`, expectedDescription: sf.Format(errors.AttributeNotAllowedDesc, "sub")},
+		{name: "unknown", body: `{"roles": "admin"}`, expectedDescription: sf.Format(errors.AttributeNotAllowedDesc, "roles")},
+		{name: "invalid_email", body: `{"email": "not-an-email"}`, expectedDescription: sf.Format(errors.AttributeInvalidEmailDesc, "email")},
+		{name: "required_email", body: `{"email": ""}`, expectedDescription: sf.Format(errors.AttributeRequiredDesc, "email")},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			response := doJsonRequest(t, app, http.MethodPost, testAccountPath, tCase.body, token)
+			checkErrorDetails(t, response, http.StatusBadRequest, tCase.expectedDescription)
+		})
+	}
+	response = doJsonRequest(t, app, http.MethodGet, testAccountPath, "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestAccountProfileAttributeTypes(t *testing.T) {
+	profile := data.UserProfile{Attributes: []data.UserProfileAttribute{
+		{Name: data.UsernameAttribute, Required: true},
+		{Name: data.EmailAttribute, Email: true},
+		{Name: "age", Type: data.NumberAttributeType},
+		{Name: "languages", Type: data.ArrayAttributeType, MaxLength: 2},
+		{Name: "department", ReadOnly: true},
+	}}
+	app := createAccountTestApp(t, &profile)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+	// values are stored with attribute types, read-only attribute could be sent unchanged
+	response := doJsonRequest(t, app, http.MethodPost, testAccountPath, `{"age": "33", "languages": "en, ru", "department": "it"}`, token)
+	info := readAccountInfo(t, response)
+	assert.Equal(t, float64(33), info["age"])
+	assert.Equal(t, []interface{}{"en", "ru"}, info["languages"])
+
+	testCases := []struct {
+		name                string
+		body                string
+		expectedDescription string
+	}{
+		{name: "read_only", body: `{"department": "hr"}`, expectedDescription: sf.Format(errors.AttributeReadOnlyDesc, "department")},
+		{name: "not_a_number", body: `{"age": "old"}`, expectedDescription: sf.Format(errors.AttributeInvalidTypeDesc, "age", "a number")},
+		{name: "array_item", body: `{"languages": "en, rus"}`, expectedDescription: sf.Format(errors.AttributeMaxLengthDesc, "languages", 2)},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			response := doJsonRequest(t, app, http.MethodPost, testAccountPath, tCase.body, token)
+			checkErrorDetails(t, response, http.StatusBadRequest, tCase.expectedDescription)
+		})
+	}
+}
+
+func TestAccountPasswordChange(t *testing.T) {
+	app := createAccountTestApp(t, nil)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+	passwordPath := testAccountPath + "/credentials/password"
+	response := doJsonRequest(t, app, http.MethodPost, passwordPath, `{"currentPassword": "wrong", "newPassword": "N3wPassw0rdValue"}`, token)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidCurrentPasswordDesc)
+	response = doJsonRequest(t, app, http.MethodPost, passwordPath, `{"currentPassword": "`+testAccountPassword+`", "newPassword": "short"}`, token)
+	checkErrorDetails(t, response, http.StatusBadRequest, sf.Format(errors.PasswordMinLengthDesc, 10))
+	response = doJsonRequest(t, app, http.MethodPost, passwordPath, `{"currentPassword": "`+testAccountPassword+
+		`", "newPassword": "N3wPassw0rdValue", "confirmation": "other"}`, token)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.PasswordConfirmMismatchDesc)
+
+	response = doJsonRequest(t, app, http.MethodPost, passwordPath, `{"currentPassword": "`+testAccountPassword+
+		`", "newPassword": "N3wPassw0rdValue", "confirmation": "N3wPassw0rdValue"}`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, "N3wPassw0rdValue"))
+}
+
+func TestAccountOtp(t *testing.T) {
+	app := createAccountTestApp(t, nil)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+	credentials := readAccountCredentials(t, app, token)
+	require.Len(t, credentials, 1)
+	assert.Equal(t, data.PasswordCredentialType, credentials[0].Type)
+
+	otpPath := testAccountPath + "/credentials/otp"
+	response := doJsonRequest(t, app, http.MethodPost, otpPath, "", token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	var enrollment dto.OtpEnrollment
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &enrollment))
+	assert.Contains(t, enrollment.OtpUri, "otpauth://totp/")
+	assert.NotEmpty(t, enrollment.RecoveryCodes)
+	response = doJsonRequest(t, app, http.MethodPost, otpPath, "", token)
+	checkErrorDetails(t, response, http.StatusConflict, errors.OtpAlreadyConfiguredDesc)
+	credentials = readAccountCredentials(t, app, token)
+	require.Len(t, credentials, 2)
+	assert.Equal(t, data.OtpCredentialType, credentials[1].Type)
+	assert.Equal(t, len(enrollment.RecoveryCodes), credentials[1].RecoveryCodes)
+	// user with OTP must provide second factor
+	response = issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	response = doJsonRequest(t, app, http.MethodDelete, otpPath, "", token)
+	assert.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodDelete, otpPath, "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.OtpNotFoundDesc)
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+}
+
+func TestAccountSessions(t *testing.T) {
+	app := createAccountTestApp(t, nil)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+	sessionsPath := testAccountPath + "/sessions"
+	sessions := readAccountSessions(t, doJsonRequest(t, app, http.MethodGet, sessionsPath, "", token))
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+	assert.False(t, sessions[0].Browser)
+	assert.Greater(t, sessions[0].Expires, sessions[0].Started)
+
+	// without current=true session of access token is kept
+	response := doJsonRequest(t, app, http.MethodDelete, sessionsPath, "", token)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	require.Len(t, readAccountSessions(t, doJsonRequest(t, app, http.MethodGet, sessionsPath, "", token)), 1)
+	response = doJsonRequest(t, app, http.MethodDelete, sessionsPath+"/"+uuid.New().String(), "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.SessionNotFoundDesc)
+	response = doJsonRequest(t, app, http.MethodDelete, sessionsPath+"/"+sessions[0].Id, "", token)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	// signed out session tokens are not valid anymore
+	response = doJsonRequest(t, app, http.MethodGet, sessionsPath, "", token)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	token = getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAccountRealm, testAuthUser, testAccountPassword))
+	response = doJsonRequest(t, app, http.MethodDelete, sessionsPath+"?current=true", "", token)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, testAccountPath, "", token)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func createAccountTestApp(t *testing.T, profile *data.UserProfile) *Application {
+	user := createTestHashingUser(testAuthUser, "0b7e5c1e-94a6-4d7e-8d3e-6f2a1c9b7d40", map[string]interface{}{"password": testAccountPassword})
+	info := user.(map[string]interface{})["info"].(map[string]interface{})
+	info["email"] = "account@ferrum.test"
+	info["email_verified"] = true
+	info["family_name"] = "Ivanov"
+	info["department"] = "it"
+	realm := data.Realm{Name: testAccountRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		PasswordPolicy: &data.PasswordPolicy{MinLength: 10}, UserProfile: profile,
+		Clients: []data.Client{
+			{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+		},
+		Users: []interface{}{user},
+	}
+	return createTestApp(t, &data.ServerData{Realms: []data.Realm{realm}})
+}
+
+func readAccountInfo(t *testing.T, response *httptest.ResponseRecorder) map[string]interface{} {
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var info map[string]interface{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &info))
+	return info
+}
+
+func readAccountCredentials(t *testing.T, app *Application, token string) []dto.AccountCredential {
+	response := doJsonRequest(t, app, http.MethodGet, testAccountPath+"/credentials", "", token)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var credentials []dto.AccountCredential
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &credentials))
+	return credentials
+}
+
+func readAccountSessions(t *testing.T, response *httptest.ResponseRecorder) []dto.AccountSession {
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var sessions []dto.AccountSession
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &sessions))
+	return sessions
+}
@@ -0,0 +1,159 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const testManagedUserPath = testManagedRealmPath + "/users/" + testManagedUserId
+
+func TestAdminConsoleIsDisabledByDefault(t *testing.T) {
+	app := createAdminTestApp(t)
+	response := doJsonRequest(t, app, http.MethodGet, "/auth/admin/console/", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, "/auth/admin/console/config.json", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestAdminConsoleIsServed(t *testing.T) {
+	appConfig := httpAppConfig
+	appConfig.AdminConsole = &config.AdminConsoleConfig{}
+	app := createTestAppWithConfig(t, &appConfig, createAdminTestData())
+
+	response := doJsonRequest(t, app, http.MethodGet, "/auth/admin/console/", "", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.True(t, strings.HasPrefix(response.Header().Get("Content-Type"), "text/html"))
+	assert.Contains(t, response.Header().Get("Content-Security-Policy"), "frame-ancestors 'none'")
+	assert.Contains(t, response.Body.String(), "console.js")
+	response = doJsonRequest(t, app, http.MethodGet, "/admin/console/console.js", "", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, "/auth/admin/console/missing.js", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	consoleConfig := readAdminResponse[dto.AdminConsoleConfig](t, doJsonRequest(t, app, http.MethodGet, "/auth/admin/console/config.json", "", ""))
+	assert.Equal(t, dto.AdminConsoleConfig{Realm: testAdminRealm, ClientId: config.DefaultAdminConsoleClientId,
+		TokenUrl: "/auth/realms/master/protocol/openid-connect/token", AdminUrl: "/auth/admin"}, consoleConfig)
+	consoleConfig = readAdminResponse[dto.AdminConsoleConfig](t, doJsonRequest(t, app, http.MethodGet, "/admin/console/config.json", "", ""))
+	assert.Equal(t, "/realms/master/protocol/openid-connect/token", consoleConfig.TokenUrl)
+	assert.Equal(t, "/admin", consoleConfig.AdminUrl)
+}
+
+func TestAdminConsoleOnAdminListener(t *testing.T) {
+	appConfig := httpAppConfig
+	appConfig.ServerCfg.AdminListener = &config.AdminListenerConfig{Schema: config.HTTP, Address: "10.0.0.1", Port: 9000}
+	appConfig.AdminConsole = &config.AdminConsoleConfig{ClientId: testClient1}
+	app := createAdminListenerTestApp(t, &appConfig)
+
+	response := doJsonRequest(t, app, http.MethodGet, "/auth/admin/console/", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	response = doAdminListenerRequest(app, http.MethodGet, "/auth/admin/console/", "", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// console gets admin realm tokens from admin listener, other realms tokens are not issued by admin listener
+	form := url.Values{"client_id": {testClient1}, "client_secret": {testClient1Secret}, "grant_type": {globals.PasswordGrantType},
+		"username": {testAdminUser}, "password": {testAdminPassword}}
+	response = doAdminListenerFormRequest(app, "/auth/realms/"+testAdminRealm+"/protocol/openid-connect/token", form)
+	token := getTokenFromResponse(t, response)
+	response = doAdminListenerRequest(app, http.MethodGet, testAdminRealmsPath, "", token)
+	assert.Equal(t, http.StatusOK, response.Code)
+	form.Set("username", testManagedUser)
+	form.Set("password", testAuthUserPassword)
+	response = doAdminListenerFormRequest(app, "/auth/realms/"+testManagedRealm+"/protocol/openid-connect/token", form)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestAdminUserSessions(t *testing.T) {
+	app := createAdminTestApp(t)
+	adminToken := getAdminToken(t, app)
+	userToken := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testManagedRealm, testManagedUser, testAuthUserPassword))
+
+	sessions := readAdminResponse[[]dto.UserSessionRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedUserPath+"/sessions", "", adminToken))
+	require.Len(t, sessions, 1)
+	assert.Equal(t, testManagedUser, sessions[0].Username)
+	assert.Equal(t, testManagedUserId, sessions[0].UserId)
+	assert.False(t, sessions[0].Browser)
+	assert.Greater(t, sessions[0].Expires, sessions[0].Start)
+
+	// killed session tokens are not active
+	response := doJsonRequest(t, app, http.MethodDelete, testManagedUserPath+"/sessions/"+sessions[0].Id, "", adminToken)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodGet, "/auth/realms/"+testManagedRealm+"/protocol/openid-connect/userinfo", "", userToken)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodDelete, testManagedUserPath+"/sessions/"+sessions[0].Id, "", adminToken),
+		http.StatusNotFound, errors.SessionNotFoundDesc)
+
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testManagedRealm, testManagedUser, testAuthUserPassword))
+	response = doJsonRequest(t, app, http.MethodPost, testManagedUserPath+"/logout", "", adminToken)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	sessions = readAdminResponse[[]dto.UserSessionRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedUserPath+"/sessions", "", adminToken))
+	assert.Empty(t, sessions)
+
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/users/"+testManagedClientId+"/sessions", "", adminToken),
+		http.StatusNotFound, errors.UserNotFoundDesc)
+}
+
+func TestAdminEvents(t *testing.T) {
+	app := createAdminPermissionsTestApp(t)
+	adminToken := getAdminToken(t, app)
+	response := issuePasswordGrantToken(t, app, testManagedRealm, testManagedUser, "wrong")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	response = issuePasswordGrantToken(t, app, testManagedRealm, testManagedUser, testAuthUserPassword)
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	form := url.Values{"client_id": {testClient1}, "client_secret": {testClient1Secret}, "grant_type": {globals.RefreshTokenGrantType},
+		"refresh_token": {token.RefreshToken}}
+	response = doFormRequest(t, app, "/auth/realms/"+testManagedRealm+"/protocol/openid-connect/token", form, nil)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	events := readAdminResponse[[]dto.EventRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/events", "", adminToken))
+	require.Len(t, events, 3)
+	assert.Equal(t, string(data.RefreshTokenEvent), events[0].Type)
+	assert.Equal(t, string(data.LoginEvent), events[1].Type)
+	assert.Equal(t, token.Session, events[1].SessionId)
+	assert.Equal(t, testClient1, events[1].ClientId)
+	assert.Equal(t, testManagedUser, events[1].Details["username"])
+	assert.Equal(t, string(data.LoginErrorEvent), events[2].Type)
+	assert.Equal(t, errors.InvalidUserCredentialsMsg, events[2].Error)
+	assert.Equal(t, testManagedUserId, events[2].UserId)
+	assert.Empty(t, events[2].SessionId)
+
+	events = readAdminResponse[[]dto.EventRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testManagedRealmPath+"/events?type=LOGIN&type=LOGIN_ERROR&max=1", "", adminToken))
+	require.Len(t, events, 1)
+	assert.Equal(t, string(data.LoginEvent), events[0].Type)
+	events = readAdminResponse[[]dto.EventRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testManagedRealmPath+"/events?user="+testManagedUserId+"&first=2", "", adminToken))
+	require.Len(t, events, 1)
+	assert.Equal(t, string(data.LoginErrorEvent), events[0].Type)
+	events = readAdminResponse[[]dto.EventRepresentation](t, doJsonRequest(t, app, http.MethodGet, testOtherRealmPath+"/events", "", adminToken))
+	assert.Empty(t, events)
+
+	// events are not visible to users administrators
+	viewerToken := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testUsersViewerUser, testAuthUserPassword))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/events", "", viewerToken), http.StatusForbidden,
+		sf.Format(errors.AdminPermissionRequiredDesc, data.ViewEventsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath+"/missing/events", "", adminToken), http.StatusNotFound,
+		errors.RealmNotFoundDesc)
+}
+
+func doAdminListenerFormRequest(app *Application, path string, form url.Values) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	(*app.adminHttpHandler).ServeHTTP(response, request)
+	return response
+}
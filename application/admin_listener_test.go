@@ -0,0 +1,116 @@
+package application
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+)
+
+func TestAdminEndpointsOnAdminListener(t *testing.T) {
+	appConfig := httpAppConfig
+	appConfig.ServerCfg.AdminListener = &config.AdminListenerConfig{Schema: config.HTTP, Address: "10.0.0.1", Port: 9000}
+	app := createAdminListenerTestApp(t, &appConfig)
+	require.NotNil(t, app.adminHttpHandler)
+	token := getAdminToken(t, app)
+
+	// admin and management endpoints are not served by main listener
+	response := doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", token)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, "/health/ready", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, "/swagger/index.html", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+
+	// token issued by main listener is accepted by admin listener
+	response = doAdminListenerRequest(app, http.MethodGet, testAdminRealmsPath, "", token)
+	realms := readAdminResponse[[]dto.RealmRepresentation](t, response)
+	assert.Len(t, realms, 2)
+	response = doAdminListenerRequest(app, http.MethodPost, testManagedRealmPath+"/users", `{"username":"ivan"}`, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	assert.True(t, strings.HasPrefix(response.Header().Get("Location"), "http://10.0.0.1:9000/auth/admin/realms/managed/users/"))
+
+	response = doAdminListenerRequest(app, http.MethodGet, "/health/ready", "", "")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var health dto.HealthStatus
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &health))
+	assert.Equal(t, dto.HealthStatusUp, health.Status)
+	require.Len(t, health.Checks, 1)
+	assert.Equal(t, dto.HealthStatusUp, health.Checks[0].Status)
+	response = doAdminListenerRequest(app, http.MethodGet, "/health/live", "", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = doAdminListenerRequest(app, http.MethodGet, "/swagger/index.html", "", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// public endpoints are not served by admin listener
+	response = doAdminListenerRequest(app, http.MethodGet, "/auth/realms/"+testManagedRealm+"/.well-known/openid-configuration", "", "")
+	assert.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestAdminEndpointsWithoutAdminListener(t *testing.T) {
+	app := createAdminTestApp(t)
+	assert.Nil(t, app.adminHttpHandler)
+	response := doJsonRequest(t, app, http.MethodGet, "/health", "", "")
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", getAdminToken(t, app))
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestAdminListenerOnHttps(t *testing.T) {
+	appConfig := httpAppConfig
+	appConfig.ServerCfg.Port = 8296
+	appConfig.ServerCfg.AdminListener = &config.AdminListenerConfig{Schema: config.HTTPS, Address: "127.0.0.1", Port: 8297,
+		Security: &config.SecurityConfig{KeyFile: filepath.Join("..", "certs", "server.key"),
+			CertificateFile: filepath.Join("..", "certs", "server.crt")}}
+	app := createAdminListenerTestApp(t, &appConfig)
+	res, err := app.Start()
+	require.True(t, res)
+	require.NoError(t, err)
+	client := &http.Client{Timeout: time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}} //nolint:gosec
+
+	var response *http.Response
+	require.Eventually(t, func() bool {
+		response, err = client.Get("https://127.0.0.1:8297/health/live")
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	mainResponse, err := client.Get("http://127.0.0.1:8296/health/live")
+	require.NoError(t, err)
+	defer mainResponse.Body.Close()
+	assert.Equal(t, http.StatusNotFound, mainResponse.StatusCode)
+}
+
+func TestAdminListenerConfigIsValidated(t *testing.T) {
+	appConfig := httpAppConfig
+	appConfig.ServerCfg.AdminListener = &config.AdminListenerConfig{Schema: config.HTTPS, Address: "127.0.0.1", Port: 9000}
+	app := CreateAppWithData(&appConfig, &data.ServerData{}, testKey, true).(*Application)
+	res, err := app.Init()
+	assert.False(t, res)
+	assert.Error(t, err)
+}
+
+func createAdminListenerTestApp(t *testing.T, appConfig *config.AppConfig) *Application {
+	return createTestAppWithConfig(t, appConfig, createAdminTestData())
+}
+
+func doAdminListenerRequest(app *Application, method string, path string, body string, bearerToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if len(bearerToken) > 0 {
+		request.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+	response := httptest.NewRecorder()
+	(*app.adminHttpHandler).ServeHTTP(response, request)
+	return response
+}
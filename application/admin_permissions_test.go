@@ -0,0 +1,172 @@
+package application
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	testRealmAdminUser    = "sales"
+	testRealmAdminUserId  = "5b0f3d6e-2c4a-4e8b-9a71-d3f6c2b8e415"
+	testUsersViewerUser   = "viewer"
+	testClientManagerUser = "crm"
+	testClientsQuerier    = "auditor"
+	testOtherRealm        = "other"
+	testOtherRealmPath    = testAdminRealmsPath + "/" + testOtherRealm
+)
+
+func TestAdminRealmAdminCanNotManageOtherRealms(t *testing.T) {
+	app := createAdminPermissionsTestApp(t)
+	rootToken := getAdminToken(t, app)
+	// realm-admin permission is granted to user via admin realm group
+	response := doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath+"/"+testAdminRealm+"/roles", `{"name":"managed/realm-admin"}`, rootToken)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath+"/"+testAdminRealm+"/groups", `{"name":"sales-admins"}`, rootToken)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	location := response.Header().Get("Location")
+	groupId := location[strings.LastIndex(location, "/")+1:]
+	response = doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath+"/"+testAdminRealm+"/groups/"+groupId+"/role-mappings/realm",
+		`[{"name":"managed/realm-admin"}]`, rootToken)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPut, testAdminRealmsPath+"/"+testAdminRealm+"/users/"+testRealmAdminUserId+"/groups/"+groupId, "", rootToken)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testRealmAdminUser, testAuthUserPassword))
+	realms := readAdminResponse[[]dto.RealmRepresentation](t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", token))
+	require.Len(t, realms, 1)
+	assert.Equal(t, testManagedRealm, realms[0].Realm)
+
+	// managed realm could be fully administered
+	response = doJsonRequest(t, app, http.MethodPut, testManagedRealmPath, `{"realm":"managed","accessTokenLifespan":60}`, token)
+	assert.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/users", `{"username":"ivan"}`, token)
+	assert.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/clients", `{"clientId":"sales-app"}`, token)
+	assert.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/roles", `{"name":"seller"}`, token)
+	assert.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+
+	// other realms, admin realm and realm creation are not allowed
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testOtherRealmPath, "", token), http.StatusForbidden,
+		sf.Format(errors.AdminPermissionRequiredDesc, data.QueryRealmsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodDelete, testOtherRealmPath, "", token), http.StatusForbidden,
+		sf.Format(errors.AdminPermissionRequiredDesc, data.RealmAdminPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testOtherRealmPath+"/users", "", token), http.StatusForbidden,
+		sf.Format(errors.AdminPermissionRequiredDesc, data.QueryUsersPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testOtherRealmPath+"/users", `{"username":"ivan"}`, token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageUsersPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testOtherRealmPath+"/clients", "", token), http.StatusForbidden,
+		sf.Format(errors.AdminPermissionRequiredDesc, data.QueryClientsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath+"/"+testAdminRealm+"/users", "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.QueryUsersPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, `{"realm":"created"}`, token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.AdminRole))
+	// forbidden realm is not created, updated or removed
+	response = doJsonRequest(t, app, http.MethodGet, testOtherRealmPath+"/users", "", rootToken)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	realms = readAdminResponse[[]dto.RealmRepresentation](t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", rootToken))
+	assert.Len(t, realms, 3)
+}
+
+func TestAdminUsersViewerPermissions(t *testing.T) {
+	app := createAdminPermissionsTestApp(t)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testUsersViewerUser, testAuthUserPassword))
+
+	users := readAdminResponse[[]dto.UserRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/users", "", token))
+	require.Len(t, users, 1)
+	response := doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/users/"+testManagedUserId+"/role-mappings", "", token)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	realm := readAdminResponse[dto.RealmRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath, "", token))
+	assert.Equal(t, testManagedRealm, realm.Realm)
+
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPut, testManagedRealmPath+"/users/"+testManagedUserId, `{"lastName":"Petrov"}`, token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageUsersPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodDelete, testManagedRealmPath+"/users/"+testManagedUserId, "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageUsersPermission))
+	response = doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/users/"+testManagedUserId+"/sessions", "", token)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/users/"+testManagedUserId+"/logout", "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageUsersPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients", "", token), http.StatusForbidden,
+		sf.Format(errors.AdminPermissionRequiredDesc, data.QueryClientsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPut, testManagedRealmPath, `{"realm":"managed"}`, token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageRealmPermission))
+}
+
+func TestAdminClientManagerPermissions(t *testing.T) {
+	app := createAdminPermissionsTestApp(t)
+	rootToken := getAdminToken(t, app)
+	response := doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/clients", `{"clientId":"erp"}`, rootToken)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	location := response.Header().Get("Location")
+	otherClientId := location[strings.LastIndex(location, "/")+1:]
+
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testClientManagerUser, testAuthUserPassword))
+	realms := readAdminResponse[[]dto.RealmRepresentation](t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", token))
+	require.Len(t, realms, 1)
+	assert.Equal(t, testManagedRealm, realms[0].Realm)
+	clients := readAdminResponse[[]dto.ClientRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients", "", token))
+	require.Len(t, clients, 1)
+	assert.Equal(t, testClient1, clients[0].ClientId)
+	assert.Nil(t, clients[0].Secret)
+	client := readAdminResponse[dto.ClientRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testManagedRealmPath+"/clients/"+testManagedClientId, "", token))
+	require.NotNil(t, client.Secret)
+	assert.Equal(t, testClient1Secret, *client.Secret)
+
+	// only client that permission is granted for could be managed
+	response = doJsonRequest(t, app, http.MethodPut, testManagedRealmPath+"/clients/"+testManagedClientId,
+		`{"clientId":"testclient1","redirectUris":["https://crm.ferrum.test/cb"]}`, token)
+	assert.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	secret := readAdminResponse[dto.CredentialRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testManagedRealmPath+"/clients/"+testManagedClientId+"/client-secret", "", token))
+	assert.Equal(t, testClient1Secret, secret.Value)
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients/"+otherClientId, "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ViewClientsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodDelete, testManagedRealmPath+"/clients/"+otherClientId, "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageClientsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/clients", `{"clientId":"crm2"}`, token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ManageClientsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/users", "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.QueryUsersPermission))
+	client = readAdminResponse[dto.ClientRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients/"+otherClientId, "", rootToken))
+	assert.Equal(t, "erp", client.ClientId)
+}
+
+func TestAdminClientsQuerierDoesNotSeeSecrets(t *testing.T) {
+	app := createAdminPermissionsTestApp(t)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testClientsQuerier, testAuthUserPassword))
+	clients := readAdminResponse[[]dto.ClientRepresentation](t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients", "", token))
+	require.Len(t, clients, 1)
+	assert.Equal(t, testClient1, clients[0].ClientId)
+	require.NotNil(t, clients[0].ClientAuthenticatorType)
+	assert.Nil(t, clients[0].Secret)
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients/"+testManagedClientId, "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ViewClientsPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodGet, testManagedRealmPath+"/clients/"+testManagedClientId+"/client-secret", "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.ViewClientsPermission))
+}
+
+// createAdminPermissionsTestApp creates admin test application with other realm and admin realm users that have permissions
+// in managed realm: realm administrator (permission is not granted yet), users viewer, clients querier and manager of one client
+func createAdminPermissionsTestApp(t *testing.T) *Application {
+	realmAdmin := createTestHashingUser(testRealmAdminUser, testRealmAdminUserId, map[string]interface{}{"password": testAuthUserPassword})
+	usersViewer := createTestHashingUser(testUsersViewerUser, "e2a7c9d4-6b1f-4f3a-8c5e-7d9b0a1c2e36", map[string]interface{}{"password": testAuthUserPassword})
+	usersViewer.(map[string]interface{})["info"].(map[string]interface{})[data.RolesAttribute] = []interface{}{"managed/view-users"}
+	clientManager := createTestHashingUser(testClientManagerUser, "0c4e8a2f-9d3b-4a6e-b1f7-5e2d8c6a9b03", map[string]interface{}{"password": testAuthUserPassword})
+	clientManager.(map[string]interface{})["info"].(map[string]interface{})[data.RolesAttribute] = []interface{}{"managed/manage-clients/" + testClient1}
+	clientsQuerier := createTestHashingUser(testClientsQuerier, "7a1d3e5c-8b2f-4c6a-9e0d-1f3b5a7c9e24", map[string]interface{}{"password": testAuthUserPassword})
+	clientsQuerier.(map[string]interface{})["info"].(map[string]interface{})[data.RolesAttribute] = []interface{}{"managed/query-clients"}
+	app := createAdminTestApp(t, realmAdmin, usersViewer, clientManager, clientsQuerier)
+	response := doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, `{"realm":"other"}`, getAdminToken(t, app))
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	return app
+}
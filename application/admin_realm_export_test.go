@@ -0,0 +1,167 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	testImportedRealm     = "imported"
+	testImportedRealmPath = testAdminRealmsPath + "/" + testImportedRealm
+	testImportedUser      = "keycloak_user"
+	testImportedPassword  = "keycloak_password"
+	testExportPath        = testImportedRealmPath + "/partial-export?exportClients=true&exportGroupsAndRoles=true&exportUsers=true"
+)
+
+// testKeyCloakRealmExport is a KeyCloak realm export with pbkdf2-sha256 hash of "keycloak_password" password
+const testKeyCloakRealmExport = `{
+  "realm": "imported",
+  "enabled": true,
+  "accessTokenLifespan": 300,
+  "passwordPolicy": "length(8) and digits(1) and hashAlgorithm(pbkdf2-sha256)",
+  "bruteForceProtected": true,
+  "failureFactor": 5,
+  "roles": {
+    "realm": [{"name": "developer"}, {"name": "offline_access"}],
+    "client": {"account": [{"name": "manage-account"}], "testclient1": [{"name": "reader"}]}
+  },
+  "groups": [{"name": "devs", "path": "/devs", "realmRoles": ["developer"], "subGroups": [{"name": "backend", "path": "/devs/backend", "subGroups": []}]}],
+  "clients": [
+    {"clientId": "testclient1", "enabled": true, "protocol": "openid-connect", "publicClient": false,
+     "clientAuthenticatorType": "client-secret", "secret": "fb6Z4RsOadVycQoeQiN57xpu8w8wplYz", "protocolMappers": [{"name": "audience"}]},
+    {"clientId": "account", "enabled": true, "protocol": "openid-connect", "publicClient": true},
+    {"clientId": "saml-app", "enabled": true, "protocol": "saml"}
+  ],
+  "users": [
+    {"id": "c6ff1a3e-4a37-4cb4-9c4c-9b0b7ed6e004", "username": "keycloak_user", "email": "kc@ferrum.io", "enabled": true,
+     "emailVerified": true, "realmRoles": ["developer", "uma_authorization"], "groups": ["/devs"],
+     "clientRoles": {"account": ["manage-account"]},
+     "credentials": [{"type": "password",
+       "secretData": "{\"value\":\"4GwXh4B3vdGnAioqUKtdtwxY0m7lAVXP3PsAb0oGQkp1QbQAzk4UtoK5HdVDJJfb9JZME+qJRb8HlILQeVK97g==\",\"salt\":\"ZmVycnVtLWtjLXNhbHQxNg==\",\"additionalParameters\":{}}",
+       "credentialData": "{\"hashIterations\":27500,\"algorithm\":\"pbkdf2-sha256\",\"additionalParameters\":{}}"}]},
+    {"username": "service-account-testclient1", "serviceAccountClientId": "testclient1"}
+  ],
+  "identityProviders": [{"alias": "github", "providerId": "github"}],
+  "authenticationFlows": [{"alias": "browser", "builtIn": true}, {"alias": "custom browser", "builtIn": false}]
+}`
+
+func TestAdminImportKeyCloakRealm(t *testing.T) {
+	app := createAdminTestApp(t)
+	token := getAdminToken(t, app)
+
+	response := doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, testKeyCloakRealmExport, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	assert.True(t, strings.HasSuffix(response.Header().Get("Location"), "/admin/realms/"+testImportedRealm))
+	var report dto.RealmMigrationReport
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &report))
+	assert.Equal(t, testImportedRealm, report.Realm)
+	assert.Equal(t, 1, report.Clients)
+	assert.Equal(t, 1, report.Users)
+	assert.Equal(t, 2, report.Roles)
+	assert.Equal(t, 1, report.Groups)
+	skipped := []dto.SkippedFeatureRepresentation{
+		{Resource: "realm", Name: testImportedRealm, Feature: "password policy \"hashAlgorithm(pbkdf2-sha256)\""},
+		{Resource: "realm", Name: testImportedRealm, Feature: "identity provider \"github\" (github)"},
+		{Resource: "realm", Name: testImportedRealm, Feature: "authentication flow \"custom browser\""},
+		{Resource: "client", Name: testClient1, Feature: "1 client roles"},
+		{Resource: "group", Name: "/devs/backend", Feature: "subgroup (subgroups and their members are not imported)"},
+		{Resource: "user", Name: testImportedUser, Feature: "realm role \"uma_authorization\" that realm doesn't have"},
+		{Resource: "user", Name: testImportedUser, Feature: "client roles"},
+		{Resource: "user", Name: "service-account-" + testClient1, Feature: "service account user (user is not imported)"},
+		{Resource: "client", Name: testClient1, Feature: "protocol mappers"},
+		{Resource: "client", Name: "account", Feature: "KeyCloak built-in client (client is not imported)"},
+		{Resource: "client", Name: "saml-app", Feature: "saml protocol (client is not imported)"},
+	}
+	assert.Equal(t, skipped, report.Skipped)
+
+	// imported user logs in with password that KeyCloak hashed and has imported realm role and group
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testImportedRealm, testImportedUser, testImportedPassword))
+	users := readAdminResponse[[]dto.UserRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testImportedRealmPath+"/users?username="+testImportedUser, "", token))
+	require.Len(t, users, 1)
+	assert.Equal(t, "c6ff1a3e-4a37-4cb4-9c4c-9b0b7ed6e004", users[0].Id)
+	mappings := readAdminResponse[[]dto.RoleRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testImportedRealmPath+"/users/"+users[0].Id+"/role-mappings/realm", "", token))
+	assert.Equal(t, []string{"developer"}, getRoleRepresentationNames(mappings))
+	groups := readAdminResponse[[]dto.GroupRepresentation](t, doJsonRequest(t, app, http.MethodGet,
+		testImportedRealmPath+"/users/"+users[0].Id+"/groups", "", token))
+	require.Len(t, groups, 1)
+	assert.Equal(t, "devs", groups[0].Name)
+
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, testKeyCloakRealmExport, token),
+		http.StatusConflict, errors.RealmExistsDesc)
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, `{"users":[]}`, token),
+		http.StatusBadRequest, errors.NameRequiredDesc)
+}
+
+func TestAdminExportRealm(t *testing.T) {
+	app := createAdminTestApp(t)
+	token := getAdminToken(t, app)
+	response := doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, testKeyCloakRealmExport, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testImportedRealm, testImportedUser, testImportedPassword))
+
+	export := readAdminResponse[dto.RealmExportRepresentation](t, doJsonRequest(t, app, http.MethodPost, testExportPath, "", token))
+	assert.Equal(t, testImportedRealm, export.Realm)
+	require.NotNil(t, export.PasswordPolicy)
+	assert.Equal(t, "length(8) and digits(1)", *export.PasswordPolicy)
+	require.NotNil(t, export.FailureFactor)
+	assert.Equal(t, 5, *export.FailureFactor)
+	require.NotNil(t, export.Roles)
+	assert.Equal(t, []string{"developer", "offline_access"}, getRoleRepresentationNames(export.Roles.Realm))
+	require.Len(t, export.Groups, 1)
+	assert.Equal(t, []string{"developer"}, export.Groups[0].RealmRoles)
+	require.Len(t, export.Clients, 1)
+	assert.Equal(t, testClient1, export.Clients[0].ClientId)
+	require.NotNil(t, export.Clients[0].Secret)
+	assert.Equal(t, testClient1Secret, *export.Clients[0].Secret)
+	require.Len(t, export.Users, 1)
+	user := export.Users[0]
+	assert.Equal(t, []string{"developer"}, user.RealmRoles)
+	assert.Equal(t, []string{"/devs"}, user.Groups)
+	require.Len(t, user.Credentials, 1)
+	assert.Equal(t, data.PasswordCredentialType, user.Credentials[0].Type)
+	// pbkdf2 hash of KeyCloak was replaced with argon2id hash on login
+	assert.Contains(t, user.Credentials[0].CredentialData, `"algorithm":"argon2"`)
+	assert.Contains(t, user.Credentials[0].CredentialData, `"type":["id"]`)
+
+	// export could be imported back with same password hashes and client secrets
+	exported, err := json.Marshal(export)
+	require.NoError(t, err)
+	response = doJsonRequest(t, app, http.MethodDelete, testImportedRealmPath, "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, string(exported), token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testImportedRealm, testImportedUser, testImportedPassword))
+
+	// users are exported only on request, skipped features are reported in Warning headers
+	export = readAdminResponse[dto.RealmExportRepresentation](t, doJsonRequest(t, app, http.MethodPost,
+		testImportedRealmPath+"/partial-export", "", token))
+	assert.Empty(t, export.Clients)
+	assert.Empty(t, export.Users)
+	assert.Nil(t, export.Roles)
+	response = doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/partial-export?exportUsers=true", "", token)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, []string{`299 - "user petr: password that is not stored as pbkdf2 or argon2id hash"`},
+		response.Header().Values("Warning"))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath+"/missing/partial-export", "", token),
+		http.StatusNotFound, errors.RealmNotFoundDesc)
+}
+
+func TestAdminRealmExportPermissions(t *testing.T) {
+	app := createAdminPermissionsTestApp(t)
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testUsersViewerUser, testAuthUserPassword))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testManagedRealmPath+"/partial-export?exportUsers=true", "", token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.RealmAdminPermission))
+	checkErrorDetails(t, doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, testKeyCloakRealmExport, token),
+		http.StatusForbidden, sf.Format(errors.AdminPermissionRequiredDesc, data.AdminRole))
+}
@@ -0,0 +1,265 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	testAdminRealm       = "master"
+	testManagedRealm     = "managed"
+	testAdminUser        = "root"
+	testAdminPassword    = "R00t_Passw0rd!"
+	testManagedUser      = "petr"
+	testManagedUserId    = "1c6a8c2e-1c55-4d9d-8f38-34a8b7bbf5a1"
+	testManagedClientId  = "7b58f7d1-5a0e-4bdb-9ed4-6b0cb3fcb37e"
+	testAdminRealmsPath  = "/auth/admin/realms"
+	testManagedRealmPath = testAdminRealmsPath + "/" + testManagedRealm
+)
+
+func TestAdminApiRequiresAdministrator(t *testing.T) {
+	app := createAdminTestApp(t)
+	response := doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testAuthUser, testAuthUserPassword))
+	response = doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", token)
+	checkErrorDetails(t, response, http.StatusForbidden, errors.AdminRoleRequiredDesc)
+
+	// managed realm user can't use admin API even with valid token of own realm
+	token = getTokenFromResponse(t, issuePasswordGrantToken(t, app, testManagedRealm, testManagedUser, testAuthUserPassword))
+	response = doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", token)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestAdminRealmsManagement(t *testing.T) {
+	app := createAdminTestApp(t)
+	token := getAdminToken(t, app)
+
+	realms := readAdminResponse[[]dto.RealmRepresentation](t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath, "", token))
+	require.Len(t, realms, 2)
+	assert.Equal(t, testManagedRealm, realms[0].Realm)
+	assert.Equal(t, testAdminRealm, realms[1].Realm)
+
+	response := doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, `{"realm":"created","accessTokenLifespan":120}`, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	assert.True(t, strings.HasSuffix(response.Header().Get("Location"), "/admin/realms/created"))
+	response = doJsonRequest(t, app, http.MethodPost, testAdminRealmsPath, `{"realm":"created"}`, token)
+	checkErrorDetails(t, response, http.StatusConflict, errors.RealmExistsDesc)
+
+	response = doJsonRequest(t, app, http.MethodPut, testAdminRealmsPath+"/created", `{"realm":"created","accessTokenLifespan":60}`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	realm := readAdminResponse[dto.RealmRepresentation](t, doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath+"/created", "", token))
+	require.NotNil(t, realm.AccessTokenLifespan)
+	assert.Equal(t, 60, *realm.AccessTokenLifespan)
+
+	response = doJsonRequest(t, app, http.MethodDelete, testAdminRealmsPath+"/created", "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodGet, testAdminRealmsPath+"/created", "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.RealmNotFoundDesc)
+
+	response = doJsonRequest(t, app, http.MethodDelete, testAdminRealmsPath+"/"+testAdminRealm, "", token)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.AdminRealmDeleteDesc)
+	response = doJsonRequest(t, app, http.MethodPut, testAdminRealmsPath+"/"+testAdminRealm, `{"realm":"renamed"}`, token)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.AdminRealmRenameDesc)
+}
+
+func TestAdminClientsManagement(t *testing.T) {
+	app := createAdminTestApp(t)
+	token := getAdminToken(t, app)
+	clientsPath := testManagedRealmPath + "/clients"
+
+	clients := readAdminResponse[[]dto.ClientRepresentation](t, doJsonRequest(t, app, http.MethodGet, clientsPath+"?clientId="+testClient1, "", token))
+	require.Len(t, clients, 1)
+	assert.Equal(t, testManagedClientId, clients[0].Id)
+
+	response := doJsonRequest(t, app, http.MethodPost, clientsPath, `{"clientId":"admin-created","redirectUris":["https://app.ferrum.test/cb"]}`, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	location := response.Header().Get("Location")
+	clientId := location[strings.LastIndex(location, "/")+1:]
+	response = doJsonRequest(t, app, http.MethodPost, clientsPath, `{"clientId":"admin-created"}`, token)
+	checkErrorDetails(t, response, http.StatusConflict, sf.Format(errors.ClientExistsDesc, "admin-created"))
+
+	secret := readAdminResponse[dto.CredentialRepresentation](t, doJsonRequest(t, app, http.MethodGet, clientsPath+"/"+clientId+"/client-secret", "", token))
+	assert.Equal(t, "secret", secret.Type)
+	assert.NotEmpty(t, secret.Value)
+	regenerated := readAdminResponse[dto.CredentialRepresentation](t, doJsonRequest(t, app, http.MethodPost, clientsPath+"/"+clientId+"/client-secret", "", token))
+	assert.NotEqual(t, secret.Value, regenerated.Value)
+
+	response = doJsonRequest(t, app, http.MethodPut, clientsPath+"/"+clientId, `{"clientId":"admin-created","publicClient":true}`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	client := readAdminResponse[dto.ClientRepresentation](t, doJsonRequest(t, app, http.MethodGet, clientsPath+"/"+clientId, "", token))
+	require.NotNil(t, client.PublicClient)
+	assert.True(t, *client.PublicClient)
+	assert.Equal(t, []string{"https://app.ferrum.test/cb"}, client.RedirectUris)
+
+	response = doJsonRequest(t, app, http.MethodDelete, clientsPath+"/"+clientId, "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodGet, clientsPath+"/"+clientId, "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.ClientNotFoundDesc)
+}
+
+func TestAdminUsersManagement(t *testing.T) {
+	app := createAdminTestApp(t)
+	token := getAdminToken(t, app)
+	usersPath := testManagedRealmPath + "/users"
+
+	body := `{"username":"ivan","email":"ivan@ferrum.test","firstName":"Ivan","enabled":true,
+              "credentials":[{"type":"password","value":"` + testNewPassword + `","temporary":false}]}`
+	response := doJsonRequest(t, app, http.MethodPost, usersPath, body, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	location := response.Header().Get("Location")
+	userId := location[strings.LastIndex(location, "/")+1:]
+	response = doJsonRequest(t, app, http.MethodPost, usersPath, `{"username":"ivan"}`, token)
+	checkErrorDetails(t, response, http.StatusConflict, errors.UserExistsDesc)
+	// created user could log in with password that was set by administrator
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testManagedRealm, "ivan", testNewPassword))
+
+	users := readAdminResponse[[]dto.UserRepresentation](t, doJsonRequest(t, app, http.MethodGet, usersPath, "", token))
+	assert.Len(t, users, 2)
+	users = readAdminResponse[[]dto.UserRepresentation](t, doJsonRequest(t, app, http.MethodGet, usersPath+"?search=iva", "", token))
+	require.Len(t, users, 1)
+	assert.Equal(t, userId, users[0].Id)
+	count := readAdminResponse[int](t, doJsonRequest(t, app, http.MethodGet, usersPath+"/count", "", token))
+	assert.Equal(t, 2, count)
+
+	response = doJsonRequest(t, app, http.MethodPut, usersPath+"/"+userId, `{"lastName":"Ivanov","attributes":{"department":["it"]}}`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	user := readAdminResponse[dto.UserRepresentation](t, doJsonRequest(t, app, http.MethodGet, usersPath+"/"+userId, "", token))
+	assert.Equal(t, "ivan", user.Username)
+	require.NotNil(t, user.LastName)
+	assert.Equal(t, "Ivanov", *user.LastName)
+	assert.Equal(t, []string{"it"}, user.Attributes["department"])
+
+	response = doJsonRequest(t, app, http.MethodPut, usersPath+"/"+userId+"/reset-password", `{"type":"password","value":"An0ther_Passw0rd!","temporary":false}`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	getTokenFromResponse(t, issuePasswordGrantToken(t, app, testManagedRealm, "ivan", "An0ther_Passw0rd!"))
+	credentials := readAdminResponse[[]dto.CredentialRepresentation](t, doJsonRequest(t, app, http.MethodGet, usersPath+"/"+userId+"/credentials", "", token))
+	require.Len(t, credentials, 1)
+	assert.Equal(t, "password", credentials[0].Type)
+	response = doJsonRequest(t, app, http.MethodDelete, usersPath+"/"+userId+"/credentials/password", "", token)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.UnsupportedCredentialDesc)
+
+	response = doJsonRequest(t, app, http.MethodPut, usersPath+"/"+userId+"/send-verify-email", "", token)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = doJsonRequest(t, app, http.MethodDelete, usersPath+"/"+userId, "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodGet, usersPath+"/"+userId, "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.UserNotFoundDesc)
+	response = doJsonRequest(t, app, http.MethodGet, usersPath+"/not-a-uuid", "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.UserNotFoundDesc)
+}
+
+func TestAdminRolesAndGroupsManagement(t *testing.T) {
+	app := createAdminTestApp(t)
+	token := getAdminToken(t, app)
+	rolesPath := testManagedRealmPath + "/roles"
+	groupsPath := testManagedRealmPath + "/groups"
+	userPath := testManagedRealmPath + "/users/" + testManagedUserId
+
+	response := doJsonRequest(t, app, http.MethodPost, rolesPath, `{"name":"reader","description":"reads"}`, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, rolesPath, `{"name":"writer"}`, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPost, rolesPath, `{"name":"reader"}`, token)
+	checkErrorDetails(t, response, http.StatusConflict, sf.Format(errors.RoleExistsDesc, "reader"))
+
+	response = doJsonRequest(t, app, http.MethodPost, groupsPath, `{"name":"editors"}`, token)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	location := response.Header().Get("Location")
+	groupId := location[strings.LastIndex(location, "/")+1:]
+	response = doJsonRequest(t, app, http.MethodPost, groupsPath+"/"+groupId+"/role-mappings/realm", `[{"name":"writer"}]`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+
+	response = doJsonRequest(t, app, http.MethodPost, userPath+"/role-mappings/realm", `[{"name":"reader"}]`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodPut, userPath+"/groups/"+groupId, "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+
+	roles := readAdminResponse[[]dto.RoleRepresentation](t, doJsonRequest(t, app, http.MethodGet, userPath+"/role-mappings/realm", "", token))
+	assert.Equal(t, []string{"reader"}, getRoleRepresentationNames(roles))
+	roles = readAdminResponse[[]dto.RoleRepresentation](t, doJsonRequest(t, app, http.MethodGet, userPath+"/role-mappings/realm/composite", "", token))
+	assert.ElementsMatch(t, []string{"reader", "writer"}, getRoleRepresentationNames(roles))
+	members := readAdminResponse[[]dto.UserRepresentation](t, doJsonRequest(t, app, http.MethodGet, groupsPath+"/"+groupId+"/members", "", token))
+	require.Len(t, members, 1)
+	assert.Equal(t, testManagedUser, members[0].Username)
+
+	// role rename must be applied to users and groups that have this role
+	response = doJsonRequest(t, app, http.MethodPut, rolesPath+"/writer", `{"name":"editor"}`, token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	roles = readAdminResponse[[]dto.RoleRepresentation](t, doJsonRequest(t, app, http.MethodGet, groupsPath+"/"+groupId+"/role-mappings/realm", "", token))
+	assert.Equal(t, []string{"editor"}, getRoleRepresentationNames(roles))
+	response = doJsonRequest(t, app, http.MethodDelete, rolesPath+"/reader", "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	roles = readAdminResponse[[]dto.RoleRepresentation](t, doJsonRequest(t, app, http.MethodGet, userPath+"/role-mappings/realm/composite", "", token))
+	assert.Equal(t, []string{"editor"}, getRoleRepresentationNames(roles))
+	response = doJsonRequest(t, app, http.MethodGet, rolesPath+"/reader", "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.RoleNotFoundDesc)
+
+	response = doJsonRequest(t, app, http.MethodDelete, userPath+"/groups/"+groupId, "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	groups := readAdminResponse[[]dto.GroupRepresentation](t, doJsonRequest(t, app, http.MethodGet, userPath+"/groups", "", token))
+	assert.Empty(t, groups)
+	response = doJsonRequest(t, app, http.MethodDelete, groupsPath+"/"+groupId, "", token)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodGet, groupsPath+"/"+groupId, "", token)
+	checkErrorDetails(t, response, http.StatusNotFound, errors.GroupNotFoundDesc)
+}
+
+// createAdminTestApp creates application with admin realm (root is an administrator) and managed realm, admins are additional
+// admin realm users
+func createAdminTestApp(t *testing.T, admins ...interface{}) *Application {
+	return createTestApp(t, createAdminTestData(admins...))
+}
+
+func createAdminTestData(admins ...interface{}) *data.ServerData {
+	admin := createTestHashingUser(testAdminUser, "3f1e8b54-8a56-4d52-a1bd-3e0d6c9f1b7a", map[string]interface{}{"password": testAdminPassword})
+	admin.(map[string]interface{})["info"].(map[string]interface{})[data.RolesAttribute] = []interface{}{data.AdminRole}
+	notAdmin := createTestHashingUser(testAuthUser, "9d2a4c3b-7e61-4f0a-b5d8-2c1e7a6f4b90", map[string]interface{}{"password": testAuthUserPassword})
+	managedUser := createTestHashingUser(testManagedUser, testManagedUserId, map[string]interface{}{"password": testAuthUserPassword})
+	clients := []data.Client{
+		{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+	}
+	realms := []data.Realm{
+		{Name: testAdminRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+			Clients: clients, Users: append([]interface{}{admin, notAdmin}, admins...)},
+		{Name: testManagedRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+			Clients: []data.Client{
+				{Name: testClient1, ID: uuid.MustParse(testManagedClientId), Type: data.Confidential,
+					Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+			},
+			Users: []interface{}{managedUser}},
+	}
+	return &data.ServerData{Realms: realms}
+}
+
+func getAdminToken(t *testing.T, app *Application) string {
+	return getTokenFromResponse(t, issuePasswordGrantToken(t, app, testAdminRealm, testAdminUser, testAdminPassword))
+}
+
+func readAdminResponse[T any](t *testing.T, response *httptest.ResponseRecorder) T {
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var result T
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	return result
+}
+
+func getRoleRepresentationNames(roles []dto.RoleRepresentation) []string {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -8,6 +10,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 
 	httpSwagger "github.com/swaggo/http-swagger"
 	"github.com/wissance/Ferrum/globals"
@@ -21,6 +24,7 @@ import (
 	"github.com/wissance/Ferrum/logging"
 	"github.com/wissance/Ferrum/managers"
 	"github.com/wissance/Ferrum/services"
+	"github.com/wissance/Ferrum/themes"
 	r "github.com/wissance/gwuu/api/rest"
 	"github.com/wissance/stringFormatter"
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -40,6 +44,11 @@ type Application struct {
 	webApiContext      *rest.WebApiContext
 	logger             *logging.AppLogger
 	httpHandler        *http.Handler
+	// adminWebApiHandler, adminWebApiContext, adminHttpHandler and adminTlsConfig are nil if admin listener is not configured
+	adminWebApiHandler *r.WebApiHandler
+	adminWebApiContext *rest.WebApiContext
+	adminHttpHandler   *http.Handler
+	adminTlsConfig     *tls.Config
 }
 
 // CreateAppWithConfigs creates but not Init new Application as AppRunner
@@ -86,6 +95,13 @@ func (app *Application) Start() (bool, error) {
 			app.logger.Error(stringFormatter.Format("An error occurred during API Service Start"))
 		}
 	}()
+	if app.adminHttpHandler != nil {
+		go func() {
+			if adminErr := app.startAdminWebService(); adminErr != nil {
+				app.logger.Error(stringFormatter.Format("An error occurred during Admin API Service Start"))
+			}
+		}()
+	}
 	return err == nil, err
 }
 
@@ -190,57 +206,140 @@ func (app *Application) initDataProviders() error {
 
 func (app *Application) initRestApi() error {
 	app.webApiHandler = r.NewWebApiHandler(true, r.AnyOrigin)
-	securityService := services.CreateSecurityService(app.dataProvider, app.logger)
+	clientCertificateRoots, err := app.readClientCertificateRoots()
+	if err != nil {
+		return err
+	}
+	securityService := services.CreateSecurityService(app.dataProvider, clientCertificateRoots, app.logger)
 	serverAddress := stringFormatter.Format("{0}:{1}", app.appConfig.ServerCfg.Address, app.appConfig.ServerCfg.Port)
 	app.webApiContext = &rest.WebApiContext{
-		Address: serverAddress, Schema: string(app.appConfig.ServerCfg.Schema),
+		Address: serverAddress, Schema: string(app.appConfig.ServerCfg.Schema), MutualTls: app.isMutualTlsEnabled(),
 		AuthDefs:     app.authenticationDefs,
 		DataProvider: app.dataProvider, Security: &securityService,
 		TokenGenerator: &services.JwtGenerator{SignKey: app.secretKey, Logger: app.logger}, Logger: app.logger,
+		Themes: themes.CreateThemeManager(app.appConfig.Ui.GetThemesDir(), app.logger),
+	}
+	if app.appConfig.Ui != nil {
+		if err := app.appConfig.Ui.Validate(); err != nil {
+			return err
+		}
+	}
+	if app.appConfig.Ciba != nil {
+		if err := app.appConfig.Ciba.Validate(); err != nil {
+			return err
+		}
+		notifier, err := services.CreateAuthenticationDeviceNotifier(app.appConfig.Ciba, app.logger)
+		if err != nil {
+			return err
+		}
+		backChannelAuth := services.CreateBackChannelAuthenticationService(app.appConfig.Ciba, notifier, app.logger)
+		app.webApiContext.BackChannelAuth = &backChannelAuth
+	}
+	if app.appConfig.Mail != nil {
+		if err := app.appConfig.Mail.Validate(); err != nil {
+			return err
+		}
+		sender, err := services.CreateMailSender(app.appConfig.Mail, app.logger)
+		if err != nil {
+			return err
+		}
+		emailActions := services.CreateEmailActionService(sender, app.dataProvider, app.appConfig.Mail.GetLinkBaseUrl(&app.appConfig.ServerCfg),
+			app.logger)
+		app.webApiContext.EmailActions = &emailActions
+	}
+	app.webApiContext.AdminRealm = app.appConfig.ServerCfg.GetAdminRealm()
+	adminService := services.CreateAdminService(app.dataProvider, app.webApiContext.EmailActions, app.webApiContext.AdminRealm, app.logger)
+	app.webApiContext.Admin = &adminService
+	eventService := services.CreateEventService(services.DefaultMaxRealmEvents, app.logger)
+	app.webApiContext.Events = &eventService
+	if app.appConfig.AdminConsole != nil {
+		app.webApiContext.AdminConsoleClientId = app.appConfig.AdminConsole.GetClientId()
 	}
 	router := app.webApiHandler.Router
 	router.StrictSlash(true)
 	app.initKeyCloakSimilarRestApiRoutes(router)
+	// admin and management endpoints are served either by admin listener or by main listener
+	adminWebApiHandler, adminWebApiContext, err := app.initAdminListener()
+	if err != nil {
+		return err
+	}
+	app.initAdminRestApiRoutes(adminWebApiHandler, adminWebApiContext)
+	app.initHealthRoutes(adminWebApiHandler, adminWebApiContext)
+	if app.appConfig.AdminConsole != nil {
+		app.initAdminConsoleRoutes(adminWebApiHandler, adminWebApiContext)
+	}
 	if app.devMode {
-		app.initSwaggerRoutes(router)
+		app.initSwaggerRoutes(adminWebApiHandler.Router, adminWebApiContext.Address)
 	}
 	// Setting up listener for logging
 	appenderIndex := app.logger.GetAppenderIndex(config.RollingFile, app.appConfig.Logging.Appenders)
 	if appenderIndex == -1 {
 		app.logger.Info("The RollingFile appender was not found.")
-		var resultRouter http.Handler = router
-		app.httpHandler = &resultRouter
-		return nil
 	}
-	app.httpHandler = app.createHttpLoggingHandler(appenderIndex, router)
+	httpLogWriter := app.createHttpLogWriter(appenderIndex)
+	app.httpHandler = createHttpHandler(httpLogWriter, router)
+	if app.adminWebApiHandler != nil {
+		app.adminHttpHandler = createHttpHandler(httpLogWriter, app.adminWebApiHandler.Router)
+	}
 	return nil
 }
 
-func (app *Application) initSwaggerRoutes(router *mux.Router) {
+// initAdminListener creates router and context of admin listener if it is configured, otherwise returns main listener
+// router and context. Admin listener context differs from main context by address and schema only (Location headers and
+// DPoP proofs use them)
+func (app *Application) initAdminListener() (*r.WebApiHandler, *rest.WebApiContext, error) {
+	adminCfg := app.appConfig.ServerCfg.AdminListener
+	if adminCfg == nil {
+		return app.webApiHandler, app.webApiContext, nil
+	}
+	if err := adminCfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if adminCfg.Schema == config.HTTPS {
+		tlsConfig, err := createAdminTlsConfig(adminCfg.Security)
+		if err != nil {
+			return nil, nil, err
+		}
+		app.adminTlsConfig = tlsConfig
+	}
+	adminWebApiContext := *app.webApiContext
+	adminWebApiContext.Address = stringFormatter.Format("{0}:{1}", adminCfg.Address, adminCfg.Port)
+	adminWebApiContext.Schema = string(adminCfg.Schema)
+	adminWebApiContext.MutualTls = false
+	app.adminWebApiContext = &adminWebApiContext
+	app.adminWebApiHandler = r.NewWebApiHandler(true, r.AnyOrigin)
+	app.adminWebApiHandler.Router.StrictSlash(true)
+	return app.adminWebApiHandler, app.adminWebApiContext, nil
+}
+
+func (app *Application) initSwaggerRoutes(router *mux.Router, address string) {
 	// Swagger docs router and config
 	swagger.SwaggerInfo.Version = "v0.9"
 	swagger.SwaggerInfo.Title = "Ferrum Authorization Server"
 	swagger.SwaggerInfo.Description = "Ferrum a better Authorization server compatible by API with a KeyCloak"
-	swagger.SwaggerInfo.Host = stringFormatter.Format("{0}:{1}", app.appConfig.ServerCfg.Address, app.appConfig.ServerCfg.Port)
+	swagger.SwaggerInfo.Host = address
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler())
 }
 
 func (app *Application) initAuthServerDefs() {
 	app.authenticationDefs.SupportedGrantTypes = []string{
-		globals.AuthorizationTokenGrantType,
+		globals.AuthorizationCodeGrantType,
 		globals.RefreshTokenGrantType,
 		globals.PasswordGrantType,
+		globals.WebAuthnGrantType,
+	}
+	if app.appConfig.Ciba != nil {
+		app.authenticationDefs.SupportedGrantTypes = append(app.authenticationDefs.SupportedGrantTypes, globals.CibaGrantType)
 	}
 
 	app.authenticationDefs.SupportedResponseTypes = []string{
-		globals.TokenResponseType,
 		globals.CodeResponseType,
-		globals.CodeTokenResponseType,
 	}
 
 	app.authenticationDefs.SupportedResponses = []string{
-		globals.JwtResponse,
+		globals.QueryResponseMode,
+		globals.FragmentResponseMode,
 	}
 
 	app.authenticationDefs.SupportedScopes = []string{
@@ -274,31 +373,326 @@ func (app *Application) initKeyCloakSimilarRestApiRoutes(router *mux.Router) {
 	// 4. OpenId Configuration endpoint
 	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/.well-known/openid-configuration", app.webApiContext.GetOpenIdConfiguration, http.MethodGet)
 	app.webApiHandler.HandleFunc(router, "/realms/{realm}/.well-known/openid-configuration", app.webApiContext.GetOpenIdConfiguration, http.MethodGet)
+	// 5. Pushed Authorization Request endpoint (RFC 9126)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/par/request", app.webApiContext.PushAuthorizationRequest, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/par/request", app.webApiContext.PushAuthorizationRequest, http.MethodPost)
+	// 6. Client-Initiated Backchannel Authentication (CIBA) endpoints
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/ciba/auth", app.webApiContext.BackChannelAuthenticate, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/ciba/auth", app.webApiContext.BackChannelAuthenticate, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/ciba/auth/callback", app.webApiContext.BackChannelAuthenticationCallback, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/ciba/auth/callback", app.webApiContext.BackChannelAuthenticationCallback, http.MethodPost)
+	// 7. Dynamic client registration endpoints (RFC 7591 && RFC 7592)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/clients-registrations/openid-connect", app.webApiContext.RegisterClient, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/clients-registrations/openid-connect", app.webApiContext.RegisterClient, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/clients-registrations/openid-connect/{clientId}", app.webApiContext.GetClientRegistration, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/clients-registrations/openid-connect/{clientId}", app.webApiContext.GetClientRegistration, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/clients-registrations/openid-connect/{clientId}", app.webApiContext.UpdateClientRegistration, http.MethodPut)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/clients-registrations/openid-connect/{clientId}", app.webApiContext.UpdateClientRegistration, http.MethodPut)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/clients-registrations/openid-connect/{clientId}", app.webApiContext.DeleteClientRegistration, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/clients-registrations/openid-connect/{clientId}", app.webApiContext.DeleteClientRegistration, http.MethodDelete)
+	// 8. WebAuthn (passkeys) endpoints, assertion is exchanged for tokens on token endpoint
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/webauthn/register/options", app.webApiContext.StartWebAuthnRegistration, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/webauthn/register/options", app.webApiContext.StartWebAuthnRegistration, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/webauthn/register", app.webApiContext.FinishWebAuthnRegistration, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/webauthn/register", app.webApiContext.FinishWebAuthnRegistration, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/webauthn/login/options", app.webApiContext.StartWebAuthnLogin, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/webauthn/login/options", app.webApiContext.StartWebAuthnLogin, http.MethodPost)
+	// 9. Email-based flows: verify email, reset password (forgot password) and email links
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/email/verify", app.webApiContext.SendVerifyEmail, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/email/verify", app.webApiContext.SendVerifyEmail, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/reset-credentials", app.webApiContext.ResetCredentials, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/reset-credentials", app.webApiContext.ResetCredentials, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/action-token", app.webApiContext.ExecuteActionToken, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/action-token", app.webApiContext.ExecuteActionToken, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/action-token", app.webApiContext.ExecuteActionToken, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/action-token", app.webApiContext.ExecuteActionToken, http.MethodPost)
+	// 10. Required actions that block login, user authenticates with token request parameters
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/required-action", app.webApiContext.ExecuteRequiredAction, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/required-action", app.webApiContext.ExecuteRequiredAction, http.MethodPost)
+	// 11. Browser login: authorization endpoint (login form or silent re-authentication with identity cookie), login, consent and registration forms
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/auth", app.webApiContext.Authorize, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/auth", app.webApiContext.Authorize, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/auth", app.webApiContext.Authorize, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/auth", app.webApiContext.Authorize, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/authenticate", app.webApiContext.AuthenticateLogin, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/authenticate", app.webApiContext.AuthenticateLogin, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/consent", app.webApiContext.SubmitConsent, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/consent", app.webApiContext.SubmitConsent, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/registration", app.webApiContext.ShowRegistration, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/registration", app.webApiContext.ShowRegistration, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/login-actions/registration", app.webApiContext.SubmitRegistration, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/login-actions/registration", app.webApiContext.SubmitRegistration, http.MethodPost)
+	// 12. Login pages theme static resources (css, images, scripts)
+	app.webApiHandler.HandleFunc(router, "/auth/resources/{theme}/{resource:.+}", app.webApiContext.GetThemeResource, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/resources/{theme}/{resource:.+}", app.webApiContext.GetThemeResource, http.MethodGet)
+	// 13. Account self-service (profile, password, OTP, sessions and consents), user authenticates with own access token
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account", app.webApiContext.GetAccount, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account", app.webApiContext.GetAccount, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account", app.webApiContext.UpdateAccount, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account", app.webApiContext.UpdateAccount, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/credentials", app.webApiContext.GetAccountCredentials, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/credentials", app.webApiContext.GetAccountCredentials, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/credentials/password", app.webApiContext.ChangeAccountPassword, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/credentials/password", app.webApiContext.ChangeAccountPassword, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/credentials/otp", app.webApiContext.EnrollAccountOtp, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/credentials/otp", app.webApiContext.EnrollAccountOtp, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/credentials/otp", app.webApiContext.RemoveAccountOtp, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/credentials/otp", app.webApiContext.RemoveAccountOtp, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/sessions", app.webApiContext.GetAccountSessions, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/sessions", app.webApiContext.GetAccountSessions, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/sessions", app.webApiContext.SignOutAccountSessions, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/sessions", app.webApiContext.SignOutAccountSessions, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/sessions/{sessionId}", app.webApiContext.SignOutAccountSession, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/sessions/{sessionId}", app.webApiContext.SignOutAccountSession, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/consents", app.webApiContext.GetAccountConsents, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/consents", app.webApiContext.GetAccountConsents, http.MethodGet)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/consents/{clientId}", app.webApiContext.RevokeAccountConsent, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/consents/{clientId}", app.webApiContext.RevokeAccountConsent, http.MethodDelete)
+	// KeyCloak account API path of consent revocation
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/account/applications/{clientId}/consent", app.webApiContext.RevokeAccountConsent, http.MethodDelete)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/account/applications/{clientId}/consent", app.webApiContext.RevokeAccountConsent, http.MethodDelete)
+	// 14. Self-service registration (realm must allow registration)
+	app.webApiHandler.HandleFunc(router, "/auth/realms/{realm}/protocol/openid-connect/ext/registrations", app.webApiContext.RegisterUser, http.MethodPost)
+	app.webApiHandler.HandleFunc(router, "/realms/{realm}/protocol/openid-connect/ext/registrations", app.webApiContext.RegisterUser, http.MethodPost)
+}
+
+// initAdminRestApiRoutes registers Admin REST API (KeyCloak compatible) routes, administrator authenticates with access token
+// of admin realm user, routes are served by admin listener if it is configured (webApiHandler and webApiContext of admin
+// listener), users/count route must be registered before users/{id}
+func (app *Application) initAdminRestApiRoutes(webApiHandler *r.WebApiHandler, webApiContext *rest.WebApiContext) {
+	router := webApiHandler.Router
+	webApiHandler.HandleFunc(router, "/auth/admin/realms", webApiContext.GetAdminRealms, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms", webApiContext.GetAdminRealms, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms", webApiContext.CreateAdminRealm, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms", webApiContext.CreateAdminRealm, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}", webApiContext.GetAdminRealm, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}", webApiContext.GetAdminRealm, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}", webApiContext.UpdateAdminRealm, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}", webApiContext.UpdateAdminRealm, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}", webApiContext.DeleteAdminRealm, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}", webApiContext.DeleteAdminRealm, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/partial-export", webApiContext.ExportAdminRealm, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/partial-export", webApiContext.ExportAdminRealm, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients", webApiContext.GetAdminClients, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients", webApiContext.GetAdminClients, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients", webApiContext.CreateAdminClient, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients", webApiContext.CreateAdminClient, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients/{id}", webApiContext.GetAdminClient, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients/{id}", webApiContext.GetAdminClient, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients/{id}", webApiContext.UpdateAdminClient, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients/{id}", webApiContext.UpdateAdminClient, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients/{id}", webApiContext.DeleteAdminClient, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients/{id}", webApiContext.DeleteAdminClient, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients/{id}/client-secret", webApiContext.GetAdminClientSecret, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients/{id}/client-secret", webApiContext.GetAdminClientSecret, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/clients/{id}/client-secret", webApiContext.RegenerateAdminClientSecret, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/clients/{id}/client-secret", webApiContext.RegenerateAdminClientSecret, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users", webApiContext.GetAdminUsers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users", webApiContext.GetAdminUsers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users", webApiContext.CreateAdminUser, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users", webApiContext.CreateAdminUser, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/count", webApiContext.CountAdminUsers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/count", webApiContext.CountAdminUsers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}", webApiContext.GetAdminUser, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}", webApiContext.GetAdminUser, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}", webApiContext.UpdateAdminUser, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}", webApiContext.UpdateAdminUser, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}", webApiContext.DeleteAdminUser, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}", webApiContext.DeleteAdminUser, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/reset-password", webApiContext.ResetAdminUserPassword, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/reset-password", webApiContext.ResetAdminUserPassword, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/credentials", webApiContext.GetAdminUserCredentials, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/credentials", webApiContext.GetAdminUserCredentials, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/credentials/{credentialId}", webApiContext.DeleteAdminUserCredential, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/credentials/{credentialId}", webApiContext.DeleteAdminUserCredential, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/execute-actions-email", webApiContext.SendAdminExecuteActionsEmail, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/execute-actions-email", webApiContext.SendAdminExecuteActionsEmail, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/send-verify-email", webApiContext.SendAdminVerifyEmail, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/send-verify-email", webApiContext.SendAdminVerifyEmail, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/groups", webApiContext.GetAdminUserGroups, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/groups", webApiContext.GetAdminUserGroups, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/groups/{groupId}", webApiContext.JoinAdminUserGroup, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/groups/{groupId}", webApiContext.JoinAdminUserGroup, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/groups/{groupId}", webApiContext.LeaveAdminUserGroup, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/groups/{groupId}", webApiContext.LeaveAdminUserGroup, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/role-mappings", webApiContext.GetAdminUserRoleMappings, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/role-mappings", webApiContext.GetAdminUserRoleMappings, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/role-mappings/realm", webApiContext.GetAdminUserRealmRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/role-mappings/realm", webApiContext.GetAdminUserRealmRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/role-mappings/realm", webApiContext.AddAdminUserRealmRoles, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/role-mappings/realm", webApiContext.AddAdminUserRealmRoles, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/role-mappings/realm", webApiContext.RemoveAdminUserRealmRoles, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/role-mappings/realm", webApiContext.RemoveAdminUserRealmRoles, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/role-mappings/realm/composite", webApiContext.GetAdminUserEffectiveRealmRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/role-mappings/realm/composite", webApiContext.GetAdminUserEffectiveRealmRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/sessions", webApiContext.GetAdminUserSessions, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/sessions", webApiContext.GetAdminUserSessions, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/sessions/{sessionId}", webApiContext.DeleteAdminUserSession, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/sessions/{sessionId}", webApiContext.DeleteAdminUserSession, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/users/{id}/logout", webApiContext.LogoutAdminUser, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/users/{id}/logout", webApiContext.LogoutAdminUser, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/roles", webApiContext.GetAdminRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/roles", webApiContext.GetAdminRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/roles", webApiContext.CreateAdminRole, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/roles", webApiContext.CreateAdminRole, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/roles/{roleName}", webApiContext.GetAdminRole, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/roles/{roleName}", webApiContext.GetAdminRole, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/roles/{roleName}", webApiContext.UpdateAdminRole, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/roles/{roleName}", webApiContext.UpdateAdminRole, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/roles/{roleName}", webApiContext.DeleteAdminRole, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/roles/{roleName}", webApiContext.DeleteAdminRole, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/roles/{roleName}/users", webApiContext.GetAdminRoleUsers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/roles/{roleName}/users", webApiContext.GetAdminRoleUsers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups", webApiContext.GetAdminGroups, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups", webApiContext.GetAdminGroups, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups", webApiContext.CreateAdminGroup, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups", webApiContext.CreateAdminGroup, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}", webApiContext.GetAdminGroup, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}", webApiContext.GetAdminGroup, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}", webApiContext.UpdateAdminGroup, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}", webApiContext.UpdateAdminGroup, http.MethodPut)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}", webApiContext.DeleteAdminGroup, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}", webApiContext.DeleteAdminGroup, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}/members", webApiContext.GetAdminGroupMembers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}/members", webApiContext.GetAdminGroupMembers, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}/role-mappings/realm", webApiContext.GetAdminGroupRealmRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}/role-mappings/realm", webApiContext.GetAdminGroupRealmRoles, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}/role-mappings/realm", webApiContext.AddAdminGroupRealmRoles, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}/role-mappings/realm", webApiContext.AddAdminGroupRealmRoles, http.MethodPost)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/groups/{groupId}/role-mappings/realm", webApiContext.RemoveAdminGroupRealmRoles, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/groups/{groupId}/role-mappings/realm", webApiContext.RemoveAdminGroupRealmRoles, http.MethodDelete)
+	webApiHandler.HandleFunc(router, "/auth/admin/realms/{realm}/events", webApiContext.GetAdminEvents, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/realms/{realm}/events", webApiContext.GetAdminEvents, http.MethodGet)
+}
+
+// initAdminConsoleRoutes registers admin web console routes, console logs in with admin realm token endpoint therefore this
+// endpoint (of admin realm only) is also served by admin listener if it is configured, config.json route must be registered
+// before console resources route
+func (app *Application) initAdminConsoleRoutes(webApiHandler *r.WebApiHandler, webApiContext *rest.WebApiContext) {
+	router := webApiHandler.Router
+	webApiHandler.HandleFunc(router, "/auth/admin/console/", webApiContext.GetAdminConsoleResource, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/console/", webApiContext.GetAdminConsoleResource, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/console/config.json", webApiContext.GetAdminConsoleConfig, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/console/config.json", webApiContext.GetAdminConsoleConfig, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/auth/admin/console/{resource:.+}", webApiContext.GetAdminConsoleResource, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/admin/console/{resource:.+}", webApiContext.GetAdminConsoleResource, http.MethodGet)
+	if webApiHandler != app.webApiHandler {
+		adminRealmPath := "/realms/{realm:" + regexp.QuoteMeta(webApiContext.AdminRealm) + "}/protocol/openid-connect/token"
+		webApiHandler.HandleFunc(router, "/auth"+adminRealmPath, webApiContext.IssueNewToken, http.MethodPost)
+		webApiHandler.HandleFunc(router, adminRealmPath, webApiContext.IssueNewToken, http.MethodPost)
+	}
+}
+
+// initHealthRoutes registers liveness and readiness checks (KeyCloak health endpoints paths)
+func (app *Application) initHealthRoutes(webApiHandler *r.WebApiHandler, webApiContext *rest.WebApiContext) {
+	router := webApiHandler.Router
+	webApiHandler.HandleFunc(router, "/health", webApiContext.GetLiveness, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/health/live", webApiContext.GetLiveness, http.MethodGet)
+	webApiHandler.HandleFunc(router, "/health/ready", webApiContext.GetReadiness, http.MethodGet)
 }
 
 func (app *Application) startWebService() error {
+	serverCfg := &app.appConfig.ServerCfg
+	var tlsConfig *tls.Config
+	if serverCfg.Schema == config.HTTPS {
+		tlsConfig = app.createTlsConfig()
+	}
+	return app.startListener("WEB API", serverCfg.Schema, serverCfg.Address, serverCfg.Port, serverCfg.Security, *app.httpHandler, tlsConfig)
+}
+
+// startAdminWebService starts admin listener, it must be called only if admin listener is configured
+func (app *Application) startAdminWebService() error {
+	adminCfg := app.appConfig.ServerCfg.AdminListener
+	return app.startListener("Admin API", adminCfg.Schema, adminCfg.Address, adminCfg.Port, adminCfg.Security, *app.adminHttpHandler,
+		app.adminTlsConfig)
+}
+
+// startListener starts HTTP or HTTPS listener (name is used for logging only), this function blocks until listener stops
+func (app *Application) startListener(name string, schema config.Schema, address string, port int, security *config.SecurityConfig,
+	handler http.Handler, tlsConfig *tls.Config) error {
 	var err error
 	addressTemplate := "{0}:{1}"
-	address := stringFormatter.Format(addressTemplate, app.appConfig.ServerCfg.Address, app.appConfig.ServerCfg.Port)
-	switch app.appConfig.ServerCfg.Schema { //nolint:exhaustive
+	listenerAddress := stringFormatter.Format(addressTemplate, address, port)
+	switch schema { //nolint:exhaustive
 	case config.HTTP:
-		app.logger.Info(stringFormatter.Format("Starting \"HTTP\" WEB API Service on address: \"{0}\"", address))
-		err = http.ListenAndServe(address, *app.httpHandler)
+		app.logger.Info(stringFormatter.Format("Starting \"HTTP\" {0} Service on address: \"{1}\"", name, listenerAddress))
+		err = http.ListenAndServe(listenerAddress, handler)
 		if err != nil {
-			app.logger.Error(stringFormatter.Format("An error occurred during attempt to start \"HTTP\" WEB API Service: {0}", err.Error()))
+			app.logger.Error(stringFormatter.Format("An error occurred during attempt to start \"HTTP\" {0} Service: {1}", name, err.Error()))
 		}
 	case config.HTTPS:
-		app.logger.Info(stringFormatter.Format("Starting \"HTTPS\" REST API Service on address: \"{0}\"", address))
-		cert := app.appConfig.ServerCfg.Security.CertificateFile
-		key := app.appConfig.ServerCfg.Security.KeyFile
-		err = http.ListenAndServeTLS(address, cert, key, *app.httpHandler)
+		app.logger.Info(stringFormatter.Format("Starting \"HTTPS\" {0} Service on address: \"{1}\"", name, listenerAddress))
+		server := &http.Server{Addr: listenerAddress, Handler: handler, TLSConfig: tlsConfig}
+		err = server.ListenAndServeTLS(security.CertificateFile, security.KeyFile)
 		if err != nil {
-			app.logger.Error(stringFormatter.Format("An error occurred during attempt tp start \"HTTPS\" REST API Service: {0}", err.Error()))
+			app.logger.Error(stringFormatter.Format("An error occurred during attempt to start \"HTTPS\" {0} Service: {1}", name, err.Error()))
 		}
 	}
 	return err
 }
 
+// isMutualTlsEnabled checks whether HTTPS listener requests client certificates
+func (app *Application) isMutualTlsEnabled() bool {
+	serverCfg := &app.appConfig.ServerCfg
+	return serverCfg.Schema == config.HTTPS && serverCfg.Security != nil && serverCfg.Security.IsMutualTlsEnabled()
+}
+
+// createTlsConfig creates HTTPS listener config, client certificate is requested but not verified on handshake because
+// self-signed client certificates are allowed, certificates are verified on client authentication
+func (app *Application) createTlsConfig() *tls.Config {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ClientAuth: tls.NoClientCert}
+	if app.isMutualTlsEnabled() {
+		tlsConfig.ClientAuth = tls.RequestClientCert
+		if app.appConfig.ServerCfg.Security.ClientCertificate == config.RequiredClientCertificate {
+			tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		}
+	}
+	return tlsConfig
+}
+
+// readClientCertificateRoots reads CA certificates that issue tls_client_auth client certificates, returns nil if CA file
+// was not set (system pool is used in this case)
+func (app *Application) readClientCertificateRoots() (*x509.CertPool, error) {
+	if !app.isMutualTlsEnabled() || len(app.appConfig.ServerCfg.Security.ClientCaFile) == 0 {
+		return nil, nil
+	}
+	return readCertificatePool(app.appConfig.ServerCfg.Security.ClientCaFile)
+}
+
+// createAdminTlsConfig creates admin HTTPS listener config, unlike main listener client certificates are verified on
+// handshake with CA certificates from security ClientCaFile (system pool is used if it is not set)
+func createAdminTlsConfig(security *config.SecurityConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ClientAuth: tls.NoClientCert}
+	if !security.IsMutualTlsEnabled() {
+		return tlsConfig, nil
+	}
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if security.ClientCertificate == config.RequiredClientCertificate {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if len(security.ClientCaFile) > 0 {
+		roots, err := readCertificatePool(security.ClientCaFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = roots
+	}
+	return tlsConfig, nil
+}
+
+// readCertificatePool reads PEM file with CA certificates
+func readCertificatePool(caFile string) (*x509.CertPool, error) {
+	fileData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred during client CA file \"%s\" reading: %w", caFile, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(fileData) {
+		return nil, fmt.Errorf("client CA file \"%s\" does not contain PEM certificates", caFile)
+	}
+	return roots, nil
+}
+
 func (app *Application) readKey() []byte {
 	absPath, err := filepath.Abs(*app.appConfigFile)
 	if err != nil {
@@ -315,11 +709,14 @@ func (app *Application) readKey() []byte {
 	return fileData
 }
 
-func (app *Application) createHttpLoggingHandler(index int, router *mux.Router) *http.Handler {
-	var resultRouter http.Handler = router
-
+// createHttpLogWriter creates writer of HTTP requests log, returns nil if HTTP logging is disabled or there is no RollingFile
+// appender (index is -1). Main and admin listeners share one writer because they share log file
+func (app *Application) createHttpLogWriter(index int) io.Writer {
+	if index == -1 || !app.appConfig.Logging.LogHTTP {
+		return nil
+	}
 	destination := app.appConfig.Logging.Appenders[index].Destination
-	lumberjackWriter := lumberjack.Logger{
+	lumberjackWriter := &lumberjack.Logger{
 		Filename:   string(destination.File),
 		MaxSize:    destination.MaxSize,
 		MaxAge:     destination.MaxAge,
@@ -327,14 +724,17 @@ func (app *Application) createHttpLoggingHandler(index int, router *mux.Router)
 		LocalTime:  destination.LocalTime,
 		Compress:   false,
 	}
+	if app.appConfig.Logging.ConsoleOutHTTP {
+		return io.MultiWriter(lumberjackWriter, os.Stdout)
+	}
+	return lumberjackWriter
+}
 
-	if app.appConfig.Logging.LogHTTP {
-		if app.appConfig.Logging.ConsoleOutHTTP {
-			writer := io.MultiWriter(&lumberjackWriter, os.Stdout)
-			resultRouter = handlers.LoggingHandler(writer, router)
-		} else {
-			resultRouter = handlers.LoggingHandler(&lumberjackWriter, router)
-		}
+// createHttpHandler creates listener handler, requests are logged if logWriter is not nil
+func createHttpHandler(logWriter io.Writer, router *mux.Router) *http.Handler {
+	var resultRouter http.Handler = router
+	if logWriter != nil {
+		resultRouter = handlers.LoggingHandler(logWriter, router)
 	}
 	return &resultRouter
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/wissance/Ferrum/config"
 	"github.com/wissance/Ferrum/data"
 	"github.com/wissance/Ferrum/dto"
@@ -12,6 +13,7 @@ import (
 	"github.com/wissance/stringFormatter"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"path/filepath"
 	"strings"
@@ -213,3 +215,11 @@ func checkIntrospectToken(t *testing.T, baseUrl string, realm string, token stri
 	assert.Nil(t, err)
 	return result
 }
+
+// checkErrorDetails checks that response has expected status and dto.ErrorDetails with expected description
+func checkErrorDetails(t *testing.T, response *httptest.ResponseRecorder, expectedStatus int, expectedDescription string) {
+	require.Equal(t, expectedStatus, response.Code, response.Body.String())
+	var errorDetails dto.ErrorDetails
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errorDetails))
+	assert.Equal(t, expectedDescription, errorDetails.Description)
+}
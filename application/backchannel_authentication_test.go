@@ -0,0 +1,199 @@
+package application
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const testCibaRealm = "cibarealm"
+const testCibaPollClient = "ciba-poll-client"
+const testCibaPingClient = "ciba-ping-client"
+const testCibaUser = "operator"
+
+func createCibaServerData(pingEndpoint string) *data.ServerData {
+	return &data.ServerData{
+		Realms: []data.Realm{
+			{Name: testCibaRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				Clients: []data.Client{
+					{Name: testCibaPollClient, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets,
+						Value: testClient1Secret}, BackChannelTokenDeliveryMode: data.PollDeliveryMode},
+					{Name: testCibaPingClient, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets,
+						Value: testClient1Secret}, BackChannelTokenDeliveryMode: data.PingDeliveryMode,
+						BackChannelClientNotificationEndpoint: pingEndpoint},
+					{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets,
+						Value: testClient1Secret}},
+				}, Users: []interface{}{
+					map[string]interface{}{"info": map[string]interface{}{"sub": "8be91328-0f85-408f-966a-fd9a04ce94d9",
+						"preferred_username": testCibaUser}, "credentials": map[string]interface{}{"password": "1234567890"}},
+				},
+			},
+		},
+	}
+}
+
+func TestBackChannelAuthenticationPollMode(t *testing.T) {
+	notificationsFile := filepath.Join(t.TempDir(), "notifications.jsonl")
+	appConfig := httpAppConfig
+	appConfig.Ciba = &config.CibaConfig{Notifier: config.FileDeviceNotifier, Destination: notificationsFile, Interval: 1}
+	app := createTestAppWithConfig(t, &appConfig, createCibaServerData(""))
+
+	// 1. approved request
+	authResult := startBackChannelAuthentication(t, app, testCibaPollClient, "", http.StatusOK)
+	assert.Equal(t, 1, authResult.Interval)
+	assert.True(t, authResult.ExpiresIn > 0)
+	checkCibaTokenError(t, app, testCibaPollClient, authResult.AuthReqId, errors.AuthorizationPendingMsg)
+	checkCibaTokenError(t, app, testCibaPollClient, authResult.AuthReqId, errors.SlowDownMsg)
+	notification := readLastDeviceNotification(t, notificationsFile)
+	assert.Equal(t, testCibaUser, notification.LoginHint)
+	assert.Equal(t, "Approve login from call-center", notification.BindingMessage)
+	assert.True(t, strings.HasSuffix(notification.CallbackUri, "/protocol/openid-connect/ext/ciba/auth/callback"))
+	// client can't use auth_req_id as callback token
+	assert.Equal(t, http.StatusUnauthorized, sendDeviceCallback(t, app, authResult.AuthReqId, data.BackChannelAuthSucceed).Code)
+	assert.Equal(t, http.StatusOK, sendDeviceCallback(t, app, notification.CallbackToken, data.BackChannelAuthSucceed).Code)
+	// decision could be passed only once
+	assert.Equal(t, http.StatusUnauthorized, sendDeviceCallback(t, app, notification.CallbackToken, data.BackChannelAuthDenied).Code)
+	// other client can't get tokens
+	checkCibaTokenError(t, app, testCibaPingClient, authResult.AuthReqId, errors.InvalidUserCredentialsMsg)
+	response := pollCibaToken(t, app, testCibaPollClient, authResult.AuthReqId)
+	assert.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	assert.True(t, len(token.AccessToken) > 0)
+	// tokens are issued only once
+	checkCibaTokenError(t, app, testCibaPollClient, authResult.AuthReqId, errors.InvalidUserCredentialsMsg)
+
+	// 2. denied request
+	authResult = startBackChannelAuthentication(t, app, testCibaPollClient, "", http.StatusOK)
+	notification = readLastDeviceNotification(t, notificationsFile)
+	assert.Equal(t, http.StatusOK, sendDeviceCallback(t, app, notification.CallbackToken, data.BackChannelAuthDenied).Code)
+	checkCibaTokenError(t, app, testCibaPollClient, authResult.AuthReqId, errors.AccessDeniedMsg)
+
+	// 3. client without CIBA settings
+	startBackChannelAuthentication(t, app, testClient1, "", http.StatusBadRequest)
+}
+
+func TestBackChannelAuthenticationPingMode(t *testing.T) {
+	pings := make(chan *http.Request, 1)
+	pingBodies := make(chan []byte, 1)
+	clientServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pingBodies <- body
+		pings <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer clientServer.Close()
+	notifications := make(chan dto.AuthenticationDeviceNotification, 1)
+	deviceServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification dto.AuthenticationDeviceNotification
+		_ = json.NewDecoder(r.Body).Decode(&notification)
+		notifications <- notification
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer deviceServer.Close()
+
+	appConfig := httpAppConfig
+	appConfig.Ciba = &config.CibaConfig{Notifier: config.HttpDeviceNotifier, Destination: deviceServer.URL}
+	app := createTestAppWithConfig(t, &appConfig, createCibaServerData(clientServer.URL))
+
+	// ping mode requires client_notification_token
+	startBackChannelAuthentication(t, app, testCibaPingClient, "", http.StatusBadRequest)
+	authResult := startBackChannelAuthentication(t, app, testCibaPingClient, "client-bearer", http.StatusOK)
+	notification := <-notifications
+	assert.Equal(t, testCibaPingClient, notification.ClientId)
+	assert.Equal(t, http.StatusOK, sendDeviceCallback(t, app, notification.CallbackToken, data.BackChannelAuthSucceed).Code)
+	select {
+	case ping := <-pings:
+		assert.Equal(t, "Bearer client-bearer", ping.Header.Get("Authorization"))
+		var pingNotification dto.ClientPingNotification
+		require.NoError(t, json.Unmarshal(<-pingBodies, &pingNotification))
+		assert.Equal(t, authResult.AuthReqId, pingNotification.AuthReqId)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "client wasn't pinged")
+	}
+	assert.Equal(t, http.StatusOK, pollCibaToken(t, app, testCibaPingClient, authResult.AuthReqId).Code)
+}
+
+func TestBackChannelAuthenticationNotConfigured(t *testing.T) {
+	app := createTestApp(t, createCibaServerData(""))
+	startBackChannelAuthentication(t, app, testCibaPollClient, "", http.StatusBadRequest)
+}
+
+func startBackChannelAuthentication(t *testing.T, app *Application, clientId string, notificationToken string,
+	expectedStatus int) dto.BackChannelAuthenticationResult {
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("scope", globals.OpenIdScope+" "+globals.ProfileScope)
+	form.Set("login_hint", testCibaUser)
+	form.Set("binding_message", "Approve login from call-center")
+	if len(notificationToken) > 0 {
+		form.Set("client_notification_token", notificationToken)
+	}
+	response := doFormRequest(t, app, "/realms/"+testCibaRealm+"/protocol/openid-connect/ext/ciba/auth", form, nil)
+	require.Equal(t, expectedStatus, response.Code)
+	var result dto.BackChannelAuthenticationResult
+	if expectedStatus == http.StatusOK {
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	}
+	return result
+}
+
+func pollCibaToken(t *testing.T, app *Application, clientId string, authReqId string) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("grant_type", globals.CibaGrantType)
+	form.Set("auth_req_id", authReqId)
+	return doFormRequest(t, app, "/realms/"+testCibaRealm+"/protocol/openid-connect/token", form, nil)
+}
+
+func checkCibaTokenError(t *testing.T, app *Application, clientId string, authReqId string, expectedError string) {
+	response := pollCibaToken(t, app, clientId, authReqId)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	var errDetails dto.ErrorDetails
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+	assert.Equal(t, expectedError, errDetails.Msg)
+}
+
+func sendDeviceCallback(t *testing.T, app *Application, callbackToken string, status data.BackChannelAuthenticationStatus) *httptest.ResponseRecorder {
+	body, err := json.Marshal(dto.AuthenticationDeviceCallback{Status: string(status)})
+	require.NoError(t, err)
+	request := httptest.NewRequest(http.MethodPost, "/realms/"+testCibaRealm+"/protocol/openid-connect/ext/ciba/auth/callback",
+		strings.NewReader(string(body)))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer "+callbackToken)
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
+
+func readLastDeviceNotification(t *testing.T, fileName string) dto.AuthenticationDeviceNotification {
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	var last string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		last = scanner.Text()
+	}
+	var notification dto.AuthenticationDeviceNotification
+	require.NoError(t, json.Unmarshal([]byte(last), &notification))
+	return notification
+}
@@ -0,0 +1,279 @@
+package application
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const (
+	testLoginRealm        = "loginrealm"
+	testLoginPublicClient = "spa"
+	testLoginRedirectUri  = "https://spa.example.com/callback"
+	testCodeVerifier      = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+var requestUriRegex = regexp.MustCompile(`name="request_uri" value="([^"]+)"`)
+
+func createLoginTestApp(t *testing.T) *Application {
+	user := createTestHashingUser(testAuthUser, "a9c1d2b6-0f0e-4b7d-9d2c-41f1f3c7e8a1", map[string]interface{}{"password": testAuthUserPassword})
+	realm := data.Realm{Name: testLoginRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		Clients: []data.Client{
+			{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret},
+				RedirectUris: []string{testRedirectUri}},
+			{Name: testLoginPublicClient, Type: data.Public, RedirectUris: []string{testLoginRedirectUri}},
+		},
+		Users: []interface{}{user},
+	}
+	return createTestApp(t, &data.ServerData{Realms: []data.Realm{realm}})
+}
+
+func TestBrowserLoginWithCode(t *testing.T) {
+	app := createLoginTestApp(t)
+	hash := sha256.Sum256([]byte(testCodeVerifier))
+	params := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType}, "state": {"st1"},
+		"redirect_uri": {testLoginRedirectUri}, "scope": {globals.OpenIdScope}, "login_hint": {testAuthUser},
+		"code_challenge": {base64.RawURLEncoding.EncodeToString(hash[:])}, "code_challenge_method": {data.S256CodeChallengeMethod}}
+	response := doAuthorizationRequest(app, params, nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, response.Body.String(), `value="`+testAuthUser+`"`)
+
+	// wrong password shows form again with new form reference
+	response = submitLoginForm(t, app, response, testAuthUser, "wrong")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	assert.Contains(t, response.Body.String(), errors.InvalidUserCredentialsDesc)
+	// form reference submitted with other client_id is rejected but remains usable
+	match := requestUriRegex.FindStringSubmatch(response.Body.String())
+	require.Len(t, match, 2)
+	form := url.Values{"client_id": {testClient1}, "request_uri": {match[1]}, "username": {testAuthUser}, "password": {testAuthUserPassword}}
+	rejected := doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/login-actions/authenticate", form, nil)
+	assert.Equal(t, http.StatusBadRequest, rejected.Code)
+	assert.Contains(t, rejected.Body.String(), errors.LoginPageExpiredDesc)
+	response = submitLoginForm(t, app, response, testAuthUser, testAuthUserPassword)
+	require.Equal(t, http.StatusFound, response.Code, response.Body.String())
+	identity := getIdentityCookie(t, response)
+	assert.True(t, identity.HttpOnly)
+	assert.Equal(t, "/auth/realms/"+testLoginRealm+"/", identity.Path)
+	location := getRedirectParams(t, response, testLoginRedirectUri)
+	assert.Equal(t, "st1", location.Get("state"))
+	code := location.Get("code")
+	require.NotEmpty(t, code)
+
+	// PKCE verifier is required, code is a one-time value
+	response = exchangeCode(t, app, testLoginPublicClient, code, "wrong-verifier")
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.CodeVerifierMismatchDesc)
+	response = doAuthorizationRequest(app, params, identity)
+	code = getRedirectParams(t, response, testLoginRedirectUri).Get("code")
+	response = exchangeCode(t, app, testLoginPublicClient, code, testCodeVerifier)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	assert.NotEmpty(t, token.AccessToken)
+	assert.Equal(t, location.Get("session_state"), token.Session)
+	response = exchangeCode(t, app, testLoginPublicClient, code, testCodeVerifier)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidAuthorizationCodeDesc)
+}
+
+func TestSilentReauthentication(t *testing.T) {
+	app := createLoginTestApp(t)
+	spaParams := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType},
+		"redirect_uri": {testLoginRedirectUri}, "state": {"st1"}}
+	// without browser login prompt=none returns login_required to client
+	params := copyValues(spaParams, "prompt", globals.NonePrompt)
+	response := doAuthorizationRequest(app, params, nil)
+	assert.Equal(t, errors.LoginRequiredMsg, getRedirectParams(t, response, testLoginRedirectUri).Get("error"))
+	response = submitLoginForm(t, app, doAuthorizationRequest(app, spaParams, nil), testAuthUser, testAuthUserPassword)
+	identity := getIdentityCookie(t, response)
+
+	// other client of realm gets code without login form
+	clientParams := url.Values{"client_id": {testClient1}, "response_type": {globals.CodeResponseType}, "redirect_uri": {testRedirectUri},
+		"prompt": {globals.NonePrompt}, "max_age": {"3600"}, "login_hint": {testAuthUser}}
+	response = doAuthorizationRequest(app, clientParams, identity)
+	code := getRedirectParams(t, response, testRedirectUri).Get("code")
+	form := url.Values{"grant_type": {globals.AuthorizationCodeGrantType}, "client_id": {testClient1}, "client_secret": {testClient1Secret},
+		"code": {code}, "redirect_uri": {testRedirectUri}}
+	response = doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/protocol/openid-connect/token", form, nil)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	// max_age, login_hint of other user and prompt=login require login form
+	response = doAuthorizationRequest(app, copyValues(clientParams, "max_age", "0"), identity)
+	assert.Equal(t, errors.LoginRequiredMsg, getRedirectParams(t, response, testRedirectUri).Get("error"))
+	response = doAuthorizationRequest(app, copyValues(clientParams, "login_hint", "other"), identity)
+	assert.Equal(t, errors.LoginRequiredMsg, getRedirectParams(t, response, testRedirectUri).Get("error"))
+	response = doAuthorizationRequest(app, copyValues(spaParams, "prompt", globals.LoginPrompt), identity)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `name="password"`)
+	response = doAuthorizationRequest(app, copyValues(clientParams, "prompt", "none login"), identity)
+	assert.Equal(t, errors.InvalidPromptDesc, getRedirectParams(t, response, testRedirectUri).Get("error_description"))
+
+	// identity cookie of other value is ignored
+	response = doAuthorizationRequest(app, clientParams, &http.Cookie{Name: globals.IdentityCookie, Value: "forged"})
+	assert.Equal(t, errors.LoginRequiredMsg, getRedirectParams(t, response, testRedirectUri).Get("error"))
+}
+
+func TestBrowserLoginsOfDifferentBrowsers(t *testing.T) {
+	app := createLoginTestApp(t)
+	spaParams := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType},
+		"redirect_uri": {testLoginRedirectUri}, "state": {"st1"}}
+	response := submitLoginForm(t, app, doAuthorizationRequest(app, spaParams, nil), testAuthUser, testAuthUserPassword)
+	firstIdentity := getIdentityCookie(t, response)
+	response = submitLoginForm(t, app, doAuthorizationRequest(app, spaParams, nil), testAuthUser, testAuthUserPassword)
+	secondIdentity := getIdentityCookie(t, response)
+	assert.NotEqual(t, firstIdentity.Value, secondIdentity.Value)
+
+	// login from other browser doesn't end SSO in the first one, both browsers share one user session
+	params := copyValues(spaParams, "prompt", globals.NonePrompt)
+	firstState := getRedirectParams(t, doAuthorizationRequest(app, params, firstIdentity), testLoginRedirectUri)
+	assert.NotEmpty(t, firstState.Get("code"))
+	secondState := getRedirectParams(t, doAuthorizationRequest(app, params, secondIdentity), testLoginRedirectUri)
+	assert.NotEmpty(t, secondState.Get("code"))
+	assert.Equal(t, firstState.Get("session_state"), secondState.Get("session_state"))
+}
+
+func TestBrowserLoginsInParallel(t *testing.T) {
+	app := createLoginTestApp(t)
+	spaParams := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType},
+		"redirect_uri": {testLoginRedirectUri}}
+	identities := make([]*http.Cookie, 10)
+	var wg sync.WaitGroup
+	for i := range identities {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			response := submitLoginForm(t, app, doAuthorizationRequest(app, spaParams, nil), testAuthUser, testAuthUserPassword)
+			identities[index] = getIdentityCookie(t, response)
+			issuePasswordGrantToken(t, app, testLoginRealm, testAuthUser, testAuthUserPassword)
+		}(i)
+	}
+	wg.Wait()
+	params := copyValues(spaParams, "prompt", globals.NonePrompt)
+	for _, identity := range identities {
+		assert.NotEmpty(t, getRedirectParams(t, doAuthorizationRequest(app, params, identity), testLoginRedirectUri).Get("code"))
+	}
+}
+
+func TestInvalidAuthorizationRequest(t *testing.T) {
+	app := createLoginTestApp(t)
+	// unregistered redirect_uri is never used for redirect
+	params := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType},
+		"redirect_uri": {"https://evil.example.com/callback"}}
+	response := doAuthorizationRequest(app, params, nil)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), errors.InvalidRedirectUriDesc)
+	params = url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.TokenResponseType},
+		"redirect_uri": {testLoginRedirectUri}, "state": {"st2"}}
+	location := getRedirectParams(t, doAuthorizationRequest(app, params, nil), testLoginRedirectUri)
+	assert.Equal(t, errors.UnsupportedResponseTypeMsg, location.Get("error"))
+	assert.Equal(t, "st2", location.Get("state"))
+	response = doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/login-actions/authenticate",
+		url.Values{"client_id": {testLoginPublicClient}, "request_uri": {"unknown"}, "username": {testAuthUser},
+			"password": {testAuthUserPassword}}, nil)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), errors.LoginPageExpiredDesc)
+}
+
+func doAuthorizationRequest(app *Application, params url.Values, identity *http.Cookie) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/auth/realms/"+testLoginRealm+"/protocol/openid-connect/auth?"+params.Encode(), nil)
+	if identity != nil {
+		request.AddCookie(identity)
+	}
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
+
+// submitLoginForm posts credentials to login form that page contains
+func submitLoginForm(t *testing.T, app *Application, page *httptest.ResponseRecorder, userName string, password string) *httptest.ResponseRecorder {
+	match := requestUriRegex.FindStringSubmatch(page.Body.String())
+	require.Len(t, match, 2, page.Body.String())
+	clientId := regexp.MustCompile(`name="client_id" value="([^"]+)"`).FindStringSubmatch(page.Body.String())[1]
+	form := url.Values{"client_id": {clientId}, "request_uri": {match[1]}, "username": {userName}, "password": {password}}
+	return doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/login-actions/authenticate", form, nil)
+}
+
+func exchangeCode(t *testing.T, app *Application, clientId string, code string, codeVerifier string) *httptest.ResponseRecorder {
+	form := url.Values{"grant_type": {globals.AuthorizationCodeGrantType}, "client_id": {clientId}, "code": {code},
+		"redirect_uri": {testLoginRedirectUri}, "code_verifier": {codeVerifier}}
+	return doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/protocol/openid-connect/token", form, nil)
+}
+
+func getIdentityCookie(t *testing.T, response *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == globals.IdentityCookie {
+			return cookie
+		}
+	}
+	require.Fail(t, "identity cookie was not set")
+	return nil
+}
+
+// getRedirectParams checks that response redirects to redirectUri and returns redirect query parameters
+func getRedirectParams(t *testing.T, response *httptest.ResponseRecorder, redirectUri string) url.Values {
+	require.Equal(t, http.StatusFound, response.Code, response.Body.String())
+	location, err := url.Parse(response.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(location.String(), redirectUri+"?"))
+	return location.Query()
+}
+
+func copyValues(values url.Values, key string, value string) url.Values {
+	result := url.Values{}
+	for k, v := range values {
+		result[k] = v
+	}
+	result.Set(key, value)
+	return result
+}
+
+func TestLoginTheme(t *testing.T) {
+	themesDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(themesDir, "brand", "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(themesDir, "brand", "templates", "login.html"),
+		[]byte(`<html lang="{{.Locale}}"><link href="{{.ResourcesUrl}}/css/login.css">{{.Msg "signIn"}} {{.Page.RequestUri}}</html>`), 0o600))
+	appConfig := httpAppConfig
+	appConfig.Ui = &config.UiConfig{ThemesDir: themesDir}
+	realm := data.Realm{Name: testLoginRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		LoginTheme: "brand", Clients: []data.Client{{Name: testLoginPublicClient, Type: data.Public, RedirectUris: []string{testLoginRedirectUri}}},
+		Users: []interface{}{}}
+	app := createTestAppWithConfig(t, &appConfig, &data.ServerData{Realms: []data.Realm{realm}})
+
+	request := httptest.NewRequest(http.MethodGet, "/auth/realms/"+testLoginRealm+"/protocol/openid-connect/auth?"+url.Values{
+		"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType}, "redirect_uri": {testLoginRedirectUri}}.Encode(), nil)
+	request.Header.Set("Accept-Language", "ru-RU,ru;q=0.9,en;q=0.8")
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "ru", response.Header().Get("Content-Language"))
+	assert.True(t, strings.HasPrefix(response.Body.String(), `<html lang="ru"><link href="/auth/resources/brand/css/login.css">Войти urn:`))
+
+	// theme without own resources uses default theme resources
+	response = doResourceRequest(app, "/auth/resources/brand/css/login.css")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Header().Get("Content-Type"), "text/css")
+	assert.Equal(t, http.StatusNotFound, doResourceRequest(app, "/resources/brand/css").Code)
+	assert.Equal(t, http.StatusNotFound, doResourceRequest(app, "/resources/brand/templates%2Flogin.html").Code)
+}
+
+func doResourceRequest(app *Application, path string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+	return response
+}
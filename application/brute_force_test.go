@@ -0,0 +1,145 @@
+package application
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+)
+
+const testBruteForceRealm = "bruteforcerealm"
+
+func TestBruteForceUserLockout(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		protection           data.BruteForceProtection
+		expectedLockoutWaits []time.Duration
+		expectedPermanent    bool
+	}{
+		{name: "incremental_temporary_lockout", protection: data.BruteForceProtection{MaxLoginFailures: 3, WaitIncrement: 60, MaxWait: 150},
+			expectedLockoutWaits: []time.Duration{60 * time.Second, 120 * time.Second, 150 * time.Second}},
+		{name: "permanent_lockout_after_temporary", protection: data.BruteForceProtection{MaxLoginFailures: 3, WaitIncrement: 60,
+			PermanentLockout: true, MaxTemporaryLockouts: 1}, expectedLockoutWaits: []time.Duration{60 * time.Second}, expectedPermanent: true},
+		{name: "immediate_permanent_lockout", protection: data.BruteForceProtection{MaxLoginFailures: 3, PermanentLockout: true},
+			expectedPermanent: true},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			app := createTestApp(t, createBruteForceServerData(tc.protection))
+			lockUser := func() *data.LoginFailures {
+				for i := 0; i < tc.protection.MaxLoginFailures; i++ {
+					response := issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, "wrong_password")
+					assert.Equal(t, http.StatusUnauthorized, response.Code)
+				}
+				// valid password is not accepted while user is locked
+				response := issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, testAuthUserPassword)
+				assert.Equal(t, http.StatusUnauthorized, response.Code)
+				failures, err := (*app.dataProvider).GetLoginFailures(testBruteForceRealm, data.UserLoginFailuresKey(testAuthUser))
+				require.NoError(t, err)
+				return failures
+			}
+
+			for i, expectedWait := range tc.expectedLockoutWaits {
+				failures := lockUser()
+				assert.Equal(t, i+1, failures.Lockouts)
+				assert.False(t, failures.Permanent)
+				assert.WithinDuration(t, time.Now().Add(expectedWait), failures.LockedUntil, 5*time.Second)
+				// lockout is over
+				failures.LockedUntil = time.Now().Add(-time.Second)
+				require.NoError(t, (*app.dataProvider).SetLoginFailures(testBruteForceRealm, *failures))
+			}
+			if !tc.expectedPermanent {
+				response := issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, testAuthUserPassword)
+				assert.Equal(t, http.StatusOK, response.Code)
+				// successful login resets counter
+				_, err := (*app.dataProvider).GetLoginFailures(testBruteForceRealm, data.UserLoginFailuresKey(testAuthUser))
+				assert.Error(t, err)
+				return
+			}
+			failures := lockUser()
+			assert.True(t, failures.Permanent)
+			assert.True(t, failures.Expires.IsZero())
+			// admin unlocks user
+			require.NoError(t, (*app.dataProvider).DeleteLoginFailures(testBruteForceRealm, data.UserLoginFailuresKey(testAuthUser)))
+			response := issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, testAuthUserPassword)
+			assert.Equal(t, http.StatusOK, response.Code)
+		})
+	}
+}
+
+func TestBruteForceIpLockout(t *testing.T) {
+	app := createTestApp(t, createBruteForceServerData(data.BruteForceProtection{MaxLoginFailures: 10, MaxIpLoginFailures: 3}))
+	// attacker tries different users from the same address (httptest requests are sent from 192.0.2.1)
+	for _, userName := range []string{"user1", "user2", testAuthUser} {
+		response := issuePasswordGrantToken(t, app, testBruteForceRealm, userName, "wrong_password")
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+	}
+	response := issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, testAuthUserPassword)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	failures, err := (*app.dataProvider).GetLoginFailures(testBruteForceRealm, data.IpLoginFailuresKey("192.0.2.1"))
+	require.NoError(t, err)
+	assert.True(t, failures.IsLocked(time.Now()))
+	assert.False(t, failures.Permanent)
+	// user itself is not locked
+	userFailures, err := (*app.dataProvider).GetLoginFailures(testBruteForceRealm, data.UserLoginFailuresKey(testAuthUser))
+	require.NoError(t, err)
+	assert.False(t, userFailures.IsLocked(time.Now()))
+}
+
+func TestBruteForceParallelGuessesAreLimited(t *testing.T) {
+	app := createTestApp(t, createBruteForceServerData(data.BruteForceProtection{MaxLoginFailures: 3, WaitIncrement: 60}))
+	// every attempt is counted before password check, therefore parallel requests can't check more than 3 passwords
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			response := issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, "wrong_password")
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+		}()
+	}
+	wg.Wait()
+	failures, err := (*app.dataProvider).GetLoginFailures(testBruteForceRealm, data.UserLoginFailuresKey(testAuthUser))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures.Lockouts)
+	assert.Equal(t, 0, failures.Failures)
+	assert.True(t, failures.IsLocked(time.Now()))
+}
+
+func TestBruteForceSuccessfulLoginIsNotCounted(t *testing.T) {
+	app := createTestApp(t, createBruteForceServerData(data.BruteForceProtection{MaxLoginFailures: 10, MaxIpLoginFailures: 3}))
+	response := issuePasswordGrantToken(t, app, testBruteForceRealm, "user1", "wrong_password")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	for i := 0; i < 3; i++ {
+		response = issuePasswordGrantToken(t, app, testBruteForceRealm, testAuthUser, testAuthUserPassword)
+		assert.Equal(t, http.StatusOK, response.Code)
+	}
+	response = issuePasswordGrantToken(t, app, testBruteForceRealm, "user2", "wrong_password")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	failures, err := (*app.dataProvider).GetLoginFailures(testBruteForceRealm, data.IpLoginFailuresKey("192.0.2.1"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, failures.Failures)
+	assert.False(t, failures.IsLocked(time.Now()))
+}
+
+func createBruteForceServerData(protection data.BruteForceProtection) *data.ServerData {
+	return &data.ServerData{
+		Realms: []data.Realm{
+			{Name: testBruteForceRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				BruteForceProtection: &protection,
+				Clients: []data.Client{
+					{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+				},
+				Users: []interface{}{
+					createTestHashingUser(testAuthUser, "667ff6a7-3f6b-449b-a217-6fc5d9ac0723",
+						map[string]interface{}{"password": testAuthUserPassword}),
+				},
+			},
+		},
+	}
+}
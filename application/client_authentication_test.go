@@ -0,0 +1,179 @@
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const testAuthRealm = "authrealm"
+const testSecretJwtClient = "secret-jwt-client"
+const testSecretJwtClientSecret = "secret-jwt-client-secret-value-0123456789"
+const testRsaKeyJwtClient = "rsa-key-jwt-client"
+const testEcKeyJwtClient = "ec-key-jwt-client"
+const testAuthUser = "vano"
+const testAuthUserPassword = "1234567890"
+
+func createClientAuthServerData(rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) *data.ServerData {
+	return &data.ServerData{
+		Realms: []data.Realm{
+			{Name: testAuthRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				Clients: []data.Client{
+					{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+					{Name: testSecretJwtClient, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientSecretJwt,
+						Value: testSecretJwtClientSecret}},
+					{Name: testRsaKeyJwtClient, Type: data.Confidential, Auth: data.Authentication{Type: data.PrivateKeyJwt,
+						Jwks: &data.JsonWebKeySet{Keys: []data.JsonWebKey{createRsaJwk("rsa1", &rsaKey.PublicKey)}}}},
+					{Name: testEcKeyJwtClient, Type: data.Confidential, Auth: data.Authentication{Type: data.PrivateKeyJwt,
+						Jwks: &data.JsonWebKeySet{Keys: []data.JsonWebKey{createEcJwk("ec1", &ecKey.PublicKey)}}}},
+				},
+				Users: []interface{}{
+					map[string]interface{}{"info": map[string]interface{}{"sub": "667ff6a7-3f6b-449b-a217-6fc5d9ac0723",
+						"preferred_username": testAuthUser}, "credentials": map[string]interface{}{"password": testAuthUserPassword}},
+				},
+			},
+		},
+	}
+}
+
+func TestClientAuthenticationMethods(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	app := createTestApp(t, createClientAuthServerData(rsaKey, ecKey))
+	tokenPath := "/realms/" + testAuthRealm + "/protocol/openid-connect/token"
+	// token endpoint is one of audiences that client assertion could be issued for
+	tokenAudience := sf.Format("{0}://{1}:{2}/auth/realms/{3}/protocol/openid-connect/token", httpAppConfig.ServerCfg.Schema,
+		httpAppConfig.ServerCfg.Address, httpAppConfig.ServerCfg.Port, testAuthRealm)
+	replayedAssertion := createClientAssertion(t, jwt.SigningMethodHS256, []byte(testSecretJwtClientSecret), "", testSecretJwtClient, tokenAudience)
+
+	testCases := []struct {
+		name           string
+		form           map[string]string
+		basicAuth      string
+		expectedStatus int
+		expectedDesc   string
+	}{
+		{name: "client_secret_post", form: map[string]string{"client_id": testClient1, "client_secret": testClient1Secret},
+			expectedStatus: http.StatusOK},
+		{name: "client_secret_basic", basicAuth: url.QueryEscape(testClient1) + ":" + url.QueryEscape(testClient1Secret),
+			expectedStatus: http.StatusOK},
+		{name: "client_secret_basic_wrong_secret", basicAuth: testClient1 + ":wrongSecret", expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.InvalidClientCredentialDesc},
+		{name: "basic_and_post_together", basicAuth: testClient1 + ":" + testClient1Secret,
+			form: map[string]string{"client_secret": testClient1Secret}, expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.MultipleClientAuthMethodsDesc},
+		{name: "client_secret_jwt", form: map[string]string{"client_assertion": replayedAssertion}, expectedStatus: http.StatusOK},
+		{name: "client_secret_jwt_replayed", form: map[string]string{"client_assertion": replayedAssertion},
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.ClientAssertionReplayedDesc},
+		{name: "client_secret_jwt_wrong_secret", form: map[string]string{"client_assertion": createClientAssertion(t,
+			jwt.SigningMethodHS256, []byte("wrongSecret"), "", testSecretJwtClient, tokenAudience)},
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientAssertionDesc},
+		{name: "client_secret_jwt_wrong_audience", form: map[string]string{"client_assertion": createClientAssertion(t,
+			jwt.SigningMethodHS256, []byte(testSecretJwtClientSecret), "", testSecretJwtClient, "https://other.example.com")},
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientAssertionDesc},
+		{name: "private_key_jwt_rsa", form: map[string]string{"client_id": testRsaKeyJwtClient, "client_assertion": createClientAssertion(t,
+			jwt.SigningMethodRS256, rsaKey, "rsa1", testRsaKeyJwtClient, tokenAudience)}, expectedStatus: http.StatusOK},
+		{name: "private_key_jwt_ec", form: map[string]string{"client_assertion": createClientAssertion(t,
+			jwt.SigningMethodES256, ecKey, "ec1", testEcKeyJwtClient, tokenAudience)}, expectedStatus: http.StatusOK},
+		{name: "private_key_jwt_hmac_with_public_key", form: map[string]string{"client_assertion": createClientAssertion(t,
+			jwt.SigningMethodHS256, []byte("any"), "rsa1", testRsaKeyJwtClient, tokenAudience)},
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientAssertionDesc},
+		{name: "private_key_jwt_client_with_secret", form: map[string]string{"client_id": testRsaKeyJwtClient, "client_secret": "any"},
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientCredentialDesc},
+		{name: "secret_client_with_assertion", form: map[string]string{"client_assertion": createClientAssertion(t,
+			jwt.SigningMethodHS256, []byte(testClient1Secret), "", testClient1, tokenAudience)},
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.ClientAuthMethodNotAllowedDesc},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("grant_type", globals.PasswordGrantType)
+			form.Set("scope", globals.OpenIdScope)
+			form.Set("username", testAuthUser)
+			form.Set("password", testAuthUserPassword)
+			for k, v := range tc.form {
+				form.Set(k, v)
+			}
+			if _, ok := tc.form["client_assertion"]; ok {
+				form.Set("client_assertion_type", globals.JwtBearerClientAssertionType)
+			}
+			headers := map[string]string{}
+			if len(tc.basicAuth) > 0 {
+				headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(tc.basicAuth))
+			}
+			response := doFormRequest(t, app, tokenPath, form, headers)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			if tc.expectedStatus != http.StatusOK {
+				var errDetails dto.ErrorDetails
+				require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+				assert.Equal(t, tc.expectedDesc, errDetails.Description)
+				return
+			}
+			var token dto.Token
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+			assert.NotEmpty(t, token.AccessToken)
+		})
+	}
+}
+
+func TestOpenIdConfigurationContainsClientAuthMethods(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	app := createTestApp(t, createClientAuthServerData(rsaKey, ecKey))
+	request := httptest.NewRequest(http.MethodGet, "/realms/"+testAuthRealm+"/.well-known/openid-configuration", nil)
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	var openIdConfig dto.OpenIdConfiguration
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &openIdConfig))
+	assert.ElementsMatch(t, []string{globals.ClientSecretBasicAuthMethod, globals.ClientSecretPostAuthMethod,
+		globals.ClientSecretJwtAuthMethod, globals.PrivateKeyJwtAuthMethod}, openIdConfig.TokenEndpointAuthMethodsSupported)
+	assert.Contains(t, openIdConfig.TokenEndpointAuthSigningAlgValuesSupported, "RS256")
+}
+
+func createClientAssertion(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, clientId string, audience string) string {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{Issuer: clientId, Subject: clientId, Audience: jwt.ClaimStrings{audience}, ID: uuid.New().String(),
+		IssuedAt: jwt.NewNumericDate(now), ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute))}
+	token := jwt.NewWithClaims(method, claims)
+	if len(kid) > 0 {
+		token.Header["kid"] = kid
+	}
+	assertion, err := token.SignedString(key)
+	require.NoError(t, err)
+	return assertion
+}
+
+func createRsaJwk(kid string, key *rsa.PublicKey) data.JsonWebKey {
+	return data.JsonWebKey{Kty: "RSA", Kid: kid, N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func createEcJwk(kid string, key *ecdsa.PublicKey) data.JsonWebKey {
+	return data.JsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256", X: base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y: base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32)))}
+}
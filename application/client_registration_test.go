@@ -0,0 +1,234 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/utils/hashing"
+)
+
+const testRegistrationRealm = "registrationrealm"
+const testInitialAccessToken = "initial-access-token-for-single-client"
+const testExpiredInitialAccessToken = "expired-initial-access-token"
+
+func createRegistrationServerData() *data.ServerData {
+	return &data.ServerData{
+		Realms: []data.Realm{
+			{Name: testRegistrationRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				Clients: []data.Client{}, Users: []interface{}{},
+				InitialAccessTokens: []data.InitialAccessToken{
+					{ID: uuid.New(), TokenHash: hashing.HashToken(testInitialAccessToken), Created: time.Now(), Count: 1},
+					{ID: uuid.New(), TokenHash: hashing.HashToken(testExpiredInitialAccessToken), Created: time.Now().Add(-time.Hour),
+						Expires: time.Now().Add(-time.Minute)},
+				},
+			},
+		},
+	}
+}
+
+func TestRegisterClientFails(t *testing.T) {
+	app := createTestApp(t, createRegistrationServerData())
+	testCases := []struct {
+		name           string
+		token          string
+		metadata       string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "no_initial_access_token", metadata: `{"client_name": "app"}`, expectedStatus: http.StatusUnauthorized,
+			expectedError: errors.InvalidTokenMsg},
+		{name: "expired_initial_access_token", token: testExpiredInitialAccessToken, metadata: `{"client_name": "app"}`,
+			expectedStatus: http.StatusUnauthorized, expectedError: errors.InvalidTokenMsg},
+		{name: "relative_redirect_uri", token: testInitialAccessToken, metadata: `{"redirect_uris": ["/callback"]}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidRedirectUriMsg},
+		{name: "unsupported_auth_method", token: testInitialAccessToken, metadata: `{"token_endpoint_auth_method": "unknown_method"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "private_key_jwt_without_jwks", token: testInitialAccessToken, metadata: `{"token_endpoint_auth_method": "private_key_jwt"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "ping_without_endpoint", token: testInitialAccessToken, metadata: `{"backchannel_token_delivery_mode": "ping"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "javascript_redirect_uri", token: testInitialAccessToken, metadata: `{"redirect_uris": ["javascript:alert(1)"]}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidRedirectUriMsg},
+		{name: "data_redirect_uri", token: testInitialAccessToken, metadata: `{"redirect_uris": ["data:text/html,<b>app</b>"]}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidRedirectUriMsg},
+		{name: "http_redirect_uri", token: testInitialAccessToken, metadata: `{"redirect_uris": ["http://app.example.com/callback"]}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidRedirectUriMsg},
+		{name: "http_notification_endpoint", token: testInitialAccessToken,
+			metadata:       `{"backchannel_token_delivery_mode": "ping", "backchannel_client_notification_endpoint": "http://app.example.com/ping"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "loopback_notification_endpoint", token: testInitialAccessToken,
+			metadata:       `{"backchannel_token_delivery_mode": "ping", "backchannel_client_notification_endpoint": "https://127.0.0.1/ping"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "localhost_notification_endpoint", token: testInitialAccessToken,
+			metadata:       `{"backchannel_token_delivery_mode": "ping", "backchannel_client_notification_endpoint": "https://localhost:8443/ping"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "private_notification_endpoint", token: testInitialAccessToken,
+			metadata:       `{"backchannel_token_delivery_mode": "ping", "backchannel_client_notification_endpoint": "https://10.0.0.5/ping"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+		{name: "link_local_notification_endpoint", token: testInitialAccessToken,
+			metadata:       `{"backchannel_token_delivery_mode": "ping", "backchannel_client_notification_endpoint": "https://169.254.169.254/ping"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: errors.InvalidClientMetadataMsg},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			response := doJsonRequest(t, app, http.MethodPost, getRegistrationPath(""), tc.metadata, tc.token)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			var errDetails dto.ErrorDetails
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+			assert.Equal(t, tc.expectedError, errDetails.Msg)
+		})
+	}
+}
+
+func TestClientRegistrationLifecycle(t *testing.T) {
+	app := createTestApp(t, createRegistrationServerData())
+	metadata := `{"client_name": "Demo app", "redirect_uris": ["` + testRedirectUri + `"], "grant_types": ["authorization_code"]}`
+	response := doJsonRequest(t, app, http.MethodPost, getRegistrationPath(""), metadata, testInitialAccessToken)
+	require.Equal(t, http.StatusCreated, response.Code)
+	registered := readClientMetadata(t, response)
+	assert.NotEmpty(t, registered.ClientId)
+	assert.NotEmpty(t, registered.ClientSecret)
+	assert.NotEmpty(t, registered.RegistrationAccessToken)
+	assert.Equal(t, globals.ClientSecretBasicAuthMethod, registered.TokenEndpointAuthMethod)
+	assert.Equal(t, []string{testRedirectUri}, registered.RedirectUris)
+	require.NotNil(t, registered.ClientSecretExpiresAt)
+	assert.Equal(t, int64(0), *registered.ClientSecretExpiresAt)
+	assert.True(t, strings.HasSuffix(registered.RegistrationClientUri, "/auth/realms/"+testRegistrationRealm+
+		"/clients-registrations/openid-connect/"+registered.ClientId))
+
+	// initial access token allows to register only one client
+	response = doJsonRequest(t, app, http.MethodPost, getRegistrationPath(""), metadata, testInitialAccessToken)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// registered client could authenticate itself
+	client, err := (*app.dataProvider).GetClient(testRegistrationRealm, registered.ClientId)
+	require.NoError(t, err)
+	realm, err := (*app.dataProvider).GetRealm(testRegistrationRealm)
+	require.NoError(t, err)
+	check := (*app.webApiContext.Security).Validate(&dto.TokenGenerationData{ClientId: client.Name, ClientSecret: registered.ClientSecret}, realm)
+	assert.Nil(t, check)
+
+	clientPath := getRegistrationPath(registered.ClientId)
+	response = doJsonRequest(t, app, http.MethodGet, clientPath, "", "wrongRegistrationToken")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, clientPath, "", registered.RegistrationAccessToken)
+	require.Equal(t, http.StatusOK, response.Code)
+	read := readClientMetadata(t, response)
+	assert.Equal(t, "Demo app", read.ClientName)
+	assert.Empty(t, read.RegistrationAccessToken)
+
+	// client_secret in metadata must be equal to current client secret
+	metadata = `{"client_id": "` + registered.ClientId + `", "client_secret": "wrong"}`
+	response = doJsonRequest(t, app, http.MethodPut, clientPath, metadata, registered.RegistrationAccessToken)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.ClientSecretMismatchDesc)
+
+	// update replaces metadata and rotates registration access token
+	metadata = `{"client_id": "` + registered.ClientId + `", "client_name": "Renamed app", "token_endpoint_auth_method": "none"}`
+	response = doJsonRequest(t, app, http.MethodPut, clientPath, metadata, registered.RegistrationAccessToken)
+	require.Equal(t, http.StatusOK, response.Code)
+	updated := readClientMetadata(t, response)
+	assert.Equal(t, "Renamed app", updated.ClientName)
+	assert.Equal(t, globals.NoneAuthMethod, updated.TokenEndpointAuthMethod)
+	assert.Empty(t, updated.ClientSecret)
+	assert.Empty(t, updated.RedirectUris)
+	assert.NotEqual(t, registered.RegistrationAccessToken, updated.RegistrationAccessToken)
+	response = doJsonRequest(t, app, http.MethodGet, clientPath, "", registered.RegistrationAccessToken)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	response = doJsonRequest(t, app, http.MethodDelete, clientPath, "", updated.RegistrationAccessToken)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, clientPath, "", updated.RegistrationAccessToken)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	_, err = (*app.dataProvider).GetClient(testRegistrationRealm, registered.ClientId)
+	assert.Error(t, err)
+}
+
+func TestRegisterClientWithLoopbackAndNativeRedirectUris(t *testing.T) {
+	app := createTestApp(t, createRegistrationServerData())
+	redirectUris := []string{"http://127.0.0.1:8080/callback", "http://localhost/callback", "com.example.app:/callback"}
+	metadata := `{"redirect_uris": ["` + strings.Join(redirectUris, `", "`) + `"], "backchannel_token_delivery_mode": "ping",
+		"backchannel_client_notification_endpoint": "https://203.0.113.10/ping"}`
+	response := doJsonRequest(t, app, http.MethodPost, getRegistrationPath(""), metadata, testInitialAccessToken)
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	registered := readClientMetadata(t, response)
+	assert.Equal(t, redirectUris, registered.RedirectUris)
+	assert.Equal(t, "https://203.0.113.10/ping", registered.BackChannelClientNotificationEndpoint)
+}
+
+func TestRegisterClientsInParallel(t *testing.T) {
+	app := createTestApp(t, createRegistrationServerData())
+	statuses := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			response := doJsonRequest(t, app, http.MethodPost, getRegistrationPath(""), `{"client_name": "app"}`, testInitialAccessToken)
+			statuses[index] = response.Code
+		}(i)
+	}
+	wg.Wait()
+	// initial access token allows to register only one client
+	created := 0
+	for _, status := range statuses {
+		if status == http.StatusCreated {
+			created++
+		} else {
+			assert.Equal(t, http.StatusUnauthorized, status)
+		}
+	}
+	assert.Equal(t, 1, created)
+	realm, err := (*app.dataProvider).GetRealm(testRegistrationRealm)
+	require.NoError(t, err)
+	assert.Equal(t, 1, realm.InitialAccessTokens[0].Used)
+}
+
+func TestOpenIdConfigurationContainsRegistrationEndpoint(t *testing.T) {
+	app := createTestApp(t, createRegistrationServerData())
+	request := httptest.NewRequest(http.MethodGet, "/realms/"+testRegistrationRealm+"/.well-known/openid-configuration", nil)
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	var openIdConfig dto.OpenIdConfiguration
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &openIdConfig))
+	assert.Equal(t, openIdConfig.Issuer+"/clients-registrations/openid-connect", openIdConfig.RegistrationEndpoint)
+}
+
+func getRegistrationPath(clientId string) string {
+	path := "/realms/" + testRegistrationRealm + "/clients-registrations/openid-connect"
+	if len(clientId) > 0 {
+		path += "/" + clientId
+	}
+	return path
+}
+
+func doJsonRequest(t *testing.T, app *Application, method string, path string, body string, bearerToken string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	if len(bearerToken) > 0 {
+		request.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
+
+func readClientMetadata(t *testing.T, response *httptest.ResponseRecorder) dto.ClientRegistrationMetadata {
+	var metadata dto.ClientRegistrationMetadata
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &metadata))
+	return metadata
+}
@@ -0,0 +1,144 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const testConsentClient = "thirdparty"
+
+func TestConsentScreen(t *testing.T) {
+	app := createConsentTestApp(t)
+	params := url.Values{"client_id": {testConsentClient}, "response_type": {globals.CodeResponseType}, "state": {"st1"},
+		"redirect_uri": {testLoginRedirectUri}, "scope": {"openid email custom"}}
+	response := submitLoginForm(t, app, doAuthorizationRequest(app, params, nil), testAuthUser, testAuthUserPassword)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	identity := getIdentityCookie(t, response)
+	// scopes without message are shown by name
+	assert.Contains(t, response.Body.String(), "Your email address")
+	assert.Contains(t, response.Body.String(), "<li>custom</li>")
+
+	// denied consent is passed to client as access_denied, consent is not stored
+	location := getRedirectParams(t, submitConsentForm(t, app, response, identity, false), testLoginRedirectUri)
+	assert.Equal(t, errors.AccessDeniedMsg, location.Get("error"))
+	assert.Equal(t, "st1", location.Get("state"))
+	response = doAuthorizationRequest(app, copyValues(params, "prompt", globals.NonePrompt), identity)
+	assert.Equal(t, errors.ConsentRequiredMsg, getRedirectParams(t, response, testLoginRedirectUri).Get("error"))
+
+	// granted consent is stored, authorization with granted scopes skips consent screen
+	response = doAuthorizationRequest(app, params, identity)
+	require.Equal(t, http.StatusOK, response.Code)
+	code := getRedirectParams(t, submitConsentForm(t, app, response, identity, true), testLoginRedirectUri).Get("code")
+	response = exchangeCode(t, app, testConsentClient, code, "")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	response = doAuthorizationRequest(app, copyValues(params, "scope", "openid email"), identity)
+	assert.NotEmpty(t, getRedirectParams(t, response, testLoginRedirectUri).Get("code"))
+	// new scope and prompt=consent show consent screen again
+	response = doAuthorizationRequest(app, copyValues(params, "scope", "openid phone"), identity)
+	assert.Equal(t, http.StatusOK, response.Code)
+	response = doAuthorizationRequest(app, copyValues(params, "prompt", globals.ConsentPrompt), identity)
+	assert.Equal(t, http.StatusOK, response.Code)
+	// client without consent_required doesn't show consent screen
+	response = doAuthorizationRequest(app, copyValues(params, "client_id", testLoginPublicClient), identity)
+	assert.NotEmpty(t, getRedirectParams(t, response, testLoginRedirectUri).Get("code"))
+
+	// user lists and revokes own consents
+	consentsPath := "/auth/realms/" + testLoginRealm + "/account/consents"
+	response = doJsonRequest(t, app, http.MethodGet, consentsPath, "", token.AccessToken)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var consents []data.UserConsent
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &consents))
+	require.Len(t, consents, 1)
+	assert.Equal(t, testConsentClient, consents[0].ClientId)
+	assert.Equal(t, []string{"openid", "email", "custom"}, consents[0].Scopes)
+	response = doJsonRequest(t, app, http.MethodDelete, consentsPath+"/"+testConsentClient, "", token.AccessToken)
+	assert.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	response = doJsonRequest(t, app, http.MethodDelete, consentsPath+"/"+testConsentClient, "", token.AccessToken)
+	assert.Equal(t, http.StatusNotFound, response.Code)
+	response = doJsonRequest(t, app, http.MethodGet, consentsPath, "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = doAuthorizationRequest(app, copyValues(params, "scope", "openid"), identity)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "Your identity")
+}
+
+func TestConsentFormReferenceIsBoundToSession(t *testing.T) {
+	app := createConsentTestApp(t)
+	params := url.Values{"client_id": {testConsentClient}, "response_type": {globals.CodeResponseType},
+		"redirect_uri": {testLoginRedirectUri}, "scope": {"openid email"}}
+	page := submitLoginForm(t, app, doAuthorizationRequest(app, params, nil), testAuthUser, testAuthUserPassword)
+	require.Equal(t, http.StatusOK, page.Code, page.Body.String())
+	identity := getIdentityCookie(t, page)
+	security := *app.webApiContext.Security
+	authRequest := dto.AuthorizationRequest{ClientId: testConsentClient, ResponseType: globals.CodeResponseType,
+		RedirectUri: testLoginRedirectUri, Scope: "openid email"}
+
+	// request_uri that client pushed itself (PAR) is not a consent form reference
+	requestUri := security.StorePushedAuthorizationRequest(testLoginRealm, &authRequest, globals.LoginPageExpiration)
+	checkConsentRejected(t, app, requestUri, identity)
+	// consent form reference of other session is not accepted
+	reference := security.StoreConsentRequest(testLoginRealm, &authRequest, uuid.New(), globals.LoginPageExpiration)
+	checkConsentRejected(t, app, reference, identity)
+	assert.False(t, data.IsConsentGranted(getConsentTestUser(t, app), testConsentClient, authRequest.Scope))
+
+	// consent form reference submitted with other client_id is rejected but remains usable
+	match := requestUriRegex.FindStringSubmatch(page.Body.String())
+	require.Len(t, match, 2)
+	form := url.Values{"client_id": {testLoginPublicClient}, "request_uri": {match[1]}, "accept": {"true"}}
+	response := doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/login-actions/consent", form,
+		map[string]string{"Cookie": identity.Name + "=" + identity.Value})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// consent form that was shown to user session is accepted
+	location := getRedirectParams(t, submitConsentForm(t, app, page, identity, true), testLoginRedirectUri)
+	assert.NotEmpty(t, location.Get("code"))
+	assert.True(t, data.IsConsentGranted(getConsentTestUser(t, app), testConsentClient, authRequest.Scope))
+}
+
+func createConsentTestApp(t *testing.T) *Application {
+	user := createTestHashingUser(testAuthUser, "5d0e3a52-8c51-4c39-9a43-7f8a1d3c2b60", map[string]interface{}{"password": testAuthUserPassword})
+	realm := data.Realm{Name: testLoginRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		Clients: []data.Client{
+			{Name: testConsentClient, Type: data.Public, RedirectUris: []string{testLoginRedirectUri}, ConsentRequired: true},
+			{Name: testLoginPublicClient, Type: data.Public, RedirectUris: []string{testLoginRedirectUri}},
+		},
+		Users: []interface{}{user},
+	}
+	return createTestApp(t, &data.ServerData{Realms: []data.Realm{realm}})
+}
+
+func submitConsentForm(t *testing.T, app *Application, page *httptest.ResponseRecorder, identity *http.Cookie, accept bool) *httptest.ResponseRecorder {
+	match := requestUriRegex.FindStringSubmatch(page.Body.String())
+	require.Len(t, match, 2, page.Body.String())
+	form := url.Values{"client_id": {testConsentClient}, "request_uri": {match[1]}, "accept": {strconv.FormatBool(accept)}}
+	return doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/login-actions/consent", form,
+		map[string]string{"Cookie": identity.Name + "=" + identity.Value})
+}
+
+func checkConsentRejected(t *testing.T, app *Application, reference string, identity *http.Cookie) {
+	form := url.Values{"client_id": {testConsentClient}, "request_uri": {reference}, "accept": {"true"}}
+	response := doFormRequest(t, app, "/auth/realms/"+testLoginRealm+"/login-actions/consent", form,
+		map[string]string{"Cookie": identity.Name + "=" + identity.Value})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), errors.LoginPageExpiredDesc)
+}
+
+func getConsentTestUser(t *testing.T, app *Application) data.User {
+	user, err := (*app.dataProvider).GetUser(testLoginRealm, testAuthUser)
+	require.NoError(t, err)
+	return user
+}
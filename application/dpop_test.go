@@ -0,0 +1,255 @@
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const testDPoPRealm = "dpoprealm"
+const testDPoPPublicClient = "dpop-public-client"
+
+var testDPoPServerData = data.ServerData{
+	Realms: []data.Realm{
+		{Name: testDPoPRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+			Clients: []data.Client{
+				{Name: testDPoPPublicClient, Type: data.Public, DPoPBoundAccessTokens: true},
+				{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+			},
+			Users: []interface{}{
+				map[string]interface{}{"info": map[string]interface{}{"sub": "667ff6a7-3f6b-449b-a217-6fc5d9ac0723",
+					"preferred_username": testAuthUser}, "credentials": map[string]interface{}{"password": testAuthUserPassword}},
+			},
+		},
+	},
+}
+
+// dpopProof describes DPoP proof JWT that test client creates
+type dpopProof struct {
+	key         *ecdsa.PrivateKey
+	method      string
+	uri         string
+	accessToken string
+	issuedAt    time.Time
+	withPrivate bool
+}
+
+func TestDPoPTokenRequest(t *testing.T) {
+	app := createTestApp(t, &testDPoPServerData)
+	key := createDPoPKey(t)
+	tokenUri := getDPoPRealmUri("protocol/openid-connect/token")
+	replayedProof := createDPoPProof(t, &dpopProof{key: key, method: http.MethodPost, uri: tokenUri})
+
+	testCases := []struct {
+		name              string
+		clientId          string
+		proof             string
+		expectedStatus    int
+		expectedDesc      string
+		expectedTokenType string
+	}{
+		{name: "dpop_bound_token", clientId: testDPoPPublicClient, proof: replayedProof, expectedStatus: http.StatusOK,
+			expectedTokenType: "DPoP"},
+		{name: "replayed_proof", clientId: testDPoPPublicClient, proof: replayedProof, expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.InvalidDPoPProofDesc},
+		{name: "proof_is_required_for_client", clientId: testDPoPPublicClient, expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.DPoPProofRequiredDesc},
+		{name: "proof_with_wrong_method", clientId: testDPoPPublicClient, proof: createDPoPProof(t, &dpopProof{key: key,
+			method: http.MethodGet, uri: tokenUri}), expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidDPoPProofDesc},
+		{name: "proof_with_wrong_uri", clientId: testDPoPPublicClient, proof: createDPoPProof(t, &dpopProof{key: key,
+			method: http.MethodPost, uri: getDPoPRealmUri("protocol/openid-connect/userinfo")}), expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.InvalidDPoPProofDesc},
+		{name: "expired_proof", clientId: testDPoPPublicClient, proof: createDPoPProof(t, &dpopProof{key: key, method: http.MethodPost,
+			uri: tokenUri, issuedAt: time.Now().Add(-time.Hour)}), expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidDPoPProofDesc},
+		{name: "proof_with_private_key", clientId: testDPoPPublicClient, proof: createDPoPProof(t, &dpopProof{key: key,
+			method: http.MethodPost, uri: tokenUri, withPrivate: true}), expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.InvalidDPoPProofDesc},
+		{name: "proof_uri_with_query", clientId: testClient1, proof: createDPoPProof(t, &dpopProof{key: key, method: http.MethodPost,
+			uri: tokenUri + "?param=value"}), expectedStatus: http.StatusOK, expectedTokenType: "DPoP"},
+		{name: "bearer_token_without_proof", clientId: testClient1, expectedStatus: http.StatusOK, expectedTokenType: "Bearer"},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			response := issueDPoPToken(t, app, tc.clientId, tc.proof)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			if tc.expectedStatus != http.StatusOK {
+				var errDetails dto.ErrorDetails
+				require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+				assert.Equal(t, errors.InvalidDPoPProofMsg, errDetails.Msg)
+				assert.Equal(t, tc.expectedDesc, errDetails.Description)
+				return
+			}
+			var token dto.Token
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+			assert.Equal(t, tc.expectedTokenType, token.TokenType)
+			claims := jwt.MapClaims{}
+			_, _, err := jwt.NewParser().ParseUnverified(token.AccessToken, claims)
+			require.NoError(t, err)
+			if len(tc.proof) > 0 {
+				assert.Equal(t, map[string]interface{}{"jkt": getDPoPKeyThumbprint(t, key)}, claims["cnf"])
+			} else {
+				assert.NotContains(t, claims, "cnf")
+			}
+		})
+	}
+}
+
+func TestDPoPBoundTokenUsage(t *testing.T) {
+	app := createTestApp(t, &testDPoPServerData)
+	key := createDPoPKey(t)
+	otherKey := createDPoPKey(t)
+	response := issueDPoPToken(t, app, testDPoPPublicClient, createDPoPProof(t, &dpopProof{key: key, method: http.MethodPost,
+		uri: getDPoPRealmUri("protocol/openid-connect/token")}))
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+
+	userInfoUri := getDPoPRealmUri("protocol/openid-connect/userinfo")
+	testCases := []struct {
+		name           string
+		scheme         string
+		proof          *dpopProof
+		expectedStatus int
+	}{
+		{name: "bearer_scheme", scheme: "Bearer", expectedStatus: http.StatusUnauthorized},
+		{name: "without_proof", scheme: "DPoP", expectedStatus: http.StatusUnauthorized},
+		{name: "proof_without_ath", scheme: "DPoP", proof: &dpopProof{key: key, method: http.MethodGet, uri: userInfoUri},
+			expectedStatus: http.StatusUnauthorized},
+		{name: "proof_of_other_key", scheme: "DPoP", proof: &dpopProof{key: otherKey, method: http.MethodGet, uri: userInfoUri,
+			accessToken: token.AccessToken}, expectedStatus: http.StatusUnauthorized},
+		{name: "valid_proof", scheme: "DPoP", proof: &dpopProof{key: key, method: http.MethodGet, uri: userInfoUri,
+			accessToken: token.AccessToken}, expectedStatus: http.StatusOK},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/auth/realms/"+testDPoPRealm+"/protocol/openid-connect/userinfo", nil)
+			request.Header.Set("Authorization", tc.scheme+" "+token.AccessToken)
+			if tc.proof != nil {
+				request.Header.Set(globals.DPoPHeader, createDPoPProof(t, tc.proof))
+			}
+			response := serveRequest(app, request)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			if tc.expectedStatus != http.StatusOK {
+				assert.True(t, strings.HasPrefix(response.Header().Get("WWW-Authenticate"), "DPoP error="))
+			}
+		})
+	}
+
+	// introspection passes binding to resource server
+	form := url.Values{}
+	form.Set("client_id", testClient1)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("token", token.AccessToken)
+	response = doFormRequest(t, app, "/realms/"+testDPoPRealm+"/protocol/openid-connect/token/introspect", form, nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	var introspectResult dto.IntrospectTokenResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &introspectResult))
+	assert.Equal(t, "DPoP", introspectResult.Type)
+	require.NotNil(t, introspectResult.Cnf)
+	assert.Equal(t, getDPoPKeyThumbprint(t, key), introspectResult.Cnf.JwkThumbprint)
+
+	// refresh requires proof signed with the same key
+	tokenUri := getDPoPRealmUri("protocol/openid-connect/token")
+	form = url.Values{}
+	form.Set("client_id", testDPoPPublicClient)
+	form.Set("grant_type", globals.RefreshTokenGrantType)
+	form.Set("refresh_token", token.RefreshToken)
+	response = doFormRequest(t, app, "/realms/"+testDPoPRealm+"/protocol/openid-connect/token", form,
+		map[string]string{globals.DPoPHeader: createDPoPProof(t, &dpopProof{key: otherKey, method: http.MethodPost, uri: tokenUri})})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	response = doFormRequest(t, app, "/realms/"+testDPoPRealm+"/protocol/openid-connect/token", form,
+		map[string]string{globals.DPoPHeader: createDPoPProof(t, &dpopProof{key: key, method: http.MethodPost, uri: tokenUri})})
+	require.Equal(t, http.StatusOK, response.Code)
+	var refreshed dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &refreshed))
+	assert.Equal(t, "DPoP", refreshed.TokenType)
+}
+
+func TestOpenIdConfigurationContainsDPoPAlgorithms(t *testing.T) {
+	app := createTestApp(t, &testDPoPServerData)
+	response := serveRequest(app, httptest.NewRequest(http.MethodGet, "/realms/"+testDPoPRealm+"/.well-known/openid-configuration", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	var openIdConfig dto.OpenIdConfiguration
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &openIdConfig))
+	assert.Contains(t, openIdConfig.DPoPSigningAlgValuesSupported, "ES256")
+	assert.NotContains(t, openIdConfig.DPoPSigningAlgValuesSupported, "HS256")
+}
+
+func issueDPoPToken(t *testing.T, app *Application, clientId string, proof string) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	if clientId == testClient1 {
+		form.Set("client_secret", testClient1Secret)
+	}
+	form.Set("grant_type", globals.PasswordGrantType)
+	form.Set("scope", globals.OpenIdScope)
+	form.Set("username", testAuthUser)
+	form.Set("password", testAuthUserPassword)
+	headers := map[string]string{}
+	if len(proof) > 0 {
+		headers[globals.DPoPHeader] = proof
+	}
+	return doFormRequest(t, app, "/auth/realms/"+testDPoPRealm+"/protocol/openid-connect/token", form, headers)
+}
+
+func getDPoPRealmUri(path string) string {
+	return sf.Format("{0}://{1}:{2}/auth/realms/{3}/{4}", httpAppConfig.ServerCfg.Schema, httpAppConfig.ServerCfg.Address,
+		httpAppConfig.ServerCfg.Port, testDPoPRealm, path)
+}
+
+func createDPoPKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func createDPoPProof(t *testing.T, proof *dpopProof) string {
+	issuedAt := proof.issuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	claims := jwt.MapClaims{"jti": uuid.New().String(), "htm": proof.method, "htu": proof.uri, "iat": issuedAt.Unix()}
+	if len(proof.accessToken) > 0 {
+		hash := sha256.Sum256([]byte(proof.accessToken))
+		claims["ath"] = base64.RawURLEncoding.EncodeToString(hash[:])
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	jwk := createEcJwk("", &proof.key.PublicKey)
+	header := map[string]interface{}{"kty": jwk.Kty, "crv": jwk.Crv, "x": jwk.X, "y": jwk.Y}
+	if proof.withPrivate {
+		header["d"] = base64.RawURLEncoding.EncodeToString(proof.key.D.FillBytes(make([]byte, 32)))
+	}
+	token.Header["typ"] = globals.DPoPProofType
+	token.Header["jwk"] = header
+	signed, err := token.SignedString(proof.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func getDPoPKeyThumbprint(t *testing.T, key *ecdsa.PrivateKey) string {
+	jwk := createEcJwk("", &key.PublicKey)
+	thumbprint, err := jwk.Thumbprint()
+	require.NoError(t, err)
+	return thumbprint
+}
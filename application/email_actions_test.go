@@ -0,0 +1,188 @@
+package application
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+)
+
+const (
+	testEmailRealm     = "emailrealm"
+	testEmailUserEmail = "vano@ferrum.test"
+	testEmailLinkBase  = "https://auth.ferrum.test"
+	testNewPassword    = "N3w_Passw0rd!"
+)
+
+var mailLinkRegex = regexp.MustCompile(`https://\S+`)
+
+func createEmailTestApp(t *testing.T, mailsFile string, settings *data.EmailSettings) *Application {
+	appConfig := httpAppConfig
+	appConfig.Mail = &config.MailConfig{Sender: config.FileMailSender, Destination: mailsFile, LinkBaseUrl: testEmailLinkBase}
+	user := createTestHashingUser(testAuthUser, "667ff6a7-3f6b-449b-a217-6fc5d9ac0a01", map[string]interface{}{"password": testAuthUserPassword})
+	user.(map[string]interface{})["info"].(map[string]interface{})["email"] = testEmailUserEmail
+	realm := data.Realm{Name: testEmailRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		Email: settings, PasswordPolicy: &data.PasswordPolicy{MinLength: 10},
+		Clients: []data.Client{
+			{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+		},
+		Users: []interface{}{user},
+	}
+	return createTestAppWithConfig(t, &appConfig, &data.ServerData{Realms: []data.Realm{realm}})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.jsonl")
+	app := createEmailTestApp(t, mailsFile, &data.EmailSettings{From: "noreply@ferrum.test"})
+
+	response := doJsonRequest(t, app, http.MethodPost, "/auth/realms/"+testEmailRealm+"/protocol/openid-connect/ext/email/verify", "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	accessToken := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testEmailRealm, testAuthUser, testAuthUserPassword))
+	response = doJsonRequest(t, app, http.MethodPost, "/auth/realms/"+testEmailRealm+"/protocol/openid-connect/ext/email/verify", "", accessToken)
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+
+	message := readLastMail(t, mailsFile)
+	assert.Equal(t, testEmailUserEmail, message.To)
+	assert.Equal(t, "noreply@ferrum.test", message.From)
+	assert.Contains(t, message.Body, "5 minutes")
+	link := getMailLink(t, message)
+	response = doLinkRequest(app, http.MethodGet, link, nil)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var result dto.ActionTokenResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(t, []string{data.VerifyEmailAction}, result.ExecutedActions)
+	user, err := (*app.dataProvider).GetUser(testEmailRealm, testAuthUser)
+	require.NoError(t, err)
+	assert.Equal(t, true, user.GetUserInfo().(map[string]interface{})["email_verified"])
+	assert.Empty(t, user.GetActionTokens())
+
+	// link is one-time
+	response = doLinkRequest(app, http.MethodGet, link, nil)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidActionTokenDesc)
+}
+
+func TestResetPassword(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.jsonl")
+	app := createEmailTestApp(t, mailsFile, &data.EmailSettings{From: "noreply@ferrum.test", ActionTokenLifespan: 7200})
+	resetPath := "/auth/realms/" + testEmailRealm + "/login-actions/reset-credentials"
+
+	// response doesn't depend on user existence
+	response := doFormRequest(t, app, resetPath, url.Values{"username": {"unknown"}}, nil)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+	_, err := os.Stat(mailsFile)
+	assert.True(t, os.IsNotExist(err))
+	response = doFormRequest(t, app, resetPath, url.Values{}, nil)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = doFormRequest(t, app, resetPath, url.Values{"username": {testAuthUser}}, nil)
+	require.Equal(t, http.StatusNoContent, response.Code)
+	message := readLastMail(t, mailsFile)
+	assert.Equal(t, "Reset password", message.Subject)
+	assert.Contains(t, message.Body, "2 hours")
+	link := getMailLink(t, message)
+
+	// link requires password and realm password policy is checked, link remains valid
+	response = doLinkRequest(app, http.MethodGet, link, nil)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.PasswordRequiredDesc)
+	response = doLinkRequest(app, http.MethodPost, link, url.Values{"password": {"short"}})
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	var errorDetails dto.ErrorDetails
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errorDetails))
+	assert.Equal(t, errors.InvalidPasswordMsg, errorDetails.Msg)
+
+	response = doLinkRequest(app, http.MethodPost, link, url.Values{"password": {testNewPassword}})
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, http.StatusOK, issuePasswordGrantToken(t, app, testEmailRealm, testAuthUser, testNewPassword).Code)
+	assert.Equal(t, http.StatusUnauthorized, issuePasswordGrantToken(t, app, testEmailRealm, testAuthUser, testAuthUserPassword).Code)
+	response = doLinkRequest(app, http.MethodPost, link, url.Values{"password": {testNewPassword}})
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidActionTokenDesc)
+}
+
+func TestExecuteActionsEmail(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.jsonl")
+	app := createEmailTestApp(t, mailsFile, &data.EmailSettings{From: "noreply@ferrum.test"})
+	realm, err := (*app.dataProvider).GetRealm(testEmailRealm)
+	require.NoError(t, err)
+	user, err := (*app.dataProvider).GetUser(testEmailRealm, testAuthUser)
+	require.NoError(t, err)
+	emailActions := *app.webApiContext.EmailActions
+
+	check := emailActions.SendExecuteActionsEmail(realm, user, []string{"CONFIGURE_TOTP"}, 0)
+	require.NotNil(t, check)
+	assert.Equal(t, errors.UnsupportedActionDesc, check.Description)
+	require.Nil(t, emailActions.SendExecuteActionsEmail(realm, user, []string{data.UpdatePasswordAction, data.VerifyEmailAction}, 0))
+	message := readLastMail(t, mailsFile)
+	assert.Contains(t, message.Body, "12 hours")
+	assert.Contains(t, message.Body, "UPDATE_PASSWORD, VERIFY_EMAIL")
+
+	response := doLinkRequest(app, http.MethodPost, getMailLink(t, message), url.Values{"password": {testNewPassword}})
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var result dto.ActionTokenResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(t, testAuthUser, result.Username)
+	assert.ElementsMatch(t, []string{data.UpdatePasswordAction, data.VerifyEmailAction}, result.ExecutedActions)
+	user, err = (*app.dataProvider).GetUser(testEmailRealm, testAuthUser)
+	require.NoError(t, err)
+	assert.Equal(t, true, user.GetUserInfo().(map[string]interface{})["email_verified"])
+}
+
+func TestEmailNotConfigured(t *testing.T) {
+	app := createEmailTestApp(t, filepath.Join(t.TempDir(), "mails.jsonl"), nil)
+	response := doFormRequest(t, app, "/auth/realms/"+testEmailRealm+"/login-actions/reset-credentials",
+		url.Values{"username": {testAuthUser}}, nil)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.EmailNotEnabledDesc)
+	response = doLinkRequest(app, http.MethodGet, testEmailLinkBase+"/auth/realms/"+testEmailRealm+"/login-actions/action-token?key=abc", nil)
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidActionTokenDesc)
+}
+
+func getTokenFromResponse(t *testing.T, response *httptest.ResponseRecorder) string {
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	return token.AccessToken
+}
+
+func readLastMail(t *testing.T, fileName string) dto.MailMessage {
+	file, err := os.Open(fileName)
+	require.NoError(t, err)
+	defer file.Close()
+	var last string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		last = scanner.Text()
+	}
+	var message dto.MailMessage
+	require.NoError(t, json.Unmarshal([]byte(last), &message))
+	return message
+}
+
+func getMailLink(t *testing.T, message dto.MailMessage) string {
+	link := mailLinkRegex.FindString(message.Body)
+	require.NotEmpty(t, link)
+	return link
+}
+
+// doLinkRequest opens email link (GET) or submits form to link (POST)
+func doLinkRequest(app *Application, method string, link string, form url.Values) *httptest.ResponseRecorder {
+	linkUrl, _ := url.Parse(link)
+	request := httptest.NewRequest(method, linkUrl.RequestURI(), strings.NewReader(form.Encode()))
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
@@ -0,0 +1,255 @@
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const testMtlsRealm = "mtlsrealm"
+const testCaTlsClient = "ca-tls-client"
+const testSelfSignedTlsClient = "self-signed-tls-client"
+const testCaTlsClientSubject = "CN=ca-tls-client,O=Ferrum"
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func TestMutualTlsClientAuthentication(t *testing.T) {
+	ca := createTestCertificate(t, pkix.Name{CommonName: "Ferrum test CA"}, nil)
+	caIssuedCert := createTestCertificate(t, pkix.Name{CommonName: testCaTlsClient, Organization: []string{"Ferrum"}}, ca)
+	otherSubjectCert := createTestCertificate(t, pkix.Name{CommonName: "other-client", Organization: []string{"Ferrum"}}, ca)
+	selfSignedCert := createTestCertificate(t, pkix.Name{CommonName: testSelfSignedTlsClient}, nil)
+	unknownSelfSignedCert := createTestCertificate(t, pkix.Name{CommonName: testSelfSignedTlsClient}, nil)
+	app := createMutualTlsTestApp(t, ca, selfSignedCert)
+
+	testCases := []struct {
+		name           string
+		clientId       string
+		certificate    *x509.Certificate
+		expectedStatus int
+		expectedDesc   string
+		expectBinding  bool
+	}{
+		{name: "tls_client_auth", clientId: testCaTlsClient, certificate: caIssuedCert.certificate, expectedStatus: http.StatusOK,
+			expectBinding: true},
+		{name: "tls_client_auth_without_certificate", clientId: testCaTlsClient, expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.ClientCertificateRequiredDesc},
+		{name: "tls_client_auth_untrusted_certificate", clientId: testCaTlsClient, certificate: selfSignedCert.certificate,
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientCertificateDesc},
+		{name: "tls_client_auth_subject_mismatch", clientId: testCaTlsClient, certificate: otherSubjectCert.certificate,
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientCertificateDesc},
+		{name: "self_signed_tls_client_auth", clientId: testSelfSignedTlsClient, certificate: selfSignedCert.certificate,
+			expectedStatus: http.StatusOK},
+		{name: "self_signed_tls_client_auth_unknown_key", clientId: testSelfSignedTlsClient, certificate: unknownSelfSignedCert.certificate,
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidClientCertificateDesc},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			response := issueMutualTlsToken(t, app, tc.clientId, tc.certificate)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			if tc.expectedStatus != http.StatusOK {
+				var errDetails dto.ErrorDetails
+				require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+				assert.Equal(t, tc.expectedDesc, errDetails.Description)
+				return
+			}
+			var token dto.Token
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+			claims := jwt.MapClaims{}
+			_, _, err := jwt.NewParser().ParseUnverified(token.AccessToken, claims)
+			require.NoError(t, err)
+			if tc.expectBinding {
+				assert.Equal(t, map[string]interface{}{"x5t#S256": getCertificateThumbprint(tc.certificate)}, claims["cnf"])
+			} else {
+				assert.NotContains(t, claims, "cnf")
+			}
+		})
+	}
+}
+
+func TestCertificateBoundAccessToken(t *testing.T) {
+	ca := createTestCertificate(t, pkix.Name{CommonName: "Ferrum test CA"}, nil)
+	clientCert := createTestCertificate(t, pkix.Name{CommonName: testCaTlsClient, Organization: []string{"Ferrum"}}, ca)
+	otherCert := createTestCertificate(t, pkix.Name{CommonName: testCaTlsClient, Organization: []string{"Ferrum"}}, ca)
+	app := createMutualTlsTestApp(t, ca, createTestCertificate(t, pkix.Name{CommonName: testSelfSignedTlsClient}, nil))
+	response := issueMutualTlsToken(t, app, testCaTlsClient, clientCert.certificate)
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+
+	userInfoPath := "/realms/" + testMtlsRealm + "/protocol/openid-connect/userinfo"
+	for _, cert := range []*x509.Certificate{nil, otherCert.certificate} {
+		request := createTlsRequest(http.MethodGet, userInfoPath, nil, cert)
+		request.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		response = serveRequest(app, request)
+		assert.Equal(t, http.StatusUnauthorized, response.Code)
+	}
+	request := createTlsRequest(http.MethodGet, userInfoPath, nil, clientCert.certificate)
+	request.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	response = serveRequest(app, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	// introspection returns token binding to resource server
+	form := url.Values{}
+	form.Set("client_id", testCaTlsClient)
+	form.Set("token", token.AccessToken)
+	request = createTlsRequest(http.MethodPost, "/realms/"+testMtlsRealm+"/protocol/openid-connect/token/introspect", form, clientCert.certificate)
+	response = serveRequest(app, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	var introspectResult dto.IntrospectTokenResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &introspectResult))
+	require.NotNil(t, introspectResult.Cnf)
+	assert.Equal(t, getCertificateThumbprint(clientCert.certificate), introspectResult.Cnf.X509Thumbprint)
+
+	// refresh token could be used only with the certificate that tokens are bound to
+	form = url.Values{}
+	form.Set("client_id", testCaTlsClient)
+	form.Set("grant_type", globals.RefreshTokenGrantType)
+	form.Set("refresh_token", token.RefreshToken)
+	request = createTlsRequest(http.MethodPost, "/realms/"+testMtlsRealm+"/protocol/openid-connect/token", form, otherCert.certificate)
+	response = serveRequest(app, request)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	request = createTlsRequest(http.MethodPost, "/realms/"+testMtlsRealm+"/protocol/openid-connect/token", form, clientCert.certificate)
+	response = serveRequest(app, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+}
+
+func TestOpenIdConfigurationContainsMutualTlsSettings(t *testing.T) {
+	ca := createTestCertificate(t, pkix.Name{CommonName: "Ferrum test CA"}, nil)
+	app := createMutualTlsTestApp(t, ca, createTestCertificate(t, pkix.Name{CommonName: testSelfSignedTlsClient}, nil))
+	response := serveRequest(app, httptest.NewRequest(http.MethodGet, "/realms/"+testMtlsRealm+"/.well-known/openid-configuration", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	var openIdConfig dto.OpenIdConfiguration
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &openIdConfig))
+	assert.True(t, openIdConfig.TlsClientCertificateBoundAccessToken)
+	assert.Contains(t, openIdConfig.TokenEndpointAuthMethodsSupported, globals.TlsClientAuthMethod)
+	assert.Contains(t, openIdConfig.TokenEndpointAuthMethodsSupported, globals.SelfSignedTlsClientAuthMethod)
+
+	// mutual TLS is not advertised when listener does not request client certificates
+	app = createTestApp(t, &testServerData)
+	response = serveRequest(app, httptest.NewRequest(http.MethodGet, "/realms/"+testRealm1+"/.well-known/openid-configuration", nil))
+	require.Equal(t, http.StatusOK, response.Code)
+	openIdConfig = dto.OpenIdConfiguration{}
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &openIdConfig))
+	assert.False(t, openIdConfig.TlsClientCertificateBoundAccessToken)
+	assert.NotContains(t, openIdConfig.TokenEndpointAuthMethodsSupported, globals.TlsClientAuthMethod)
+}
+
+func createMutualTlsTestApp(t *testing.T, ca *testCertificate, selfSignedCert *testCertificate) *Application {
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw})
+	require.NoError(t, os.WriteFile(caFile, caPem, 0600))
+	appConfig := httpsAppConfig
+	appConfig.ServerCfg.Security = &config.SecurityConfig{KeyFile: httpsAppConfig.ServerCfg.Security.KeyFile,
+		CertificateFile: httpsAppConfig.ServerCfg.Security.CertificateFile, ClientCertificate: config.OptionalClientCertificate,
+		ClientCaFile: caFile}
+	selfSignedKey := selfSignedCert.key.PublicKey
+	serverData := data.ServerData{
+		Realms: []data.Realm{
+			{Name: testMtlsRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				Clients: []data.Client{
+					{Name: testCaTlsClient, Type: data.Confidential, TlsClientCertificateBoundAccessTokens: true,
+						Auth: data.Authentication{Type: data.TlsClientAuth, TlsSubjectDn: testCaTlsClientSubject}},
+					{Name: testSelfSignedTlsClient, Type: data.Confidential, Auth: data.Authentication{Type: data.SelfSignedTlsClientAuth,
+						Jwks: &data.JsonWebKeySet{Keys: []data.JsonWebKey{createEcJwk("self-signed", &selfSignedKey)}}}},
+				},
+				Users: []interface{}{
+					map[string]interface{}{"info": map[string]interface{}{"sub": "667ff6a7-3f6b-449b-a217-6fc5d9ac0723",
+						"preferred_username": testAuthUser}, "credentials": map[string]interface{}{"password": testAuthUserPassword}},
+				},
+			},
+		},
+	}
+	return createTestAppWithConfig(t, &appConfig, &serverData)
+}
+
+// createTestCertificate creates client certificate signed by issuer or self-signed (CA) certificate if issuer is nil
+func createTestCertificate(t *testing.T, subject pkix.Name, issuer *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{SerialNumber: serial, Subject: subject, NotBefore: time.Now().Add(-time.Minute),
+		NotAfter: time.Now().Add(time.Hour), ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}
+	parent := template
+	signKey := key
+	if issuer == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		parent = issuer.certificate
+		signKey = issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCertificate{certificate: certificate, key: key}
+}
+
+func issueMutualTlsToken(t *testing.T, app *Application, clientId string, certificate *x509.Certificate) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	form.Set("grant_type", globals.PasswordGrantType)
+	form.Set("scope", globals.OpenIdScope)
+	form.Set("username", testAuthUser)
+	form.Set("password", testAuthUserPassword)
+	request := createTlsRequest(http.MethodPost, "/realms/"+testMtlsRealm+"/protocol/openid-connect/token", form, certificate)
+	return serveRequest(app, request)
+}
+
+// createTlsRequest creates request that was received via TLS connection where client presented certificate (if it is not nil)
+func createTlsRequest(method string, path string, form url.Values, certificate *x509.Certificate) *http.Request {
+	var request *http.Request
+	if form != nil {
+		request = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		request = httptest.NewRequest(method, path, nil)
+	}
+	request.TLS = &tls.ConnectionState{}
+	if certificate != nil {
+		request.TLS.PeerCertificates = []*x509.Certificate{certificate}
+	}
+	return request
+}
+
+func serveRequest(app *Application, request *http.Request) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
+
+func getCertificateThumbprint(certificate *x509.Certificate) string {
+	thumbprint := sha256.Sum256(certificate.Raw)
+	return base64.RawURLEncoding.EncodeToString(thumbprint[:])
+}
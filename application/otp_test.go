@@ -0,0 +1,122 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/services"
+	"github.com/wissance/Ferrum/utils/otp"
+)
+
+const (
+	testOtpRealm     = "otprealm"
+	testOtpAdminUser = "otp_admin"
+	testOtpPlainUser = "otp_plain"
+)
+
+func TestPasswordGrantWithOtp(t *testing.T) {
+	otpPolicy := data.OtpPolicy{Digits: 8, Period: 30, Algorithm: otp.Sha256Algorithm, LookAhead: 1, RecoveryCodes: 2,
+		RequiredRoles: []string{"admin"}}
+	realm := data.Realm{Name: testOtpRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		OtpPolicy: &otpPolicy,
+		Clients: []data.Client{
+			{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+		},
+	}
+	credential, uri, recoveryCodes, err := services.CreateOtpCredential(&realm, testAuthUser)
+	require.NoError(t, err)
+	require.Len(t, recoveryCodes, 2)
+	keyUri, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, credential.Secret, keyUri.Query().Get("secret"))
+	assert.Equal(t, "8", keyUri.Query().Get("digits"))
+	assert.Equal(t, otp.Sha256Algorithm, keyUri.Query().Get("algorithm"))
+
+	otpUser := data.CreateUser(createTestHashingUser(testAuthUser, "667ff6a7-3f6b-449b-a217-6fc5d9ac0801",
+		map[string]interface{}{"password": testAuthUserPassword}))
+	require.NoError(t, otpUser.SetOtpCredential(credential))
+	adminUser := createTestHashingUser(testOtpAdminUser, "667ff6a7-3f6b-449b-a217-6fc5d9ac0802",
+		map[string]interface{}{"password": testAuthUserPassword})
+	adminUser.(map[string]interface{})["info"].(map[string]interface{})["roles"] = []interface{}{"admin"}
+	realm.Users = []interface{}{otpUser.GetRawData(), adminUser,
+		createTestHashingUser(testOtpPlainUser, "667ff6a7-3f6b-449b-a217-6fc5d9ac0803", map[string]interface{}{"password": testAuthUserPassword}),
+	}
+	app := createTestApp(t, &data.ServerData{Realms: []data.Realm{realm}})
+
+	settings := otp.Settings{Digits: otpPolicy.Digits, Period: otpPolicy.Period, Algorithm: otpPolicy.Algorithm}
+	counter := otp.GetCounter(time.Now(), otpPolicy.Period)
+	currentCode, err := otp.GenerateCode(credential.Secret, counter, settings)
+	require.NoError(t, err)
+	previousCode, err := otp.GenerateCode(credential.Secret, counter-1, settings)
+	require.NoError(t, err)
+	outdatedCode, err := otp.GenerateCode(credential.Secret, counter-3, settings)
+	require.NoError(t, err)
+
+	// steps are executed sequentially because every successful code or recovery code can't be used again
+	steps := []struct {
+		name                string
+		userName            string
+		password            string
+		totp                string
+		expectedStatus      int
+		expectedDescription string
+	}{
+		{name: "missing_totp", userName: testAuthUser, password: testAuthUserPassword, expectedStatus: http.StatusUnauthorized,
+			expectedDescription: errors.OtpRequiredDesc},
+		{name: "wrong_password_with_valid_totp", userName: testAuthUser, password: "wrong_password", totp: currentCode,
+			expectedStatus: http.StatusUnauthorized, expectedDescription: errors.InvalidUserCredentialsDesc},
+		{name: "outdated_totp", userName: testAuthUser, password: testAuthUserPassword, totp: outdatedCode,
+			expectedStatus: http.StatusUnauthorized, expectedDescription: errors.InvalidOtpDesc},
+		{name: "previous_time_step_totp", userName: testAuthUser, password: testAuthUserPassword, totp: previousCode,
+			expectedStatus: http.StatusOK},
+		{name: "current_totp", userName: testAuthUser, password: testAuthUserPassword, totp: currentCode, expectedStatus: http.StatusOK},
+		{name: "replayed_totp", userName: testAuthUser, password: testAuthUserPassword, totp: currentCode,
+			expectedStatus: http.StatusUnauthorized, expectedDescription: errors.InvalidOtpDesc},
+		{name: "recovery_code", userName: testAuthUser, password: testAuthUserPassword, totp: recoveryCodes[0], expectedStatus: http.StatusOK},
+		{name: "used_recovery_code", userName: testAuthUser, password: testAuthUserPassword, totp: recoveryCodes[0],
+			expectedStatus: http.StatusUnauthorized, expectedDescription: errors.InvalidOtpDesc},
+		{name: "admin_without_otp", userName: testOtpAdminUser, password: testAuthUserPassword, expectedStatus: http.StatusUnauthorized,
+			expectedDescription: errors.OtpNotConfiguredDesc},
+		{name: "user_without_otp", userName: testOtpPlainUser, password: testAuthUserPassword, expectedStatus: http.StatusOK},
+	}
+	for _, step := range steps {
+		response := issueOtpPasswordGrantToken(t, app, step.userName, step.password, step.totp)
+		require.Equal(t, step.expectedStatus, response.Code, step.name)
+		if len(step.expectedDescription) > 0 {
+			var errorDetails dto.ErrorDetails
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errorDetails))
+			assert.Equal(t, step.expectedDescription, errorDetails.Description, step.name)
+		}
+	}
+
+	user, err := (*app.dataProvider).GetUser(testOtpRealm, testAuthUser)
+	require.NoError(t, err)
+	storedCredential := user.GetOtpCredential()
+	require.NotNil(t, storedCredential)
+	assert.Equal(t, counter, storedCredential.LastCounter)
+	assert.Len(t, storedCredential.RecoveryCodes, 1)
+}
+
+func issueOtpPasswordGrantToken(t *testing.T, app *Application, userName string, password string, totp string) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("client_id", testClient1)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("grant_type", globals.PasswordGrantType)
+	form.Set("scope", globals.OpenIdScope)
+	form.Set("username", userName)
+	form.Set("password", password)
+	if len(totp) > 0 {
+		form.Set("totp", totp)
+	}
+	return doFormRequest(t, app, "/auth/realms/"+testOtpRealm+"/protocol/openid-connect/token", form, nil)
+}
@@ -0,0 +1,96 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/utils/hashing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testPasswordHashingRealm = "hashingrealm"
+
+func TestPasswordHashesOnLogin(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte(testAuthUserPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	argon2Hash, err := hashing.HashPassword(testAuthUserPassword)
+	require.NoError(t, err)
+	serverData := data.ServerData{
+		Realms: []data.Realm{
+			{Name: testPasswordHashingRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				Clients: []data.Client{
+					{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+				},
+				Users: []interface{}{
+					createTestHashingUser("plain_user", "c6ff1a3e-4a37-4cb4-9c4c-9b0b7ed6e001",
+						map[string]interface{}{"password": testAuthUserPassword}),
+					createTestHashingUser("bcrypt_user", "c6ff1a3e-4a37-4cb4-9c4c-9b0b7ed6e002",
+						map[string]interface{}{"password": string(bcryptHash)}),
+					createTestHashingUser("argon2_user", "c6ff1a3e-4a37-4cb4-9c4c-9b0b7ed6e003",
+						map[string]interface{}{"password": argon2Hash}),
+					// KeyCloak export credential of "keycloak_password" password
+					createTestHashingUser("keycloak_user", "c6ff1a3e-4a37-4cb4-9c4c-9b0b7ed6e004", map[string]interface{}{
+						"secretData": `{"value":"4GwXh4B3vdGnAioqUKtdtwxY0m7lAVXP3PsAb0oGQkp1QbQAzk4UtoK5HdVDJJfb9JZME+qJRb8HlILQeVK97g==",` +
+							`"salt":"ZmVycnVtLWtjLXNhbHQxNg==","additionalParameters":{}}`,
+						"credentialData": `{"hashIterations":27500,"algorithm":"pbkdf2-sha256","additionalParameters":{}}`}),
+				},
+			},
+		},
+	}
+	app := createTestApp(t, &serverData)
+
+	testCases := []struct {
+		name     string
+		userName string
+		password string
+	}{
+		{name: "plain_text_password", userName: "plain_user", password: testAuthUserPassword},
+		{name: "bcrypt_hash", userName: "bcrypt_user", password: testAuthUserPassword},
+		{name: "argon2id_hash", userName: "argon2_user", password: testAuthUserPassword},
+		{name: "keycloak_pbkdf2_sha256_credential", userName: "keycloak_user", password: "keycloak_password"},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			response := issuePasswordGrantToken(t, app, testPasswordHashingRealm, tc.userName, tc.password+"_wrong")
+			assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+			response = issuePasswordGrantToken(t, app, testPasswordHashingRealm, tc.userName, tc.password)
+			assert.Equal(t, http.StatusOK, response.Code)
+			// password is rehashed with argon2id after successful login
+			user, err := (*app.dataProvider).GetUser(testPasswordHashingRealm, tc.userName)
+			require.NoError(t, err)
+			assert.True(t, strings.HasPrefix(user.GetPassword(), "$argon2id$"))
+			assert.NotContains(t, user.GetJsonString(), "secretData")
+			matches, rehash := hashing.CheckPassword(tc.password, user.GetPassword())
+			assert.True(t, matches)
+			assert.False(t, rehash)
+
+			response = issuePasswordGrantToken(t, app, testPasswordHashingRealm, tc.userName, tc.password)
+			assert.Equal(t, http.StatusOK, response.Code)
+		})
+	}
+}
+
+func createTestHashingUser(userName string, userId string, credentials map[string]interface{}) interface{} {
+	return map[string]interface{}{"info": map[string]interface{}{"sub": userId, "preferred_username": userName},
+		"credentials": credentials}
+}
+
+func issuePasswordGrantToken(t *testing.T, app *Application, realm string, userName string, password string) *httptest.ResponseRecorder {
+	form := url.Values{}
+	form.Set("client_id", testClient1)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("grant_type", globals.PasswordGrantType)
+	form.Set("scope", globals.OpenIdScope)
+	form.Set("username", userName)
+	form.Set("password", password)
+	return doFormRequest(t, app, "/auth/realms/"+realm+"/protocol/openid-connect/token", form, nil)
+}
@@ -0,0 +1,59 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+)
+
+func TestExpiredPasswordLogin(t *testing.T) {
+	serverData := data.ServerData{
+		Realms: []data.Realm{
+			{Name: testPasswordHashingRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+				PasswordPolicy: &data.PasswordPolicy{MaxAge: 30},
+				Clients: []data.Client{
+					{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+				},
+				Users: []interface{}{
+					createTestHashingUser("expired_user", "9a1d1b0c-3f0e-4a4e-8d7e-1f2a3b4c5d01", map[string]interface{}{
+						"password": testAuthUserPassword, "changed": time.Now().AddDate(0, 0, -31).Unix()}),
+					createTestHashingUser("actual_user", "9a1d1b0c-3f0e-4a4e-8d7e-1f2a3b4c5d02", map[string]interface{}{
+						"password": testAuthUserPassword, "changed": time.Now().AddDate(0, 0, -29).Unix()}),
+					// password change time is unknown, such password never expires
+					createTestHashingUser("legacy_user", "9a1d1b0c-3f0e-4a4e-8d7e-1f2a3b4c5d03", map[string]interface{}{
+						"password": testAuthUserPassword}),
+				},
+			},
+		},
+	}
+	app := createTestApp(t, &serverData)
+
+	testCases := []struct {
+		name           string
+		userName       string
+		expectedStatus int
+	}{
+		{name: "expired_password", userName: "expired_user", expectedStatus: http.StatusUnauthorized},
+		{name: "actual_password", userName: "actual_user", expectedStatus: http.StatusOK},
+		{name: "password_without_change_time", userName: "legacy_user", expectedStatus: http.StatusOK},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			response := issuePasswordGrantToken(t, app, testPasswordHashingRealm, tc.userName, testAuthUserPassword)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			if tc.expectedStatus != http.StatusOK {
+				var errDetails dto.ErrorDetails
+				require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+				assert.Equal(t, errors.PasswordExpiredDesc, errDetails.Description)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const testParRealm = "parrealm"
+const testParClient = "par-client"
+const testRedirectUri = "https://app.example.com/callback"
+
+var testParServerData = data.ServerData{
+	Realms: []data.Realm{
+		{Name: testParRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+			Clients: []data.Client{
+				{Name: testParClient, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets,
+					Value: testClient1Secret}, RedirectUris: []string{testRedirectUri}, RequirePar: true},
+			}, Users: []interface{}{},
+		},
+	},
+}
+
+func TestPushedAuthorizationRequest(t *testing.T) {
+	app := createTestApp(t, &testParServerData)
+	parUrl := "/realms/" + testParRealm + "/protocol/openid-connect/ext/par/request"
+	testCases := []struct {
+		name           string
+		clientSecret   string
+		redirectUri    string
+		requestUri     string
+		expectedStatus int
+		expectedDesc   string
+	}{
+		{name: "valid_request", clientSecret: testClient1Secret, redirectUri: testRedirectUri, expectedStatus: http.StatusCreated},
+		{name: "wrong_client_secret", clientSecret: "wrongSecret", redirectUri: testRedirectUri, expectedStatus: http.StatusUnauthorized,
+			expectedDesc: errors.InvalidClientCredentialDesc},
+		{name: "unregistered_redirect_uri", clientSecret: testClient1Secret, redirectUri: "https://evil.example.com",
+			expectedStatus: http.StatusBadRequest, expectedDesc: errors.InvalidRedirectUriDesc},
+		{name: "request_uri_in_par", clientSecret: testClient1Secret, redirectUri: testRedirectUri,
+			requestUri: globals.PushedAuthorizationRequestUriPrefix + "1", expectedStatus: http.StatusBadRequest,
+			expectedDesc: errors.RequestUriNotAllowedDesc},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Set("client_id", testParClient)
+			form.Set("client_secret", tc.clientSecret)
+			form.Set("response_type", globals.CodeResponseType)
+			form.Set("redirect_uri", tc.redirectUri)
+			form.Set("scope", globals.OpenIdScope)
+			form.Set("state", "xyz")
+			if len(tc.requestUri) > 0 {
+				form.Set("request_uri", tc.requestUri)
+			}
+			response := doFormRequest(t, app, parUrl, form, nil)
+			assert.Equal(t, tc.expectedStatus, response.Code)
+			if tc.expectedStatus != http.StatusCreated {
+				var errDetails dto.ErrorDetails
+				require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+				assert.Equal(t, tc.expectedDesc, errDetails.Description)
+				return
+			}
+			var result dto.PushedAuthorizationResult
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+			assert.True(t, strings.HasPrefix(result.RequestUri, globals.PushedAuthorizationRequestUriPrefix))
+			assert.Equal(t, globals.PushedAuthorizationRequestExpiration, result.ExpiresIn)
+			// request_uri could be used only once and only by client that pushed request
+			security := *app.webApiContext.Security
+			assert.Nil(t, security.GetPushedAuthorizationRequest(testParRealm, "other-client", result.RequestUri))
+			// request_uri read by other client remains usable by client that pushed request
+			require.NotNil(t, security.GetPushedAuthorizationRequest(testParRealm, testParClient, result.RequestUri))
+			assert.Nil(t, security.GetPushedAuthorizationRequest(testParRealm, testParClient, result.RequestUri))
+			result2 := doFormRequest(t, app, parUrl, form, nil)
+			var second dto.PushedAuthorizationResult
+			require.NoError(t, json.Unmarshal(result2.Body.Bytes(), &second))
+			pushed := security.GetPushedAuthorizationRequest(testParRealm, testParClient, second.RequestUri)
+			require.NotNil(t, pushed)
+			assert.Equal(t, "xyz", pushed.State)
+			assert.Empty(t, pushed.ClientSecret)
+			assert.Nil(t, security.GetPushedAuthorizationRequest(testParRealm, testParClient, second.RequestUri))
+		})
+	}
+}
+
+func TestOpenIdConfigurationContainsParEndpoint(t *testing.T) {
+	app := createTestApp(t, &testParServerData)
+	request := httptest.NewRequest(http.MethodGet, "/realms/"+testParRealm+"/.well-known/openid-configuration", nil)
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+	var cfg dto.OpenIdConfiguration
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &cfg))
+	assert.True(t, strings.HasSuffix(cfg.PushedAuthorizationRequestEndpoint, "/protocol/openid-connect/ext/par/request"))
+}
+
+// createTestApp creates and initializes Application without starting a listener, requests are passed directly to app.httpHandler
+func createTestApp(t *testing.T, serverData *data.ServerData) *Application {
+	return createTestAppWithConfig(t, &httpAppConfig, serverData)
+}
+
+func createTestAppWithConfig(t *testing.T, appConfig *config.AppConfig, serverData *data.ServerData) *Application {
+	app := CreateAppWithData(appConfig, serverData, testKey, true).(*Application)
+	res, err := app.Init()
+	require.True(t, res)
+	require.NoError(t, err)
+	return app
+}
+
+// doFormRequest sends POST x-www-form-urlencoded request to application handler
+func doFormRequest(t *testing.T, app *Application, path string, form url.Values, headers map[string]string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
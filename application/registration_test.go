@@ -0,0 +1,183 @@
+package application
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+)
+
+const (
+	testSelfRegistrationRealm = "registrationrealm"
+	testRegistrationPassword  = "N3wUs3rPassw0rd"
+)
+
+var registrationLinkRegex = regexp.MustCompile(`<a href="([^"]+login-actions/registration[^"]+)"`)
+
+func TestRegistrationRest(t *testing.T) {
+	app := createRegistrationTestApp(t, false, nil)
+	registrationPath := "/auth/realms/" + testSelfRegistrationRealm + "/protocol/openid-connect/ext/registrations"
+	body := `{"attributes": {"preferred_username": "newuser", "email": "newuser@example.com", "given_name": "New"}, "password": "` +
+		testRegistrationPassword + `"}`
+	response := doJsonRequest(t, app, http.MethodPost, registrationPath, body, "")
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	var result dto.RegistrationResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(t, "newuser", result.Username)
+	assert.NotEmpty(t, result.Id)
+	assert.Empty(t, result.RequiredActions)
+	// registered user gets tokens with own password
+	token := getTokenFromResponse(t, issuePasswordGrantToken(t, app, testSelfRegistrationRealm, "newuser", testRegistrationPassword))
+	assert.NotEmpty(t, token)
+
+	response = doJsonRequest(t, app, http.MethodPost, registrationPath, body, "")
+	checkErrorDetails(t, response, http.StatusConflict, errors.UsernameExistsDesc)
+
+	testCases := []struct {
+		name       string
+		attributes string
+		password   string
+	}{
+		{name: "invalid-email", attributes: `{"preferred_username": "user2", "email": "not-an-email"}`, password: testRegistrationPassword},
+		{name: "missing-email", attributes: `{"preferred_username": "user2"}`, password: testRegistrationPassword},
+		{name: "unknown-attribute", attributes: `{"preferred_username": "user2", "email": "user2@example.com", "role": "admin"}`,
+			password: testRegistrationPassword},
+		{name: "reserved-attribute", attributes: `{"preferred_username": "user2", "email": "user2@example.com", "sub": "1"}`,
+			password: testRegistrationPassword},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			body := `{"attributes": ` + tCase.attributes + `, "password": "` + tCase.password + `"}`
+			response := doJsonRequest(t, app, http.MethodPost, registrationPath, body, "")
+			require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+			var errDetails dto.ErrorDetails
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errDetails))
+			assert.Equal(t, errors.InvalidUserProfileMsg, errDetails.Msg)
+		})
+	}
+	// password policy is applied to registered user password
+	body = `{"attributes": {"preferred_username": "user3", "email": "user3@example.com"}, "password": "short"}`
+	response = doJsonRequest(t, app, http.MethodPost, registrationPath, body, "")
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// realm without registration_allowed refuses registration
+	response = doJsonRequest(t, app, http.MethodPost, "/auth/realms/"+testLoginRealm+"/protocol/openid-connect/ext/registrations", body, "")
+	checkErrorDetails(t, response, http.StatusForbidden, errors.RegistrationNotAllowedDesc)
+}
+
+func TestBrowserRegistration(t *testing.T) {
+	app := createRegistrationTestApp(t, false, nil)
+	params := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType}, "state": {"st1"},
+		"redirect_uri": {testLoginRedirectUri}, "scope": {globals.OpenIdScope}}
+	response := doRegistrationAuthorizationRequest(app, params)
+	require.Equal(t, http.StatusOK, response.Code)
+	match := registrationLinkRegex.FindStringSubmatch(response.Body.String())
+	require.Len(t, match, 2, response.Body.String())
+	request := httptest.NewRequest(http.MethodGet, html.UnescapeString(match[1]), nil)
+	page := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(page, request)
+	require.Equal(t, http.StatusOK, page.Code, page.Body.String())
+	assert.Contains(t, page.Body.String(), `name="password_confirm"`)
+	assert.Contains(t, page.Body.String(), `name="given_name"`)
+
+	// mismatched passwords show form again with entered attributes
+	form := url.Values{"preferred_username": {"browseruser"}, "email": {"browser@example.com"}, "password": {testRegistrationPassword},
+		"password_confirm": {"other"}}
+	page = submitRegistrationForm(t, app, page, form)
+	require.Equal(t, http.StatusBadRequest, page.Code)
+	assert.Contains(t, page.Body.String(), `value="browser@example.com"`)
+
+	form.Set("password_confirm", testRegistrationPassword)
+	response = submitRegistrationForm(t, app, page, form)
+	assert.Equal(t, "st1", getRedirectParams(t, response, testLoginRedirectUri).Get("state"))
+	assert.NotEmpty(t, getIdentityCookie(t, response).Value)
+
+	// prompt=create opens registration form instead of login form, realm without registration shows login form
+	response = doRegistrationAuthorizationRequest(app, copyValues(params, "prompt", globals.CreatePrompt))
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `name="password_confirm"`)
+	response = doAuthorizationRequest(createLoginTestApp(t), copyValues(params, "prompt", globals.CreatePrompt), nil)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.NotContains(t, response.Body.String(), `name="password_confirm"`)
+}
+
+func TestRegistrationWithEmailVerification(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.json")
+	app := createRegistrationTestApp(t, true, &mailsFile)
+	registrationPath := "/realms/" + testSelfRegistrationRealm + "/protocol/openid-connect/ext/registrations"
+	body := `{"attributes": {"preferred_username": "verifyuser", "email": "verify@example.com"}, "password": "` + testRegistrationPassword + `"}`
+	response := doJsonRequest(t, app, http.MethodPost, registrationPath, body, "")
+	require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+	var result dto.RegistrationResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(t, []string{data.VerifyEmailAction}, result.RequiredActions)
+	message := readLastMail(t, mailsFile)
+	assert.Equal(t, "verify@example.com", message.To)
+	getMailLink(t, message)
+	// user can't get tokens until email is verified
+	response = issuePasswordGrantToken(t, app, testSelfRegistrationRealm, "verifyuser", testRegistrationPassword)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+
+	// browser registration shows info page instead of redirect
+	params := url.Values{"client_id": {testLoginPublicClient}, "response_type": {globals.CodeResponseType},
+		"redirect_uri": {testLoginRedirectUri}, "scope": {globals.OpenIdScope}, "prompt": {globals.CreatePrompt}}
+	page := doRegistrationAuthorizationRequest(app, params)
+	require.Equal(t, http.StatusOK, page.Code)
+	form := url.Values{"preferred_username": {"browserverify"}, "email": {"browserverify@example.com"},
+		"password": {testRegistrationPassword}, "password_confirm": {testRegistrationPassword}}
+	response = submitRegistrationForm(t, app, page, form)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Contains(t, response.Body.String(), "Account browserverify was created")
+	assert.Empty(t, response.Result().Cookies())
+}
+
+func createRegistrationTestApp(t *testing.T, verifyEmail bool, mailsFile *string) *Application {
+	realm := data.Realm{Name: testSelfRegistrationRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		RegistrationAllowed: true, VerifyEmail: verifyEmail, PasswordPolicy: &data.PasswordPolicy{MinLength: 10},
+		Clients: []data.Client{
+			{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+			{Name: testLoginPublicClient, Type: data.Public, RedirectUris: []string{testLoginRedirectUri}},
+		},
+		Users: []interface{}{},
+	}
+	loginRealm := data.Realm{Name: testLoginRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		Clients: []data.Client{{Name: testLoginPublicClient, Type: data.Public, RedirectUris: []string{testLoginRedirectUri}}},
+		Users:   []interface{}{},
+	}
+	serverData := data.ServerData{Realms: []data.Realm{realm, loginRealm}}
+	if mailsFile == nil {
+		return createTestApp(t, &serverData)
+	}
+	serverData.Realms[0].Email = &data.EmailSettings{From: "noreply@ferrum.test"}
+	appConfig := httpAppConfig
+	appConfig.Mail = &config.MailConfig{Sender: config.FileMailSender, Destination: *mailsFile, LinkBaseUrl: testEmailLinkBase}
+	return createTestAppWithConfig(t, &appConfig, &serverData)
+}
+
+func doRegistrationAuthorizationRequest(app *Application, params url.Values) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/auth/realms/"+testSelfRegistrationRealm+"/protocol/openid-connect/auth?"+params.Encode(), nil)
+	response := httptest.NewRecorder()
+	(*app.httpHandler).ServeHTTP(response, request)
+	return response
+}
+
+// submitRegistrationForm posts form values to registration form that page contains
+func submitRegistrationForm(t *testing.T, app *Application, page *httptest.ResponseRecorder, form url.Values) *httptest.ResponseRecorder {
+	match := requestUriRegex.FindStringSubmatch(page.Body.String())
+	require.Len(t, match, 2, page.Body.String())
+	values := copyValues(form, "request_uri", match[1])
+	values.Set("client_id", testLoginPublicClient)
+	return doFormRequest(t, app, "/auth/realms/"+testSelfRegistrationRealm+"/login-actions/registration", values, nil)
+}
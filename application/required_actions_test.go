@@ -0,0 +1,137 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+)
+
+func TestRequiredActionsBlockLogin(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.jsonl")
+	app := createEmailTestApp(t, mailsFile, &data.EmailSettings{From: "noreply@ferrum.test"})
+	setUserRequiredActions(t, app, []string{data.UpdatePasswordAction, data.TermsAndConditionsAction, data.ConfigureTotpAction,
+		data.VerifyEmailAction})
+
+	response := issuePasswordGrantToken(t, app, testEmailRealm, testAuthUser, testAuthUserPassword)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	var errorDetails dto.ErrorDetails
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &errorDetails))
+	assert.Equal(t, errors.AccountNotSetUpDesc, errorDetails.Description)
+
+	// user must authenticate to execute action
+	response = executeRequiredAction(t, app, testAuthUserPassword+"1", "", url.Values{"action": {data.TermsAndConditionsAction}})
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = executeRequiredAction(t, app, testAuthUserPassword, "", url.Values{"action": {data.TermsAndConditionsAction}})
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.TermsNotAcceptedDesc)
+	result := checkRequiredActionResult(t, executeRequiredAction(t, app, testAuthUserPassword, "",
+		url.Values{"action": {data.TermsAndConditionsAction}, "accept": {"true"}}), http.StatusOK)
+	assert.Equal(t, []string{data.UpdatePasswordAction, data.ConfigureTotpAction, data.VerifyEmailAction}, result.RequiredActions)
+	response = executeRequiredAction(t, app, testAuthUserPassword, "", url.Values{"action": {data.TermsAndConditionsAction}, "accept": {"true"}})
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.ActionNotRequiredDesc)
+
+	// new password must satisfy realm password policy
+	response = executeRequiredAction(t, app, testAuthUserPassword, "", url.Values{"action": {data.UpdatePasswordAction}, "new_password": {"short"}})
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	checkRequiredActionResult(t, executeRequiredAction(t, app, testAuthUserPassword, "",
+		url.Values{"action": {data.UpdatePasswordAction}, "new_password": {testNewPassword}}), http.StatusOK)
+
+	result = checkRequiredActionResult(t, executeRequiredAction(t, app, testNewPassword, "",
+		url.Values{"action": {data.ConfigureTotpAction}}), http.StatusOK)
+	assert.NotEmpty(t, result.OtpUri)
+	assert.NotEmpty(t, result.RecoveryCodes)
+	recoveryCodes := result.RecoveryCodes
+
+	// VERIFY_EMAIL sends link, action is satisfied when user opens link
+	result = checkRequiredActionResult(t, executeRequiredAction(t, app, testNewPassword, recoveryCodes[0],
+		url.Values{"action": {data.VerifyEmailAction}}), http.StatusAccepted)
+	assert.Equal(t, []string{data.VerifyEmailAction}, result.RequiredActions)
+	response = doLinkRequest(app, http.MethodGet, getMailLink(t, readLastMail(t, mailsFile)), nil)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	form := url.Values{"client_id": {testClient1}, "client_secret": {testClient1Secret}, "grant_type": {"password"},
+		"username": {testAuthUser}, "password": {testNewPassword}, "totp": {recoveryCodes[1]}}
+	response = doFormRequest(t, app, "/auth/realms/"+testEmailRealm+"/protocol/openid-connect/token", form, nil)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+}
+
+func TestEmailLinkSatisfiesRequiredAction(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.jsonl")
+	app := createEmailTestApp(t, mailsFile, &data.EmailSettings{From: "noreply@ferrum.test"})
+	setUserRequiredActions(t, app, []string{data.UpdatePasswordAction})
+
+	response := doFormRequest(t, app, "/auth/realms/"+testEmailRealm+"/login-actions/reset-credentials", url.Values{"username": {testAuthUser}}, nil)
+	require.Equal(t, http.StatusNoContent, response.Code)
+	response = doLinkRequest(app, http.MethodPost, getMailLink(t, readLastMail(t, mailsFile)), url.Values{"password": {testNewPassword}})
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	assert.Equal(t, http.StatusOK, issuePasswordGrantToken(t, app, testEmailRealm, testAuthUser, testNewPassword).Code)
+}
+
+func TestRealmOtpPolicyRequiresOtpConfiguration(t *testing.T) {
+	mailsFile := filepath.Join(t.TempDir(), "mails.jsonl")
+	app := createEmailTestApp(t, mailsFile, &data.EmailSettings{From: "noreply@ferrum.test"})
+	realm, err := (*app.dataProvider).GetRealm(testEmailRealm)
+	require.NoError(t, err)
+	realm.OtpPolicy = &data.OtpPolicy{Required: true}
+	require.NoError(t, (*app.dataProvider).UpdateRealm(testEmailRealm, *realm))
+
+	// user has no CONFIGURE_TOTP action, OTP is required by realm policy
+	checkErrorDetails(t, issuePasswordGrantToken(t, app, testEmailRealm, testAuthUser, testAuthUserPassword), http.StatusUnauthorized,
+		errors.OtpNotConfiguredDesc)
+	response := executeRequiredAction(t, app, testAuthUserPassword, "", url.Values{"action": {data.TermsAndConditionsAction}, "accept": {"true"}})
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.OtpNotConfiguredDesc)
+	// disabled user can't configure OTP
+	updateRequiredActionsUser(t, app, func(user data.User) { require.NoError(t, user.SetEnabled(false)) })
+	response = executeRequiredAction(t, app, testAuthUserPassword, "", url.Values{"action": {data.ConfigureTotpAction}})
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.UserDisabledDesc)
+	updateRequiredActionsUser(t, app, func(user data.User) { require.NoError(t, user.SetEnabled(true)) })
+
+	result := checkRequiredActionResult(t, executeRequiredAction(t, app, testAuthUserPassword, "",
+		url.Values{"action": {data.ConfigureTotpAction}}), http.StatusOK)
+	assert.NotEmpty(t, result.OtpUri)
+	require.NotEmpty(t, result.RecoveryCodes)
+	response = executeRequiredAction(t, app, testAuthUserPassword, result.RecoveryCodes[0], url.Values{"action": {data.ConfigureTotpAction}})
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.ActionNotRequiredDesc)
+	form := url.Values{"client_id": {testClient1}, "client_secret": {testClient1Secret}, "grant_type": {"password"},
+		"username": {testAuthUser}, "password": {testAuthUserPassword}, "totp": {result.RecoveryCodes[1]}}
+	response = doFormRequest(t, app, "/auth/realms/"+testEmailRealm+"/protocol/openid-connect/token", form, nil)
+	assert.Equal(t, http.StatusOK, response.Code, response.Body.String())
+}
+
+func setUserRequiredActions(t *testing.T, app *Application, actions []string) {
+	updateRequiredActionsUser(t, app, func(user data.User) { require.NoError(t, user.SetRequiredActions(actions)) })
+}
+
+func updateRequiredActionsUser(t *testing.T, app *Application, update func(user data.User)) {
+	user, err := (*app.dataProvider).GetUser(testEmailRealm, testAuthUser)
+	require.NoError(t, err)
+	update(user)
+	require.NoError(t, (*app.dataProvider).UpdateUser(testEmailRealm, testAuthUser, user))
+}
+
+func executeRequiredAction(t *testing.T, app *Application, password string, totp string, form url.Values) *httptest.ResponseRecorder {
+	form.Set("client_id", testClient1)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("username", testAuthUser)
+	form.Set("password", password)
+	if len(totp) > 0 {
+		form.Set("totp", totp)
+	}
+	return doFormRequest(t, app, "/auth/realms/"+testEmailRealm+"/login-actions/required-action", form, nil)
+}
+
+func checkRequiredActionResult(t *testing.T, response *httptest.ResponseRecorder, expectedStatus int) dto.RequiredActionResult {
+	require.Equal(t, expectedStatus, response.Code, response.Body.String())
+	var result dto.RequiredActionResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.Equal(t, testAuthUser, result.Username)
+	return result
+}
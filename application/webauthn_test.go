@@ -0,0 +1,255 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	"github.com/wissance/Ferrum/utils/webauthn"
+	"github.com/wissance/Ferrum/utils/webauthn/webauthntest"
+)
+
+const (
+	testWebAuthnRealm  = "webauthnrealm"
+	testWebAuthnRpId   = "ferrum.test"
+	testWebAuthnOrigin = "https://ferrum.test"
+)
+
+func createWebAuthnServerData(settings *data.WebAuthnSettings) *data.ServerData {
+	realm := data.Realm{Name: testWebAuthnRealm, TokenExpiration: testAccessTokenExpiration, RefreshTokenExpiration: testRefreshTokenExpiration,
+		WebAuthn: settings,
+		Clients: []data.Client{
+			{Name: testClient1, Type: data.Confidential, Auth: data.Authentication{Type: data.ClientIdAndSecrets, Value: testClient1Secret}},
+		},
+		Users: []interface{}{
+			createTestHashingUser(testAuthUser, "667ff6a7-3f6b-449b-a217-6fc5d9ac0901", map[string]interface{}{"password": testAuthUserPassword}),
+		},
+	}
+	return &data.ServerData{Realms: []data.Realm{realm}}
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	app := createTestApp(t, createWebAuthnServerData(&data.WebAuthnSettings{RpId: testWebAuthnRpId, Origins: []string{testWebAuthnOrigin},
+		UserVerification: data.RequiredUserVerification}))
+	authenticator := webauthntest.NewAuthenticator(testWebAuthnRpId, testWebAuthnOrigin)
+
+	// registration without access token is not allowed
+	response := doJsonRequest(t, app, http.MethodPost, getWebAuthnPath("register/options"), "", "")
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	accessToken := getWebAuthnUserToken(t, app)
+	credential := registerPasskey(t, app, authenticator, accessToken, "laptop")
+	require.Equal(t, http.StatusCreated, credential.Code)
+	var descriptor dto.PublicKeyCredentialDescriptor
+	require.NoError(t, json.Unmarshal(credential.Body.Bytes(), &descriptor))
+	user, err := (*app.dataProvider).GetUser(testWebAuthnRealm, testAuthUser)
+	require.NoError(t, err)
+	storedCredentials := user.GetWebAuthnCredentials()
+	require.Len(t, storedCredentials, 1)
+	assert.Equal(t, descriptor.Id, storedCredentials[0].Id)
+	assert.Equal(t, "laptop", storedCredentials[0].Name)
+	assert.Equal(t, webauthn.AlgorithmES256, storedCredentials[0].Algorithm)
+
+	// passkey with username, allowCredentials contains registered passkey
+	options := startPasskeyLogin(t, app, `{"username": "`+testAuthUser+`"}`)
+	require.Len(t, options.AllowCredentials, 1)
+	assert.Equal(t, descriptor.Id, options.AllowCredentials[0].Id)
+	assert.Equal(t, testWebAuthnRpId, options.RpId)
+	assertion, err := authenticator.Login(options.Challenge, descriptor.Id)
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	assert.NotEmpty(t, token.AccessToken)
+
+	// replayed assertion: challenge was already used
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+
+	// discoverable passkey login without username
+	options = startPasskeyLogin(t, app, "")
+	assert.Empty(t, options.AllowCredentials)
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	require.Equal(t, http.StatusOK, response.Code)
+	user, err = (*app.dataProvider).GetUser(testWebAuthnRealm, testAuthUser)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), user.GetWebAuthnCredentials()[0].SignCount)
+
+	// cloned authenticator: signature counter doesn't grow
+	authenticator.SetSignCount(descriptor.Id, 0)
+	options = startPasskeyLogin(t, app, "")
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+
+	// assertion from page of another origin
+	authenticator.SetSignCount(descriptor.Id, 10)
+	authenticator.Origin = "https://evil.test"
+	options = startPasskeyLogin(t, app, "")
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+
+	// realm requires user verification
+	authenticator.Origin = testWebAuthnOrigin
+	authenticator.UserVerified = false
+	options = startPasskeyLogin(t, app, "")
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+}
+
+func TestWebAuthnRegistrationFails(t *testing.T) {
+	app := createTestApp(t, createWebAuthnServerData(&data.WebAuthnSettings{RpId: testWebAuthnRpId, Origins: []string{testWebAuthnOrigin}}))
+	accessToken := getWebAuthnUserToken(t, app)
+
+	// authenticator created credential for another relying party
+	authenticator := webauthntest.NewAuthenticator("evil.test", testWebAuthnOrigin)
+	response := registerPasskey(t, app, authenticator, accessToken, "")
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidWebAuthnResponseDesc)
+
+	// the same passkey can't be registered twice
+	authenticator = webauthntest.NewAuthenticator(testWebAuthnRpId, testWebAuthnOrigin)
+	options := startPasskeyRegistration(t, app, accessToken)
+	userHandle, err := webauthn.DecodeBase64Url(options.User.Id)
+	require.NoError(t, err)
+	registration, err := authenticator.Register(options.Challenge, userHandle)
+	require.NoError(t, err)
+	response = finishPasskeyRegistration(t, app, accessToken, registration, "")
+	require.Equal(t, http.StatusCreated, response.Code)
+	options = startPasskeyRegistration(t, app, accessToken)
+	require.Len(t, options.ExcludeCredentials, 1)
+	response = finishPasskeyRegistration(t, app, accessToken, registration, "")
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.InvalidWebAuthnResponseDesc)
+}
+
+func TestWebAuthnFailedAssertionsLockUser(t *testing.T) {
+	serverData := createWebAuthnServerData(&data.WebAuthnSettings{RpId: testWebAuthnRpId, Origins: []string{testWebAuthnOrigin}})
+	serverData.Realms[0].BruteForceProtection = &data.BruteForceProtection{MaxLoginFailures: 2, WaitIncrement: 60}
+	app := createTestApp(t, serverData)
+	authenticator := webauthntest.NewAuthenticator(testWebAuthnRpId, testWebAuthnOrigin)
+	credential := registerPasskey(t, app, authenticator, getWebAuthnUserToken(t, app), "")
+	require.Equal(t, http.StatusCreated, credential.Code)
+	var descriptor dto.PublicKeyCredentialDescriptor
+	require.NoError(t, json.Unmarshal(credential.Body.Bytes(), &descriptor))
+	options := startPasskeyLogin(t, app, "")
+	assertion, err := authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response := issueWebAuthnGrantToken(t, app, assertion)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	// wrong signature and not growing signature counter are counted as login failures
+	options = startPasskeyLogin(t, app, "")
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	signature, err := webauthn.DecodeBase64Url(assertion.Response.Signature)
+	require.NoError(t, err)
+	signature[len(signature)-1] ^= 0xff
+	assertion.Response.Signature = webauthn.EncodeBase64Url(signature)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+	failures, err := (*app.dataProvider).GetLoginFailures(testWebAuthnRealm, data.UserLoginFailuresKey(testAuthUser))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures)
+
+	authenticator.SetSignCount(descriptor.Id, 0)
+	options = startPasskeyLogin(t, app, "")
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+	failures, err = (*app.dataProvider).GetLoginFailures(testWebAuthnRealm, data.UserLoginFailuresKey(testAuthUser))
+	require.NoError(t, err)
+	assert.True(t, failures.IsLocked(time.Now()))
+
+	// valid assertion of locked user is rejected
+	authenticator.SetSignCount(descriptor.Id, 10)
+	options = startPasskeyLogin(t, app, "")
+	assertion, err = authenticator.Login(options.Challenge, "")
+	require.NoError(t, err)
+	response = issueWebAuthnGrantToken(t, app, assertion)
+	checkErrorDetails(t, response, http.StatusUnauthorized, errors.InvalidWebAuthnResponseDesc)
+}
+
+func TestWebAuthnNotEnabled(t *testing.T) {
+	app := createTestApp(t, createWebAuthnServerData(nil))
+	response := doJsonRequest(t, app, http.MethodPost, getWebAuthnPath("login/options"), "", "")
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.WebAuthnNotEnabledDesc)
+	response = doJsonRequest(t, app, http.MethodPost, getWebAuthnPath("register/options"), "", getWebAuthnUserToken(t, app))
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.WebAuthnNotEnabledDesc)
+	response = issueWebAuthnGrantToken(t, app, &webauthntest.PublicKeyCredential{Id: "AAAA"})
+	checkErrorDetails(t, response, http.StatusBadRequest, errors.WebAuthnNotEnabledDesc)
+}
+
+func getWebAuthnPath(path string) string {
+	return "/auth/realms/" + testWebAuthnRealm + "/protocol/openid-connect/ext/webauthn/" + path
+}
+
+func getWebAuthnUserToken(t *testing.T, app *Application) string {
+	response := issuePasswordGrantToken(t, app, testWebAuthnRealm, testAuthUser, testAuthUserPassword)
+	require.Equal(t, http.StatusOK, response.Code)
+	var token dto.Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	return token.AccessToken
+}
+
+func registerPasskey(t *testing.T, app *Application, authenticator *webauthntest.Authenticator, accessToken string,
+	name string) *httptest.ResponseRecorder {
+	options := startPasskeyRegistration(t, app, accessToken)
+	assert.Equal(t, testWebAuthnRpId, options.Rp.Id)
+	userHandle, err := webauthn.DecodeBase64Url(options.User.Id)
+	require.NoError(t, err)
+	registration, err := authenticator.Register(options.Challenge, userHandle)
+	require.NoError(t, err)
+	return finishPasskeyRegistration(t, app, accessToken, registration, name)
+}
+
+func startPasskeyRegistration(t *testing.T, app *Application, accessToken string) dto.PublicKeyCredentialCreationOptions {
+	response := doJsonRequest(t, app, http.MethodPost, getWebAuthnPath("register/options"), "", accessToken)
+	require.Equal(t, http.StatusOK, response.Code)
+	var options dto.PublicKeyCredentialCreationOptions
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &options))
+	return options
+}
+
+func finishPasskeyRegistration(t *testing.T, app *Application, accessToken string, credential *webauthntest.PublicKeyCredential,
+	name string) *httptest.ResponseRecorder {
+	body, err := json.Marshal(map[string]interface{}{"name": name, "credential": credential})
+	require.NoError(t, err)
+	return doJsonRequest(t, app, http.MethodPost, getWebAuthnPath("register"), string(body), accessToken)
+}
+
+func startPasskeyLogin(t *testing.T, app *Application, body string) dto.PublicKeyCredentialRequestOptions {
+	response := doJsonRequest(t, app, http.MethodPost, getWebAuthnPath("login/options"), body, "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var options dto.PublicKeyCredentialRequestOptions
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &options))
+	return options
+}
+
+func issueWebAuthnGrantToken(t *testing.T, app *Application, assertion *webauthntest.PublicKeyCredential) *httptest.ResponseRecorder {
+	encodedAssertion, err := json.Marshal(assertion)
+	require.NoError(t, err)
+	form := url.Values{}
+	form.Set("client_id", testClient1)
+	form.Set("client_secret", testClient1Secret)
+	form.Set("grant_type", globals.WebAuthnGrantType)
+	form.Set("scope", globals.OpenIdScope)
+	form.Set("webauthn_assertion", string(encodedAssertion))
+	return doFormRequest(t, app, "/auth/realms/"+testWebAuthnRealm+"/protocol/openid-connect/token", form, nil)
+}
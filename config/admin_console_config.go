@@ -0,0 +1,21 @@
+package config
+
+// DefaultAdminConsoleClientId is a client of admin realm that admin console logs in with if client_id is not set (KeyCloak
+// admin CLI client name)
+const DefaultAdminConsoleClientId = "admin-cli"
+
+// AdminConsoleConfig is an admin web console settings, console is served (embedded in binary) only if this section is present
+/* ClientId is a public client of admin realm that console uses to get administrator tokens with password grant, console is
+ * served by admin listener if it is configured (token endpoint of admin realm is also served by admin listener then)
+ */
+type AdminConsoleConfig struct {
+	ClientId string `json:"client_id" example:"admin-cli"`
+}
+
+// GetClientId returns client that admin console logs in with
+func (cfg *AdminConsoleConfig) GetClientId() string {
+	if len(cfg.ClientId) == 0 {
+		return DefaultAdminConsoleClientId
+	}
+	return cfg.ClientId
+}
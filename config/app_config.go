@@ -11,12 +11,19 @@ const (
 	serverValidationErrExitCode        = 567
 	dataSourceValidationErrExitCode    = 568
 	loggingSystemValidationErrExitCode = 569
+	cibaValidationErrExitCode          = 570
+	mailValidationErrExitCode          = 571
+	uiValidationErrExitCode            = 572
 )
 
 type AppConfig struct {
-	ServerCfg  ServerConfig     `json:"server"`
-	DataSource DataSourceConfig `json:"data_source"`
-	Logging    LoggingConfig    `json:"logging"`
+	ServerCfg    ServerConfig        `json:"server"`
+	DataSource   DataSourceConfig    `json:"data_source"`
+	Logging      LoggingConfig       `json:"logging"`
+	Ciba         *CibaConfig         `json:"ciba"`
+	Mail         *MailConfig         `json:"mail"`
+	Ui           *UiConfig           `json:"ui"`
+	AdminConsole *AdminConsoleConfig `json:"admin_console"`
 }
 
 func ReadAppConfig(pathToConfig string) (*AppConfig, error) {
@@ -54,4 +61,25 @@ func (cfg *AppConfig) Validate() {
 		println(loggingSystemCfgValidationErr.Error())
 		os.Exit(loggingSystemValidationErrExitCode)
 	}
+	if cfg.Ciba != nil {
+		cibaCfgValidationErr := cfg.Ciba.Validate()
+		if cibaCfgValidationErr != nil {
+			println(cibaCfgValidationErr.Error())
+			os.Exit(cibaValidationErrExitCode)
+		}
+	}
+	if cfg.Mail != nil {
+		mailCfgValidationErr := cfg.Mail.Validate()
+		if mailCfgValidationErr != nil {
+			println(mailCfgValidationErr.Error())
+			os.Exit(mailValidationErrExitCode)
+		}
+	}
+	if cfg.Ui != nil {
+		uiCfgValidationErr := cfg.Ui.Validate()
+		if uiCfgValidationErr != nil {
+			println(uiCfgValidationErr.Error())
+			os.Exit(uiValidationErrExitCode)
+		}
+	}
 }
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+type AuthenticationDeviceNotifierType string
+
+const (
+	// HttpDeviceNotifier sends authentication request to an external service (i.e. push notification gateway) via HTTP POST
+	HttpDeviceNotifier AuthenticationDeviceNotifierType = "http"
+	// FileDeviceNotifier appends authentication requests to a file, it is a stand-in for tests and local development
+	FileDeviceNotifier AuthenticationDeviceNotifierType = "file"
+)
+
+const (
+	defaultCibaExpiration   = 120
+	defaultCibaPollInterval = 5
+)
+
+// CibaConfig is a Client-Initiated Backchannel Authentication settings, if this section is absent CIBA is disabled
+/* Notifier delivers authentication request to the user authentication device, Destination is an url (HttpDeviceNotifier)
+ * or path to file (FileDeviceNotifier). Expiration is auth_req_id lifetime in seconds, Interval is a minimal poll interval in seconds
+ */
+type CibaConfig struct {
+	Notifier    AuthenticationDeviceNotifierType `json:"notifier" example:"http or file"`
+	Destination string                           `json:"destination" example:"http://127.0.0.1:8585/ciba/notify"`
+	Expiration  int                              `json:"expiration"`
+	Interval    int                              `json:"interval"`
+}
+
+func (cfg *CibaConfig) Validate() error {
+	if cfg.Notifier != HttpDeviceNotifier && cfg.Notifier != FileDeviceNotifier {
+		return errors.New(sf.Format("ciba notifier type \"{0}\" is not supported", cfg.Notifier))
+	}
+	if len(cfg.Destination) == 0 {
+		return errors.New("ciba notifier destination wasn't set")
+	}
+	if cfg.Expiration <= 0 {
+		cfg.Expiration = defaultCibaExpiration
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultCibaPollInterval
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"errors"
+	"strings"
+
+	sf "github.com/wissance/stringFormatter"
+)
+
+type MailSenderType string
+
+const (
+	// SmtpMailSender sends emails via SMTP server from realm email settings
+	SmtpMailSender MailSenderType = "smtp"
+	// FileMailSender appends emails to a file, it is a stand-in for tests and local development
+	FileMailSender MailSenderType = "file"
+)
+
+// MailConfig is an outbound email settings, if this section is absent email-based flows (verify email, reset password and
+// execute actions emails) are disabled
+/* Sender is a way of delivery, SMTP server address and sender address are taken from realm email settings, Destination is a path
+ * to file (FileMailSender). LinkBaseUrl is a public Ferrum url (i.e. https://auth.example.com) that is used in links of emails,
+ * server schema, address and port are used if it is not set
+ */
+type MailConfig struct {
+	Sender      MailSenderType `json:"sender" example:"smtp or file"`
+	Destination string         `json:"destination" example:"./mails.json"`
+	LinkBaseUrl string         `json:"link_base_url" example:"https://auth.example.com"`
+}
+
+func (cfg *MailConfig) Validate() error {
+	if cfg.Sender != SmtpMailSender && cfg.Sender != FileMailSender {
+		return errors.New(sf.Format("mail sender type \"{0}\" is not supported", cfg.Sender))
+	}
+	if cfg.Sender == FileMailSender && len(cfg.Destination) == 0 {
+		return errors.New("mail sender destination wasn't set")
+	}
+	return nil
+}
+
+// GetLinkBaseUrl returns base url of links in emails (LinkBaseUrl or url of server listener)
+func (cfg *MailConfig) GetLinkBaseUrl(serverCfg *ServerConfig) string {
+	if len(cfg.LinkBaseUrl) > 0 {
+		return strings.TrimRight(cfg.LinkBaseUrl, "/")
+	}
+	return sf.Format("{0}://{1}:{2}", serverCfg.Schema, serverCfg.Address, serverCfg.Port)
+}
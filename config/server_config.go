@@ -13,17 +13,79 @@ const (
 	HTTPS Schema = "https"
 )
 
+// ClientCertificateMode is a mode of client certificate (mutual TLS, RFC 8705) request on HTTPS listener
+type ClientCertificateMode string
+
+const (
+	NoClientCertificate       ClientCertificateMode = "none"
+	OptionalClientCertificate ClientCertificateMode = "optional"
+	RequiredClientCertificate ClientCertificateMode = "required"
+)
+
+// SecurityConfig is a HTTPS listener config, CertificateFile and KeyFile is a server certificate pair
+/* ClientCertificate enables mutual TLS: in optional mode server requests client certificate, in required mode connection without
+ * certificate is refused. Certificate chain is not verified on handshake because self-signed certificates are allowed
+ * (self_signed_tls_client_auth), tls_client_auth certificates are verified with CA certificates from ClientCaFile (system
+ * pool is used if ClientCaFile is empty)
+ */
 type SecurityConfig struct {
-	CertificateFile string `json:"certificate_file" example:"./certificates/server.crt"`
-	KeyFile         string `json:"key_file" example:"./certificates/server.key"`
+	CertificateFile   string                `json:"certificate_file" example:"./certificates/server.crt"`
+	KeyFile           string                `json:"key_file" example:"./certificates/server.key"`
+	ClientCertificate ClientCertificateMode `json:"client_certificate" example:"none, optional or required"`
+	ClientCaFile      string                `json:"client_ca_file" example:"./certificates/ca.crt"`
 }
 
+// IsMutualTlsEnabled checks whether server requests client certificates
+func (cfg *SecurityConfig) IsMutualTlsEnabled() bool {
+	return cfg.ClientCertificate == OptionalClientCertificate || cfg.ClientCertificate == RequiredClientCertificate
+}
+
+// DefaultAdminRealm is a realm which users manage server via Admin REST API if ServerConfig AdminRealm is not set (KeyCloak uses the same)
+const DefaultAdminRealm = "master"
+
+// ServerConfig is a main HTTP(S) listener config, AdminRealm is a realm that issues tokens for Admin REST API
+/* AdminListener is an optional separate listener of admin and management endpoints (Admin REST API, health checks, swagger),
+ * if it is set these endpoints are not served by main listener, so main listener could be exposed to the internet while
+ * admin listener stays on internal interface
+ */
 type ServerConfig struct {
-	Schema     Schema          `json:"schema" example:"http or https"`
-	Address    string          `json:"address" example:"127.0.0.1 or mydomain.com"`
-	Port       int             `json:"port" example:"8080"`
-	Security   *SecurityConfig `json:"security"`
-	SecretFile string          `json:"secret_file" example:"./keyfile"`
+	Schema        Schema               `json:"schema" example:"http or https"`
+	Address       string               `json:"address" example:"127.0.0.1 or mydomain.com"`
+	Port          int                  `json:"port" example:"8080"`
+	Security      *SecurityConfig      `json:"security"`
+	SecretFile    string               `json:"secret_file" example:"./keyfile"`
+	AdminRealm    string               `json:"admin_realm" example:"master"`
+	AdminListener *AdminListenerConfig `json:"admin_listener"`
+}
+
+// AdminListenerConfig is a config of admin HTTP(S) listener with own TLS settings
+/* Unlike main listener admin listener verifies client certificates on handshake (with CA certificates from Security
+ * ClientCaFile or system pool), so client_certificate "required" allows only administrators with trusted certificates
+ */
+type AdminListenerConfig struct {
+	Schema   Schema          `json:"schema" example:"http or https"`
+	Address  string          `json:"address" example:"127.0.0.1"`
+	Port     int             `json:"port" example:"9000"`
+	Security *SecurityConfig `json:"security"`
+}
+
+// Validate checks admin listener schema, port and TLS settings
+func (cfg *AdminListenerConfig) Validate() error {
+	if cfg.Schema != HTTP && cfg.Schema != HTTPS {
+		return errors.New(sf.Format("admin listener schema \"{0}\" is not supported", cfg.Schema))
+	}
+	if cfg.Port <= 0 {
+		return errors.New("admin listener port wasn't set")
+	}
+	return validateSecurity(cfg.Schema, cfg.Security)
+}
+
+// GetAdminRealm returns realm that issues tokens for Admin REST API
+func (cfg *ServerConfig) GetAdminRealm() string {
+	if len(cfg.AdminRealm) == 0 {
+		return DefaultAdminRealm
+	}
+	return cfg.AdminRealm
 }
 
 func (cfg *ServerConfig) Validate() error {
@@ -34,19 +96,47 @@ func (cfg *ServerConfig) Validate() error {
 	if err != nil && errors.Is(err, os.ErrNotExist) {
 		return errors.New(sf.Format("secret file on path \"{0}\" does not exists", cfg.SecretFile))
 	}
-	if cfg.Schema == HTTPS {
-		if cfg.Security == nil {
+	if err = validateSecurity(cfg.Schema, cfg.Security); err != nil {
+		return err
+	}
+	if cfg.AdminListener != nil {
+		if err = cfg.AdminListener.Validate(); err != nil {
+			return err
+		}
+		if cfg.AdminListener.Address == cfg.Address && cfg.AdminListener.Port == cfg.Port {
+			return errors.New("admin listener must have address or port that differs from server address and port")
+		}
+	}
+	return nil
+}
+
+// validateSecurity checks that HTTPS listener has existing certificate pair and valid client certificate settings
+func validateSecurity(schema Schema, security *SecurityConfig) error {
+	if schema == HTTPS {
+		if security == nil {
 			return errors.New("https schema requires a certs pair (\"security\" property)")
 		}
 
-		_, keyFileErr := os.Stat(cfg.Security.KeyFile)
+		_, keyFileErr := os.Stat(security.KeyFile)
 		if keyFileErr != nil && errors.Is(keyFileErr, os.ErrNotExist) {
-			return errors.New(sf.Format("Security (certificate) config Key file \"{0}\" does not exists", cfg.Security.KeyFile))
+			return errors.New(sf.Format("Security (certificate) config Key file \"{0}\" does not exists", security.KeyFile))
 		}
 
-		_, crtFileErr := os.Stat(cfg.Security.CertificateFile)
+		_, crtFileErr := os.Stat(security.CertificateFile)
 		if crtFileErr != nil && errors.Is(crtFileErr, os.ErrNotExist) {
-			return errors.New(sf.Format("Security (certificate) config Certificate file \"{0}\" does not exists", cfg.Security.CertificateFile))
+			return errors.New(sf.Format("Security (certificate) config Certificate file \"{0}\" does not exists", security.CertificateFile))
+		}
+
+		switch security.ClientCertificate {
+		case "", NoClientCertificate, OptionalClientCertificate, RequiredClientCertificate:
+		default:
+			return errors.New(sf.Format("Security config client_certificate value \"{0}\" is not supported", security.ClientCertificate))
+		}
+		if len(security.ClientCaFile) > 0 {
+			_, caFileErr := os.Stat(security.ClientCaFile)
+			if caFileErr != nil && errors.Is(caFileErr, os.ErrNotExist) {
+				return errors.New(sf.Format("Security config client CA file \"{0}\" does not exists", security.ClientCaFile))
+			}
 		}
 	}
 	return nil
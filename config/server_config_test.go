@@ -0,0 +1,77 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateServerConfigClientCertificate(t *testing.T) {
+	certsDir := filepath.Join("..", "certs")
+	testCases := []struct {
+		name              string
+		clientCertificate ClientCertificateMode
+		clientCaFile      string
+		expectError       bool
+		mutualTls         bool
+	}{
+		{name: "without_client_certificate", clientCertificate: "", expectError: false, mutualTls: false},
+		{name: "optional_client_certificate", clientCertificate: OptionalClientCertificate, expectError: false, mutualTls: true},
+		{name: "required_client_certificate_with_ca", clientCertificate: RequiredClientCertificate,
+			clientCaFile: filepath.Join(certsDir, "server.crt"), expectError: false, mutualTls: true},
+		{name: "unknown_client_certificate_mode", clientCertificate: "sometimes", expectError: true},
+		{name: "missing_client_ca_file", clientCertificate: OptionalClientCertificate,
+			clientCaFile: filepath.Join(certsDir, "missing_ca.crt"), expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := ServerConfig{Schema: HTTPS, Address: "127.0.0.1", Port: 8672, SecretFile: filepath.Join("..", "keyfile"),
+				Security: &SecurityConfig{CertificateFile: filepath.Join(certsDir, "server.crt"), KeyFile: filepath.Join(certsDir, "server.key"),
+					ClientCertificate: tc.clientCertificate, ClientCaFile: tc.clientCaFile}}
+			err := cfg.Validate()
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.mutualTls, cfg.Security.IsMutualTlsEnabled())
+		})
+	}
+}
+
+func TestValidateServerConfigAdminListener(t *testing.T) {
+	certsDir := filepath.Join("..", "certs")
+	security := &SecurityConfig{CertificateFile: filepath.Join(certsDir, "server.crt"), KeyFile: filepath.Join(certsDir, "server.key"),
+		ClientCertificate: RequiredClientCertificate, ClientCaFile: filepath.Join(certsDir, "server.crt")}
+	testCases := []struct {
+		name          string
+		adminListener *AdminListenerConfig
+		expectError   bool
+	}{
+		{name: "without_admin_listener"},
+		{name: "http_admin_listener", adminListener: &AdminListenerConfig{Schema: HTTP, Address: "127.0.0.1", Port: 9000}},
+		{name: "https_admin_listener", adminListener: &AdminListenerConfig{Schema: HTTPS, Address: "10.0.0.1", Port: 8672, Security: security}},
+		{name: "https_admin_listener_without_security", adminListener: &AdminListenerConfig{Schema: HTTPS, Address: "127.0.0.1", Port: 9000},
+			expectError: true},
+		{name: "admin_listener_without_port", adminListener: &AdminListenerConfig{Schema: HTTP, Address: "127.0.0.1"}, expectError: true},
+		{name: "admin_listener_unknown_schema", adminListener: &AdminListenerConfig{Schema: "ftp", Address: "127.0.0.1", Port: 9000},
+			expectError: true},
+		{name: "admin_listener_on_server_address", adminListener: &AdminListenerConfig{Schema: HTTP, Address: "127.0.0.1", Port: 8672},
+			expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := ServerConfig{Schema: HTTP, Address: "127.0.0.1", Port: 8672, SecretFile: filepath.Join("..", "keyfile"),
+				AdminListener: tc.adminListener}
+			err := cfg.Validate()
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"errors"
+	"os"
+
+	sf "github.com/wissance/stringFormatter"
+)
+
+// UiConfig is a server-rendered pages (login form) settings, if this section is absent only default theme (embedded in binary)
+// is available
+/* ThemesDir is a directory with custom themes, every theme is a subdirectory (theme name that realm login_theme refers to)
+ * with templates, messages and resources directories, files that theme doesn't have are taken from default theme
+ */
+type UiConfig struct {
+	ThemesDir string `json:"themes_dir" example:"./themes"`
+}
+
+func (cfg *UiConfig) Validate() error {
+	if len(cfg.ThemesDir) == 0 {
+		return errors.New("themes directory wasn't set")
+	}
+	info, err := os.Stat(cfg.ThemesDir)
+	if err != nil || !info.IsDir() {
+		return errors.New(sf.Format("themes directory \"{0}\" doesn't exist", cfg.ThemesDir))
+	}
+	return nil
+}
+
+// GetThemesDir returns directory with custom themes, empty value means there are no custom themes
+func (cfg *UiConfig) GetThemesDir() string {
+	if cfg == nil {
+		return ""
+	}
+	return cfg.ThemesDir
+}
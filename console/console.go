@@ -0,0 +1,37 @@
+package console
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+)
+
+// IndexPage is a console page that is served for console root path, console is a single page application over Admin REST API
+// that is embedded in binary
+const IndexPage = "index.html"
+
+//go:embed static
+var embeddedFiles embed.FS
+
+// OpenResource opens static file of admin web console, empty name opens IndexPage
+/* Parameters:
+ *    - name - file path relative to console root
+ * Returns: file or error if console doesn't have such file
+ */
+func OpenResource(name string) (fs.File, error) {
+	if len(name) == 0 {
+		name = IndexPage
+	}
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrNotExist
+	}
+	file, err := embeddedFiles.Open(path.Join("static", name))
+	if err != nil {
+		return nil, err
+	}
+	if info, statErr := file.Stat(); statErr != nil || info.IsDir() {
+		_ = file.Close()
+		return nil, fs.ErrNotExist
+	}
+	return file, nil
+}
@@ -0,0 +1,438 @@
+// Ferrum admin console: single page application over Admin REST API, administrator signs in with password grant of
+// admin realm client (see config.json), tokens are kept in memory only and are refreshed before access token expires
+(function () {
+    "use strict";
+
+    const usersPageSize = 20;
+    const refreshMargin = 10000;
+
+    const state = {
+        config: null,
+        token: null,
+        refreshToken: null,
+        tokenExpires: 0,
+        realm: null,
+        usersFirst: 0,
+        user: null
+    };
+
+    function $(id) {
+        return document.getElementById(id);
+    }
+
+    function show(element, visible) {
+        element.classList.toggle("hidden", !visible);
+    }
+
+    function showMessage(text, isError) {
+        const message = $("message");
+        message.textContent = text;
+        message.classList.toggle("error", !!isError);
+        show(message, true);
+    }
+
+    function clearMessage() {
+        show($("message"), false);
+    }
+
+    function formatTime(milliseconds) {
+        return milliseconds ? new Date(milliseconds).toLocaleString() : "";
+    }
+
+    function createCell(row, text) {
+        const cell = document.createElement("td");
+        cell.textContent = text === undefined || text === null ? "" : String(text);
+        row.appendChild(cell);
+        return cell;
+    }
+
+    function clearChildren(element) {
+        while (element.firstChild) {
+            element.removeChild(element.firstChild);
+        }
+    }
+
+    function getErrorText(body, status) {
+        if (body && (body.error || body.error_description)) {
+            return [body.error, body.error_description].filter(Boolean).join(": ");
+        }
+        return "Request failed with status " + status;
+    }
+
+    // token endpoint requests
+
+    async function requestToken(parameters) {
+        parameters.set("client_id", state.config.clientId);
+        const response = await fetch(state.config.tokenUrl, {
+            method: "POST",
+            headers: {"Content-Type": "application/x-www-form-urlencoded"},
+            body: parameters.toString()
+        });
+        const body = await response.json().catch(function () {
+            return null;
+        });
+        if (!response.ok) {
+            throw new Error(getErrorText(body, response.status));
+        }
+        state.token = body.access_token;
+        state.refreshToken = body.refresh_token;
+        state.tokenExpires = Date.now() + body.expires_in * 1000;
+    }
+
+    async function login(username, password, totp) {
+        const parameters = new URLSearchParams();
+        parameters.set("grant_type", "password");
+        parameters.set("scope", "openid");
+        parameters.set("username", username);
+        parameters.set("password", password);
+        if (totp) {
+            parameters.set("totp", totp);
+        }
+        await requestToken(parameters);
+        $("username").textContent = username;
+    }
+
+    async function getToken() {
+        if (state.token && Date.now() > state.tokenExpires - refreshMargin) {
+            const parameters = new URLSearchParams();
+            parameters.set("grant_type", "refresh_token");
+            parameters.set("refresh_token", state.refreshToken);
+            try {
+                await requestToken(parameters);
+            } catch (err) {
+                logout();
+                throw new Error("Session expired, please sign in again");
+            }
+        }
+        return state.token;
+    }
+
+    function logout() {
+        state.token = null;
+        state.refreshToken = null;
+        state.realm = null;
+        state.user = null;
+        show($("main-view"), false);
+        show($("realm-selector"), false);
+        show($("account"), false);
+        show($("login-view"), true);
+    }
+
+    // Admin REST API requests
+
+    async function api(method, path, body) {
+        const token = await getToken();
+        const options = {method: method, headers: {"Authorization": "Bearer " + token}};
+        if (body !== undefined) {
+            options.headers["Content-Type"] = "application/json";
+            options.body = JSON.stringify(body);
+        }
+        const response = await fetch(state.config.adminUrl + path, options);
+        if (response.status === 401) {
+            logout();
+            throw new Error("Session expired, please sign in again");
+        }
+        const text = await response.text();
+        const result = text ? JSON.parse(text) : null;
+        if (!response.ok) {
+            throw new Error(getErrorText(result, response.status));
+        }
+        return result;
+    }
+
+    function realmPath(path) {
+        return "/realms/" + encodeURIComponent(state.realm) + path;
+    }
+
+    async function run(action, successText) {
+        clearMessage();
+        try {
+            await action();
+            if (successText) {
+                showMessage(successText, false);
+            }
+        } catch (err) {
+            showMessage(err.message, true);
+        }
+    }
+
+    // realms
+
+    async function loadRealms() {
+        const realms = await api("GET", "/realms");
+        const select = $("realm");
+        clearChildren(select);
+        realms.forEach(function (realm) {
+            const option = document.createElement("option");
+            option.value = realm.realm;
+            option.textContent = realm.displayName || realm.realm;
+            select.appendChild(option);
+        });
+        if (realms.length === 0) {
+            throw new Error("You have no permissions to administer realms");
+        }
+        state.realm = realms[0].realm;
+        select.value = state.realm;
+        show($("realm-selector"), true);
+        show($("account"), true);
+        show($("login-view"), false);
+        show($("main-view"), true);
+        selectTab("users");
+    }
+
+    function selectTab(name) {
+        document.querySelectorAll(".tab").forEach(function (tab) {
+            tab.classList.toggle("active", tab.dataset.tab === name);
+        });
+        document.querySelectorAll(".tab-content").forEach(function (content) {
+            show(content, content.id === name + "-tab");
+        });
+        if (name === "clients") {
+            run(loadClients);
+        } else if (name === "users") {
+            state.usersFirst = 0;
+            run(loadUsers);
+        } else if (name === "events") {
+            run(loadEvents);
+        }
+    }
+
+    // clients
+
+    async function loadClients() {
+        const clients = await api("GET", realmPath("/clients"));
+        const tbody = $("clients");
+        clearChildren(tbody);
+        $("client-title").textContent = "Select client";
+        $("client-json").textContent = "";
+        clients.forEach(function (client) {
+            const row = document.createElement("tr");
+            row.className = "selectable";
+            createCell(row, client.clientId);
+            createCell(row, client.enabled ? "yes" : "no");
+            createCell(row, client.publicClient ? "yes" : "no");
+            row.addEventListener("click", function () {
+                selectRow(tbody, row);
+                $("client-title").textContent = client.clientId;
+                $("client-json").textContent = JSON.stringify(client, null, 2);
+            });
+            tbody.appendChild(row);
+        });
+    }
+
+    function selectRow(tbody, row) {
+        tbody.querySelectorAll("tr").forEach(function (r) {
+            r.classList.toggle("selected", r === row);
+        });
+    }
+
+    // users
+
+    async function loadUsers() {
+        const search = $("users-search-value").value;
+        const users = await api("GET", realmPath("/users?search=" + encodeURIComponent(search) + "&first=" + state.usersFirst +
+            "&max=" + usersPageSize));
+        const tbody = $("users");
+        clearChildren(tbody);
+        users.forEach(function (user) {
+            const row = document.createElement("tr");
+            row.className = "selectable";
+            createCell(row, user.username);
+            createCell(row, user.email);
+            createCell(row, user.enabled === false ? "no" : "yes");
+            row.addEventListener("click", function () {
+                selectRow(tbody, row);
+                run(function () {
+                    return loadUser(user.id);
+                });
+            });
+            tbody.appendChild(row);
+        });
+        $("users-previous").disabled = state.usersFirst === 0;
+        $("users-next").disabled = users.length < usersPageSize;
+        show($("user-details"), false);
+    }
+
+    async function loadUser(id) {
+        const user = await api("GET", realmPath("/users/" + encodeURIComponent(id)));
+        state.user = user;
+        $("user-title").textContent = user.username;
+        $("user-email").value = user.email || "";
+        $("user-first-name").value = user.firstName || "";
+        $("user-last-name").value = user.lastName || "";
+        $("user-enabled").checked = user.enabled !== false;
+        $("user-email-verified").checked = !!user.emailVerified;
+        $("user-attributes").value = JSON.stringify(user.attributes || {}, null, 2);
+        $("password-form").reset();
+        show($("user-details"), true);
+        await loadSessions();
+    }
+
+    // parseAttributes validates attributes before they are sent: KeyCloak attributes are an object of strings arrays
+    function parseAttributes(text) {
+        let attributes;
+        try {
+            attributes = JSON.parse(text.trim() || "{}");
+        } catch (err) {
+            throw new Error("Attributes are not a valid JSON: " + err.message);
+        }
+        if (attributes === null || typeof attributes !== "object" || Array.isArray(attributes)) {
+            throw new Error("Attributes must be a JSON object");
+        }
+        Object.keys(attributes).forEach(function (name) {
+            const values = attributes[name];
+            if (!Array.isArray(values) || values.some(function (v) {
+                return typeof v !== "string";
+            })) {
+                throw new Error("Attribute \"" + name + "\" must be an array of strings");
+            }
+        });
+        return attributes;
+    }
+
+    async function saveUser() {
+        const representation = {
+            username: state.user.username,
+            email: $("user-email").value,
+            firstName: $("user-first-name").value,
+            lastName: $("user-last-name").value,
+            enabled: $("user-enabled").checked,
+            emailVerified: $("user-email-verified").checked,
+            attributes: parseAttributes($("user-attributes").value)
+        };
+        await api("PUT", realmPath("/users/" + encodeURIComponent(state.user.id)), representation);
+        await loadUser(state.user.id);
+    }
+
+    async function resetPassword() {
+        const password = $("password-value").value;
+        if (password !== $("password-confirmation").value) {
+            throw new Error("Password confirmation doesn't match password");
+        }
+        await api("PUT", realmPath("/users/" + encodeURIComponent(state.user.id) + "/reset-password"),
+            {type: "password", value: password, temporary: $("password-temporary").checked});
+        $("password-form").reset();
+    }
+
+    // sessions
+
+    async function loadSessions() {
+        const userPath = "/users/" + encodeURIComponent(state.user.id);
+        const sessions = await api("GET", realmPath(userPath + "/sessions"));
+        const tbody = $("sessions");
+        clearChildren(tbody);
+        sessions.forEach(function (session) {
+            const row = document.createElement("tr");
+            createCell(row, formatTime(session.start));
+            createCell(row, formatTime(session.expires));
+            createCell(row, session.browser ? "yes" : "no");
+            const button = document.createElement("button");
+            button.type = "button";
+            button.textContent = "Sign out";
+            button.addEventListener("click", function () {
+                run(async function () {
+                    await api("DELETE", realmPath(userPath + "/sessions/" + encodeURIComponent(session.id)));
+                    await loadSessions();
+                }, "Session was signed out");
+            });
+            createCell(row, "").appendChild(button);
+            tbody.appendChild(row);
+        });
+        $("sessions-logout").disabled = sessions.length === 0;
+    }
+
+    // events
+
+    async function loadEvents() {
+        const parameters = new URLSearchParams();
+        [["type", $("events-type").value], ["client", $("events-client").value.trim()],
+            ["user", $("events-user").value.trim()]].forEach(function (parameter) {
+            if (parameter[1]) {
+                parameters.set(parameter[0], parameter[1]);
+            }
+        });
+        const events = await api("GET", realmPath("/events?" + parameters.toString()));
+        const tbody = $("events");
+        clearChildren(tbody);
+        events.forEach(function (event) {
+            const row = document.createElement("tr");
+            createCell(row, formatTime(event.time));
+            createCell(row, event.type);
+            createCell(row, event.clientId);
+            createCell(row, event.userId);
+            createCell(row, event.ipAddress);
+            createCell(row, event.error);
+            createCell(row, event.details ? Object.keys(event.details).map(function (key) {
+                return key + "=" + event.details[key];
+            }).join(", ") : "");
+            tbody.appendChild(row);
+        });
+    }
+
+    // initialization
+
+    function bindEvents() {
+        $("login-form").addEventListener("submit", function (event) {
+            event.preventDefault();
+            run(async function () {
+                await login($("login-username").value, $("login-password").value, $("login-totp").value);
+                $("login-form").reset();
+                await loadRealms();
+            });
+        });
+        $("logout").addEventListener("click", logout);
+        $("realm").addEventListener("change", function () {
+            state.realm = $("realm").value;
+            selectTab(document.querySelector(".tab.active").dataset.tab);
+        });
+        document.querySelectorAll(".tab").forEach(function (tab) {
+            tab.addEventListener("click", function () {
+                selectTab(tab.dataset.tab);
+            });
+        });
+        $("users-search").addEventListener("submit", function (event) {
+            event.preventDefault();
+            state.usersFirst = 0;
+            run(loadUsers);
+        });
+        $("users-previous").addEventListener("click", function () {
+            state.usersFirst = Math.max(0, state.usersFirst - usersPageSize);
+            run(loadUsers);
+        });
+        $("users-next").addEventListener("click", function () {
+            state.usersFirst += usersPageSize;
+            run(loadUsers);
+        });
+        $("user-form").addEventListener("submit", function (event) {
+            event.preventDefault();
+            run(saveUser, "User was saved");
+        });
+        $("password-form").addEventListener("submit", function (event) {
+            event.preventDefault();
+            run(resetPassword, "Password was reset");
+        });
+        $("sessions-logout").addEventListener("click", function () {
+            run(async function () {
+                await api("POST", realmPath("/users/" + encodeURIComponent(state.user.id) + "/logout"));
+                await loadSessions();
+            }, "All user sessions were signed out");
+        });
+        $("events-search").addEventListener("submit", function (event) {
+            event.preventDefault();
+            run(loadEvents);
+        });
+    }
+
+    async function init() {
+        bindEvents();
+        const response = await fetch("config.json");
+        if (!response.ok) {
+            showMessage("Admin console configuration is not available", true);
+            return;
+        }
+        state.config = await response.json();
+        show($("login-view"), true);
+    }
+
+    init();
+})();
@@ -0,0 +1,159 @@
+package data
+
+import "strings"
+
+// Admin permissions (KeyCloak realm-management roles) that admin realm user gets via realm roles in form {realm}/{permission}
+// or {realm}/{permission}/{clientId} (only clients permissions could be granted for a single client)
+const (
+	RealmAdminPermission     = "realm-admin"
+	ManageRealmPermission    = "manage-realm"
+	ViewRealmPermission      = "view-realm"
+	QueryRealmsPermission    = "query-realms"
+	ManageUsersPermission    = "manage-users"
+	ViewUsersPermission      = "view-users"
+	QueryUsersPermission     = "query-users"
+	QueryGroupsPermission    = "query-groups"
+	ManageClientsPermission  = "manage-clients"
+	ViewClientsPermission    = "view-clients"
+	QueryClientsPermission   = "query-clients"
+	ViewEventsPermission     = "view-events"
+	adminPermissionSeparator = "/"
+)
+
+// impliedAdminPermissions are permissions that permission includes directly (like KeyCloak composite roles), realm-admin
+// includes all permissions
+var impliedAdminPermissions = map[string][]string{
+	ManageRealmPermission:   {ViewRealmPermission},
+	ViewRealmPermission:     {QueryRealmsPermission},
+	ManageUsersPermission:   {ViewUsersPermission},
+	ViewUsersPermission:     {QueryUsersPermission, QueryGroupsPermission},
+	QueryUsersPermission:    {QueryRealmsPermission},
+	QueryGroupsPermission:   {QueryRealmsPermission},
+	ManageClientsPermission: {ViewClientsPermission},
+	ViewClientsPermission:   {QueryClientsPermission},
+	QueryClientsPermission:  {QueryRealmsPermission},
+	ViewEventsPermission:    {QueryRealmsPermission},
+}
+
+// AdminPermissions are permissions of admin realm user in managed realms and clients
+/* User with AdminRole is an administrator of all realms (including admin realm) and the only one who could create realms,
+ * other permissions are granted per realm ({realm}/manage-users) or per client ({realm}/manage-clients/{clientId}) and
+ * can't be granted for admin realm itself, otherwise such user could assign AdminRole to any admin realm user
+ */
+type AdminPermissions struct {
+	global  bool
+	realms  map[string]map[string]bool
+	clients map[string]map[string]map[string]bool
+}
+
+// CreateAdminPermissions evaluates admin permissions from effective realm roles of admin realm user
+/* Parameters:
+ *    - adminRealm - name of admin realm
+ *    - roles - effective roles of admin realm user (see Realm.GetEffectiveRoles)
+ * Returns: admin permissions, roles that are not permissions are ignored
+ */
+func CreateAdminPermissions(adminRealm string, roles []string) *AdminPermissions {
+	permissions := &AdminPermissions{realms: map[string]map[string]bool{},
+		clients: map[string]map[string]map[string]bool{}}
+	for _, role := range roles {
+		if role == AdminRole {
+			permissions.global = true
+			continue
+		}
+		parts := strings.SplitN(role, adminPermissionSeparator, 3)
+		if len(parts) < 2 || parts[0] == "" || parts[0] == adminRealm || !isAdminPermission(parts[1]) {
+			continue
+		}
+		realm := parts[0]
+		if len(parts) == 2 {
+			addAdminPermission(getPermissionsSet(permissions.realms, realm), parts[1])
+			continue
+		}
+		if !isClientAdminPermission(parts[1]) || parts[2] == "" {
+			continue
+		}
+		clients, ok := permissions.clients[realm]
+		if !ok {
+			clients = map[string]map[string]bool{}
+			permissions.clients[realm] = clients
+		}
+		clientPermissions := getPermissionsSet(clients, parts[2])
+		addAdminPermission(clientPermissions, parts[1])
+		// client administrator could see realm in realms list
+		delete(clientPermissions, QueryRealmsPermission)
+		addAdminPermission(getPermissionsSet(permissions.realms, realm), QueryRealmsPermission)
+	}
+	return permissions
+}
+
+// IsGlobalAdmin returns true if user has AdminRole (administrator of all realms)
+func (permissions *AdminPermissions) IsGlobalAdmin() bool {
+	return permissions.global
+}
+
+// HasAnyPermission returns true if user has at least one admin permission (user is an administrator)
+func (permissions *AdminPermissions) HasAnyPermission() bool {
+	return permissions.global || len(permissions.realms) > 0
+}
+
+// HasRealmPermission returns true if user has permission in realm (directly or via permission that includes it)
+func (permissions *AdminPermissions) HasRealmPermission(realm string, permission string) bool {
+	if permissions.global {
+		return true
+	}
+	return permissions.realms[realm][permission]
+}
+
+// HasClientPermission returns true if user has clients permission in realm or permission for client with clientId
+func (permissions *AdminPermissions) HasClientPermission(realm string, clientId string, permission string) bool {
+	return permissions.HasRealmPermission(realm, permission) || permissions.clients[realm][clientId][permission]
+}
+
+// HasAnyClientPermission returns true if user has permission in realm or permission for at least one realm client
+func (permissions *AdminPermissions) HasAnyClientPermission(realm string, permission string) bool {
+	if permissions.HasRealmPermission(realm, permission) {
+		return true
+	}
+	for _, clientPermissions := range permissions.clients[realm] {
+		if clientPermissions[permission] {
+			return true
+		}
+	}
+	return false
+}
+
+func isAdminPermission(permission string) bool {
+	_, ok := impliedAdminPermissions[permission]
+	return ok || permission == RealmAdminPermission || permission == QueryRealmsPermission
+}
+
+func isClientAdminPermission(permission string) bool {
+	return permission == ManageClientsPermission || permission == ViewClientsPermission || permission == QueryClientsPermission
+}
+
+func getPermissionsSet(sets map[string]map[string]bool, key string) map[string]bool {
+	set, ok := sets[key]
+	if !ok {
+		set = map[string]bool{}
+		sets[key] = set
+	}
+	return set
+}
+
+// addAdminPermission adds permission and all permissions that it includes
+func addAdminPermission(permissions map[string]bool, permission string) {
+	if permission == RealmAdminPermission {
+		permissions[RealmAdminPermission] = true
+		for p := range impliedAdminPermissions {
+			addAdminPermission(permissions, p)
+		}
+		return
+	}
+	if permissions[permission] {
+		return
+	}
+	permissions[permission] = true
+	for _, implied := range impliedAdminPermissions[permission] {
+		addAdminPermission(permissions, implied)
+	}
+}
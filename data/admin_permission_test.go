@@ -0,0 +1,60 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminPermissions(t *testing.T) {
+	testCases := []struct {
+		name               string
+		roles              []string
+		realm              string
+		permission         string
+		expectedPermission bool
+	}{
+		{name: "global_admin", roles: []string{AdminRole}, realm: "master", permission: ManageUsersPermission, expectedPermission: true},
+		{name: "realm_admin_includes_all", roles: []string{"sales/realm-admin"}, realm: "sales", permission: QueryGroupsPermission,
+			expectedPermission: true},
+		{name: "realm_admin_of_other_realm", roles: []string{"sales/realm-admin"}, realm: "support", permission: QueryRealmsPermission},
+		{name: "manage_users_includes_view", roles: []string{"sales/manage-users"}, realm: "sales", permission: QueryUsersPermission,
+			expectedPermission: true},
+		{name: "view_users_not_includes_manage", roles: []string{"sales/view-users"}, realm: "sales", permission: ManageUsersPermission},
+		{name: "realm_admin_includes_events", roles: []string{"sales/realm-admin"}, realm: "sales", permission: ViewEventsPermission,
+			expectedPermission: true},
+		{name: "users_permission_not_includes_events", roles: []string{"sales/manage-users"}, realm: "sales",
+			permission: ViewEventsPermission},
+		{name: "users_permission_not_includes_clients", roles: []string{"sales/manage-users"}, realm: "sales",
+			permission: ViewClientsPermission},
+		{name: "admin_realm_could_not_be_granted", roles: []string{"master/realm-admin"}, realm: "master", permission: ViewUsersPermission},
+		{name: "unknown_permission", roles: []string{"sales/manage-everything"}, realm: "sales", permission: QueryRealmsPermission},
+		{name: "client_permission_shows_realm", roles: []string{"sales/manage-clients/crm"}, realm: "sales",
+			permission: QueryRealmsPermission, expectedPermission: true},
+		{name: "client_permission_is_not_realm_permission", roles: []string{"sales/manage-clients/crm"}, realm: "sales",
+			permission: ViewClientsPermission},
+		{name: "users_permission_could_not_be_granted_for_client", roles: []string{"sales/manage-users/crm"}, realm: "sales",
+			permission: QueryRealmsPermission},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			permissions := CreateAdminPermissions("master", tc.roles)
+			assert.Equal(t, tc.expectedPermission, permissions.HasRealmPermission(tc.realm, tc.permission))
+		})
+	}
+}
+
+func TestAdminClientPermissions(t *testing.T) {
+	permissions := CreateAdminPermissions("master", []string{"sales/manage-clients/crm", "sales/view-clients/web", "support/query-clients"})
+	assert.False(t, permissions.IsGlobalAdmin())
+	assert.True(t, permissions.HasClientPermission("sales", "crm", ManageClientsPermission))
+	assert.True(t, permissions.HasClientPermission("sales", "crm", ViewClientsPermission))
+	assert.True(t, permissions.HasClientPermission("sales", "web", ViewClientsPermission))
+	assert.False(t, permissions.HasClientPermission("sales", "web", ManageClientsPermission))
+	assert.False(t, permissions.HasClientPermission("sales", "erp", ViewClientsPermission))
+	assert.False(t, permissions.HasClientPermission("support", "crm", ViewClientsPermission))
+	assert.True(t, permissions.HasAnyClientPermission("sales", QueryClientsPermission))
+	assert.True(t, permissions.HasAnyClientPermission("support", QueryClientsPermission))
+	assert.False(t, permissions.HasAnyClientPermission("support", ViewClientsPermission))
+}
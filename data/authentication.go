@@ -2,14 +2,36 @@ package data
 
 type AuthenticationType int
 
-// ClientIdAndSecrets AuthenticationType represents Confidential Clients
+// ClientIdAndSecrets AuthenticationType represents Confidential Clients that pass client_secret in a request body or Basic Authorization header,
+// ClientSecretJwt - clients that pass JWT signed with client secret (HMAC), PrivateKeyJwt - clients that pass JWT signed with private key,
+// TlsClientAuth - clients that use certificate issued by trusted CA (mutual TLS), SelfSignedTlsClientAuth - clients that use self-signed certificate
 const (
-	ClientIdAndSecrets AuthenticationType = 1
+	ClientIdAndSecrets      AuthenticationType = 1
+	ClientSecretJwt         AuthenticationType = 2
+	PrivateKeyJwt           AuthenticationType = 3
+	TlsClientAuth           AuthenticationType = 4
+	SelfSignedTlsClientAuth AuthenticationType = 5
 )
 
-// Authentication struct for Clients authentication data, for ClientIdAndSecrets Value stores ClientSecret
+// Authentication struct for Clients authentication data, for ClientIdAndSecrets and ClientSecretJwt Value stores ClientSecret,
+// for PrivateKeyJwt and SelfSignedTlsClientAuth Jwks stores client public keys, for TlsClientAuth one of TlsSubjectDn, TlsSanDns or
+// TlsSanUri is a value that client certificate must have (RFC 8705 section 2.1.2)
 type Authentication struct {
-	Type       AuthenticationType
-	Value      string
-	Attributes interface{}
+	Type         AuthenticationType
+	Value        string
+	Attributes   interface{}
+	Jwks         *JsonWebKeySet `json:"jwks,omitempty"`
+	TlsSubjectDn string         `json:"tls_client_auth_subject_dn,omitempty"`
+	TlsSanDns    string         `json:"tls_client_auth_san_dns,omitempty"`
+	TlsSanUri    string         `json:"tls_client_auth_san_uri,omitempty"`
+}
+
+// IsSecretBased checks whether client authenticates with client secret (Value)
+func (auth *Authentication) IsSecretBased() bool {
+	return auth.Type == ClientIdAndSecrets || auth.Type == ClientSecretJwt
+}
+
+// IsCertificateBased checks whether client authenticates with certificate (mutual TLS)
+func (auth *Authentication) IsCertificateBased() bool {
+	return auth.Type == TlsClientAuth || auth.Type == SelfSignedTlsClientAuth
 }
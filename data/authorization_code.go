@@ -0,0 +1,34 @@
+package data
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PKCE code challenge methods (RFC 7636)
+const (
+	PlainCodeChallengeMethod = "plain"
+	S256CodeChallengeMethod  = "S256"
+)
+
+// AuthorizationCode is a one-time code that authorization endpoint issues after user login, client exchanges it for tokens
+/* Code itself is not stored, it is a key of stored value. RedirectUri must be passed to token endpoint again, CodeChallenge
+ * and CodeChallengeMethod are set if client started authorization with PKCE (RFC 7636), code_verifier is required then
+ */
+type AuthorizationCode struct {
+	ClientId            string
+	RedirectUri         string
+	UserId              uuid.UUID
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	AuthTime            time.Time
+	Expired             time.Time
+}
+
+// IsCodeChallengeMethodSupported checks whether PKCE method could be used, empty method means plain (RFC 7636 section 4.3)
+func IsCodeChallengeMethodSupported(method string) bool {
+	return method == "" || method == PlainCodeChallengeMethod || method == S256CodeChallengeMethod
+}
@@ -0,0 +1,48 @@
+package data
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BackChannelTokenDeliveryMode is a CIBA mode of tokens delivery to client, Ferrum supports Poll && Ping modes (but not Push)
+type BackChannelTokenDeliveryMode string
+
+const (
+	PollDeliveryMode BackChannelTokenDeliveryMode = "poll"
+	PingDeliveryMode BackChannelTokenDeliveryMode = "ping"
+)
+
+// BackChannelAuthenticationStatus is a state of CIBA request, values except Pending are same that authentication device passes
+// to callback endpoint (like Keycloak does)
+type BackChannelAuthenticationStatus string
+
+const (
+	BackChannelAuthPending   BackChannelAuthenticationStatus = "PENDING"
+	BackChannelAuthSucceed   BackChannelAuthenticationStatus = "SUCCEED"
+	BackChannelAuthDenied    BackChannelAuthenticationStatus = "UNAUTHORIZED"
+	BackChannelAuthCancelled BackChannelAuthenticationStatus = "CANCELLED"
+)
+
+// BackChannelAuthenticationRequest is a Client-Initiated Backchannel Authentication request state
+/* AuthReqId is an identifier that client uses to obtain tokens, CallbackToken is a secret that authentication device uses
+ * to report user decision, these values are different because client must not be able to approve request itself.
+ * ClientNotificationToken and NotificationEndpoint are using only in PingDeliveryMode
+ */
+type BackChannelAuthenticationRequest struct {
+	AuthReqId               string
+	CallbackToken           string
+	ClientId                string
+	UserId                  uuid.UUID
+	Username                string
+	Scope                   string
+	BindingMessage          string
+	DeliveryMode            BackChannelTokenDeliveryMode
+	ClientNotificationToken string
+	NotificationEndpoint    string
+	Status                  BackChannelAuthenticationStatus
+	Expired                 time.Time
+	Interval                int
+	LastPolled              time.Time
+}
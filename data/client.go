@@ -13,9 +13,50 @@ const (
 )
 
 // Client is a realm client, represents an application nad set of rules for interacting with Authorization server
+/* RedirectUris is a list of allowed redirect uri values that client could pass to authorization endpoint, RequirePar means that
+ * client could start authorization only with request_uri obtained from Pushed Authorization Request endpoint (RFC 9126)
+ * BackChannelTokenDeliveryMode allows client to use CIBA (empty value means CIBA is not allowed), in ping mode server
+ * notifies client via BackChannelClientNotificationEndpoint
+ * RegistrationAccessTokenHash is set for dynamically registered clients (RFC 7591), registration access token allows
+ * client to read, update and delete own registration (RFC 7592), DisplayName is a human-readable client name (client_name)
+ * TlsClientCertificateBoundAccessTokens means that access tokens are bound to client certificate (cnf claim, RFC 8705)
+ * DPoPBoundAccessTokens means that client must always send DPoP proof on token request (RFC 9449)
+ * Disabled client (Enabled is false) can't authenticate, client without Enabled value is enabled
+ * ConsentRequired means that browser login shows consent screen with requested scopes until user grants them (third-party clients)
+ */
 type Client struct {
-	Type ClientType
-	ID   uuid.UUID
-	Name string
-	Auth Authentication
+	Type         ClientType
+	ID           uuid.UUID
+	Name         string
+	Auth         Authentication
+	Enabled      *bool    `json:"enabled,omitempty"`
+	RedirectUris []string `json:"redirect_uris,omitempty"`
+	RequirePar   bool     `json:"require_par,omitempty"`
+	// ConsentRequired requires user consent to requested scopes on browser login
+	ConsentRequired bool `json:"consent_required,omitempty"`
+	// TlsClientCertificateBoundAccessTokens requires client certificate on token request
+	TlsClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	// DPoPBoundAccessTokens requires DPoP proof on token request
+	DPoPBoundAccessTokens bool `json:"dpop_bound_access_tokens,omitempty"`
+	// CIBA client settings
+	BackChannelTokenDeliveryMode          BackChannelTokenDeliveryMode `json:"backchannel_token_delivery_mode,omitempty"`
+	BackChannelClientNotificationEndpoint string                       `json:"backchannel_client_notification_endpoint,omitempty"`
+	// Dynamic client registration settings
+	DisplayName                 string `json:"display_name,omitempty"`
+	RegistrationAccessTokenHash string `json:"registration_access_token_hash,omitempty"`
+}
+
+// IsEnabled checks whether client is enabled (client without enabled value is enabled)
+func (client *Client) IsEnabled() bool {
+	return client.Enabled == nil || *client.Enabled
+}
+
+// IsRedirectUriAllowed checks whether redirectUri is one of registered client RedirectUris (exact match as OAuth 2.1 requires)
+func (client *Client) IsRedirectUriAllowed(redirectUri string) bool {
+	for _, uri := range client.RedirectUris {
+		if uri == redirectUri {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,49 @@
+package data
+
+import "strings"
+
+// UserConsent is a set of scopes that user granted to client on consent screen, consents are stored in user consents
+/* Created and LastUpdated are unix seconds, Scopes are all scopes that user ever granted to client (consent for new scopes
+ * extends Scopes), consent without scopes means that user granted client access without any scope
+ */
+type UserConsent struct {
+	ClientId    string   `json:"client_id"`
+	Scopes      []string `json:"scopes"`
+	Created     int64    `json:"created"`
+	LastUpdated int64    `json:"last_updated,omitempty"`
+}
+
+// FindConsent returns user consent of client or nil if user didn't grant consent to client
+func FindConsent(user User, clientId string) *UserConsent {
+	consents := user.GetConsents()
+	for i := range consents {
+		if consents[i].ClientId == clientId {
+			return &consents[i]
+		}
+	}
+	return nil
+}
+
+// IsConsentGranted checks whether user granted all requested scopes (space-separated scope parameter) to client
+func IsConsentGranted(user User, clientId string, scope string) bool {
+	consent := FindConsent(user, clientId)
+	if consent == nil {
+		return false
+	}
+	for _, requested := range strings.Fields(scope) {
+		if !consent.HasScope(requested) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasScope checks whether scope is granted by consent
+func (consent *UserConsent) HasScope(scope string) bool {
+	for _, s := range consent.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package data
+
+// Email defaults that are used if realm EmailSettings values are not set, lifespans are the same as in KeyCloak
+const (
+	DefaultSmtpPort                 = 25
+	DefaultSmtpSslPort              = 465
+	DefaultActionTokenLifespan      = 300
+	DefaultAdminActionTokenLifespan = 43200
+)
+
+// EmailSettings is a realm outbound email settings, email-based flows are enabled only in realms that have these settings
+/*    - From - sender address, FromDisplayName is a sender name (realm name if not set), ReplyTo is an optional reply address
+ *    - Host, Port, Username, Password - SMTP server and credentials (no authentication if Username is empty)
+ *    - Ssl - connect with implicit TLS (port 465 by default), StartTls - require STARTTLS on plain connection
+ *    - ActionTokenLifespan - lifetime (seconds) of links that user requested (verify email, reset password)
+ *    - AdminActionTokenLifespan - lifetime (seconds) of execute actions links that administrator sent
+ */
+type EmailSettings struct {
+	From                     string `json:"from"`
+	FromDisplayName          string `json:"from_display_name,omitempty"`
+	ReplyTo                  string `json:"reply_to,omitempty"`
+	Host                     string `json:"host,omitempty"`
+	Port                     int    `json:"port,omitempty"`
+	Username                 string `json:"username,omitempty"`
+	Password                 string `json:"password,omitempty"`
+	Ssl                      bool   `json:"ssl,omitempty"`
+	StartTls                 bool   `json:"starttls,omitempty"`
+	ActionTokenLifespan      int    `json:"action_token_lifespan,omitempty"`
+	AdminActionTokenLifespan int    `json:"admin_action_token_lifespan,omitempty"`
+}
+
+// ActionToken is an issued email link that allows user to execute actions (verify email, update password) without login
+/* Token is stored in user credentials.action_tokens as a hash (TokenHash), Email is an address that link was sent to (email
+ * verification fails if user changed email after that), Expires is unix seconds. Token is removed after use
+ */
+type ActionToken struct {
+	TokenHash string   `json:"token_hash"`
+	Actions   []string `json:"actions"`
+	Email     string   `json:"email"`
+	Expires   int64    `json:"expires"`
+}
+
+// GetPort returns SMTP server port or default port of connection type
+func (settings *EmailSettings) GetPort() int {
+	if settings.Port > 0 {
+		return settings.Port
+	}
+	if settings.Ssl {
+		return DefaultSmtpSslPort
+	}
+	return DefaultSmtpPort
+}
+
+// GetActionTokenLifespan returns lifetime (seconds) of user requested links or default value if it is not set
+func (settings *EmailSettings) GetActionTokenLifespan() int {
+	if settings.ActionTokenLifespan <= 0 {
+		return DefaultActionTokenLifespan
+	}
+	return settings.ActionTokenLifespan
+}
+
+// GetAdminActionTokenLifespan returns lifetime (seconds) of administrator execute actions links or default value if it is not set
+func (settings *EmailSettings) GetAdminActionTokenLifespan() int {
+	if settings.AdminActionTokenLifespan <= 0 {
+		return DefaultAdminActionTokenLifespan
+	}
+	return settings.AdminActionTokenLifespan
+}
+
+// IsActionSupported checks whether action could be executed via email link
+func IsActionSupported(action string) bool {
+	return action == VerifyEmailAction || action == UpdatePasswordAction
+}
+
+// HasAction checks whether token contains action
+func (token *ActionToken) HasAction(action string) bool {
+	for _, a := range token.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
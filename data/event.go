@@ -0,0 +1,49 @@
+package data
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType is a type of user event (KeyCloak event types), failed operation has type with ErrorEventSuffix
+type EventType string
+
+// User events that token endpoint records
+const (
+	LoginEvent        EventType = "LOGIN"
+	LoginErrorEvent   EventType = "LOGIN_ERROR"
+	CodeToTokenEvent  EventType = "CODE_TO_TOKEN"
+	RefreshTokenEvent EventType = "REFRESH_TOKEN"
+	ErrorEventSuffix            = "_ERROR"
+)
+
+// Event is a user event (login, token refresh and so on) of realm
+/* Time - time when event happened
+ * Type - event type, failed operation type ends with ErrorEventSuffix
+ * ClientId - name of client that requested operation (could be empty)
+ * UserId - user identifier (uuid.Nil if user is unknown, i.e. username is wrong)
+ * SessionId - identifier of user session that operation started or updated (uuid.Nil if operation failed)
+ * IpAddress - client ip address
+ * Error - error description of failed operation
+ * Details - other event data (i.e. username and grant type)
+ */
+type Event struct {
+	Time      time.Time
+	Type      EventType
+	Realm     string
+	ClientId  string
+	UserId    uuid.UUID
+	SessionId uuid.UUID
+	IpAddress string
+	Error     string
+	Details   map[string]string
+}
+
+// GetErrorType returns type of failed operation event
+func (eventType EventType) GetErrorType() EventType {
+	if eventType == LoginEvent {
+		return LoginErrorEvent
+	}
+	return eventType + ErrorEventSuffix
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InitialAccessToken is a realm token that authorizes dynamic client registration (RFC 7591)
+/* Token value is returned only once (on creation), data source keeps only TokenHash. Count is a maximum number of clients
+ * that could be registered with this token (0 means unlimited), Used is a number of already registered clients.
+ * Zero Expires means that token never expires
+ */
+type InitialAccessToken struct {
+	ID        uuid.UUID `json:"id"`
+	TokenHash string    `json:"token_hash"`
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+	Count     int       `json:"count"`
+	Used      int       `json:"used"`
+}
+
+// IsActive checks whether token could be used for client registration at moment
+func (token *InitialAccessToken) IsActive(moment time.Time) bool {
+	if !token.Expires.IsZero() && !moment.Before(token.Expires) {
+		return false
+	}
+	return token.Count == 0 || token.Used < token.Count
+}
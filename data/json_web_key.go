@@ -0,0 +1,114 @@
+package data
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// JsonWebKey is a public key in JWK format (RFC 7517), only RSA and EC (P-256, P-384, P-521) keys are supported
+type JsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA key parameters
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC key parameters
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JsonWebKeySet is a set of public keys (i.e. client keys that are using for private_key_jwt authentication)
+type JsonWebKeySet struct {
+	Keys []JsonWebKey `json:"keys"`
+}
+
+var errUnsupportedKey = errors.New("unsupported or malformed json web key")
+
+// GetPublicKey converts JWK to *rsa.PublicKey or *ecdsa.PublicKey
+func (key *JsonWebKey) GetPublicKey() (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		n, nErr := decodeKeyParameter(key.N)
+		e, eErr := decodeKeyParameter(key.E)
+		if nErr != nil || eErr != nil || !e.IsInt64() {
+			return nil, errUnsupportedKey
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errUnsupportedKey
+		}
+		x, xErr := decodeKeyParameter(key.X)
+		y, yErr := decodeKeyParameter(key.Y)
+		if xErr != nil || yErr != nil || !curve.IsOnCurve(x, y) {
+			return nil, errUnsupportedKey
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, errUnsupportedKey
+}
+
+// Thumbprint calculates base64url-encoded SHA-256 JWK thumbprint (RFC 7638), thumbprint is calculated from JSON with only required
+// key members in lexicographic order without whitespaces (json.Marshal sorts map keys)
+func (key *JsonWebKey) Thumbprint() (string, error) {
+	if _, err := key.GetPublicKey(); err != nil {
+		return "", err
+	}
+	var members map[string]string
+	if key.Kty == "RSA" {
+		members = map[string]string{"e": key.E, "kty": key.Kty, "n": key.N}
+	} else {
+		members = map[string]string{"crv": key.Crv, "kty": key.Kty, "x": key.X, "y": key.Y}
+	}
+	jsonValue, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(jsonValue)
+	return base64.RawURLEncoding.EncodeToString(hash[:]), nil
+}
+
+// FindKey returns key with specified kid, if kid is empty key set must contain exactly one key
+func (set *JsonWebKeySet) FindKey(kid string) *JsonWebKey {
+	if len(kid) == 0 {
+		if len(set.Keys) == 1 {
+			return &set.Keys[0]
+		}
+		return nil
+	}
+	for i := range set.Keys {
+		if set.Keys[i].Kid == kid {
+			return &set.Keys[i]
+		}
+	}
+	return nil
+}
+
+func decodeKeyParameter(value string) (*big.Int, error) {
+	if len(value) == 0 {
+		return nil, errUnsupportedKey
+	}
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
@@ -0,0 +1,27 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJsonWebKeyThumbprint(t *testing.T) {
+	// example key and thumbprint are taken from RFC 7638 section 3.1
+	key := JsonWebKey{Kty: "RSA", Kid: "2011-04-29", Alg: "RS256", E: "AQAB",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"}
+	thumbprint, err := key.Thumbprint()
+	require.NoError(t, err)
+	assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+
+	// optional members do not change thumbprint
+	key.Kid = ""
+	key.Use = "sig"
+	otherThumbprint, err := key.Thumbprint()
+	require.NoError(t, err)
+	assert.Equal(t, thumbprint, otherThumbprint)
+
+	_, err = (&JsonWebKey{Kty: "oct"}).Thumbprint()
+	assert.Error(t, err)
+}
@@ -3,17 +3,42 @@ package data
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ohler55/ojg/jp"
+	"github.com/wissance/Ferrum/utils/hashing"
 )
 
 const (
-	pathToPassword = "credentials.password"
+	pathToCredentials     = "credentials"
+	pathToPassword        = "credentials.password"
+	pathToSecretData      = "credentials.secretData"
+	pathToCredentialData  = "credentials.credentialData"
+	pathToPasswordHistory = "credentials.history"
+	pathToPasswordChanged = "credentials.changed"
+	pathToOtp             = "credentials.otp"
+	pathToWebAuthn        = "credentials.webauthn"
+	pathToActionTokens    = "credentials.action_tokens"
+	pathToRequiredActions = "credentials.required_actions"
+	pathToEmail           = "info.email"
+	enabledKey            = "enabled"
+	notBeforeKey          = "not_before"
+	consentsKey           = "consents"
+	validUntilKey         = "valid_until"
+	passwordKey           = "password"
+	passwordHistoryKey    = "history"
+	passwordChangedKey    = "changed"
+	otpKey                = "otp"
+	webAuthnKey           = "webauthn"
+	actionTokensKey       = "action_tokens"
+	requiredActionsKey    = "required_actions"
+	emailVerifiedKey      = "email_verified"
 )
 
 // KeyCloakUser this structure is for user data that looks similar to KeyCloak, Users in Keycloak have info field with preferred_username and sub
-// and others fields, Ferrum users have credentials built-in in user (temporary it stores in non encrypted mode)
+// and others fields, Ferrum users have credentials built-in in user, password is stored as a hash (credentials.password) or as KeyCloak
+// pbkdf2 credential (credentials.secretData && credentials.credentialData) for users that were migrated from KeyCloak
 type KeyCloakUser struct {
 	rawData     interface{}
 	jsonRawData string
@@ -42,26 +67,325 @@ func (user *KeyCloakUser) GetUsername() string {
 	return getPathStringValue[string](user.rawData, "info.preferred_username")
 }
 
-// GetPassword returns password
-/* this function use internal map to navigate over credentials.password keys to retrieve a password
+// GetPassword returns password hash
+/* this function use internal map to navigate over credentials.password keys to retrieve a password hash, if user was migrated from KeyCloak
+ * and has no credentials.password, KeyCloak pbkdf2 credential (secretData && credentialData) is encoded to hashing.CheckPassword format.
+ * Users that were created before password hashing have password in plain text here
  * Parameters: no
- * Returns: password
+ * Returns: password hash (empty string if user has no password)
  */
 func (user *KeyCloakUser) GetPassword() string {
-	return getPathStringValue[string](user.rawData, pathToPassword)
+	password := getPathStringValue[string](user.rawData, pathToPassword)
+	if len(password) > 0 {
+		return password
+	}
+	return user.getKeyCloakPasswordHash()
 }
 
+// SetPassword stores password hash (argon2id) in user credentials
+/* this function replaces any previous password credential (including KeyCloak pbkdf2 credential) with a hash of a new password,
+ * previous password hash is kept in credentials.history (up to MaxPasswordHistory hashes) and credentials.changed is set to
+ * current time (unix seconds) to check password policy history and max age
+ * Parameters:
+ *    - password - new password in plain text
+ * Returns: error if hash calculation or credentials update failed
+ */
 func (user *KeyCloakUser) SetPassword(password string) error {
-	mask, err := jp.ParseString(pathToPassword)
+	history := user.GetPasswordHistory()
+	previous := user.GetPassword()
+	// password in plain text (user was created before password hashing or is created now) is not kept in history
+	if hashing.IsPasswordHash(previous) {
+		history = append([]string{previous}, history...)
+		if len(history) > MaxPasswordHistory {
+			history = history[:MaxPasswordHistory]
+		}
+	}
+	if err := user.storePasswordHash(password); err != nil {
+		return err
+	}
+	credentials := user.getCredentials()
+	credentials[passwordHistoryKey] = history
+	credentials[passwordChangedKey] = time.Now().Unix()
+	user.updateJsonString()
+	return nil
+}
+
+// RehashPassword replaces stored password hash with a new hash of the same password (i.e. hash algorithm or parameters were changed)
+/* unlike SetPassword this function keeps password history and password change time
+ * Parameters:
+ *    - password - current password in plain text
+ * Returns: error if hash calculation or credentials update failed
+ */
+func (user *KeyCloakUser) RehashPassword(password string) error {
+	if err := user.storePasswordHash(password); err != nil {
+		return err
+	}
+	user.updateJsonString()
+	return nil
+}
+
+// GetPasswordHistory returns hashes of previous passwords (the latest one is first)
+func (user *KeyCloakUser) GetPasswordHistory() []string {
+	var values []interface{}
+	switch v := getPathStringValue[interface{}](user.rawData, pathToPasswordHistory).(type) {
+	case []interface{}:
+		values = v
+	case []string:
+		// history that was set by SetPassword and was not marshalled yet
+		return append([]string{}, v...)
+	}
+	history := make([]string, 0, len(values))
+	for _, v := range values {
+		if passwordHash, ok := v.(string); ok {
+			history = append(history, passwordHash)
+		}
+	}
+	return history
+}
+
+// GetPasswordChanged returns time of last password change or zero time if it is unknown (password was set outside of Ferrum)
+func (user *KeyCloakUser) GetPasswordChanged() time.Time {
+	return getUnixTime(getPathStringValue[interface{}](user.rawData, pathToPasswordChanged))
+}
+
+// GetOtpCredential returns user TOTP credential or nil if user has no OTP configured
+func (user *KeyCloakUser) GetOtpCredential() *OtpCredential {
+	var credential OtpCredential
+	if !readJsonValue(getPathStringValue[interface{}](user.rawData, pathToOtp), &credential) || len(credential.Secret) == 0 {
+		return nil
+	}
+	return &credential
+}
+
+// SetOtpCredential stores user TOTP credential in credentials.otp
+/* credential is stored as json object (not as struct) to keep raw user data a plain json
+ * Parameters:
+ *    - credential - new credential, nil removes OTP from user
+ * Returns: error if user data is not a json object
+ */
+func (user *KeyCloakUser) SetOtpCredential(credential *OtpCredential) error {
+	if credential == nil {
+		return user.setCredential(otpKey, nil)
+	}
+	return user.setCredential(otpKey, credential)
+}
+
+// GetWebAuthnCredentials returns user passkeys (empty slice if user has no passkeys)
+func (user *KeyCloakUser) GetWebAuthnCredentials() []WebAuthnCredential {
+	var credentials []WebAuthnCredential
+	if !readJsonValue(getPathStringValue[interface{}](user.rawData, pathToWebAuthn), &credentials) {
+		return []WebAuthnCredential{}
+	}
+	return credentials
+}
+
+// SetWebAuthnCredentials replaces user passkeys (credentials.webauthn), empty slice removes all passkeys
+func (user *KeyCloakUser) SetWebAuthnCredentials(credentials []WebAuthnCredential) error {
+	if len(credentials) == 0 {
+		return user.setCredential(webAuthnKey, nil)
+	}
+	return user.setCredential(webAuthnKey, credentials)
+}
+
+// GetActionTokens returns issued email action tokens (empty slice if user has no tokens)
+func (user *KeyCloakUser) GetActionTokens() []ActionToken {
+	var tokens []ActionToken
+	if !readJsonValue(getPathStringValue[interface{}](user.rawData, pathToActionTokens), &tokens) {
+		return []ActionToken{}
+	}
+	return tokens
+}
+
+// SetActionTokens replaces email action tokens (credentials.action_tokens), empty slice removes all tokens
+func (user *KeyCloakUser) SetActionTokens(tokens []ActionToken) error {
+	if len(tokens) == 0 {
+		return user.setCredential(actionTokensKey, nil)
+	}
+	return user.setCredential(actionTokensKey, tokens)
+}
+
+// GetRequiredActions returns actions that user must execute before login (empty slice if user has no pending actions)
+func (user *KeyCloakUser) GetRequiredActions() []string {
+	var actions []string
+	if !readJsonValue(getPathStringValue[interface{}](user.rawData, pathToRequiredActions), &actions) {
+		return []string{}
+	}
+	return actions
+}
+
+// SetRequiredActions replaces user required actions (credentials.required_actions), empty slice removes all actions
+func (user *KeyCloakUser) SetRequiredActions(actions []string) error {
+	if len(actions) == 0 {
+		return user.setCredential(requiredActionsKey, nil)
+	}
+	return user.setCredential(requiredActionsKey, actions)
+}
+
+// GetConsents returns scopes that user granted to clients on consent screen (empty slice if user has no consents)
+func (user *KeyCloakUser) GetConsents() []UserConsent {
+	var consents []UserConsent
+	if !readJsonValue(getPathStringValue[interface{}](user.rawData, consentsKey), &consents) {
+		return []UserConsent{}
+	}
+	return consents
+}
+
+// SetConsents replaces user consents (top-level consents like KeyCloak user clientConsents), empty slice removes all consents
+func (user *KeyCloakUser) SetConsents(consents []UserConsent) error {
+	if len(consents) == 0 {
+		return user.setRawValue(consentsKey, nil)
+	}
+	value, err := toPlainJson(consents)
+	if err != nil {
+		return err
+	}
+	return user.setRawValue(consentsKey, value)
+}
+
+// IsEnabled returns user enabled flag (top-level enabled like KeyCloak user has), user without this flag is enabled
+func (user *KeyCloakUser) IsEnabled() bool {
+	enabled, ok := getPathStringValue[interface{}](user.rawData, enabledKey).(bool)
+	return !ok || enabled
+}
+
+// SetEnabled sets user enabled flag, disabled user can't log in, refresh tokens and his tokens are not active
+func (user *KeyCloakUser) SetEnabled(enabled bool) error {
+	return user.setRawValue(enabledKey, enabled)
+}
+
+// GetNotBefore returns time before that user can't log in (not_before, unix seconds) or zero time if it is not set
+func (user *KeyCloakUser) GetNotBefore() time.Time {
+	return getUnixTime(getPathStringValue[interface{}](user.rawData, notBeforeKey))
+}
+
+// GetValidUntil returns time after that user can't log in (valid_until, unix seconds) or zero time if it is not set
+func (user *KeyCloakUser) GetValidUntil() time.Time {
+	return getUnixTime(getPathStringValue[interface{}](user.rawData, validUntilKey))
+}
+
+// SetValidity sets user account validity window, zero time removes corresponding bound
+func (user *KeyCloakUser) SetValidity(notBefore time.Time, validUntil time.Time) error {
+	for key, value := range map[string]time.Time{notBeforeKey: notBefore, validUntilKey: validUntil} {
+		var rawValue interface{}
+		if !value.IsZero() {
+			rawValue = value.Unix()
+		}
+		if err := user.setRawValue(key, rawValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetEmail returns user email (info.email)
+func (user *KeyCloakUser) GetEmail() string {
+	return getPathStringValue[string](user.rawData, pathToEmail)
+}
+
+// SetEmailVerified sets info.email_verified, this value is a part of userinfo and tokens like KeyCloak do
+func (user *KeyCloakUser) SetEmailVerified(verified bool) error {
+	return user.SetInfoValue(emailVerifiedKey, verified)
+}
+
+// SetInfoValue sets user info (userinfo and tokens claims) attribute, nil value removes attribute
+func (user *KeyCloakUser) SetInfoValue(name string, value interface{}) error {
+	rawData, ok := user.rawData.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("user data is not a json object")
+	}
+	info, ok := rawData["info"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("user has no info object")
+	}
+	if value == nil {
+		delete(info, name)
+	} else {
+		info[name] = value
+	}
+	user.updateJsonString()
+	return nil
+}
+
+// setRawValue sets top-level user data value, nil value removes key
+func (user *KeyCloakUser) setRawValue(key string, value interface{}) error {
+	rawData, ok := user.rawData.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("user data is not a json object")
+	}
+	if value == nil {
+		delete(rawData, key)
+	} else {
+		rawData[key] = value
+	}
+	user.updateJsonString()
+	return nil
+}
+
+// setCredential stores value as plain json (maps and slices, not structs) in credentials[key], nil value removes credential
+func (user *KeyCloakUser) setCredential(key string, value interface{}) error {
+	credentials := user.getCredentials()
+	if credentials == nil {
+		return fmt.Errorf("user data is not a json object")
+	}
+	if value == nil {
+		delete(credentials, key)
+	} else {
+		credential, err := toPlainJson(value)
+		if err != nil {
+			return err
+		}
+		credentials[key] = credential
+	}
+	user.updateJsonString()
+	return nil
+}
+
+// toPlainJson converts value (i.e. struct) to json maps and slices that raw user data consists of
+func toPlainJson(value interface{}) (interface{}, error) {
+	jsonData, err := json.Marshal(value)
 	if err != nil {
-		return fmt.Errorf("jp.ParseString failed: %w", err)
+		return nil, err
 	}
-	if err := mask.Set(user.rawData, password); err != nil {
-		return fmt.Errorf("jp.Set failed: %w", err)
+	var result interface{}
+	if err = json.Unmarshal(jsonData, &result); err != nil {
+		return nil, err
 	}
+	return result, nil
+}
+
+// storePasswordHash calculates password hash and replaces password credential with it
+func (user *KeyCloakUser) storePasswordHash(password string) error {
+	passwordHash, err := hashing.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hashing.HashPassword failed: %w", err)
+	}
+	credentials := user.getCredentials()
+	if credentials == nil {
+		return fmt.Errorf("user data is not a json object")
+	}
+	delete(credentials, "secretData")
+	delete(credentials, "credentialData")
+	credentials[passwordKey] = passwordHash
+	return nil
+}
+
+// getCredentials returns credentials object, it is created if user has no credentials
+func (user *KeyCloakUser) getCredentials() map[string]interface{} {
+	rawData, ok := user.rawData.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	credentials, ok := rawData[pathToCredentials].(map[string]interface{})
+	if !ok {
+		credentials = map[string]interface{}{}
+		rawData[pathToCredentials] = credentials
+	}
+	return credentials
+}
+
+func (user *KeyCloakUser) updateJsonString() {
 	jsonData, _ := json.Marshal(user.rawData)
 	user.jsonRawData = string(jsonData)
-	return nil
 }
 
 // GetId returns unique user identifier
@@ -71,10 +395,8 @@ func (user *KeyCloakUser) SetPassword(password string) error {
  */
 func (user *KeyCloakUser) GetId() uuid.UUID {
 	idStrValue := getPathStringValue[string](user.rawData, "info.sub")
-	id, err := uuid.Parse(idStrValue)
-	if err != nil {
-		// todo(UMV): think what to do here, return error!
-	}
+	// data managers store only users with valid info.sub (see ValidateUser), zero UUID means user that wasn't stored yet
+	id, _ := uuid.Parse(idStrValue)
 	return id
 }
 
@@ -96,6 +418,62 @@ func (user *KeyCloakUser) GetJsonString() string {
 	return user.jsonRawData
 }
 
+// getKeyCloakPasswordHash returns KeyCloak pbkdf2 credential encoded with hashing.EncodePbkdf2Hash
+/* KeyCloak export stores secretData ({"value": "...", "salt": "..."}) and credentialData ({"hashIterations": 27500,
+ * "algorithm": "pbkdf2-sha256"}) as json strings, we accept both json strings and objects
+ * Parameters: no
+ * Returns: encoded hash or empty string if user has no valid KeyCloak credential
+ */
+func (user *KeyCloakUser) getKeyCloakPasswordHash() string {
+	secretData := struct {
+		Value string `json:"value"`
+		Salt  string `json:"salt"`
+	}{}
+	credentialData := struct {
+		HashIterations int    `json:"hashIterations"`
+		Algorithm      string `json:"algorithm"`
+	}{}
+	if !readJsonValue(getPathStringValue[interface{}](user.rawData, pathToSecretData), &secretData) ||
+		!readJsonValue(getPathStringValue[interface{}](user.rawData, pathToCredentialData), &credentialData) {
+		return ""
+	}
+	passwordHash, err := hashing.EncodePbkdf2Hash(credentialData.Algorithm, credentialData.HashIterations, secretData.Salt, secretData.Value)
+	if err != nil {
+		return ""
+	}
+	return passwordHash
+}
+
+// readJsonValue unmarshalls value that is either json string or already parsed json object into result
+func readJsonValue(value interface{}, result interface{}) bool {
+	var jsonData []byte
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		jsonData = []byte(v)
+	default:
+		jsonData, _ = json.Marshal(v)
+	}
+	return json.Unmarshal(jsonData, result) == nil
+}
+
+// getUnixTime converts json number of unix seconds to time, any other value is a zero time
+func getUnixTime(value interface{}) time.Time {
+	var seconds int64
+	switch v := value.(type) {
+	case float64:
+		seconds = int64(v)
+	case int64:
+		seconds = v
+	case int:
+		seconds = int64(v)
+	default:
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
 // getPathStringValue is a generic function to get actually map by key, key represents as a jsonpath navigation property
 /* this function uses json path to navigate over nested maps and return any required type
  * Parameters:
@@ -2,9 +2,14 @@ package data
 
 import (
 	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/utils/hashing"
 	sf "github.com/wissance/stringFormatter"
-	"testing"
 )
 
 func TestInitUserWithJsonAndCheck(t *testing.T) {
@@ -29,3 +34,207 @@ func TestInitUserWithJsonAndCheck(t *testing.T) {
 		})
 	}
 }
+
+// KeyCloak pbkdf2-sha256 credential of "keycloak_password" password (27500 iterations)
+const (
+	testKeyCloakSalt = "ZmVycnVtLWtjLXNhbHQxNg=="
+	testKeyCloakHash = "4GwXh4B3vdGnAioqUKtdtwxY0m7lAVXP3PsAb0oGQkp1QbQAzk4UtoK5HdVDJJfb9JZME+qJRb8HlILQeVK97g=="
+)
+
+func TestSetPasswordStoresHash(t *testing.T) {
+	testCases := []struct {
+		name     string
+		userJson string
+	}{
+		{name: "user_with_plain_password", userJson: `{"info":{"preferred_username": "admin"}, "credentials":{"password": "1234567890"}}`},
+		{name: "user_with_keycloak_credential", userJson: `{"info":{"preferred_username": "admin"}, "credentials":{` +
+			`"secretData": "{\"value\":\"` + testKeyCloakHash + `\",\"salt\":\"` + testKeyCloakSalt + `\"}",` +
+			`"credentialData": "{\"hashIterations\":27500,\"algorithm\":\"pbkdf2-sha256\"}"}}`},
+	}
+
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var rawUserData interface{}
+			err := json.Unmarshal([]byte(tc.userJson), &rawUserData)
+			require.NoError(t, err)
+			user := CreateUser(rawUserData)
+			err = user.SetPassword("new_password")
+			require.NoError(t, err)
+			passwordHash := user.GetPassword()
+			assert.True(t, strings.HasPrefix(passwordHash, "$argon2id$"))
+			matches, rehash := hashing.CheckPassword("new_password", passwordHash)
+			assert.True(t, matches)
+			assert.False(t, rehash)
+			assert.NotContains(t, user.GetJsonString(), "new_password")
+			assert.NotContains(t, user.GetJsonString(), "secretData")
+		})
+	}
+}
+
+func TestGetPasswordOfKeyCloakUser(t *testing.T) {
+	secretData := sf.Format(`{"value":"{0}","salt":"{1}"}`, testKeyCloakHash, testKeyCloakSalt)
+	testCases := []struct {
+		name            string
+		credentials     map[string]interface{}
+		expectedMatches bool
+	}{
+		{name: "json_string_credential", credentials: map[string]interface{}{"secretData": secretData,
+			"credentialData": `{"hashIterations":27500,"algorithm":"pbkdf2-sha256"}`}, expectedMatches: true},
+		{name: "json_object_credential", credentials: map[string]interface{}{
+			"secretData":     map[string]interface{}{"value": testKeyCloakHash, "salt": testKeyCloakSalt},
+			"credentialData": map[string]interface{}{"hashIterations": 27500, "algorithm": "pbkdf2-sha256"}}, expectedMatches: true},
+		{name: "unsupported_algorithm", credentials: map[string]interface{}{"secretData": secretData,
+			"credentialData": `{"hashIterations":27500,"algorithm":"md5"}`}, expectedMatches: false},
+		{name: "without_credential_data", credentials: map[string]interface{}{"secretData": secretData}, expectedMatches: false},
+	}
+
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin"},
+				"credentials": tc.credentials})
+			matches, _ := hashing.CheckPassword("keycloak_password", user.GetPassword())
+			assert.Equal(t, tc.expectedMatches, matches)
+		})
+	}
+}
+
+func TestSetPasswordKeepsHistory(t *testing.T) {
+	user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin"},
+		"credentials": map[string]interface{}{"password": "plain_password"}})
+	err := user.SetPassword("password_0")
+	require.NoError(t, err)
+	// password in plain text is not kept in history
+	assert.Empty(t, user.GetPasswordHistory())
+	assert.WithinDuration(t, time.Now(), user.GetPasswordChanged(), time.Minute)
+
+	for i := 1; i <= MaxPasswordHistory+1; i++ {
+		err = user.SetPassword(sf.Format("password_{0}", i))
+		require.NoError(t, err)
+	}
+	history := user.GetPasswordHistory()
+	assert.Equal(t, MaxPasswordHistory, len(history))
+	matches, _ := hashing.CheckPassword(sf.Format("password_{0}", MaxPasswordHistory), history[0])
+	assert.True(t, matches)
+
+	// rehash keeps history, history survives json round trip
+	err = user.RehashPassword(sf.Format("password_{0}", MaxPasswordHistory+1))
+	require.NoError(t, err)
+	var rawUserData interface{}
+	err = json.Unmarshal([]byte(user.GetJsonString()), &rawUserData)
+	require.NoError(t, err)
+	restored := CreateUser(rawUserData)
+	assert.Equal(t, history, restored.GetPasswordHistory())
+	assert.Equal(t, user.GetPasswordChanged(), restored.GetPasswordChanged())
+}
+
+func TestOtpCredential(t *testing.T) {
+	user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin"},
+		"credentials": map[string]interface{}{"password": "plain_password"}})
+	assert.Nil(t, user.GetOtpCredential())
+
+	credential := OtpCredential{Secret: "JBSWY3DPEHPK3PXP", LastCounter: 57000000, RecoveryCodes: []string{"hash1", "hash2"}}
+	err := user.SetOtpCredential(&credential)
+	require.NoError(t, err)
+	assert.Equal(t, &credential, user.GetOtpCredential())
+	// credential survives json round trip and doesn't affect password
+	var rawUserData interface{}
+	err = json.Unmarshal([]byte(user.GetJsonString()), &rawUserData)
+	require.NoError(t, err)
+	restored := CreateUser(rawUserData)
+	assert.Equal(t, &credential, restored.GetOtpCredential())
+	assert.Equal(t, "plain_password", restored.GetPassword())
+
+	err = restored.SetOtpCredential(nil)
+	require.NoError(t, err)
+	assert.Nil(t, restored.GetOtpCredential())
+	assert.NotContains(t, restored.GetJsonString(), "otp")
+}
+
+func TestActionTokensAndEmailVerified(t *testing.T) {
+	user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin", "email": "admin@ferrum.test"},
+		"credentials": map[string]interface{}{"password": "plain_password"}})
+	assert.Equal(t, "admin@ferrum.test", user.GetEmail())
+	assert.Empty(t, user.GetActionTokens())
+
+	tokens := []ActionToken{{TokenHash: "hash1", Actions: []string{VerifyEmailAction}, Email: "admin@ferrum.test", Expires: 1700000000}}
+	require.NoError(t, user.SetActionTokens(tokens))
+	require.NoError(t, user.SetEmailVerified(true))
+	var rawUserData interface{}
+	require.NoError(t, json.Unmarshal([]byte(user.GetJsonString()), &rawUserData))
+	restored := CreateUser(rawUserData)
+	assert.Equal(t, tokens, restored.GetActionTokens())
+	assert.Equal(t, true, restored.GetUserInfo().(map[string]interface{})["email_verified"])
+
+	require.NoError(t, restored.SetActionTokens(nil))
+	assert.NotContains(t, restored.GetJsonString(), "action_tokens")
+}
+
+func TestRequiredActions(t *testing.T) {
+	user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin"},
+		"credentials": map[string]interface{}{"password": "plain_password", "required_actions": []interface{}{TermsAndConditionsAction}}})
+	assert.True(t, HasRequiredAction(user, TermsAndConditionsAction))
+	require.NoError(t, AddRequiredAction(user, UpdatePasswordAction))
+	require.NoError(t, AddRequiredAction(user, UpdatePasswordAction))
+	assert.Equal(t, []string{TermsAndConditionsAction, UpdatePasswordAction}, user.GetRequiredActions())
+
+	removed, err := RemoveRequiredAction(user, ConfigureTotpAction)
+	require.NoError(t, err)
+	assert.False(t, removed)
+	removed, err = RemoveRequiredAction(user, TermsAndConditionsAction)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	var rawUserData interface{}
+	require.NoError(t, json.Unmarshal([]byte(user.GetJsonString()), &rawUserData))
+	restored := CreateUser(rawUserData)
+	assert.Equal(t, []string{UpdatePasswordAction}, restored.GetRequiredActions())
+
+	_, err = RemoveRequiredAction(restored, UpdatePasswordAction)
+	require.NoError(t, err)
+	assert.Empty(t, restored.GetRequiredActions())
+	assert.NotContains(t, restored.GetJsonString(), "required_actions")
+}
+
+func TestUserEnabledAndValidity(t *testing.T) {
+	user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin"}})
+	assert.True(t, user.IsEnabled())
+	assert.True(t, user.GetNotBefore().IsZero())
+	assert.True(t, user.GetValidUntil().IsZero())
+
+	notBefore := time.Unix(1767225600, 0)
+	validUntil := time.Unix(1798761599, 0)
+	require.NoError(t, user.SetEnabled(false))
+	require.NoError(t, user.SetValidity(notBefore, validUntil))
+	var rawUserData interface{}
+	require.NoError(t, json.Unmarshal([]byte(user.GetJsonString()), &rawUserData))
+	restored := CreateUser(rawUserData)
+	assert.False(t, restored.IsEnabled())
+	assert.Equal(t, notBefore, restored.GetNotBefore())
+	assert.Equal(t, validUntil, restored.GetValidUntil())
+
+	require.NoError(t, restored.SetValidity(time.Time{}, time.Time{}))
+	assert.NotContains(t, restored.GetJsonString(), "not_before")
+	assert.NotContains(t, restored.GetJsonString(), "valid_until")
+}
+
+func TestUserConsents(t *testing.T) {
+	user := CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "admin"}})
+	assert.Empty(t, user.GetConsents())
+	assert.False(t, IsConsentGranted(user, "app", ""))
+	require.NoError(t, user.SetConsents([]UserConsent{{ClientId: "app", Scopes: []string{"openid", "email"}, Created: 1700000000}}))
+
+	var rawUserData interface{}
+	require.NoError(t, json.Unmarshal([]byte(user.GetJsonString()), &rawUserData))
+	restored := CreateUser(rawUserData)
+	assert.Equal(t, int64(1700000000), FindConsent(restored, "app").Created)
+	assert.True(t, IsConsentGranted(restored, "app", "email openid"))
+	assert.True(t, IsConsentGranted(restored, "app", ""))
+	assert.False(t, IsConsentGranted(restored, "app", "openid phone"))
+	assert.False(t, IsConsentGranted(restored, "other", "openid"))
+
+	require.NoError(t, restored.SetConsents(nil))
+	assert.NotContains(t, restored.GetJsonString(), "consents")
+}
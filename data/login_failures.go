@@ -0,0 +1,84 @@
+package data
+
+import "time"
+
+// Brute-force protection defaults that are used if realm BruteForceProtection values are not set
+const (
+	DefaultLoginWaitIncrement    = 60
+	DefaultLoginMaxWait          = 900
+	DefaultLoginFailureResetTime = 43200
+)
+
+// BruteForceProtection is a realm brute-force detection settings
+/* Failed logins are counted per user and per client ip address:
+ *    - MaxLoginFailures - number of user failed logins after which user is temporarily locked (0 - user failures are not counted)
+ *    - MaxIpLoginFailures - number of failed logins from one ip address after which address is temporarily locked (0 - not counted)
+ *    - WaitIncrement - lockout duration (seconds), every next lockout is WaitIncrement longer than previous one
+ *    - MaxWait - maximum lockout duration (seconds)
+ *    - FailureResetTime - time (seconds) after the last failure when failures counter is reset
+ *    - PermanentLockout - user is locked permanently (until admin unlocks it) after MaxTemporaryLockouts temporary lockouts,
+ *      ip addresses are never locked permanently
+ */
+type BruteForceProtection struct {
+	MaxLoginFailures     int  `json:"max_login_failures"`
+	MaxIpLoginFailures   int  `json:"max_ip_login_failures,omitempty"`
+	WaitIncrement        int  `json:"wait_increment,omitempty"`
+	MaxWait              int  `json:"max_wait,omitempty"`
+	FailureResetTime     int  `json:"failure_reset_time,omitempty"`
+	PermanentLockout     bool `json:"permanent_lockout,omitempty"`
+	MaxTemporaryLockouts int  `json:"max_temporary_lockouts,omitempty"`
+}
+
+// LoginFailures is a counter of failed logins of a user or from an ip address, it is stored in a data source to be shared between
+// server instances, Expires is a time when counter could be removed (zero value - never, i.e. permanent lockout)
+type LoginFailures struct {
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	Lockouts    int       `json:"lockouts"`
+	LastFailure time.Time `json:"last_failure"`
+	LockedUntil time.Time `json:"locked_until,omitempty"`
+	Permanent   bool      `json:"permanent,omitempty"`
+	Expires     time.Time `json:"expires,omitempty"`
+}
+
+// UserLoginFailuresKey returns LoginFailures key of a user
+func UserLoginFailuresKey(userName string) string {
+	return "user:" + userName
+}
+
+// IpLoginFailuresKey returns LoginFailures key of a client ip address
+func IpLoginFailuresKey(address string) string {
+	return "ip:" + address
+}
+
+// IsLocked checks whether user or ip address is locked at the moment
+func (failures *LoginFailures) IsLocked(now time.Time) bool {
+	return failures.Permanent || now.Before(failures.LockedUntil)
+}
+
+// IsExpired checks whether counter is outdated and must be ignored
+func (failures *LoginFailures) IsExpired(now time.Time) bool {
+	return !failures.Expires.IsZero() && !now.Before(failures.Expires)
+}
+
+// GetWaitIncrement returns WaitIncrement or default value if it is not set
+func (protection *BruteForceProtection) GetWaitIncrement() time.Duration {
+	return getSecondsOrDefault(protection.WaitIncrement, DefaultLoginWaitIncrement)
+}
+
+// GetMaxWait returns MaxWait or default value if it is not set
+func (protection *BruteForceProtection) GetMaxWait() time.Duration {
+	return getSecondsOrDefault(protection.MaxWait, DefaultLoginMaxWait)
+}
+
+// GetFailureResetTime returns FailureResetTime or default value if it is not set
+func (protection *BruteForceProtection) GetFailureResetTime() time.Duration {
+	return getSecondsOrDefault(protection.FailureResetTime, DefaultLoginFailureResetTime)
+}
+
+func getSecondsOrDefault(value int, defaultValue int) time.Duration {
+	if value <= 0 {
+		value = defaultValue
+	}
+	return time.Duration(value) * time.Second
+}
@@ -0,0 +1,64 @@
+package data
+
+// OTP policy defaults that are used if realm OtpPolicy values are not set (these values are supported by all authenticator apps)
+const (
+	DefaultOtpDigits        = 6
+	DefaultOtpPeriod        = 30
+	DefaultOtpAlgorithm     = "SHA1"
+	DefaultOtpLookAhead     = 1
+	DefaultOtpRecoveryCodes = 10
+)
+
+// OtpPolicy is a realm time-based one-time password (RFC 6238) second factor settings
+/* Zero value of any property (except LookAhead) means that default value is used:
+ *    - Digits - code length (6 or 8)
+ *    - Period - time step (seconds)
+ *    - Algorithm - HMAC algorithm (SHA1, SHA256 or SHA512)
+ *    - LookAhead - number of time steps before and after current one when code is also accepted (clock drift compensation)
+ *    - RecoveryCodes - number of one-time recovery codes that are generated on enrollment
+ *    - Required - every user of the realm must have OTP configured, login of users without OTP is denied
+ *    - RequiredRoles - users that have any of these roles (user info roles) must have OTP configured (i.e. admin accounts)
+ */
+type OtpPolicy struct {
+	Digits        int      `json:"digits,omitempty"`
+	Period        int      `json:"period,omitempty"`
+	Algorithm     string   `json:"algorithm,omitempty"`
+	LookAhead     int      `json:"look_ahead"`
+	RecoveryCodes int      `json:"recovery_codes,omitempty"`
+	Required      bool     `json:"required,omitempty"`
+	RequiredRoles []string `json:"required_roles,omitempty"`
+}
+
+// OtpCredential is a user TOTP credential that is stored in user credentials.otp
+/* Secret is a base32 encoded shared secret (it is required to calculate codes therefore it can't be hashed), LastCounter is a time
+ * step of the last accepted code (code can't be used twice), RecoveryCodes are hashes of unused one-time recovery codes
+ */
+type OtpCredential struct {
+	Secret        string   `json:"secret"`
+	LastCounter   int64    `json:"last_counter,omitempty"`
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// GetOtpPolicy returns realm OtpPolicy with default values instead of unset values, realm without policy gets default policy
+func (realm *Realm) GetOtpPolicy() OtpPolicy {
+	policy := OtpPolicy{LookAhead: DefaultOtpLookAhead}
+	if realm.OtpPolicy != nil {
+		policy = *realm.OtpPolicy
+	}
+	if policy.Digits <= 0 {
+		policy.Digits = DefaultOtpDigits
+	}
+	if policy.Period <= 0 {
+		policy.Period = DefaultOtpPeriod
+	}
+	if len(policy.Algorithm) == 0 {
+		policy.Algorithm = DefaultOtpAlgorithm
+	}
+	if policy.LookAhead < 0 {
+		policy.LookAhead = 0
+	}
+	if policy.RecoveryCodes <= 0 {
+		policy.RecoveryCodes = DefaultOtpRecoveryCodes
+	}
+	return policy
+}
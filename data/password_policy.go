@@ -0,0 +1,27 @@
+package data
+
+// MaxPasswordHistory is a maximum number of previous password hashes that are kept in user credentials
+const MaxPasswordHistory = 24
+
+// PasswordPolicy is a realm password policy, it is checked on every password set (CLI, admin API and self-service)
+/* Zero value of any property means that this rule is not applied:
+ *    - MinLength, MaxLength - password length limits (in characters)
+ *    - MinDigits, MinLowerCase, MinUpperCase, MinSpecialChars - minimal number of characters of each class
+ *    - NotUsername, NotEmail - password must not be equal to username or email (case-insensitive)
+ *    - History - password must not be equal to current or any of History - 1 previous passwords (up to MaxPasswordHistory)
+ *    - MaxAge - number of days after password change when password expires and login with it is denied
+ *    - DenylistFile - path to a file with forbidden passwords (one per line, case-insensitive)
+ */
+type PasswordPolicy struct {
+	MinLength       int    `json:"min_length,omitempty"`
+	MaxLength       int    `json:"max_length,omitempty"`
+	MinDigits       int    `json:"min_digits,omitempty"`
+	MinLowerCase    int    `json:"min_lower_case,omitempty"`
+	MinUpperCase    int    `json:"min_upper_case,omitempty"`
+	MinSpecialChars int    `json:"min_special_chars,omitempty"`
+	NotUsername     bool   `json:"not_username,omitempty"`
+	NotEmail        bool   `json:"not_email,omitempty"`
+	History         int    `json:"history,omitempty"`
+	MaxAge          int    `json:"max_age,omitempty"`
+	DenylistFile    string `json:"denylist_file,omitempty"`
+}
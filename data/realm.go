@@ -4,11 +4,49 @@ package data
 /* It was originally designed to efficiently work in memory with small amount of data therefore it contains relations with Clients and Users
  * But in a systems with thousands of users working at the same time it is too expensive to fetch Realm with all relations therefore
  * in such systems Clients && Users would be empty, and we should to get User or Client separately
+ * InitialAccessTokens are tokens that allow dynamic client registration, PasswordPolicy is checked on every user password set,
+ * BruteForceProtection limits number of failed logins, OtpPolicy configures TOTP second factor, WebAuthn enables passkeys,
+ * Email enables email-based flows (verify email, reset password, execute actions), disabled realm (Enabled is false) doesn't
+ * allow any authentication, realm without Enabled value is enabled, SsoSessionLifespan is a lifetime (seconds) of browser
+ * login (identity cookie) after which user must enter credentials again, LoginTheme is a name of login pages theme
+ * (default theme if not set), RegistrationAllowed enables self-service registration of users with UserProfile attributes,
+ * VerifyEmail requires registered users to verify email before login, Roles are realm roles definitions and Groups are sets of users
+ * with common realm roles (see role.go)
  */
 type Realm struct {
-	Name                   string        `json:"name"`
-	Clients                []Client      `json:"clients"`
-	Users                  []interface{} `json:"users"`
-	TokenExpiration        int           `json:"token_expiration"`
-	RefreshTokenExpiration int           `json:"refresh_expiration"`
+	Name                   string                `json:"name"`
+	Enabled                *bool                 `json:"enabled,omitempty"`
+	Clients                []Client              `json:"clients"`
+	Users                  []interface{}         `json:"users"`
+	TokenExpiration        int                   `json:"token_expiration"`
+	RefreshTokenExpiration int                   `json:"refresh_expiration"`
+	InitialAccessTokens    []InitialAccessToken  `json:"initial_access_tokens,omitempty"`
+	PasswordPolicy         *PasswordPolicy       `json:"password_policy,omitempty"`
+	BruteForceProtection   *BruteForceProtection `json:"brute_force_protection,omitempty"`
+	OtpPolicy              *OtpPolicy            `json:"otp_policy,omitempty"`
+	WebAuthn               *WebAuthnSettings     `json:"webauthn,omitempty"`
+	Email                  *EmailSettings        `json:"email,omitempty"`
+	SsoSessionLifespan     int                   `json:"sso_session_lifespan,omitempty"`
+	LoginTheme             string                `json:"login_theme,omitempty"`
+	RegistrationAllowed    bool                  `json:"registration_allowed,omitempty"`
+	VerifyEmail            bool                  `json:"verify_email,omitempty"`
+	UserProfile            *UserProfile          `json:"user_profile,omitempty"`
+	Roles                  []Role                `json:"roles,omitempty"`
+	Groups                 []Group               `json:"groups,omitempty"`
+}
+
+// DefaultSsoSessionLifespan is a browser login lifetime (seconds) if realm SsoSessionLifespan is not set, KeyCloak uses same value
+const DefaultSsoSessionLifespan = 36000
+
+// IsEnabled checks whether realm is enabled (realm without enabled value is enabled)
+func (realm *Realm) IsEnabled() bool {
+	return realm.Enabled == nil || *realm.Enabled
+}
+
+// GetSsoSessionLifespan returns lifetime (seconds) of browser login or default value if it is not set
+func (realm *Realm) GetSsoSessionLifespan() int {
+	if realm.SsoSessionLifespan <= 0 {
+		return DefaultSsoSessionLifespan
+	}
+	return realm.SsoSessionLifespan
 }
@@ -0,0 +1,54 @@
+package data
+
+// Required actions that user must execute before login completes, names are the same as KeyCloak required actions. Email link
+// could execute only VerifyEmailAction and UpdatePasswordAction (see IsActionSupported)
+const (
+	UpdatePasswordAction     = "UPDATE_PASSWORD"
+	VerifyEmailAction        = "VERIFY_EMAIL"
+	ConfigureTotpAction      = "CONFIGURE_TOTP"
+	TermsAndConditionsAction = "TERMS_AND_CONDITIONS"
+)
+
+// IsRequiredActionSupported checks whether action could be assigned to user as a required action
+func IsRequiredActionSupported(action string) bool {
+	return action == UpdatePasswordAction || action == VerifyEmailAction || action == ConfigureTotpAction ||
+		action == TermsAndConditionsAction
+}
+
+// HasRequiredAction checks whether user has pending required action
+func HasRequiredAction(user User, action string) bool {
+	for _, a := range user.GetRequiredActions() {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// AddRequiredAction adds action to user required actions (if user doesn't have it yet), user must be stored by caller
+func AddRequiredAction(user User, action string) error {
+	if HasRequiredAction(user, action) {
+		return nil
+	}
+	return user.SetRequiredActions(append(user.GetRequiredActions(), action))
+}
+
+// RemoveRequiredAction removes satisfied action from user required actions, user must be stored by caller
+/* Parameters:
+ *    - user - user that executed action
+ *    - action - executed action
+ * Returns: true if user had this action, error if user data is not a json object
+ */
+func RemoveRequiredAction(user User, action string) (bool, error) {
+	actions := user.GetRequiredActions()
+	remaining := make([]string, 0, len(actions))
+	for _, a := range actions {
+		if a != action {
+			remaining = append(remaining, a)
+		}
+	}
+	if len(remaining) == len(actions) {
+		return false, nil
+	}
+	return true, user.SetRequiredActions(remaining)
+}
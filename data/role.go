@@ -0,0 +1,120 @@
+package data
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// User info attributes with realm roles and groups that user is member of (group names)
+const (
+	RolesAttribute  = "roles"
+	GroupsAttribute = "groups"
+)
+
+// AdminRole is a role that admin realm user must have to use Admin REST API
+const AdminRole = "admin"
+
+// Role is a realm role definition, users get roles via user info roles or via groups that they are members of
+type Role struct {
+	Id          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+}
+
+// Group is a set of users with common realm roles, Attributes are arbitrary group attributes (KeyCloak uses the same
+// attributes format), groups are not nested
+type Group struct {
+	Id         uuid.UUID           `json:"id"`
+	Name       string              `json:"name"`
+	RealmRoles []string            `json:"realm_roles,omitempty"`
+	Attributes map[string][]string `json:"attributes,omitempty"`
+}
+
+// FindRole returns realm role definition by name or nil if realm doesn't have such role
+func (realm *Realm) FindRole(name string) *Role {
+	for i := range realm.Roles {
+		if realm.Roles[i].Name == name {
+			return &realm.Roles[i]
+		}
+	}
+	return nil
+}
+
+// FindGroup returns realm group by identifier or nil if realm doesn't have such group
+func (realm *Realm) FindGroup(id uuid.UUID) *Group {
+	for i := range realm.Groups {
+		if realm.Groups[i].Id == id {
+			return &realm.Groups[i]
+		}
+	}
+	return nil
+}
+
+// FindGroupByName returns realm group by name or nil if realm doesn't have such group
+func (realm *Realm) FindGroupByName(name string) *Group {
+	for i := range realm.Groups {
+		if realm.Groups[i].Name == name {
+			return &realm.Groups[i]
+		}
+	}
+	return nil
+}
+
+// GetEffectiveRoles returns sorted roles that user has directly (user info roles) and via groups
+func (realm *Realm) GetEffectiveRoles(user User) []string {
+	roles := map[string]bool{}
+	for _, role := range GetUserRoles(user) {
+		roles[role] = true
+	}
+	for _, groupName := range GetUserGroups(user) {
+		if group := realm.FindGroupByName(groupName); group != nil {
+			for _, role := range group.RealmRoles {
+				roles[role] = true
+			}
+		}
+	}
+	result := make([]string, 0, len(roles))
+	for role := range roles {
+		result = append(result, role)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// GetUserRoles returns realm roles that are directly assigned to user (user info roles)
+func GetUserRoles(user User) []string {
+	return getInfoStrings(user, RolesAttribute)
+}
+
+// SetUserRoles replaces realm roles that are directly assigned to user, empty roles remove user info roles
+func SetUserRoles(user User, roles []string) error {
+	return setInfoStrings(user, RolesAttribute, roles)
+}
+
+// GetUserGroups returns names of groups that user is member of
+func GetUserGroups(user User) []string {
+	return getInfoStrings(user, GroupsAttribute)
+}
+
+// SetUserGroups replaces groups that user is member of, empty groups remove user info groups
+func SetUserGroups(user User, groups []string) error {
+	return setInfoStrings(user, GroupsAttribute, groups)
+}
+
+func getInfoStrings(user User, name string) []string {
+	info, _ := user.GetUserInfo().(map[string]interface{})
+	values, _ := getStringItems(info[name])
+	return values
+}
+
+func setInfoStrings(user User, name string, values []string) error {
+	if len(values) == 0 {
+		return user.SetInfoValue(name, nil)
+	}
+	items := make([]interface{}, len(values))
+	for i, v := range values {
+		items[i] = v
+	}
+	return user.SetInfoValue(name, items)
+}
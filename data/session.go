@@ -11,6 +11,9 @@ import (
  * Expired - time when session expires
  * RefreshExpired - time when refresh expires
  * JwtAccessToken and JwtRefreshToken - access and refresh tokens
+ * Confirmation - binding of access token to client certificate (nil if token is not bound)
+ * AuthTime - time when user entered credentials on browser login form (session of identity cookie has AuthTime of that browser login)
+ * BrowserLogins - logins via browser login form, every browser has own identity cookie (empty if user didn't log in via browser)
  */
 type UserSession struct {
 	Id              uuid.UUID
@@ -20,4 +23,15 @@ type UserSession struct {
 	RefreshExpired  time.Time
 	JwtAccessToken  string
 	JwtRefreshToken string
+	Confirmation    *TokenConfirmation
+	AuthTime        time.Time
+	BrowserLogins   []BrowserLogin
+}
+
+// BrowserLogin is a login of user via browser login form: Identity is a value of identity cookie that login set, AuthTime is a
+// time when user entered credentials, Expired is a time when browser login expires
+type BrowserLogin struct {
+	Identity string
+	AuthTime time.Time
+	Expired  time.Time
 }
@@ -1,6 +1,9 @@
 package data
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +25,39 @@ type JwtCommonInfo struct {
 	SessionState uuid.UUID `json:"session_state"`
 	SessionId    uuid.UUID `json:"sid"`
 	Scope        string    `json:"scope"`
+	// Confirmation is set only for access tokens that are bound to client certificate
+	Confirmation *TokenConfirmation `json:"cnf,omitempty"`
+}
+
+// TokenConfirmation is a "cnf" token claim that binds token to a client certificate (RFC 8705 section 3.1) or
+// to a DPoP proof key (RFC 9449 section 6.1), JwkThumbprint is a JWK SHA-256 thumbprint (RFC 7638)
+type TokenConfirmation struct {
+	X509Thumbprint string `json:"x5t#S256,omitempty"`
+	JwkThumbprint  string `json:"jkt,omitempty"`
+}
+
+// CreateCertificateConfirmation creates confirmation with base64url-encoded SHA-256 thumbprint of DER-encoded certificate
+func CreateCertificateConfirmation(certificate *x509.Certificate) *TokenConfirmation {
+	thumbprint := sha256.Sum256(certificate.Raw)
+	return &TokenConfirmation{X509Thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint[:])}
+}
+
+// IsCertificateBound checks whether confirmation binds token to a client certificate
+func (confirmation *TokenConfirmation) IsCertificateBound() bool {
+	return confirmation != nil && len(confirmation.X509Thumbprint) > 0
+}
+
+// IsDPoPBound checks whether confirmation binds token to a DPoP proof key
+func (confirmation *TokenConfirmation) IsDPoPBound() bool {
+	return confirmation != nil && len(confirmation.JwkThumbprint) > 0
+}
+
+// MatchesCertificate checks whether certificate is a certificate that token is bound to
+func (confirmation *TokenConfirmation) MatchesCertificate(certificate *x509.Certificate) bool {
+	if certificate == nil {
+		return false
+	}
+	return CreateCertificateConfirmation(certificate).X509Thumbprint == confirmation.X509Thumbprint
 }
 
 // TokenRefreshData is a JWT token with embedded just a common data (JwtCommonInfo)
@@ -1,6 +1,17 @@
 package data
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Credential types that user could have (KeyCloak uses the same names)
+const (
+	PasswordCredentialType = "password"
+	OtpCredentialType      = "otp"
+	WebAuthnCredentialType = "webauthn"
+)
 
 // User is a common user interface with all Required methods to get information about user, in future we probably won't have GetPassword method
 // because Password is not an only method for authentication
@@ -8,6 +19,27 @@ type User interface {
 	GetUsername() string
 	GetPassword() string
 	SetPassword(password string) error
+	RehashPassword(password string) error
+	GetPasswordHistory() []string
+	GetPasswordChanged() time.Time
+	GetOtpCredential() *OtpCredential
+	SetOtpCredential(credential *OtpCredential) error
+	GetWebAuthnCredentials() []WebAuthnCredential
+	SetWebAuthnCredentials(credentials []WebAuthnCredential) error
+	GetActionTokens() []ActionToken
+	SetActionTokens(tokens []ActionToken) error
+	GetRequiredActions() []string
+	SetRequiredActions(actions []string) error
+	GetConsents() []UserConsent
+	SetConsents(consents []UserConsent) error
+	GetEmail() string
+	IsEnabled() bool
+	SetEnabled(enabled bool) error
+	GetNotBefore() time.Time
+	GetValidUntil() time.Time
+	SetValidity(notBefore time.Time, validUntil time.Time) error
+	SetEmailVerified(verified bool) error
+	SetInfoValue(name string, value interface{}) error
 	GetId() uuid.UUID
 	GetUserInfo() interface{}
 	GetRawData() interface{}
@@ -0,0 +1,307 @@
+package data
+
+import (
+	"encoding/json"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// User info attributes that Ferrum itself uses, username is required by every user profile
+const (
+	UsernameAttribute      = "preferred_username"
+	EmailAttribute         = "email"
+	GivenNameAttribute     = "given_name"
+	FamilyNameAttribute    = "family_name"
+	SubAttribute           = "sub"
+	EmailVerifiedAttribute = "email_verified"
+	defaultAttributeLength = 255
+)
+
+// User profile attribute types, attribute without type is a string
+const (
+	StringAttributeType  = "string"
+	NumberAttributeType  = "number"
+	BooleanAttributeType = "boolean"
+	ArrayAttributeType   = "array"
+)
+
+// UserProfile is a realm definition of user info attributes that user fills in on self-service registration
+/* Attributes are user info keys (preferred_username, email, given_name, ...), attributes that profile doesn't have are not
+ * accepted from user, realm without profile uses DefaultUserProfile. Profile that realm defines explicitly is also enforced
+ * by every data manager on user create and update (see ValidateUser)
+ */
+type UserProfile struct {
+	Attributes []UserProfileAttribute `json:"attributes"`
+}
+
+// UserProfileAttribute is a user info attribute definition, zero value of any validator means that it is not applied
+/*    - Type - value type: string (default), number, boolean or array (of strings), validators below are applied to string
+ *      values and to every array item
+ *    - ReadOnly - attribute is set by administrator only, user can't set it on registration or change it via account
+ *    - Required - attribute value must not be empty
+ *    - Email - attribute value must be an email address
+ *    - Pattern - attribute value must match regular expression (Go syntax)
+ *    - MinLength, MaxLength - attribute value length limits (in characters)
+ */
+type UserProfileAttribute struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	ReadOnly  bool   `json:"read_only,omitempty"`
+	Required  bool   `json:"required,omitempty"`
+	Email     bool   `json:"email,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	MinLength int    `json:"min_length,omitempty"`
+	MaxLength int    `json:"max_length,omitempty"`
+}
+
+// DefaultUserProfile is a profile of realm without UserProfile: username and email are required, names are optional
+var DefaultUserProfile = UserProfile{Attributes: []UserProfileAttribute{
+	{Name: UsernameAttribute, Required: true, MaxLength: defaultAttributeLength},
+	{Name: EmailAttribute, Required: true, Email: true, MaxLength: defaultAttributeLength},
+	{Name: GivenNameAttribute, MaxLength: defaultAttributeLength},
+	{Name: FamilyNameAttribute, MaxLength: defaultAttributeLength},
+}}
+
+// GetUserProfile returns realm user profile or DefaultUserProfile if realm doesn't have it
+func (realm *Realm) GetUserProfile() *UserProfile {
+	if realm.UserProfile == nil {
+		return &DefaultUserProfile
+	}
+	return realm.UserProfile
+}
+
+// FindAttribute returns attribute definition by name or nil if profile doesn't have such attribute
+func (profile *UserProfile) FindAttribute(name string) *UserProfileAttribute {
+	for i := range profile.Attributes {
+		if profile.Attributes[i].Name == name {
+			return &profile.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// ValidateUser checks that user data is consistent and matches realm user profile
+/* Every user must have info with valid identifier (info.sub is UUID) and username, if realm defines UserProfile explicitly
+ * info attributes are also checked against it (attributes that profile doesn't define, i.e. roles, are allowed here).
+ * Data managers call this function before user is stored
+ * Parameters:
+ *    - realm - realm that user belongs to
+ *    - user - created or updated user
+ * Returns: nil if user is valid, otherwise errors.UserValidationError with all violations
+ */
+func ValidateUser(realm *Realm, user User) error {
+	rawData, _ := user.GetRawData().(map[string]interface{})
+	info, ok := rawData["info"].(map[string]interface{})
+	if !ok {
+		return errors.NewUserValidationError([]errors.AttributeViolation{createViolation(errors.AttributeRequiredDesc, "info")})
+	}
+	var violations []errors.AttributeViolation
+	sub, _ := info[SubAttribute].(string)
+	if len(sub) == 0 {
+		violations = append(violations, createViolation(errors.AttributeRequiredDesc, SubAttribute))
+	} else if _, err := uuid.Parse(sub); err != nil {
+		violations = append(violations, createViolation(errors.AttributeInvalidFormatDesc, SubAttribute))
+	}
+	profile := realm.UserProfile
+	if profile == nil {
+		profile = &UserProfile{}
+	}
+	violations = append(violations, profile.ValidateInfo(info)...)
+	if len(violations) > 0 {
+		return errors.NewUserValidationError(violations)
+	}
+	return nil
+}
+
+// ValidateInfo checks user info attribute values against profile attributes (in order of profile attributes)
+/* Username is required even if profile doesn't have it, attributes that profile doesn't define are not checked
+ * Parameters:
+ *    - info - user info (attribute name -> value as it is stored in user data)
+ * Returns: violations (nil if info is valid)
+ */
+func (profile *UserProfile) ValidateInfo(info map[string]interface{}) []errors.AttributeViolation {
+	var violations []errors.AttributeViolation
+	if profile.FindAttribute(UsernameAttribute) == nil && isEmptyValue(info[UsernameAttribute]) {
+		violations = append(violations, createViolation(errors.AttributeRequiredDesc, UsernameAttribute))
+	}
+	for i := range profile.Attributes {
+		violations = append(violations, profile.Attributes[i].validate(info[profile.Attributes[i].Name])...)
+	}
+	return violations
+}
+
+// ParseValue converts value that user entered (form or JSON string) to value of attribute type, array items are separated
+// by comma. Value that can't be converted is returned as is and doesn't pass type check
+func (attribute *UserProfileAttribute) ParseValue(value string) interface{} {
+	switch attribute.Type {
+	case NumberAttributeType:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	case BooleanAttributeType:
+		if flag, err := strconv.ParseBool(value); err == nil {
+			return flag
+		}
+	case ArrayAttributeType:
+		items := make([]interface{}, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return value
+}
+
+// FormatAttributeValue converts stored user info value to string as user enters it (array items are separated by comma)
+func FormatAttributeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, FormatAttributeValue(item))
+		}
+		return strings.Join(items, ",")
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return sf.Format("{0}", v)
+	}
+}
+
+func (attribute *UserProfileAttribute) validate(value interface{}) []errors.AttributeViolation {
+	if isEmptyValue(value) {
+		if attribute.Required || attribute.Name == UsernameAttribute {
+			return []errors.AttributeViolation{createViolation(errors.AttributeRequiredDesc, attribute.Name)}
+		}
+		return nil
+	}
+	switch attribute.Type {
+	case NumberAttributeType:
+		if !isNumber(value) {
+			return []errors.AttributeViolation{createViolation(errors.AttributeInvalidTypeDesc, attribute.Name, "a number")}
+		}
+		return nil
+	case BooleanAttributeType:
+		if _, ok := value.(bool); !ok {
+			return []errors.AttributeViolation{createViolation(errors.AttributeInvalidTypeDesc, attribute.Name, "a boolean")}
+		}
+		return nil
+	case ArrayAttributeType:
+		items, ok := getStringItems(value)
+		if !ok {
+			return []errors.AttributeViolation{createViolation(errors.AttributeInvalidTypeDesc, attribute.Name, "an array of strings")}
+		}
+		var violations []errors.AttributeViolation
+		for _, item := range items {
+			// same violation of several items is reported once
+			for _, v := range attribute.validateString(item) {
+				if !containsViolation(violations, v) {
+					violations = append(violations, v)
+				}
+			}
+		}
+		return violations
+	default:
+		str, ok := value.(string)
+		if !ok {
+			return []errors.AttributeViolation{createViolation(errors.AttributeInvalidTypeDesc, attribute.Name, "a string")}
+		}
+		return attribute.validateString(str)
+	}
+}
+
+func (attribute *UserProfileAttribute) validateString(value string) []errors.AttributeViolation {
+	var violations []errors.AttributeViolation
+	length := len([]rune(value))
+	if attribute.MinLength > 0 && length < attribute.MinLength {
+		violations = append(violations, createViolation(errors.AttributeMinLengthDesc, attribute.Name, attribute.MinLength))
+	}
+	if attribute.MaxLength > 0 && length > attribute.MaxLength {
+		violations = append(violations, createViolation(errors.AttributeMaxLengthDesc, attribute.Name, attribute.MaxLength))
+	}
+	if attribute.Email && !isEmailAddress(value) {
+		violations = append(violations, createViolation(errors.AttributeInvalidEmailDesc, attribute.Name))
+	}
+	if len(attribute.Pattern) > 0 {
+		// invalid pattern is a realm configuration error, no value matches it
+		pattern, err := regexp.Compile(attribute.Pattern)
+		if err != nil || !pattern.MatchString(value) {
+			violations = append(violations, createViolation(errors.AttributeInvalidFormatDesc, attribute.Name))
+		}
+	}
+	return violations
+}
+
+func createViolation(template string, attribute string, args ...interface{}) errors.AttributeViolation {
+	return errors.AttributeViolation{Attribute: attribute, Description: sf.Format(template, append([]interface{}{attribute}, args...)...)}
+}
+
+func containsViolation(violations []errors.AttributeViolation, violation errors.AttributeViolation) bool {
+	for _, v := range violations {
+		if v == violation {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	case []string:
+		return len(v) == 0
+	}
+	return false
+}
+
+func isNumber(value interface{}) bool {
+	switch value.(type) {
+	case float64, float32, int, int32, int64, json.Number:
+		return true
+	}
+	return false
+}
+
+func getStringItems(value interface{}) ([]string, bool) {
+	if items, ok := value.([]string); ok {
+		return items, true
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	items := make([]string, 0, len(values))
+	for _, v := range values {
+		item, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// isEmailAddress checks whether value is a plain email address (without display name)
+func isEmailAddress(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
@@ -0,0 +1,88 @@
+package data
+
+import (
+	"encoding/json"
+	e "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+func TestValidateUser(t *testing.T) {
+	profile := UserProfile{Attributes: []UserProfileAttribute{
+		{Name: EmailAttribute, Required: true, Email: true},
+		{Name: "age", Type: NumberAttributeType},
+		{Name: "subscribed", Type: BooleanAttributeType},
+		{Name: "phones", Type: ArrayAttributeType, Pattern: `^\+[0-9]{7,15}$`},
+		{Name: "department", ReadOnly: true, MaxLength: 5},
+	}}
+	testCases := []struct {
+		name               string
+		userJson           string
+		profile            *UserProfile
+		expectedViolations []errors.AttributeViolation
+	}{
+		{name: "valid_without_profile", userJson: `{"info": {"sub": "5d3e2f1c-8a9b-4c7d-9e6f-1a2b3c4d5e6f", "preferred_username": "user"}}`},
+		{name: "valid_with_profile", profile: &profile, userJson: `{"info": {"sub": "5d3e2f1c-8a9b-4c7d-9e6f-1a2b3c4d5e6f",
+			"preferred_username": "user", "email": "user@ferrum.test", "age": 33, "subscribed": true, "phones": ["+79001234567"],
+			"department": "it", "roles": ["admin"]}}`},
+		{name: "no_info", userJson: `{"credentials": {}}`,
+			expectedViolations: []errors.AttributeViolation{{Attribute: "info", Description: sf.Format(errors.AttributeRequiredDesc, "info")}}},
+		{name: "no_identifier_and_username", userJson: `{"info": {"name": "user"}}`,
+			expectedViolations: []errors.AttributeViolation{
+				{Attribute: SubAttribute, Description: sf.Format(errors.AttributeRequiredDesc, SubAttribute)},
+				{Attribute: UsernameAttribute, Description: sf.Format(errors.AttributeRequiredDesc, UsernameAttribute)},
+			}},
+		{name: "invalid_identifier", userJson: `{"info": {"sub": "1", "preferred_username": "user"}}`,
+			expectedViolations: []errors.AttributeViolation{{Attribute: SubAttribute, Description: sf.Format(errors.AttributeInvalidFormatDesc, SubAttribute)}}},
+		{name: "profile_violations", profile: &profile, userJson: `{"info": {"sub": "5d3e2f1c-8a9b-4c7d-9e6f-1a2b3c4d5e6f",
+			"preferred_username": "user", "age": "33", "subscribed": "yes", "phones": ["+79001234567", "123", "456"], "department": "finance"}}`,
+			expectedViolations: []errors.AttributeViolation{
+				{Attribute: EmailAttribute, Description: sf.Format(errors.AttributeRequiredDesc, EmailAttribute)},
+				{Attribute: "age", Description: sf.Format(errors.AttributeInvalidTypeDesc, "age", "a number")},
+				{Attribute: "subscribed", Description: sf.Format(errors.AttributeInvalidTypeDesc, "subscribed", "a boolean")},
+				{Attribute: "phones", Description: sf.Format(errors.AttributeInvalidFormatDesc, "phones")},
+				{Attribute: "department", Description: sf.Format(errors.AttributeMaxLengthDesc, "department", 5)},
+			}},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			var rawUser interface{}
+			require.NoError(t, json.Unmarshal([]byte(tCase.userJson), &rawUser))
+			err := ValidateUser(&Realm{Name: "profilerealm", UserProfile: tCase.profile}, CreateUser(rawUser))
+			if len(tCase.expectedViolations) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr errors.UserValidationError
+			require.True(t, e.As(err, &validationErr))
+			assert.Equal(t, tCase.expectedViolations, validationErr.Violations)
+		})
+	}
+}
+
+func TestParseAndFormatAttributeValue(t *testing.T) {
+	testCases := []struct {
+		name          string
+		attribute     UserProfileAttribute
+		value         string
+		expectedValue interface{}
+	}{
+		{name: "string", attribute: UserProfileAttribute{Name: "nickname"}, value: "nick", expectedValue: "nick"},
+		{name: "number", attribute: UserProfileAttribute{Name: "age", Type: NumberAttributeType}, value: "33.5", expectedValue: 33.5},
+		{name: "boolean", attribute: UserProfileAttribute{Name: "subscribed", Type: BooleanAttributeType}, value: "true", expectedValue: true},
+		{name: "array", attribute: UserProfileAttribute{Name: "phones", Type: ArrayAttributeType}, value: "+7900,+7901",
+			expectedValue: []interface{}{"+7900", "+7901"}},
+		{name: "not_a_number", attribute: UserProfileAttribute{Name: "age", Type: NumberAttributeType}, value: "old", expectedValue: "old"},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			value := tCase.attribute.ParseValue(tCase.value)
+			assert.Equal(t, tCase.expectedValue, value)
+			assert.Equal(t, tCase.value, FormatAttributeValue(value))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package data
+
+// WebAuthn defaults that are used if realm WebAuthnSettings values are not set
+const (
+	DefaultWebAuthnTimeout          = 300
+	DefaultWebAuthnUserVerification = "preferred"
+	RequiredUserVerification        = "required"
+)
+
+// WebAuthnSettings is a realm WebAuthn relying party settings, passkeys are enabled only in realms that have these settings
+/*    - RpId - relying party id, domain of origins (i.e. "example.com" for "https://login.example.com")
+ *    - RpName - relying party name that authenticator shows to user (realm name if not set)
+ *    - Origins - origins (scheme://host[:port]) of pages that register and use passkeys
+ *    - UserVerification - "required", "preferred" or "discouraged", with "required" response without UV flag is rejected
+ *    - Timeout - time (seconds) that user has to finish registration or login after options were issued
+ */
+type WebAuthnSettings struct {
+	RpId             string   `json:"rp_id"`
+	RpName           string   `json:"rp_name,omitempty"`
+	Origins          []string `json:"origins"`
+	UserVerification string   `json:"user_verification,omitempty"`
+	Timeout          int      `json:"timeout,omitempty"`
+}
+
+// WebAuthnCredential is a user passkey (public key credential) that is stored in user credentials.webauthn
+/* Id and PublicKey (COSE_Key) are base64url encoded, SignCount is the last signature counter that authenticator reported
+ * (counter that doesn't grow means that authenticator could be cloned), Created and LastUsed are unix seconds
+ */
+type WebAuthnCredential struct {
+	Id        string `json:"id"`
+	PublicKey string `json:"public_key"`
+	Algorithm int    `json:"algorithm"`
+	SignCount uint32 `json:"sign_count"`
+	Name      string `json:"name,omitempty"`
+	Created   int64  `json:"created"`
+	LastUsed  int64  `json:"last_used,omitempty"`
+}
+
+// GetUserVerification returns UserVerification or default value if it is not set
+func (settings *WebAuthnSettings) GetUserVerification() string {
+	if len(settings.UserVerification) == 0 {
+		return DefaultWebAuthnUserVerification
+	}
+	return settings.UserVerification
+}
+
+// GetTimeout returns Timeout (seconds) or default value if it is not set
+func (settings *WebAuthnSettings) GetTimeout() int {
+	if settings.Timeout <= 0 {
+		return DefaultWebAuthnTimeout
+	}
+	return settings.Timeout
+}
+
+// IsOriginAllowed checks whether origin is one of relying party origins
+func (settings *WebAuthnSettings) IsOriginAllowed(origin string) bool {
+	for _, o := range settings.Origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
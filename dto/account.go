@@ -0,0 +1,35 @@
+package dto
+
+// PasswordChangeRequest is a password change of user that owns access token, field names are the same as KeyCloak account
+// API uses. Confirmation is optional, if it is set it must be equal to NewPassword
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	Confirmation    string `json:"confirmation"`
+}
+
+// AccountCredential is a user credential (password, otp or passkey) without secret data, Created is a unix time of credential
+// set up (if it is known), RecoveryCodes is a number of unused OTP recovery codes
+type AccountCredential struct {
+	Type          string `json:"type"`
+	Id            string `json:"id,omitempty"`
+	Label         string `json:"label,omitempty"`
+	Created       int64  `json:"created,omitempty"`
+	RecoveryCodes int    `json:"recovery_codes,omitempty"`
+}
+
+// OtpEnrollment is a result of OTP set up, OtpUri and RecoveryCodes are returned only once
+type OtpEnrollment struct {
+	OtpUri        string   `json:"otp_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// AccountSession is a user session, Started and Expires are unix times, Browser is true if session was started by browser
+// login (identity cookie), Current is true for session of access token that requested sessions list
+type AccountSession struct {
+	Id      string `json:"id"`
+	Started int64  `json:"started"`
+	Expires int64  `json:"expires"`
+	Browser bool   `json:"browser"`
+	Current bool   `json:"current"`
+}
@@ -0,0 +1,149 @@
+package dto
+
+// Admin REST API representations have the same field names as KeyCloak representations, fields with pointer types are
+// optional: on update only fields that request contains are changed
+
+// RealmRepresentation is a KeyCloak realm, AccessTokenLifespan is a data.Realm TokenExpiration, SsoSessionIdleTimeout is a
+// refresh token lifetime and SsoSessionMaxLifespan is a browser login lifetime (all in seconds)
+type RealmRepresentation struct {
+	Id                    string  `json:"id,omitempty"`
+	Realm                 string  `json:"realm"`
+	Enabled               *bool   `json:"enabled,omitempty"`
+	AccessTokenLifespan   *int    `json:"accessTokenLifespan,omitempty"`
+	SsoSessionIdleTimeout *int    `json:"ssoSessionIdleTimeout,omitempty"`
+	SsoSessionMaxLifespan *int    `json:"ssoSessionMaxLifespan,omitempty"`
+	RegistrationAllowed   *bool   `json:"registrationAllowed,omitempty"`
+	VerifyEmail           *bool   `json:"verifyEmail,omitempty"`
+	LoginTheme            *string `json:"loginTheme,omitempty"`
+	BruteForceProtected   *bool   `json:"bruteForceProtected,omitempty"`
+}
+
+// ClientRepresentation is a KeyCloak client, Id is a client identifier (UUID) and ClientId is a client name, Attributes
+// are KeyCloak client attributes that Ferrum supports (require.pushed.authorization.requests,
+// tls.client.certificate.bound.access.tokens and dpop.bound.access.tokens)
+type ClientRepresentation struct {
+	Id                      string            `json:"id,omitempty"`
+	ClientId                string            `json:"clientId"`
+	Name                    *string           `json:"name,omitempty"`
+	Enabled                 *bool             `json:"enabled,omitempty"`
+	PublicClient            *bool             `json:"publicClient,omitempty"`
+	ClientAuthenticatorType *string           `json:"clientAuthenticatorType,omitempty"`
+	Secret                  *string           `json:"secret,omitempty"`
+	RedirectUris            []string          `json:"redirectUris,omitempty"`
+	ConsentRequired         *bool             `json:"consentRequired,omitempty"`
+	Attributes              map[string]string `json:"attributes,omitempty"`
+}
+
+// CredentialRepresentation is a KeyCloak credential: password on user create and password reset (Temporary password requires
+// user to change it on next login), client secret and user credential metadata (without secret data, CreatedDate is unix time
+// in milliseconds), SecretData and CredentialData (json strings) are set only in realm export (hashed password and OTP secret)
+type CredentialRepresentation struct {
+	Id             string `json:"id,omitempty"`
+	Type           string `json:"type"`
+	Value          string `json:"value,omitempty"`
+	Temporary      *bool  `json:"temporary,omitempty"`
+	UserLabel      string `json:"userLabel,omitempty"`
+	CreatedDate    int64  `json:"createdDate,omitempty"`
+	SecretData     string `json:"secretData,omitempty"`
+	CredentialData string `json:"credentialData,omitempty"`
+}
+
+// UserRepresentation is a KeyCloak user, Attributes are user info attributes that don't have own representation fields,
+// Credentials, RealmRoles and Groups (group names) are used only on user create
+type UserRepresentation struct {
+	Id              string                     `json:"id,omitempty"`
+	Username        string                     `json:"username,omitempty"`
+	Email           *string                    `json:"email,omitempty"`
+	FirstName       *string                    `json:"firstName,omitempty"`
+	LastName        *string                    `json:"lastName,omitempty"`
+	Enabled         *bool                      `json:"enabled,omitempty"`
+	EmailVerified   *bool                      `json:"emailVerified,omitempty"`
+	Attributes      map[string][]string        `json:"attributes,omitempty"`
+	RequiredActions []string                   `json:"requiredActions,omitempty"`
+	Credentials     []CredentialRepresentation `json:"credentials,omitempty"`
+	RealmRoles      []string                   `json:"realmRoles,omitempty"`
+	Groups          []string                   `json:"groups,omitempty"`
+}
+
+// RoleRepresentation is a KeyCloak realm role, ContainerId is a realm name, roles are never composite
+type RoleRepresentation struct {
+	Id          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Composite   bool   `json:"composite"`
+	ClientRole  bool   `json:"clientRole"`
+	ContainerId string `json:"containerId,omitempty"`
+}
+
+// GroupRepresentation is a KeyCloak group, Path is a "/" + Name because groups are not nested (SubGroups is always empty),
+// ClientRoles and SubGroups of imported KeyCloak groups are skipped
+type GroupRepresentation struct {
+	Id          string                `json:"id,omitempty"`
+	Name        string                `json:"name"`
+	Path        string                `json:"path,omitempty"`
+	RealmRoles  []string              `json:"realmRoles,omitempty"`
+	ClientRoles map[string][]string   `json:"clientRoles,omitempty"`
+	Attributes  map[string][]string   `json:"attributes,omitempty"`
+	SubGroups   []GroupRepresentation `json:"subGroups"`
+}
+
+// MappingsRepresentation is a KeyCloak role mappings of user or group (only realm roles)
+type MappingsRepresentation struct {
+	RealmMappings []RoleRepresentation `json:"realmMappings,omitempty"`
+}
+
+// UsersQuery is a filter of users list: Search matches username, email, first or last name, other fields match own
+// attributes, all fields match substring (case-insensitive) unless Exact is true, First and Max are paging parameters
+type UsersQuery struct {
+	Search    string
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	Exact     bool
+	First     int
+	Max       int
+}
+
+// UserSessionRepresentation is a KeyCloak user session, Start and Expires are unix times in milliseconds, Browser is true if
+// session was started by browser login (identity cookie)
+type UserSessionRepresentation struct {
+	Id       string `json:"id"`
+	Username string `json:"username"`
+	UserId   string `json:"userId"`
+	Start    int64  `json:"start"`
+	Expires  int64  `json:"expires"`
+	Browser  bool   `json:"browser"`
+}
+
+// EventRepresentation is a KeyCloak user event, Time is a unix time in milliseconds
+type EventRepresentation struct {
+	Time      int64             `json:"time"`
+	Type      string            `json:"type"`
+	RealmId   string            `json:"realmId"`
+	ClientId  string            `json:"clientId,omitempty"`
+	UserId    string            `json:"userId,omitempty"`
+	SessionId string            `json:"sessionId,omitempty"`
+	IpAddress string            `json:"ipAddress,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// EventsQuery is a filter of events list: Types, Client and User match exactly, events are sorted from newest, First and Max
+// are paging parameters
+type EventsQuery struct {
+	Types  []string
+	Client string
+	User   string
+	First  int
+	Max    int
+}
+
+// AdminConsoleConfig is a configuration of admin web console: Realm is an admin realm, ClientId is a client of admin realm that
+// console gets tokens for, TokenUrl and AdminUrl are server paths of admin realm token endpoint and Admin REST API
+type AdminConsoleConfig struct {
+	Realm    string `json:"realm"`
+	ClientId string `json:"clientId"`
+	TokenUrl string `json:"tokenUrl"`
+	AdminUrl string `json:"adminUrl"`
+}
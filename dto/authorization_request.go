@@ -0,0 +1,87 @@
+package dto
+
+// AuthorizationRequest is a set of OpenId Connect authorization request parameters, it is using both by authorization and
+// pushed authorization request (RFC 9126) endpoints, could be passed via query string or x-www-form-urlencoded body
+type AuthorizationRequest struct {
+	ClientId            string `json:"client_id" schema:"client_id"`
+	ClientSecret        string `json:"client_secret,omitempty" schema:"client_secret"`
+	ResponseType        string `json:"response_type" schema:"response_type"`
+	RedirectUri         string `json:"redirect_uri" schema:"redirect_uri"`
+	Scope               string `json:"scope,omitempty" schema:"scope"`
+	State               string `json:"state,omitempty" schema:"state"`
+	Nonce               string `json:"nonce,omitempty" schema:"nonce"`
+	ResponseMode        string `json:"response_mode,omitempty" schema:"response_mode"`
+	CodeChallenge       string `json:"code_challenge,omitempty" schema:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method,omitempty" schema:"code_challenge_method"`
+	Prompt              string `json:"prompt,omitempty" schema:"prompt"`
+	MaxAge              string `json:"max_age,omitempty" schema:"max_age"`
+	LoginHint           string `json:"login_hint,omitempty" schema:"login_hint"`
+	UiLocales           string `json:"ui_locales,omitempty" schema:"ui_locales"`
+	RequestUri          string `json:"request_uri,omitempty" schema:"request_uri"`
+}
+
+// LoginFormRequest is a browser login form submission, RequestUri references authorization request that login form was shown for
+type LoginFormRequest struct {
+	ClientId   string `schema:"client_id"`
+	RequestUri string `schema:"request_uri"`
+	Username   string `schema:"username"`
+	Password   string `schema:"password"`
+	Totp       string `schema:"totp"`
+}
+
+// ConsentFormRequest is a consent screen submission, Accept is true if user granted requested scopes to client
+type ConsentFormRequest struct {
+	ClientId   string `schema:"client_id"`
+	RequestUri string `schema:"request_uri"`
+	Accept     bool   `schema:"accept"`
+}
+
+// PushedAuthorizationResult is a PAR endpoint successful response, RequestUri must be passed to authorization endpoint
+// instead of all other parameters (except client_id)
+type PushedAuthorizationResult struct {
+	RequestUri string `json:"request_uri"`
+	ExpiresIn  int    `json:"expires_in"`
+}
+
+// BackChannelAuthenticationRequest is a CIBA authentication request (x-www-form-urlencoded) that client sends to backchannel
+// authentication endpoint, user is identified by login_hint (username)
+type BackChannelAuthenticationRequest struct {
+	ClientId                string `json:"client_id" schema:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty" schema:"client_secret"`
+	Scope                   string `json:"scope" schema:"scope"`
+	LoginHint               string `json:"login_hint" schema:"login_hint"`
+	BindingMessage          string `json:"binding_message,omitempty" schema:"binding_message"`
+	ClientNotificationToken string `json:"client_notification_token,omitempty" schema:"client_notification_token"`
+	RequestedExpiry         int    `json:"requested_expiry,omitempty" schema:"requested_expiry"`
+}
+
+// BackChannelAuthenticationResult is a successful CIBA authentication request response
+type BackChannelAuthenticationResult struct {
+	AuthReqId string `json:"auth_req_id"`
+	ExpiresIn int    `json:"expires_in"`
+	Interval  int    `json:"interval"`
+}
+
+// AuthenticationDeviceNotification is a message that is delivering to user authentication device, device should ask user
+// and pass decision to callback endpoint with Authorization: Bearer {callback_token}
+type AuthenticationDeviceNotification struct {
+	Realm          string `json:"realm"`
+	ClientId       string `json:"client_id"`
+	LoginHint      string `json:"login_hint"`
+	Scope          string `json:"scope"`
+	BindingMessage string `json:"binding_message,omitempty"`
+	CallbackToken  string `json:"callback_token"`
+	CallbackUri    string `json:"callback_uri"`
+	ExpiresIn      int    `json:"expires_in"`
+}
+
+// AuthenticationDeviceCallback is a body that authentication device passes to callback endpoint, status is SUCCEED,
+// UNAUTHORIZED or CANCELLED
+type AuthenticationDeviceCallback struct {
+	Status string `json:"status"`
+}
+
+// ClientPingNotification is a CIBA ping mode notification body that server sends to client notification endpoint
+type ClientPingNotification struct {
+	AuthReqId string `json:"auth_req_id"`
+}
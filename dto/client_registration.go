@@ -0,0 +1,30 @@
+package dto
+
+import "github.com/wissance/Ferrum/data"
+
+// ClientRegistrationMetadata is a client metadata used by dynamic client registration (RFC 7591) and client registration management (RFC 7592)
+/* Client sends metadata (without issued values) on registration and update, server responds with full metadata including client_id,
+ * client_secret (for confidential clients) and registration_access_token that must be used to read, update or delete registration
+ */
+type ClientRegistrationMetadata struct {
+	ClientId                              string              `json:"client_id,omitempty"`
+	ClientSecret                          string              `json:"client_secret,omitempty"`
+	ClientIdIssuedAt                      int64               `json:"client_id_issued_at,omitempty"`
+	ClientSecretExpiresAt                 *int64              `json:"client_secret_expires_at,omitempty"`
+	RegistrationAccessToken               string              `json:"registration_access_token,omitempty"`
+	RegistrationClientUri                 string              `json:"registration_client_uri,omitempty"`
+	ClientName                            string              `json:"client_name,omitempty"`
+	RedirectUris                          []string            `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod               string              `json:"token_endpoint_auth_method,omitempty"`
+	Jwks                                  *data.JsonWebKeySet `json:"jwks,omitempty"`
+	RequirePushedAuthorizationRequests    bool                `json:"require_pushed_authorization_requests,omitempty"`
+	BackChannelTokenDeliveryMode          string              `json:"backchannel_token_delivery_mode,omitempty"`
+	BackChannelClientNotificationEndpoint string              `json:"backchannel_client_notification_endpoint,omitempty"`
+	// mutual TLS client metadata (RFC 8705 section 2.1.2 and 3.4)
+	TlsClientAuthSubjectDn                string `json:"tls_client_auth_subject_dn,omitempty"`
+	TlsClientAuthSanDns                   string `json:"tls_client_auth_san_dns,omitempty"`
+	TlsClientAuthSanUri                   string `json:"tls_client_auth_san_uri,omitempty"`
+	TlsClientCertificateBoundAccessTokens bool   `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+	// DPoP client metadata (RFC 9449 section 5.2)
+	DPoPBoundAccessTokens bool `json:"dpop_bound_access_tokens,omitempty"`
+}
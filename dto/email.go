@@ -0,0 +1,34 @@
+package dto
+
+// MailMessage is a plain text email that is passed to mail sender, Realm is a name of realm that sends email
+type MailMessage struct {
+	Realm   string `json:"realm"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// ResetCredentialsRequest is a forgot password request, Username is a username or email of user
+type ResetCredentialsRequest struct {
+	Username string `json:"username" schema:"username"`
+}
+
+// ActionTokenRequest is a body of email link action execution, Password is required only if link contains UPDATE_PASSWORD action
+type ActionTokenRequest struct {
+	Key      string `json:"key" schema:"key"`
+	Password string `json:"password" schema:"password"`
+}
+
+// ActionTokenResult is a result of email link action execution
+type ActionTokenResult struct {
+	Username        string   `json:"username"`
+	ExecutedActions []string `json:"executed_actions"`
+}
+
+// ExecuteActionsEmailRequest is an administrator request to send execute actions email, Lifespan is a link lifetime in seconds
+// (realm default if it is not set)
+type ExecuteActionsEmailRequest struct {
+	Actions  []string `json:"actions"`
+	Lifespan int      `json:"lifespan,omitempty"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+// Health check statuses (the same as KeyCloak health endpoints return)
+const (
+	HealthStatusUp   = "UP"
+	HealthStatusDown = "DOWN"
+)
+
+// HealthStatus is a result of health check, Checks are results of server parts checks (only readiness check has them)
+type HealthStatus struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthCheck is a result of one server part check
+type HealthCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
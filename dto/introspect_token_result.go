@@ -1,5 +1,7 @@
 package dto
 
+import "github.com/wissance/Ferrum/data"
+
 // StringOrArray represents a value that can either be a string or an array of strings
 type StringOrArray []string
 
@@ -12,4 +14,6 @@ type IntrospectTokenResult struct {
 	AuthTime int           `json:"auth_time,omitempty"`
 	Jti      string        `json:"jti,omitempty"`
 	Type     string        `json:"typ,omitempty"`
+	// Cnf is passed for tokens that are bound to client certificate, resource server must check it (RFC 8705 section 3.2)
+	Cnf *data.TokenConfirmation `json:"cnf,omitempty"`
 }
@@ -12,7 +12,7 @@ type OpenIdConfiguration struct {
 	DeviceAuthorizationEndpoint        string   `json:"device_authorization_endpoint"`
 	RegistrationEndpoint               string   `json:"registration_endpoint"`
 	PushedAuthorizationRequestEndpoint string   `json:"pushed_authorization_request_endpoint"`
-	BackChannelAuthorizationEndpoint   string   `json:"back_channel_authorization_endpoint"`
+	BackChannelAuthenticationEndpoint  string   `json:"backchannel_authentication_endpoint,omitempty"`
 	GrantTypesSupported                []string `json:"grant_types_supported"`
 	ResponseTypesSupported             []string `json:"response_types_supported"`
 	// JwksUri                            string   `json:"jwks_uri"` // TODO (UMV): Uncomment if required
@@ -25,9 +25,10 @@ type OpenIdConfiguration struct {
 	//UserInfoSigningAlgValuesSupported                  []string `json:"userinfo_signing_alg_values_supported"`
 	//RequestObjectSigningAlgValuesSupported             []string `json:"request_object_signing_alg_values_supported"`
 	//RequestEncryptionEncValuesSupported                []string `json:"request_encryption_enc_values_supported"`
-	ResponseModesSupported []string `json:"response_modes_supported"`
-	//TokenEndpointAuthSigningAlgValuesSupported         []string `json:"token_endpoint_auth_signing_alg_values_supported"`
-	//IntrospectionEndpointAuthMethodsSupported          []string `json:"introspection_endpoint_auth_methods_supported"`
+	ResponseModesSupported                     []string `json:"response_modes_supported"`
+	TokenEndpointAuthMethodsSupported          []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgValuesSupported []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	IntrospectionEndpointAuthMethodsSupported  []string `json:"introspection_endpoint_auth_methods_supported"`
 	//IntrospectionEndpointAuthSigningAlgValuesSupported []string `json:"introspection_endpoint_auth_signing_alg_values_supported"`
 	//AuthorizationSigningAlgValuesSupported             []string `json:"authorization_signing_alg_values_supported"`
 	//AuthorizationEncryptionAlgValuesSupported          []string `json:"authorization_encryption_alg_values_supported"`
@@ -38,6 +39,10 @@ type OpenIdConfiguration struct {
 	RequestParameterSupported            bool     `json:"request_parameter_supported"`
 	CodeChallengeMethodsSupported        []string `json:"code_challenge_methods_supported"`
 	TlsClientCertificateBoundAccessToken bool     `json:"tls_client_certificate_bound_access_token"`
+	// DPoPSigningAlgValuesSupported are algorithms that client could use to sign DPoP proof (RFC 9449 section 5.1)
+	DPoPSigningAlgValuesSupported []string `json:"dpop_signing_alg_values_supported"`
+	// BackChannelTokenDeliveryModesSupported is empty if CIBA is not configured
+	BackChannelTokenDeliveryModesSupported []string `json:"backchannel_token_delivery_modes_supported,omitempty"`
 	//RevocationEndpointAuthMethodsSupported             []string `json:"revocation_endpoint_auth_methods_supported"`
 	//RevocationEndpointAuthSigningAlgValuesSupported    []string `json:"revocation_endpoint_auth_signing_alg_values_supported"`
 	//BackChannelLogoutSupported                         bool     // TODO (UMV): Uncomment if required
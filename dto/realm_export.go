@@ -0,0 +1,116 @@
+package dto
+
+import "encoding/json"
+
+// RealmExportRepresentation is a KeyCloak realm export (realm-export.json of KeyCloak export, Admin REST API realm create body)
+/* Ferrum imports realm settings, clients, users (with hashed credentials), realm roles and groups, other KeyCloak features
+ * (IdentityProviders, Components, AuthenticationFlows and ClientScopes) are read only to report them as skipped (see
+ * RealmMigrationReport). PasswordPolicy is a KeyCloak policy string (i.e. "length(8) and digits(1)"), brute-force settings
+ * (FailureFactor etc.), OTP policy and action tokens lifespans are in seconds as in KeyCloak, SmtpServer is a KeyCloak email
+ * settings (all values are strings)
+ */
+type RealmExportRepresentation struct {
+	RealmRepresentation
+	PasswordPolicy                      *string                              `json:"passwordPolicy,omitempty"`
+	FailureFactor                       *int                                 `json:"failureFactor,omitempty"`
+	WaitIncrementSeconds                *int                                 `json:"waitIncrementSeconds,omitempty"`
+	MaxFailureWaitSeconds               *int                                 `json:"maxFailureWaitSeconds,omitempty"`
+	MaxDeltaTimeSeconds                 *int                                 `json:"maxDeltaTimeSeconds,omitempty"`
+	PermanentLockout                    *bool                                `json:"permanentLockout,omitempty"`
+	MaxTemporaryLockouts                *int                                 `json:"maxTemporaryLockouts,omitempty"`
+	OtpPolicyType                       *string                              `json:"otpPolicyType,omitempty"`
+	OtpPolicyAlgorithm                  *string                              `json:"otpPolicyAlgorithm,omitempty"`
+	OtpPolicyDigits                     *int                                 `json:"otpPolicyDigits,omitempty"`
+	OtpPolicyLookAheadWindow            *int                                 `json:"otpPolicyLookAheadWindow,omitempty"`
+	OtpPolicyPeriod                     *int                                 `json:"otpPolicyPeriod,omitempty"`
+	ActionTokenGeneratedByUserLifespan  *int                                 `json:"actionTokenGeneratedByUserLifespan,omitempty"`
+	ActionTokenGeneratedByAdminLifespan *int                                 `json:"actionTokenGeneratedByAdminLifespan,omitempty"`
+	SmtpServer                          map[string]string                    `json:"smtpServer,omitempty"`
+	Roles                               *RolesRepresentation                 `json:"roles,omitempty"`
+	Groups                              []GroupRepresentation                `json:"groups,omitempty"`
+	Clients                             []ClientExportRepresentation         `json:"clients,omitempty"`
+	Users                               []UserExportRepresentation           `json:"users,omitempty"`
+	IdentityProviders                   []IdentityProviderRepresentation     `json:"identityProviders,omitempty"`
+	Components                          map[string][]ComponentRepresentation `json:"components,omitempty"`
+	AuthenticationFlows                 []AuthenticationFlowRepresentation   `json:"authenticationFlows,omitempty"`
+	ClientScopes                        []ClientScopeExportRepresentation    `json:"clientScopes,omitempty"`
+}
+
+// RolesRepresentation is a KeyCloak realm export roles, Client are client roles by clientId (they are skipped on import)
+type RolesRepresentation struct {
+	Realm  []RoleRepresentation            `json:"realm,omitempty"`
+	Client map[string][]RoleRepresentation `json:"client,omitempty"`
+}
+
+// ClientExportRepresentation is a KeyCloak realm export client, fields that are not in ClientRepresentation are KeyCloak
+// features that Ferrum doesn't support (non openid-connect protocol, bearer-only and service account clients, etc.)
+type ClientExportRepresentation struct {
+	ClientRepresentation
+	Protocol                     string            `json:"protocol,omitempty"`
+	BearerOnly                   bool              `json:"bearerOnly,omitempty"`
+	ImplicitFlowEnabled          bool              `json:"implicitFlowEnabled,omitempty"`
+	ServiceAccountsEnabled       bool              `json:"serviceAccountsEnabled,omitempty"`
+	AuthorizationServicesEnabled bool              `json:"authorizationServicesEnabled,omitempty"`
+	ProtocolMappers              []json.RawMessage `json:"protocolMappers,omitempty"`
+}
+
+// UserExportRepresentation is a KeyCloak realm export user, Credentials contain password hash and OTP secret (see
+// CredentialRepresentation SecretData and CredentialData), ClientRoles, FederatedIdentities and service account users
+// (ServiceAccountClientId) are skipped on import
+type UserExportRepresentation struct {
+	UserRepresentation
+	ClientRoles            map[string][]string `json:"clientRoles,omitempty"`
+	FederatedIdentities    []json.RawMessage   `json:"federatedIdentities,omitempty"`
+	ServiceAccountClientId string              `json:"serviceAccountClientId,omitempty"`
+}
+
+// IdentityProviderRepresentation is a KeyCloak identity provider (external login via other OpenID provider, SAML or social network)
+type IdentityProviderRepresentation struct {
+	Alias      string `json:"alias"`
+	ProviderId string `json:"providerId"`
+}
+
+// ComponentRepresentation is a KeyCloak realm component (user federation provider, keys provider, etc.)
+type ComponentRepresentation struct {
+	Name       string `json:"name"`
+	ProviderId string `json:"providerId"`
+}
+
+// AuthenticationFlowRepresentation is a KeyCloak authentication flow, BuiltIn flows exist in every KeyCloak realm
+type AuthenticationFlowRepresentation struct {
+	Alias   string `json:"alias"`
+	BuiltIn bool   `json:"builtIn"`
+}
+
+// ClientScopeExportRepresentation is a KeyCloak client scope
+type ClientScopeExportRepresentation struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// RealmExportQuery selects what realm export contains besides realm settings (KeyCloak partial export exportClients and
+// exportGroupsAndRoles parameters, Users is a Ferrum extension)
+type RealmExportQuery struct {
+	Clients        bool
+	GroupsAndRoles bool
+	Users          bool
+}
+
+// SkippedFeatureRepresentation is a feature that was skipped on realm import (KeyCloak feature that Ferrum doesn't support) or
+// export (Ferrum feature that KeyCloak realm export can't contain), Resource is a type of object that has this feature (realm,
+// client, user, group or role) and Name is an object name
+type SkippedFeatureRepresentation struct {
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	Feature  string `json:"feature"`
+}
+
+// RealmMigrationReport is a result of realm import or export: numbers of imported (exported) objects and skipped features
+type RealmMigrationReport struct {
+	Realm   string                         `json:"realm"`
+	Clients int                            `json:"clients"`
+	Users   int                            `json:"users"`
+	Roles   int                            `json:"roles"`
+	Groups  int                            `json:"groups"`
+	Skipped []SkippedFeatureRepresentation `json:"skipped"`
+}
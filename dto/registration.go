@@ -0,0 +1,25 @@
+package dto
+
+// RegistrationRequest is a self-service registration request, Attributes are user info values (keys are attributes of realm
+// user profile, i.e. preferred_username, email, given_name)
+type RegistrationRequest struct {
+	Attributes map[string]string `json:"attributes"`
+	Password   string            `json:"password"`
+}
+
+// RegistrationFormRequest is a browser registration form submission, user profile attributes are form fields with attribute
+// names, RequestUri references authorization request that registration form was shown for
+type RegistrationFormRequest struct {
+	ClientId        string `schema:"client_id"`
+	RequestUri      string `schema:"request_uri"`
+	Password        string `schema:"password"`
+	PasswordConfirm string `schema:"password_confirm"`
+}
+
+// RegistrationResult is a result of successful registration, RequiredActions contains VERIFY_EMAIL if realm requires email
+// verification (user can't log in until email is verified)
+type RegistrationResult struct {
+	Id              string   `json:"id"`
+	Username        string   `json:"username"`
+	RequiredActions []string `json:"required_actions"`
+}
@@ -0,0 +1,20 @@
+package dto
+
+// RequiredActionRequest is an action that user executes before login completes, user authenticates in the same request with
+// token request parameters (client_id, username, password and totp). NewPassword is required for UPDATE_PASSWORD action, Accept
+// must be true for TERMS_AND_CONDITIONS action
+type RequiredActionRequest struct {
+	Action      string `json:"action" schema:"action"`
+	NewPassword string `json:"new_password" schema:"new_password"`
+	Accept      bool   `json:"accept" schema:"accept"`
+}
+
+// RequiredActionResult is a result of required action execution, RequiredActions are actions that are still pending.
+// OtpUri and RecoveryCodes are returned only once on CONFIGURE_TOTP action
+type RequiredActionResult struct {
+	Username        string   `json:"username"`
+	ExecutedAction  string   `json:"executed_action,omitempty"`
+	RequiredActions []string `json:"required_actions"`
+	OtpUri          string   `json:"otp_uri,omitempty"`
+	RecoveryCodes   []string `json:"recovery_codes,omitempty"`
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "crypto/x509"
+
 type TokenGenerationData struct {
 	ClientId     string `json:"client_id" schema:"client_id"`
 	ClientSecret string `json:"client_secret" schema:"client_secret"`
@@ -7,5 +9,22 @@ type TokenGenerationData struct {
 	Scope        string `json:"scope" schema:"scope"`
 	Username     string `json:"username" schema:"username"`
 	Password     string `json:"password" schema:"password"`
+	Totp         string `json:"totp" schema:"totp"`
 	RefreshToken string `json:"refresh_token" schema:"refresh_token"`
+	AuthReqId    string `json:"auth_req_id" schema:"auth_req_id"`
+	// authorization_code grant parameters, code is issued by authorization endpoint
+	Code         string `json:"code" schema:"code"`
+	RedirectUri  string `json:"redirect_uri" schema:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier" schema:"code_verifier"`
+	// WebAuthnAssertion is a passkey login response (dto.PublicKeyCredential json)
+	WebAuthnAssertion string `json:"webauthn_assertion" schema:"webauthn_assertion"`
+	// client_secret_jwt and private_key_jwt client authentication
+	ClientAssertionType string `json:"client_assertion_type" schema:"client_assertion_type"`
+	ClientAssertion     string `json:"client_assertion" schema:"client_assertion"`
+	// ClientAssertionAudiences are not passed by client, handler sets values that client_assertion aud claim could contain
+	ClientAssertionAudiences []string `json:"-" schema:"-"`
+	// ClientCertificate is a certificate that client presented on TLS handshake (mutual TLS), nil if certificate wasn't presented
+	ClientCertificate *x509.Certificate `json:"-" schema:"-"`
+	// ClientAddress is an ip address of client (it is used for brute-force detection)
+	ClientAddress string `json:"-" schema:"-"`
 }
@@ -0,0 +1,80 @@
+package dto
+
+// PublicKeyCredential is a result of navigator.credentials.create() (registration) or navigator.credentials.get() (login)
+// serialized with PublicKeyCredential toJSON(), all binary values are base64url encoded
+type PublicKeyCredential struct {
+	Id       string                `json:"id"`
+	RawId    string                `json:"rawId"`
+	Type     string                `json:"type"`
+	Response AuthenticatorResponse `json:"response"`
+}
+
+// AuthenticatorResponse contains AttestationObject on registration and AuthenticatorData, Signature and UserHandle on login
+type AuthenticatorResponse struct {
+	ClientDataJson    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject,omitempty"`
+	AuthenticatorData string `json:"authenticatorData,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// WebAuthnRegistration is a passkey registration request body, Name is a user-defined passkey name (i.e. "my laptop")
+type WebAuthnRegistration struct {
+	Name       string              `json:"name,omitempty"`
+	Credential PublicKeyCredential `json:"credential"`
+}
+
+// WebAuthnLoginOptionsRequest is a passkey login options request body, Username is optional (without it only discoverable
+// credentials could be used)
+type WebAuthnLoginOptionsRequest struct {
+	Username string `json:"username,omitempty"`
+}
+
+// PublicKeyCredentialCreationOptions are registration options that should be passed to
+// PublicKeyCredential.parseCreationOptionsFromJSON() and navigator.credentials.create()
+type PublicKeyCredentialCreationOptions struct {
+	Rp                     RelyingPartyEntity              `json:"rp"`
+	User                   UserEntity                      `json:"user"`
+	Challenge              string                          `json:"challenge"`
+	PubKeyCredParams       []PublicKeyCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                             `json:"timeout"`
+	ExcludeCredentials     []PublicKeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection          `json:"authenticatorSelection"`
+	Attestation            string                          `json:"attestation"`
+}
+
+// PublicKeyCredentialRequestOptions are login options that should be passed to PublicKeyCredential.parseRequestOptionsFromJSON()
+// and navigator.credentials.get()
+type PublicKeyCredentialRequestOptions struct {
+	Challenge        string                          `json:"challenge"`
+	Timeout          int                             `json:"timeout"`
+	RpId             string                          `json:"rpId"`
+	AllowCredentials []PublicKeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                          `json:"userVerification"`
+}
+
+type RelyingPartyEntity struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PublicKeyCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type PublicKeyCredentialDescriptor struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
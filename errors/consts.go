@@ -14,6 +14,163 @@ const (
 	InvalidTokenMsg              = "Invalid token"
 	InvalidTokenDesc             = "Token verification failed"
 	TokenIsNotActive             = "Token is not active"
+	InvalidRequestUriMsg         = "Invalid request_uri"
+	InvalidRequestUriDesc        = "request_uri is expired, was already used or belongs to another client"
+	RequestUriNotAllowedDesc     = "request_uri is not allowed in pushed authorization request"
+	ParRequiredDesc              = "Client requires pushed authorization request"
+	InvalidRedirectUriDesc       = "redirect_uri is not registered for client"
+	UnsupportedResponseTypeMsg   = "Unsupported response type"
+	BadBodyForAuthorizationMsg   = "Bad body for authorization request, see documentations"
+	// CIBA error codes are taken from OpenID Connect CIBA specification because clients make decisions by them
+	UnauthorizedClientMsg         = "unauthorized_client"
+	CibaNotAllowedDesc            = "Client is not allowed to use backchannel authentication"
+	CibaNotEnabledDesc            = "Backchannel authentication is not enabled on server"
+	UnknownUserIdMsg              = "unknown_user_id"
+	InvalidScopeMsg               = "invalid_scope"
+	OpenIdScopeRequiredDesc       = "scope must contain openid"
+	NotificationTokenRequiredDesc = "client_notification_token is required in ping mode"
+	AuthorizationPendingMsg       = "authorization_pending"
+	AuthorizationPendingDesc      = "The authorization request is still pending"
+	SlowDownMsg                   = "slow_down"
+	SlowDownDesc                  = "Polling interval must be increased"
+	AccessDeniedMsg               = "access_denied"
+	AccessDeniedDesc              = "The end-user denied the authorization request"
+	ExpiredTokenMsg               = "expired_token"
+	ExpiredAuthReqIdDesc          = "auth_req_id has expired or unknown"
+	DeviceNotificationFailedDesc  = "Unable to deliver authentication request to user device"
+	// Dynamic client registration error codes are taken from RFC 7591
+	InvalidClientMetadataMsg           = "invalid_client_metadata"
+	InvalidRedirectUriMsg              = "invalid_redirect_uri"
+	BadBodyForClientRegistrationDesc   = "Bad body for client registration, expected client metadata json"
+	InvalidInitialAccessTokenDesc      = "Initial access token is invalid, expired or exhausted"
+	InvalidRegistrationAccessTokenDesc = "Registration access token is invalid or client does not exist"
+	UnsupportedAuthMethodDesc          = "token_endpoint_auth_method is not supported"
+	RedirectUriFormatDesc              = "redirect_uri must be an absolute uri without fragment"
+	RedirectUriSchemeDesc              = "redirect_uri must use https (http only for loopback host) or private-use scheme of native application"
+	UnsupportedDeliveryModeDesc        = "backchannel_token_delivery_mode is not supported"
+	NotificationEndpointRequiredDesc   = "backchannel_client_notification_endpoint is required in ping mode"
+	NotificationEndpointFormatDesc     = "backchannel_client_notification_endpoint must be an https uri of public host"
+	ClientIdMismatchDesc               = "client_id does not match registered client"
+	ClientSecretMismatchDesc           = "client_secret does not match registered client secret"
+	JwksRequiredDesc                   = "jwks with valid public keys is required for private_key_jwt authentication"
+	// client authentication errors
+	MultipleClientAuthMethodsDesc  = "Client must not use more than one authentication method"
+	UnsupportedClientAssertionDesc = "client_assertion_type is not supported"
+	InvalidClientAssertionDesc     = "client_assertion is invalid, expired or has unexpected issuer, subject or audience"
+	ClientAssertionReplayedDesc    = "client_assertion was already used"
+	ClientAuthMethodNotAllowedDesc = "Client authentication method is not allowed for client"
+	// mutual TLS errors
+	ClientCertificateRequiredDesc = "Client certificate is required"
+	InvalidClientCertificateDesc  = "Client certificate is not trusted or does not match registered client certificate"
+	CertificateBindingFailedDesc  = "Token is bound to another client certificate"
+	TlsSubjectRequiredDesc        = "tls_client_auth requires one of tls_client_auth_subject_dn, tls_client_auth_san_dns or tls_client_auth_san_uri"
+	JwksRequiredForTlsDesc        = "jwks with valid public keys is required for self_signed_tls_client_auth authentication"
+	// DPoP errors, error code is taken from RFC 9449
+	InvalidDPoPProofMsg     = "invalid_dpop_proof"
+	InvalidDPoPProofDesc    = "DPoP proof is invalid, expired, was already used or does not match request"
+	DPoPProofRequiredDesc   = "DPoP proof is required"
+	DPoPKeyMismatchDesc     = "DPoP proof key does not match token binding"
+	TokenIsNotDPoPBoundDesc = "Token is not bound to DPoP proof key"
+	// password policy errors, descriptions are templates that are formatted with policy values
+	InvalidPasswordMsg          = "invalid_password"
+	PasswordMinLengthDesc       = "Password must be at least {0} characters long"
+	PasswordMaxLengthDesc       = "Password must be at most {0} characters long"
+	PasswordMinDigitsDesc       = "Password must contain at least {0} digits"
+	PasswordMinLowerCaseDesc    = "Password must contain at least {0} lower case characters"
+	PasswordMinUpperCaseDesc    = "Password must contain at least {0} upper case characters"
+	PasswordMinSpecialCharsDesc = "Password must contain at least {0} special characters"
+	PasswordIsUsernameDesc      = "Password must not be equal to username"
+	PasswordIsEmailDesc         = "Password must not be equal to email"
+	PasswordInHistoryDesc       = "Password must not be equal to any of last {0} passwords"
+	PasswordIsDenylistedDesc    = "Password is too common"
+	PasswordDenylistErrorDesc   = "Password denylist is not available"
+	PasswordExpiredDesc         = "Password has expired"
+	// second factor errors
+	OtpRequiredDesc      = "One-time password (totp) is required"
+	InvalidOtpDesc       = "Invalid one-time password"
+	OtpNotConfiguredDesc = "One-time password is required but is not configured for user"
+	// WebAuthn (passkeys) errors
+	WebAuthnNotEnabledDesc       = "WebAuthn is not enabled in realm"
+	InvalidWebAuthnResponseDesc  = "WebAuthn response is invalid, expired or does not match issued options"
+	WebAuthnCredentialExistsDesc = "Passkey is already registered"
+	BadBodyForWebAuthnMsg        = "Bad body for WebAuthn request"
+	// email-based flows errors
+	EmailNotEnabledDesc      = "Email is not configured"
+	EmailSendFailedDesc      = "Unable to send email"
+	UserHasNoEmailDesc       = "User has no email"
+	InvalidActionTokenDesc   = "Link is invalid, expired or was already used"
+	UnsupportedActionDesc    = "Action is not supported"
+	PasswordRequiredDesc     = "New password is required"
+	BadBodyForEmailActionMsg = "Bad body for email action request"
+	// required actions errors, AccountNotSetUpDesc is the same as KeyCloak returns
+	AccountNotSetUpDesc         = "Account is not fully set up"
+	ActionNotRequiredDesc       = "Action is not required for user"
+	TermsNotAcceptedDesc        = "Terms and conditions must be accepted"
+	BadBodyForRequiredActionMsg = "Bad body for required action request"
+	// realm, client and user state errors, descriptions are the same as KeyCloak returns
+	RealmDisabledDesc  = "Realm not enabled"
+	ClientDisabledDesc = "Client disabled"
+	UserDisabledDesc   = "Account disabled"
+	UserNotValidDesc   = "Account is not valid at this time"
+	// browser login and authorization code errors, login_required and interaction_required are taken from OpenID Connect Core
+	LoginRequiredMsg                   = "login_required"
+	LoginRequiredDesc                  = "User is not logged in or login has expired"
+	InteractionRequiredMsg             = "interaction_required"
+	InvalidPromptDesc                  = "prompt none must not be combined with other values"
+	InvalidMaxAgeDesc                  = "max_age must be a non-negative number of seconds"
+	UnsupportedCodeChallengeMethodDesc = "code_challenge_method is not supported"
+	LoginPageExpiredDesc               = "Login page has expired, please start login from application again"
+	InvalidAuthorizationCodeDesc       = "Code is invalid, expired, was already used or was issued to another client"
+	RedirectUriMismatchDesc            = "redirect_uri does not match authorization request"
+	CodeVerifierMismatchDesc           = "code_verifier does not match code_challenge"
+	// consent errors, consent_required is taken from OpenID Connect Core
+	ConsentRequiredMsg  = "consent_required"
+	ConsentRequiredDesc = "User has not granted requested scopes to client"
+	ConsentNotFoundDesc = "User has no consent for client"
+	// self-service registration and user profile errors, descriptions are templates that are formatted with attribute name and limits
+	InvalidUserProfileMsg       = "invalid_user_profile"
+	AttributeRequiredDesc       = "Attribute {0} is required"
+	AttributeMinLengthDesc      = "Attribute {0} must be at least {1} characters long"
+	AttributeMaxLengthDesc      = "Attribute {0} must be at most {1} characters long"
+	AttributeInvalidEmailDesc   = "Attribute {0} must be a valid email address"
+	AttributeInvalidFormatDesc  = "Attribute {0} has invalid format"
+	AttributeNotAllowedDesc     = "Attribute {0} is not allowed"
+	RegistrationNotAllowedDesc  = "Registration is not allowed in realm"
+	UsernameExistsDesc          = "Username already exists"
+	PasswordConfirmMismatchDesc = "Passwords do not match"
+	BadBodyForRegistrationMsg   = "Bad body for registration request, see documentations"
+	AttributeReadOnlyDesc       = "Attribute {0} can't be changed"
+	AttributeInvalidTypeDesc    = "Attribute {0} must be {1}"
+	// account self-service errors
+	InvalidCurrentPasswordDesc = "Current password is invalid"
+	OtpAlreadyConfiguredDesc   = "One-time password is already configured"
+	OtpNotFoundDesc            = "One-time password is not configured"
+	OtpRequiredByPolicyDesc    = "One-time password is required by realm policy and can't be removed"
+	SessionNotFoundDesc        = "Session not found"
+	BadBodyForAccountMsg       = "Bad body for account request, see documentations"
+	// admin REST API errors, descriptions of not found and conflict errors are the same as KeyCloak returns
+	NotFoundMsg                 = "not_found"
+	ConflictMsg                 = "conflict"
+	NotSupportedMsg             = "not_supported"
+	AdminRoleRequiredDesc       = "Administrator role is required"
+	AdminPermissionRequiredDesc = "Admin permission {0} is required"
+	RealmNotFoundDesc           = "Realm not found"
+	RealmExistsDesc             = "Realm with same name exists"
+	AdminRealmDeleteDesc        = "Admin realm can't be removed"
+	AdminRealmRenameDesc        = "Admin realm can't be renamed"
+	ClientNotFoundDesc          = "Could not find client"
+	ClientExistsDesc            = "Client {0} already exists"
+	UserNotFoundDesc            = "User not found"
+	UserExistsDesc              = "User exists with same username"
+	RoleNotFoundDesc            = "Could not find role"
+	RoleExistsDesc              = "Role with name {0} already exists"
+	GroupNotFoundDesc           = "Could not find group by id"
+	GroupExistsDesc             = "Top level group named '{0}' already exists"
+	CredentialNotFoundDesc      = "Credential not found"
+	NameRequiredDesc            = "Name is required"
+	UnsupportedCredentialDesc   = "Credential type is not supported"
+	OperationNotSupportedDesc   = "Operation is not supported by data source"
+	BadBodyForAdminMsg          = "Bad body for admin request, see documentations"
 
 	ServiceIsUnavailable = "Service is not available, please check again later"
 	OtherAppError        = "Other error"
@@ -2,6 +2,8 @@ package errors
 
 import (
 	"errors"
+	"strings"
+
 	sf "github.com/wissance/stringFormatter"
 )
 
@@ -13,6 +15,7 @@ var (
 	ErrNotExists              = errors.New("not exists")
 	ErrOperationNotSupported  = errors.New("manager operation is not supported yet (temporarily or permanent)")
 	ErrDataSourceNotAvailable = DataProviderNotAvailable{}
+	EmptyUserValidationErr    = UserValidationError{}
 )
 
 type ObjectAlreadyExistsError struct {
@@ -33,6 +36,17 @@ type UnknownError struct {
 	internalErr error
 }
 
+// AttributeViolation is a problem of one user attribute, Description is one of user profile errors (AttributeRequiredDesc, ...)
+type AttributeViolation struct {
+	Attribute   string `json:"attribute"`
+	Description string `json:"description"`
+}
+
+// UserValidationError is an error of user data that doesn't match realm user profile, it contains all found violations
+type UserValidationError struct {
+	Violations []AttributeViolation
+}
+
 type DataProviderNotAvailable struct {
 	providerType string
 	source       string
@@ -71,3 +85,20 @@ func NewDataProviderNotAvailable(providerType string, source string) DataProvide
 func (e DataProviderNotAvailable) Error() string {
 	return sf.Format("{0} is not ready/up/available, please try again later", e.providerType)
 }
+
+func NewUserValidationError(violations []AttributeViolation) UserValidationError {
+	return UserValidationError{Violations: violations}
+}
+
+func (e UserValidationError) Error() string {
+	return sf.Format("user data is not valid: {0}", strings.Join(e.GetDescriptions(), "; "))
+}
+
+// GetDescriptions returns descriptions of all violations
+func (e UserValidationError) GetDescriptions() []string {
+	descriptions := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		descriptions = append(descriptions, v.Description)
+	}
+	return descriptions
+}
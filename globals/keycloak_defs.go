@@ -4,7 +4,10 @@ const (
 	RefreshTokenGrantType       = "refresh_token"
 	AuthorizationTokenGrantType = "authorization_token"
 	PasswordGrantType           = "password"
+	CibaGrantType               = "urn:openid:params:grant-type:ciba"
+	WebAuthnGrantType           = "urn:ferrum:params:grant-type:webauthn"
 	RealmPathVar                = "realm"
+	ClientIdPathVar             = "clientId"
 	ProfileScope                = "profile"
 	ProfileEmailScope           = "profile email"
 	EmailScope                  = "email"
@@ -17,4 +20,58 @@ const (
 	EmailClaimType              = "email"
 	PreferredUsernameClaim      = "preferred_username"
 	JwtResponse                 = "jwt"
+	// PushedAuthorizationRequestUriPrefix is a prefix of request_uri value returned by PAR endpoint (RFC 9126)
+	PushedAuthorizationRequestUriPrefix = "urn:ietf:params:oauth:request_uri:"
+	// PushedAuthorizationRequestExpiration is a lifetime (seconds) of pushed authorization request, Keycloak uses same value
+	PushedAuthorizationRequestExpiration = 60
+	// AuthorizationCodeGrantType is a grant that client exchanges code issued by authorization endpoint with
+	AuthorizationCodeGrantType = "authorization_code"
+	// QueryResponseMode and FragmentResponseMode are the ways how authorization endpoint passes code to client redirect_uri
+	QueryResponseMode    = "query"
+	FragmentResponseMode = "fragment"
+	// NonePrompt means that authorization endpoint must not display any page, LoginPrompt forces user to enter credentials again
+	NonePrompt  = "none"
+	LoginPrompt = "login"
+	// ConsentPrompt forces consent screen even if user has already granted requested scopes
+	ConsentPrompt = "consent"
+	// CreatePrompt shows registration form instead of login form (OpenID Connect Prompt Create)
+	CreatePrompt = "create"
+	// AuthorizationCodeExpiration is a lifetime (seconds) of authorization code, Keycloak uses same value
+	AuthorizationCodeExpiration = 60
+	// LoginPageExpiration is a lifetime (seconds) of login form, user must submit credentials before it expires
+	LoginPageExpiration = 1800
+	// ThemePathVar and ResourcePathVar are path variables of theme static resources route
+	ThemePathVar    = "theme"
+	ResourcePathVar = "resource"
+	// SessionIdPathVar is a path variable of account session routes
+	SessionIdPathVar = "sessionId"
+	// Admin REST API path variables: IdPathVar is a client or user identifier, CredentialIdPathVar is a user credential identifier
+	IdPathVar           = "id"
+	GroupIdPathVar      = "groupId"
+	RoleNamePathVar     = "roleName"
+	CredentialIdPathVar = "credentialId"
+	// IdentityCookie is a name of cookie that keeps browser login (SSO) of user in realm
+	IdentityCookie = "FERRUM_IDENTITY"
+	// NoneAuthMethod is a token_endpoint_auth_method of public clients (RFC 7591)
+	NoneAuthMethod = "none"
+	// ClientSecretPostAuthMethod is a token_endpoint_auth_method of clients that pass client_secret in request body (RFC 7591)
+	ClientSecretPostAuthMethod = "client_secret_post"
+	// ClientSecretBasicAuthMethod is a token_endpoint_auth_method of clients that pass client_id and client_secret in Basic Authorization header
+	ClientSecretBasicAuthMethod = "client_secret_basic"
+	// ClientSecretJwtAuthMethod is a token_endpoint_auth_method of clients that pass client_assertion signed with client secret (HMAC)
+	ClientSecretJwtAuthMethod = "client_secret_jwt"
+	// PrivateKeyJwtAuthMethod is a token_endpoint_auth_method of clients that pass client_assertion signed with private key
+	PrivateKeyJwtAuthMethod = "private_key_jwt"
+	// TlsClientAuthMethod is a token_endpoint_auth_method of clients that use certificate issued by trusted CA (RFC 8705)
+	TlsClientAuthMethod = "tls_client_auth"
+	// SelfSignedTlsClientAuthMethod is a token_endpoint_auth_method of clients that use self-signed certificate (RFC 8705)
+	SelfSignedTlsClientAuthMethod = "self_signed_tls_client_auth"
+	// DPoPHeader is a header that client passes DPoP proof with (RFC 9449)
+	DPoPHeader = "DPoP"
+	// DPoPProofType is a typ header of DPoP proof JWT
+	DPoPProofType = "dpop+jwt"
+	// DPoPProofLifetime is a max age (seconds) of DPoP proof, iat claim must be not older and not newer than this value
+	DPoPProofLifetime = 60
+	// JwtBearerClientAssertionType is the only supported client_assertion_type (RFC 7523)
+	JwtBearerClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
 )
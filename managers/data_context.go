@@ -22,23 +22,29 @@ type DataContext interface {
 	IsAvailable() bool
 	// GetRealm returns realm by name (unique) returns realm with clients but no users
 	GetRealm(realmName string) (*data.Realm, error)
+	// GetRealms returns all realms sorted by name (without users, clients are not guaranteed, use GetRealm)
+	GetRealms() ([]data.Realm, error)
 	// GetClient returns realm client by name (client name is also unique in a realm)
 	GetClient(realmName string, name string) (*data.Client, error)
 	// GetUser return realm user (consider what to do with Federated users) by name
 	GetUser(realmName string, userName string) (data.User, error)
+	// GetUsers returns all realm users, realm without users could return errors.ErrZeroLength
+	GetUsers(realmName string) ([]data.User, error)
 	// GetUserById return realm user by id
 	GetUserById(realmName string, userId uuid.UUID) (data.User, error)
 	// CreateRealm creates new data.Realm in a data store, receive realmData unmarshalled json in a data.Realm
 	CreateRealm(realmData data.Realm) error
 	// CreateClient creates new data.Client in a data store, requires to pass realmName (because client name is not unique), clientData is an unmarshalled json of type data.Client
 	CreateClient(realmName string, clientData data.Client) error
-	// CreateUser creates new data.User in a data store within a realm with name = realmName
+	// CreateUser creates new data.User in a data store within a realm with name = realmName, user data must match realm user profile
+	// (see data.ValidateUser), otherwise errors.UserValidationError is returned
 	CreateUser(realmName string, userData data.User) error
 	// UpdateRealm updates existing data.Realm in a data store within name = realmData, and new data = realmData
 	UpdateRealm(realmName string, realmData data.Realm) error
 	// UpdateClient updates existing data.Client in a data store with name = clientName and new data = clientData
 	UpdateClient(realmName string, clientName string, clientData data.Client) error
-	// UpdateUser updates existing data.User in a data store with realm name = realName, username = userName and data=userData
+	// UpdateUser updates existing data.User in a data store with realm name = realName, username = userName and data=userData,
+	// new user data is validated like in CreateUser
 	UpdateUser(realmName string, userName string, userData data.User) error
 	// DeleteRealm removes realm from data storage (Should be a CASCADE remove of all related Users and Clients)
 	DeleteRealm(realmName string) error
@@ -46,6 +52,19 @@ type DataContext interface {
 	DeleteClient(realmName string, clientName string) error
 	// DeleteUser removes data.User from data store by user (userName) and realm (realmName) name respectively
 	DeleteUser(realmName string, userName string) error
+	// GetLoginFailures returns brute-force detection counter by key (data.UserLoginFailuresKey or data.IpLoginFailuresKey), expired counter is not returned
+	GetLoginFailures(realmName string, key string) (*data.LoginFailures, error)
+	// SetLoginFailures creates or replaces brute-force detection counter, counter is stored until failures.Expires
+	SetLoginFailures(realmName string, failures data.LoginFailures) error
+	// UpdateLoginFailures atomically reads brute-force detection counter (new counter if there is no counter), changes it with update
+	// function and stores it, updates from parallel requests and other server instances are not lost (update could be called
+	// several times if counter was changed concurrently), returns stored counter
+	UpdateLoginFailures(realmName string, key string, update func(failures *data.LoginFailures)) (*data.LoginFailures, error)
+	// DeleteLoginFailures removes brute-force detection counter (i.e. admin unlocks user)
+	DeleteLoginFailures(realmName string, key string) error
+	// UseInitialAccessToken atomically checks that realm initial access token with tokenId is active and increments its usages counter,
+	// other realm data is not changed, returns false if token doesn't exist, has expired or is exhausted
+	UseInitialAccessToken(realmName string, tokenId uuid.UUID) (bool, error)
 
 	// SetPassword(realmName string, userName string, password string) error
 }
@@ -65,7 +84,7 @@ func PrepareContextUsingData(dataSourceCfg *config.DataSourceConfig, data *data.
 	var err error
 	switch dataSourceCfg.Type {
 	case config.FILE:
-		dc, err = files.CreateFileDataManagerWithInitData(data)
+		dc, err = files.CreateFileDataManagerWithInitData(data, logger)
 
 	case config.REDIS:
 		return nil, errors.New("not supported initialization with init data")
@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"github.com/wissance/Ferrum/config"
 	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/wissance/Ferrum/errors"
 
@@ -16,28 +19,40 @@ import (
 type objectType string
 
 const (
-	Realm  objectType = "realm"
-	Client            = "client"
-	User              = "user"
+	Realm         objectType = "realm"
+	Client                   = "client"
+	User                     = "user"
+	LoginFailures            = "login failures"
 )
 
 // FileDataManager is the simplest Data Storage without any dependencies, it uses single JSON file (it is users and clients RO auth server)
-// This context type is extremely useful for simple systems
+// This context type is extremely useful for simple systems, modifications (i.e. dynamically registered clients) are kept in memory only
 type FileDataManager struct {
 	dataFile   string
 	serverData data.ServerData
 	logger     *logging.AppLogger
+	mutex      sync.RWMutex
+	// loginFailures are brute-force detection counters (realm name -> counter key -> counter), they are kept in memory only
+	loginFailures map[string]map[string]data.LoginFailures
 }
 
 // CreateFileDataManagerWithInitData initializes instance of FileDataManager and sets loaded data to serverData
 /* This factory function creates initialize with data instance of  FileDataManager, error reserved for usage but always nil here
  * Parameters:
  *    serverData already loaded data.ServerData from Json file in memory
+ *    logger - logger instance
  * Returns: context and error (currently is nil)
  */
-func CreateFileDataManagerWithInitData(serverData *data.ServerData) (*FileDataManager, error) {
+func CreateFileDataManagerWithInitData(serverData *data.ServerData, logger *logging.AppLogger) (*FileDataManager, error) {
 	// todo(UMV): todo provide an error handling
-	mn := &FileDataManager{serverData: *serverData}
+	// realms are copied because in-memory modifications must not change caller data
+	realms := make([]data.Realm, len(serverData.Realms))
+	for i, r := range serverData.Realms {
+		r.Clients = append([]data.Client{}, r.Clients...)
+		r.Users = append([]interface{}{}, r.Users...)
+		realms[i] = r
+	}
+	mn := &FileDataManager{serverData: data.ServerData{Realms: realms}, logger: logger}
 	return mn, nil
 }
 
@@ -73,14 +88,38 @@ func (mn *FileDataManager) GetRealm(realmName string) (*data.Realm, error) {
 	if !mn.IsAvailable() {
 		return nil, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
 	}
-	for _, e := range mn.serverData.Realms {
-		// case-sensitive comparison, myapp and MyApP are different realms
-		if e.Name == realmName {
-			e.Users = nil
-			return &e, nil
-		}
+	mn.mutex.RLock()
+	defer mn.mutex.RUnlock()
+	index := mn.findRealm(realmName)
+	if index < 0 {
+		return nil, errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	realm := mn.serverData.Realms[index]
+	realm.Users = nil
+	// copy of clients and initial access tokens slices, in-memory modifications must not change already returned realm
+	realm.Clients = append([]data.Client{}, realm.Clients...)
+	realm.InitialAccessTokens = append([]data.InitialAccessToken(nil), realm.InitialAccessTokens...)
+	return &realm, nil
+}
+
+// GetRealms returns all realms (sorted by name) with clients but without users
+func (mn *FileDataManager) GetRealms() ([]data.Realm, error) {
+	if !mn.IsAvailable() {
+		return nil, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.RLock()
+	defer mn.mutex.RUnlock()
+	realms := make([]data.Realm, len(mn.serverData.Realms))
+	for i, r := range mn.serverData.Realms {
+		r.Users = nil
+		r.Clients = append([]data.Client{}, r.Clients...)
+		r.InitialAccessTokens = append([]data.InitialAccessToken(nil), r.InitialAccessTokens...)
+		realms[i] = r
 	}
-	return nil, errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	sort.Slice(realms, func(i, j int) bool {
+		return realms[i].Name < realms[j].Name
+	})
+	return realms, nil
 }
 
 // GetUsers function for getting all Realm User
@@ -93,21 +132,22 @@ func (mn *FileDataManager) GetUsers(realmName string) ([]data.User, error) {
 	if !mn.IsAvailable() {
 		return nil, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
 	}
-	for _, e := range mn.serverData.Realms {
-		// case-sensitive comparison, myapp and MyApP are different realms
-		if e.Name == realmName {
-			if len(e.Users) == 0 {
-				return nil, errors.ErrZeroLength
-			}
-			users := make([]data.User, len(e.Users))
-			for i, u := range e.Users {
-				user := data.CreateUser(u)
-				users[i] = user
-			}
-			return users, nil
-		}
+	mn.mutex.RLock()
+	defer mn.mutex.RUnlock()
+	index := mn.findRealm(realmName)
+	if index < 0 {
+		return nil, errors.NewObjectNotFoundError(User, "", sf.Format("get realm: {0} users", realmName))
+	}
+	realmUsers := mn.serverData.Realms[index].Users
+	if len(realmUsers) == 0 {
+		return nil, errors.ErrZeroLength
 	}
-	return nil, errors.NewObjectNotFoundError(User, "", sf.Format("get realm: {0} users", realmName))
+	users := make([]data.User, len(realmUsers))
+	for i, u := range realmUsers {
+		// users are copied because caller could modify user (i.e. set password) and pass it to UpdateUser
+		users[i] = data.CreateUser(copyRawUser(u))
+	}
+	return users, nil
 }
 
 // GetClient function for getting Realm Client by name
@@ -121,18 +161,19 @@ func (mn *FileDataManager) GetClient(realmName string, clientName string) (*data
 	if !mn.IsAvailable() {
 		return nil, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
 	}
-	realm, err := mn.GetRealm(realmName)
-	if err != nil {
-		mn.logger.Warn(sf.Format("GetRealm failed: {0}", err.Error()))
-		return nil, err
+	mn.mutex.RLock()
+	defer mn.mutex.RUnlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		mn.logger.Warn(sf.Format("GetRealm failed: realm \"{0}\" does not exists", realmName))
+		return nil, errors.NewObjectNotFoundError(string(Realm), realmName, "")
 	}
-
-	for _, c := range realm.Clients {
-		if c.Name == clientName {
-			return &c, nil
-		}
+	clientIndex := mn.findClient(realmIndex, clientName)
+	if clientIndex < 0 {
+		return nil, errors.NewObjectNotFoundError(Client, clientName, sf.Format("realm: {0}", realmName))
 	}
-	return nil, errors.NewObjectNotFoundError(Client, clientName, sf.Format("realm: {0}", realmName))
+	client := mn.serverData.Realms[realmIndex].Clients[clientIndex]
+	return &client, nil
 }
 
 // GetUser function for getting Realm User by userName
@@ -180,51 +221,374 @@ func (mn *FileDataManager) GetUserById(realmName string, userId uuid.UUID) (data
 }
 
 // CreateRealm creates new data.Realm in a data store, receive realmData unmarshalled json in a data.Realm
-/*
- *
+/* Realm with its clients and users is stored in memory only, every realm user is validated against realm user profile
+ * (see data.ValidateUser)
  */
 func (mn *FileDataManager) CreateRealm(realmData data.Realm) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	if mn.findRealm(realmData.Name) >= 0 {
+		return errors.NewObjectExistsError(string(Realm), realmData.Name, "")
+	}
+	users := make([]interface{}, len(realmData.Users))
+	for i, u := range realmData.Users {
+		users[i] = copyRawUser(u)
+		if err := data.ValidateUser(&realmData, data.CreateUser(users[i])); err != nil {
+			return err
+		}
+	}
+	realmData.Users = users
+	realmData.Clients = append([]data.Client{}, realmData.Clients...)
+	realmData.InitialAccessTokens = append([]data.InitialAccessToken(nil), realmData.InitialAccessTokens...)
+	mn.serverData.Realms = append(mn.serverData.Realms, realmData)
+	return nil
 }
 
 // CreateClient creates new data.Client in a data store, requires to pass realmName (because client name is not unique), clientData is an unmarshalled json of type data.Client
+/* Client is stored in memory only, data file remains unchanged
+ */
 func (mn *FileDataManager) CreateClient(realmName string, clientData data.Client) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	if mn.findClient(realmIndex, clientData.Name) >= 0 {
+		return errors.NewObjectExistsError(Client, clientData.Name, sf.Format("realm: {0}", realmName))
+	}
+	realm := &mn.serverData.Realms[realmIndex]
+	realm.Clients = append(realm.Clients, clientData)
+	return nil
 }
 
 // CreateUser creates new data.User in a data store within a realm with name = realmName
+/* User is stored in memory only (i.e. user registered himself), data file remains unchanged. User is validated against realm
+ * user profile (see data.ValidateUser)
+ */
 func (mn *FileDataManager) CreateUser(realmName string, userData data.User) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	userName := userData.GetUsername()
+	if mn.findUser(realmIndex, userName) >= 0 {
+		return errors.NewObjectExistsError(User, userName, sf.Format("realm: {0}", realmName))
+	}
+	realm := &mn.serverData.Realms[realmIndex]
+	if err := data.ValidateUser(realm, userData); err != nil {
+		return err
+	}
+	realm.Users = append(realm.Users, copyRawUser(userData.GetRawData()))
+	return nil
 }
 
 // UpdateRealm updates existing data.Realm in a data store within name = realmData, and new data = realmData
+/* Updates only realm settings, realm clients and users remain unchanged (like in other DataContext), changes are stored in memory only
+ */
 func (mn *FileDataManager) UpdateRealm(realmName string, realmData data.Realm) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	if realmData.Name != realmName && mn.findRealm(realmData.Name) >= 0 {
+		return errors.NewObjectExistsError(string(Realm), realmData.Name, "")
+	}
+	realmData.Clients = mn.serverData.Realms[realmIndex].Clients
+	realmData.Users = mn.serverData.Realms[realmIndex].Users
+	// initial access tokens usages are changed in memory, stored realm must not share them with caller
+	realmData.InitialAccessTokens = append([]data.InitialAccessToken(nil), realmData.InitialAccessTokens...)
+	mn.serverData.Realms[realmIndex] = realmData
+	return nil
 }
 
 // UpdateClient updates existing data.Client in a data store with name = clientName and new data = clientData
+/* Changes are stored in memory only
+ */
 func (mn *FileDataManager) UpdateClient(realmName string, clientName string, clientData data.Client) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	clientIndex := mn.findClient(realmIndex, clientName)
+	if clientIndex < 0 {
+		return errors.NewObjectNotFoundError(Client, clientName, sf.Format("realm: {0}", realmName))
+	}
+	if clientData.Name != clientName && mn.findClient(realmIndex, clientData.Name) >= 0 {
+		return errors.NewObjectExistsError(Client, clientData.Name, sf.Format("realm: {0}", realmName))
+	}
+	mn.serverData.Realms[realmIndex].Clients[clientIndex] = clientData
+	return nil
 }
 
 // UpdateUser updates existing data.User in a data store with realm name = realName, username = userName and data=userData
+/* Changes are stored in memory only, user is validated against realm user profile (see data.ValidateUser)
+ */
 func (mn *FileDataManager) UpdateUser(realmName string, userName string, userData data.User) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	userIndex := mn.findUser(realmIndex, userName)
+	if userIndex < 0 {
+		return errors.NewObjectNotFoundError(User, userName, sf.Format("realm: {0}", realmName))
+	}
+	newUserName := userData.GetUsername()
+	if newUserName != userName && mn.findUser(realmIndex, newUserName) >= 0 {
+		return errors.NewObjectExistsError(User, newUserName, sf.Format("realm: {0}", realmName))
+	}
+	if err := data.ValidateUser(&mn.serverData.Realms[realmIndex], userData); err != nil {
+		return err
+	}
+	mn.serverData.Realms[realmIndex].Users[userIndex] = copyRawUser(userData.GetRawData())
+	return nil
 }
 
 // DeleteRealm removes realm from data storage (Should be a CASCADE remove of all related Users and Clients)
+/* Realm is removed from memory only
+ */
 func (mn *FileDataManager) DeleteRealm(realmName string) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	realms := make([]data.Realm, 0, len(mn.serverData.Realms)-1)
+	realms = append(realms, mn.serverData.Realms[:realmIndex]...)
+	mn.serverData.Realms = append(realms, mn.serverData.Realms[realmIndex+1:]...)
+	delete(mn.loginFailures, realmName)
+	return nil
 }
 
 // DeleteClient removes client with name = clientName from realm with name = clientName
+/* Changes are stored in memory only
+ */
 func (mn *FileDataManager) DeleteClient(realmName string, clientName string) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	clientIndex := mn.findClient(realmIndex, clientName)
+	if clientIndex < 0 {
+		return errors.NewObjectNotFoundError(Client, clientName, sf.Format("realm: {0}", realmName))
+	}
+	realm := &mn.serverData.Realms[realmIndex]
+	clients := make([]data.Client, 0, len(realm.Clients)-1)
+	clients = append(clients, realm.Clients[:clientIndex]...)
+	realm.Clients = append(clients, realm.Clients[clientIndex+1:]...)
+	return nil
 }
 
 // DeleteUser removes data.User from data store by user (userName) and realm (realmName) name respectively
+/* User is removed from memory only
+ */
 func (mn *FileDataManager) DeleteUser(realmName string, userName string) error {
-	return errors.ErrOperationNotSupported
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	userIndex := mn.findUser(realmIndex, userName)
+	if userIndex < 0 {
+		return errors.NewObjectNotFoundError(User, userName, sf.Format("realm: {0}", realmName))
+	}
+	realm := &mn.serverData.Realms[realmIndex]
+	users := make([]interface{}, 0, len(realm.Users)-1)
+	users = append(users, realm.Users[:userIndex]...)
+	realm.Users = append(users, realm.Users[userIndex+1:]...)
+	return nil
+}
+
+// GetLoginFailures returns brute-force detection counter by key, expired counter is not returned
+func (mn *FileDataManager) GetLoginFailures(realmName string, key string) (*data.LoginFailures, error) {
+	if !mn.IsAvailable() {
+		return nil, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.RLock()
+	defer mn.mutex.RUnlock()
+	failures, ok := mn.loginFailures[realmName][key]
+	if !ok || failures.IsExpired(time.Now()) {
+		return nil, errors.NewObjectNotFoundError(LoginFailures, key, sf.Format("realm: {0}", realmName))
+	}
+	return &failures, nil
+}
+
+// SetLoginFailures creates or replaces brute-force detection counter
+/* Counters are stored in memory only
+ */
+func (mn *FileDataManager) SetLoginFailures(realmName string, failures data.LoginFailures) error {
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	return mn.setLoginFailures(realmName, failures)
+}
+
+// UpdateLoginFailures changes brute-force detection counter (new counter if there is no counter or it has expired) with update
+// function, counter is read, updated and stored under mutex, therefore concurrent updates are not lost
+func (mn *FileDataManager) UpdateLoginFailures(realmName string, key string, update func(failures *data.LoginFailures)) (*data.LoginFailures, error) {
+	if !mn.IsAvailable() {
+		return nil, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	failures, ok := mn.loginFailures[realmName][key]
+	if !ok || failures.IsExpired(time.Now()) {
+		failures = data.LoginFailures{Key: key}
+	}
+	update(&failures)
+	if err := mn.setLoginFailures(realmName, failures); err != nil {
+		return nil, err
+	}
+	return &failures, nil
+}
+
+// setLoginFailures stores brute-force detection counter, must be called under mutex
+func (mn *FileDataManager) setLoginFailures(realmName string, failures data.LoginFailures) error {
+	if mn.findRealm(realmName) < 0 {
+		return errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	if mn.loginFailures == nil {
+		mn.loginFailures = map[string]map[string]data.LoginFailures{}
+	}
+	realmFailures, ok := mn.loginFailures[realmName]
+	if !ok {
+		realmFailures = map[string]data.LoginFailures{}
+		mn.loginFailures[realmName] = realmFailures
+	}
+	// expired counters are removed here because there is no other place to clean them
+	now := time.Now()
+	for k, v := range realmFailures {
+		if v.IsExpired(now) {
+			delete(realmFailures, k)
+		}
+	}
+	realmFailures[failures.Key] = failures
+	return nil
+}
+
+// DeleteLoginFailures removes brute-force detection counter
+func (mn *FileDataManager) DeleteLoginFailures(realmName string, key string) error {
+	if !mn.IsAvailable() {
+		return errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	if _, ok := mn.loginFailures[realmName][key]; !ok {
+		return errors.NewObjectNotFoundError(LoginFailures, key, sf.Format("realm: {0}", realmName))
+	}
+	delete(mn.loginFailures[realmName], key)
+	return nil
+}
+
+// UseInitialAccessToken checks that realm initial access token is active and increments its usages counter under mutex
+/* Changes are stored in memory only
+ */
+func (mn *FileDataManager) UseInitialAccessToken(realmName string, tokenId uuid.UUID) (bool, error) {
+	if !mn.IsAvailable() {
+		return false, errors.NewDataProviderNotAvailable(string(config.FILE), mn.dataFile)
+	}
+	mn.mutex.Lock()
+	defer mn.mutex.Unlock()
+	realmIndex := mn.findRealm(realmName)
+	if realmIndex < 0 {
+		return false, errors.NewObjectNotFoundError(string(Realm), realmName, "")
+	}
+	tokens := mn.serverData.Realms[realmIndex].InitialAccessTokens
+	for i := range tokens {
+		if tokens[i].ID == tokenId {
+			if !tokens[i].IsActive(time.Now()) {
+				return false, nil
+			}
+			tokens[i].Used++
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// findRealm returns index of realm with name realmName in serverData.Realms or -1 if realm was not found, must be called under mutex
+func (mn *FileDataManager) findRealm(realmName string) int {
+	for i, r := range mn.serverData.Realms {
+		// case-sensitive comparison, myapp and MyApP are different realms
+		if r.Name == realmName {
+			return i
+		}
+	}
+	return -1
+}
+
+// findClient returns index of client with name clientName in realm with index realmIndex or -1 if client was not found, must be called under mutex
+func (mn *FileDataManager) findClient(realmIndex int, clientName string) int {
+	for i, c := range mn.serverData.Realms[realmIndex].Clients {
+		if c.Name == clientName {
+			return i
+		}
+	}
+	return -1
+}
+
+// findUser returns index of user with name userName in realm with index realmIndex or -1 if user was not found, must be called under mutex
+func (mn *FileDataManager) findUser(realmIndex int, userName string) int {
+	for i, u := range mn.serverData.Realms[realmIndex].Users {
+		if data.CreateUser(u).GetUsername() == userName {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyRawUser makes deep copy of user json (nested maps) to separate stored users from users that are returned to callers
+func copyRawUser(rawUser interface{}) interface{} {
+	jsonData, err := json.Marshal(rawUser)
+	if err != nil {
+		return rawUser
+	}
+	var userCopy interface{}
+	if err = json.Unmarshal(jsonData, &userCopy); err != nil {
+		return rawUser
+	}
+	return userCopy
 }
 
 // loadData this function loads data from JSON file (dataFile) to serverData
@@ -7,8 +7,10 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/wissance/Ferrum/config"
 	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
 	"github.com/wissance/Ferrum/logging"
 	"testing"
+	"time"
 )
 
 const testDataFile = "test_data.json"
@@ -102,6 +104,179 @@ func TestGetUserByIdSuccessfully(t *testing.T) {
 	checkUser(t, &expectedUser, &user)
 }
 
+func TestClientOperationsInMemory(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm := "myapp"
+	client := data.Client{ID: uuid.New(), Name: "dynamic-client", Type: data.Public}
+	err := manager.CreateClient(realm, client)
+	assert.NoError(t, err)
+	err = manager.CreateClient(realm, client)
+	assert.ErrorAs(t, err, &errors.ErrExists)
+
+	c, err := manager.GetClient(realm, client.Name)
+	assert.NoError(t, err)
+	checkClient(t, &client, c)
+
+	client.Type = data.Confidential
+	client.Auth = data.Authentication{Type: data.ClientIdAndSecrets, Value: "dynamicClientSecret"}
+	err = manager.UpdateClient(realm, client.Name, client)
+	assert.NoError(t, err)
+	c, err = manager.GetClient(realm, client.Name)
+	assert.NoError(t, err)
+	checkClient(t, &client, c)
+
+	err = manager.DeleteClient(realm, client.Name)
+	assert.NoError(t, err)
+	_, err = manager.GetClient(realm, client.Name)
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+	// realm clients that were loaded from file remain
+	r, err := manager.GetRealm(realm)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, r.Clients)
+}
+
+func TestUpdateRealmInMemory(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm, err := manager.GetRealm("myapp")
+	require.NoError(t, err)
+	clientsNumber := len(realm.Clients)
+	realm.TokenExpiration = 600
+	realm.Clients = nil
+	err = manager.UpdateRealm(realm.Name, *realm)
+	assert.NoError(t, err)
+	updated, err := manager.GetRealm(realm.Name)
+	assert.NoError(t, err)
+	checkRealm(t, realm, updated)
+	assert.Equal(t, clientsNumber, len(updated.Clients))
+}
+
+func TestUseInitialAccessTokenInMemory(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm, err := manager.GetRealm("myapp")
+	require.NoError(t, err)
+	tokenId := uuid.New()
+	realm.InitialAccessTokens = []data.InitialAccessToken{{ID: tokenId, Created: time.Now(), Count: 2}}
+	require.NoError(t, manager.UpdateRealm(realm.Name, *realm))
+	for i := 0; i < 2; i++ {
+		used, useErr := manager.UseInitialAccessToken(realm.Name, tokenId)
+		assert.NoError(t, useErr)
+		assert.True(t, used)
+	}
+	// token is exhausted, realm returned earlier is not changed
+	used, err := manager.UseInitialAccessToken(realm.Name, tokenId)
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.Equal(t, 0, realm.InitialAccessTokens[0].Used)
+	updated, err := manager.GetRealm(realm.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.InitialAccessTokens[0].Used)
+	used, err = manager.UseInitialAccessToken(realm.Name, uuid.New())
+	assert.NoError(t, err)
+	assert.False(t, used)
+}
+
+func TestUpdateUserInMemory(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm := "myapp"
+	user, err := manager.GetUser(realm, "admin")
+	require.NoError(t, err)
+	err = user.SetPassword("new_password")
+	require.NoError(t, err)
+	// user is a copy, therefore stored user remains unchanged until UpdateUser
+	stored, err := manager.GetUser(realm, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, "1s2d3f4g90xs", stored.GetPassword())
+
+	err = manager.UpdateUser(realm, "admin", user)
+	assert.NoError(t, err)
+	updated, err := manager.GetUser(realm, "admin")
+	assert.NoError(t, err)
+	checkUser(t, &user, &updated)
+
+	err = manager.UpdateUser(realm, "unknown_user", user)
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+}
+
+func TestCreateUserInMemory(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm := "myapp"
+	var rawUser interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"info": {"sub": "0f5b3a7c-2d8e-4c1f-9b6a-3e7d2c1b0a94", "preferred_username": "newcomer"}}`), &rawUser))
+	user := data.CreateUser(rawUser)
+	err := manager.CreateUser(realm, user)
+	assert.NoError(t, err)
+	err = manager.CreateUser(realm, user)
+	assert.ErrorAs(t, err, &errors.ErrExists)
+	err = manager.CreateUser("unknown_realm", user)
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+
+	created, err := manager.GetUser(realm, "newcomer")
+	require.NoError(t, err)
+	checkUser(t, &user, &created)
+	created, err = manager.GetUserById(realm, user.GetId())
+	require.NoError(t, err)
+	assert.Equal(t, "newcomer", created.GetUsername())
+}
+
+func TestCreateAndUpdateInvalidUser(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm := "myapp"
+	var rawUser interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"info": {"sub": "not-a-uuid", "preferred_username": "newcomer"}}`), &rawUser))
+	user := data.CreateUser(rawUser)
+	err := manager.CreateUser(realm, user)
+	assert.ErrorAs(t, err, &errors.EmptyUserValidationErr)
+	_, err = manager.GetUser(realm, "newcomer")
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+
+	stored, err := manager.GetUser(realm, "admin")
+	require.NoError(t, err)
+	require.NoError(t, stored.SetInfoValue(data.SubAttribute, nil))
+	err = manager.UpdateUser(realm, "admin", stored)
+	assert.ErrorAs(t, err, &errors.EmptyUserValidationErr)
+	stored, err = manager.GetUser(realm, "admin")
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, stored.GetId())
+}
+
+func TestLoginFailuresInMemory(t *testing.T) {
+	manager := createTestFileDataManager(t)
+	realm := "myapp"
+	key := data.UserLoginFailuresKey("admin")
+	_, err := manager.GetLoginFailures(realm, key)
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+
+	failures := data.LoginFailures{Key: key, Failures: 2, LastFailure: time.Now(), Expires: time.Now().Add(time.Minute)}
+	err = manager.SetLoginFailures(realm, failures)
+	assert.NoError(t, err)
+	stored, err := manager.GetLoginFailures(realm, key)
+	assert.NoError(t, err)
+	assert.Equal(t, failures.Failures, stored.Failures)
+
+	// expired counter is not returned
+	failures.Expires = time.Now().Add(-time.Second)
+	err = manager.SetLoginFailures(realm, failures)
+	assert.NoError(t, err)
+	_, err = manager.GetLoginFailures(realm, key)
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+
+	err = manager.SetLoginFailures("unknown_realm", failures)
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+	err = manager.DeleteLoginFailures(realm, data.IpLoginFailuresKey("127.0.0.1"))
+	assert.ErrorAs(t, err, &errors.EmptyNotFoundErr)
+
+	// expired counter is updated as a new one
+	updated, err := manager.UpdateLoginFailures(realm, key, func(failures *data.LoginFailures) {
+		failures.Failures++
+		failures.Expires = time.Now().Add(time.Minute)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, updated.Failures)
+	stored, err = manager.GetLoginFailures(realm, key)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stored.Failures)
+}
+
 func createTestFileDataManager(t *testing.T) *FileDataManager {
 	loggerCfg := config.LoggingConfig{}
 
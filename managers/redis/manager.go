@@ -22,17 +22,20 @@ const (
 	realmClientsKeyTemplate = "{0}.realm_{1}_clients"
 	clientKeyTemplate       = "{0}.{1}_client_{2}"
 	realmUsersKeyTemplate   = "{0}.realm_{1}_users"
+	// loginFailuresKeyTemplate is a template of brute-force detection counter key, {2} is a data.UserLoginFailuresKey or data.IpLoginFailuresKey
+	loginFailuresKeyTemplate = "{0}.{1}_login_failures_{2}"
 	// realmUsersFullDataKeyTemplate = "{0}.realm_{1}_users_full_data"
 )
 
 type objectType string
 
 const (
-	Realm        objectType = "realm"
-	RealmClients            = "realm clients"
-	RealmUsers              = "realm users"
-	Client                  = "client"
-	User                    = "user"
+	Realm         objectType = "realm"
+	RealmClients             = "realm clients"
+	RealmUsers               = "realm users"
+	Client                   = "client"
+	User                     = "user"
+	LoginFailures            = "login failures"
 )
 
 const defaultNamespace = "fe"
@@ -0,0 +1,146 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	errors2 "github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// loginFailuresUpdateAttempts is a number of UpdateLoginFailures transaction attempts when counter is changed concurrently
+const loginFailuresUpdateAttempts = 10
+
+// GetLoginFailures - getting brute-force detection counter
+/* Counter is stored by key that combines namespace, realm name and counter key (loginFailuresKeyTemplate), Redis removes
+ * counter itself when it expires
+ * Arguments:
+ *    - realmName - name of a realm
+ *    - key - counter key (data.UserLoginFailuresKey or data.IpLoginFailuresKey)
+ * Returns: counter and error (ObjectNotFoundError if there are no failures)
+ */
+func (mn *RedisDataManager) GetLoginFailures(realmName string, key string) (*data.LoginFailures, error) {
+	if !mn.IsAvailable() {
+		return nil, errors2.NewDataProviderNotAvailable(string(config.REDIS), mn.redisOption.Addr)
+	}
+	failuresKey := sf.Format(loginFailuresKeyTemplate, mn.namespace, realmName, key)
+	failures, err := getSingleRedisObject[data.LoginFailures](mn.redisClient, mn.ctx, mn.logger, LoginFailures, failuresKey)
+	if err != nil {
+		return nil, err
+	}
+	if failures.IsExpired(time.Now()) {
+		return nil, errors2.NewObjectNotFoundError(LoginFailures, failuresKey, "")
+	}
+	return failures, nil
+}
+
+// SetLoginFailures - creating or replacing brute-force detection counter
+/* Counter is stored with Redis expiration = failures.Expires (without expiration if Expires is not set)
+ * Arguments:
+ *    - realmName - name of a realm
+ *    - failures - counter
+ * Returns: error
+ */
+func (mn *RedisDataManager) SetLoginFailures(realmName string, failures data.LoginFailures) error {
+	if !mn.IsAvailable() {
+		return errors2.NewDataProviderNotAvailable(string(config.REDIS), mn.redisOption.Addr)
+	}
+	failuresJson, err := json.Marshal(failures)
+	if err != nil {
+		return errors2.NewUnknownError("json.Marshal", "RedisDataManager.SetLoginFailures", err)
+	}
+	var expiration time.Duration
+	if !failures.Expires.IsZero() {
+		expiration = time.Until(failures.Expires)
+		if expiration <= 0 {
+			return nil
+		}
+	}
+	failuresKey := sf.Format(loginFailuresKeyTemplate, mn.namespace, realmName, failures.Key)
+	statusCmd := mn.redisClient.Set(mn.ctx, failuresKey, string(failuresJson), expiration)
+	if statusCmd.Err() != nil {
+		mn.logger.Warn(sf.Format("An error occurred during Set {0}: \"{1}\" from Redis server", LoginFailures, failuresKey))
+		return errors2.NewUnknownError("Set", "RedisDataManager.SetLoginFailures", statusCmd.Err())
+	}
+	return nil
+}
+
+// UpdateLoginFailures - atomic change of brute-force detection counter
+/* Counter is read, changed with update function and stored in Redis optimistic transaction (WATCH/MULTI/EXEC), if other request
+ * (or other server instance) changed counter in between transaction is repeated (up to loginFailuresUpdateAttempts times) with
+ * actual counter value, therefore concurrent failures are not lost
+ * Arguments:
+ *    - realmName - name of a realm
+ *    - key - counter key (data.UserLoginFailuresKey or data.IpLoginFailuresKey)
+ *    - update - function that changes counter (new counter if there is no counter or it has expired)
+ * Returns: stored counter and error
+ */
+func (mn *RedisDataManager) UpdateLoginFailures(realmName string, key string, update func(failures *data.LoginFailures)) (*data.LoginFailures, error) {
+	if !mn.IsAvailable() {
+		return nil, errors2.NewDataProviderNotAvailable(string(config.REDIS), mn.redisOption.Addr)
+	}
+	failuresKey := sf.Format(loginFailuresKeyTemplate, mn.namespace, realmName, key)
+	var failures *data.LoginFailures
+	transaction := func(tx *redis.Tx) error {
+		failures = &data.LoginFailures{Key: key}
+		failuresJson, err := tx.Get(mn.ctx, failuresKey).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil {
+			var stored data.LoginFailures
+			if json.Unmarshal([]byte(failuresJson), &stored) == nil && !stored.IsExpired(time.Now()) {
+				failures = &stored
+			}
+		}
+		update(failures)
+		updatedJson, err := json.Marshal(failures)
+		if err != nil {
+			return err
+		}
+		var expiration time.Duration
+		expired := false
+		if !failures.Expires.IsZero() {
+			expiration = time.Until(failures.Expires)
+			// Redis expiration precision is a millisecond
+			expired = expiration < time.Millisecond
+		}
+		_, err = tx.TxPipelined(mn.ctx, func(pipe redis.Pipeliner) error {
+			if expired {
+				pipe.Del(mn.ctx, failuresKey)
+			} else {
+				pipe.Set(mn.ctx, failuresKey, string(updatedJson), expiration)
+			}
+			return nil
+		})
+		return err
+	}
+	for i := 0; i < loginFailuresUpdateAttempts; i++ {
+		err := mn.redisClient.Watch(mn.ctx, transaction, failuresKey)
+		if err == nil {
+			return failures, nil
+		}
+		if err != redis.TxFailedErr {
+			mn.logger.Warn(sf.Format("An error occurred during update of {0}: \"{1}\" in Redis server", LoginFailures, failuresKey))
+			return nil, errors2.NewUnknownError("Watch", "RedisDataManager.UpdateLoginFailures", err)
+		}
+	}
+	return nil, errors2.NewUnknownError("Watch", "RedisDataManager.UpdateLoginFailures", redis.TxFailedErr)
+}
+
+// DeleteLoginFailures - removing brute-force detection counter
+/* Arguments:
+ *    - realmName - name of a realm
+ *    - key - counter key
+ * Returns: error (ObjectNotFoundError if there is no counter)
+ */
+func (mn *RedisDataManager) DeleteLoginFailures(realmName string, key string) error {
+	if !mn.IsAvailable() {
+		return errors2.NewDataProviderNotAvailable(string(config.REDIS), mn.redisOption.Addr)
+	}
+	failuresKey := sf.Format(loginFailuresKeyTemplate, mn.namespace, realmName, key)
+	return mn.deleteRedisObject(LoginFailures, failuresKey)
+}
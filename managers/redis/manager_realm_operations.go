@@ -3,12 +3,21 @@ package redis
 import (
 	"encoding/json"
 	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/wissance/Ferrum/config"
 	"github.com/wissance/Ferrum/data"
 	appErrs "github.com/wissance/Ferrum/errors"
 	sf "github.com/wissance/stringFormatter"
 )
 
+// realmUpdateAttempts is a number of realm transaction attempts when realm is changed concurrently
+const realmUpdateAttempts = 10
+
 // GetRealm function for getting realm by name, returns the realm with clients but no users.
 /* This function constructs Redis key by pattern combines namespace and realm name (realmKeyTemplate). Unlike from FILE provider.
  * Realm stored in Redis does not have Clients and Users inside Realm itself, these objects must be queried separately.
@@ -43,6 +52,41 @@ func (mn *RedisDataManager) GetRealm(realmName string) (*data.Realm, error) {
 	return realm, nil
 }
 
+// GetRealms function for getting all realms (sorted by name) without clients and users
+/* Realm keys are found by realmKeyTemplate pattern (SCAN of String keys only), keys of realm clients and users relations are
+ * Lists and are not scanned, keys of other objects of realms which names start with "realm_" are skipped because they don't
+ * contain realm with name from key. Key that couldn't be read or isn't a realm is skipped with warning
+ * Returns: Tuple - realms and error
+ */
+func (mn *RedisDataManager) GetRealms() ([]data.Realm, error) {
+	if !mn.IsAvailable() {
+		return []data.Realm{}, appErrs.NewDataProviderNotAvailable(string(config.REDIS), mn.redisOption.Addr)
+	}
+	realmKeyPrefix := sf.Format(realmKeyTemplate, mn.namespace, "")
+	realms := make([]data.Realm, 0)
+	iterator := mn.redisClient.ScanType(mn.ctx, 0, realmKeyPrefix+"*", 0, "string").Iterator()
+	for iterator.Next(mn.ctx) {
+		realmName := strings.TrimPrefix(iterator.Val(), realmKeyPrefix)
+		realm, err := mn.getRealmObject(realmName)
+		if err != nil {
+			if !errors.As(err, &appErrs.EmptyNotFoundErr) {
+				mn.logger.Warn(sf.Format("Key \"{0}\" was skipped in realms list: {1}", iterator.Val(), err.Error()))
+			}
+			continue
+		}
+		if realm.Name == realmName {
+			realms = append(realms, *realm)
+		}
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, appErrs.NewUnknownError("Scan", "RedisDataManager.GetRealms", err)
+	}
+	sort.Slice(realms, func(i, j int) bool {
+		return realms[i].Name < realms[j].Name
+	})
+	return realms, nil
+}
+
 // CreateRealm - creates a realm, if the realm has users and clients, they will also be created.
 /* Create Realm, if it contains User s it creates them too:
  * 1. Check realm by name, realmName MUST be unique
@@ -109,13 +153,10 @@ func (mn *RedisDataManager) CreateRealm(newRealm data.Realm) error {
 		}
 	}
 
-	shortRealm := data.Realm{
-		Name:                   newRealm.Name,
-		Clients:                []data.Client{},
-		Users:                  []any{},
-		TokenExpiration:        newRealm.TokenExpiration,
-		RefreshTokenExpiration: newRealm.RefreshTokenExpiration,
-	}
+	// realm object is stored with all settings but without relations, clients && users are stored separately
+	shortRealm := newRealm
+	shortRealm.Clients = []data.Client{}
+	shortRealm.Users = []any{}
 	jsonShortRealm, err := json.Marshal(shortRealm)
 	if err != nil {
 		mn.logger.Error(sf.Format("An error occurred during Marshal Realm: {0}", err.Error()))
@@ -230,13 +271,9 @@ func (mn *RedisDataManager) UpdateRealm(realmName string, realmNew data.Realm) e
 		for i, u := range users {
 			usersData[i] = u.GetRawData()
 		}
-		newRealmWithOldClientsAndUsers := data.Realm{
-			Name:                   realmNew.Name,
-			Clients:                clients,
-			Users:                  usersData,
-			TokenExpiration:        realmNew.TokenExpiration,
-			RefreshTokenExpiration: realmNew.RefreshTokenExpiration,
-		}
+		newRealmWithOldClientsAndUsers := realmNew
+		newRealmWithOldClientsAndUsers.Clients = clients
+		newRealmWithOldClientsAndUsers.Users = usersData
 		if deleteRealmErr := mn.DeleteRealm(oldRealm.Name); deleteRealmErr != nil {
 			return appErrs.NewUnknownError("DeleteRealm", "RedisDataManager.UpdateRealm", deleteRealmErr)
 		}
@@ -246,13 +283,10 @@ func (mn *RedisDataManager) UpdateRealm(realmName string, realmNew data.Realm) e
 		return nil
 	}
 
-	shortRealm := data.Realm{
-		Name:                   realmNew.Name,
-		Clients:                []data.Client{},
-		Users:                  []any{},
-		TokenExpiration:        realmNew.TokenExpiration,
-		RefreshTokenExpiration: realmNew.RefreshTokenExpiration,
-	}
+	// realm object is stored with all settings but without relations, clients && users are stored separately
+	shortRealm := realmNew
+	shortRealm.Clients = []data.Client{}
+	shortRealm.Users = []any{}
 	jsonShortRealm, err := json.Marshal(shortRealm)
 	if err != nil {
 		mn.logger.Error(sf.Format("An error occurred during Marshal Realm: {0}", err.Error()))
@@ -264,6 +298,73 @@ func (mn *RedisDataManager) UpdateRealm(realmName string, realmNew data.Realm) e
 	return nil
 }
 
+// UseInitialAccessToken - atomic usage of realm initial access token
+/* Realm object is read, token usages counter is incremented and realm object is stored in Redis optimistic transaction
+ * (WATCH/MULTI/EXEC), if realm was changed in between (other registration or realm update) transaction is repeated (up to
+ * realmUpdateAttempts times) with actual realm, therefore token can't be used more than Count times and other realm changes
+ * are not overwritten
+ * Arguments:
+ *    - realmName - name of a realm
+ *    - tokenId - initial access token identifier
+ * Returns: true if token was used, false if token doesn't exist, has expired or is exhausted, and error
+ */
+func (mn *RedisDataManager) UseInitialAccessToken(realmName string, tokenId uuid.UUID) (bool, error) {
+	if !mn.IsAvailable() {
+		return false, appErrs.NewDataProviderNotAvailable(string(config.REDIS), mn.redisOption.Addr)
+	}
+	realmKey := sf.Format(realmKeyTemplate, mn.namespace, realmName)
+	used := false
+	transaction := func(tx *redis.Tx) error {
+		used = false
+		realmJson, err := tx.Get(mn.ctx, realmKey).Result()
+		if err == redis.Nil {
+			return appErrs.NewObjectNotFoundError(string(Realm), realmName, "")
+		}
+		if err != nil {
+			return err
+		}
+		var realm data.Realm
+		if err = json.Unmarshal([]byte(realmJson), &realm); err != nil {
+			return err
+		}
+		tokenIndex := -1
+		for i := range realm.InitialAccessTokens {
+			if realm.InitialAccessTokens[i].ID == tokenId {
+				tokenIndex = i
+				break
+			}
+		}
+		if tokenIndex < 0 || !realm.InitialAccessTokens[tokenIndex].IsActive(time.Now()) {
+			return nil
+		}
+		realm.InitialAccessTokens[tokenIndex].Used++
+		updatedJson, err := json.Marshal(realm)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(mn.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(mn.ctx, realmKey, string(updatedJson), 0)
+			return nil
+		})
+		used = err == nil
+		return err
+	}
+	for i := 0; i < realmUpdateAttempts; i++ {
+		err := mn.redisClient.Watch(mn.ctx, transaction, realmKey)
+		if err == nil {
+			return used, nil
+		}
+		if errors.As(err, &appErrs.EmptyNotFoundErr) {
+			return false, err
+		}
+		if err != redis.TxFailedErr {
+			mn.logger.Warn(sf.Format("An error occurred during update of {0}: \"{1}\" in Redis server", Realm, realmKey))
+			return false, appErrs.NewUnknownError("Watch", "RedisDataManager.UseInitialAccessToken", err)
+		}
+	}
+	return false, appErrs.NewUnknownError("Watch", "RedisDataManager.UseInitialAccessToken", redis.TxFailedErr)
+}
+
 // getRealmObject - getting realm without clients and users
 /*
  * Arguments:
@@ -10,8 +10,12 @@ import (
 	"github.com/wissance/Ferrum/data"
 	appErrs "github.com/wissance/Ferrum/errors"
 	"github.com/wissance/Ferrum/logging"
+	"github.com/wissance/Ferrum/utils/hashing"
 	sf "github.com/wissance/stringFormatter"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const testUser = "ferrum_db"
@@ -547,8 +551,8 @@ func TestCreateUserSuccessfully(t *testing.T) {
 			assert.NoError(t, err)
 			checkRealm(t, &realm, r)
 
-			jsonTemplate := `{"info":{"name":"{0}", "preferred_username": "{1}"}, "credentials":{"password": "123"}}`
-			jsonStr := sf.Format(jsonTemplate, tCase.userName, tCase.userName)
+			jsonTemplate := `{"info":{"sub":"{2}", "name":"{0}", "preferred_username": "{1}"}, "credentials":{"password": "123"}}`
+			jsonStr := sf.Format(jsonTemplate, tCase.userName, tCase.userName, uuid.New().String())
 			var rawUser interface{}
 			err = json.Unmarshal([]byte(jsonStr), &rawUser)
 			assert.NoError(t, err)
@@ -575,8 +579,8 @@ func TestCreateUserFailsDuplicateUser(t *testing.T) {
 	err := manager.CreateRealm(realm)
 	assert.NoError(t, err)
 
-	jsonTemplate := `{"info":{"name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
-	jsonStr := sf.Format(jsonTemplate, "iiivanov", "321_ne_314ras")
+	jsonTemplate := `{"info":{"sub":"{2}", "name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
+	jsonStr := sf.Format(jsonTemplate, "iiivanov", "321_ne_314ras", uuid.New().String())
 	var rawUser interface{}
 	err = json.Unmarshal([]byte(jsonStr), &rawUser)
 	assert.NoError(t, err)
@@ -604,8 +608,8 @@ func TestUpdateUserSuccessfully(t *testing.T) {
 	assert.NoError(t, err)
 	userName := "pppetrov"
 
-	jsonTemplate := `{"info":{"name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
-	jsonStr := sf.Format(jsonTemplate, userName, "67890")
+	jsonTemplate := `{"info":{"sub":"{2}", "name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
+	jsonStr := sf.Format(jsonTemplate, userName, "67890", uuid.New().String())
 	var rawUser interface{}
 	err = json.Unmarshal([]byte(jsonStr), &rawUser)
 	assert.NoError(t, err)
@@ -641,8 +645,8 @@ func TestUpdateUserFailsNonExistingUser(t *testing.T) {
 	assert.NoError(t, err)
 
 	userName := sf.Format("non-existing-user-{0}", uuid.New().String())
-	jsonTemplate := `{"info":{"name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
-	jsonStr := sf.Format(jsonTemplate, userName, "67890")
+	jsonTemplate := `{"info":{"sub":"{2}", "name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
+	jsonStr := sf.Format(jsonTemplate, userName, "67890", uuid.New().String())
 	var rawUser interface{}
 	err = json.Unmarshal([]byte(jsonStr), &rawUser)
 	assert.NoError(t, err)
@@ -667,8 +671,8 @@ func TestDeleteUserSuccessfully(t *testing.T) {
 	assert.NoError(t, err)
 	userName := "sidorov"
 
-	jsonTemplate := `{"info":{"name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
-	jsonStr := sf.Format(jsonTemplate, userName, "98765")
+	jsonTemplate := `{"info":{"sub":"{2}", "name":"{0}", "preferred_username": "{0}"}, "credentials":{"password": "{1}"}}`
+	jsonStr := sf.Format(jsonTemplate, userName, "98765", uuid.New().String())
 	var rawUser interface{}
 	err = json.Unmarshal([]byte(jsonStr), &rawUser)
 	assert.NoError(t, err)
@@ -763,18 +767,126 @@ func TestChangeUserPasswordSuccessfully(t *testing.T) {
 	err = manager.SetPassword(realm.Name, userName, newPassword)
 	assert.NoError(t, err)
 
-	userJson = sf.Format(userTemplate, userName, newPassword)
-	err = json.Unmarshal([]byte(userJson), &rawUser)
-	assert.NoError(t, err)
-	expectedUser := data.CreateUser(rawUser)
+	// password is stored as a hash
 	u, err := manager.GetUser(realm.Name, userName)
 	assert.NoError(t, err)
-	checkUser(t, &expectedUser, &u)
+	assert.Equal(t, userName, u.GetUsername())
+	assert.NotContains(t, u.GetJsonString(), newPassword)
+	matches, _ := hashing.CheckPassword(newPassword, u.GetPassword())
+	assert.True(t, matches)
 
 	err = manager.DeleteRealm(realm.Name)
 	assert.NoError(t, err)
 }
 
+func TestLoginFailuresOperations(t *testing.T) {
+	manager := createTestRedisDataManager(t)
+	realmName := sf.Format("app_4_login_failures_{0}", uuid.New().String())
+	key := data.UserLoginFailuresKey("new_app_user")
+	failures := data.LoginFailures{Key: key, Failures: 1, LastFailure: time.Now(), Expires: time.Now().Add(time.Minute)}
+	err := manager.SetLoginFailures(realmName, failures)
+	assert.NoError(t, err)
+	stored, err := manager.GetLoginFailures(realmName, key)
+	assert.NoError(t, err)
+	assert.Equal(t, failures.Failures, stored.Failures)
+
+	err = manager.DeleteLoginFailures(realmName, key)
+	assert.NoError(t, err)
+	_, err = manager.GetLoginFailures(realmName, key)
+	assert.True(t, errors.As(err, &appErrs.EmptyNotFoundErr))
+	err = manager.DeleteLoginFailures(realmName, key)
+	assert.True(t, errors.As(err, &appErrs.EmptyNotFoundErr))
+
+	// parallel updates (i.e. from different server instances) are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, updateErr := manager.UpdateLoginFailures(realmName, key, func(failures *data.LoginFailures) {
+				failures.Failures++
+				failures.Expires = time.Now().Add(time.Minute)
+			})
+			assert.NoError(t, updateErr)
+		}()
+	}
+	wg.Wait()
+	stored, err = manager.GetLoginFailures(realmName, key)
+	require.NoError(t, err)
+	assert.Equal(t, 20, stored.Failures)
+	assert.NoError(t, manager.DeleteLoginFailures(realmName, key))
+}
+
+func TestGetRealmsWithRelationLikeNames(t *testing.T) {
+	manager := createTestRedisDataManager(t)
+	suffix := uuid.New().String()
+	// realms names end like names of realm clients and users relations keys, realm has clients and users relations
+	realmNames := []string{sf.Format("partner_{0}_users", suffix), sf.Format("partner_{0}_clients", suffix)}
+	for _, name := range realmNames {
+		realm := data.Realm{Name: name, TokenExpiration: 3600,
+			Clients: []data.Client{{Name: "client", ID: uuid.New(), Type: data.Public}},
+			Users:   []any{map[string]any{"info": map[string]any{"preferred_username": "user", "sub": uuid.New().String()}}},
+		}
+		require.NoError(t, manager.CreateRealm(realm))
+	}
+	// key that is not a realm doesn't fail realms listing
+	brokenKey := sf.Format(realmKeyTemplate, manager.namespace, "broken_"+suffix)
+	require.NoError(t, manager.redisClient.Set(manager.ctx, brokenKey, "not a realm", 0).Err())
+
+	realms, err := manager.GetRealms()
+	require.NoError(t, err)
+	found := make([]string, 0)
+	for _, r := range realms {
+		if strings.Contains(r.Name, suffix) {
+			found = append(found, r.Name)
+		}
+	}
+	assert.ElementsMatch(t, realmNames, found)
+	assert.NoError(t, manager.redisClient.Del(manager.ctx, brokenKey).Err())
+	for _, name := range realmNames {
+		assert.NoError(t, manager.DeleteRealm(name))
+	}
+}
+
+func TestUseInitialAccessTokenInParallel(t *testing.T) {
+	manager := createTestRedisDataManager(t)
+	tokenId := uuid.New()
+	realm := data.Realm{
+		Name:                sf.Format("app_4_initial_token_{0}", uuid.New().String()),
+		Clients:             []data.Client{},
+		Users:               []any{},
+		TokenExpiration:     3600,
+		InitialAccessTokens: []data.InitialAccessToken{{ID: tokenId, Created: time.Now(), Count: 5}},
+	}
+	require.NoError(t, manager.CreateRealm(realm))
+	// parallel registrations (i.e. on different server instances) can't use token more than Count times
+	var wg sync.WaitGroup
+	var usedMutex sync.Mutex
+	usages := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			used, useErr := manager.UseInitialAccessToken(realm.Name, tokenId)
+			assert.NoError(t, useErr)
+			if used {
+				usedMutex.Lock()
+				usages++
+				usedMutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, usages)
+	stored, err := manager.GetRealm(realm.Name)
+	require.NoError(t, err)
+	assert.Equal(t, 5, stored.InitialAccessTokens[0].Used)
+	assert.Equal(t, realm.TokenExpiration, stored.TokenExpiration)
+	_, err = manager.UseInitialAccessToken(sf.Format("app_4_missing_{0}", uuid.New().String()), tokenId)
+	assert.True(t, errors.As(err, &appErrs.EmptyNotFoundErr))
+	assert.NoError(t, manager.DeleteRealm(realm.Name))
+}
+
 func createTestRedisDataManager(t *testing.T) *RedisDataManager {
 	rndNamespace := sf.Format("ferrum_test_{0}", uuid.New().String())
 	dataSourceCfg := config.DataSourceConfig{
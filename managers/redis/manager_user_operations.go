@@ -117,7 +117,7 @@ func (mn *RedisDataManager) GetUserById(realmName string, userId uuid.UUID) (dat
 }
 
 // CreateUser - new user creation
-/* Returns an error if the user exists in redis
+/* Returns an error if the user exists in redis or user data doesn't match realm user profile (see data.ValidateUser)
  * Arguments:
  *    - realmName
  *    - userNew
@@ -129,11 +129,14 @@ func (mn *RedisDataManager) CreateUser(realmName string, userNew data.User) erro
 	}
 	// TODO(SIA) Add transaction
 	// TODO(SIA) use function isExists
-	_, err := mn.getRealmObject(realmName)
+	realm, err := mn.getRealmObject(realmName)
 	if err != nil {
 		mn.logger.Warn(sf.Format("CreateUser: GetRealmObject failed, error: {0}", err.Error()))
 		return err
 	}
+	if err = data.ValidateUser(realm, userNew); err != nil {
+		return err
+	}
 	userName := userNew.GetUsername()
 	// TODO(SIA) use function isExists
 	_, err = mn.GetUser(realmName, userName)
@@ -187,7 +190,7 @@ func (mn *RedisDataManager) DeleteUser(realmName string, userName string) error
 }
 
 // UpdateUser - upgrading an existing user
-/*
+/* Returns an error if new user data doesn't match realm user profile (see data.ValidateUser)
  * Arguments:
  *    - realmName
  *    - userName
@@ -206,6 +209,13 @@ func (mn *RedisDataManager) UpdateUser(realmName string, userName string, userNe
 		}
 		return errors2.NewUnknownError("GetUser", "RedisDataManager.UpdateUser", err)
 	}
+	realm, err := mn.getRealmObject(realmName)
+	if err != nil {
+		return errors2.NewUnknownError("getRealmObject", "RedisDataManager.UpdateUser", err)
+	}
+	if err = data.ValidateUser(realm, userNew); err != nil {
+		return err
+	}
 	oldUserName := oldUser.GetUsername()
 	oldUserId := oldUser.GetId()
 
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/utils/hashing"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// UpdateAccountProfile updates user info attributes from account self-service request
+/* Only attributes that request contains are changed, empty value removes attribute. Changed attributes together with current
+ * values of other profile attributes are checked against realm user profile, username and read-only attributes can't be
+ * changed, email change resets email_verified
+ * Parameters:
+ *    - realm - realm that user belongs to
+ *    - user - user that owns access token
+ *    - attributes - changed attributes (attribute name -> value)
+ * Returns: error if attributes are not valid or user wasn't stored
+ */
+func (service *TokenBasedSecurityService) UpdateAccountProfile(realm *data.Realm, user data.User, attributes map[string]string) *data.OperationError {
+	userName := user.GetUsername()
+	profile := realm.GetUserProfile()
+	info, _ := user.GetUserInfo().(map[string]interface{})
+	values := map[string]string{data.UsernameAttribute: userName}
+	for _, attribute := range profile.Attributes {
+		if value, ok := info[attribute.Name]; ok {
+			values[attribute.Name] = data.FormatAttributeValue(value)
+		}
+	}
+	for name, value := range attributes {
+		value = strings.TrimSpace(value)
+		attribute := profile.FindAttribute(name)
+		readOnly := name == data.UsernameAttribute || (attribute != nil && attribute.ReadOnly)
+		if readOnly && value != values[name] {
+			return &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: sf.Format(errors.AttributeReadOnlyDesc, name)}
+		}
+		values[name] = value
+	}
+	if check := ValidateUserAttributes(profile, values); check != nil {
+		return check
+	}
+	changed := ParseUserAttributes(profile, values)
+	emailChanged := false
+	for name := range attributes {
+		value := values[name]
+		if name == data.UsernameAttribute || value == data.FormatAttributeValue(info[name]) {
+			continue
+		}
+		var err error
+		if len(value) == 0 {
+			err = user.SetInfoValue(name, nil)
+		} else {
+			err = user.SetInfoValue(name, changed[name])
+		}
+		if err != nil {
+			service.logger.Error(sf.Format("Account: attribute \"{0}\" of user \"{1}\" was not set: {2}", name, userName, err.Error()))
+			return &data.OperationError{Msg: errors.OtherAppError}
+		}
+		emailChanged = emailChanged || name == data.EmailAttribute
+	}
+	if emailChanged {
+		if err := user.SetEmailVerified(false); err != nil {
+			service.logger.Error(sf.Format("Account: email_verified of user \"{0}\" was not reset: {1}", userName, err.Error()))
+			return &data.OperationError{Msg: errors.OtherAppError}
+		}
+	}
+	if check := service.storeAccount(realm, user); check != nil {
+		return check
+	}
+	service.logger.Info(sf.Format("User \"{0}\" updated own profile", userName))
+	return nil
+}
+
+// ChangePassword changes password of user that knows current password
+/* Wrong current password is counted as failed login by realm brute-force protection, locked user can't change password.
+ * New password is checked against realm password policy, password change satisfies UPDATE_PASSWORD required action
+ * Parameters:
+ *    - realm - realm with password policy
+ *    - user - user that owns access token
+ *    - passwordChange - current and new passwords
+ *    - address - client ip address (could be empty)
+ * Returns: error if current password is invalid, new password doesn't satisfy policy or user wasn't stored
+ */
+func (service *TokenBasedSecurityService) ChangePassword(realm *data.Realm, user data.User, passwordChange *dto.PasswordChangeRequest,
+	address string) *data.OperationError {
+	userName := user.GetUsername()
+	if len(passwordChange.NewPassword) == 0 {
+		return &data.OperationError{Msg: errors.InvalidPasswordMsg, Description: errors.PasswordRequiredDesc}
+	}
+	if len(passwordChange.Confirmation) > 0 && passwordChange.Confirmation != passwordChange.NewPassword {
+		return &data.OperationError{Msg: errors.InvalidPasswordMsg, Description: errors.PasswordConfirmMismatchDesc}
+	}
+	invalidPassword := &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidCurrentPasswordDesc}
+	attempts, allowed := service.startLoginAttempt(realm, userName, address)
+	if !allowed {
+		return invalidPassword
+	}
+	if matches, _ := hashing.CheckPassword(passwordChange.CurrentPassword, user.GetPassword()); !matches {
+		service.logger.Debug(sf.Format("Account: user \"{0}\" provided wrong current password", userName))
+		return invalidPassword
+	}
+	service.cancelLoginAttempt(realm, userName, attempts)
+	service.resetLoginFailures(realm, userName)
+	if check := CheckPasswordPolicy(realm.PasswordPolicy, user, passwordChange.NewPassword); check != nil {
+		return check
+	}
+	if err := user.SetPassword(passwordChange.NewPassword); err != nil {
+		service.logger.Error(sf.Format("Account: password of user \"{0}\" was not set: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if _, err := data.RemoveRequiredAction(user, data.UpdatePasswordAction); err != nil {
+		service.logger.Error(sf.Format("Account: action of user \"{0}\" was not removed: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if check := service.storeAccount(realm, user); check != nil {
+		return check
+	}
+	service.logger.Info(sf.Format("User \"{0}\" changed own password", userName))
+	return nil
+}
+
+// GetAccountCredentials returns user credentials without secret data: password, OTP (if configured) and passkeys
+func GetAccountCredentials(user data.User) []dto.AccountCredential {
+	credentials := make([]dto.AccountCredential, 0)
+	if len(user.GetPassword()) > 0 {
+		credential := dto.AccountCredential{Type: data.PasswordCredentialType}
+		if changed := user.GetPasswordChanged(); !changed.IsZero() {
+			credential.Created = changed.Unix()
+		}
+		credentials = append(credentials, credential)
+	}
+	if otpCredential := user.GetOtpCredential(); otpCredential != nil {
+		credentials = append(credentials, dto.AccountCredential{Type: data.OtpCredentialType, RecoveryCodes: len(otpCredential.RecoveryCodes)})
+	}
+	for _, passkey := range user.GetWebAuthnCredentials() {
+		credentials = append(credentials, dto.AccountCredential{Type: data.WebAuthnCredentialType, Id: passkey.Id, Label: passkey.Name,
+			Created: passkey.Created})
+	}
+	return credentials
+}
+
+// EnrollOtp sets up OTP of user that doesn't have it yet (user must remove current OTP to enroll new one)
+/* Parameters:
+ *    - realm - realm with OTP policy
+ *    - user - user that owns access token
+ * Returns: otpauth:// uri and recovery codes that are shown to user only once or error if OTP is already configured
+ */
+func (service *TokenBasedSecurityService) EnrollOtp(realm *data.Realm, user data.User) (*dto.OtpEnrollment, *data.OperationError) {
+	userName := user.GetUsername()
+	if user.GetOtpCredential() != nil {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.OtpAlreadyConfiguredDesc}
+	}
+	credential, uri, recoveryCodes, err := CreateOtpCredential(realm, userName)
+	if err != nil {
+		service.logger.Error(sf.Format("Account: OTP of user \"{0}\" was not created: {1}", userName, err.Error()))
+		return nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if err = user.SetOtpCredential(credential); err != nil {
+		service.logger.Error(sf.Format("Account: OTP of user \"{0}\" was not set: {1}", userName, err.Error()))
+		return nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if _, err = data.RemoveRequiredAction(user, data.ConfigureTotpAction); err != nil {
+		service.logger.Error(sf.Format("Account: action of user \"{0}\" was not removed: {1}", userName, err.Error()))
+		return nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if check := service.storeAccount(realm, user); check != nil {
+		return nil, check
+	}
+	service.logger.Info(sf.Format("User \"{0}\" enrolled OTP", userName))
+	return &dto.OtpEnrollment{OtpUri: uri, RecoveryCodes: recoveryCodes}, nil
+}
+
+// RemoveOtp removes OTP of user, OTP that realm OTP policy requires for user can't be removed
+func (service *TokenBasedSecurityService) RemoveOtp(realm *data.Realm, user data.User) *data.OperationError {
+	userName := user.GetUsername()
+	if user.GetOtpCredential() == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.OtpNotFoundDesc}
+	}
+	policy := realm.GetOtpPolicy()
+	if policy.Required || hasAnyRole(user, policy.RequiredRoles) {
+		return &data.OperationError{Msg: errors.AccessDeniedMsg, Description: errors.OtpRequiredByPolicyDesc}
+	}
+	if err := user.SetOtpCredential(nil); err != nil {
+		service.logger.Error(sf.Format("Account: OTP of user \"{0}\" was not removed: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if check := service.storeAccount(realm, user); check != nil {
+		return check
+	}
+	service.logger.Info(sf.Format("User \"{0}\" removed OTP", userName))
+	return nil
+}
+
+// GetUserSessions returns active (not expired) sessions of user
+/* Session is active while any of access token, refresh token or browser login (identity cookie) is not expired
+ * Parameters:
+ *    - realm - name of a realm
+ *    - userId - user identifier
+ * Returns: copies of user sessions
+ */
+func (service *TokenBasedSecurityService) GetUserSessions(realm string, userId uuid.UUID) []data.UserSession {
+	service.sessionsMutex.RLock()
+	defer service.sessionsMutex.RUnlock()
+	sessions := make([]data.UserSession, 0)
+	current := time.Now()
+	realmSessions := service.UserSessions[realm]
+	for i := range realmSessions {
+		if realmSessions[i].UserId == userId && GetSessionExpiration(&realmSessions[i]).After(current) {
+			sessions = append(sessions, *copySession(&realmSessions[i]))
+		}
+	}
+	return sessions
+}
+
+// DeleteUserSession removes (signs out) user session, tokens and identity cookies of all session browser logins become invalid
+/* Parameters:
+ *    - realm - name of a realm
+ *    - userId - identifier of user that owns session
+ *    - sessionId - session identifier
+ * Returns: true if session was removed, false if user has no such session
+ */
+func (service *TokenBasedSecurityService) DeleteUserSession(realm string, userId uuid.UUID, sessionId uuid.UUID) bool {
+	service.sessionsMutex.Lock()
+	defer service.sessionsMutex.Unlock()
+	realmSessions := service.UserSessions[realm]
+	for i, s := range realmSessions {
+		if s.Id == sessionId && s.UserId == userId {
+			service.UserSessions[realm] = append(realmSessions[:i:i], realmSessions[i+1:]...)
+			service.logger.Info(sf.Format("Session \"{0}\" of user \"{1}\" was signed out", sessionId.String(), userId.String()))
+			return true
+		}
+	}
+	return false
+}
+
+// GetSessionExpiration returns time when session ends (latest of access token, refresh token and browser login expiration)
+func GetSessionExpiration(session *data.UserSession) time.Time {
+	expiration := session.Expired
+	if session.RefreshExpired.After(expiration) {
+		expiration = session.RefreshExpired
+	}
+	for _, l := range session.BrowserLogins {
+		if l.Expired.After(expiration) {
+			expiration = l.Expired
+		}
+	}
+	return expiration
+}
+
+// storeAccount stores user that was changed via account self-service
+func (service *TokenBasedSecurityService) storeAccount(realm *data.Realm, user data.User) *data.OperationError {
+	userName := user.GetUsername()
+	if err := (*service.DataProvider).UpdateUser(realm.Name, userName, user); err != nil {
+		if check := getUserValidationError(err); check != nil {
+			return check
+		}
+		service.logger.Error(sf.Format("Account: user \"{0}\" was not stored: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	return nil
+}
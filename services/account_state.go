@@ -0,0 +1,33 @@
+package services
+
+import (
+	"time"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// CheckUserState checks whether user could log in, refresh tokens and use tokens
+/* Parameters:
+ *    - realm - realm of user, realm must be enabled
+ *    - user - user that must be enabled, current time must be after user not_before and before user valid_until
+ * Returns: nil if user is active, otherwise error (data.OperationError) with description
+ */
+func (service *TokenBasedSecurityService) CheckUserState(realm *data.Realm, user data.User) *data.OperationError {
+	if !realm.IsEnabled() {
+		return &data.OperationError{Msg: errors.AccessDeniedMsg, Description: errors.RealmDisabledDesc}
+	}
+	if !user.IsEnabled() {
+		service.logger.Debug(sf.Format("User \"{0}\" is disabled", user.GetUsername()))
+		return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.UserDisabledDesc}
+	}
+	now := time.Now()
+	notBefore := user.GetNotBefore()
+	validUntil := user.GetValidUntil()
+	if (!notBefore.IsZero() && now.Before(notBefore)) || (!validUntil.IsZero() && !now.Before(validUntil)) {
+		service.logger.Debug(sf.Format("User \"{0}\" is not valid at this time", user.GetUsername()))
+		return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.UserNotValidDesc}
+	}
+	return nil
+}
@@ -0,0 +1,567 @@
+package services
+
+import (
+	e "errors"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/logging"
+	"github.com/wissance/Ferrum/managers"
+	"github.com/wissance/Ferrum/utils/random"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// Default lifetimes (seconds) of realm that was created via Admin REST API, KeyCloak uses same values
+const (
+	defaultAccessTokenLifespan   = 300
+	defaultSsoSessionIdleTimeout = 1800
+	defaultMaxLoginFailures      = 30
+	adminClientSecretSize        = 24
+)
+
+// KeyCloak client authenticator types (clientAuthenticatorType) and client attributes that Ferrum supports
+const (
+	clientSecretAuthenticator    = "client-secret"
+	clientSecretJwtAuthenticator = "client-secret-jwt"
+	clientJwtAuthenticator       = "client-jwt"
+	clientX509Authenticator      = "client-x509"
+	requireParAttribute          = "require.pushed.authorization.requests"
+	tlsBoundTokensAttribute      = "tls.client.certificate.bound.access.tokens"
+	dpopBoundTokensAttribute     = "dpop.bound.access.tokens"
+	// clientSecretCredentialType is a credential type of client secret representation
+	clientSecretCredentialType = "secret"
+)
+
+// AdminService is an interface of KeyCloak-compatible Admin REST API: CRUD of realms, clients, users, roles and groups over
+// managers.DataContext, all methods accept and return KeyCloak representations (see dto/admin.go)
+type AdminService interface {
+	// GetRealms returns all realms
+	GetRealms() ([]dto.RealmRepresentation, *data.OperationError)
+	// GetRealm returns realm by name
+	GetRealm(realmName string) (*dto.RealmRepresentation, *data.OperationError)
+	// CreateRealm creates realm without clients and users
+	CreateRealm(representation *dto.RealmRepresentation) *data.OperationError
+	// UpdateRealm changes realm settings that representation contains, realm could be renamed (except admin realm)
+	UpdateRealm(realmName string, representation *dto.RealmRepresentation) *data.OperationError
+	// DeleteRealm removes realm with clients and users (admin realm can't be removed)
+	DeleteRealm(realmName string) *data.OperationError
+	// ImportRealm creates realm with clients, users, realm roles and groups of KeyCloak realm export, returns import report
+	ImportRealm(representation *dto.RealmExportRepresentation) (*dto.RealmMigrationReport, *data.OperationError)
+	// ExportRealm returns realm in KeyCloak realm export format and export report
+	ExportRealm(realmName string, query *dto.RealmExportQuery) (*dto.RealmExportRepresentation, *dto.RealmMigrationReport, *data.OperationError)
+
+	// GetClients returns realm clients, non-empty clientId returns only client with such name
+	GetClients(realmName string, clientId string) ([]dto.ClientRepresentation, *data.OperationError)
+	// GetClient returns client by identifier
+	GetClient(realmName string, id uuid.UUID) (*dto.ClientRepresentation, *data.OperationError)
+	// CreateClient creates client, confidential client without secret gets generated secret, returns client identifier
+	CreateClient(realmName string, representation *dto.ClientRepresentation) (uuid.UUID, *data.OperationError)
+	// UpdateClient changes client settings that representation contains
+	UpdateClient(realmName string, id uuid.UUID, representation *dto.ClientRepresentation) *data.OperationError
+	// DeleteClient removes client
+	DeleteClient(realmName string, id uuid.UUID) *data.OperationError
+	// GetClientSecret returns secret of client that authenticates with secret
+	GetClientSecret(realmName string, id uuid.UUID) (*dto.CredentialRepresentation, *data.OperationError)
+	// RegenerateClientSecret sets new generated secret of client that authenticates with secret
+	RegenerateClientSecret(realmName string, id uuid.UUID) (*dto.CredentialRepresentation, *data.OperationError)
+
+	// GetUsers returns page of users that match query sorted by username
+	GetUsers(realmName string, query *dto.UsersQuery) ([]dto.UserRepresentation, *data.OperationError)
+	// CountUsers returns number of users that match query (paging parameters are ignored)
+	CountUsers(realmName string, query *dto.UsersQuery) (int, *data.OperationError)
+	// GetUser returns user by identifier
+	GetUser(realmName string, id uuid.UUID) (*dto.UserRepresentation, *data.OperationError)
+	// CreateUser creates user with password credentials, realm roles and groups of representation, returns user identifier
+	CreateUser(realmName string, representation *dto.UserRepresentation) (uuid.UUID, *data.OperationError)
+	// UpdateUser changes user fields that representation contains, attributes (if present) replace all user attributes
+	UpdateUser(realmName string, id uuid.UUID, representation *dto.UserRepresentation) *data.OperationError
+	// DeleteUser removes user
+	DeleteUser(realmName string, id uuid.UUID) *data.OperationError
+	// ResetPassword sets user password, temporary password requires user to change it on next login
+	ResetPassword(realmName string, id uuid.UUID, credential *dto.CredentialRepresentation) *data.OperationError
+	// GetUserCredentials returns user credentials without secret data
+	GetUserCredentials(realmName string, id uuid.UUID) ([]dto.CredentialRepresentation, *data.OperationError)
+	// DeleteUserCredential removes user OTP (credential id is "otp") or passkey
+	DeleteUserCredential(realmName string, id uuid.UUID, credentialId string) *data.OperationError
+	// SendExecuteActionsEmail sends email with link that requires user to execute actions, lifespan in seconds (0 - default)
+	SendExecuteActionsEmail(realmName string, id uuid.UUID, actions []string, lifespan int) *data.OperationError
+	// SendVerifyEmail sends email verification link to user
+	SendVerifyEmail(realmName string, id uuid.UUID) *data.OperationError
+	// GetUserGroups returns groups that user is member of
+	GetUserGroups(realmName string, id uuid.UUID) ([]dto.GroupRepresentation, *data.OperationError)
+	// JoinGroup makes user a member of group
+	JoinGroup(realmName string, id uuid.UUID, groupId uuid.UUID) *data.OperationError
+	// LeaveGroup removes user from group
+	LeaveGroup(realmName string, id uuid.UUID, groupId uuid.UUID) *data.OperationError
+	// GetUserRoleMappings returns realm roles that are directly assigned to user
+	GetUserRoleMappings(realmName string, id uuid.UUID) (*dto.MappingsRepresentation, *data.OperationError)
+	// GetUserRealmRoles returns realm roles of user, effective roles also include roles of user groups
+	GetUserRealmRoles(realmName string, id uuid.UUID, effective bool) ([]dto.RoleRepresentation, *data.OperationError)
+	// AddUserRealmRoles assigns realm roles to user
+	AddUserRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError
+	// RemoveUserRealmRoles removes realm roles that are directly assigned to user
+	RemoveUserRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError
+
+	// GetRoles returns realm roles
+	GetRoles(realmName string) ([]dto.RoleRepresentation, *data.OperationError)
+	// GetRole returns realm role by name
+	GetRole(realmName string, name string) (*dto.RoleRepresentation, *data.OperationError)
+	// CreateRole creates realm role
+	CreateRole(realmName string, representation *dto.RoleRepresentation) *data.OperationError
+	// UpdateRole changes role name and description, renamed role is renamed in users and groups
+	UpdateRole(realmName string, name string, representation *dto.RoleRepresentation) *data.OperationError
+	// DeleteRole removes realm role, role is also removed from users and groups
+	DeleteRole(realmName string, name string) *data.OperationError
+	// GetRoleUsers returns users that have role directly assigned
+	GetRoleUsers(realmName string, name string) ([]dto.UserRepresentation, *data.OperationError)
+
+	// GetGroups returns realm groups, non-empty search returns groups which names contain it
+	GetGroups(realmName string, search string) ([]dto.GroupRepresentation, *data.OperationError)
+	// GetGroup returns group by identifier
+	GetGroup(realmName string, id uuid.UUID) (*dto.GroupRepresentation, *data.OperationError)
+	// CreateGroup creates group, returns group identifier
+	CreateGroup(realmName string, representation *dto.GroupRepresentation) (uuid.UUID, *data.OperationError)
+	// UpdateGroup changes group name and attributes, renamed group is renamed in user memberships
+	UpdateGroup(realmName string, id uuid.UUID, representation *dto.GroupRepresentation) *data.OperationError
+	// DeleteGroup removes group, members leave removed group
+	DeleteGroup(realmName string, id uuid.UUID) *data.OperationError
+	// GetGroupMembers returns users that are members of group
+	GetGroupMembers(realmName string, id uuid.UUID) ([]dto.UserRepresentation, *data.OperationError)
+	// GetGroupRealmRoles returns realm roles of group
+	GetGroupRealmRoles(realmName string, id uuid.UUID) ([]dto.RoleRepresentation, *data.OperationError)
+	// AddGroupRealmRoles assigns realm roles to group
+	AddGroupRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError
+	// RemoveGroupRealmRoles removes realm roles of group
+	RemoveGroupRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError
+}
+
+// DataContextAdminService is an implementation of AdminService, roles and groups are stored in data.Realm (changed via
+// UpdateRealm), user roles and group memberships are stored in user info (see data/role.go)
+type DataContextAdminService struct {
+	dataProvider *managers.DataContext
+	emailActions *EmailActionService
+	adminRealm   string
+	// mutex serializes read-modify-write changes of realm (roles and groups) and users
+	mutex  sync.Mutex
+	logger *logging.AppLogger
+}
+
+// CreateAdminService creates DataContextAdminService as AdminService
+/* Parameters:
+ *    - dataProvider - data context where realms, clients and users are stored
+ *    - emailActions - email actions service (nil if mail is not configured, email operations are not available)
+ *    - adminRealm - realm of administrators (config.ServerConfig GetAdminRealm), it can't be removed or renamed
+ *    - logger - logger service
+ * Returns: instance of DataContextAdminService as AdminService
+ */
+func CreateAdminService(dataProvider *managers.DataContext, emailActions *EmailActionService, adminRealm string,
+	logger *logging.AppLogger) AdminService {
+	return AdminService(&DataContextAdminService{dataProvider: dataProvider, emailActions: emailActions, adminRealm: adminRealm,
+		logger: logger})
+}
+
+// GetRealms returns all realms
+func (service *DataContextAdminService) GetRealms() ([]dto.RealmRepresentation, *data.OperationError) {
+	realms, err := (*service.dataProvider).GetRealms()
+	if err != nil {
+		return nil, service.getOperationError(err, "Realms read", errors.RealmNotFoundDesc, "")
+	}
+	result := make([]dto.RealmRepresentation, len(realms))
+	for i := range realms {
+		result[i] = createRealmRepresentation(&realms[i])
+	}
+	return result, nil
+}
+
+// GetRealm returns realm by name
+func (service *DataContextAdminService) GetRealm(realmName string) (*dto.RealmRepresentation, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, check
+	}
+	representation := createRealmRepresentation(realm)
+	return &representation, nil
+}
+
+// CreateRealm creates realm without clients and users
+/* Realm that representation doesn't set lifetimes for gets KeyCloak default lifetimes (access token 5 minutes, refresh token
+ * 30 minutes)
+ * Parameters:
+ *    - representation - realm name (required) and settings
+ * Returns: nil if realm was created, otherwise error (conflict if realm already exists)
+ */
+func (service *DataContextAdminService) CreateRealm(representation *dto.RealmRepresentation) *data.OperationError {
+	if len(representation.Realm) == 0 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.NameRequiredDesc}
+	}
+	realm := data.Realm{Name: representation.Realm, Clients: []data.Client{}, Users: []interface{}{},
+		TokenExpiration: defaultAccessTokenLifespan, RefreshTokenExpiration: defaultSsoSessionIdleTimeout}
+	applyRealmRepresentation(&realm, representation)
+	if err := (*service.dataProvider).CreateRealm(realm); err != nil {
+		return service.getOperationError(err, "Realm create", errors.RealmNotFoundDesc, errors.RealmExistsDesc)
+	}
+	service.logger.Info(sf.Format("Admin: realm \"{0}\" was created", realm.Name))
+	return nil
+}
+
+// UpdateRealm changes realm settings that representation contains, realm could be renamed (except admin realm)
+func (service *DataContextAdminService) UpdateRealm(realmName string, representation *dto.RealmRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return check
+	}
+	if realmName == service.adminRealm && len(representation.Realm) > 0 && representation.Realm != realmName {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.AdminRealmRenameDesc}
+	}
+	applyRealmRepresentation(realm, representation)
+	if err := (*service.dataProvider).UpdateRealm(realmName, *realm); err != nil {
+		return service.getOperationError(err, "Realm update", errors.RealmNotFoundDesc, errors.RealmExistsDesc)
+	}
+	service.logger.Info(sf.Format("Admin: realm \"{0}\" was updated", realmName))
+	return nil
+}
+
+// DeleteRealm removes realm with clients and users (admin realm can't be removed)
+func (service *DataContextAdminService) DeleteRealm(realmName string) *data.OperationError {
+	if realmName == service.adminRealm {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.AdminRealmDeleteDesc}
+	}
+	if _, check := service.getRealm(realmName); check != nil {
+		return check
+	}
+	if err := (*service.dataProvider).DeleteRealm(realmName); err != nil {
+		return service.getOperationError(err, "Realm delete", errors.RealmNotFoundDesc, "")
+	}
+	service.logger.Info(sf.Format("Admin: realm \"{0}\" was removed", realmName))
+	return nil
+}
+
+// GetClients returns realm clients, non-empty clientId returns only client with such name
+func (service *DataContextAdminService) GetClients(realmName string, clientId string) ([]dto.ClientRepresentation, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, check
+	}
+	result := make([]dto.ClientRepresentation, 0, len(realm.Clients))
+	for i := range realm.Clients {
+		if len(clientId) == 0 || realm.Clients[i].Name == clientId {
+			result = append(result, createClientRepresentation(&realm.Clients[i]))
+		}
+	}
+	return result, nil
+}
+
+// GetClient returns client by identifier
+func (service *DataContextAdminService) GetClient(realmName string, id uuid.UUID) (*dto.ClientRepresentation, *data.OperationError) {
+	client, check := service.getClient(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	representation := createClientRepresentation(client)
+	return &representation, nil
+}
+
+// CreateClient creates client, confidential client without secret gets generated secret
+/* Client is confidential unless representation has publicClient true, confidential client authenticates with secret unless
+ * representation sets other clientAuthenticatorType
+ * Parameters:
+ *    - realmName - name of a realm
+ *    - representation - client name (clientId, required) and settings, client identifier (id) is generated if it is not set
+ * Returns: identifier of created client or error (conflict if realm already has client with same name)
+ */
+func (service *DataContextAdminService) CreateClient(realmName string, representation *dto.ClientRepresentation) (uuid.UUID, *data.OperationError) {
+	if len(representation.ClientId) == 0 {
+		return uuid.Nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.NameRequiredDesc}
+	}
+	if _, check := service.getRealm(realmName); check != nil {
+		return uuid.Nil, check
+	}
+	client := data.Client{Type: data.Confidential, ID: uuid.New(), Auth: data.Authentication{Type: data.ClientIdAndSecrets}}
+	if id, err := uuid.Parse(representation.Id); err == nil {
+		client.ID = id
+	}
+	if check := applyClientRepresentation(&client, representation); check != nil {
+		return uuid.Nil, check
+	}
+	if client.Type == data.Confidential && client.Auth.IsSecretBased() && len(client.Auth.Value) == 0 {
+		secret, err := random.GenerateToken(adminClientSecretSize)
+		if err != nil {
+			service.logger.Error(sf.Format("Admin: secret of client \"{0}\" was not generated: {1}", client.Name, err.Error()))
+			return uuid.Nil, &data.OperationError{Msg: errors.OtherAppError}
+		}
+		client.Auth.Value = secret
+	}
+	if err := (*service.dataProvider).CreateClient(realmName, client); err != nil {
+		return uuid.Nil, service.getOperationError(err, "Client create", errors.RealmNotFoundDesc,
+			sf.Format(errors.ClientExistsDesc, client.Name))
+	}
+	service.logger.Info(sf.Format("Admin: client \"{0}\" was created in realm \"{1}\"", client.Name, realmName))
+	return client.ID, nil
+}
+
+// UpdateClient changes client settings that representation contains
+func (service *DataContextAdminService) UpdateClient(realmName string, id uuid.UUID, representation *dto.ClientRepresentation) *data.OperationError {
+	client, check := service.getClient(realmName, id)
+	if check != nil {
+		return check
+	}
+	clientName := client.Name
+	if check = applyClientRepresentation(client, representation); check != nil {
+		return check
+	}
+	return service.storeClient(realmName, clientName, client)
+}
+
+// DeleteClient removes client
+func (service *DataContextAdminService) DeleteClient(realmName string, id uuid.UUID) *data.OperationError {
+	client, check := service.getClient(realmName, id)
+	if check != nil {
+		return check
+	}
+	if err := (*service.dataProvider).DeleteClient(realmName, client.Name); err != nil {
+		return service.getOperationError(err, "Client delete", errors.ClientNotFoundDesc, "")
+	}
+	service.logger.Info(sf.Format("Admin: client \"{0}\" was removed from realm \"{1}\"", client.Name, realmName))
+	return nil
+}
+
+// GetClientSecret returns secret of client that authenticates with secret
+func (service *DataContextAdminService) GetClientSecret(realmName string, id uuid.UUID) (*dto.CredentialRepresentation, *data.OperationError) {
+	client, check := service.getClient(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	if client.Type != data.Confidential || !client.Auth.IsSecretBased() {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedCredentialDesc}
+	}
+	return &dto.CredentialRepresentation{Type: clientSecretCredentialType, Value: client.Auth.Value}, nil
+}
+
+// RegenerateClientSecret sets new generated secret of client that authenticates with secret, previous secret becomes invalid
+func (service *DataContextAdminService) RegenerateClientSecret(realmName string, id uuid.UUID) (*dto.CredentialRepresentation, *data.OperationError) {
+	client, check := service.getClient(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	if client.Type != data.Confidential || !client.Auth.IsSecretBased() {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedCredentialDesc}
+	}
+	secret, err := random.GenerateToken(adminClientSecretSize)
+	if err != nil {
+		service.logger.Error(sf.Format("Admin: secret of client \"{0}\" was not generated: {1}", client.Name, err.Error()))
+		return nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	client.Auth.Value = secret
+	if check = service.storeClient(realmName, client.Name, client); check != nil {
+		return nil, check
+	}
+	return &dto.CredentialRepresentation{Type: clientSecretCredentialType, Value: secret}, nil
+}
+
+// getRealm reads realm with clients (disabled realm is also returned because admin manages it)
+func (service *DataContextAdminService) getRealm(realmName string) (*data.Realm, *data.OperationError) {
+	realm, err := (*service.dataProvider).GetRealm(realmName)
+	if err != nil {
+		return nil, service.getOperationError(err, "Realm read", errors.RealmNotFoundDesc, "")
+	}
+	return realm, nil
+}
+
+// getClient reads realm client by identifier
+func (service *DataContextAdminService) getClient(realmName string, id uuid.UUID) (*data.Client, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, check
+	}
+	for i := range realm.Clients {
+		if realm.Clients[i].ID == id {
+			return &realm.Clients[i], nil
+		}
+	}
+	return nil, &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.ClientNotFoundDesc}
+}
+
+func (service *DataContextAdminService) storeClient(realmName string, clientName string, client *data.Client) *data.OperationError {
+	if err := (*service.dataProvider).UpdateClient(realmName, clientName, *client); err != nil {
+		return service.getOperationError(err, "Client update", errors.ClientNotFoundDesc, sf.Format(errors.ClientExistsDesc, client.Name))
+	}
+	service.logger.Info(sf.Format("Admin: client \"{0}\" was updated in realm \"{1}\"", client.Name, realmName))
+	return nil
+}
+
+// getOperationError converts data context error to operation error
+/* Parameters:
+ *    - err - data context error
+ *    - operation - operation name for logging
+ *    - notFoundDesc - description of error if object was not found
+ *    - existsDesc - description of error if object already exists
+ * Returns: operation error, unknown errors are logged
+ */
+func (service *DataContextAdminService) getOperationError(err error, operation string, notFoundDesc string, existsDesc string) *data.OperationError {
+	if check := getUserValidationError(err); check != nil {
+		return check
+	}
+	switch {
+	case e.As(err, &errors.ErrDataSourceNotAvailable):
+		service.logger.Error(sf.Format("Admin: {0} failed, data provider is not available", operation))
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	case e.As(err, &errors.EmptyNotFoundErr) || e.Is(err, errors.ErrZeroLength):
+		return &data.OperationError{Msg: errors.NotFoundMsg, Description: notFoundDesc}
+	case e.As(err, &errors.ErrExists):
+		return &data.OperationError{Msg: errors.ConflictMsg, Description: existsDesc}
+	case e.Is(err, errors.ErrOperationNotSupported):
+		return &data.OperationError{Msg: errors.NotSupportedMsg, Description: errors.OperationNotSupportedDesc}
+	}
+	service.logger.Error(sf.Format("Admin: {0} failed: {1}", operation, err.Error()))
+	return &data.OperationError{Msg: errors.OtherAppError}
+}
+
+func createRealmRepresentation(realm *data.Realm) dto.RealmRepresentation {
+	enabled := realm.IsEnabled()
+	accessTokenLifespan := realm.TokenExpiration
+	refreshTokenLifespan := realm.RefreshTokenExpiration
+	ssoSessionLifespan := realm.GetSsoSessionLifespan()
+	registrationAllowed := realm.RegistrationAllowed
+	verifyEmail := realm.VerifyEmail
+	loginTheme := realm.LoginTheme
+	bruteForceProtected := realm.BruteForceProtection != nil
+	return dto.RealmRepresentation{Id: realm.Name, Realm: realm.Name, Enabled: &enabled, AccessTokenLifespan: &accessTokenLifespan,
+		SsoSessionIdleTimeout: &refreshTokenLifespan, SsoSessionMaxLifespan: &ssoSessionLifespan,
+		RegistrationAllowed: &registrationAllowed, VerifyEmail: &verifyEmail, LoginTheme: &loginTheme,
+		BruteForceProtected: &bruteForceProtected}
+}
+
+func applyRealmRepresentation(realm *data.Realm, representation *dto.RealmRepresentation) {
+	if len(representation.Realm) > 0 {
+		realm.Name = representation.Realm
+	}
+	if representation.Enabled != nil {
+		enabled := *representation.Enabled
+		realm.Enabled = &enabled
+	}
+	if representation.AccessTokenLifespan != nil {
+		realm.TokenExpiration = *representation.AccessTokenLifespan
+	}
+	if representation.SsoSessionIdleTimeout != nil {
+		realm.RefreshTokenExpiration = *representation.SsoSessionIdleTimeout
+	}
+	if representation.SsoSessionMaxLifespan != nil {
+		realm.SsoSessionLifespan = *representation.SsoSessionMaxLifespan
+	}
+	if representation.RegistrationAllowed != nil {
+		realm.RegistrationAllowed = *representation.RegistrationAllowed
+	}
+	if representation.VerifyEmail != nil {
+		realm.VerifyEmail = *representation.VerifyEmail
+	}
+	if representation.LoginTheme != nil {
+		realm.LoginTheme = *representation.LoginTheme
+	}
+	if representation.BruteForceProtected != nil {
+		if !*representation.BruteForceProtected {
+			realm.BruteForceProtection = nil
+		} else if realm.BruteForceProtection == nil {
+			realm.BruteForceProtection = &data.BruteForceProtection{MaxLoginFailures: defaultMaxLoginFailures}
+		}
+	}
+}
+
+// createClientRepresentation creates client representation without secret, secret is visible only to administrators who could
+// view client (see GetClientSecret)
+func createClientRepresentation(client *data.Client) dto.ClientRepresentation {
+	name := client.DisplayName
+	enabled := client.IsEnabled()
+	publicClient := client.Type == data.Public
+	consentRequired := client.ConsentRequired
+	representation := dto.ClientRepresentation{Id: client.ID.String(), ClientId: client.Name, Name: &name, Enabled: &enabled,
+		PublicClient: &publicClient, RedirectUris: client.RedirectUris, ConsentRequired: &consentRequired,
+		Attributes: map[string]string{
+			requireParAttribute:      strconv.FormatBool(client.RequirePar),
+			tlsBoundTokensAttribute:  strconv.FormatBool(client.TlsClientCertificateBoundAccessTokens),
+			dpopBoundTokensAttribute: strconv.FormatBool(client.DPoPBoundAccessTokens),
+		},
+	}
+	if !publicClient {
+		authenticatorType := getClientAuthenticatorType(client.Auth.Type)
+		representation.ClientAuthenticatorType = &authenticatorType
+	}
+	return representation
+}
+
+func applyClientRepresentation(client *data.Client, representation *dto.ClientRepresentation) *data.OperationError {
+	if len(representation.ClientId) > 0 {
+		client.Name = representation.ClientId
+	}
+	if representation.Name != nil {
+		client.DisplayName = *representation.Name
+	}
+	if representation.Enabled != nil {
+		enabled := *representation.Enabled
+		client.Enabled = &enabled
+	}
+	if representation.PublicClient != nil {
+		if *representation.PublicClient {
+			client.Type = data.Public
+			client.Auth = data.Authentication{}
+		} else if client.Type != data.Confidential {
+			client.Type = data.Confidential
+			client.Auth = data.Authentication{Type: data.ClientIdAndSecrets}
+		}
+	}
+	if representation.ClientAuthenticatorType != nil && client.Type == data.Confidential &&
+		*representation.ClientAuthenticatorType != getClientAuthenticatorType(client.Auth.Type) {
+		authType, ok := parseClientAuthenticatorType(*representation.ClientAuthenticatorType)
+		if !ok {
+			return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedCredentialDesc}
+		}
+		client.Auth.Type = authType
+	}
+	if representation.Secret != nil && client.Type == data.Confidential && client.Auth.IsSecretBased() {
+		client.Auth.Value = *representation.Secret
+	}
+	if representation.RedirectUris != nil {
+		client.RedirectUris = representation.RedirectUris
+	}
+	if representation.ConsentRequired != nil {
+		client.ConsentRequired = *representation.ConsentRequired
+	}
+	for name, flag := range map[string]*bool{requireParAttribute: &client.RequirePar,
+		tlsBoundTokensAttribute: &client.TlsClientCertificateBoundAccessTokens, dpopBoundTokensAttribute: &client.DPoPBoundAccessTokens} {
+		if value, ok := representation.Attributes[name]; ok {
+			*flag, _ = strconv.ParseBool(value)
+		}
+	}
+	return nil
+}
+
+func getClientAuthenticatorType(authType data.AuthenticationType) string {
+	switch authType {
+	case data.ClientSecretJwt:
+		return clientSecretJwtAuthenticator
+	case data.PrivateKeyJwt:
+		return clientJwtAuthenticator
+	case data.TlsClientAuth, data.SelfSignedTlsClientAuth:
+		return clientX509Authenticator
+	}
+	return clientSecretAuthenticator
+}
+
+func parseClientAuthenticatorType(authenticatorType string) (data.AuthenticationType, bool) {
+	switch authenticatorType {
+	case clientSecretAuthenticator:
+		return data.ClientIdAndSecrets, true
+	case clientSecretJwtAuthenticator:
+		return data.ClientSecretJwt, true
+	case clientJwtAuthenticator:
+		return data.PrivateKeyJwt, true
+	case clientX509Authenticator:
+		return data.TlsClientAuth, true
+	}
+	return 0, false
+}
@@ -0,0 +1,789 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/utils/hashing"
+	"github.com/wissance/Ferrum/utils/otp"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// KeyCloak realm export values that are converted on realm import and export
+const (
+	openIdConnectProtocol    = "openid-connect"
+	keyCloakArgon2Algorithm  = "argon2"
+	keyCloakTotpType         = "totp"
+	keyCloakHmacPrefix       = "Hmac"
+	keyCloakBase32Encoding   = "BASE32"
+	keyCloakSecretMask       = "**********"
+	keyCloakPolicySeparator  = " and "
+	smtpHostKey              = "host"
+	userStorageComponentType = "org.keycloak.storage.UserStorageProvider"
+	keyProviderComponentType = "org.keycloak.keys.KeyProvider"
+	userProfileComponentType = "org.keycloak.userprofile.UserProfileProvider"
+)
+
+// KeyCloak argon2 defaults that are used if credential doesn't have additional parameters
+const (
+	keyCloakArgon2Iterations  = 5
+	keyCloakArgon2Memory      = 7168
+	keyCloakArgon2Parallelism = 1
+	keyCloakArgon2Type        = "id"
+	keyCloakArgon2Version     = "1.3"
+)
+
+// Types of objects in realm migration report (dto.SkippedFeatureRepresentation Resource)
+const (
+	realmReportResource  = "realm"
+	clientReportResource = "client"
+	userReportResource   = "user"
+	groupReportResource  = "group"
+	roleReportResource   = "role"
+)
+
+// keyCloakBuiltInClients are clients that every KeyCloak realm has (KeyCloak account and admin consoles), they are not
+// imported except admin-cli that is a public client of admin tools
+var keyCloakBuiltInClients = map[string]bool{"account": true, "account-console": true, "broker": true, "realm-management": true,
+	"security-admin-console": true}
+
+// keyCloakDefaultClientScopes are client scopes that every KeyCloak realm has, Ferrum issues standard claims without them
+var keyCloakDefaultClientScopes = map[string]bool{"acr": true, "address": true, "basic": true, "email": true,
+	"microprofile-jwt": true, "offline_access": true, "phone": true, "profile": true, "role_list": true, "roles": true,
+	"web-origins": true, "organization": true, "saml_organization": true}
+
+// skippedFeature is a feature of object that is reported as skipped if object has it
+type skippedFeature struct {
+	name    string
+	present bool
+}
+
+// keyCloakPasswordData is a KeyCloak password credential secretData and credentialData (argon2 parameters are additional)
+type keyCloakPasswordData struct {
+	Value                string              `json:"value,omitempty"`
+	Salt                 string              `json:"salt,omitempty"`
+	HashIterations       int                 `json:"hashIterations,omitempty"`
+	Algorithm            string              `json:"algorithm,omitempty"`
+	AdditionalParameters map[string][]string `json:"additionalParameters"`
+}
+
+// keyCloakOtpData is a KeyCloak OTP credential secretData and credentialData, secret is a plain string unless SecretEncoding is BASE32
+type keyCloakOtpData struct {
+	Value          string `json:"value,omitempty"`
+	SubType        string `json:"subType,omitempty"`
+	Digits         int    `json:"digits,omitempty"`
+	Period         int    `json:"period,omitempty"`
+	Algorithm      string `json:"algorithm,omitempty"`
+	Counter        int    `json:"counter,omitempty"`
+	SecretEncoding string `json:"secretEncoding,omitempty"`
+}
+
+// ImportRealm creates realm with clients, users, realm roles and groups of KeyCloak realm export
+/* Realm settings are imported as CreateRealm does with KeyCloak password policy, brute-force, OTP and SMTP settings. Users keep
+ * their KeyCloak identifiers and hashed passwords (pbkdf2 and argon2 credentials are stored as is and are rehashed on the first
+ * login), users with password in plain text (credential value) get its hash. Import is all or nothing: realm is removed if any
+ * client or user was not stored. KeyCloak features that Ferrum doesn't support are skipped and listed in report
+ * Parameters:
+ *    - representation - KeyCloak realm export, realm name is required
+ * Returns: import report or error (conflict if realm already exists)
+ */
+func (service *DataContextAdminService) ImportRealm(representation *dto.RealmExportRepresentation) (*dto.RealmMigrationReport, *data.OperationError) {
+	if len(representation.Realm) == 0 {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.NameRequiredDesc}
+	}
+	report := &dto.RealmMigrationReport{Realm: representation.Realm, Skipped: []dto.SkippedFeatureRepresentation{}}
+	realm := data.Realm{Name: representation.Realm, Clients: []data.Client{}, Users: []interface{}{},
+		TokenExpiration: defaultAccessTokenLifespan, RefreshTokenExpiration: defaultSsoSessionIdleTimeout}
+	applyRealmRepresentation(&realm, &representation.RealmRepresentation)
+	importRealmSettings(&realm, representation, report)
+	importRealmRoles(&realm, representation.Roles, report)
+	importGroups(&realm, representation.Groups, report)
+	users := make([]data.User, 0, len(representation.Users))
+	for i := range representation.Users {
+		user, err := importUser(&realm, &representation.Users[i], report)
+		if err != nil {
+			service.logger.Error(sf.Format("Admin: user \"{0}\" was not imported: {1}", representation.Users[i].Username, err.Error()))
+			return nil, &data.OperationError{Msg: errors.OtherAppError}
+		}
+		if user != nil {
+			users = append(users, user)
+		}
+	}
+
+	if err := (*service.dataProvider).CreateRealm(realm); err != nil {
+		return nil, service.getOperationError(err, "Realm import", errors.RealmNotFoundDesc, errors.RealmExistsDesc)
+	}
+	for i := range representation.Clients {
+		client := &representation.Clients[i]
+		if !isClientImported(client, report) {
+			continue
+		}
+		if _, check := service.CreateClient(realm.Name, &client.ClientRepresentation); check != nil {
+			return nil, service.rollbackImport(realm.Name, check)
+		}
+		report.Clients++
+	}
+	for _, user := range users {
+		if err := (*service.dataProvider).CreateUser(realm.Name, user); err != nil {
+			return nil, service.rollbackImport(realm.Name, service.getOperationError(err, "User import", errors.RealmNotFoundDesc,
+				errors.UserExistsDesc))
+		}
+	}
+	report.Users = len(users)
+	report.Roles = len(realm.Roles)
+	report.Groups = len(realm.Groups)
+	service.logger.Info(sf.Format("Admin: realm \"{0}\" was imported with {1} clients and {2} users, {3} features were skipped",
+		realm.Name, report.Clients, report.Users, len(report.Skipped)))
+	return report, nil
+}
+
+// ExportRealm returns realm in KeyCloak realm export format (could be imported by KeyCloak or by ImportRealm)
+/* Client secrets and password hashes (pbkdf2 and argon2id) are exported as is, therefore export must be available only to realm
+ * administrators. Ferrum features that KeyCloak realm export can't contain are skipped and listed in report
+ * Parameters:
+ *    - realmName - name of a realm
+ *    - query - what export contains besides realm settings (clients, realm roles and groups, users)
+ * Returns: realm export, export report or error
+ */
+func (service *DataContextAdminService) ExportRealm(realmName string, query *dto.RealmExportQuery) (*dto.RealmExportRepresentation,
+	*dto.RealmMigrationReport, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, nil, check
+	}
+	report := &dto.RealmMigrationReport{Realm: realm.Name, Skipped: []dto.SkippedFeatureRepresentation{}}
+	representation := dto.RealmExportRepresentation{RealmRepresentation: createRealmRepresentation(realm)}
+	exportRealmSettings(realm, &representation, report)
+	if query.GroupsAndRoles {
+		representation.Roles = &dto.RolesRepresentation{Realm: createRoleRepresentations(realm, getRealmRoleNames(realm))}
+		representation.Groups = make([]dto.GroupRepresentation, len(realm.Groups))
+		for i := range realm.Groups {
+			representation.Groups[i] = createGroupRepresentation(&realm.Groups[i])
+		}
+		report.Roles = len(realm.Roles)
+		report.Groups = len(realm.Groups)
+	}
+	if query.Clients {
+		representation.Clients = make([]dto.ClientExportRepresentation, len(realm.Clients))
+		for i := range realm.Clients {
+			representation.Clients[i] = exportClient(&realm.Clients[i], report)
+		}
+		report.Clients = len(realm.Clients)
+	}
+	if query.Users {
+		users, check := service.getUsers(realmName)
+		if check != nil {
+			return nil, nil, check
+		}
+		representation.Users = make([]dto.UserExportRepresentation, len(users))
+		otpPolicy := realm.GetOtpPolicy()
+		for i, user := range users {
+			representation.Users[i] = exportUser(user, &otpPolicy, report)
+		}
+		report.Users = len(users)
+	}
+	service.logger.Info(sf.Format("Admin: realm \"{0}\" was exported, {1} features were skipped", realm.Name, len(report.Skipped)))
+	return &representation, report, nil
+}
+
+// rollbackImport removes partially imported realm and returns import error
+func (service *DataContextAdminService) rollbackImport(realmName string, check *data.OperationError) *data.OperationError {
+	if err := (*service.dataProvider).DeleteRealm(realmName); err != nil {
+		service.logger.Error(sf.Format("Admin: partially imported realm \"{0}\" was not removed: {1}", realmName, err.Error()))
+	}
+	return check
+}
+
+// importRealmSettings sets realm settings of KeyCloak realm export that are not a part of dto.RealmRepresentation
+func importRealmSettings(realm *data.Realm, representation *dto.RealmExportRepresentation, report *dto.RealmMigrationReport) {
+	if representation.PasswordPolicy != nil && len(*representation.PasswordPolicy) > 0 {
+		realm.PasswordPolicy = parseKeyCloakPasswordPolicy(*representation.PasswordPolicy, realm.Name, report)
+	}
+	if protection := realm.BruteForceProtection; protection != nil {
+		for value, setting := range map[*int]*int{representation.FailureFactor: &protection.MaxLoginFailures,
+			representation.WaitIncrementSeconds: &protection.WaitIncrement, representation.MaxFailureWaitSeconds: &protection.MaxWait,
+			representation.MaxDeltaTimeSeconds:  &protection.FailureResetTime,
+			representation.MaxTemporaryLockouts: &protection.MaxTemporaryLockouts} {
+			if value != nil {
+				*setting = *value
+			}
+		}
+		if representation.PermanentLockout != nil {
+			protection.PermanentLockout = *representation.PermanentLockout
+		}
+	}
+	importOtpPolicy(realm, representation, report)
+	if len(representation.SmtpServer[smtpHostKey]) > 0 {
+		realm.Email = parseKeyCloakSmtpServer(representation.SmtpServer, realm.Name, report)
+		if representation.ActionTokenGeneratedByUserLifespan != nil {
+			realm.Email.ActionTokenLifespan = *representation.ActionTokenGeneratedByUserLifespan
+		}
+		if representation.ActionTokenGeneratedByAdminLifespan != nil {
+			realm.Email.AdminActionTokenLifespan = *representation.ActionTokenGeneratedByAdminLifespan
+		}
+	}
+
+	for _, provider := range representation.IdentityProviders {
+		addSkippedFeature(report, realmReportResource, realm.Name, sf.Format("identity provider \"{0}\" ({1})", provider.Alias,
+			provider.ProviderId))
+	}
+	for _, component := range representation.Components[userStorageComponentType] {
+		addSkippedFeature(report, realmReportResource, realm.Name, sf.Format("user federation \"{0}\" ({1})", component.Name,
+			component.ProviderId))
+	}
+	if len(representation.Components[keyProviderComponentType]) > 0 {
+		addSkippedFeature(report, realmReportResource, realm.Name, "realm keys (tokens that KeyCloak issued are not valid)")
+	}
+	if len(representation.Components[userProfileComponentType]) > 0 {
+		addSkippedFeature(report, realmReportResource, realm.Name, "declarative user profile")
+	}
+	for _, flow := range representation.AuthenticationFlows {
+		if !flow.BuiltIn {
+			addSkippedFeature(report, realmReportResource, realm.Name, sf.Format("authentication flow \"{0}\"", flow.Alias))
+		}
+	}
+	for _, scope := range representation.ClientScopes {
+		if !keyCloakDefaultClientScopes[scope.Name] {
+			addSkippedFeature(report, realmReportResource, realm.Name, sf.Format("client scope \"{0}\"", scope.Name))
+		}
+	}
+}
+
+func importOtpPolicy(realm *data.Realm, representation *dto.RealmExportRepresentation, report *dto.RealmMigrationReport) {
+	if representation.OtpPolicyType != nil && *representation.OtpPolicyType != keyCloakTotpType {
+		addSkippedFeature(report, realmReportResource, realm.Name, sf.Format("OTP policy type \"{0}\"", *representation.OtpPolicyType))
+		return
+	}
+	if representation.OtpPolicyAlgorithm == nil && representation.OtpPolicyDigits == nil && representation.OtpPolicyPeriod == nil &&
+		representation.OtpPolicyLookAheadWindow == nil {
+		return
+	}
+	policy := data.OtpPolicy{LookAhead: data.DefaultOtpLookAhead}
+	if representation.OtpPolicyAlgorithm != nil {
+		algorithm := strings.TrimPrefix(*representation.OtpPolicyAlgorithm, keyCloakHmacPrefix)
+		if otp.IsAlgorithmSupported(algorithm) {
+			policy.Algorithm = algorithm
+		} else {
+			addSkippedFeature(report, realmReportResource, realm.Name, sf.Format("OTP algorithm \"{0}\"", *representation.OtpPolicyAlgorithm))
+		}
+	}
+	for value, setting := range map[*int]*int{representation.OtpPolicyDigits: &policy.Digits, representation.OtpPolicyPeriod: &policy.Period,
+		representation.OtpPolicyLookAheadWindow: &policy.LookAhead} {
+		if value != nil {
+			*setting = *value
+		}
+	}
+	realm.OtpPolicy = &policy
+}
+
+// importRealmRoles sets realm roles definitions, composite roles are imported without composites, client roles are skipped
+func importRealmRoles(realm *data.Realm, roles *dto.RolesRepresentation, report *dto.RealmMigrationReport) {
+	if roles == nil {
+		return
+	}
+	for _, role := range roles.Realm {
+		if len(role.Name) == 0 || realm.FindRole(role.Name) != nil {
+			continue
+		}
+		id, err := uuid.Parse(role.Id)
+		if err != nil {
+			id = uuid.New()
+		}
+		realm.Roles = append(realm.Roles, data.Role{Id: id, Name: role.Name, Description: role.Description})
+		if role.Composite {
+			addSkippedFeature(report, roleReportResource, role.Name, "composite role (role is imported without composites)")
+		}
+	}
+	clientIds := make([]string, 0, len(roles.Client))
+	for clientId := range roles.Client {
+		clientIds = append(clientIds, clientId)
+	}
+	sort.Strings(clientIds)
+	for _, clientId := range clientIds {
+		if len(roles.Client[clientId]) > 0 && !keyCloakBuiltInClients[clientId] {
+			addSkippedFeature(report, clientReportResource, clientId, sf.Format("{0} client roles", len(roles.Client[clientId])))
+		}
+	}
+}
+
+// importGroups sets top-level groups with realm roles that realm has, subgroups are skipped
+func importGroups(realm *data.Realm, groups []dto.GroupRepresentation, report *dto.RealmMigrationReport) {
+	for _, representation := range groups {
+		if len(representation.Name) == 0 || realm.FindGroupByName(representation.Name) != nil {
+			continue
+		}
+		id, err := uuid.Parse(representation.Id)
+		if err != nil || realm.FindGroup(id) != nil {
+			id = uuid.New()
+		}
+		group := data.Group{Id: id, Name: representation.Name, Attributes: representation.Attributes}
+		for _, role := range representation.RealmRoles {
+			if realm.FindRole(role) != nil {
+				group.RealmRoles = append(group.RealmRoles, role)
+			}
+		}
+		realm.Groups = append(realm.Groups, group)
+		if len(representation.ClientRoles) > 0 {
+			addSkippedFeature(report, groupReportResource, group.Name, "client roles")
+		}
+		for _, subGroup := range representation.SubGroups {
+			path := subGroup.Path
+			if len(path) == 0 {
+				path = groupPathSeparator + group.Name + groupPathSeparator + subGroup.Name
+			}
+			addSkippedFeature(report, groupReportResource, path, "subgroup (subgroups and their members are not imported)")
+		}
+	}
+}
+
+// isClientImported checks whether client could be imported (openid-connect client that is not KeyCloak built-in client), masked
+// secret of KeyCloak partial export is removed from representation (client gets generated secret)
+func isClientImported(client *dto.ClientExportRepresentation, report *dto.RealmMigrationReport) bool {
+	if len(client.Protocol) > 0 && client.Protocol != openIdConnectProtocol {
+		addSkippedFeature(report, clientReportResource, client.ClientId, sf.Format("{0} protocol (client is not imported)", client.Protocol))
+		return false
+	}
+	if keyCloakBuiltInClients[client.ClientId] {
+		addSkippedFeature(report, clientReportResource, client.ClientId, "KeyCloak built-in client (client is not imported)")
+		return false
+	}
+	if client.ClientAuthenticatorType != nil && (client.PublicClient == nil || !*client.PublicClient) {
+		if _, ok := parseClientAuthenticatorType(*client.ClientAuthenticatorType); !ok {
+			addSkippedFeature(report, clientReportResource, client.ClientId, sf.Format("client authenticator \"{0}\" (client is not imported)",
+				*client.ClientAuthenticatorType))
+			return false
+		}
+	}
+	if client.Secret != nil && *client.Secret == keyCloakSecretMask {
+		client.Secret = nil
+		addSkippedFeature(report, clientReportResource, client.ClientId, "masked client secret (client gets new secret)")
+	}
+	addSkippedFeatures(report, clientReportResource, client.ClientId, []skippedFeature{{"bearer-only client", client.BearerOnly},
+		{"implicit flow", client.ImplicitFlowEnabled}, {"service account", client.ServiceAccountsEnabled},
+		{"authorization services", client.AuthorizationServicesEnabled}, {"protocol mappers", len(client.ProtocolMappers) > 0}})
+	return true
+}
+
+// importUser creates user of KeyCloak realm export user (realm roles and groups that realm doesn't have are skipped), service
+// account users are not imported (nil user)
+func importUser(realm *data.Realm, representation *dto.UserExportRepresentation, report *dto.RealmMigrationReport) (data.User, error) {
+	name := representation.Username
+	if len(representation.ServiceAccountClientId) > 0 {
+		addSkippedFeature(report, userReportResource, name, "service account user (user is not imported)")
+		return nil, nil
+	}
+	id, err := uuid.Parse(representation.Id)
+	if err != nil {
+		id = uuid.New()
+	}
+	// hashed password is stored as is (user credentials.password), like data manager stores it
+	credentials := map[string]interface{}{}
+	for i := range representation.Credentials {
+		credential := &representation.Credentials[i]
+		if credential.Type != data.PasswordCredentialType || len(credential.Value) > 0 {
+			continue
+		}
+		if passwordHash, feature := parseKeyCloakPasswordCredential(credential); len(feature) > 0 {
+			addSkippedFeature(report, userReportResource, name, feature)
+		} else {
+			credentials[data.PasswordCredentialType] = passwordHash
+		}
+	}
+	user := data.CreateUser(map[string]interface{}{"info": map[string]interface{}{data.SubAttribute: id.String()},
+		"credentials": credentials})
+	userRepresentation := representation.UserRepresentation
+	userRepresentation.Credentials = nil
+	userRepresentation.Attributes = map[string][]string{}
+	for attribute, values := range representation.Attributes {
+		if !isUserRepresentationAttribute(attribute) {
+			userRepresentation.Attributes[attribute] = values
+		}
+	}
+	userRepresentation.RealmRoles = []string{}
+	for _, role := range representation.RealmRoles {
+		if realm.FindRole(role) != nil {
+			userRepresentation.RealmRoles = append(userRepresentation.RealmRoles, role)
+		} else {
+			addSkippedFeature(report, userReportResource, name, sf.Format("realm role \"{0}\" that realm doesn't have", role))
+		}
+	}
+	userRepresentation.Groups = []string{}
+	for _, group := range representation.Groups {
+		groupName := strings.TrimPrefix(group, groupPathSeparator)
+		if realm.FindGroupByName(groupName) != nil {
+			userRepresentation.Groups = append(userRepresentation.Groups, groupName)
+		} else {
+			addSkippedFeature(report, userReportResource, name, sf.Format("membership in group \"{0}\"", group))
+		}
+	}
+	if err = applyUserRepresentation(realm, user, &userRepresentation); err != nil {
+		return nil, err
+	}
+	for i := range representation.Credentials {
+		if err = importCredential(realm, user, &representation.Credentials[i], report); err != nil {
+			return nil, err
+		}
+	}
+	addSkippedFeatures(report, userReportResource, name, []skippedFeature{{"client roles", len(representation.ClientRoles) > 0},
+		{"identity provider links", len(representation.FederatedIdentities) > 0}})
+	return user, nil
+}
+
+// importCredential sets user password (credential value in plain text) or OTP credential, hashed passwords are already stored
+// (see importUser), other credentials are skipped
+func importCredential(realm *data.Realm, user data.User, credential *dto.CredentialRepresentation, report *dto.RealmMigrationReport) error {
+	name := user.GetUsername()
+	switch credential.Type {
+	case data.PasswordCredentialType:
+		if len(credential.Value) == 0 {
+			return nil
+		}
+		if err := user.SetPassword(credential.Value); err != nil {
+			return err
+		}
+		if credential.Temporary != nil && *credential.Temporary {
+			return data.AddRequiredAction(user, data.UpdatePasswordAction)
+		}
+		return nil
+	case data.OtpCredentialType:
+		otpCredential, feature := parseKeyCloakOtpCredential(credential, realm.GetOtpPolicy())
+		if len(feature) > 0 {
+			addSkippedFeature(report, userReportResource, name, feature)
+			return nil
+		}
+		return user.SetOtpCredential(otpCredential)
+	}
+	addSkippedFeature(report, userReportResource, name, sf.Format("{0} credential", credential.Type))
+	return nil
+}
+
+// parseKeyCloakPasswordCredential returns password hash of KeyCloak pbkdf2 or argon2 credential or skipped feature description
+func parseKeyCloakPasswordCredential(credential *dto.CredentialRepresentation) (string, string) {
+	var secretData, credentialData keyCloakPasswordData
+	if json.Unmarshal([]byte(credential.SecretData), &secretData) != nil ||
+		json.Unmarshal([]byte(credential.CredentialData), &credentialData) != nil {
+		return "", "password credential without secret data"
+	}
+	var passwordHash string
+	var err error
+	switch credentialData.Algorithm {
+	case hashing.Pbkdf2Algorithm, hashing.Pbkdf2Sha256Algorithm, hashing.Pbkdf2Sha512Algorithm:
+		passwordHash, err = hashing.EncodePbkdf2Hash(credentialData.Algorithm, credentialData.HashIterations, secretData.Salt,
+			secretData.Value)
+	case keyCloakArgon2Algorithm:
+		parameters := credentialData.AdditionalParameters
+		if getKeyCloakParameter(parameters, "type", keyCloakArgon2Type) != keyCloakArgon2Type ||
+			getKeyCloakParameter(parameters, "version", keyCloakArgon2Version) != keyCloakArgon2Version {
+			return "", "argon2 password hash of type other than argon2id 1.3"
+		}
+		iterations := credentialData.HashIterations
+		if iterations <= 0 {
+			iterations = keyCloakArgon2Iterations
+		}
+		memory, _ := strconv.Atoi(getKeyCloakParameter(parameters, "memory", strconv.Itoa(keyCloakArgon2Memory)))
+		parallelism, _ := strconv.Atoi(getKeyCloakParameter(parameters, "parallelism", strconv.Itoa(keyCloakArgon2Parallelism)))
+		passwordHash, err = hashing.EncodeArgon2idHash(iterations, memory, parallelism, secretData.Salt, secretData.Value)
+	default:
+		return "", sf.Format("password hash algorithm \"{0}\"", credentialData.Algorithm)
+	}
+	if err != nil {
+		return "", "invalid password hash"
+	}
+	return passwordHash, ""
+}
+
+// parseKeyCloakOtpCredential returns TOTP credential of KeyCloak OTP credential or skipped feature description, Ferrum checks
+// codes of all users with realm OTP policy therefore credential with other code settings is skipped
+func parseKeyCloakOtpCredential(credential *dto.CredentialRepresentation, policy data.OtpPolicy) (*data.OtpCredential, string) {
+	var secretData, credentialData keyCloakOtpData
+	if json.Unmarshal([]byte(credential.SecretData), &secretData) != nil ||
+		json.Unmarshal([]byte(credential.CredentialData), &credentialData) != nil || len(secretData.Value) == 0 {
+		return nil, "OTP credential without secret data"
+	}
+	if len(credentialData.SubType) > 0 && credentialData.SubType != keyCloakTotpType {
+		return nil, sf.Format("OTP credential type \"{0}\"", credentialData.SubType)
+	}
+	if (credentialData.Digits > 0 && credentialData.Digits != policy.Digits) || (credentialData.Period > 0 && credentialData.Period != policy.Period) ||
+		(len(credentialData.Algorithm) > 0 && strings.TrimPrefix(credentialData.Algorithm, keyCloakHmacPrefix) != policy.Algorithm) {
+		return nil, "OTP credential with settings that differ from realm OTP policy"
+	}
+	if credentialData.SecretEncoding == keyCloakBase32Encoding {
+		if _, err := otp.DecodeSecret(secretData.Value); err != nil {
+			return nil, "invalid OTP secret"
+		}
+		return &data.OtpCredential{Secret: strings.ToUpper(strings.TrimRight(secretData.Value, "="))}, ""
+	}
+	return &data.OtpCredential{Secret: otp.EncodeSecret([]byte(secretData.Value))}, ""
+}
+
+// parseKeyCloakPasswordPolicy converts KeyCloak password policy string (i.e. "length(8) and digits(1)") to realm password policy
+func parseKeyCloakPasswordPolicy(value string, realmName string, report *dto.RealmMigrationReport) *data.PasswordPolicy {
+	policy := data.PasswordPolicy{}
+	rules := map[string]*int{"length": &policy.MinLength, "maxLength": &policy.MaxLength, "digits": &policy.MinDigits,
+		"lowerCase": &policy.MinLowerCase, "upperCase": &policy.MinUpperCase, "specialChars": &policy.MinSpecialChars,
+		"passwordHistory": &policy.History, "forceExpiredPasswordChange": &policy.MaxAge}
+	for _, item := range strings.Split(value, keyCloakPolicySeparator) {
+		item = strings.TrimSpace(item)
+		name, argument := item, ""
+		if start := strings.Index(item, "("); start > 0 && strings.HasSuffix(item, ")") {
+			name, argument = item[:start], item[start+1:len(item)-1]
+		}
+		if rule, ok := rules[name]; ok {
+			if number, err := strconv.Atoi(argument); err == nil {
+				*rule = number
+				continue
+			}
+		}
+		switch name {
+		case "notUsername":
+			policy.NotUsername = true
+		case "notEmail":
+			policy.NotEmail = true
+		case "":
+		default:
+			addSkippedFeature(report, realmReportResource, realmName, sf.Format("password policy \"{0}\"", item))
+		}
+	}
+	return &policy
+}
+
+// parseKeyCloakSmtpServer converts KeyCloak smtpServer settings to realm email settings, masked password is skipped
+func parseKeyCloakSmtpServer(smtpServer map[string]string, realmName string, report *dto.RealmMigrationReport) *data.EmailSettings {
+	settings := data.EmailSettings{From: smtpServer["from"], FromDisplayName: smtpServer["fromDisplayName"],
+		ReplyTo: smtpServer["replyTo"], Host: smtpServer[smtpHostKey], Ssl: smtpServer["ssl"] == "true",
+		StartTls: smtpServer["starttls"] == "true"}
+	settings.Port, _ = strconv.Atoi(smtpServer["port"])
+	if smtpServer["auth"] == "true" {
+		settings.Username = smtpServer["user"]
+		if password := smtpServer["password"]; password != keyCloakSecretMask {
+			settings.Password = password
+		} else {
+			addSkippedFeature(report, realmReportResource, realmName, "masked SMTP password")
+		}
+	}
+	return &settings
+}
+
+// exportRealmSettings sets KeyCloak realm export settings that are not a part of dto.RealmRepresentation
+func exportRealmSettings(realm *data.Realm, representation *dto.RealmExportRepresentation, report *dto.RealmMigrationReport) {
+	if realm.PasswordPolicy != nil {
+		passwordPolicy := formatKeyCloakPasswordPolicy(realm.PasswordPolicy)
+		representation.PasswordPolicy = &passwordPolicy
+		if len(realm.PasswordPolicy.DenylistFile) > 0 {
+			addSkippedFeature(report, realmReportResource, realm.Name, "password denylist file")
+		}
+	}
+	if protection := realm.BruteForceProtection; protection != nil {
+		permanentLockout := protection.PermanentLockout
+		representation.FailureFactor = getIntPtr(protection.MaxLoginFailures)
+		representation.WaitIncrementSeconds = getIntPtr(getDefaultValue(protection.WaitIncrement, data.DefaultLoginWaitIncrement))
+		representation.MaxFailureWaitSeconds = getIntPtr(getDefaultValue(protection.MaxWait, data.DefaultLoginMaxWait))
+		representation.MaxDeltaTimeSeconds = getIntPtr(getDefaultValue(protection.FailureResetTime, data.DefaultLoginFailureResetTime))
+		representation.MaxTemporaryLockouts = getIntPtr(protection.MaxTemporaryLockouts)
+		representation.PermanentLockout = &permanentLockout
+		if protection.MaxIpLoginFailures > 0 {
+			addSkippedFeature(report, realmReportResource, realm.Name, "ip address brute-force protection")
+		}
+	}
+	if realm.OtpPolicy != nil {
+		policy := realm.GetOtpPolicy()
+		otpType := keyCloakTotpType
+		algorithm := keyCloakHmacPrefix + policy.Algorithm
+		representation.OtpPolicyType = &otpType
+		representation.OtpPolicyAlgorithm = &algorithm
+		representation.OtpPolicyDigits = getIntPtr(policy.Digits)
+		representation.OtpPolicyPeriod = getIntPtr(policy.Period)
+		representation.OtpPolicyLookAheadWindow = getIntPtr(policy.LookAhead)
+		if policy.Required || len(policy.RequiredRoles) > 0 {
+			addSkippedFeature(report, realmReportResource, realm.Name, "required OTP")
+		}
+	}
+	if email := realm.Email; email != nil {
+		representation.SmtpServer = map[string]string{"from": email.From, "fromDisplayName": email.FromDisplayName,
+			"replyTo": email.ReplyTo, smtpHostKey: email.Host, "ssl": strconv.FormatBool(email.Ssl),
+			"starttls": strconv.FormatBool(email.StartTls), "auth": strconv.FormatBool(len(email.Username) > 0)}
+		if email.Port > 0 {
+			representation.SmtpServer["port"] = strconv.Itoa(email.Port)
+		}
+		if len(email.Username) > 0 {
+			representation.SmtpServer["user"] = email.Username
+			representation.SmtpServer["password"] = email.Password
+		}
+		representation.ActionTokenGeneratedByUserLifespan = getIntPtr(getDefaultValue(email.ActionTokenLifespan,
+			data.DefaultActionTokenLifespan))
+		representation.ActionTokenGeneratedByAdminLifespan = getIntPtr(getDefaultValue(email.AdminActionTokenLifespan,
+			data.DefaultAdminActionTokenLifespan))
+	}
+	addSkippedFeatures(report, realmReportResource, realm.Name, []skippedFeature{
+		{"initial access tokens", len(realm.InitialAccessTokens) > 0}, {"passkeys (WebAuthn) settings", realm.WebAuthn != nil},
+		{"user profile", realm.UserProfile != nil}})
+}
+
+func exportClient(client *data.Client, report *dto.RealmMigrationReport) dto.ClientExportRepresentation {
+	representation := dto.ClientExportRepresentation{ClientRepresentation: createClientRepresentation(client),
+		Protocol: openIdConnectProtocol}
+	if client.Type == data.Confidential && client.Auth.IsSecretBased() {
+		secret := client.Auth.Value
+		representation.Secret = &secret
+	}
+	addSkippedFeatures(report, clientReportResource, client.Name, []skippedFeature{
+		{"CIBA settings", len(client.BackChannelTokenDeliveryMode) > 0},
+		{"dynamic registration access token", len(client.RegistrationAccessTokenHash) > 0},
+		{"client authentication keys", client.Auth.Jwks != nil || len(client.Auth.TlsSubjectDn) > 0 || len(client.Auth.TlsSanDns) > 0 ||
+			len(client.Auth.TlsSanUri) > 0}})
+	return representation
+}
+
+// exportUser creates KeyCloak realm export user with password hash and OTP credentials, passkeys and passwords in plain text
+// or in bcrypt hash are skipped
+func exportUser(user data.User, otpPolicy *data.OtpPolicy, report *dto.RealmMigrationReport) dto.UserExportRepresentation {
+	name := user.GetUsername()
+	representation := dto.UserExportRepresentation{UserRepresentation: createUserRepresentation(user)}
+	representation.RealmRoles = data.GetUserRoles(user)
+	groups := data.GetUserGroups(user)
+	representation.Groups = make([]string, len(groups))
+	for i, group := range groups {
+		representation.Groups[i] = groupPathSeparator + group
+	}
+	representation.Credentials = []dto.CredentialRepresentation{}
+	if password := user.GetPassword(); len(password) > 0 {
+		if credential, ok := createKeyCloakPasswordCredential(password); ok {
+			if changed := user.GetPasswordChanged(); !changed.IsZero() {
+				credential.CreatedDate = changed.UnixMilli()
+			}
+			representation.Credentials = append(representation.Credentials, *credential)
+		} else {
+			addSkippedFeature(report, userReportResource, name, "password that is not stored as pbkdf2 or argon2id hash")
+		}
+	}
+	if otpCredential := user.GetOtpCredential(); otpCredential != nil {
+		representation.Credentials = append(representation.Credentials, createKeyCloakOtpCredential(otpCredential, otpPolicy))
+		if len(otpCredential.RecoveryCodes) > 0 {
+			addSkippedFeature(report, userReportResource, name, "OTP recovery codes")
+		}
+	}
+	if len(user.GetWebAuthnCredentials()) > 0 {
+		addSkippedFeature(report, userReportResource, name, "passkeys (WebAuthn credentials)")
+	}
+	return representation
+}
+
+// createKeyCloakPasswordCredential creates KeyCloak password credential of pbkdf2 or argon2id hash
+func createKeyCloakPasswordCredential(passwordHash string) (*dto.CredentialRepresentation, bool) {
+	decoded, err := hashing.DecodePasswordHash(passwordHash)
+	if err != nil {
+		return nil, false
+	}
+	secretData := keyCloakPasswordData{Value: decoded.Value, Salt: decoded.Salt, AdditionalParameters: map[string][]string{}}
+	credentialData := keyCloakPasswordData{HashIterations: decoded.Iterations, Algorithm: decoded.Algorithm,
+		AdditionalParameters: map[string][]string{}}
+	if decoded.Algorithm == hashing.Argon2idAlgorithm {
+		value, _ := base64.StdEncoding.DecodeString(decoded.Value)
+		credentialData.Algorithm = keyCloakArgon2Algorithm
+		credentialData.AdditionalParameters = map[string][]string{"type": {keyCloakArgon2Type}, "version": {keyCloakArgon2Version},
+			"memory": {strconv.Itoa(decoded.Memory)}, "parallelism": {strconv.Itoa(decoded.Parallelism)},
+			"hashLength": {strconv.Itoa(len(value))}}
+	}
+	secretJson, _ := json.Marshal(secretData)
+	credentialJson, _ := json.Marshal(credentialData)
+	return &dto.CredentialRepresentation{Type: data.PasswordCredentialType, SecretData: string(secretJson),
+		CredentialData: string(credentialJson)}, true
+}
+
+// createKeyCloakOtpCredential creates KeyCloak OTP credential with realm OTP policy settings, secret is a plain string if it is
+// a valid text (secret that was imported from KeyCloak), otherwise it is exported base32 encoded
+func createKeyCloakOtpCredential(credential *data.OtpCredential, policy *data.OtpPolicy) dto.CredentialRepresentation {
+	secretData := keyCloakOtpData{Value: credential.Secret}
+	credentialData := keyCloakOtpData{SubType: keyCloakTotpType, Digits: policy.Digits, Period: policy.Period,
+		Algorithm: keyCloakHmacPrefix + policy.Algorithm, SecretEncoding: keyCloakBase32Encoding}
+	if secret, err := otp.DecodeSecret(credential.Secret); err == nil && isPrintableText(secret) {
+		secretData.Value = string(secret)
+		credentialData.SecretEncoding = ""
+	}
+	secretJson, _ := json.Marshal(secretData)
+	credentialJson, _ := json.Marshal(credentialData)
+	return dto.CredentialRepresentation{Type: data.OtpCredentialType, SecretData: string(secretJson), CredentialData: string(credentialJson)}
+}
+
+// formatKeyCloakPasswordPolicy converts realm password policy to KeyCloak password policy string
+func formatKeyCloakPasswordPolicy(policy *data.PasswordPolicy) string {
+	items := make([]string, 0)
+	for _, rule := range []struct {
+		name  string
+		value int
+	}{{"length", policy.MinLength}, {"maxLength", policy.MaxLength}, {"digits", policy.MinDigits}, {"lowerCase", policy.MinLowerCase},
+		{"upperCase", policy.MinUpperCase}, {"specialChars", policy.MinSpecialChars}, {"passwordHistory", policy.History},
+		{"forceExpiredPasswordChange", policy.MaxAge}} {
+		if rule.value > 0 {
+			items = append(items, sf.Format("{0}({1})", rule.name, rule.value))
+		}
+	}
+	if policy.NotUsername {
+		items = append(items, "notUsername(undefined)")
+	}
+	if policy.NotEmail {
+		items = append(items, "notEmail(undefined)")
+	}
+	return strings.Join(items, keyCloakPolicySeparator)
+}
+
+// addSkippedFeature adds feature of object (resource type and name) to skipped features of migration report
+func addSkippedFeature(report *dto.RealmMigrationReport, resource string, name string, feature string) {
+	report.Skipped = append(report.Skipped, dto.SkippedFeatureRepresentation{Resource: resource, Name: name, Feature: feature})
+}
+
+// addSkippedFeatures adds features that object (resource type and name) has to skipped features of migration report
+func addSkippedFeatures(report *dto.RealmMigrationReport, resource string, name string, features []skippedFeature) {
+	for _, feature := range features {
+		if feature.present {
+			addSkippedFeature(report, resource, name, feature.name)
+		}
+	}
+}
+
+// getKeyCloakParameter returns first value of KeyCloak credential additional parameter or defaultValue if it is not set
+func getKeyCloakParameter(parameters map[string][]string, name string, defaultValue string) string {
+	if values := parameters[name]; len(values) > 0 {
+		return values[0]
+	}
+	return defaultValue
+}
+
+func getRealmRoleNames(realm *data.Realm) []string {
+	names := make([]string, len(realm.Roles))
+	for i := range realm.Roles {
+		names[i] = realm.Roles[i].Name
+	}
+	return names
+}
+
+func getIntPtr(value int) *int {
+	return &value
+}
+
+func getDefaultValue(value int, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func isPrintableText(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+	for _, r := range string(value) {
+		if r < ' ' || r == utf8.RuneError {
+			return false
+		}
+	}
+	return true
+}
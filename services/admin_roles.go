@@ -0,0 +1,526 @@
+package services
+
+import (
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const groupPathSeparator = "/"
+
+// GetRoles returns realm roles
+func (service *DataContextAdminService) GetRoles(realmName string) ([]dto.RoleRepresentation, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, check
+	}
+	roles := make([]dto.RoleRepresentation, len(realm.Roles))
+	for i := range realm.Roles {
+		roles[i] = createRoleRepresentation(realm, realm.Roles[i].Name)
+	}
+	return roles, nil
+}
+
+// GetRole returns realm role by name
+func (service *DataContextAdminService) GetRole(realmName string, name string) (*dto.RoleRepresentation, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, check
+	}
+	if realm.FindRole(name) == nil {
+		return nil, &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.RoleNotFoundDesc}
+	}
+	role := createRoleRepresentation(realm, name)
+	return &role, nil
+}
+
+// CreateRole creates realm role
+func (service *DataContextAdminService) CreateRole(realmName string, representation *dto.RoleRepresentation) *data.OperationError {
+	if len(representation.Name) == 0 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.NameRequiredDesc}
+	}
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return check
+	}
+	if realm.FindRole(representation.Name) != nil {
+		return &data.OperationError{Msg: errors.ConflictMsg, Description: sf.Format(errors.RoleExistsDesc, representation.Name)}
+	}
+	realm.Roles = append(realm.Roles, data.Role{Id: uuid.New(), Name: representation.Name, Description: representation.Description})
+	return service.storeRealm(realmName, realm)
+}
+
+// UpdateRole changes role name and description, renamed role is renamed in users and groups
+func (service *DataContextAdminService) UpdateRole(realmName string, name string, representation *dto.RoleRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return check
+	}
+	role := realm.FindRole(name)
+	if role == nil {
+		return &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.RoleNotFoundDesc}
+	}
+	newName := name
+	if len(representation.Name) > 0 && representation.Name != name {
+		if realm.FindRole(representation.Name) != nil {
+			return &data.OperationError{Msg: errors.ConflictMsg, Description: sf.Format(errors.RoleExistsDesc, representation.Name)}
+		}
+		newName = representation.Name
+	}
+	role.Name = newName
+	role.Description = representation.Description
+	if newName == name {
+		return service.storeRealm(realmName, realm)
+	}
+	for i := range realm.Groups {
+		realm.Groups[i].RealmRoles = replaceValue(realm.Groups[i].RealmRoles, name, newName)
+	}
+	if check = service.storeRealm(realmName, realm); check != nil {
+		return check
+	}
+	return service.replaceUsersValue(realmName, data.GetUserRoles, data.SetUserRoles, name, newName)
+}
+
+// DeleteRole removes realm role, role is also removed from users and groups
+func (service *DataContextAdminService) DeleteRole(realmName string, name string) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return check
+	}
+	roles := make([]data.Role, 0, len(realm.Roles))
+	for _, r := range realm.Roles {
+		if r.Name != name {
+			roles = append(roles, r)
+		}
+	}
+	if len(roles) == len(realm.Roles) {
+		return &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.RoleNotFoundDesc}
+	}
+	realm.Roles = roles
+	for i := range realm.Groups {
+		realm.Groups[i].RealmRoles = replaceValue(realm.Groups[i].RealmRoles, name, "")
+	}
+	if check = service.storeRealm(realmName, realm); check != nil {
+		return check
+	}
+	return service.replaceUsersValue(realmName, data.GetUserRoles, data.SetUserRoles, name, "")
+}
+
+// GetRoleUsers returns users that have role directly assigned
+func (service *DataContextAdminService) GetRoleUsers(realmName string, name string) ([]dto.UserRepresentation, *data.OperationError) {
+	if _, check := service.GetRole(realmName, name); check != nil {
+		return nil, check
+	}
+	return service.getUsersWith(realmName, data.GetUserRoles, name)
+}
+
+// GetGroups returns realm groups, non-empty search returns groups which names contain it
+func (service *DataContextAdminService) GetGroups(realmName string, search string) ([]dto.GroupRepresentation, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, check
+	}
+	groups := make([]dto.GroupRepresentation, 0, len(realm.Groups))
+	for i := range realm.Groups {
+		if len(search) == 0 || matchValue(realm.Groups[i].Name, search, false) {
+			groups = append(groups, createGroupRepresentation(&realm.Groups[i]))
+		}
+	}
+	return groups, nil
+}
+
+// GetGroup returns group by identifier
+func (service *DataContextAdminService) GetGroup(realmName string, id uuid.UUID) (*dto.GroupRepresentation, *data.OperationError) {
+	_, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	representation := createGroupRepresentation(group)
+	return &representation, nil
+}
+
+// CreateGroup creates group with realm roles and attributes of representation, returns group identifier
+func (service *DataContextAdminService) CreateGroup(realmName string, representation *dto.GroupRepresentation) (uuid.UUID, *data.OperationError) {
+	if len(representation.Name) == 0 {
+		return uuid.Nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.NameRequiredDesc}
+	}
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return uuid.Nil, check
+	}
+	if realm.FindGroupByName(representation.Name) != nil {
+		return uuid.Nil, &data.OperationError{Msg: errors.ConflictMsg, Description: sf.Format(errors.GroupExistsDesc, representation.Name)}
+	}
+	for _, role := range representation.RealmRoles {
+		if realm.FindRole(role) == nil {
+			return uuid.Nil, &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.RoleNotFoundDesc}
+		}
+	}
+	group := data.Group{Id: uuid.New(), Name: representation.Name, RealmRoles: representation.RealmRoles, Attributes: representation.Attributes}
+	if id, err := uuid.Parse(representation.Id); err == nil && realm.FindGroup(id) == nil {
+		group.Id = id
+	}
+	realm.Groups = append(realm.Groups, group)
+	if check = service.storeRealm(realmName, realm); check != nil {
+		return uuid.Nil, check
+	}
+	return group.Id, nil
+}
+
+// UpdateGroup changes group name and attributes, renamed group is renamed in user memberships
+func (service *DataContextAdminService) UpdateGroup(realmName string, id uuid.UUID, representation *dto.GroupRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return check
+	}
+	name := group.Name
+	if len(representation.Name) > 0 && representation.Name != name {
+		if realm.FindGroupByName(representation.Name) != nil {
+			return &data.OperationError{Msg: errors.ConflictMsg, Description: sf.Format(errors.GroupExistsDesc, representation.Name)}
+		}
+		group.Name = representation.Name
+	}
+	if representation.Attributes != nil {
+		group.Attributes = representation.Attributes
+	}
+	newName := group.Name
+	if check = service.storeRealm(realmName, realm); check != nil || newName == name {
+		return check
+	}
+	return service.replaceUsersValue(realmName, data.GetUserGroups, data.SetUserGroups, name, newName)
+}
+
+// DeleteGroup removes group, members leave removed group
+func (service *DataContextAdminService) DeleteGroup(realmName string, id uuid.UUID) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return check
+	}
+	name := group.Name
+	groups := make([]data.Group, 0, len(realm.Groups))
+	for _, g := range realm.Groups {
+		if g.Id != id {
+			groups = append(groups, g)
+		}
+	}
+	realm.Groups = groups
+	if check = service.storeRealm(realmName, realm); check != nil {
+		return check
+	}
+	return service.replaceUsersValue(realmName, data.GetUserGroups, data.SetUserGroups, name, "")
+}
+
+// GetGroupMembers returns users that are members of group
+func (service *DataContextAdminService) GetGroupMembers(realmName string, id uuid.UUID) ([]dto.UserRepresentation, *data.OperationError) {
+	_, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	return service.getUsersWith(realmName, data.GetUserGroups, group.Name)
+}
+
+// GetGroupRealmRoles returns realm roles of group
+func (service *DataContextAdminService) GetGroupRealmRoles(realmName string, id uuid.UUID) ([]dto.RoleRepresentation, *data.OperationError) {
+	realm, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	return createRoleRepresentations(realm, group.RealmRoles), nil
+}
+
+// AddGroupRealmRoles assigns realm roles to group
+func (service *DataContextAdminService) AddGroupRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return check
+	}
+	names, check := getRoleNames(realm, roles)
+	if check != nil {
+		return check
+	}
+	group.RealmRoles = addValues(group.RealmRoles, names)
+	return service.storeRealm(realmName, realm)
+}
+
+// RemoveGroupRealmRoles removes realm roles of group
+func (service *DataContextAdminService) RemoveGroupRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, group, check := service.getGroup(realmName, id)
+	if check != nil {
+		return check
+	}
+	names, check := getRoleNames(realm, roles)
+	if check != nil {
+		return check
+	}
+	for _, name := range names {
+		group.RealmRoles = replaceValue(group.RealmRoles, name, "")
+	}
+	return service.storeRealm(realmName, realm)
+}
+
+// GetUserGroups returns groups that user is member of
+func (service *DataContextAdminService) GetUserGroups(realmName string, id uuid.UUID) ([]dto.GroupRepresentation, *data.OperationError) {
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	groups := make([]dto.GroupRepresentation, 0)
+	for _, name := range data.GetUserGroups(user) {
+		if group := realm.FindGroupByName(name); group != nil {
+			groups = append(groups, createGroupRepresentation(group))
+		}
+	}
+	return groups, nil
+}
+
+// JoinGroup makes user a member of group
+func (service *DataContextAdminService) JoinGroup(realmName string, id uuid.UUID, groupId uuid.UUID) *data.OperationError {
+	return service.changeUserGroups(realmName, id, groupId, func(groups []string, name string) []string {
+		return addValues(groups, []string{name})
+	})
+}
+
+// LeaveGroup removes user from group
+func (service *DataContextAdminService) LeaveGroup(realmName string, id uuid.UUID, groupId uuid.UUID) *data.OperationError {
+	return service.changeUserGroups(realmName, id, groupId, func(groups []string, name string) []string {
+		return replaceValue(groups, name, "")
+	})
+}
+
+// GetUserRoleMappings returns realm roles that are directly assigned to user
+func (service *DataContextAdminService) GetUserRoleMappings(realmName string, id uuid.UUID) (*dto.MappingsRepresentation, *data.OperationError) {
+	roles, check := service.GetUserRealmRoles(realmName, id, false)
+	if check != nil {
+		return nil, check
+	}
+	return &dto.MappingsRepresentation{RealmMappings: roles}, nil
+}
+
+// GetUserRealmRoles returns realm roles of user, effective roles also include roles of user groups
+func (service *DataContextAdminService) GetUserRealmRoles(realmName string, id uuid.UUID, effective bool) ([]dto.RoleRepresentation, *data.OperationError) {
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	if effective {
+		return createRoleRepresentations(realm, realm.GetEffectiveRoles(user)), nil
+	}
+	return createRoleRepresentations(realm, data.GetUserRoles(user)), nil
+}
+
+// AddUserRealmRoles assigns realm roles to user
+func (service *DataContextAdminService) AddUserRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError {
+	return service.changeUserRoles(realmName, id, roles, addValues)
+}
+
+// RemoveUserRealmRoles removes realm roles that are directly assigned to user
+func (service *DataContextAdminService) RemoveUserRealmRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation) *data.OperationError {
+	return service.changeUserRoles(realmName, id, roles, func(current []string, removed []string) []string {
+		for _, name := range removed {
+			current = replaceValue(current, name, "")
+		}
+		return current
+	})
+}
+
+// getGroup reads realm and realm group by identifier, group points to realm Groups item
+func (service *DataContextAdminService) getGroup(realmName string, id uuid.UUID) (*data.Realm, *data.Group, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, nil, check
+	}
+	group := realm.FindGroup(id)
+	if group == nil {
+		return nil, nil, &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.GroupNotFoundDesc}
+	}
+	return realm, group, nil
+}
+
+// storeRealm stores realm with changed roles or groups
+func (service *DataContextAdminService) storeRealm(realmName string, realm *data.Realm) *data.OperationError {
+	if err := (*service.dataProvider).UpdateRealm(realmName, *realm); err != nil {
+		return service.getOperationError(err, "Realm update", errors.RealmNotFoundDesc, errors.RealmExistsDesc)
+	}
+	service.logger.Info(sf.Format("Admin: roles and groups of realm \"{0}\" were updated", realmName))
+	return nil
+}
+
+// getUsersWith returns users whose roles or groups (get function) contain value
+func (service *DataContextAdminService) getUsersWith(realmName string, get func(data.User) []string,
+	value string) ([]dto.UserRepresentation, *data.OperationError) {
+	users, check := service.getUsers(realmName)
+	if check != nil {
+		return nil, check
+	}
+	result := make([]dto.UserRepresentation, 0)
+	for _, user := range users {
+		if containsValue(get(user), value) {
+			result = append(result, createUserRepresentation(user))
+		}
+	}
+	return result, nil
+}
+
+// replaceUsersValue renames (or removes if newValue is empty) role or group of all realm users that have it
+/* Parameters:
+ *    - realmName - name of a realm
+ *    - get - function that returns user roles or groups
+ *    - set - function that stores user roles or groups
+ *    - oldValue - renamed or removed role (group) name
+ *    - newValue - new role (group) name, empty value removes role (group)
+ * Returns: nil if all users were updated
+ */
+func (service *DataContextAdminService) replaceUsersValue(realmName string, get func(data.User) []string,
+	set func(data.User, []string) error, oldValue string, newValue string) *data.OperationError {
+	users, check := service.getUsers(realmName)
+	if check != nil {
+		return check
+	}
+	for _, user := range users {
+		values := get(user)
+		if !containsValue(values, oldValue) {
+			continue
+		}
+		if err := set(user, replaceValue(values, oldValue, newValue)); err != nil {
+			service.logger.Error(sf.Format("Admin: user \"{0}\" was not changed: {1}", user.GetUsername(), err.Error()))
+			return &data.OperationError{Msg: errors.OtherAppError}
+		}
+		if check = service.storeUser(realmName, user.GetUsername(), user); check != nil {
+			return check
+		}
+	}
+	return nil
+}
+
+func (service *DataContextAdminService) changeUserGroups(realmName string, id uuid.UUID, groupId uuid.UUID,
+	change func([]string, string) []string) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	group := realm.FindGroup(groupId)
+	if group == nil {
+		return &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.GroupNotFoundDesc}
+	}
+	if err := data.SetUserGroups(user, change(data.GetUserGroups(user), group.Name)); err != nil {
+		service.logger.Error(sf.Format("Admin: groups of user \"{0}\" were not changed: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	return service.storeUser(realmName, user.GetUsername(), user)
+}
+
+func (service *DataContextAdminService) changeUserRoles(realmName string, id uuid.UUID, roles []dto.RoleRepresentation,
+	change func([]string, []string) []string) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	names, check := getRoleNames(realm, roles)
+	if check != nil {
+		return check
+	}
+	if err := data.SetUserRoles(user, change(data.GetUserRoles(user), names)); err != nil {
+		service.logger.Error(sf.Format("Admin: roles of user \"{0}\" were not changed: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	return service.storeUser(realmName, user.GetUsername(), user)
+}
+
+// getRoleNames returns names of realm roles of representations, role is found by name or (if name is empty) by identifier
+func getRoleNames(realm *data.Realm, roles []dto.RoleRepresentation) ([]string, *data.OperationError) {
+	names := make([]string, 0, len(roles))
+	for _, r := range roles {
+		name := r.Name
+		if len(name) == 0 {
+			for _, role := range realm.Roles {
+				if role.Id.String() == r.Id {
+					name = role.Name
+				}
+			}
+		}
+		if len(name) == 0 || realm.FindRole(name) == nil {
+			return nil, &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.RoleNotFoundDesc}
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// createRoleRepresentation creates representation of realm role, role that is assigned but not defined in realm has only name
+func createRoleRepresentation(realm *data.Realm, name string) dto.RoleRepresentation {
+	representation := dto.RoleRepresentation{Name: name, ContainerId: realm.Name}
+	if role := realm.FindRole(name); role != nil {
+		representation.Id = role.Id.String()
+		representation.Description = role.Description
+	}
+	return representation
+}
+
+func createRoleRepresentations(realm *data.Realm, names []string) []dto.RoleRepresentation {
+	roles := make([]dto.RoleRepresentation, len(names))
+	for i, name := range names {
+		roles[i] = createRoleRepresentation(realm, name)
+	}
+	return roles
+}
+
+func createGroupRepresentation(group *data.Group) dto.GroupRepresentation {
+	return dto.GroupRepresentation{Id: group.Id.String(), Name: group.Name, Path: groupPathSeparator + group.Name,
+		RealmRoles: group.RealmRoles, Attributes: group.Attributes, SubGroups: []dto.GroupRepresentation{}}
+}
+
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// addValues appends values that are not in current yet
+func addValues(current []string, values []string) []string {
+	result := append([]string{}, current...)
+	for _, v := range values {
+		if !containsValue(result, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// replaceValue replaces oldValue with newValue, empty newValue removes oldValue
+func replaceValue(values []string, oldValue string, newValue string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		switch {
+		case v != oldValue:
+			result = append(result, v)
+		case len(newValue) > 0 && !containsValue(values, newValue):
+			result = append(result, newValue)
+		}
+	}
+	return result
+}
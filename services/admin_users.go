@@ -0,0 +1,502 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const millisecondsInSecond = 1000
+
+// GetUsers returns page of users that match query sorted by username
+func (service *DataContextAdminService) GetUsers(realmName string, query *dto.UsersQuery) ([]dto.UserRepresentation, *data.OperationError) {
+	users, check := service.findUsers(realmName, query)
+	if check != nil {
+		return nil, check
+	}
+	if query.First > 0 {
+		if query.First >= len(users) {
+			return []dto.UserRepresentation{}, nil
+		}
+		users = users[query.First:]
+	}
+	if query.Max > 0 && query.Max < len(users) {
+		users = users[:query.Max]
+	}
+	return users, nil
+}
+
+// CountUsers returns number of users that match query (paging parameters are ignored)
+func (service *DataContextAdminService) CountUsers(realmName string, query *dto.UsersQuery) (int, *data.OperationError) {
+	users, check := service.findUsers(realmName, query)
+	if check != nil {
+		return 0, check
+	}
+	return len(users), nil
+}
+
+// GetUser returns user by identifier
+func (service *DataContextAdminService) GetUser(realmName string, id uuid.UUID) (*dto.UserRepresentation, *data.OperationError) {
+	_, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	representation := createUserRepresentation(user)
+	return &representation, nil
+}
+
+// CreateUser creates user with password credentials, realm roles and groups of representation
+/* User gets generated identifier (info.sub), password is checked against realm password policy, temporary password adds
+ * UPDATE_PASSWORD required action. Realm roles and groups must be defined in realm
+ * Parameters:
+ *    - realmName - name of a realm
+ *    - representation - username (required), attributes, credentials (only password), realm roles and groups
+ * Returns: identifier of created user or error (conflict if realm already has user with same username)
+ */
+func (service *DataContextAdminService) CreateUser(realmName string, representation *dto.UserRepresentation) (uuid.UUID, *data.OperationError) {
+	if len(strings.TrimSpace(representation.Username)) == 0 {
+		return uuid.Nil, &data.OperationError{Msg: errors.InvalidUserProfileMsg,
+			Description: sf.Format(errors.AttributeRequiredDesc, data.UsernameAttribute)}
+	}
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return uuid.Nil, check
+	}
+	if check = validateUserRepresentation(realm, representation); check != nil {
+		return uuid.Nil, check
+	}
+	id := uuid.New()
+	user := data.CreateUser(map[string]interface{}{"info": map[string]interface{}{data.SubAttribute: id.String()},
+		"credentials": map[string]interface{}{}})
+	if err := applyUserRepresentation(realm, user, representation); err != nil {
+		service.logger.Error(sf.Format("Admin: user \"{0}\" was not created: {1}", representation.Username, err.Error()))
+		return uuid.Nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	for i := range representation.Credentials {
+		if check = service.setPassword(realm, user, &representation.Credentials[i]); check != nil {
+			return uuid.Nil, check
+		}
+	}
+	if err := (*service.dataProvider).CreateUser(realmName, user); err != nil {
+		return uuid.Nil, service.getOperationError(err, "User create", errors.RealmNotFoundDesc, errors.UserExistsDesc)
+	}
+	service.logger.Info(sf.Format("Admin: user \"{0}\" was created in realm \"{1}\"", user.GetUsername(), realmName))
+	return id, nil
+}
+
+// UpdateUser changes user fields that representation contains, attributes (if present) replace all user attributes
+func (service *DataContextAdminService) UpdateUser(realmName string, id uuid.UUID, representation *dto.UserRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	if check = validateUserRepresentation(realm, representation); check != nil {
+		return check
+	}
+	userName := user.GetUsername()
+	if err := applyUserRepresentation(realm, user, representation); err != nil {
+		service.logger.Error(sf.Format("Admin: user \"{0}\" was not changed: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	return service.storeUser(realmName, userName, user)
+}
+
+// DeleteUser removes user
+func (service *DataContextAdminService) DeleteUser(realmName string, id uuid.UUID) *data.OperationError {
+	_, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	if err := (*service.dataProvider).DeleteUser(realmName, user.GetUsername()); err != nil {
+		return service.getOperationError(err, "User delete", errors.UserNotFoundDesc, "")
+	}
+	service.logger.Info(sf.Format("Admin: user \"{0}\" was removed from realm \"{1}\"", user.GetUsername(), realmName))
+	return nil
+}
+
+// ResetPassword sets user password, temporary password requires user to change it on next login
+func (service *DataContextAdminService) ResetPassword(realmName string, id uuid.UUID, credential *dto.CredentialRepresentation) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	if check = service.setPassword(realm, user, credential); check != nil {
+		return check
+	}
+	return service.storeUser(realmName, user.GetUsername(), user)
+}
+
+// GetUserCredentials returns user credentials without secret data, password and OTP credential identifiers are credential types
+func (service *DataContextAdminService) GetUserCredentials(realmName string, id uuid.UUID) ([]dto.CredentialRepresentation, *data.OperationError) {
+	_, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return nil, check
+	}
+	accountCredentials := GetAccountCredentials(user)
+	credentials := make([]dto.CredentialRepresentation, len(accountCredentials))
+	for i, c := range accountCredentials {
+		credentials[i] = dto.CredentialRepresentation{Id: c.Id, Type: c.Type, UserLabel: c.Label, CreatedDate: c.Created * millisecondsInSecond}
+		if len(c.Id) == 0 {
+			credentials[i].Id = c.Type
+		}
+	}
+	return credentials, nil
+}
+
+// DeleteUserCredential removes user OTP (credential id is "otp") or passkey, password can't be removed
+func (service *DataContextAdminService) DeleteUserCredential(realmName string, id uuid.UUID, credentialId string) *data.OperationError {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	_, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	if credentialId == data.PasswordCredentialType {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedCredentialDesc}
+	}
+	var err error
+	if credentialId == data.OtpCredentialType && user.GetOtpCredential() != nil {
+		err = user.SetOtpCredential(nil)
+	} else {
+		passkeys := user.GetWebAuthnCredentials()
+		remaining := make([]data.WebAuthnCredential, 0, len(passkeys))
+		for _, p := range passkeys {
+			if p.Id != credentialId {
+				remaining = append(remaining, p)
+			}
+		}
+		if len(remaining) == len(passkeys) {
+			return &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.CredentialNotFoundDesc}
+		}
+		err = user.SetWebAuthnCredentials(remaining)
+	}
+	if err != nil {
+		service.logger.Error(sf.Format("Admin: credential of user \"{0}\" was not removed: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	return service.storeUser(realmName, user.GetUsername(), user)
+}
+
+// SendExecuteActionsEmail sends email with link that requires user to execute actions, lifespan in seconds (0 - default)
+func (service *DataContextAdminService) SendExecuteActionsEmail(realmName string, id uuid.UUID, actions []string, lifespan int) *data.OperationError {
+	if service.emailActions == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc}
+	}
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	return (*service.emailActions).SendExecuteActionsEmail(realm, user, actions, lifespan)
+}
+
+// SendVerifyEmail sends email verification link to user
+func (service *DataContextAdminService) SendVerifyEmail(realmName string, id uuid.UUID) *data.OperationError {
+	if service.emailActions == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc}
+	}
+	realm, user, check := service.getUser(realmName, id)
+	if check != nil {
+		return check
+	}
+	return (*service.emailActions).SendVerifyEmail(realm, user)
+}
+
+// getUser reads realm and realm user by identifier
+func (service *DataContextAdminService) getUser(realmName string, id uuid.UUID) (*data.Realm, data.User, *data.OperationError) {
+	realm, check := service.getRealm(realmName)
+	if check != nil {
+		return nil, nil, check
+	}
+	user, err := (*service.dataProvider).GetUserById(realmName, id)
+	if err != nil {
+		return nil, nil, service.getOperationError(err, "User read", errors.UserNotFoundDesc, "")
+	}
+	return realm, user, nil
+}
+
+// getUsers reads all realm users sorted by username
+func (service *DataContextAdminService) getUsers(realmName string) ([]data.User, *data.OperationError) {
+	if _, check := service.getRealm(realmName); check != nil {
+		return nil, check
+	}
+	users, err := (*service.dataProvider).GetUsers(realmName)
+	if err != nil {
+		if err == errors.ErrZeroLength {
+			return []data.User{}, nil
+		}
+		return nil, service.getOperationError(err, "Users read", errors.RealmNotFoundDesc, "")
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].GetUsername() < users[j].GetUsername()
+	})
+	return users, nil
+}
+
+// findUsers returns all users that match query (without paging)
+func (service *DataContextAdminService) findUsers(realmName string, query *dto.UsersQuery) ([]dto.UserRepresentation, *data.OperationError) {
+	users, check := service.getUsers(realmName)
+	if check != nil {
+		return nil, check
+	}
+	result := make([]dto.UserRepresentation, 0)
+	for _, user := range users {
+		representation := createUserRepresentation(user)
+		if matchUsersQuery(&representation, query) {
+			result = append(result, representation)
+		}
+	}
+	return result, nil
+}
+
+// storeUser stores changed user, userName is a username before change
+func (service *DataContextAdminService) storeUser(realmName string, userName string, user data.User) *data.OperationError {
+	if err := (*service.dataProvider).UpdateUser(realmName, userName, user); err != nil {
+		return service.getOperationError(err, "User update", errors.UserNotFoundDesc, errors.UserExistsDesc)
+	}
+	service.logger.Info(sf.Format("Admin: user \"{0}\" was updated in realm \"{1}\"", user.GetUsername(), realmName))
+	return nil
+}
+
+// setPassword sets password of credential (only password credentials are supported), user must be stored by caller
+func (service *DataContextAdminService) setPassword(realm *data.Realm, user data.User, credential *dto.CredentialRepresentation) *data.OperationError {
+	if len(credential.Type) > 0 && credential.Type != data.PasswordCredentialType {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedCredentialDesc}
+	}
+	if len(credential.Value) == 0 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.PasswordRequiredDesc}
+	}
+	if check := CheckPasswordPolicy(realm.PasswordPolicy, user, credential.Value); check != nil {
+		return check
+	}
+	err := user.SetPassword(credential.Value)
+	if err == nil {
+		if credential.Temporary != nil && *credential.Temporary {
+			err = data.AddRequiredAction(user, data.UpdatePasswordAction)
+		} else {
+			_, err = data.RemoveRequiredAction(user, data.UpdatePasswordAction)
+		}
+	}
+	if err != nil {
+		service.logger.Error(sf.Format("Admin: password of user \"{0}\" was not set: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	return nil
+}
+
+// validateUserRepresentation checks that representation attributes could be set by admin and that realm roles and groups exist
+func validateUserRepresentation(realm *data.Realm, representation *dto.UserRepresentation) *data.OperationError {
+	for name := range representation.Attributes {
+		if isUserRepresentationAttribute(name) {
+			return &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: sf.Format(errors.AttributeNotAllowedDesc, name)}
+		}
+	}
+	for _, role := range representation.RealmRoles {
+		if realm.FindRole(role) == nil {
+			return &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.RoleNotFoundDesc}
+		}
+	}
+	for _, group := range representation.Groups {
+		if realm.FindGroupByName(strings.TrimPrefix(group, "/")) == nil {
+			return &data.OperationError{Msg: errors.NotFoundMsg, Description: errors.GroupNotFoundDesc}
+		}
+	}
+	return nil
+}
+
+// applyUserRepresentation sets user fields that representation contains (representation must be validated), email change
+// resets email verification
+func applyUserRepresentation(realm *data.Realm, user data.User, representation *dto.UserRepresentation) error {
+	if len(representation.Username) > 0 {
+		if err := user.SetInfoValue(data.UsernameAttribute, strings.TrimSpace(representation.Username)); err != nil {
+			return err
+		}
+	}
+	if representation.Email != nil && *representation.Email != user.GetEmail() {
+		if err := setInfoString(user, data.EmailAttribute, *representation.Email); err != nil {
+			return err
+		}
+		if err := user.SetEmailVerified(false); err != nil {
+			return err
+		}
+	}
+	for name, value := range map[string]*string{data.GivenNameAttribute: representation.FirstName,
+		data.FamilyNameAttribute: representation.LastName} {
+		if value != nil {
+			if err := setInfoString(user, name, *value); err != nil {
+				return err
+			}
+		}
+	}
+	if representation.EmailVerified != nil {
+		if err := user.SetEmailVerified(*representation.EmailVerified); err != nil {
+			return err
+		}
+	}
+	if representation.Enabled != nil {
+		if err := user.SetEnabled(*representation.Enabled); err != nil {
+			return err
+		}
+	}
+	if representation.Attributes != nil {
+		info, _ := user.GetUserInfo().(map[string]interface{})
+		for name := range info {
+			if _, ok := representation.Attributes[name]; !ok && !isUserRepresentationAttribute(name) {
+				if err := user.SetInfoValue(name, nil); err != nil {
+					return err
+				}
+			}
+		}
+		profile := realm.GetUserProfile()
+		for name, values := range representation.Attributes {
+			if err := user.SetInfoValue(name, parseAttributeValues(profile, name, values)); err != nil {
+				return err
+			}
+		}
+	}
+	if representation.RequiredActions != nil {
+		if err := user.SetRequiredActions(representation.RequiredActions); err != nil {
+			return err
+		}
+	}
+	if representation.RealmRoles != nil {
+		if err := data.SetUserRoles(user, representation.RealmRoles); err != nil {
+			return err
+		}
+	}
+	if representation.Groups != nil {
+		groups := make([]string, len(representation.Groups))
+		for i, g := range representation.Groups {
+			groups[i] = strings.TrimPrefix(g, "/")
+		}
+		if err := data.SetUserGroups(user, groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createUserRepresentation(user data.User) dto.UserRepresentation {
+	info, _ := user.GetUserInfo().(map[string]interface{})
+	enabled := user.IsEnabled()
+	emailVerified, _ := info[data.EmailVerifiedAttribute].(bool)
+	representation := dto.UserRepresentation{Id: user.GetId().String(), Username: user.GetUsername(), Enabled: &enabled,
+		EmailVerified: &emailVerified, RequiredActions: user.GetRequiredActions()}
+	representation.Email = getInfoString(info, data.EmailAttribute)
+	representation.FirstName = getInfoString(info, data.GivenNameAttribute)
+	representation.LastName = getInfoString(info, data.FamilyNameAttribute)
+	attributes := map[string][]string{}
+	for name, value := range info {
+		if !isUserRepresentationAttribute(name) {
+			attributes[name] = formatAttributeValues(value)
+		}
+	}
+	if len(attributes) > 0 {
+		representation.Attributes = attributes
+	}
+	return representation
+}
+
+// isUserRepresentationAttribute checks whether user info attribute is a UserRepresentation field (or it is set only by server)
+// and therefore it is not a part of UserRepresentation Attributes
+func isUserRepresentationAttribute(name string) bool {
+	switch name {
+	case data.SubAttribute, data.UsernameAttribute, data.EmailAttribute, data.EmailVerifiedAttribute, data.GivenNameAttribute,
+		data.FamilyNameAttribute, data.RolesAttribute, data.GroupsAttribute:
+		return true
+	}
+	return false
+}
+
+// parseAttributeValues converts KeyCloak attribute values to user info value: array attribute (or attribute with several
+// values) is stored as array, other attributes are converted to profile attribute type, empty values remove attribute
+func parseAttributeValues(profile *data.UserProfile, name string, values []string) interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	attribute := profile.FindAttribute(name)
+	if len(values) > 1 || (attribute != nil && attribute.Type == data.ArrayAttributeType) {
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		return items
+	}
+	if attribute != nil {
+		return attribute.ParseValue(values[0])
+	}
+	return values[0]
+}
+
+func formatAttributeValues(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return []string{data.FormatAttributeValue(value)}
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = data.FormatAttributeValue(item)
+	}
+	return values
+}
+
+func getInfoString(info map[string]interface{}, name string) *string {
+	value, ok := info[name].(string)
+	if !ok || len(value) == 0 {
+		return nil
+	}
+	return &value
+}
+
+// setInfoString sets user info string attribute, empty value removes attribute
+func setInfoString(user data.User, name string, value string) error {
+	if value = strings.TrimSpace(value); len(value) == 0 {
+		return user.SetInfoValue(name, nil)
+	}
+	return user.SetInfoValue(name, value)
+}
+
+// matchUsersQuery checks whether user matches all query filters (see dto.UsersQuery)
+func matchUsersQuery(representation *dto.UserRepresentation, query *dto.UsersQuery) bool {
+	email := getStringValue(representation.Email)
+	firstName := getStringValue(representation.FirstName)
+	lastName := getStringValue(representation.LastName)
+	if search := strings.Trim(query.Search, "*"); len(search) > 0 {
+		if !matchValue(representation.Username, search, query.Exact) && !matchValue(email, search, query.Exact) &&
+			!matchValue(firstName, search, query.Exact) && !matchValue(lastName, search, query.Exact) {
+			return false
+		}
+	}
+	filters := [][]string{{representation.Username, query.Username}, {email, query.Email}, {firstName, query.FirstName},
+		{lastName, query.LastName}}
+	for _, f := range filters {
+		if len(f[1]) > 0 && !matchValue(f[0], f[1], query.Exact) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchValue(value string, pattern string, exact bool) bool {
+	if exact {
+		return strings.EqualFold(value, pattern)
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(pattern))
+}
+
+func getStringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
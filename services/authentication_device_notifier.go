@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// AuthenticationDeviceNotifier is an interface that delivers CIBA authentication requests to user authentication device
+/* Ferrum doesn't communicate with user devices itself, notifier passes request to external system (push gateway, messenger bot,
+ * call-center application, ...) that should ask user and pass decision to callback endpoint using notification CallbackToken
+ */
+type AuthenticationDeviceNotifier interface {
+	// Notify delivers authentication request to user device, returns error if request wasn't delivered
+	Notify(notification *dto.AuthenticationDeviceNotification) error
+}
+
+// CreateAuthenticationDeviceNotifier creates notifier instance according to config.CibaConfig Notifier type
+/* Parameters:
+ *    - cfg - CIBA config section
+ *    - logger - logger service
+ * Returns: instance of AuthenticationDeviceNotifier or error if notifier type is not supported
+ */
+func CreateAuthenticationDeviceNotifier(cfg *config.CibaConfig, logger *logging.AppLogger) (AuthenticationDeviceNotifier, error) {
+	switch cfg.Notifier {
+	case config.HttpDeviceNotifier:
+		return AuthenticationDeviceNotifier(CreateHttpDeviceNotifier(cfg.Destination, logger)), nil
+	case config.FileDeviceNotifier:
+		return AuthenticationDeviceNotifier(CreateFileDeviceNotifier(cfg.Destination, logger)), nil
+	default:
+		return nil, errors.New(sf.Format("authentication device notifier \"{0}\" is not supported", cfg.Notifier))
+	}
+}
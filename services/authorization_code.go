@@ -0,0 +1,150 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/utils/random"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// identitySize is a number of random bytes in identity cookie value
+const identitySize = 32
+
+// StartSsoSession starts (or updates) user session after browser login and generates identity cookie value for it
+/* Session is the same as token endpoint uses, therefore tokens issued after browser login are related to it. Every browser
+ * login has own identity (login from other browser doesn't end SSO in the first one), identity is valid for realm
+ * SsoSessionLifespan, AuthTime is set to current time (user has just entered credentials). Expired browser logins are removed
+ * Parameters:
+ *    - realm - realm that user logged in
+ *    - userId - user identifier
+ * Returns: identity cookie value or error if random value wasn't generated
+ */
+func (service *TokenBasedSecurityService) StartSsoSession(realm *data.Realm, userId uuid.UUID) (string, error) {
+	identity, err := random.GenerateToken(identitySize)
+	if err != nil {
+		return "", err
+	}
+	service.sessionsMutex.Lock()
+	defer service.sessionsMutex.Unlock()
+	session := service.startOrUpdateSession(realm.Name, userId, realm.TokenExpiration, realm.RefreshTokenExpiration)
+	current := time.Now()
+	browserLogins := make([]data.BrowserLogin, 0, len(session.BrowserLogins)+1)
+	for _, l := range session.BrowserLogins {
+		if l.Expired.After(current) {
+			browserLogins = append(browserLogins, l)
+		}
+	}
+	session.AuthTime = current
+	session.BrowserLogins = append(browserLogins, data.BrowserLogin{
+		Identity: identity, AuthTime: current,
+		Expired: current.Add(time.Second * time.Duration(realm.GetSsoSessionLifespan())),
+	})
+	return identity, nil
+}
+
+// GetSessionByIdentity returns user session by identity cookie value
+/* Parameters:
+ *    - realm - name of a realm
+ *    - identity - identity cookie value
+ * Returns: copy of session with AuthTime of browser login that set identity or nil if session not found or browser login expired
+ */
+func (service *TokenBasedSecurityService) GetSessionByIdentity(realm string, identity string) *data.UserSession {
+	if len(identity) == 0 {
+		return nil
+	}
+	service.sessionsMutex.RLock()
+	defer service.sessionsMutex.RUnlock()
+	realmSessions := service.UserSessions[realm]
+	for i := range realmSessions {
+		for _, l := range realmSessions[i].BrowserLogins {
+			if subtle.ConstantTimeCompare([]byte(l.Identity), []byte(identity)) == 1 {
+				if l.Expired.Before(time.Now()) {
+					return nil
+				}
+				session := copySession(&realmSessions[i])
+				session.AuthTime = l.AuthTime
+				return session
+			}
+		}
+	}
+	return nil
+}
+
+// StoreAuthorizationCode saves code data and generates code value, expired codes are removed on every store call
+/* Parameters:
+ *    - realm - name of a realm
+ *    - code - data that is required for code exchange (client, redirect_uri, user, PKCE challenge)
+ *    - lifetime - code lifetime in seconds
+ * Returns: code value that authorization endpoint passes to client redirect_uri
+ */
+func (service *TokenBasedSecurityService) StoreAuthorizationCode(realm string, code *data.AuthorizationCode, lifetime int) string {
+	service.codesMutex.Lock()
+	defer service.codesMutex.Unlock()
+	realmCodes, ok := service.authorizationCodes[realm]
+	if !ok {
+		realmCodes = map[string]data.AuthorizationCode{}
+		service.authorizationCodes[realm] = realmCodes
+	}
+	current := time.Now()
+	for c, stored := range realmCodes {
+		if stored.Expired.Before(current) {
+			delete(realmCodes, c)
+		}
+	}
+	value := uuid.New().String()
+	storedCode := *code
+	storedCode.Expired = current.Add(time.Second * time.Duration(lifetime))
+	realmCodes[value] = storedCode
+	return value
+}
+
+// ExchangeAuthorizationCode returns (and removes, code is a one-time value) code data if code could be exchanged for tokens
+/* Code must be issued to the same client and not expired, redirectUri must be the same as in authorization request and
+ * codeVerifier must match PKCE code challenge (RFC 7636 section 4.6) if authorization request had it
+ * Parameters:
+ *    - realm - name of a realm
+ *    - clientId - authenticated client that exchanges code
+ *    - code - code value
+ *    - redirectUri - redirect_uri parameter of token request
+ *    - codeVerifier - code_verifier parameter of token request
+ * Returns: code data or error (invalid grant)
+ */
+func (service *TokenBasedSecurityService) ExchangeAuthorizationCode(realm string, clientId string, code string, redirectUri string,
+	codeVerifier string) (*data.AuthorizationCode, *data.OperationError) {
+	service.codesMutex.Lock()
+	storedCode, ok := service.authorizationCodes[realm][code]
+	if ok {
+		delete(service.authorizationCodes[realm], code)
+	}
+	service.codesMutex.Unlock()
+	if !ok || storedCode.Expired.Before(time.Now()) || storedCode.ClientId != clientId {
+		service.logger.Debug(sf.Format("Authorization code exchange: code is invalid, expired or belongs to another client than \"{0}\"", clientId))
+		return nil, &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidAuthorizationCodeDesc}
+	}
+	if storedCode.RedirectUri != redirectUri {
+		return nil, &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.RedirectUriMismatchDesc}
+	}
+	if !checkCodeVerifier(&storedCode, codeVerifier) {
+		return nil, &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.CodeVerifierMismatchDesc}
+	}
+	return &storedCode, nil
+}
+
+// checkCodeVerifier checks PKCE code_verifier, code without code challenge must be exchanged without verifier
+func checkCodeVerifier(code *data.AuthorizationCode, codeVerifier string) bool {
+	if len(code.CodeChallenge) == 0 {
+		return len(codeVerifier) == 0
+	}
+	expected := codeVerifier
+	if code.CodeChallengeMethod == data.S256CodeChallengeMethod {
+		hash := sha256.Sum256([]byte(codeVerifier))
+		expected = base64.RawURLEncoding.EncodeToString(hash[:])
+	}
+	return len(codeVerifier) > 0 && subtle.ConstantTimeCompare([]byte(expected), []byte(code.CodeChallenge)) == 1
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"github.com/wissance/Ferrum/data"
+)
+
+// BackChannelAuthenticationService is an interface that manages Client-Initiated Backchannel Authentication (CIBA) requests lifecycle
+/* CIBA flow in Ferrum:
+ * 1. Client sends authentication request to backchannel authentication endpoint, service stores request and notifies user device
+ *    via AuthenticationDeviceNotifier
+ * 2. Authentication device passes user decision to callback endpoint (CompleteAuthentication), in ping mode service notifies client
+ * 3. Client polls token endpoint with auth_req_id (PollAuthentication) and receives tokens if user approved request
+ */
+type BackChannelAuthenticationService interface {
+	// StartAuthentication assigns identifiers to request, stores it and notifies user authentication device
+	StartAuthentication(realm string, request *data.BackChannelAuthenticationRequest, requestedExpiry int, callbackUri string) error
+	// CompleteAuthentication saves user decision passed by authentication device, in ping mode it also notifies client
+	CompleteAuthentication(realm string, callbackToken string, status data.BackChannelAuthenticationStatus) *data.OperationError
+	// PollAuthentication returns approved request (request is removed after that) or error that should be passed to client
+	PollAuthentication(realm string, clientId string, authReqId string) (*data.BackChannelAuthenticationRequest, *data.OperationError)
+}
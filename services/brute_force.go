@@ -0,0 +1,210 @@
+package services
+
+import (
+	"time"
+
+	"github.com/wissance/Ferrum/data"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// isLoginLocked checks whether user or client ip address is locked by realm brute-force protection
+/* Parameters:
+ *    - realm - data.Realm with brute-force protection settings
+ *    - userName - name of user that logs in (it is not required that user exists)
+ *    - address - client ip address (could be empty)
+ * Returns: true if login must be denied without password check
+ */
+func (service *TokenBasedSecurityService) isLoginLocked(realm *data.Realm, userName string, address string) bool {
+	protection := realm.BruteForceProtection
+	if protection == nil {
+		return false
+	}
+	now := time.Now()
+	for _, key := range getLoginFailuresKeys(protection, userName, address) {
+		failures, _ := (*service.DataProvider).GetLoginFailures(realm.Name, key)
+		if failures != nil && failures.IsLocked(now) {
+			service.logger.Debug(sf.Format("Login of \"{0}\" is denied, \"{1}\" is locked", userName, key))
+			return true
+		}
+	}
+	return false
+}
+
+// loginAttempt is a login attempt that was counted in brute-force detection counter (key) before credentials check, lockout is
+// a counter state if this attempt has locked user (ip address), otherwise nil
+type loginAttempt struct {
+	key     string
+	lockout *data.LoginFailures
+}
+
+// startLoginAttempt checks that user and client ip address are not locked and counts login attempt as a failure before credentials check
+/* Attempt is counted atomically before slow password hash comparison, therefore parallel requests can't check more passwords than
+ * realm brute-force protection allows (locked user gets the same response as user with wrong password). Attempt that turned out
+ * to be successful must be cancelled (cancelLoginAttempt), counters store errors are only logged and don't deny login
+ * Parameters:
+ *    - realm - data.Realm with brute-force protection settings
+ *    - userName - name of user that logs in (it is not required that user exists)
+ *    - address - client ip address (could be empty)
+ * Returns: counted attempts and false if login must be denied without credentials check
+ */
+func (service *TokenBasedSecurityService) startLoginAttempt(realm *data.Realm, userName string, address string) ([]loginAttempt, bool) {
+	if service.isLoginLocked(realm, userName, address) {
+		return nil, false
+	}
+	protection := realm.BruteForceProtection
+	if protection == nil {
+		return nil, true
+	}
+	attempts := make([]loginAttempt, 0, 2)
+	for _, key := range getLoginFailuresKeys(protection, userName, address) {
+		attempt := loginAttempt{key: key}
+		locked := false
+		_, err := (*service.DataProvider).UpdateLoginFailures(realm.Name, key, func(failures *data.LoginFailures) {
+			now := time.Now()
+			// counter could be locked by parallel request after isLoginLocked check
+			locked = failures.IsLocked(now)
+			attempt.lockout = nil
+			if !locked && service.addLoginFailure(realm.Name, protection, userName, failures, now) {
+				lockout := *failures
+				attempt.lockout = &lockout
+			}
+		})
+		if err != nil {
+			service.logger.Warn(sf.Format("Login failures counter \"{0}\" was not stored: {1}", key, err.Error()))
+			continue
+		}
+		if locked {
+			service.logger.Debug(sf.Format("Login of \"{0}\" is denied, \"{1}\" is locked", userName, key))
+			service.cancelLoginAttempt(realm, userName, attempts)
+			return nil, false
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, true
+}
+
+// cancelLoginAttempt removes attempts (see startLoginAttempt) of login with valid credentials from failed logins counters,
+// lockout that attempt has caused is also cancelled if nobody has changed counter after it
+func (service *TokenBasedSecurityService) cancelLoginAttempt(realm *data.Realm, userName string, attempts []loginAttempt) {
+	protection := realm.BruteForceProtection
+	for _, attempt := range attempts {
+		lockout := attempt.lockout
+		_, err := (*service.DataProvider).UpdateLoginFailures(realm.Name, attempt.key, func(failures *data.LoginFailures) {
+			if lockout != nil && failures.Lockouts == lockout.Lockouts && failures.Permanent == lockout.Permanent &&
+				failures.LockedUntil.Equal(lockout.LockedUntil) {
+				maxFailures, _ := getLoginFailuresLimit(protection, userName, attempt.key)
+				failures.Lockouts--
+				failures.Failures = maxFailures - 1
+				failures.LockedUntil = time.Time{}
+				failures.Permanent = false
+			} else if failures.Failures > 0 {
+				failures.Failures--
+			}
+			setLoginFailuresExpiration(protection, failures)
+		})
+		if err != nil {
+			service.logger.Warn(sf.Format("Login failures counter \"{0}\" was not stored: {1}", attempt.key, err.Error()))
+		}
+	}
+}
+
+// registerLoginFailure increments failed logins counters of user and client ip address and locks them if there are too many failures
+/* Counters are updated atomically by data provider (UpdateLoginFailures), therefore parallel failures (also on other server
+ * instances) are not lost
+ * Parameters:
+ *    - realm - data.Realm with brute-force protection settings
+ *    - userName - name of user that failed to log in
+ *    - address - client ip address (could be empty)
+ * Returns: nothing, counters store errors are logged only
+ */
+func (service *TokenBasedSecurityService) registerLoginFailure(realm *data.Realm, userName string, address string) {
+	protection := realm.BruteForceProtection
+	if protection == nil {
+		return
+	}
+	for _, key := range getLoginFailuresKeys(protection, userName, address) {
+		_, err := (*service.DataProvider).UpdateLoginFailures(realm.Name, key, func(failures *data.LoginFailures) {
+			service.addLoginFailure(realm.Name, protection, userName, failures, time.Now())
+		})
+		if err != nil {
+			service.logger.Warn(sf.Format("Login failures counter \"{0}\" was not stored: {1}", key, err.Error()))
+		}
+	}
+}
+
+// addLoginFailure increments failures of counter and locks user (ip address) if there are too many failures
+/* Counter is reset if previous failure was earlier than FailureResetTime ago. Every lockout is WaitIncrement longer than previous
+ * one (but not longer than MaxWait), with PermanentLockout user is locked permanently after MaxTemporaryLockouts temporary lockouts
+ * Returns: true if this failure has locked user (ip address)
+ */
+func (service *TokenBasedSecurityService) addLoginFailure(realmName string, protection *data.BruteForceProtection, userName string,
+	failures *data.LoginFailures, now time.Time) bool {
+	maxFailures, permanentLockout := getLoginFailuresLimit(protection, userName, failures.Key)
+	if now.Sub(failures.LastFailure) > protection.GetFailureResetTime() {
+		failures.Failures = 0
+	}
+	failures.Failures++
+	failures.LastFailure = now
+	locked := false
+	if failures.Failures >= maxFailures {
+		locked = true
+		failures.Failures = 0
+		failures.Lockouts++
+		if permanentLockout && failures.Lockouts > protection.MaxTemporaryLockouts {
+			failures.Permanent = true
+			service.logger.Info(sf.Format("Realm \"{0}\": \"{1}\" is locked permanently", realmName, failures.Key))
+		} else {
+			wait := protection.GetWaitIncrement() * time.Duration(failures.Lockouts)
+			if wait > protection.GetMaxWait() {
+				wait = protection.GetMaxWait()
+			}
+			failures.LockedUntil = now.Add(wait)
+			service.logger.Info(sf.Format("Realm \"{0}\": \"{1}\" is locked until {2}", realmName, failures.Key,
+				failures.LockedUntil.Format(time.RFC3339)))
+		}
+	}
+	setLoginFailuresExpiration(protection, failures)
+	return locked
+}
+
+// resetLoginFailures removes user failed logins counter after successful login, ip address counter is kept because attacker
+// could log in with own account to reset it
+func (service *TokenBasedSecurityService) resetLoginFailures(realm *data.Realm, userName string) {
+	if realm.BruteForceProtection == nil || realm.BruteForceProtection.MaxLoginFailures <= 0 {
+		return
+	}
+	_ = (*service.DataProvider).DeleteLoginFailures(realm.Name, data.UserLoginFailuresKey(userName))
+}
+
+// getLoginFailuresKeys returns keys of counters that are enabled in realm brute-force protection
+func getLoginFailuresKeys(protection *data.BruteForceProtection, userName string, address string) []string {
+	var keys []string
+	if protection.MaxLoginFailures > 0 {
+		keys = append(keys, data.UserLoginFailuresKey(userName))
+	}
+	if protection.MaxIpLoginFailures > 0 && len(address) > 0 {
+		keys = append(keys, data.IpLoginFailuresKey(address))
+	}
+	return keys
+}
+
+// getLoginFailuresLimit returns number of failures after which counter is locked and whether it could be locked permanently
+// (ip addresses are never locked permanently)
+func getLoginFailuresLimit(protection *data.BruteForceProtection, userName string, key string) (int, bool) {
+	if key != data.UserLoginFailuresKey(userName) {
+		return protection.MaxIpLoginFailures, false
+	}
+	return protection.MaxLoginFailures, protection.PermanentLockout
+}
+
+// setLoginFailuresExpiration sets time when counter could be removed: FailureResetTime after the last failure or the end of
+// lockout, permanently locked counter never expires
+func setLoginFailuresExpiration(protection *data.BruteForceProtection, failures *data.LoginFailures) {
+	failures.Expires = time.Time{}
+	if !failures.Permanent {
+		failures.Expires = failures.LastFailure.Add(protection.GetFailureResetTime())
+		if failures.LockedUntil.After(failures.Expires) {
+			failures.Expires = failures.LockedUntil
+		}
+	}
+}
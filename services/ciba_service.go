@@ -0,0 +1,206 @@
+package services
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/logging"
+	"github.com/wissance/Ferrum/utils/random"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	cibaIdentifierSize    = 32
+	cibaSlowDownIncrement = 5
+)
+
+// CibaService is an in-memory implementation of BackChannelAuthenticationService
+type CibaService struct {
+	notifier   AuthenticationDeviceNotifier
+	expiration int
+	interval   int
+	requests   map[string]map[string]*data.BackChannelAuthenticationRequest
+	mutex      sync.Mutex
+	client     *http.Client
+	logger     *logging.AppLogger
+}
+
+// CreateBackChannelAuthenticationService creates CibaService as BackChannelAuthenticationService
+/* Parameters:
+ *    - cfg - validated CIBA config (expiration and interval values)
+ *    - notifier - authentication device notifier
+ *    - logger - logger service
+ * Returns: instance of CibaService as BackChannelAuthenticationService
+ */
+func CreateBackChannelAuthenticationService(cfg *config.CibaConfig, notifier AuthenticationDeviceNotifier,
+	logger *logging.AppLogger) BackChannelAuthenticationService {
+	service := &CibaService{
+		notifier: notifier, expiration: cfg.Expiration, interval: cfg.Interval, logger: logger,
+		requests: map[string]map[string]*data.BackChannelAuthenticationRequest{},
+		// ping notification is not sent to other host via redirect (client notification endpoint is checked on registration)
+		client: &http.Client{Timeout: deviceNotificationTimeout, CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+	return BackChannelAuthenticationService(service)
+}
+
+// StartAuthentication assigns auth_req_id && callback token to request, stores it and notifies user device
+/* Request lifetime is a config value, client could request shorter lifetime via requestedExpiry. Request is stored only if
+ * device notification was successful
+ * Parameters:
+ *    - realm - name of a realm
+ *    - request - request with client and user data, this function fills AuthReqId, CallbackToken, Status, Expired and Interval
+ *    - requestedExpiry - client requested lifetime in seconds (0 - not requested)
+ *    - callbackUri - full uri of callback endpoint that device should use
+ * Returns: error if identifiers couldn't be generated or device notification failed
+ */
+func (service *CibaService) StartAuthentication(realm string, request *data.BackChannelAuthenticationRequest, requestedExpiry int,
+	callbackUri string) error {
+	authReqId, err := random.GenerateToken(cibaIdentifierSize)
+	if err != nil {
+		return err
+	}
+	callbackToken, err := random.GenerateToken(cibaIdentifierSize)
+	if err != nil {
+		return err
+	}
+	expiration := service.expiration
+	if requestedExpiry > 0 && requestedExpiry < expiration {
+		expiration = requestedExpiry
+	}
+	request.AuthReqId = authReqId
+	request.CallbackToken = callbackToken
+	request.Status = data.BackChannelAuthPending
+	request.Expired = time.Now().Add(time.Second * time.Duration(expiration))
+	request.Interval = service.interval
+
+	notification := dto.AuthenticationDeviceNotification{
+		Realm: realm, ClientId: request.ClientId, LoginHint: request.Username, Scope: request.Scope,
+		BindingMessage: request.BindingMessage, CallbackToken: callbackToken, CallbackUri: callbackUri, ExpiresIn: expiration,
+	}
+	if notifyErr := service.notifier.Notify(&notification); notifyErr != nil {
+		return notifyErr
+	}
+
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realmRequests, ok := service.requests[realm]
+	if !ok {
+		realmRequests = map[string]*data.BackChannelAuthenticationRequest{}
+		service.requests[realm] = realmRequests
+	}
+	current := time.Now()
+	for id, r := range realmRequests {
+		if r.Expired.Before(current) {
+			delete(realmRequests, id)
+		}
+	}
+	realmRequests[authReqId] = request
+	return nil
+}
+
+// CompleteAuthentication saves decision made by user on authentication device
+/* Decision could be passed only once and only for non-expired request. In ping mode client is notified via
+ * its notification endpoint, ping failure doesn't affect decision (client still could poll)
+ * Parameters:
+ *    - realm - name of a realm
+ *    - callbackToken - secret value that device received in notification
+ *    - status - user decision
+ * Returns: nil if decision was saved, otherwise error
+ */
+func (service *CibaService) CompleteAuthentication(realm string, callbackToken string, status data.BackChannelAuthenticationStatus) *data.OperationError {
+	if status != data.BackChannelAuthSucceed && status != data.BackChannelAuthDenied && status != data.BackChannelAuthCancelled {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: sf.Format("status \"{0}\" is not supported", status)}
+	}
+	service.mutex.Lock()
+	var completed *data.BackChannelAuthenticationRequest
+	for _, r := range service.requests[realm] {
+		if subtle.ConstantTimeCompare([]byte(r.CallbackToken), []byte(callbackToken)) == 1 && r.Status == data.BackChannelAuthPending && r.Expired.After(time.Now()) {
+			r.Status = status
+			completed = r
+			break
+		}
+	}
+	service.mutex.Unlock()
+	if completed == nil {
+		return &data.OperationError{Msg: errors.InvalidTokenMsg, Description: errors.ExpiredAuthReqIdDesc}
+	}
+	if completed.DeliveryMode == data.PingDeliveryMode {
+		service.pingClient(completed)
+	}
+	return nil
+}
+
+// PollAuthentication checks request state for client that polls token endpoint
+/* Parameters:
+ *    - realm - name of a realm
+ *    - clientId - client that polls (must be same as client started authentication)
+ *    - authReqId - identifier obtained by client from backchannel authentication endpoint
+ * Returns: approved request (it is removed, tokens could be issued only once) or error with CIBA error code
+ */
+func (service *CibaService) PollAuthentication(realm string, clientId string,
+	authReqId string) (*data.BackChannelAuthenticationRequest, *data.OperationError) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realmRequests := service.requests[realm]
+	request, ok := realmRequests[authReqId]
+	if !ok || request.ClientId != clientId {
+		return nil, &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.ExpiredAuthReqIdDesc}
+	}
+	current := time.Now()
+	if request.Expired.Before(current) {
+		delete(realmRequests, authReqId)
+		return nil, &data.OperationError{Msg: errors.ExpiredTokenMsg, Description: errors.ExpiredAuthReqIdDesc}
+	}
+	switch request.Status {
+	case data.BackChannelAuthSucceed:
+		delete(realmRequests, authReqId)
+		return request, nil
+	case data.BackChannelAuthDenied, data.BackChannelAuthCancelled:
+		delete(realmRequests, authReqId)
+		return nil, &data.OperationError{Msg: errors.AccessDeniedMsg, Description: errors.AccessDeniedDesc}
+	}
+	// pending, poll mode clients must respect interval
+	if request.DeliveryMode == data.PollDeliveryMode && !request.LastPolled.IsZero() &&
+		request.LastPolled.Add(time.Second*time.Duration(request.Interval)).After(current) {
+		request.Interval += cibaSlowDownIncrement
+		request.LastPolled = current
+		return nil, &data.OperationError{Msg: errors.SlowDownMsg, Description: errors.SlowDownDesc}
+	}
+	request.LastPolled = current
+	return nil, &data.OperationError{Msg: errors.AuthorizationPendingMsg, Description: errors.AuthorizationPendingDesc}
+}
+
+// pingClient sends CIBA ping notification to client notification endpoint using client_notification_token as Bearer
+func (service *CibaService) pingClient(request *data.BackChannelAuthenticationRequest) {
+	body, err := json.Marshal(dto.ClientPingNotification{AuthReqId: request.AuthReqId})
+	if err != nil {
+		service.logger.Error(sf.Format("An error occurred during ping notification marshal: {0}", err.Error()))
+		return
+	}
+	httpRequest, err := http.NewRequest(http.MethodPost, request.NotificationEndpoint, bytes.NewReader(body))
+	if err != nil {
+		service.logger.Error(sf.Format("An error occurred during ping notification request creation: {0}", err.Error()))
+		return
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Authorization", "Bearer "+request.ClientNotificationToken)
+	response, err := service.client.Do(httpRequest)
+	if err != nil {
+		service.logger.Warn(sf.Format("Client \"{0}\" ping notification failed: {1}", request.ClientId, err.Error()))
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		service.logger.Warn(sf.Format("Client \"{0}\" ping notification was rejected with status: {1}", request.ClientId, response.StatusCode))
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// validateClientAssertion checks client_assertion of client_secret_jwt and private_key_jwt authentication (RFC 7523, OpenID Connect Core 9)
+/* Assertion is a JWT, where iss and sub are client_id, aud is one of tokenIssueData.ClientAssertionAudiences, exp and jti are required.
+ * client_secret_jwt assertion is signed with client secret (HMAC), private_key_jwt assertion is signed with a private key, public key
+ * is taken from client jwks by kid. If client_id was not passed it is taken from assertion sub and assigned to tokenIssueData.
+ * Parameters:
+ *    - tokenIssueData - client authentication data
+ *    - realm - realm with clients
+ * Returns: nil if assertion is valid, otherwise error (data.OperationError) with description
+ */
+func (service *TokenBasedSecurityService) validateClientAssertion(tokenIssueData *dto.TokenGenerationData, realm *data.Realm) *data.OperationError {
+	if tokenIssueData.ClientAssertionType != globals.JwtBearerClientAssertionType {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.UnsupportedClientAssertionDesc}
+	}
+	invalidAssertion := &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientAssertionDesc}
+	claims := jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenIssueData.ClientAssertion, &claims); err != nil {
+		service.logger.Debug(sf.Format("Client assertion could not be parsed: {0}", err.Error()))
+		return invalidAssertion
+	}
+	clientId := tokenIssueData.ClientId
+	if len(clientId) == 0 {
+		clientId = claims.Subject
+	}
+	var client *data.Client
+	for i := range realm.Clients {
+		if realm.Clients[i].Name == clientId {
+			client = &realm.Clients[i]
+		}
+	}
+	if client == nil {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCredentialDesc}
+	}
+	if client.Auth.Type != data.ClientSecretJwt && client.Auth.Type != data.PrivateKeyJwt {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.ClientAuthMethodNotAllowedDesc}
+	}
+
+	claims = jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenIssueData.ClientAssertion, &claims, func(token *jwt.Token) (interface{}, error) {
+		return getClientAssertionKey(client, token)
+	})
+	if err != nil {
+		service.logger.Debug(sf.Format("Client assertion verification failed: {0}", err.Error()))
+		return invalidAssertion
+	}
+	if claims.Issuer != clientId || claims.Subject != clientId || claims.ExpiresAt == nil || len(claims.ID) == 0 {
+		return invalidAssertion
+	}
+	audienceMatches := false
+	for _, audience := range tokenIssueData.ClientAssertionAudiences {
+		if claims.VerifyAudience(audience, true) {
+			audienceMatches = true
+			break
+		}
+	}
+	if !audienceMatches {
+		return invalidAssertion
+	}
+	if !service.registerAssertion(clientId, claims.ID, claims.ExpiresAt.Time) {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.ClientAssertionReplayedDesc}
+	}
+	tokenIssueData.ClientId = clientId
+	service.logger.Trace("Client assertion was successfully validated")
+	return nil
+}
+
+// registerAssertion remembers assertion (client assertion or DPoP proof) jti of issuer until assertion expiration, returns false if
+// assertion was already used
+func (service *TokenBasedSecurityService) registerAssertion(issuer string, jti string, expires time.Time) bool {
+	service.assertionsMutex.Lock()
+	defer service.assertionsMutex.Unlock()
+	now := time.Now()
+	for k, v := range service.usedAssertions {
+		if v.Before(now) {
+			delete(service.usedAssertions, k)
+		}
+	}
+	key := issuer + ":" + jti
+	if _, ok := service.usedAssertions[key]; ok {
+		return false
+	}
+	service.usedAssertions[key] = expires
+	return true
+}
+
+// getClientAssertionKey returns key for client assertion signature verification according to client authentication type,
+// signing method must correspond to key type (HMAC for client secret, RSA/RSA-PSS/ECDSA for client public keys)
+func getClientAssertionKey(client *data.Client, token *jwt.Token) (interface{}, error) {
+	if client.Auth.Type == data.ClientSecretJwt {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || len(client.Auth.Value) == 0 {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(client.Auth.Value), nil
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, jwt.ErrSignatureInvalid
+	}
+	if client.Auth.Jwks == nil {
+		return nil, jwt.ErrInvalidKey
+	}
+	kid, _ := token.Header["kid"].(string)
+	key := client.Auth.Jwks.FindKey(kid)
+	if key == nil {
+		return nil, jwt.ErrInvalidKey
+	}
+	return key.GetPublicKey()
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"crypto"
+	"crypto/x509"
+	"strings"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// publicKey is a common interface of rsa, ecdsa and ed25519 public keys
+type publicKey interface {
+	Equal(x crypto.PublicKey) bool
+}
+
+// validateClientCertificate checks certificate of tls_client_auth and self_signed_tls_client_auth authentication (RFC 8705 section 2)
+/* tls_client_auth certificate must be issued by trusted CA (clientCertificateRoots) and must have subject DN or SAN that was
+ * registered for client, self_signed_tls_client_auth certificate public key must be one of client jwks keys
+ * Parameters:
+ *    - client - client that uses mutual TLS authentication
+ *    - tokenIssueData - client authentication data with certificate that was presented on TLS handshake
+ * Returns: nil if certificate is valid, otherwise error (data.OperationError) with description
+ */
+func (service *TokenBasedSecurityService) validateClientCertificate(client *data.Client, tokenIssueData *dto.TokenGenerationData) *data.OperationError {
+	certificate := tokenIssueData.ClientCertificate
+	if certificate == nil {
+		return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.ClientCertificateRequiredDesc}
+	}
+	invalidCertificate := &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCertificateDesc}
+	if client.Auth.Type == data.SelfSignedTlsClientAuth {
+		if !isCertificateKeyRegistered(client.Auth.Jwks, certificate) {
+			service.logger.Debug(sf.Format("Client \"{0}\" certificate public key is not registered", client.Name))
+			return invalidCertificate
+		}
+		service.logger.Trace("Self-signed client certificate was successfully validated")
+		return nil
+	}
+	_, err := certificate.Verify(x509.VerifyOptions{Roots: service.clientCertificateRoots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+	if err != nil {
+		service.logger.Debug(sf.Format("Client \"{0}\" certificate verification failed: {1}", client.Name, err.Error()))
+		return invalidCertificate
+	}
+	if !isCertificateSubjectMatches(&client.Auth, certificate) {
+		service.logger.Debug(sf.Format("Client \"{0}\" certificate subject does not match registered subject", client.Name))
+		return invalidCertificate
+	}
+	service.logger.Trace("Client certificate was successfully validated")
+	return nil
+}
+
+// isCertificateKeyRegistered checks whether certificate public key is one of jwks keys
+func isCertificateKeyRegistered(jwks *data.JsonWebKeySet, certificate *x509.Certificate) bool {
+	if jwks == nil {
+		return false
+	}
+	certificateKey, ok := certificate.PublicKey.(publicKey)
+	if !ok {
+		return false
+	}
+	for i := range jwks.Keys {
+		key, err := jwks.Keys[i].GetPublicKey()
+		if err == nil && certificateKey.Equal(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCertificateSubjectMatches checks certificate subject DN or SAN against expected value, only one of them is registered for client
+func isCertificateSubjectMatches(auth *data.Authentication, certificate *x509.Certificate) bool {
+	switch {
+	case len(auth.TlsSubjectDn) > 0:
+		return normalizeDistinguishedName(certificate.Subject.String()) == normalizeDistinguishedName(auth.TlsSubjectDn)
+	case len(auth.TlsSanDns) > 0:
+		for _, name := range certificate.DNSNames {
+			if strings.EqualFold(name, auth.TlsSanDns) {
+				return true
+			}
+		}
+	case len(auth.TlsSanUri) > 0:
+		for _, uri := range certificate.URIs {
+			if uri.String() == auth.TlsSanUri {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeDistinguishedName removes spaces around RDN separators, i.e. "CN=client, O=Org" -> "CN=client,O=Org"
+func normalizeDistinguishedName(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return strings.Join(parts, ",")
+}
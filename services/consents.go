@@ -0,0 +1,157 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// consentRequest is an authorization request that waits for user consent, it is bound to session that was shown consent form
+type consentRequest struct {
+	request   *dto.AuthorizationRequest
+	sessionId uuid.UUID
+	expired   time.Time
+}
+
+// GrantConsent stores scopes that user granted to client on consent screen
+/* Scopes are added to previously granted scopes of client, therefore next authorization with any of granted scopes
+ * doesn't show consent screen
+ * Parameters:
+ *    - realm - realm that user belongs to
+ *    - user - user that granted consent
+ *    - clientId - client that consent is granted to
+ *    - scope - space-separated requested scopes (scope parameter of authorization request)
+ * Returns: error if user wasn't stored
+ */
+func (service *TokenBasedSecurityService) GrantConsent(realm *data.Realm, user data.User, clientId string, scope string) *data.OperationError {
+	consents := user.GetConsents()
+	current := time.Now().Unix()
+	index := -1
+	for i := range consents {
+		if consents[i].ClientId == clientId {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		consents = append(consents, data.UserConsent{ClientId: clientId, Scopes: []string{}, Created: current})
+		index = len(consents) - 1
+	}
+	for _, s := range strings.Fields(scope) {
+		if !consents[index].HasScope(s) {
+			consents[index].Scopes = append(consents[index].Scopes, s)
+		}
+	}
+	consents[index].LastUpdated = current
+	if check := service.storeConsents(realm, user, consents); check != nil {
+		return check
+	}
+	service.logger.Info(sf.Format("User \"{0}\" granted scopes \"{1}\" to client \"{2}\"", user.GetUsername(), scope, clientId))
+	return nil
+}
+
+// RevokeConsent removes user consent of client, next browser login to client shows consent screen again
+/* Parameters:
+ *    - realm - realm that user belongs to
+ *    - user - user that revokes consent (admin revokes it on behalf of user)
+ *    - clientId - client that consent was granted to
+ * Returns: error if user has no consent for client or user wasn't stored
+ */
+func (service *TokenBasedSecurityService) RevokeConsent(realm *data.Realm, user data.User, clientId string) *data.OperationError {
+	consents := user.GetConsents()
+	remaining := make([]data.UserConsent, 0, len(consents))
+	for _, c := range consents {
+		if c.ClientId != clientId {
+			remaining = append(remaining, c)
+		}
+	}
+	if len(remaining) == len(consents) {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.ConsentNotFoundDesc}
+	}
+	if check := service.storeConsents(realm, user, remaining); check != nil {
+		return check
+	}
+	service.logger.Info(sf.Format("Consent of user \"{0}\" to client \"{1}\" was revoked", user.GetUsername(), clientId))
+	return nil
+}
+
+// storeConsents replaces user consents and stores user in data source
+func (service *TokenBasedSecurityService) storeConsents(realm *data.Realm, user data.User, consents []data.UserConsent) *data.OperationError {
+	userName := user.GetUsername()
+	if err := user.SetConsents(consents); err != nil {
+		service.logger.Error(sf.Format("Consent: consents of user \"{0}\" were not set: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if err := (*service.DataProvider).UpdateUser(realm.Name, userName, user); err != nil {
+		service.logger.Error(sf.Format("Consent: user \"{0}\" was not stored: {1}", userName, err.Error()))
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	return nil
+}
+
+// StoreConsentRequest saves authorization request until user submits consent form and generates consent form reference
+/* Consent requests are stored separately from pushed authorization requests, therefore request_uri that client got from
+ * PAR endpoint can't be submitted as consent. Request is bound to session of user that was shown consent form, expired
+ * requests are removed on every store call
+ * Parameters:
+ *    - realm - name of a realm
+ *    - authRequest - authorization request that requires user consent
+ *    - sessionId - identifier of user session that consent form was shown for
+ *    - lifetime - consent form lifetime in seconds
+ * Returns: consent form reference
+ */
+func (service *TokenBasedSecurityService) StoreConsentRequest(realm string, authRequest *dto.AuthorizationRequest, sessionId uuid.UUID, lifetime int) string {
+	service.consentMutex.Lock()
+	defer service.consentMutex.Unlock()
+	realmRequests, ok := service.consentRequests[realm]
+	if !ok {
+		realmRequests = map[string]consentRequest{}
+		service.consentRequests[realm] = realmRequests
+	}
+	current := time.Now()
+	for reference, r := range realmRequests {
+		if r.expired.Before(current) {
+			delete(realmRequests, reference)
+		}
+	}
+	reference := uuid.New().String()
+	realmRequests[reference] = consentRequest{request: authRequest, sessionId: sessionId,
+		expired: current.Add(time.Second * time.Duration(lifetime))}
+	return reference
+}
+
+// GetConsentRequest returns authorization request by consent form reference
+/* Reference is a one-time value, therefore request is removed on first read by session that was shown consent form, request
+ * is returned only if it was stored for the same client and the same user session and is not expired
+ * Parameters:
+ *    - realm - name of a realm
+ *    - clientId - client that consent form was shown for
+ *    - reference - consent form reference
+ *    - sessionId - identifier of session of user that submits consent form
+ * Returns: authorization request or nil if request not found, expired or belongs to other client or session
+ */
+func (service *TokenBasedSecurityService) GetConsentRequest(realm string, clientId string, reference string, sessionId uuid.UUID) *dto.AuthorizationRequest {
+	service.consentMutex.Lock()
+	defer service.consentMutex.Unlock()
+	r, ok := service.consentRequests[realm][reference]
+	if !ok {
+		return nil
+	}
+	if r.expired.Before(time.Now()) {
+		delete(service.consentRequests[realm], reference)
+		service.logger.Debug("Consent request is expired")
+		return nil
+	}
+	// request is removed only by session that was shown consent form, other sessions can't make it unusable
+	if r.request.ClientId != clientId || r.sessionId != sessionId {
+		service.logger.Debug("Consent request belongs to another client or session")
+		return nil
+	}
+	delete(service.consentRequests[realm], reference)
+	return r.request
+}
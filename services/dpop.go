@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// dpopProofClaims are DPoP proof JWT claims (RFC 9449 section 4.2), AccessTokenHash is required when proof is sent with access token
+type dpopProofClaims struct {
+	jwt.RegisteredClaims
+	HttpMethod      string `json:"htm"`
+	HttpUri         string `json:"htu"`
+	AccessTokenHash string `json:"ath,omitempty"`
+}
+
+// CheckDPoPProof validates DPoP proof (RFC 9449 section 4.3) and returns JWK thumbprint of proof key
+/* Proof is a JWT with typ dpop+jwt signed with asymmetric algorithm by a private key, public key is passed in jwk header.
+ * Proof must have jti (proof is a one-time value), iat not older than globals.DPoPProofLifetime, htm and htu that match request
+ * and ath (hash of access token) if proof is sent to protected resource
+ * Parameters:
+ *    - proof - DPoP header value
+ *    - httpMethod - request method
+ *    - httpUris - request uri variants (i.e. with and without /auth prefix), htu must be one of them
+ *    - accessToken - access token that is sent with proof (empty value for token endpoint)
+ * Returns: JWK thumbprint of proof key and nil if proof is valid, otherwise empty string and error with description
+ */
+func (service *TokenBasedSecurityService) CheckDPoPProof(proof string, httpMethod string, httpUris []string, accessToken string) (string, *data.OperationError) {
+	invalidProof := &data.OperationError{Msg: errors.InvalidDPoPProofMsg, Description: errors.InvalidDPoPProofDesc}
+	var proofKey *data.JsonWebKey
+	claims := dpopProofClaims{}
+	_, err := jwt.ParseWithClaims(proof, &claims, func(token *jwt.Token) (interface{}, error) {
+		var keyErr error
+		proofKey, keyErr = getDPoPProofKey(token)
+		if keyErr != nil {
+			return nil, keyErr
+		}
+		return proofKey.GetPublicKey()
+	})
+	if err != nil {
+		service.logger.Debug(sf.Format("DPoP proof verification failed: {0}", err.Error()))
+		return "", invalidProof
+	}
+	if len(claims.ID) == 0 || claims.IssuedAt == nil || claims.HttpMethod != httpMethod || !isDPoPUriMatches(claims.HttpUri, httpUris) {
+		return "", invalidProof
+	}
+	lifetime := time.Second * time.Duration(globals.DPoPProofLifetime)
+	now := time.Now()
+	if claims.IssuedAt.Time.Before(now.Add(-lifetime)) || claims.IssuedAt.Time.After(now.Add(lifetime)) {
+		service.logger.Debug("DPoP proof is expired or issued in future")
+		return "", invalidProof
+	}
+	if len(accessToken) > 0 {
+		accessTokenHash := sha256.Sum256([]byte(accessToken))
+		if claims.AccessTokenHash != base64.RawURLEncoding.EncodeToString(accessTokenHash[:]) {
+			service.logger.Debug("DPoP proof ath does not match access token")
+			return "", invalidProof
+		}
+	}
+	thumbprint, err := proofKey.Thumbprint()
+	if err != nil {
+		return "", invalidProof
+	}
+	// proof is a one-time value, it is remembered until it becomes too old to be accepted
+	if !service.registerAssertion(globals.DPoPProofType+":"+thumbprint, claims.ID, claims.IssuedAt.Time.Add(lifetime)) {
+		service.logger.Debug("DPoP proof was already used")
+		return "", invalidProof
+	}
+	return thumbprint, nil
+}
+
+// getDPoPProofKey checks DPoP proof header (typ, asymmetric alg, jwk without private part) and returns proof public key
+func getDPoPProofKey(token *jwt.Token) (*data.JsonWebKey, error) {
+	if typ, _ := token.Header["typ"].(string); typ != globals.DPoPProofType {
+		return nil, jwt.ErrInvalidKeyType
+	}
+	switch token.Method.(type) {
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+	default:
+		return nil, jwt.ErrSignatureInvalid
+	}
+	jwk, ok := token.Header["jwk"].(map[string]interface{})
+	if !ok {
+		return nil, jwt.ErrInvalidKey
+	}
+	if _, hasPrivatePart := jwk["d"]; hasPrivatePart {
+		return nil, jwt.ErrInvalidKey
+	}
+	jwkValue, err := json.Marshal(jwk)
+	if err != nil {
+		return nil, err
+	}
+	key := data.JsonWebKey{}
+	if err = json.Unmarshal(jwkValue, &key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// isDPoPUriMatches compares htu claim with request uris, query and fragment are ignored (RFC 9449 section 4.3)
+func isDPoPUriMatches(httpUri string, httpUris []string) bool {
+	parsedUri, err := url.Parse(httpUri)
+	if err != nil {
+		return false
+	}
+	parsedUri.RawQuery = ""
+	parsedUri.Fragment = ""
+	for _, uri := range httpUris {
+		if parsedUri.String() == uri {
+			return true
+		}
+	}
+	return false
+}
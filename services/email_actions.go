@@ -0,0 +1,306 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/logging"
+	"github.com/wissance/Ferrum/managers"
+	"github.com/wissance/Ferrum/utils/hashing"
+	"github.com/wissance/Ferrum/utils/random"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	actionTokenSize = 32
+	// maxUserActionTokens limits number of simultaneously valid links of a user, the oldest link is dropped
+	maxUserActionTokens     = 10
+	actionTokenKeySeparator = "."
+)
+
+// Email texts are the same as KeyCloak default theme texts
+const (
+	verifyEmailSubject      = "Verify email"
+	verifyEmailBody         = "Someone has created a {0} account with this email address. If this was you, click the link below to verify your email address\n\n{1}\n\nThis link will expire within {2}.\n\nIf you didn't create this account, just ignore this message.\n"
+	resetPasswordSubject    = "Reset password"
+	resetPasswordBody       = "Someone just requested to change your {0} account's credentials. If this was you, click on the link below to reset them.\n\n{1}\n\nThis link will expire within {2}.\n\nIf you don't want to reset your credentials, just ignore this message and nothing will be changed.\n"
+	executeActionsSubject   = "Update Your Account"
+	executeActionsBody      = "Your administrator has just requested that you update your {0} account by performing the following action(s): {3}. Click on the link below to start this process.\n\n{1}\n\nThis link will expire within {2}.\n\nIf you are unaware that your administrator has requested this, just ignore this message and nothing will be changed.\n"
+	actionTokenLinkTemplate = "{0}/auth/realms/{1}/login-actions/action-token?key={2}"
+	lifespanMinutesTemplate = "{0} minutes"
+	lifespanHoursTemplate   = "{0} hours"
+	secondsInMinute         = 60
+	secondsInHour           = 3600
+)
+
+// EmailActionService is an interface of email-based flows: user gets email with link, link allows to execute actions (verify email,
+// update password) without login
+/* Flows:
+ * 1. Verify email - user requests email with link that sets info.email_verified
+ * 2. Reset password (forgot password) - user requests email with link that allows to set new password
+ * 3. Execute actions - administrator sends email with link that requires user to execute actions
+ */
+type EmailActionService interface {
+	// SendVerifyEmail sends email verification link to user email
+	SendVerifyEmail(realm *data.Realm, user data.User) *data.OperationError
+	// SendResetPasswordEmail sends reset password link if user exists and has email, result doesn't depend on user existence
+	SendResetPasswordEmail(realm *data.Realm, userName string) *data.OperationError
+	// SendExecuteActionsEmail sends link with actions that administrator requested, lifespan in seconds (0 - realm default)
+	SendExecuteActionsEmail(realm *data.Realm, user data.User, actions []string, lifespan int) *data.OperationError
+	// ExecuteActionToken executes actions of link key, password is required only for UPDATE_PASSWORD action
+	ExecuteActionToken(realm *data.Realm, key string, password string) (*dto.ActionTokenResult, *data.OperationError)
+}
+
+// MailActionService is an implementation of EmailActionService, action tokens are stored in user credentials (not in memory),
+// therefore links that CLI sends are valid on server and links survive server restart
+type MailActionService struct {
+	sender       MailSender
+	dataProvider *managers.DataContext
+	linkBaseUrl  string
+	mutex        sync.Mutex
+	logger       *logging.AppLogger
+}
+
+// CreateEmailActionService creates MailActionService as EmailActionService
+/* Parameters:
+ *    - sender - mail sender
+ *    - dataProvider - data context where users (and their action tokens) are stored
+ *    - linkBaseUrl - public Ferrum url that is used in links (config.MailConfig GetLinkBaseUrl)
+ *    - logger - logger service
+ * Returns: instance of MailActionService as EmailActionService
+ */
+func CreateEmailActionService(sender MailSender, dataProvider *managers.DataContext, linkBaseUrl string,
+	logger *logging.AppLogger) EmailActionService {
+	return EmailActionService(&MailActionService{sender: sender, dataProvider: dataProvider, linkBaseUrl: linkBaseUrl, logger: logger})
+}
+
+// SendVerifyEmail issues VERIFY_EMAIL link with realm user lifespan and sends it to user email
+func (service *MailActionService) SendVerifyEmail(realm *data.Realm, user data.User) *data.OperationError {
+	if realm.Email == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc}
+	}
+	return service.sendActionEmail(realm, user, []string{data.VerifyEmailAction}, realm.Email.GetActionTokenLifespan(),
+		verifyEmailSubject, verifyEmailBody)
+}
+
+// SendResetPasswordEmail issues UPDATE_PASSWORD link with realm user lifespan and sends it to user email
+/* Unknown user, user without email and delivery error are only logged: response must not allow to find out whether user exists
+ * Parameters:
+ *    - realm - realm with email settings
+ *    - userName - name of user that forgot password
+ * Returns: error only if email is not configured in realm
+ */
+func (service *MailActionService) SendResetPasswordEmail(realm *data.Realm, userName string) *data.OperationError {
+	if realm.Email == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc}
+	}
+	user, err := (*service.dataProvider).GetUser(realm.Name, userName)
+	if err != nil || user == nil {
+		service.logger.Debug(sf.Format("Reset password: user \"{0}\" was not found in realm \"{1}\"", userName, realm.Name))
+		return nil
+	}
+	check := service.sendActionEmail(realm, user, []string{data.UpdatePasswordAction}, realm.Email.GetActionTokenLifespan(),
+		resetPasswordSubject, resetPasswordBody)
+	if check != nil {
+		service.logger.Debug(sf.Format("Reset password: email was not sent to user \"{0}\": {1}", userName, check.Description))
+	}
+	return nil
+}
+
+// SendExecuteActionsEmail issues link with actions that administrator requested and sends it to user email
+/* Parameters:
+ *    - realm - realm with email settings
+ *    - user - user that should execute actions
+ *    - actions - data.VerifyEmailAction and (or) data.UpdatePasswordAction
+ *    - lifespan - link lifetime (seconds), 0 - realm administrator links lifespan
+ * Returns: nil if email was sent
+ */
+func (service *MailActionService) SendExecuteActionsEmail(realm *data.Realm, user data.User, actions []string, lifespan int) *data.OperationError {
+	if realm.Email == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.EmailNotEnabledDesc}
+	}
+	if len(actions) == 0 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedActionDesc}
+	}
+	for _, action := range actions {
+		if !data.IsActionSupported(action) {
+			return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedActionDesc}
+		}
+	}
+	if lifespan <= 0 {
+		lifespan = realm.Email.GetAdminActionTokenLifespan()
+	}
+	return service.sendActionEmail(realm, user, actions, lifespan, executeActionsSubject, executeActionsBody)
+}
+
+// ExecuteActionToken checks link key and executes link actions, key is removed after successful execution
+/* Link with UPDATE_PASSWORD action without password (or with password that violates realm password policy) is not consumed,
+ * so user could retry. VERIFY_EMAIL fails if user email was changed after link was sent
+ * Parameters:
+ *    - realm - realm of user
+ *    - key - link key ({user id}.{token})
+ *    - password - new password (UPDATE_PASSWORD action)
+ * Returns: executed actions or error
+ */
+func (service *MailActionService) ExecuteActionToken(realm *data.Realm, key string, password string) (*dto.ActionTokenResult, *data.OperationError) {
+	invalidToken := &data.OperationError{Msg: errors.InvalidTokenMsg, Description: errors.InvalidActionTokenDesc}
+	userIdValue, token, ok := strings.Cut(key, actionTokenKeySeparator)
+	userId, err := uuid.Parse(userIdValue)
+	if !ok || err != nil || len(token) == 0 {
+		return nil, invalidToken
+	}
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	user, err := (*service.dataProvider).GetUserById(realm.Name, userId)
+	if err != nil || user == nil {
+		return nil, invalidToken
+	}
+	now := time.Now().Unix()
+	tokens := user.GetActionTokens()
+	tokenIndex := -1
+	for i, t := range tokens {
+		if t.Expires > now && hashing.CheckTokenHash(token, t.TokenHash) {
+			tokenIndex = i
+			break
+		}
+	}
+	if tokenIndex < 0 {
+		service.logger.Debug(sf.Format("Action token: link of user \"{0}\" is invalid or expired", user.GetUsername()))
+		return nil, invalidToken
+	}
+	actionToken := tokens[tokenIndex]
+	tokens = append(tokens[:tokenIndex], tokens[tokenIndex+1:]...)
+	var check *data.OperationError
+	if actionToken.HasAction(data.VerifyEmailAction) && !strings.EqualFold(user.GetEmail(), actionToken.Email) {
+		// link was sent to previous email, it is useless now
+		service.logger.Debug(sf.Format("Action token: email of user \"{0}\" was changed after link was sent", user.GetUsername()))
+		check = invalidToken
+	} else if actionToken.HasAction(data.UpdatePasswordAction) {
+		if len(password) == 0 {
+			return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.PasswordRequiredDesc}
+		}
+		if check = CheckPasswordPolicy(realm.PasswordPolicy, user, password); check != nil {
+			return nil, check
+		}
+	}
+	if check == nil {
+		check = service.applyActions(realm.Name, user, &actionToken, password, tokens)
+	} else {
+		_ = service.storeActionTokens(realm.Name, user, tokens)
+	}
+	if check != nil {
+		return nil, check
+	}
+	service.logger.Info(sf.Format("User \"{0}\" executed actions {1} via email link", user.GetUsername(), strings.Join(actionToken.Actions, ", ")))
+	return &dto.ActionTokenResult{Username: user.GetUsername(), ExecutedActions: actionToken.Actions}, nil
+}
+
+// applyActions sets new password and (or) marks email as verified, removes satisfied required actions and stores user with remaining tokens
+func (service *MailActionService) applyActions(realmName string, user data.User, actionToken *data.ActionToken, password string,
+	tokens []data.ActionToken) *data.OperationError {
+	if actionToken.HasAction(data.UpdatePasswordAction) {
+		if err := user.SetPassword(password); err != nil {
+			service.logger.Error(sf.Format("Action token: password of user \"{0}\" was not set: {1}", user.GetUsername(), err.Error()))
+			return &data.OperationError{Msg: errors.OtherAppError}
+		}
+	}
+	if actionToken.HasAction(data.VerifyEmailAction) {
+		if err := user.SetEmailVerified(true); err != nil {
+			service.logger.Error(sf.Format("Action token: email of user \"{0}\" was not verified: {1}", user.GetUsername(), err.Error()))
+			return &data.OperationError{Msg: errors.OtherAppError}
+		}
+	}
+	// executed actions satisfy the same user required actions
+	for _, action := range actionToken.Actions {
+		if _, err := data.RemoveRequiredAction(user, action); err != nil {
+			service.logger.Error(sf.Format("Action token: required actions of user \"{0}\" were not updated: {1}", user.GetUsername(), err.Error()))
+			return &data.OperationError{Msg: errors.OtherAppError}
+		}
+	}
+	if err := service.storeActionTokens(realmName, user, tokens); err != nil {
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	return nil
+}
+
+// sendActionEmail issues action token (link) and sends email with it
+func (service *MailActionService) sendActionEmail(realm *data.Realm, user data.User, actions []string, lifespan int,
+	subject string, bodyTemplate string) *data.OperationError {
+	email := user.GetEmail()
+	if len(email) == 0 {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UserHasNoEmailDesc}
+	}
+	key, err := service.issueActionToken(realm.Name, user.GetId(), actions, email, lifespan)
+	if err != nil {
+		service.logger.Error(sf.Format("Action token for user \"{0}\" was not issued: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	link := sf.Format(actionTokenLinkTemplate, service.linkBaseUrl, url.PathEscape(realm.Name), url.QueryEscape(key))
+	message := dto.MailMessage{
+		Realm: realm.Name, From: realm.Email.From, To: email, Subject: subject,
+		Body: sf.Format(bodyTemplate, realm.Name, link, formatLifespan(lifespan), strings.Join(actions, ", ")),
+	}
+	if err = service.sender.Send(realm.Email, &message); err != nil {
+		service.logger.Error(sf.Format("Email to user \"{0}\" was not sent: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable, Description: errors.EmailSendFailedDesc}
+	}
+	service.logger.Info(sf.Format("Email with actions {0} was sent to user \"{1}\"", strings.Join(actions, ", "), user.GetUsername()))
+	return nil
+}
+
+// issueActionToken generates token, stores its hash in user credentials (expired tokens are removed) and returns link key
+func (service *MailActionService) issueActionToken(realmName string, userId uuid.UUID, actions []string, email string,
+	lifespan int) (string, error) {
+	token, err := random.GenerateToken(actionTokenSize)
+	if err != nil {
+		return "", err
+	}
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	// user is re-read under mutex, otherwise parallel requests could overwrite each other tokens
+	user, err := (*service.dataProvider).GetUserById(realmName, userId)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().Unix()
+	tokens := []data.ActionToken{}
+	for _, t := range user.GetActionTokens() {
+		if t.Expires > now {
+			tokens = append(tokens, t)
+		}
+	}
+	tokens = append(tokens, data.ActionToken{TokenHash: hashing.HashToken(token), Actions: actions, Email: email,
+		Expires: now + int64(lifespan)})
+	if len(tokens) > maxUserActionTokens {
+		tokens = tokens[len(tokens)-maxUserActionTokens:]
+	}
+	if err = service.storeActionTokens(realmName, user, tokens); err != nil {
+		return "", err
+	}
+	return userId.String() + actionTokenKeySeparator + token, nil
+}
+
+func (service *MailActionService) storeActionTokens(realmName string, user data.User, tokens []data.ActionToken) error {
+	err := user.SetActionTokens(tokens)
+	if err == nil {
+		err = (*service.dataProvider).UpdateUser(realmName, user.GetUsername(), user)
+	}
+	if err != nil {
+		service.logger.Error(sf.Format("Action tokens of user \"{0}\" were not stored: {1}", user.GetUsername(), err.Error()))
+	}
+	return err
+}
+
+// formatLifespan formats link lifetime for email text
+func formatLifespan(lifespan int) string {
+	if lifespan >= secondsInHour && lifespan%secondsInHour == 0 {
+		return sf.Format(lifespanHoursTemplate, lifespan/secondsInHour)
+	}
+	return sf.Format(lifespanMinutesTemplate, (lifespan+secondsInMinute-1)/secondsInMinute)
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// DefaultMaxRealmEvents is a number of latest events that is stored per realm
+const DefaultMaxRealmEvents = 1000
+
+// EventService is an interface of user events store (login, token refresh and so on) that admin console and Admin REST API read
+type EventService interface {
+	// AddEvent stores event of realm
+	AddEvent(event *data.Event)
+	// GetEvents returns page of realm events that match query sorted from newest
+	GetEvents(realmName string, query *dto.EventsQuery) []dto.EventRepresentation
+}
+
+// MemoryEventService is an implementation of EventService that stores latest events of every realm in memory, events are lost on
+// server restart and oldest realm events are dropped when realm has more than maxRealmEvents events
+type MemoryEventService struct {
+	events         map[string][]data.Event
+	maxRealmEvents int
+	mutex          sync.RWMutex
+	logger         *logging.AppLogger
+}
+
+// CreateEventService creates in-memory EventService
+/* Parameters:
+ *    - maxRealmEvents - number of latest events that is stored per realm (DefaultMaxRealmEvents if it is not positive)
+ *    - logger - logger
+ * Returns: EventService
+ */
+func CreateEventService(maxRealmEvents int, logger *logging.AppLogger) EventService {
+	if maxRealmEvents <= 0 {
+		maxRealmEvents = DefaultMaxRealmEvents
+	}
+	return EventService(&MemoryEventService{events: map[string][]data.Event{}, maxRealmEvents: maxRealmEvents, logger: logger})
+}
+
+// AddEvent stores event of realm, oldest realm event is dropped if realm has too many events
+func (service *MemoryEventService) AddEvent(event *data.Event) {
+	service.mutex.Lock()
+	defer service.mutex.Unlock()
+	realmEvents := append(service.events[event.Realm], *event)
+	if len(realmEvents) > service.maxRealmEvents {
+		realmEvents = realmEvents[len(realmEvents)-service.maxRealmEvents:]
+	}
+	service.events[event.Realm] = realmEvents
+	service.logger.Debug(sf.Format("Realm \"{0}\": event {1} of client \"{2}\"", event.Realm, string(event.Type), event.ClientId))
+}
+
+// GetEvents returns page of realm events that match query sorted from newest
+func (service *MemoryEventService) GetEvents(realmName string, query *dto.EventsQuery) []dto.EventRepresentation {
+	service.mutex.RLock()
+	defer service.mutex.RUnlock()
+	realmEvents := service.events[realmName]
+	result := make([]dto.EventRepresentation, 0)
+	skipped := 0
+	for i := len(realmEvents) - 1; i >= 0 && (query.Max <= 0 || len(result) < query.Max); i-- {
+		event := &realmEvents[i]
+		if !matchEventsQuery(event, query) {
+			continue
+		}
+		if skipped < query.First {
+			skipped++
+			continue
+		}
+		result = append(result, createEventRepresentation(event))
+	}
+	return result
+}
+
+func matchEventsQuery(event *data.Event, query *dto.EventsQuery) bool {
+	if len(query.Client) > 0 && event.ClientId != query.Client {
+		return false
+	}
+	if len(query.User) > 0 && event.UserId.String() != query.User {
+		return false
+	}
+	if len(query.Types) == 0 {
+		return true
+	}
+	for _, eventType := range query.Types {
+		if string(event.Type) == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func createEventRepresentation(event *data.Event) dto.EventRepresentation {
+	representation := dto.EventRepresentation{Time: event.Time.UnixMilli(), Type: string(event.Type), RealmId: event.Realm,
+		ClientId: event.ClientId, IpAddress: event.IpAddress, Error: event.Error, Details: event.Details}
+	if event.UserId != uuid.Nil {
+		representation.UserId = event.UserId.String()
+	}
+	if event.SessionId != uuid.Nil {
+		representation.SessionId = event.SessionId.String()
+	}
+	return representation
+}
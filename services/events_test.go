@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+)
+
+func TestMemoryEventServiceKeepsLatestRealmEvents(t *testing.T) {
+	logger := logging.CreateLogger(&config.LoggingConfig{Level: "info"})
+	service := CreateEventService(2, logger)
+	started := time.Now()
+	for i, client := range []string{"web", "crm", "erp"} {
+		service.AddEvent(&data.Event{Time: started.Add(time.Duration(i) * time.Second), Type: data.LoginEvent, Realm: "sales", ClientId: client})
+	}
+	service.AddEvent(&data.Event{Time: started, Type: data.LoginErrorEvent, Realm: "support", ClientId: "web"})
+
+	events := service.GetEvents("sales", &dto.EventsQuery{})
+	assert.Len(t, events, 2)
+	assert.Equal(t, "erp", events[0].ClientId)
+	assert.Equal(t, "crm", events[1].ClientId)
+	assert.Empty(t, events[0].UserId)
+	events = service.GetEvents("sales", &dto.EventsQuery{Client: "web"})
+	assert.Empty(t, events)
+	events = service.GetEvents("support", &dto.EventsQuery{Types: []string{string(data.LoginErrorEvent)}})
+	assert.Len(t, events, 1)
+	assert.Empty(t, service.GetEvents("other", &dto.EventsQuery{}))
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// FileDeviceNotifier is AuthenticationDeviceNotifier that appends every notification as a JSON line to file
+/* This notifier is a stand-in for tests and local development: test (or developer) reads notification from file and calls
+ * callback endpoint with callback_token instead of real device
+ */
+type FileDeviceNotifier struct {
+	fileName string
+	mutex    sync.Mutex
+	logger   *logging.AppLogger
+}
+
+// CreateFileDeviceNotifier creates FileDeviceNotifier that writes notifications to fileName
+func CreateFileDeviceNotifier(fileName string, logger *logging.AppLogger) *FileDeviceNotifier {
+	return &FileDeviceNotifier{fileName: fileName, logger: logger}
+}
+
+// Notify appends notification to file
+func (notifier *FileDeviceNotifier) Notify(notification *dto.AuthenticationDeviceNotification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	file, err := os.OpenFile(notifier.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		notifier.logger.Error(sf.Format("An error occurred during notifications file open: {0}", err.Error()))
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
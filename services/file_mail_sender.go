@@ -0,0 +1,46 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// FileMailSender is MailSender that appends every message as a JSON line to file
+/* This sender is a stand-in for tests and local development: test (or developer) reads message from file and opens link
+ * from message body, realm SMTP server settings are not used
+ */
+type FileMailSender struct {
+	fileName string
+	mutex    sync.Mutex
+	logger   *logging.AppLogger
+}
+
+// CreateFileMailSender creates FileMailSender that writes messages to fileName
+func CreateFileMailSender(fileName string, logger *logging.AppLogger) *FileMailSender {
+	return &FileMailSender{fileName: fileName, logger: logger}
+}
+
+// Send appends message to file
+func (sender *FileMailSender) Send(_ *data.EmailSettings, message *dto.MailMessage) error {
+	line, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	sender.mutex.Lock()
+	defer sender.mutex.Unlock()
+	file, err := os.OpenFile(sender.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		sender.logger.Error(sf.Format("An error occurred during mails file open: {0}", err.Error()))
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
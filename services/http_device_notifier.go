@@ -0,0 +1,46 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const deviceNotificationTimeout = 10 * time.Second
+
+// HttpDeviceNotifier is AuthenticationDeviceNotifier that sends notification as JSON via HTTP POST to external service
+type HttpDeviceNotifier struct {
+	endpoint string
+	client   *http.Client
+	logger   *logging.AppLogger
+}
+
+// CreateHttpDeviceNotifier creates HttpDeviceNotifier that posts notifications to endpoint
+func CreateHttpDeviceNotifier(endpoint string, logger *logging.AppLogger) *HttpDeviceNotifier {
+	return &HttpDeviceNotifier{endpoint: endpoint, client: &http.Client{Timeout: deviceNotificationTimeout}, logger: logger}
+}
+
+// Notify posts notification to external service, any 2xx status is considered as successful delivery
+func (notifier *HttpDeviceNotifier) Notify(notification *dto.AuthenticationDeviceNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("json.Marshal failed: %w", err)
+	}
+	response, err := notifier.client.Post(notifier.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		notifier.logger.Error(sf.Format("An error occurred during authentication device notification: {0}", err.Error()))
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		notifier.logger.Error(sf.Format("Authentication device notification was rejected with status: {0}", response.StatusCode))
+		return fmt.Errorf("authentication device notification was rejected with status %d", response.StatusCode)
+	}
+	return nil
+}
@@ -81,7 +81,7 @@ func (generator *JwtGenerator) prepareAccessToken(realmBaseUrl string, tokenType
 	issuer := realmBaseUrl
 	jwtCommon := data.JwtCommonInfo{Issuer: issuer, Type: tokenType, Audience: "account", Scope: scope, JwtId: uuid.New(),
 		IssuedAt: sessionData.Started, ExpiredAt: sessionData.Expired, Subject: sessionData.UserId,
-		SessionId: sessionData.Id, SessionState: sessionData.Id}
+		SessionId: sessionData.Id, SessionState: sessionData.Id, Confirmation: sessionData.Confirmation}
 	accessToken := data.CreateAccessToken(&jwtCommon, userData)
 	return accessToken
 }
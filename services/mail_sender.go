@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// MailSender is an interface that delivers emails, every realm has own sender address and SMTP server (data.EmailSettings)
+type MailSender interface {
+	// Send delivers message using realm email settings, returns error if message wasn't accepted by mail server
+	Send(settings *data.EmailSettings, message *dto.MailMessage) error
+}
+
+// CreateMailSender creates mail sender instance according to config.MailConfig Sender type
+/* Parameters:
+ *    - cfg - mail config section
+ *    - logger - logger service
+ * Returns: instance of MailSender or error if sender type is not supported
+ */
+func CreateMailSender(cfg *config.MailConfig, logger *logging.AppLogger) (MailSender, error) {
+	switch cfg.Sender {
+	case config.SmtpMailSender:
+		return MailSender(CreateSmtpMailSender(logger)), nil
+	case config.FileMailSender:
+		return MailSender(CreateFileMailSender(cfg.Destination, logger)), nil
+	default:
+		return nil, errors.New(sf.Format("mail sender \"{0}\" is not supported", cfg.Sender))
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/utils/hashing"
+	"github.com/wissance/Ferrum/utils/otp"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// recoveryCodeSize is a number of random bytes of a recovery code (code is shown as xxxx-xxxx-xxxx hex groups)
+const recoveryCodeSize = 6
+
+// CreateOtpCredential creates new user TOTP credential (enrollment) according to realm OTP policy
+/* Credential is not stored by this function, caller must set it to user (data.User SetOtpCredential) and update user. Uri and
+ * recovery codes must be shown to user only once, credential contains only hashes of recovery codes
+ * Parameters:
+ *    - realm - data.Realm with OTP policy, realm name is used as issuer
+ *    - userName - name of user that enrolls OTP
+ * Returns: credential, otpauth:// uri for authenticator app, recovery codes in plain text and error if policy is invalid or
+ *          system random generator is not available
+ */
+func CreateOtpCredential(realm *data.Realm, userName string) (*data.OtpCredential, string, []string, error) {
+	policy := realm.GetOtpPolicy()
+	if !otp.IsAlgorithmSupported(policy.Algorithm) {
+		return nil, "", nil, fmt.Errorf("OTP algorithm \"%s\" is not supported", policy.Algorithm)
+	}
+	secret, err := otp.GenerateSecret()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	credential := data.OtpCredential{Secret: secret}
+	recoveryCodes := make([]string, 0, policy.RecoveryCodes)
+	for i := 0; i < policy.RecoveryCodes; i++ {
+		code, codeErr := generateRecoveryCode()
+		if codeErr != nil {
+			return nil, "", nil, codeErr
+		}
+		recoveryCodes = append(recoveryCodes, code)
+		credential.RecoveryCodes = append(credential.RecoveryCodes, hashing.HashToken(normalizeRecoveryCode(code)))
+	}
+	uri := otp.CreateKeyUri(realm.Name, userName, secret, getOtpSettings(&policy))
+	return &credential, uri, recoveryCodes, nil
+}
+
+// IsOtpSetupRequired checks whether user has no OTP configured but realm OTP policy requires OTP for all users or for user roles
+func IsOtpSetupRequired(realm *data.Realm, user data.User) bool {
+	policy := realm.GetOtpPolicy()
+	return user.GetOtpCredential() == nil && (policy.Required || hasAnyRole(user, policy.RequiredRoles))
+}
+
+// CheckOtp checks second factor of a user that already passed password check
+/* User without OTP configured passes check unless realm OTP policy requires OTP for all users or for user roles. Code is either
+ * TOTP code (every code is accepted only once) or one of recovery codes (used recovery code is removed). Wrong code is counted as
+ * failed login by realm brute-force protection
+ * Parameters:
+ *    - realm - data.Realm with OTP policy
+ *    - user - user that passed password check
+ *    - code - TOTP code or recovery code provided by user
+ *    - address - client ip address (could be empty)
+ * Returns: nil if check passed, otherwise error (data.OperationError) with description
+ */
+func (service *TokenBasedSecurityService) CheckOtp(realm *data.Realm, user data.User, code string, address string) *data.OperationError {
+	policy := realm.GetOtpPolicy()
+	userName := user.GetUsername()
+	if user.GetOtpCredential() == nil {
+		if IsOtpSetupRequired(realm, user) {
+			service.logger.Debug(sf.Format("OTP check: user \"{0}\" has no OTP configured", userName))
+			return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.OtpNotConfiguredDesc}
+		}
+		return nil
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == 0 {
+		return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.OtpRequiredDesc}
+	}
+
+	// credential is re-read and updated under mutex, otherwise parallel requests could use the same code twice
+	service.otpMutex.Lock()
+	defer service.otpMutex.Unlock()
+	storedUser, _ := (*service.DataProvider).GetUser(realm.Name, userName)
+	if storedUser != nil && storedUser.GetOtpCredential() != nil {
+		user = storedUser
+	}
+	credential := user.GetOtpCredential()
+	counter, valid := otp.ValidateCode(credential.Secret, code, otp.GetCounter(time.Now(), policy.Period), policy.LookAhead,
+		getOtpSettings(&policy))
+	if valid && counter > credential.LastCounter {
+		credential.LastCounter = counter
+		service.updateOtpCredential(realm.Name, user, credential)
+		return nil
+	}
+	if !valid {
+		for i, recoveryCodeHash := range credential.RecoveryCodes {
+			if hashing.CheckTokenHash(normalizeRecoveryCode(code), recoveryCodeHash) {
+				credential.RecoveryCodes = append(credential.RecoveryCodes[:i], credential.RecoveryCodes[i+1:]...)
+				service.logger.Info(sf.Format("User \"{0}\" used recovery code, {1} codes left", userName, len(credential.RecoveryCodes)))
+				service.updateOtpCredential(realm.Name, user, credential)
+				return nil
+			}
+		}
+	}
+	service.logger.Trace("OTP check: code mismatch or code was already used")
+	service.registerLoginFailure(realm, userName, address)
+	return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidOtpDesc}
+}
+
+// updateOtpCredential stores used TOTP time step or used recovery code removal, store error is only logged
+func (service *TokenBasedSecurityService) updateOtpCredential(realmName string, user data.User, credential *data.OtpCredential) {
+	userName := user.GetUsername()
+	if err := user.SetOtpCredential(credential); err != nil {
+		service.logger.Warn(sf.Format("OTP credential of user \"{0}\" was not updated: {1}", userName, err.Error()))
+		return
+	}
+	if err := (*service.DataProvider).UpdateUser(realmName, userName, user); err != nil {
+		service.logger.Warn(sf.Format("OTP credential of user \"{0}\" was not stored: {1}", userName, err.Error()))
+	}
+}
+
+// hasAnyRole checks whether user info roles contain any of roles
+func hasAnyRole(user data.User, roles []string) bool {
+	if len(roles) == 0 {
+		return false
+	}
+	info, ok := user.GetUserInfo().(map[string]interface{})
+	if !ok {
+		return false
+	}
+	userRoles, _ := info["roles"].([]interface{})
+	for _, userRole := range userRoles {
+		for _, role := range roles {
+			if userRole == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func getOtpSettings(policy *data.OtpPolicy) otp.Settings {
+	return otp.Settings{Digits: policy.Digits, Period: policy.Period, Algorithm: policy.Algorithm}
+}
+
+func generateRecoveryCode() (string, error) {
+	value := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(value)
+	return code[:4] + "-" + code[4:8] + "-" + code[8:], nil
+}
+
+// normalizeRecoveryCode allows user to enter recovery code without dashes and in any case
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", ""))
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"bufio"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/utils/hashing"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	defaultGeneratedPasswordLength = 12
+	lowerCaseChars                 = "abcdefghijklmnopqrstuvwxyz"
+	upperCaseChars                 = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars                     = "0123456789"
+	specialChars                   = "!#$%&*+-=?@^_~"
+)
+
+// passwordDenylist is a denylist file content, file is re-read when it is modified
+type passwordDenylist struct {
+	modified  time.Time
+	passwords map[string]struct{}
+}
+
+var passwordDenylists = map[string]*passwordDenylist{}
+var passwordDenylistsMutex sync.Mutex
+
+// CheckPasswordPolicy checks new password of a user against realm password policy
+/* This function must be called on every password set (CLI, admin API, self-service), all violated rules are reported at once
+ * Parameters:
+ *    - policy - realm password policy (nil means that realm has no policy and any non-empty password is allowed)
+ *    - user - user whose password is set (username, email and password history are checked), could be nil for a new user
+ *    - password - new password in plain text
+ * Returns: nil if password satisfies policy, otherwise error with descriptions of all violated rules separated by "; "
+ */
+func CheckPasswordPolicy(policy *data.PasswordPolicy, user data.User, password string) *data.OperationError {
+	if len(password) == 0 {
+		return &data.OperationError{Msg: errors.InvalidPasswordMsg, Description: sf.Format(errors.PasswordMinLengthDesc, 1)}
+	}
+	if policy == nil {
+		return nil
+	}
+	var violations []string
+	length := len([]rune(password))
+	if policy.MinLength > 0 && length < policy.MinLength {
+		violations = append(violations, sf.Format(errors.PasswordMinLengthDesc, policy.MinLength))
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		violations = append(violations, sf.Format(errors.PasswordMaxLengthDesc, policy.MaxLength))
+	}
+	digits, lower, upper, special := countPasswordCharClasses(password)
+	if digits < policy.MinDigits {
+		violations = append(violations, sf.Format(errors.PasswordMinDigitsDesc, policy.MinDigits))
+	}
+	if lower < policy.MinLowerCase {
+		violations = append(violations, sf.Format(errors.PasswordMinLowerCaseDesc, policy.MinLowerCase))
+	}
+	if upper < policy.MinUpperCase {
+		violations = append(violations, sf.Format(errors.PasswordMinUpperCaseDesc, policy.MinUpperCase))
+	}
+	if special < policy.MinSpecialChars {
+		violations = append(violations, sf.Format(errors.PasswordMinSpecialCharsDesc, policy.MinSpecialChars))
+	}
+	if user != nil {
+		if policy.NotUsername && strings.EqualFold(password, user.GetUsername()) {
+			violations = append(violations, errors.PasswordIsUsernameDesc)
+		}
+		if policy.NotEmail {
+			email := getUserEmail(user)
+			if len(email) > 0 && strings.EqualFold(password, email) {
+				violations = append(violations, errors.PasswordIsEmailDesc)
+			}
+		}
+		if policy.History > 0 && isPasswordInHistory(user, password, policy.History) {
+			violations = append(violations, sf.Format(errors.PasswordInHistoryDesc, policy.History))
+		}
+	}
+	if len(policy.DenylistFile) > 0 {
+		denylisted, err := isPasswordDenylisted(policy.DenylistFile, password)
+		if err != nil {
+			violations = append(violations, errors.PasswordDenylistErrorDesc)
+		} else if denylisted {
+			violations = append(violations, errors.PasswordIsDenylistedDesc)
+		}
+	}
+	if len(violations) > 0 {
+		return &data.OperationError{Msg: errors.InvalidPasswordMsg, Description: strings.Join(violations, "; ")}
+	}
+	return nil
+}
+
+// IsPasswordExpired checks whether user password is older than policy MaxAge days
+/* Password that was set outside of Ferrum (change time is unknown) never expires
+ * Parameters:
+ *    - policy - realm password policy (could be nil)
+ *    - user - user that logs in
+ * Returns: true if password has expired
+ */
+func IsPasswordExpired(policy *data.PasswordPolicy, user data.User) bool {
+	if policy == nil || policy.MaxAge <= 0 {
+		return false
+	}
+	changed := user.GetPasswordChanged()
+	if changed.IsZero() {
+		return false
+	}
+	return time.Now().After(changed.AddDate(0, 0, policy.MaxAge))
+}
+
+// GeneratePassword generates random password that satisfies password policy length and character classes rules
+/* Password has at least defaultGeneratedPasswordLength characters and always contains characters of each class
+ * Parameters:
+ *    - policy - realm password policy (could be nil)
+ * Returns: password or error if policy rules are contradictory or system random generator is not available
+ */
+func GeneratePassword(policy *data.PasswordPolicy) (string, error) {
+	minCounts := map[string]int{lowerCaseChars: 1, upperCaseChars: 1, digitChars: 1, specialChars: 1}
+	length := defaultGeneratedPasswordLength
+	if policy != nil {
+		policyCounts := map[string]int{lowerCaseChars: policy.MinLowerCase, upperCaseChars: policy.MinUpperCase,
+			digitChars: policy.MinDigits, specialChars: policy.MinSpecialChars}
+		for chars, count := range policyCounts {
+			if count > minCounts[chars] {
+				minCounts[chars] = count
+			}
+		}
+		if policy.MinLength > length {
+			length = policy.MinLength
+		}
+		if policy.MaxLength > 0 && policy.MaxLength < length {
+			length = policy.MaxLength
+		}
+	}
+	required := 0
+	for _, count := range minCounts {
+		required += count
+	}
+	if required > length {
+		return "", fmt.Errorf("password policy requires %d characters of different classes but allows only %d", required, length)
+	}
+	password := make([]byte, 0, length)
+	for chars, count := range minCounts {
+		for i := 0; i < count; i++ {
+			c, err := getRandomChar(chars)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+	allChars := lowerCaseChars + upperCaseChars + digitChars + specialChars
+	for len(password) < length {
+		c, err := getRandomChar(allChars)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	// Fisher-Yates shuffle, otherwise characters of each class are grouped together
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func countPasswordCharClasses(password string) (int, int, int, int) {
+	var digits, lower, upper, special int
+	for _, r := range password {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+		case unicode.IsLower(r):
+			lower++
+		case unicode.IsUpper(r):
+			upper++
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			special++
+		}
+	}
+	return digits, lower, upper, special
+}
+
+func getUserEmail(user data.User) string {
+	info, ok := user.GetUserInfo().(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	email, _ := info["email"].(string)
+	return email
+}
+
+// isPasswordInHistory checks password against current password and depth - 1 previous passwords
+func isPasswordInHistory(user data.User, password string, depth int) bool {
+	passwordHashes := append([]string{user.GetPassword()}, user.GetPasswordHistory()...)
+	if len(passwordHashes) > depth {
+		passwordHashes = passwordHashes[:depth]
+	}
+	for _, passwordHash := range passwordHashes {
+		if matches, _ := hashing.CheckPassword(password, passwordHash); matches {
+			return true
+		}
+	}
+	return false
+}
+
+// isPasswordDenylisted checks password (case-insensitive) against denylist file, file is cached until it is modified
+func isPasswordDenylisted(denylistFile string, password string) (bool, error) {
+	info, err := os.Stat(denylistFile)
+	if err != nil {
+		return false, err
+	}
+	passwordDenylistsMutex.Lock()
+	defer passwordDenylistsMutex.Unlock()
+	denylist, ok := passwordDenylists[denylistFile]
+	if !ok || !denylist.modified.Equal(info.ModTime()) {
+		denylist, err = readPasswordDenylist(denylistFile, info.ModTime())
+		if err != nil {
+			return false, err
+		}
+		passwordDenylists[denylistFile] = denylist
+	}
+	_, denylisted := denylist.passwords[strings.ToLower(password)]
+	return denylisted, nil
+}
+
+func readPasswordDenylist(denylistFile string, modified time.Time) (*passwordDenylist, error) {
+	file, err := os.Open(denylistFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	denylist := passwordDenylist{modified: modified, passwords: map[string]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) > 0 {
+			denylist.passwords[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return &denylist, scanner.Err()
+}
+
+func getRandomChar(chars string) (byte, error) {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+	if err != nil {
+		return 0, err
+	}
+	return chars[index.Int64()], nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	denylistFile := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(denylistFile, []byte("Qwerty123!\npassword\n"), 0600))
+	user := data.CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "Vano.Ivanov1!",
+		"email": "vano@ferrum.test"}, "credentials": map[string]interface{}{}})
+	require.NoError(t, user.SetPassword("Previous#Password1"))
+	require.NoError(t, user.SetPassword("Current#Password1"))
+
+	testCases := []struct {
+		name               string
+		policy             *data.PasswordPolicy
+		password           string
+		expectedViolations []string
+	}{
+		{name: "without_policy", password: "1"},
+		{name: "empty_password_without_policy", password: "", expectedViolations: []string{sf.Format(errors.PasswordMinLengthDesc, 1)}},
+		{name: "valid_password", policy: &data.PasswordPolicy{MinLength: 8, MaxLength: 20, MinDigits: 1, MinLowerCase: 1,
+			MinUpperCase: 1, MinSpecialChars: 1, NotUsername: true, NotEmail: true, History: 3, DenylistFile: denylistFile},
+			password: "Valid#Password1"},
+		{name: "length", policy: &data.PasswordPolicy{MinLength: 8, MaxLength: 10}, password: "short",
+			expectedViolations: []string{sf.Format(errors.PasswordMinLengthDesc, 8)}},
+		{name: "max_length", policy: &data.PasswordPolicy{MinLength: 8, MaxLength: 10}, password: "very_long_password",
+			expectedViolations: []string{sf.Format(errors.PasswordMaxLengthDesc, 10)}},
+		{name: "character_classes", policy: &data.PasswordPolicy{MinDigits: 2, MinLowerCase: 1, MinUpperCase: 1, MinSpecialChars: 1},
+			password: "password1", expectedViolations: []string{sf.Format(errors.PasswordMinDigitsDesc, 2),
+				sf.Format(errors.PasswordMinUpperCaseDesc, 1), sf.Format(errors.PasswordMinSpecialCharsDesc, 1)}},
+		{name: "not_username", policy: &data.PasswordPolicy{NotUsername: true}, password: "vano.ivanov1!",
+			expectedViolations: []string{errors.PasswordIsUsernameDesc}},
+		{name: "not_email", policy: &data.PasswordPolicy{NotEmail: true}, password: "VANO@ferrum.test",
+			expectedViolations: []string{errors.PasswordIsEmailDesc}},
+		{name: "current_password", policy: &data.PasswordPolicy{History: 1}, password: "Current#Password1",
+			expectedViolations: []string{sf.Format(errors.PasswordInHistoryDesc, 1)}},
+		{name: "previous_password", policy: &data.PasswordPolicy{History: 2}, password: "Previous#Password1",
+			expectedViolations: []string{sf.Format(errors.PasswordInHistoryDesc, 2)}},
+		{name: "previous_password_beyond_history", policy: &data.PasswordPolicy{History: 1}, password: "Previous#Password1"},
+		{name: "denylisted_password", policy: &data.PasswordPolicy{DenylistFile: denylistFile}, password: "qwerty123!",
+			expectedViolations: []string{errors.PasswordIsDenylistedDesc}},
+		{name: "missing_denylist_file", policy: &data.PasswordPolicy{DenylistFile: denylistFile + ".missing"}, password: "qwerty123!",
+			expectedViolations: []string{errors.PasswordDenylistErrorDesc}},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			check := CheckPasswordPolicy(tc.policy, user, tc.password)
+			if len(tc.expectedViolations) == 0 {
+				assert.Nil(t, check)
+				return
+			}
+			require.NotNil(t, check)
+			assert.Equal(t, errors.InvalidPasswordMsg, check.Msg)
+			assert.Equal(t, strings.Join(tc.expectedViolations, "; "), check.Description)
+		})
+	}
+}
+
+func TestIsPasswordExpired(t *testing.T) {
+	user := data.CreateUser(map[string]interface{}{"info": map[string]interface{}{"preferred_username": "vano"},
+		"credentials": map[string]interface{}{"password": "1234567890"}})
+	policy := &data.PasswordPolicy{MaxAge: 30}
+	// password change time is unknown
+	assert.False(t, IsPasswordExpired(policy, user))
+
+	require.NoError(t, user.SetPassword("1234567890"))
+	assert.False(t, IsPasswordExpired(policy, user))
+	assert.False(t, IsPasswordExpired(nil, user))
+
+	credentials := user.GetRawData().(map[string]interface{})["credentials"].(map[string]interface{})
+	credentials["changed"] = time.Now().AddDate(0, 0, -31).Unix()
+	assert.True(t, IsPasswordExpired(policy, user))
+}
+
+func TestGeneratePassword(t *testing.T) {
+	testCases := []struct {
+		name           string
+		policy         *data.PasswordPolicy
+		expectedLength int
+		expectedError  bool
+	}{
+		{name: "without_policy", expectedLength: defaultGeneratedPasswordLength},
+		{name: "long_password", policy: &data.PasswordPolicy{MinLength: 20, MinDigits: 3, MinSpecialChars: 2}, expectedLength: 20},
+		{name: "short_max_length", policy: &data.PasswordPolicy{MaxLength: 8}, expectedLength: 8},
+		{name: "contradictory_policy", policy: &data.PasswordPolicy{MaxLength: 4, MinDigits: 4}, expectedError: true},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			password, err := GeneratePassword(tc.policy)
+			if tc.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedLength, len(password))
+			assert.Nil(t, CheckPasswordPolicy(tc.policy, nil, password))
+			assert.Nil(t, CheckPasswordPolicy(&data.PasswordPolicy{MinDigits: 1, MinLowerCase: 1, MinUpperCase: 1, MinSpecialChars: 1},
+				nil, password))
+		})
+	}
+}
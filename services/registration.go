@@ -0,0 +1,75 @@
+package services
+
+import (
+	e "errors"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// RegisterUser creates user from self-service registration request
+/* Attributes are validated against realm user profile (read-only attributes are not accepted), password against realm password policy, user gets generated identifier
+ * (info.sub) and password hash. If realm requires email verification user gets VERIFY_EMAIL required action (caller sends
+ * verification link)
+ * Parameters:
+ *    - realm - realm that allows registration
+ *    - registration - user attributes and password
+ * Returns: created user or error
+ */
+func (service *TokenBasedSecurityService) RegisterUser(realm *data.Realm, registration *dto.RegistrationRequest) (data.User, *data.OperationError) {
+	if !realm.RegistrationAllowed {
+		return nil, &data.OperationError{Msg: errors.AccessDeniedMsg, Description: errors.RegistrationNotAllowedDesc}
+	}
+	profile := realm.GetUserProfile()
+	attributes := map[string]string{}
+	for name, value := range registration.Attributes {
+		if value = strings.TrimSpace(value); len(value) > 0 {
+			if attribute := profile.FindAttribute(name); attribute != nil && attribute.ReadOnly {
+				return nil, &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: sf.Format(errors.AttributeNotAllowedDesc, name)}
+			}
+			attributes[name] = value
+		}
+	}
+	if check := ValidateUserAttributes(profile, attributes); check != nil {
+		return nil, check
+	}
+	if realm.VerifyEmail && len(attributes[data.EmailAttribute]) == 0 {
+		return nil, &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: sf.Format(errors.AttributeRequiredDesc, data.EmailAttribute)}
+	}
+
+	info := ParseUserAttributes(profile, attributes)
+	info[data.SubAttribute] = uuid.New().String()
+	if len(attributes[data.EmailAttribute]) > 0 {
+		info[data.EmailVerifiedAttribute] = false
+	}
+	user := data.CreateUser(map[string]interface{}{"info": info, "credentials": map[string]interface{}{}})
+	if check := CheckPasswordPolicy(realm.PasswordPolicy, user, registration.Password); check != nil {
+		return nil, check
+	}
+	if err := user.SetPassword(registration.Password); err != nil {
+		service.logger.Error(sf.Format("Registration: password of user \"{0}\" was not set: {1}", user.GetUsername(), err.Error()))
+		return nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if realm.VerifyEmail {
+		if err := data.AddRequiredAction(user, data.VerifyEmailAction); err != nil {
+			service.logger.Error(sf.Format("Registration: required action of user \"{0}\" was not set: {1}", user.GetUsername(), err.Error()))
+			return nil, &data.OperationError{Msg: errors.OtherAppError}
+		}
+	}
+	if err := (*service.DataProvider).CreateUser(realm.Name, user); err != nil {
+		if e.As(err, &errors.ErrExists) {
+			return nil, &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: errors.UsernameExistsDesc}
+		}
+		if check := getUserValidationError(err); check != nil {
+			return nil, check
+		}
+		service.logger.Error(sf.Format("Registration: user \"{0}\" was not created: {1}", user.GetUsername(), err.Error()))
+		return nil, &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	service.logger.Info(sf.Format("User \"{0}\" registered in realm \"{1}\"", user.GetUsername(), realm.Name))
+	return user, nil
+}
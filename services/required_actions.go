@@ -0,0 +1,73 @@
+package services
+
+import (
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// ExecuteRequiredAction executes pending required action of user that already passed credentials check
+/* VERIFY_EMAIL action is not executed here, it is satisfied only via email link (EmailActionService). UPDATE_PASSWORD is also
+ * executed for user with expired password and CONFIGURE_TOTP for user that realm OTP policy requires to have OTP (see
+ * IsOtpSetupRequired) even if user doesn't have these actions
+ * Parameters:
+ *    - realm - data.Realm with password and OTP policies
+ *    - user - authenticated user
+ *    - actionRequest - action with action data (new password or terms acceptance)
+ * Returns: executed action and remaining actions (with OTP uri and recovery codes for CONFIGURE_TOTP) or error
+ */
+func (service *TokenBasedSecurityService) ExecuteRequiredAction(realm *data.Realm, user data.User,
+	actionRequest *dto.RequiredActionRequest) (*dto.RequiredActionResult, *data.OperationError) {
+	action := actionRequest.Action
+	if !data.IsRequiredActionSupported(action) || action == data.VerifyEmailAction {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.UnsupportedActionDesc}
+	}
+	passwordExpired := action == data.UpdatePasswordAction && IsPasswordExpired(realm.PasswordPolicy, user)
+	otpSetupRequired := action == data.ConfigureTotpAction && IsOtpSetupRequired(realm, user)
+	if !data.HasRequiredAction(user, action) && !passwordExpired && !otpSetupRequired {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.ActionNotRequiredDesc}
+	}
+	userName := user.GetUsername()
+	result := dto.RequiredActionResult{Username: userName, ExecutedAction: action}
+	switch action {
+	case data.UpdatePasswordAction:
+		if len(actionRequest.NewPassword) == 0 {
+			return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.PasswordRequiredDesc}
+		}
+		if check := CheckPasswordPolicy(realm.PasswordPolicy, user, actionRequest.NewPassword); check != nil {
+			return nil, check
+		}
+		if err := user.SetPassword(actionRequest.NewPassword); err != nil {
+			service.logger.Error(sf.Format("Required action: password of user \"{0}\" was not set: {1}", userName, err.Error()))
+			return nil, &data.OperationError{Msg: errors.OtherAppError}
+		}
+	case data.ConfigureTotpAction:
+		credential, uri, recoveryCodes, err := CreateOtpCredential(realm, userName)
+		if err != nil {
+			service.logger.Error(sf.Format("Required action: OTP of user \"{0}\" was not created: {1}", userName, err.Error()))
+			return nil, &data.OperationError{Msg: errors.OtherAppError}
+		}
+		if err = user.SetOtpCredential(credential); err != nil {
+			service.logger.Error(sf.Format("Required action: OTP of user \"{0}\" was not set: {1}", userName, err.Error()))
+			return nil, &data.OperationError{Msg: errors.OtherAppError}
+		}
+		result.OtpUri = uri
+		result.RecoveryCodes = recoveryCodes
+	case data.TermsAndConditionsAction:
+		if !actionRequest.Accept {
+			return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.TermsNotAcceptedDesc}
+		}
+	}
+	if _, err := data.RemoveRequiredAction(user, action); err != nil {
+		service.logger.Error(sf.Format("Required action: action of user \"{0}\" was not removed: {1}", userName, err.Error()))
+		return nil, &data.OperationError{Msg: errors.OtherAppError}
+	}
+	if err := (*service.DataProvider).UpdateUser(realm.Name, userName, user); err != nil {
+		service.logger.Error(sf.Format("Required action: user \"{0}\" was not stored: {1}", userName, err.Error()))
+		return nil, &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	service.logger.Info(sf.Format("User \"{0}\" executed required action {1}", userName, action))
+	result.RequiredActions = user.GetRequiredActions()
+	return &result, nil
+}
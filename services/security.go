@@ -11,7 +11,35 @@ type SecurityService interface {
 	// Validate checks whether provided tokenIssueData could be used for token generation or not
 	Validate(tokenIssueData *dto.TokenGenerationData, realm *data.Realm) *data.OperationError
 	// CheckCredentials validates provided in tokenIssueData pairs of clientId+clientSecret and username+password
-	CheckCredentials(tokenIssueData *dto.TokenGenerationData, realmName string) *data.OperationError
+	CheckCredentials(tokenIssueData *dto.TokenGenerationData, realm *data.Realm) *data.OperationError
+	// CheckUserState checks that realm and user are enabled and user is valid at this time (not_before and valid_until)
+	CheckUserState(realm *data.Realm, user data.User) *data.OperationError
+	// CheckOtp validates TOTP code or recovery code of user that already passed password check
+	CheckOtp(realm *data.Realm, user data.User, code string, address string) *data.OperationError
+	// StartWebAuthnRegistration issues passkey registration options (challenge) for authenticated user
+	StartWebAuthnRegistration(realm *data.Realm, user data.User) (*dto.PublicKeyCredentialCreationOptions, *data.OperationError)
+	// FinishWebAuthnRegistration verifies passkey registration response and stores passkey in user credentials
+	FinishWebAuthnRegistration(realm *data.Realm, user data.User, registration *dto.WebAuthnRegistration) *data.OperationError
+	// StartWebAuthnLogin issues passkey login options (challenge), userName is optional
+	StartWebAuthnLogin(realm *data.Realm, userName string) (*dto.PublicKeyCredentialRequestOptions, *data.OperationError)
+	// CheckWebAuthnAssertion verifies passkey login response and returns user that owns passkey
+	CheckWebAuthnAssertion(realm *data.Realm, assertion *dto.PublicKeyCredential, address string) (data.User, *data.OperationError)
+	// ExecuteRequiredAction executes pending required action (UPDATE_PASSWORD, CONFIGURE_TOTP, TERMS_AND_CONDITIONS) of authenticated user
+	ExecuteRequiredAction(realm *data.Realm, user data.User, actionRequest *dto.RequiredActionRequest) (*dto.RequiredActionResult, *data.OperationError)
+	// RegisterUser creates user from self-service registration request (realm user profile attributes and password)
+	RegisterUser(realm *data.Realm, registration *dto.RegistrationRequest) (data.User, *data.OperationError)
+	// GrantConsent adds requested scopes to user consent of client and stores user
+	GrantConsent(realm *data.Realm, user data.User, clientId string, scope string) *data.OperationError
+	// RevokeConsent removes user consent of client and stores user
+	RevokeConsent(realm *data.Realm, user data.User, clientId string) *data.OperationError
+	// UpdateAccountProfile updates user info attributes of authenticated user (account self-service)
+	UpdateAccountProfile(realm *data.Realm, user data.User, attributes map[string]string) *data.OperationError
+	// ChangePassword changes password of authenticated user after current password check
+	ChangePassword(realm *data.Realm, user data.User, passwordChange *dto.PasswordChangeRequest, address string) *data.OperationError
+	// EnrollOtp creates OTP credential of authenticated user that doesn't have OTP yet
+	EnrollOtp(realm *data.Realm, user data.User) (*dto.OtpEnrollment, *data.OperationError)
+	// RemoveOtp removes OTP credential of authenticated user if realm OTP policy doesn't require it
+	RemoveOtp(realm *data.Realm, user data.User) *data.OperationError
 	// GetCurrentUserByName return CurrentUser data by name
 	GetCurrentUserByName(realmName string, userName string) data.User
 	// GetCurrentUserById return CurrentUser data by id
@@ -20,12 +48,36 @@ type SecurityService interface {
 	StartOrUpdateSession(realm string, userId uuid.UUID, duration int, refresh int) uuid.UUID
 	// AssignTokens this function creates relation between userId and issued tokens (access and refresh)
 	AssignTokens(realm string, userId uuid.UUID, accessToken *string, refreshToken *string)
+	// CheckDPoPProof validates DPoP proof (RFC 9449) for request and returns JWK thumbprint of proof key
+	CheckDPoPProof(proof string, httpMethod string, httpUris []string, accessToken string) (string, *data.OperationError)
+	// AssignTokenConfirmation binds session tokens to client certificate (RFC 8705) or DPoP key (RFC 9449), nil confirmation removes binding
+	AssignTokenConfirmation(realm string, userId uuid.UUID, confirmation *data.TokenConfirmation)
 	// GetSession returns user session data
 	GetSession(realm string, userId uuid.UUID) *data.UserSession
+	// GetUserSessions returns active sessions of user
+	GetUserSessions(realm string, userId uuid.UUID) []data.UserSession
+	// DeleteUserSession removes (signs out) user session, returns false if user has no such session
+	DeleteUserSession(realm string, userId uuid.UUID, sessionId uuid.UUID) bool
 	// GetSessionByAccessToken returns session data by access token
 	GetSessionByAccessToken(realm string, token *string) *data.UserSession
 	// GetSessionByRefreshToken returns session data by access token
 	GetSessionByRefreshToken(realm string, token *string) *data.UserSession
 	// CheckSessionAndRefreshExpired checks is user tokens expired or not (could user use them or should get new ones)
 	CheckSessionAndRefreshExpired(realm string, userId uuid.UUID) (bool, bool)
+	// StorePushedAuthorizationRequest saves authorization request parameters pushed by client (RFC 9126) and returns request_uri
+	StorePushedAuthorizationRequest(realm string, authRequest *dto.AuthorizationRequest, lifetime int) string
+	// GetPushedAuthorizationRequest returns (and removes, request_uri is a one-time value) pushed authorization request
+	GetPushedAuthorizationRequest(realm string, clientId string, requestUri string) *dto.AuthorizationRequest
+	// StoreConsentRequest saves authorization request shown on consent screen for user session and returns consent form reference
+	StoreConsentRequest(realm string, authRequest *dto.AuthorizationRequest, sessionId uuid.UUID, lifetime int) string
+	// GetConsentRequest returns (and removes, reference is a one-time value) authorization request of consent form of user session
+	GetConsentRequest(realm string, clientId string, reference string, sessionId uuid.UUID) *dto.AuthorizationRequest
+	// StartSsoSession starts user session after browser login and returns identity cookie value
+	StartSsoSession(realm *data.Realm, userId uuid.UUID) (string, error)
+	// GetSessionByIdentity returns session data by identity cookie value (nil if browser login expired)
+	GetSessionByIdentity(realm string, identity string) *data.UserSession
+	// StoreAuthorizationCode saves data of code issued by authorization endpoint and returns code value
+	StoreAuthorizationCode(realm string, code *data.AuthorizationCode, lifetime int) string
+	// ExchangeAuthorizationCode returns (and removes, code is a one-time value) code data, checks client, redirect_uri and PKCE
+	ExchangeAuthorizationCode(realm string, clientId string, code string, redirectUri string, codeVerifier string) (*data.AuthorizationCode, *data.OperationError)
 }
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SmtpMailSender is MailSender that delivers messages to SMTP server from realm email settings
+type SmtpMailSender struct {
+	logger *logging.AppLogger
+}
+
+// CreateSmtpMailSender creates SmtpMailSender
+func CreateSmtpMailSender(logger *logging.AppLogger) *SmtpMailSender {
+	return &SmtpMailSender{logger: logger}
+}
+
+// Send connects to SMTP server (with implicit TLS or STARTTLS if configured), authenticates and sends message
+/* Credentials are sent only over TLS connection (or to localhost), this is a net/smtp PLAIN authentication restriction
+ * Parameters:
+ *    - settings - realm email settings with SMTP server, credentials and sender address
+ *    - message - plain text message, From value is ignored (settings From and FromDisplayName are used)
+ * Returns: nil if message was accepted by SMTP server
+ */
+func (sender *SmtpMailSender) Send(settings *data.EmailSettings, message *dto.MailMessage) error {
+	from, to, err := parseMailAddresses(settings, message)
+	if err != nil {
+		return err
+	}
+	content, err := buildMailContent(settings, message, from, to)
+	if err != nil {
+		return err
+	}
+	client, err := sender.connect(settings)
+	if err != nil {
+		sender.logger.Error(sf.Format("An error occurred during SMTP server \"{0}\" connection: {1}", settings.Host, err.Error()))
+		return err
+	}
+	defer client.Close()
+	if err = sendMailContent(client, settings, from.Address, to.Address, content); err != nil {
+		sender.logger.Error(sf.Format("An error occurred during email sending via SMTP server \"{0}\": {1}", settings.Host, err.Error()))
+		return err
+	}
+	return nil
+}
+
+// connect opens SMTP session, with Ssl connection is TLS from the beginning, with StartTls connection is upgraded to TLS
+func (sender *SmtpMailSender) connect(settings *data.EmailSettings) (*smtp.Client, error) {
+	address := net.JoinHostPort(settings.Host, strconv.Itoa(settings.GetPort()))
+	tlsConfig := &tls.Config{ServerName: settings.Host, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if settings.Ssl {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, settings.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if settings.StartTls && !settings.Ssl {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP server doesn't support STARTTLS")
+		}
+		if err = client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// sendMailContent authenticates (if settings have credentials) and sends message content, from and to are envelope addresses
+// without display names (MAIL FROM and RCPT TO commands accept only address)
+func sendMailContent(client *smtp.Client, settings *data.EmailSettings, from string, to string, content []byte) error {
+	if len(settings.Username) > 0 {
+		if err := client.Auth(smtp.PlainAuth("", settings.Username, settings.Password, settings.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(content); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// parseMailAddresses validates sender and recipient addresses (that prevents headers injection), addresses could have display
+// names, sender display name is FromDisplayName, name from sender address or realm name (the first that is set)
+func parseMailAddresses(settings *data.EmailSettings, message *dto.MailMessage) (*mail.Address, *mail.Address, error) {
+	from, err := mail.ParseAddress(settings.From)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sender address is invalid: %w", err)
+	}
+	if len(settings.FromDisplayName) > 0 {
+		from.Name = settings.FromDisplayName
+	}
+	if len(from.Name) == 0 {
+		from.Name = message.Realm
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return nil, nil, fmt.Errorf("recipient address is invalid: %w", err)
+	}
+	return from, to, nil
+}
+
+// buildMailContent creates RFC 5322 message with quoted-printable UTF-8 text body from validated addresses (see parseMailAddresses)
+func buildMailContent(settings *data.EmailSettings, message *dto.MailMessage, from *mail.Address, to *mail.Address) ([]byte, error) {
+	messageId := make([]byte, 16)
+	if _, err := rand.Read(messageId); err != nil {
+		return nil, err
+	}
+	var content bytes.Buffer
+	content.WriteString("From: " + from.String() + "\r\n")
+	content.WriteString("To: " + to.String() + "\r\n")
+	if len(settings.ReplyTo) > 0 {
+		replyTo, replyToErr := mail.ParseAddress(settings.ReplyTo)
+		if replyToErr != nil {
+			return nil, fmt.Errorf("reply address is invalid: %w", replyToErr)
+		}
+		content.WriteString("Reply-To: " + replyTo.String() + "\r\n")
+	}
+	content.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", strings.ReplaceAll(message.Subject, "\n", " ")) + "\r\n")
+	content.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	content.WriteString("Message-ID: <" + hex.EncodeToString(messageId) + "@" + from.Address[strings.LastIndex(from.Address, "@")+1:] + ">\r\n")
+	content.WriteString("MIME-Version: 1.0\r\n")
+	content.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	content.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	bodyWriter := quotedprintable.NewWriter(&content)
+	if _, err := bodyWriter.Write([]byte(strings.ReplaceAll(message.Body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := bodyWriter.Close(); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
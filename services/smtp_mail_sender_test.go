@@ -0,0 +1,121 @@
+package services
+
+import (
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/logging"
+)
+
+// smtpSession is what fake SMTP server received
+type smtpSession struct {
+	from string
+	to   string
+	data string
+}
+
+func TestSmtpMailSender(t *testing.T) {
+	port, sessions := startFakeSmtp(t)
+	logger := logging.CreateLogger(&config.LoggingConfig{Level: "info"})
+	sender := CreateSmtpMailSender(logger)
+	settings := data.EmailSettings{From: "noreply@ferrum.test", FromDisplayName: "Ferrum", Host: "127.0.0.1", Port: port}
+	message := dto.MailMessage{Realm: "myapp", To: "vano@ferrum.test", Subject: "Проверка email",
+		Body: "Click the link below\n\nhttps://auth.ferrum.test/auth/realms/myapp/login-actions/action-token?key=abc=def\n"}
+	require.NoError(t, sender.Send(&settings, &message))
+
+	session := <-sessions
+	assert.Equal(t, "<noreply@ferrum.test>", session.from)
+	assert.Equal(t, "<vano@ferrum.test>", session.to)
+	headers, body, _ := strings.Cut(session.data, "\r\n\r\n")
+	assert.Contains(t, headers, "From: \"Ferrum\" <noreply@ferrum.test>")
+	assert.Contains(t, headers, "To: <vano@ferrum.test>")
+	assert.Contains(t, headers, "Subject: =?utf-8?q?")
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	assert.Contains(t, string(decoded), "key=abc=def")
+
+	// header injection via recipient is not possible
+	message.To = "vano@ferrum.test\r\nBcc: all@ferrum.test"
+	assert.Error(t, sender.Send(&settings, &message))
+}
+
+func TestSmtpMailSenderWithDisplayNames(t *testing.T) {
+	port, sessions := startFakeSmtp(t)
+	logger := logging.CreateLogger(&config.LoggingConfig{Level: "info"})
+	sender := CreateSmtpMailSender(logger)
+	settings := data.EmailSettings{From: "Ferrum <noreply@ferrum.test>", Host: "127.0.0.1", Port: port}
+	message := dto.MailMessage{Realm: "myapp", To: "Vano <vano@ferrum.test>", Subject: "Test", Body: "Text"}
+	require.NoError(t, sender.Send(&settings, &message))
+
+	// envelope has only addresses, headers have display names
+	session := <-sessions
+	assert.Equal(t, "<noreply@ferrum.test>", session.from)
+	assert.Equal(t, "<vano@ferrum.test>", session.to)
+	headers, _, _ := strings.Cut(session.data, "\r\n\r\n")
+	assert.Contains(t, headers, "From: \"Ferrum\" <noreply@ferrum.test>")
+	assert.Contains(t, headers, "To: \"Vano\" <vano@ferrum.test>")
+}
+
+// startFakeSmtp starts fake SMTP server for one session, returns server port and channel with received session
+func startFakeSmtp(t *testing.T) (int, <-chan smtpSession) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	sessions := make(chan smtpSession, 1)
+	go serveFakeSmtp(listener, sessions)
+	port, _ := strconv.Atoi(strings.Split(listener.Addr().String(), ":")[1])
+	return port, sessions
+}
+
+// serveFakeSmtp accepts one connection and answers with minimal SMTP dialog (RFC 5321) without extensions
+func serveFakeSmtp(listener net.Listener, sessions chan<- smtpSession) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	session := smtpSession{}
+	_ = text.PrintfLine("220 fake.smtp ESMTP")
+	for {
+		line, readErr := text.ReadLine()
+		if readErr != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			_ = text.PrintfLine("250 fake.smtp")
+		case "MAIL":
+			session.from = strings.TrimPrefix(line, "MAIL FROM:")
+			_ = text.PrintfLine("250 OK")
+		case "RCPT":
+			session.to = strings.TrimPrefix(line, "RCPT TO:")
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 Start mail input")
+			lines, dataErr := text.ReadDotLines()
+			if dataErr != nil {
+				return
+			}
+			session.data = strings.Join(lines, "\r\n")
+			_ = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 Bye")
+			sessions <- session
+			return
+		default:
+			_ = text.PrintfLine("502 Command not implemented")
+		}
+	}
+}
@@ -1,45 +1,105 @@
 package services
 
 import (
+	"crypto/subtle"
+	"crypto/x509"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/wissance/Ferrum/data"
 	"github.com/wissance/Ferrum/dto"
 	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/globals"
 	"github.com/wissance/Ferrum/logging"
 	"github.com/wissance/Ferrum/managers"
+	"github.com/wissance/Ferrum/utils/hashing"
+	sf "github.com/wissance/stringFormatter"
 )
 
+// pushedAuthorizationRequest is a stored PAR request, it lives in memory like sessions do
+type pushedAuthorizationRequest struct {
+	request *dto.AuthorizationRequest
+	expired time.Time
+}
+
 // TokenBasedSecurityService structure that implements SecurityService
 type TokenBasedSecurityService struct {
 	DataProvider *managers.DataContext
-	UserSessions map[string][]data.UserSession
-	logger       *logging.AppLogger
+	// UserSessions are realm users sessions (realm -> sessions), they are read and changed under sessionsMutex
+	UserSessions   map[string][]data.UserSession
+	sessionsMutex  sync.RWMutex
+	pushedRequests map[string]map[string]pushedAuthorizationRequest
+	parMutex       sync.Mutex
+	// consentRequests are authorization requests shown on consent screen (realm -> consent form reference -> request)
+	consentRequests map[string]map[string]consentRequest
+	consentMutex    sync.Mutex
+	// usedAssertions is a jti -> expiration map of client assertions and DPoP proofs that were already used (both are one-time values)
+	usedAssertions  map[string]time.Time
+	assertionsMutex sync.Mutex
+	// otpMutex serializes TOTP credentials updates (used time steps and recovery codes)
+	otpMutex sync.Mutex
+	// webAuthnCeremonies are issued passkey registration and login challenges (realm -> challenge -> ceremony)
+	webAuthnCeremonies map[string]map[string]webAuthnCeremony
+	webAuthnMutex      sync.Mutex
+	// authorizationCodes are issued by authorization endpoint codes (realm -> code -> code data)
+	authorizationCodes map[string]map[string]data.AuthorizationCode
+	codesMutex         sync.Mutex
+	// clientCertificateRoots are CA certificates that issue tls_client_auth client certificates, nil means system pool
+	clientCertificateRoots *x509.CertPool
+	logger                 *logging.AppLogger
 }
 
 // CreateSecurityService creates instance of TokenBasedSecurityService as SecurityService
 /* This function creates SecurityService based on dataProvider as managers.DataContext
  * Parameters:
  *    - dataProvider - any managers.DataContext implementation (config.FILE, config.REDIS)
+ *    - clientCertificateRoots - CA certificates for tls_client_auth client certificates verification (nil - system pool is used)
  *    - logger - logger service
  * Returns instance of TokenBasedSecurityService as SecurityService
  */
-func CreateSecurityService(dataProvider *managers.DataContext, logger *logging.AppLogger) SecurityService {
-	pwdSecService := &TokenBasedSecurityService{DataProvider: dataProvider, UserSessions: map[string][]data.UserSession{}, logger: logger}
+func CreateSecurityService(dataProvider *managers.DataContext, clientCertificateRoots *x509.CertPool, logger *logging.AppLogger) SecurityService {
+	pwdSecService := &TokenBasedSecurityService{DataProvider: dataProvider, UserSessions: map[string][]data.UserSession{},
+		pushedRequests: map[string]map[string]pushedAuthorizationRequest{}, usedAssertions: map[string]time.Time{},
+		consentRequests:        map[string]map[string]consentRequest{},
+		webAuthnCeremonies:     map[string]map[string]webAuthnCeremony{},
+		authorizationCodes:     map[string]map[string]data.AuthorizationCode{},
+		clientCertificateRoots: clientCertificateRoots, logger: logger}
 	secService := SecurityService(pwdSecService)
 	return secService
 }
 
 // Validate functions that check whether provided clientId and clientSecret valid or not
 /* First this function get find data.Realm data.Client by clientId, if client is data.Public there is nothing to do, for confidential
- * clients function checks provided clientSecret
+ * clients function checks provided clientSecret, client_assertion (client_secret_jwt and private_key_jwt authentication) or
+ * client certificate (tls_client_auth and self_signed_tls_client_auth authentication)
  * Parameters:
  *    - tokenIssueData data required for issue new token
- *    - realm - obtained from managers.DataContext realm
+ *    - realm - obtained from managers.DataContext realm, realm and client must be enabled
  * Returns: nil if Validation passed, otherwise error (data.OperationError) with description
  */
 func (service *TokenBasedSecurityService) Validate(tokenIssueData *dto.TokenGenerationData, realm *data.Realm) *data.OperationError {
+	if !realm.IsEnabled() {
+		return &data.OperationError{Msg: errors.AccessDeniedMsg, Description: errors.RealmDisabledDesc}
+	}
+	if check := service.validateClient(tokenIssueData, realm); check != nil {
+		return check
+	}
+	// client state is checked after authentication, therefore only client itself knows that it is disabled
+	for _, c := range realm.Clients {
+		if c.Name == tokenIssueData.ClientId && !c.IsEnabled() {
+			service.logger.Debug(sf.Format("Client \"{0}\" is disabled", c.Name))
+			return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.ClientDisabledDesc}
+		}
+	}
+	return nil
+}
+
+// validateClient authenticates client with client secret, client_assertion or client certificate
+func (service *TokenBasedSecurityService) validateClient(tokenIssueData *dto.TokenGenerationData, realm *data.Realm) *data.OperationError {
+	if len(tokenIssueData.ClientAssertionType) > 0 || len(tokenIssueData.ClientAssertion) > 0 {
+		return service.validateClientAssertion(tokenIssueData, realm)
+	}
 	for _, c := range realm.Clients {
 		if c.Name == tokenIssueData.ClientId {
 			if c.Type == data.Public {
@@ -48,7 +108,11 @@ func (service *TokenBasedSecurityService) Validate(tokenIssueData *dto.TokenGene
 			}
 
 			// here we make deal with confidential client
-			if c.Auth.Type == data.ClientIdAndSecrets && c.Auth.Value == tokenIssueData.ClientSecret {
+			if c.Auth.IsCertificateBased() {
+				return service.validateClientCertificate(&c, tokenIssueData)
+			}
+			if c.Auth.Type == data.ClientIdAndSecrets && len(c.Auth.Value) > 0 &&
+				subtle.ConstantTimeCompare([]byte(c.Auth.Value), []byte(tokenIssueData.ClientSecret)) == 1 {
 				service.logger.Trace("Private client was successfully validated")
 				return nil
 			}
@@ -58,29 +122,76 @@ func (service *TokenBasedSecurityService) Validate(tokenIssueData *dto.TokenGene
 	return &data.OperationError{Msg: errors.InvalidClientMsg, Description: errors.InvalidClientCredentialDesc}
 }
 
-// CheckCredentials function that checks provided credentials (username and password)
-/* This function extracts data.User from DataProvider and also this function checks password from user credentials
+// CheckCredentials function that checks provided credentials (username, password and TOTP code if user has OTP configured)
+/* This function extracts data.User from DataProvider and also this function checks password from user credentials and second factor
  * Parameters:
  *    - tokenIssueData - issues token
- *    - realm - name of a data.Realm
+ *    - realm - data.Realm, password of realm user must not be expired according to realm password policy, number of failed
+ *      logins is limited by realm brute-force protection, user must be enabled and valid at this time (CheckUserState)
  * Returns: nil if credentials are valid, otherwise error (data.OperationError) with description
  */
-func (service *TokenBasedSecurityService) CheckCredentials(tokenIssueData *dto.TokenGenerationData, realmName string) *data.OperationError {
-	user, _ := (*service.DataProvider).GetUser(realmName, tokenIssueData.Username)
+func (service *TokenBasedSecurityService) CheckCredentials(tokenIssueData *dto.TokenGenerationData, realm *data.Realm) *data.OperationError {
+	invalidCredentials := &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidUserCredentialsDesc}
+	// locked user gets the same error as user with wrong password, that doesn't allow to find out that user exists, attempt is
+	// counted as a failure before password check and is cancelled if password is valid
+	attempts, allowed := service.startLoginAttempt(realm, tokenIssueData.Username, tokenIssueData.ClientAddress)
+	if !allowed {
+		return invalidCredentials
+	}
+	user, _ := (*service.DataProvider).GetUser(realm.Name, tokenIssueData.Username)
 	if user == nil {
+		// hash calculation makes response time of unknown user similar to response time of a wrong password
+		_, _ = hashing.HashPassword(tokenIssueData.Password)
 		service.logger.Trace("Credential check: username mismatch")
-		return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidUserCredentialsDesc}
+		return invalidCredentials
 	}
 
-	// todo(UMV): use hash instead raw passwords
-	password := user.GetPassword()
-	if password != tokenIssueData.Password {
+	matches, rehash := hashing.CheckPassword(tokenIssueData.Password, user.GetPassword())
+	if !matches {
 		service.logger.Trace("Credential check: password mismatch")
-		return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidUserCredentialsDesc}
+		return invalidCredentials
+	}
+	service.cancelLoginAttempt(realm, tokenIssueData.Username, attempts)
+	// user state is checked after password (therefore nobody but user knows that account is disabled) and before OTP, therefore
+	// errors that required actions fix (OTP is not configured, password has expired) are returned only to enabled and valid user
+	if stateCheck := service.CheckUserState(realm, user); stateCheck != nil {
+		return stateCheck
+	}
+	if otpCheck := service.CheckOtp(realm, user, tokenIssueData.Totp, tokenIssueData.ClientAddress); otpCheck != nil {
+		return otpCheck
+	}
+	service.resetLoginFailures(realm, tokenIssueData.Username)
+	if IsPasswordExpired(realm.PasswordPolicy, user) {
+		service.logger.Debug(sf.Format("Credential check: password of user \"{0}\" has expired", tokenIssueData.Username))
+		return &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.PasswordExpiredDesc}
+	}
+	if rehash {
+		service.rehashPassword(realm.Name, user, tokenIssueData.Password)
 	}
 	return nil
 }
 
+// rehashPassword replaces password in plain text, KeyCloak pbkdf2 or bcrypt hash with actual password hash after successful login
+/* Rehash error is not a login error, therefore it is only logged and user gets tokens anyway
+ * Parameters:
+ *    - realmName - name of a data.Realm
+ *    - user - user that successfully passed credentials check
+ *    - password - password in plain text
+ * Returns: nothing
+ */
+func (service *TokenBasedSecurityService) rehashPassword(realmName string, user data.User, password string) {
+	userName := user.GetUsername()
+	if err := user.RehashPassword(password); err != nil {
+		service.logger.Warn(sf.Format("Password rehash of user \"{0}\" failed: {1}", userName, err.Error()))
+		return
+	}
+	if err := (*service.DataProvider).UpdateUser(realmName, userName, user); err != nil {
+		service.logger.Warn(sf.Format("Password rehash of user \"{0}\" was not stored: {1}", userName, err.Error()))
+		return
+	}
+	service.logger.Debug(sf.Format("Password of user \"{0}\" was rehashed", userName))
+}
+
 // GetCurrentUserByName return public user info by username
 /* This function simply return user by name, by querying user from DataProvider
  * Parameters:
@@ -116,6 +227,13 @@ func (service *TokenBasedSecurityService) GetCurrentUserById(realmName string, u
  * Returns: identifier of session
  */
 func (service *TokenBasedSecurityService) StartOrUpdateSession(realm string, userId uuid.UUID, duration int, refresh int) uuid.UUID {
+	service.sessionsMutex.Lock()
+	defer service.sessionsMutex.Unlock()
+	return service.startOrUpdateSession(realm, userId, duration, refresh).Id
+}
+
+// startOrUpdateSession starts new session or updates existing one (see StartOrUpdateSession), must be called under sessionsMutex
+func (service *TokenBasedSecurityService) startOrUpdateSession(realm string, userId uuid.UUID, duration int, refresh int) *data.UserSession {
 	realmSessions, ok := service.UserSessions[realm]
 	sessionId := uuid.New()
 	// if there are no realm sessions ...
@@ -127,14 +245,14 @@ func (service *TokenBasedSecurityService) StartOrUpdateSession(realm string, use
 			RefreshExpired: started.Add(time.Second * time.Duration(refresh)),
 		}
 		service.UserSessions[realm] = append(realmSessions, userSession)
-		return sessionId
+		return &service.UserSessions[realm][0]
 	}
 	// realm session exists, we should find and update Expired values OR add new
 	for i, s := range realmSessions {
 		if s.UserId == userId {
 			realmSessions[i].Expired = time.Now().Add(time.Second * time.Duration(duration))
 			service.UserSessions[realm] = realmSessions
-			return s.Id
+			return &realmSessions[i]
 		}
 	}
 	// such session does not exist, adding
@@ -142,8 +260,9 @@ func (service *TokenBasedSecurityService) StartOrUpdateSession(realm string, use
 		Id: sessionId, UserId: userId, Started: time.Now(),
 		Expired: time.Now().Add(time.Second * time.Duration(duration)),
 	}
-	service.UserSessions[realm] = append(realmSessions, userSession)
-	return userSession.Id
+	realmSessions = append(realmSessions, userSession)
+	service.UserSessions[realm] = realmSessions
+	return &realmSessions[len(realmSessions)-1]
 }
 
 // AssignTokens saves obtained tokens in existing UserSession
@@ -156,6 +275,8 @@ func (service *TokenBasedSecurityService) StartOrUpdateSession(realm string, use
  * Returns nothing
  */
 func (service *TokenBasedSecurityService) AssignTokens(realm string, userId uuid.UUID, accessToken *string, refreshToken *string) {
+	service.sessionsMutex.Lock()
+	defer service.sessionsMutex.Unlock()
 	realmSessions, ok := service.UserSessions[realm]
 	if ok {
 		// index := -1
@@ -170,24 +291,39 @@ func (service *TokenBasedSecurityService) AssignTokens(realm string, userId uuid
 	}
 }
 
+// AssignTokenConfirmation binds tokens of existing UserSession to client certificate
+/* This function sets confirmation (cnf claim) for tokens that will be issued for session, nil confirmation removes binding
+ * Parameters:
+ *    - realm - name of realm
+ *    - userId - user identifier
+ *    - confirmation - token confirmation or nil
+ * Returns nothing
+ */
+func (service *TokenBasedSecurityService) AssignTokenConfirmation(realm string, userId uuid.UUID, confirmation *data.TokenConfirmation) {
+	service.sessionsMutex.Lock()
+	defer service.sessionsMutex.Unlock()
+	realmSessions, ok := service.UserSessions[realm]
+	if ok {
+		for i, s := range realmSessions {
+			if s.UserId == userId {
+				realmSessions[i].Confirmation = confirmation
+				break
+			}
+		}
+	}
+}
+
 // GetSession returns user session related to user
 /* Function iterates over sessions and searches appropriate session by comparing userId with s.UserId
  * Parameters:
  *    - realm - name of a realm
  *    - userId - user identifier
- * Returns data.UserSession if found or nil
+ * Returns copy of data.UserSession if found or nil
  */
 func (service *TokenBasedSecurityService) GetSession(realm string, userId uuid.UUID) *data.UserSession {
-	realmSessions, ok := service.UserSessions[realm]
-	if !ok {
-		return nil
-	}
-	for _, s := range realmSessions {
-		if s.UserId == userId {
-			return &s
-		}
-	}
-	return nil
+	return service.findSession(realm, func(s *data.UserSession) bool {
+		return s.UserId == userId
+	})
 }
 
 // GetSessionByAccessToken returns user session related to user by access token
@@ -195,19 +331,12 @@ func (service *TokenBasedSecurityService) GetSession(realm string, userId uuid.U
  * Parameters:
  *    - realm - name of a realm
  *    - token - access token
- * Returns data.UserSession if found or nil
+ * Returns copy of data.UserSession if found or nil
  */
 func (service *TokenBasedSecurityService) GetSessionByAccessToken(realm string, token *string) *data.UserSession {
-	realmSessions, ok := service.UserSessions[realm]
-	if !ok {
-		return nil
-	}
-	for _, s := range realmSessions {
-		if s.JwtAccessToken == *token {
-			return &s
-		}
-	}
-	return nil
+	return service.findSession(realm, func(s *data.UserSession) bool {
+		return s.JwtAccessToken == *token
+	})
 }
 
 // GetSessionByRefreshToken returns user session related to user by refresh token
@@ -215,21 +344,38 @@ func (service *TokenBasedSecurityService) GetSessionByAccessToken(realm string,
  * Parameters:
  *    - realm - name of a realm
  *    - token - refresh token
- * Returns data.UserSession if found or nil
+ * Returns copy of data.UserSession if found or nil
  */
 func (service *TokenBasedSecurityService) GetSessionByRefreshToken(realm string, token *string) *data.UserSession {
-	realmSessions, ok := service.UserSessions[realm]
-	if !ok {
-		return nil
-	}
-	for _, s := range realmSessions {
-		if s.JwtRefreshToken == *token {
-			return &s
+	return service.findSession(realm, func(s *data.UserSession) bool {
+		return s.JwtRefreshToken == *token
+	})
+}
+
+// findSession returns copy of the first realm session that matches, sessions could be changed by parallel requests after return
+func (service *TokenBasedSecurityService) findSession(realm string, matches func(s *data.UserSession) bool) *data.UserSession {
+	service.sessionsMutex.RLock()
+	defer service.sessionsMutex.RUnlock()
+	realmSessions := service.UserSessions[realm]
+	for i := range realmSessions {
+		if matches(&realmSessions[i]) {
+			return copySession(&realmSessions[i])
 		}
 	}
 	return nil
 }
 
+// copySession returns copy of session that doesn't share browser logins and token confirmation with stored session
+func copySession(session *data.UserSession) *data.UserSession {
+	sessionCopy := *session
+	sessionCopy.BrowserLogins = append([]data.BrowserLogin(nil), session.BrowserLogins...)
+	if session.Confirmation != nil {
+		confirmation := *session.Confirmation
+		sessionCopy.Confirmation = &confirmation
+	}
+	return &sessionCopy
+}
+
 // CheckSessionAndRefreshExpired this function checks both token are expired or not
 /* This function compares current time with expiration time (usually refresh token expires earlier than access)
  * Parameters:
@@ -245,3 +391,66 @@ func (service *TokenBasedSecurityService) CheckSessionAndRefreshExpired(realm st
 	current := time.Now().In(time.UTC)
 	return s.Expired.In(time.UTC).Before(current), s.RefreshExpired.In(time.UTC).Before(current)
 }
+
+// StorePushedAuthorizationRequest saves authorization request parameters and generates request_uri for them
+/* This function stores request in memory (like sessions), request_uri is a urn with random identifier. Expired requests
+ * are removed on every store call
+ * Parameters:
+ *    - realm - name of a realm
+ *    - authRequest - validated authorization request parameters
+ *    - lifetime - request_uri lifetime in seconds
+ * Returns: request_uri that client should pass to authorization endpoint
+ */
+func (service *TokenBasedSecurityService) StorePushedAuthorizationRequest(realm string, authRequest *dto.AuthorizationRequest, lifetime int) string {
+	service.parMutex.Lock()
+	defer service.parMutex.Unlock()
+	realmRequests, ok := service.pushedRequests[realm]
+	if !ok {
+		realmRequests = map[string]pushedAuthorizationRequest{}
+		service.pushedRequests[realm] = realmRequests
+	}
+	current := time.Now()
+	for uri, r := range realmRequests {
+		if r.expired.Before(current) {
+			delete(realmRequests, uri)
+		}
+	}
+	requestUri := globals.PushedAuthorizationRequestUriPrefix + uuid.New().String()
+	realmRequests[requestUri] = pushedAuthorizationRequest{request: authRequest,
+		expired: current.Add(time.Second * time.Duration(lifetime))}
+	return requestUri
+}
+
+// GetPushedAuthorizationRequest returns pushed authorization request by request_uri
+/* request_uri is a one-time value, therefore request is removed on first read by client that pushed it (read by other
+ * client doesn't remove request), request is returned only if it was pushed by the same client and is not expired
+ * Parameters:
+ *    - realm - name of a realm
+ *    - clientId - client that is passing request_uri to authorization endpoint
+ *    - requestUri - value obtained by client from PAR endpoint
+ * Returns: pushed request or nil if request not found, expired or belongs to other client
+ */
+func (service *TokenBasedSecurityService) GetPushedAuthorizationRequest(realm string, clientId string, requestUri string) *dto.AuthorizationRequest {
+	service.parMutex.Lock()
+	defer service.parMutex.Unlock()
+	realmRequests, ok := service.pushedRequests[realm]
+	if !ok {
+		return nil
+	}
+	r, ok := realmRequests[requestUri]
+	if !ok {
+		return nil
+	}
+	if r.expired.Before(time.Now()) {
+		delete(realmRequests, requestUri)
+		service.logger.Debug("Pushed authorization request is expired")
+		return nil
+	}
+	// request is removed only by client that pushed it, otherwise anybody who knows request_uri could make it unusable
+	if r.request.ClientId != clientId {
+		service.logger.Debug("Pushed authorization request belongs to another client")
+		return nil
+	}
+	delete(realmRequests, requestUri)
+	return r.request
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	e "errors"
+	"sort"
+	"strings"
+
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// ValidateUserAttributes checks user info attributes against realm user profile
+/* Every attribute must be defined in profile, username is required even if profile doesn't require it, sub and email_verified
+ * are never accepted. Values are converted to attribute types before check (see ParseUserAttributes)
+ * Parameters:
+ *    - profile - realm user profile (see data.Realm GetUserProfile)
+ *    - attributes - attribute name -> value (values are already trimmed)
+ * Returns: nil if attributes are valid, otherwise error with descriptions of all violations separated by "; "
+ */
+func ValidateUserAttributes(profile *data.UserProfile, attributes map[string]string) *data.OperationError {
+	var violations []string
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if isReservedAttribute(name) || (name != data.UsernameAttribute && profile.FindAttribute(name) == nil) {
+			violations = append(violations, sf.Format(errors.AttributeNotAllowedDesc, name))
+		}
+	}
+	for _, v := range profile.ValidateInfo(ParseUserAttributes(profile, attributes)) {
+		violations = append(violations, v.Description)
+	}
+	if len(violations) > 0 {
+		return &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: strings.Join(violations, "; ")}
+	}
+	return nil
+}
+
+// ParseUserAttributes converts attribute values that user entered to types of profile attributes, empty values are omitted
+func ParseUserAttributes(profile *data.UserProfile, attributes map[string]string) map[string]interface{} {
+	info := make(map[string]interface{}, len(attributes))
+	for name, value := range attributes {
+		if len(value) == 0 {
+			continue
+		}
+		if attribute := profile.FindAttribute(name); attribute != nil {
+			info[name] = attribute.ParseValue(value)
+		} else {
+			info[name] = value
+		}
+	}
+	return info
+}
+
+// getUserValidationError converts error of user store to operation error if user data doesn't match realm user profile
+func getUserValidationError(err error) *data.OperationError {
+	var validationErr errors.UserValidationError
+	if !e.As(err, &validationErr) {
+		return nil
+	}
+	return &data.OperationError{Msg: errors.InvalidUserProfileMsg, Description: strings.Join(validationErr.GetDescriptions(), "; ")}
+}
+
+// isReservedAttribute checks whether user info attribute is set only by server (user identifier and email verification)
+func isReservedAttribute(name string) bool {
+	return name == data.SubAttribute || name == data.EmailVerifiedAttribute
+}
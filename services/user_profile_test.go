@@ -0,0 +1,47 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/errors"
+	sf "github.com/wissance/stringFormatter"
+)
+
+func TestValidateUserAttributes(t *testing.T) {
+	profile := data.UserProfile{Attributes: []data.UserProfileAttribute{
+		{Name: data.EmailAttribute, Email: true},
+		{Name: "phone", Required: true, Pattern: `^\+[0-9]{7,15}$`},
+		{Name: "nickname", MinLength: 3, MaxLength: 5},
+	}}
+	testCases := []struct {
+		name               string
+		attributes         map[string]string
+		expectedViolations []string
+	}{
+		{name: "valid", attributes: map[string]string{data.UsernameAttribute: "user", "phone": "+79001234567", "nickname": "ника"}},
+		{name: "required", attributes: map[string]string{},
+			expectedViolations: []string{sf.Format(errors.AttributeRequiredDesc, data.UsernameAttribute), sf.Format(errors.AttributeRequiredDesc, "phone")}},
+		{name: "email", attributes: map[string]string{data.UsernameAttribute: "user", "phone": "+79001234567", data.EmailAttribute: "User <user@ferrum.test>"},
+			expectedViolations: []string{sf.Format(errors.AttributeInvalidEmailDesc, data.EmailAttribute)}},
+		{name: "pattern_and_length", attributes: map[string]string{data.UsernameAttribute: "user", "phone": "12345", "nickname": "ab"},
+			expectedViolations: []string{sf.Format(errors.AttributeInvalidFormatDesc, "phone"), sf.Format(errors.AttributeMinLengthDesc, "nickname", 3)}},
+		{name: "not_allowed", attributes: map[string]string{data.UsernameAttribute: "user", "phone": "+79001234567", "sub": "1", "role": "admin"},
+			expectedViolations: []string{sf.Format(errors.AttributeNotAllowedDesc, "role"), sf.Format(errors.AttributeNotAllowedDesc, "sub")}},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			check := ValidateUserAttributes(&profile, tCase.attributes)
+			if len(tCase.expectedViolations) == 0 {
+				assert.Nil(t, check)
+				return
+			}
+			require.NotNil(t, check)
+			assert.Equal(t, errors.InvalidUserProfileMsg, check.Msg)
+			assert.Equal(t, strings.Join(tCase.expectedViolations, "; "), check.Description)
+		})
+	}
+}
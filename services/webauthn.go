@@ -0,0 +1,369 @@
+package services
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wissance/Ferrum/data"
+	"github.com/wissance/Ferrum/dto"
+	"github.com/wissance/Ferrum/errors"
+	"github.com/wissance/Ferrum/utils/webauthn"
+	sf "github.com/wissance/stringFormatter"
+)
+
+const (
+	// webAuthnChallengeSize is a size of random challenge, WebAuthn requires at least 16 bytes
+	webAuthnChallengeSize   = 32
+	publicKeyCredentialType = "public-key"
+)
+
+// webAuthnCeremony is an issued registration or login options challenge, it lives in memory like sessions do
+/* userId is a user that registers passkey or user whose name was passed to login options (uuid.Nil - any user, discoverable
+ * credentials login)
+ */
+type webAuthnCeremony struct {
+	ceremonyType string
+	userId       uuid.UUID
+	expired      time.Time
+}
+
+// StartWebAuthnRegistration issues passkey registration options for authenticated user
+/* Parameters:
+ *    - realm - data.Realm with WebAuthn relying party settings
+ *    - user - user that registers passkey (already registered passkeys are excluded)
+ * Returns: options for navigator.credentials.create() or error if WebAuthn is not enabled in realm
+ */
+func (service *TokenBasedSecurityService) StartWebAuthnRegistration(realm *data.Realm,
+	user data.User) (*dto.PublicKeyCredentialCreationOptions, *data.OperationError) {
+	settings := realm.WebAuthn
+	if settings == nil {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.WebAuthnNotEnabledDesc}
+	}
+	userId := user.GetId()
+	challenge, err := service.startWebAuthnCeremony(realm.Name, webauthn.CreateCeremonyType, userId, settings.GetTimeout())
+	if err != nil {
+		service.logger.Error(sf.Format("WebAuthn challenge generation failed: {0}", err.Error()))
+		return nil, &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	rpName := settings.RpName
+	if len(rpName) == 0 {
+		rpName = realm.Name
+	}
+	options := dto.PublicKeyCredentialCreationOptions{
+		Rp:   dto.RelyingPartyEntity{Id: settings.RpId, Name: rpName},
+		User: dto.UserEntity{Id: webauthn.EncodeBase64Url(userId[:]), Name: user.GetUsername(), DisplayName: user.GetUsername()},
+		// residentKey "preferred" makes passkey discoverable, therefore user could log in without username
+		Challenge: challenge, Timeout: settings.GetTimeout() * 1000, Attestation: webauthn.NoneAttestationFormat,
+		AuthenticatorSelection: dto.AuthenticatorSelection{ResidentKey: "preferred", UserVerification: settings.GetUserVerification()},
+		ExcludeCredentials:     getCredentialDescriptors(user),
+	}
+	for _, algorithm := range webauthn.SupportedAlgorithms {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, dto.PublicKeyCredentialParameter{Type: publicKeyCredentialType,
+			Alg: algorithm})
+	}
+	return &options, nil
+}
+
+// FinishWebAuthnRegistration verifies registration response (WebAuthn Level 2 section 7.1) and stores new passkey in user credentials
+/* Parameters:
+ *    - realm - data.Realm with WebAuthn relying party settings
+ *    - user - user that registers passkey, it must be the same user that got registration options
+ *    - registration - navigator.credentials.create() result and passkey name
+ * Returns: nil if passkey was registered, otherwise error
+ */
+func (service *TokenBasedSecurityService) FinishWebAuthnRegistration(realm *data.Realm, user data.User,
+	registration *dto.WebAuthnRegistration) *data.OperationError {
+	settings := realm.WebAuthn
+	if settings == nil {
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.WebAuthnNotEnabledDesc}
+	}
+	invalidResponse := &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.InvalidWebAuthnResponseDesc}
+	ceremony, err := service.checkWebAuthnClientData(realm, &registration.Credential, webauthn.CreateCeremonyType)
+	if err != nil {
+		service.logger.Debug(sf.Format("WebAuthn registration: {0}", err.Error()))
+		return invalidResponse
+	}
+	if ceremony.userId != user.GetId() {
+		service.logger.Debug("WebAuthn registration: options were issued for another user")
+		return invalidResponse
+	}
+	attestationObject, err := webauthn.DecodeBase64Url(registration.Credential.Response.AttestationObject)
+	if err == nil {
+		var authData *webauthn.AuthenticatorData
+		authData, err = webauthn.ParseAttestationObject(attestationObject)
+		if err == nil {
+			err = checkWebAuthnAuthenticatorData(settings, authData)
+		}
+		if err == nil {
+			return service.storeWebAuthnCredential(realm.Name, user, registration, authData.AttestedCredential)
+		}
+	}
+	service.logger.Debug(sf.Format("WebAuthn registration: {0}", err.Error()))
+	return invalidResponse
+}
+
+// StartWebAuthnLogin issues passkey login options
+/* Options with userName contain user passkeys (allowCredentials), options without userName allow any discoverable passkey.
+ * Unknown user gets options without passkeys, that doesn't allow to find out that user exists
+ * Parameters:
+ *    - realm - data.Realm with WebAuthn relying party settings
+ *    - userName - name of user that logs in (could be empty)
+ * Returns: options for navigator.credentials.get() or error if WebAuthn is not enabled in realm
+ */
+func (service *TokenBasedSecurityService) StartWebAuthnLogin(realm *data.Realm,
+	userName string) (*dto.PublicKeyCredentialRequestOptions, *data.OperationError) {
+	settings := realm.WebAuthn
+	if settings == nil {
+		return nil, &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.WebAuthnNotEnabledDesc}
+	}
+	userId := uuid.Nil
+	allowCredentials := []dto.PublicKeyCredentialDescriptor{}
+	if len(userName) > 0 {
+		// unknown user gets random id, therefore any passkey will be rejected
+		userId = uuid.New()
+		user, _ := (*service.DataProvider).GetUser(realm.Name, userName)
+		if user != nil {
+			userId = user.GetId()
+			allowCredentials = getCredentialDescriptors(user)
+		}
+	}
+	challenge, err := service.startWebAuthnCeremony(realm.Name, webauthn.GetCeremonyType, userId, settings.GetTimeout())
+	if err != nil {
+		service.logger.Error(sf.Format("WebAuthn challenge generation failed: {0}", err.Error()))
+		return nil, &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	return &dto.PublicKeyCredentialRequestOptions{Challenge: challenge, Timeout: settings.GetTimeout() * 1000, RpId: settings.RpId,
+		AllowCredentials: allowCredentials, UserVerification: settings.GetUserVerification()}, nil
+}
+
+// CheckWebAuthnAssertion verifies passkey login response (WebAuthn Level 2 section 7.2) and returns user that owns passkey
+/* User is found by userHandle (discoverable passkeys) or by user name that was passed to login options. Passkey signature counter
+ * must grow (if authenticator supports it), otherwise authenticator could be cloned. Login of user that is locked by brute-force
+ * protection is denied, assertion with foreign passkey, wrong signature or counter is counted as failed login
+ * Parameters:
+ *    - realm - data.Realm with WebAuthn relying party settings
+ *    - assertion - navigator.credentials.get() result
+ *    - address - client ip address (could be empty)
+ * Returns: user and nil if assertion is valid, otherwise nil and error
+ */
+func (service *TokenBasedSecurityService) CheckWebAuthnAssertion(realm *data.Realm, assertion *dto.PublicKeyCredential,
+	address string) (data.User, *data.OperationError) {
+	settings := realm.WebAuthn
+	if settings == nil {
+		return nil, &data.OperationError{Msg: errors.UnauthorizedClientMsg, Description: errors.WebAuthnNotEnabledDesc}
+	}
+	invalidAssertion := &data.OperationError{Msg: errors.InvalidUserCredentialsMsg, Description: errors.InvalidWebAuthnResponseDesc}
+	ceremony, err := service.checkWebAuthnClientData(realm, assertion, webauthn.GetCeremonyType)
+	if err != nil {
+		service.logger.Debug(sf.Format("WebAuthn login: {0}", err.Error()))
+		return nil, invalidAssertion
+	}
+	// user is read and updated under mutex, otherwise parallel logins could accept the same signature counter value twice
+	service.webAuthnMutex.Lock()
+	defer service.webAuthnMutex.Unlock()
+	user := service.findWebAuthnUser(realm.Name, assertion.Response.UserHandle, ceremony.userId)
+	if user == nil {
+		service.logger.Debug("WebAuthn login: user was not found")
+		return nil, invalidAssertion
+	}
+	if service.isLoginLocked(realm, user.GetUsername(), address) {
+		return nil, invalidAssertion
+	}
+	credentials := user.GetWebAuthnCredentials()
+	index := -1
+	for i := range credentials {
+		if credentials[i].Id == assertion.Id {
+			index = i
+		}
+	}
+	if index < 0 {
+		service.logger.Debug("WebAuthn login: passkey doesn't belong to user")
+		service.registerLoginFailure(realm, user.GetUsername(), address)
+		return nil, invalidAssertion
+	}
+	signCount, err := verifyWebAuthnAssertion(settings, &credentials[index], assertion)
+	if err != nil {
+		service.logger.Debug(sf.Format("WebAuthn login: {0}", err.Error()))
+		service.registerLoginFailure(realm, user.GetUsername(), address)
+		return nil, invalidAssertion
+	}
+	if (signCount != 0 || credentials[index].SignCount != 0) && signCount <= credentials[index].SignCount {
+		service.logger.Warn(sf.Format("WebAuthn login: passkey signature counter of user \"{0}\" didn't grow, authenticator could be cloned",
+			user.GetUsername()))
+		service.registerLoginFailure(realm, user.GetUsername(), address)
+		return nil, invalidAssertion
+	}
+	credentials[index].SignCount = signCount
+	credentials[index].LastUsed = time.Now().Unix()
+	if err = user.SetWebAuthnCredentials(credentials); err == nil {
+		err = (*service.DataProvider).UpdateUser(realm.Name, user.GetUsername(), user)
+	}
+	if err != nil {
+		// counter that wasn't stored only weakens clone detection, therefore login is not denied
+		service.logger.Warn(sf.Format("WebAuthn passkey usage of user \"{0}\" was not stored: {1}", user.GetUsername(), err.Error()))
+	}
+	service.resetLoginFailures(realm, user.GetUsername())
+	return user, nil
+}
+
+// startWebAuthnCeremony generates challenge and stores ceremony until timeout, expired ceremonies are removed on every call
+func (service *TokenBasedSecurityService) startWebAuthnCeremony(realm string, ceremonyType string, userId uuid.UUID,
+	timeout int) (string, error) {
+	challengeBytes := make([]byte, webAuthnChallengeSize)
+	if _, err := rand.Read(challengeBytes); err != nil {
+		return "", err
+	}
+	challenge := webauthn.EncodeBase64Url(challengeBytes)
+	service.webAuthnMutex.Lock()
+	defer service.webAuthnMutex.Unlock()
+	realmCeremonies, ok := service.webAuthnCeremonies[realm]
+	if !ok {
+		realmCeremonies = map[string]webAuthnCeremony{}
+		service.webAuthnCeremonies[realm] = realmCeremonies
+	}
+	current := time.Now()
+	for c, ceremony := range realmCeremonies {
+		if ceremony.expired.Before(current) {
+			delete(realmCeremonies, c)
+		}
+	}
+	realmCeremonies[challenge] = webAuthnCeremony{ceremonyType: ceremonyType, userId: userId,
+		expired: current.Add(time.Second * time.Duration(timeout))}
+	return challenge, nil
+}
+
+// checkWebAuthnClientData checks clientDataJSON type and origin and returns (and removes, challenge is a one-time value) ceremony
+func (service *TokenBasedSecurityService) checkWebAuthnClientData(realm *data.Realm, credential *dto.PublicKeyCredential,
+	ceremonyType string) (*webAuthnCeremony, error) {
+	if credential.Type != publicKeyCredentialType {
+		return nil, fmt.Errorf("unexpected credential type \"%s\"", credential.Type)
+	}
+	clientDataJson, err := webauthn.DecodeBase64Url(credential.Response.ClientDataJson)
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := webauthn.ParseClientData(clientDataJson, ceremonyType)
+	if err != nil {
+		return nil, err
+	}
+	service.webAuthnMutex.Lock()
+	ceremony, ok := service.webAuthnCeremonies[realm.Name][clientData.Challenge]
+	delete(service.webAuthnCeremonies[realm.Name], clientData.Challenge)
+	service.webAuthnMutex.Unlock()
+	if !ok || ceremony.ceremonyType != ceremonyType || ceremony.expired.Before(time.Now()) {
+		return nil, fmt.Errorf("challenge is unknown, expired or was already used")
+	}
+	if !realm.WebAuthn.IsOriginAllowed(clientData.Origin) || clientData.CrossOrigin {
+		return nil, fmt.Errorf("origin \"%s\" is not allowed", clientData.Origin)
+	}
+	return &ceremony, nil
+}
+
+// findWebAuthnUser finds user by userHandle (user id) and checks that it is the same user whose name was passed to login options
+func (service *TokenBasedSecurityService) findWebAuthnUser(realm string, userHandle string, ceremonyUserId uuid.UUID) data.User {
+	userId := ceremonyUserId
+	if len(userHandle) > 0 {
+		handle, err := webauthn.DecodeBase64Url(userHandle)
+		if err != nil {
+			return nil
+		}
+		handleUserId, err := uuid.FromBytes(handle)
+		if err != nil || (ceremonyUserId != uuid.Nil && handleUserId != ceremonyUserId) {
+			return nil
+		}
+		userId = handleUserId
+	}
+	if userId == uuid.Nil {
+		return nil
+	}
+	user, _ := (*service.DataProvider).GetUserById(realm, userId)
+	return user
+}
+
+// storeWebAuthnCredential adds attested credential to user passkeys and updates user
+func (service *TokenBasedSecurityService) storeWebAuthnCredential(realm string, user data.User, registration *dto.WebAuthnRegistration,
+	attested *webauthn.AttestedCredential) *data.OperationError {
+	credentialId := webauthn.EncodeBase64Url(attested.CredentialId)
+	if credentialId != registration.Credential.Id {
+		service.logger.Debug("WebAuthn registration: credential id doesn't match attested credential")
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.InvalidWebAuthnResponseDesc}
+	}
+	_, algorithm, err := webauthn.ParsePublicKey(attested.PublicKey)
+	if err != nil {
+		service.logger.Debug(sf.Format("WebAuthn registration: {0}", err.Error()))
+		return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.InvalidWebAuthnResponseDesc}
+	}
+	service.webAuthnMutex.Lock()
+	defer service.webAuthnMutex.Unlock()
+	credentials := user.GetWebAuthnCredentials()
+	for _, c := range credentials {
+		if c.Id == credentialId {
+			return &data.OperationError{Msg: errors.InvalidRequestMsg, Description: errors.WebAuthnCredentialExistsDesc}
+		}
+	}
+	credentials = append(credentials, data.WebAuthnCredential{Id: credentialId, PublicKey: webauthn.EncodeBase64Url(attested.PublicKey),
+		Algorithm: algorithm, Name: registration.Name, Created: time.Now().Unix()})
+	if err = user.SetWebAuthnCredentials(credentials); err == nil {
+		err = (*service.DataProvider).UpdateUser(realm, user.GetUsername(), user)
+	}
+	if err != nil {
+		service.logger.Error(sf.Format("WebAuthn passkey of user \"{0}\" was not stored: {1}", user.GetUsername(), err.Error()))
+		return &data.OperationError{Msg: errors.ServiceIsUnavailable}
+	}
+	service.logger.Info(sf.Format("User \"{0}\" registered passkey", user.GetUsername()))
+	return nil
+}
+
+// checkWebAuthnAuthenticatorData checks relying party id and user presence and verification flags
+func checkWebAuthnAuthenticatorData(settings *data.WebAuthnSettings, authData *webauthn.AuthenticatorData) error {
+	if !authData.CheckRpId(settings.RpId) {
+		return fmt.Errorf("authenticator data belongs to another relying party")
+	}
+	if !authData.HasFlag(webauthn.FlagUserPresent) {
+		return fmt.Errorf("user presence flag is not set")
+	}
+	if settings.GetUserVerification() == data.RequiredUserVerification && !authData.HasFlag(webauthn.FlagUserVerified) {
+		return fmt.Errorf("user verification flag is not set")
+	}
+	return nil
+}
+
+// verifyWebAuthnAssertion checks authenticator data and signature of assertion and returns signature counter
+func verifyWebAuthnAssertion(settings *data.WebAuthnSettings, credential *data.WebAuthnCredential,
+	assertion *dto.PublicKeyCredential) (uint32, error) {
+	rawAuthData, err := webauthn.DecodeBase64Url(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	authData, err := webauthn.ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+	if err = checkWebAuthnAuthenticatorData(settings, authData); err != nil {
+		return 0, err
+	}
+	clientDataJson, _ := webauthn.DecodeBase64Url(assertion.Response.ClientDataJson)
+	signature, err := webauthn.DecodeBase64Url(assertion.Response.Signature)
+	if err != nil {
+		return 0, err
+	}
+	publicKey, err := webauthn.DecodeBase64Url(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	if err = webauthn.VerifySignature(publicKey, rawAuthData, clientDataJson, signature); err != nil {
+		return 0, err
+	}
+	return authData.SignCount, nil
+}
+
+// getCredentialDescriptors returns descriptors of user passkeys (excludeCredentials and allowCredentials of ceremony options)
+func getCredentialDescriptors(user data.User) []dto.PublicKeyCredentialDescriptor {
+	descriptors := []dto.PublicKeyCredentialDescriptor{}
+	for _, c := range user.GetWebAuthnCredentials() {
+		descriptors = append(descriptors, dto.PublicKeyCredentialDescriptor{Type: publicKeyCredentialType, Id: c.Id})
+	}
+	return descriptors
+}
@@ -0,0 +1,274 @@
+package themes
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/wissance/Ferrum/logging"
+	sf "github.com/wissance/stringFormatter"
+)
+
+// DefaultTheme is a name of theme that is embedded in binary, other themes take missing templates, messages and resources from it
+const DefaultTheme = "default"
+
+// DefaultLocale is a locale that is used if neither ui_locales nor Accept-Language match theme message bundles
+const DefaultLocale = "en"
+
+// Page templates that every theme has (custom theme could override any of them)
+const (
+	LoginTemplate        = "login.html"
+	ConsentTemplate      = "consent.html"
+	RegistrationTemplate = "register.html"
+	InfoTemplate         = "info.html"
+	ErrorTemplate        = "error.html"
+)
+
+const (
+	templatesDir       = "templates"
+	messagesDir        = "messages"
+	resourcesDir       = "resources"
+	messagesFilePrefix = "messages_"
+	messagesFileSuffix = ".json"
+)
+
+//go:embed default
+var embeddedThemes embed.FS
+
+// Theme is a set of login pages templates, message bundles (locale -> key -> text) and static resources (css, images, scripts)
+/* Theme files are layers: theme directory first, embedded default theme last, therefore theme could contain only files that
+ * it changes. Message text is formatted with stringFormatter, i.e. "Sign in to {0}"
+ */
+type Theme struct {
+	Name      string
+	templates *template.Template
+	messages  map[string]map[string]string
+	layers    []fs.FS
+}
+
+// ThemeManager loads themes from themes directory (once, themes are cached) and from binary (default theme)
+type ThemeManager struct {
+	themesDir string
+	themes    map[string]*Theme
+	mutex     sync.Mutex
+	logger    *logging.AppLogger
+}
+
+// PageContext is a value that page template is executed with, Page is a page model (i.e. login form values)
+type PageContext struct {
+	Locale       string
+	ResourcesUrl string
+	Page         interface{}
+	theme        *Theme
+}
+
+// CreateThemeManager creates ThemeManager
+/* Parameters:
+ *    - themesDir - directory with custom themes (every theme is a subdirectory), empty value means only default theme is available
+ *    - logger - logger service
+ * Returns: theme manager
+ */
+func CreateThemeManager(themesDir string, logger *logging.AppLogger) *ThemeManager {
+	return &ThemeManager{themesDir: themesDir, themes: map[string]*Theme{}, logger: logger}
+}
+
+// GetTheme returns theme by name, theme that doesn't exist or couldn't be loaded is replaced by default theme
+/* Parameters:
+ *    - name - theme name (realm login_theme), empty name means default theme
+ * Returns: loaded theme
+ */
+func (manager *ThemeManager) GetTheme(name string) *Theme {
+	if len(name) == 0 {
+		name = DefaultTheme
+	}
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	if theme, ok := manager.themes[name]; ok {
+		return theme
+	}
+	theme, err := manager.loadTheme(name)
+	if err != nil {
+		manager.logger.Warn(sf.Format("Theme \"{0}\" was not loaded, default theme is used: {1}", name, err.Error()))
+		theme = manager.themes[DefaultTheme]
+		if theme == nil {
+			// default theme is embedded, it could fail only if binary was built with broken templates
+			theme, err = manager.loadTheme(DefaultTheme)
+			if err != nil {
+				panic(err)
+			}
+			manager.themes[DefaultTheme] = theme
+		}
+	}
+	manager.themes[name] = theme
+	return theme
+}
+
+func (manager *ThemeManager) loadTheme(name string) (*Theme, error) {
+	defaultLayer, err := fs.Sub(embeddedThemes, DefaultTheme)
+	if err != nil {
+		return nil, err
+	}
+	layers := []fs.FS{defaultLayer}
+	if name != DefaultTheme {
+		if len(manager.themesDir) == 0 || filepath.Base(name) != name || name == ".." {
+			return nil, fmt.Errorf("theme doesn't exist")
+		}
+		themeDir := filepath.Join(manager.themesDir, name)
+		if info, statErr := os.Stat(themeDir); statErr != nil || !info.IsDir() {
+			return nil, fmt.Errorf("theme directory \"%s\" doesn't exist", themeDir)
+		}
+		layers = append([]fs.FS{os.DirFS(themeDir)}, layers...)
+	}
+	theme := Theme{Name: name, templates: template.New(name), messages: map[string]map[string]string{}, layers: layers}
+	// default layer is read first, therefore theme templates and messages replace default ones
+	for i := len(layers) - 1; i >= 0; i-- {
+		templateFiles, _ := fs.Glob(layers[i], templatesDir+"/*.html")
+		if len(templateFiles) > 0 {
+			if _, err = theme.templates.ParseFS(layers[i], templateFiles...); err != nil {
+				return nil, err
+			}
+		}
+		if err = theme.readMessages(layers[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &theme, nil
+}
+
+func (theme *Theme) readMessages(layer fs.FS) error {
+	messagesFiles, _ := fs.Glob(layer, messagesDir+"/"+messagesFilePrefix+"*"+messagesFileSuffix)
+	for _, file := range messagesFiles {
+		content, err := fs.ReadFile(layer, file)
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err = json.Unmarshal(content, &messages); err != nil {
+			return fmt.Errorf("message bundle \"%s\" is invalid: %w", file, err)
+		}
+		locale := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(path.Base(file), messagesFilePrefix), messagesFileSuffix))
+		if theme.messages[locale] == nil {
+			theme.messages[locale] = map[string]string{}
+		}
+		for key, text := range messages {
+			theme.messages[locale][key] = text
+		}
+	}
+	return nil
+}
+
+// Render executes page template
+/* Parameters:
+ *    - writer - page destination
+ *    - templateName - page template (LoginTemplate, ErrorTemplate, ...)
+ *    - locale - message bundle locale (see SelectLocale)
+ *    - resourcesUrl - url of theme static resources (templates use it for css, images and scripts)
+ *    - page - page model
+ * Returns: error if template is missing or failed
+ */
+func (theme *Theme) Render(writer io.Writer, templateName string, locale string, resourcesUrl string, page interface{}) error {
+	context := PageContext{Locale: locale, ResourcesUrl: resourcesUrl, Page: page, theme: theme}
+	return theme.templates.ExecuteTemplate(writer, templateName, &context)
+}
+
+// SelectLocale selects message bundle locale: first ui_locales value (in order of preference) that theme has, then
+// Accept-Language value (by quality) and DefaultLocale at last, region value (i.e. en-US) matches language bundle (en)
+func (theme *Theme) SelectLocale(uiLocales string, acceptLanguage string) string {
+	candidates := append(strings.Fields(uiLocales), parseAcceptLanguage(acceptLanguage)...)
+	for _, candidate := range candidates {
+		candidate = strings.ToLower(candidate)
+		if _, ok := theme.messages[candidate]; ok {
+			return candidate
+		}
+		language, _, _ := strings.Cut(candidate, "-")
+		if _, ok := theme.messages[language]; ok {
+			return language
+		}
+	}
+	return DefaultLocale
+}
+
+// OpenResource opens theme static resource (relative path inside resources directory), directories are not resources
+func (theme *Theme) OpenResource(name string) (fs.File, error) {
+	resourcePath := resourcesDir + "/" + name
+	if !fs.ValidPath(resourcePath) {
+		return nil, fs.ErrNotExist
+	}
+	for _, layer := range theme.layers {
+		file, err := layer.Open(resourcePath)
+		if err != nil {
+			continue
+		}
+		info, err := file.Stat()
+		if err == nil && !info.IsDir() {
+			return file, nil
+		}
+		_ = file.Close()
+	}
+	return nil, fs.ErrNotExist
+}
+
+// Msg returns message text in page locale (or DefaultLocale text), key itself is returned if there is no such message,
+// therefore errors descriptions could be translated by messages with description as a key
+func (context *PageContext) Msg(key string, args ...interface{}) string {
+	text, ok := context.theme.messages[context.Locale][key]
+	if !ok {
+		text, ok = context.theme.messages[DefaultLocale][key]
+	}
+	if !ok {
+		text = key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return sf.Format(text, args...)
+}
+
+// HasMsg checks whether theme has message in page locale or in DefaultLocale, i.e. to show scope description or scope name
+func (context *PageContext) HasMsg(key string) bool {
+	if _, ok := context.theme.messages[context.Locale][key]; ok {
+		return true
+	}
+	_, ok := context.theme.messages[DefaultLocale][key]
+	return ok
+}
+
+// parseAcceptLanguage returns Accept-Language header languages ordered by quality (q parameter), languages with q=0 are skipped
+func parseAcceptLanguage(header string) []string {
+	type weightedLanguage struct {
+		language string
+		quality  float64
+	}
+	languages := make([]weightedLanguage, 0)
+	for _, part := range strings.Split(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") {
+			if parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+				quality = parsed
+			}
+		}
+		if len(language) > 0 && language != "*" && quality > 0 {
+			languages = append(languages, weightedLanguage{language: language, quality: quality})
+		}
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+	result := make([]string, len(languages))
+	for i, l := range languages {
+		result[i] = l.language
+	}
+	return result
+}
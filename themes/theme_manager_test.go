@@ -0,0 +1,91 @@
+package themes
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/wissance/Ferrum/config"
+	"github.com/wissance/Ferrum/logging"
+)
+
+type testPage struct {
+	Realm string
+	Error string
+}
+
+func TestCustomThemeOverridesDefault(t *testing.T) {
+	themesDir := t.TempDir()
+	writeThemeFile(t, themesDir, "brand/templates/error.html", `<p class="brand">{{.Msg "errorTitle" .Page.Realm}}: {{.Msg .Page.Error}}</p>`)
+	writeThemeFile(t, themesDir, "brand/messages/messages_en.json", `{"errorTitle": "Oops in {0}"}`)
+	writeThemeFile(t, themesDir, "brand/messages/messages_de.json", `{"errorTitle": "Fehler in {0}", "Invalid client": "Unbekannte Anwendung"}`)
+	writeThemeFile(t, themesDir, "brand/resources/img/logo.svg", `<svg/>`)
+	manager := CreateThemeManager(themesDir, logging.CreateLogger(&config.LoggingConfig{Level: "info"}))
+
+	theme := manager.GetTheme("brand")
+	assert.Equal(t, "brand", theme.Name)
+	var content bytes.Buffer
+	require.NoError(t, theme.Render(&content, ErrorTemplate, "de", "/resources/brand", &testPage{Realm: "myapp", Error: "Invalid client"}))
+	assert.Equal(t, `<p class="brand">Fehler in myapp: Unbekannte Anwendung</p>`, content.String())
+	content.Reset()
+	require.NoError(t, theme.Render(&content, ErrorTemplate, "en", "/resources/brand", &testPage{Realm: "myapp", Error: "Invalid client"}))
+	assert.Equal(t, `<p class="brand">Oops in myapp: Invalid client</p>`, content.String())
+	// login template and russian messages are taken from default theme
+	assert.NotNil(t, theme.templates.Lookup(LoginTemplate))
+	assert.Equal(t, "ru", theme.SelectLocale("", "ru-RU,ru;q=0.9"))
+
+	// resources are taken from theme and then from default theme, directories and paths outside resources are not available
+	assertResource(t, theme, "img/logo.svg", "<svg/>")
+	_, err := theme.OpenResource("css/login.css")
+	assert.NoError(t, err)
+	for _, name := range []string{"img", "../messages/messages_en.json", "/etc/passwd"} {
+		_, err = theme.OpenResource(name)
+		assert.Error(t, err, name)
+	}
+
+	// unknown theme and theme name that is a path are replaced by default theme
+	assert.Equal(t, DefaultTheme, manager.GetTheme("unknown").Name)
+	assert.Equal(t, DefaultTheme, manager.GetTheme("../brand").Name)
+	assert.Equal(t, DefaultTheme, manager.GetTheme("").Name)
+}
+
+func TestSelectLocale(t *testing.T) {
+	theme := CreateThemeManager("", logging.CreateLogger(&config.LoggingConfig{Level: "info"})).GetTheme(DefaultTheme)
+	testCases := []struct {
+		name           string
+		uiLocales      string
+		acceptLanguage string
+		expected       string
+	}{
+		{name: "nothing", expected: DefaultLocale},
+		{name: "ui_locales_first", uiLocales: "fr ru", acceptLanguage: "en", expected: "ru"},
+		{name: "accept_language_quality", acceptLanguage: "en;q=0.5, ru-RU;q=0.8, fr", expected: "ru"},
+		{name: "zero_quality", acceptLanguage: "ru;q=0, de", expected: DefaultLocale},
+		{name: "unknown_locales", uiLocales: "de", acceptLanguage: "fr-CA", expected: DefaultLocale},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, theme.SelectLocale(tc.uiLocales, tc.acceptLanguage))
+		})
+	}
+}
+
+func writeThemeFile(t *testing.T, themesDir string, name string, content string) {
+	fileName := filepath.Join(themesDir, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(fileName), 0o755))
+	require.NoError(t, os.WriteFile(fileName, []byte(content), 0o600))
+}
+
+func assertResource(t *testing.T, theme *Theme, name string, expected string) {
+	file, err := theme.OpenResource(name)
+	require.NoError(t, err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+}
@@ -0,0 +1,258 @@
+package hashing
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// Argon2idAlgorithm is a default algorithm of password hashing, hash is encoded as $argon2id$v=19$m={memory},t={time},p={threads}${salt}${hash}
+	Argon2idAlgorithm = "argon2id"
+	// Pbkdf2Sha256Algorithm is a KeyCloak default algorithm, hash is encoded as $pbkdf2-sha256$i={iterations}${salt}${hash}
+	Pbkdf2Sha256Algorithm = "pbkdf2-sha256"
+	// Pbkdf2Sha512Algorithm is a KeyCloak algorithm, hash is encoded as $pbkdf2-sha512$i={iterations}${salt}${hash}
+	Pbkdf2Sha512Algorithm = "pbkdf2-sha512"
+	// Pbkdf2Algorithm is a KeyCloak legacy algorithm (pbkdf2 with sha1), hash is encoded as $pbkdf2$i={iterations}${salt}${hash}
+	Pbkdf2Algorithm = "pbkdf2"
+)
+
+// argon2id parameters (OWASP recommended minimum: 19 MiB of memory, 2 iterations, 1 degree of parallelism)
+const (
+	argon2Memory  uint32 = 19 * 1024
+	argon2Time    uint32 = 2
+	argon2Threads uint8  = 1
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash format")
+
+// PasswordHash is a decoded password hash (see DecodePasswordHash) in KeyCloak credential terms
+/* Algorithm is a KeyCloak algorithm name (pbkdf2, pbkdf2-sha256, pbkdf2-sha512 or argon2id), Salt and Value are base64 encoded
+ * with padding (as KeyCloak stores them), Memory (KiB) and Parallelism are set only for argon2id hash
+ */
+type PasswordHash struct {
+	Algorithm   string
+	Iterations  int
+	Memory      int
+	Parallelism int
+	Salt        string
+	Value       string
+}
+
+// HashPassword returns argon2id hash of a password encoded in PHC string format
+/* Each hash has its own random salt, therefore same password gives different hashes, use CheckPassword to compare password with hash
+ * Parameters:
+ *    - password - password in plain text
+ * Returns: encoded hash and error if random salt generation failed
+ */
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("rand.Read failed: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2idAlgorithm, argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword checks in constant time whether password corresponds to stored password hash
+/* Stored value could be argon2id hash (HashPassword), bcrypt hash ($2a$, $2b$, $2y$), pbkdf2 hash imported from KeyCloak
+ * (EncodePbkdf2Hash) or a password in plain text (users that were created before passwords hashing), any value except argon2id hash
+ * with actual parameters should be replaced with HashPassword result after successful check
+ * Parameters:
+ *    - password - password in plain text that was provided by user
+ *    - storedValue - password hash (or password) from user credentials
+ * Returns: true if password matches, true if stored value must be rehashed
+ */
+func CheckPassword(password string, storedValue string) (bool, bool) {
+	if len(storedValue) == 0 {
+		return false, false
+	}
+	if !strings.HasPrefix(storedValue, "$") {
+		return subtle.ConstantTimeCompare([]byte(password), []byte(storedValue)) == 1, true
+	}
+	parts := strings.Split(storedValue, "$")
+	switch parts[1] {
+	case Argon2idAlgorithm:
+		return checkArgon2idHash(password, parts)
+	case "2a", "2b", "2y":
+		return bcrypt.CompareHashAndPassword([]byte(storedValue), []byte(password)) == nil, true
+	case Pbkdf2Sha256Algorithm, Pbkdf2Sha512Algorithm, Pbkdf2Algorithm:
+		return checkPbkdf2Hash(password, parts), true
+	}
+	// value that starts with $ but has unknown format is a password in plain text
+	return subtle.ConstantTimeCompare([]byte(password), []byte(storedValue)) == 1, true
+}
+
+// IsPasswordHash checks whether stored password value is a hash of supported format (not a password in plain text)
+func IsPasswordHash(storedValue string) bool {
+	if !strings.HasPrefix(storedValue, "$") {
+		return false
+	}
+	switch strings.Split(storedValue, "$")[1] {
+	case Argon2idAlgorithm, "2a", "2b", "2y", Pbkdf2Sha256Algorithm, Pbkdf2Sha512Algorithm, Pbkdf2Algorithm:
+		return true
+	}
+	return false
+}
+
+// EncodePbkdf2Hash encodes KeyCloak pbkdf2 credential (secretData and credentialData) into a format that CheckPassword understands
+/* Parameters:
+ *    - algorithm - KeyCloak credentialData algorithm (pbkdf2, pbkdf2-sha256 or pbkdf2-sha512)
+ *    - iterations - KeyCloak credentialData hashIterations
+ *    - salt - base64 encoded KeyCloak secretData salt
+ *    - value - base64 encoded KeyCloak secretData value
+ * Returns: encoded hash or error if algorithm is not supported
+ */
+func EncodePbkdf2Hash(algorithm string, iterations int, salt string, value string) (string, error) {
+	if getPbkdf2HashFunc(algorithm) == nil {
+		return "", fmt.Errorf("unsupported password hash algorithm \"%s\"", algorithm)
+	}
+	if iterations <= 0 {
+		return "", errInvalidPasswordHash
+	}
+	return fmt.Sprintf("$%s$i=%d$%s$%s", algorithm, iterations, salt, value), nil
+}
+
+// EncodeArgon2idHash encodes KeyCloak argon2 credential (type id, version 1.3) into HashPassword format
+/* Parameters:
+ *    - iterations - KeyCloak credentialData hashIterations
+ *    - memory - KeyCloak credentialData memory (KiB)
+ *    - parallelism - KeyCloak credentialData parallelism
+ *    - salt - base64 encoded KeyCloak secretData salt
+ *    - value - base64 encoded KeyCloak secretData value
+ * Returns: encoded hash or error if parameters or base64 values are invalid
+ */
+func EncodeArgon2idHash(iterations int, memory int, parallelism int, salt string, value string) (string, error) {
+	saltBytes, saltErr := decodeBase64(salt)
+	key, keyErr := decodeBase64(value)
+	if saltErr != nil || keyErr != nil || len(key) == 0 || iterations <= 0 || memory <= 0 || parallelism <= 0 || parallelism > 255 {
+		return "", errInvalidPasswordHash
+	}
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2idAlgorithm, argon2.Version, memory, iterations, parallelism,
+		base64.RawStdEncoding.EncodeToString(saltBytes), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// DecodePasswordHash decodes pbkdf2 (EncodePbkdf2Hash) or argon2id (HashPassword) hash to store it as KeyCloak credential
+/* Parameters:
+ *    - storedValue - password hash from user credentials
+ * Returns: decoded hash or error if value is not a hash or hash algorithm is not supported by KeyCloak (bcrypt)
+ */
+func DecodePasswordHash(storedValue string) (*PasswordHash, error) {
+	if !strings.HasPrefix(storedValue, "$") {
+		return nil, errInvalidPasswordHash
+	}
+	parts := strings.Split(storedValue, "$")
+	result := PasswordHash{Algorithm: parts[1]}
+	var saltPart, valuePart string
+	switch parts[1] {
+	case Argon2idAlgorithm:
+		var version int
+		if len(parts) != 6 {
+			return nil, errInvalidPasswordHash
+		}
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return nil, errInvalidPasswordHash
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &result.Memory, &result.Iterations, &result.Parallelism); err != nil {
+			return nil, errInvalidPasswordHash
+		}
+		saltPart, valuePart = parts[4], parts[5]
+	case Pbkdf2Sha256Algorithm, Pbkdf2Sha512Algorithm, Pbkdf2Algorithm:
+		if len(parts) != 5 || !strings.HasPrefix(parts[2], "i=") {
+			return nil, errInvalidPasswordHash
+		}
+		iterations, err := strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+		if err != nil || iterations <= 0 {
+			return nil, errInvalidPasswordHash
+		}
+		result.Iterations = iterations
+		saltPart, valuePart = parts[3], parts[4]
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm \"%s\"", parts[1])
+	}
+	salt, saltErr := decodeBase64(saltPart)
+	value, valueErr := decodeBase64(valuePart)
+	if saltErr != nil || valueErr != nil || len(value) == 0 {
+		return nil, errInvalidPasswordHash
+	}
+	result.Salt = base64.StdEncoding.EncodeToString(salt)
+	result.Value = base64.StdEncoding.EncodeToString(value)
+	return &result, nil
+}
+
+func checkArgon2idHash(password string, parts []string) (bool, bool) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	if len(parts) != 6 {
+		return false, false
+	}
+	var version int
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, saltErr := base64.RawStdEncoding.DecodeString(parts[4])
+	expected, hashErr := base64.RawStdEncoding.DecodeString(parts[5])
+	if saltErr != nil || hashErr != nil || len(expected) == 0 {
+		return false, false
+	}
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(key, expected) != 1 {
+		return false, false
+	}
+	outdated := memory != argon2Memory || time != argon2Time || threads != argon2Threads || uint32(len(expected)) != argon2KeyLen
+	return true, outdated
+}
+
+func checkPbkdf2Hash(password string, parts []string) bool {
+	// "", algorithm, "i=...", salt, hash
+	if len(parts) != 5 || !strings.HasPrefix(parts[2], "i=") {
+		return false
+	}
+	iterations, err := strconv.Atoi(strings.TrimPrefix(parts[2], "i="))
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, saltErr := decodeBase64(parts[3])
+	expected, hashErr := decodeBase64(parts[4])
+	if saltErr != nil || hashErr != nil || len(expected) == 0 {
+		return false
+	}
+	key := pbkdf2.Key([]byte(password), salt, iterations, len(expected), getPbkdf2HashFunc(parts[1]))
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+func getPbkdf2HashFunc(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case Pbkdf2Sha256Algorithm:
+		return sha256.New
+	case Pbkdf2Sha512Algorithm:
+		return sha512.New
+	case Pbkdf2Algorithm:
+		return sha1.New
+	}
+	return nil
+}
+
+// decodeBase64 decodes KeyCloak base64 values, they are padded but we also accept unpadded values
+func decodeBase64(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(value, "="))
+}
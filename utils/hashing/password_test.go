@@ -0,0 +1,117 @@
+package hashing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testKeyCloakPassword = "keycloak_password"
+	testKeyCloakSalt     = "ZmVycnVtLWtjLXNhbHQxNg=="
+	testPbkdf2Sha256Hash = "4GwXh4B3vdGnAioqUKtdtwxY0m7lAVXP3PsAb0oGQkp1QbQAzk4UtoK5HdVDJJfb9JZME+qJRb8HlILQeVK97g=="
+	testPbkdf2Sha512Hash = "PItlHV9LorIC2NxJ1jCBu5wP0KO3qNPNlT3Lntf9XbOQbemZeKC0sG0bIb32mJWRcvtmBsBvzvVhSceJGB3bxQ=="
+)
+
+func TestHashPassword(t *testing.T) {
+	passwordHash, err := HashPassword("1234567890")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(passwordHash, "$argon2id$v=19$"))
+	otherHash, err := HashPassword("1234567890")
+	require.NoError(t, err)
+	assert.NotEqual(t, passwordHash, otherHash)
+
+	matches, rehash := CheckPassword("1234567890", passwordHash)
+	assert.True(t, matches)
+	assert.False(t, rehash)
+	matches, _ = CheckPassword("1234567891", passwordHash)
+	assert.False(t, matches)
+}
+
+func TestCheckPassword(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bcrypt_password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	pbkdf2Sha256Hash, err := EncodePbkdf2Hash(Pbkdf2Sha256Algorithm, 27500, testKeyCloakSalt, testPbkdf2Sha256Hash)
+	require.NoError(t, err)
+	pbkdf2Sha512Hash, err := EncodePbkdf2Hash(Pbkdf2Sha512Algorithm, 30000, testKeyCloakSalt, testPbkdf2Sha512Hash)
+	require.NoError(t, err)
+	outdatedArgon2Hash := "$argon2id$v=19$m=4096,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$2gwotFrXd0+zAFJqh3DHaDnAxcUpU8hrA/whR9a0Ls0"
+
+	testCases := []struct {
+		name            string
+		password        string
+		storedValue     string
+		expectedMatches bool
+		expectedRehash  bool
+	}{
+		{name: "plain_text", password: "1234567890", storedValue: "1234567890", expectedMatches: true, expectedRehash: true},
+		{name: "plain_text_mismatch", password: "1234567891", storedValue: "1234567890", expectedMatches: false},
+		{name: "empty_stored_value", password: "", storedValue: "", expectedMatches: false},
+		{name: "bcrypt", password: "bcrypt_password", storedValue: string(bcryptHash), expectedMatches: true, expectedRehash: true},
+		{name: "bcrypt_mismatch", password: "password", storedValue: string(bcryptHash), expectedMatches: false},
+		{name: "keycloak_pbkdf2_sha256", password: testKeyCloakPassword, storedValue: pbkdf2Sha256Hash, expectedMatches: true,
+			expectedRehash: true},
+		{name: "keycloak_pbkdf2_sha256_mismatch", password: "password", storedValue: pbkdf2Sha256Hash, expectedMatches: false},
+		{name: "keycloak_pbkdf2_sha512", password: testKeyCloakPassword, storedValue: pbkdf2Sha512Hash, expectedMatches: true,
+			expectedRehash: true},
+		{name: "argon2id_with_outdated_parameters", password: "password", storedValue: outdatedArgon2Hash, expectedMatches: true,
+			expectedRehash: true},
+		{name: "broken_argon2id_hash", password: "password", storedValue: "$argon2id$v=19$m=4096$salt$hash", expectedMatches: false},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			matches, rehash := CheckPassword(tc.password, tc.storedValue)
+			assert.Equal(t, tc.expectedMatches, matches)
+			if tc.expectedMatches {
+				assert.Equal(t, tc.expectedRehash, rehash)
+			}
+		})
+	}
+}
+
+func TestEncodePbkdf2HashFailsOnUnsupportedAlgorithm(t *testing.T) {
+	_, err := EncodePbkdf2Hash("md5", 27500, testKeyCloakSalt, testPbkdf2Sha256Hash)
+	assert.Error(t, err)
+	_, err = EncodePbkdf2Hash(Pbkdf2Sha256Algorithm, 0, testKeyCloakSalt, testPbkdf2Sha256Hash)
+	assert.Error(t, err)
+}
+
+func TestDecodePasswordHash(t *testing.T) {
+	pbkdf2Hash, err := EncodePbkdf2Hash(Pbkdf2Sha256Algorithm, 27500, testKeyCloakSalt, testPbkdf2Sha256Hash)
+	require.NoError(t, err)
+	decoded, err := DecodePasswordHash(pbkdf2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, PasswordHash{Algorithm: Pbkdf2Sha256Algorithm, Iterations: 27500, Salt: testKeyCloakSalt,
+		Value: testPbkdf2Sha256Hash}, *decoded)
+
+	// argon2id hash survives KeyCloak credential round trip
+	argon2Hash, err := HashPassword("argon2_password")
+	require.NoError(t, err)
+	decoded, err = DecodePasswordHash(argon2Hash)
+	require.NoError(t, err)
+	assert.Equal(t, Argon2idAlgorithm, decoded.Algorithm)
+	assert.Equal(t, int(argon2Time), decoded.Iterations)
+	assert.Equal(t, int(argon2Memory), decoded.Memory)
+	assert.Equal(t, int(argon2Threads), decoded.Parallelism)
+	assert.True(t, strings.HasSuffix(decoded.Salt, "=="))
+	encoded, err := EncodeArgon2idHash(decoded.Iterations, decoded.Memory, decoded.Parallelism, decoded.Salt, decoded.Value)
+	require.NoError(t, err)
+	assert.Equal(t, argon2Hash, encoded)
+	matches, rehash := CheckPassword("argon2_password", encoded)
+	assert.True(t, matches)
+	assert.False(t, rehash)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("bcrypt_password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	_, err = DecodePasswordHash(string(bcryptHash))
+	assert.Error(t, err)
+	_, err = DecodePasswordHash("plain_password")
+	assert.Error(t, err)
+	_, err = EncodeArgon2idHash(0, 7168, 1, decoded.Salt, decoded.Value)
+	assert.Error(t, err)
+}
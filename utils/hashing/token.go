@@ -0,0 +1,27 @@
+package hashing
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+)
+
+// HashToken returns hex encoded SHA-256 hash of a secret token
+/* Long-living secret tokens (initial access tokens, registration access tokens) are high-entropy random values, therefore
+ * we store only their hash in a data source (fast hash is enough here, unlike passwords)
+ * Parameters:
+ *    - token - secret token value
+ * Returns: hex encoded hash
+ */
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// CheckTokenHash checks in constant time whether token corresponds to tokenHash obtained by HashToken, empty tokenHash never matches
+func CheckTokenHash(token string, tokenHash string) bool {
+	if len(tokenHash) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(tokenHash)) == 1
+}
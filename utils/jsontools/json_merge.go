@@ -17,11 +17,11 @@ func MergeNonIntersect[T1 any, T2 any](first *T1, second *T2) (interface{}, stri
 		// todo(UMV): add trouble logging
 		return nil, ""
 	}
-	// trim } from end of str1
-	str1 = []byte(strings.TrimRight(string(str1), "}"))
+	// trim } from end of str1 (only one, str1 could end with nested object)
+	str1 = []byte(strings.TrimSuffix(string(str1), "}"))
 
 	// trim { from start of str2
-	str2 = []byte(strings.TrimLeft(string(str2), "{"))
+	str2 = []byte(strings.TrimPrefix(string(str2), "{"))
 	str := string(str1) + "," + string(str2)
 
 	err = json.Unmarshal([]byte(str), &result)
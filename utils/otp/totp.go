@@ -0,0 +1,151 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Sha1Algorithm   = "SHA1"
+	Sha256Algorithm = "SHA256"
+	Sha512Algorithm = "SHA512"
+)
+
+// secretSize is a size of generated secret (RFC 4226 recommends 160 bits)
+const secretSize = 20
+
+// Settings are TOTP parameters that are shared by generator (authenticator app) and verifier (server)
+type Settings struct {
+	Digits    int
+	Period    int
+	Algorithm string
+}
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret generates random TOTP secret encoded as base32 without padding (as authenticator apps expect)
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return EncodeSecret(secret), nil
+}
+
+// EncodeSecret encodes raw secret (i.e. secret imported from other server) as base32 without padding like GenerateSecret does
+func EncodeSecret(secret []byte) string {
+	return secretEncoding.EncodeToString(secret)
+}
+
+// DecodeSecret decodes base32 encoded secret, padding and lower case letters are accepted
+func DecodeSecret(secret string) ([]byte, error) {
+	return secretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+}
+
+// GetCounter returns TOTP time step number (RFC 6238 section 4) of moment t
+func GetCounter(t time.Time, period int) int64 {
+	return t.Unix() / int64(period)
+}
+
+// GenerateCode calculates TOTP code (RFC 6238 is HOTP (RFC 4226) with time step number as counter)
+/* Parameters:
+ *    - secret - base32 encoded secret
+ *    - counter - time step number (GetCounter)
+ *    - settings - code digits and HMAC algorithm
+ * Returns: code or error if secret or algorithm is invalid
+ */
+func GenerateCode(secret string, counter int64, settings Settings) (string, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret: %w", err)
+	}
+	hashFunc := getHashFunc(settings.Algorithm)
+	if hashFunc == nil {
+		return "", fmt.Errorf("unsupported algorithm \"%s\"", settings.Algorithm)
+	}
+	mac := hmac.New(hashFunc, key)
+	counterBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(counterBytes, uint64(counter))
+	mac.Write(counterBytes)
+	sum := mac.Sum(nil)
+	// dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := int64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	modulo := int64(1)
+	for i := 0; i < settings.Digits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", settings.Digits, value%modulo), nil
+}
+
+// ValidateCode checks TOTP code in time steps window [counter - lookAhead, counter + lookAhead] (clock drift compensation)
+/* Parameters:
+ *    - secret - base32 encoded secret
+ *    - code - code that was provided by user
+ *    - counter - current time step number
+ *    - lookAhead - number of time steps before and after current that are also accepted
+ *    - settings - code digits and HMAC algorithm
+ * Returns: time step number of matched code and true or 0 and false if code is invalid
+ */
+func ValidateCode(secret string, code string, counter int64, lookAhead int, settings Settings) (int64, bool) {
+	if len(code) != settings.Digits {
+		return 0, false
+	}
+	for step := counter - int64(lookAhead); step <= counter+int64(lookAhead); step++ {
+		expected, err := GenerateCode(secret, step, settings)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// CreateKeyUri creates otpauth:// uri (Key Uri Format that is supported by authenticator apps) that is usually shown as QR code
+/* Parameters:
+ *    - issuer - service name (i.e. realm)
+ *    - accountName - user name
+ *    - secret - base32 encoded secret
+ *    - settings - code digits, period and algorithm
+ * Returns: uri
+ */
+func CreateKeyUri(issuer string, accountName string, secret string, settings Settings) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", settings.Algorithm)
+	params.Set("digits", fmt.Sprintf("%d", settings.Digits))
+	params.Set("period", fmt.Sprintf("%d", settings.Period))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// IsAlgorithmSupported checks whether TOTP HMAC algorithm is supported
+func IsAlgorithmSupported(algorithm string) bool {
+	return getHashFunc(algorithm) != nil
+}
+
+func getHashFunc(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case Sha1Algorithm:
+		return sha1.New
+	case Sha256Algorithm:
+		return sha256.New
+	case Sha512Algorithm:
+		return sha512.New
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package otp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 Appendix B test vectors, secrets are ASCII "1234567890" repeated to key length of each algorithm
+func TestGenerateCodeRfc6238Vectors(t *testing.T) {
+	secrets := map[string]string{
+		Sha1Algorithm:   base32.StdEncoding.EncodeToString([]byte("12345678901234567890")),
+		Sha256Algorithm: base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012")),
+		Sha512Algorithm: base32.StdEncoding.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234")),
+	}
+	testCases := []struct {
+		unixTime  int64
+		algorithm string
+		expected  string
+	}{
+		{unixTime: 59, algorithm: Sha1Algorithm, expected: "94287082"},
+		{unixTime: 59, algorithm: Sha256Algorithm, expected: "46119246"},
+		{unixTime: 59, algorithm: Sha512Algorithm, expected: "90693936"},
+		{unixTime: 1111111109, algorithm: Sha1Algorithm, expected: "07081804"},
+		{unixTime: 1111111109, algorithm: Sha256Algorithm, expected: "68084774"},
+		{unixTime: 1111111109, algorithm: Sha512Algorithm, expected: "25091201"},
+		{unixTime: 2000000000, algorithm: Sha1Algorithm, expected: "69279037"},
+		{unixTime: 20000000000, algorithm: Sha512Algorithm, expected: "47863826"},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.algorithm+"_"+time.Unix(tc.unixTime, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			settings := Settings{Digits: 8, Period: 30, Algorithm: tc.algorithm}
+			code, err := GenerateCode(secrets[tc.algorithm], GetCounter(time.Unix(tc.unixTime, 0), settings.Period), settings)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, code)
+		})
+	}
+}
+
+func TestValidateCode(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	settings := Settings{Digits: 6, Period: 30, Algorithm: Sha1Algorithm}
+	counter := GetCounter(time.Now(), settings.Period)
+	previousCode, err := GenerateCode(secret, counter-1, settings)
+	require.NoError(t, err)
+
+	step, ok := ValidateCode(secret, previousCode, counter, 1, settings)
+	assert.True(t, ok)
+	assert.Equal(t, counter-1, step)
+	_, ok = ValidateCode(secret, previousCode, counter, 0, settings)
+	assert.False(t, ok)
+	_, ok = ValidateCode(secret, "12345", counter, 1, settings)
+	assert.False(t, ok)
+}
+
+func TestCreateKeyUri(t *testing.T) {
+	uri := CreateKeyUri("my realm", "vano", "JBSWY3DPEHPK3PXP", Settings{Digits: 6, Period: 30, Algorithm: Sha1Algorithm})
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/my realm:vano", parsed.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	assert.Equal(t, "my realm", parsed.Query().Get("issuer"))
+	assert.Equal(t, "6", parsed.Query().Get("digits"))
+}
@@ -0,0 +1,20 @@
+package random
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// GenerateToken generates cryptographically secure random opaque value encoded as base64 url (without padding)
+/* This function is using for generation of one-time secrets (request identifiers, callback tokens, and so on)
+ * Parameters:
+ *    - size - number of random bytes (encoded value is longer)
+ * Returns: encoded random value or error if system random generator is not available
+ */
+func GenerateToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
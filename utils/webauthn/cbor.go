@@ -0,0 +1,143 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// maxCborDepth limits nesting of decoded items, WebAuthn structures are not deeper than 3 levels
+const maxCborDepth = 8
+
+var errCborTruncated = errors.New("cbor: unexpected end of data")
+
+// decodeCbor decodes one CBOR (RFC 8949) data item, only subset that is used by WebAuthn (CTAP2 canonical CBOR) is supported:
+/* unsigned and negative integers (int64), byte strings ([]byte), text strings (string), arrays ([]interface{}), maps
+ * (map[interface{}]interface{} with int64 or string keys), booleans and null, indefinite length items and floats are not supported
+ * Parameters:
+ *    - raw - CBOR encoded data
+ * Returns: decoded item, data that follows item (i.e. extensions after COSE key in authenticator data) and error
+ */
+func decodeCbor(raw []byte) (interface{}, []byte, error) {
+	return decodeCborItem(raw, 0)
+}
+
+func decodeCborItem(raw []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCborDepth {
+		return nil, nil, errors.New("cbor: nesting is too deep")
+	}
+	if len(raw) == 0 {
+		return nil, nil, errCborTruncated
+	}
+	majorType := raw[0] >> 5
+	argument, rest, err := readCborArgument(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch majorType {
+	case 0:
+		if argument > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return int64(argument), rest, nil
+	case 1:
+		if argument > 1<<63-1 {
+			return nil, nil, errors.New("cbor: integer overflow")
+		}
+		return -1 - int64(argument), rest, nil
+	case 2, 3:
+		if uint64(len(rest)) < argument {
+			return nil, nil, errCborTruncated
+		}
+		value := rest[:argument]
+		if majorType == 3 {
+			return string(value), rest[argument:], nil
+		}
+		return append([]byte{}, value...), rest[argument:], nil
+	case 4:
+		// every item takes at least one byte, that protects from huge allocations
+		if uint64(len(rest)) < argument {
+			return nil, nil, errCborTruncated
+		}
+		items := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			var item interface{}
+			item, rest, err = decodeCborItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rest, nil
+	case 5:
+		if argument > uint64(len(rest))/2 {
+			return nil, nil, errCborTruncated
+		}
+		items := make(map[interface{}]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			var key, value interface{}
+			key, rest, err = decodeCborItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errors.New("cbor: map key must be integer or text string")
+			}
+			value, rest, err = decodeCborItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, rest, nil
+	case 7:
+		switch raw[0] & 0x1f {
+		case 20:
+			return false, rest, nil
+		case 21:
+			return true, rest, nil
+		case 22:
+			return nil, rest, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("cbor: unsupported item 0x%02x", raw[0])
+}
+
+// readCborArgument reads item argument (value, length or count) that follows initial byte
+func readCborArgument(raw []byte) (uint64, []byte, error) {
+	info := raw[0] & 0x1f
+	rest := raw[1:]
+	if info < 24 {
+		return uint64(info), rest, nil
+	}
+	size := 0
+	switch info {
+	case 24:
+		size = 1
+	case 25:
+		size = 2
+	case 26:
+		size = 4
+	case 27:
+		size = 8
+	default:
+		return 0, nil, fmt.Errorf("cbor: unsupported item 0x%02x", raw[0])
+	}
+	if len(rest) < size {
+		return 0, nil, errCborTruncated
+	}
+	var argument uint64
+	switch size {
+	case 1:
+		argument = uint64(rest[0])
+	case 2:
+		argument = uint64(binary.BigEndian.Uint16(rest))
+	case 4:
+		argument = uint64(binary.BigEndian.Uint32(rest))
+	case 8:
+		argument = binary.BigEndian.Uint64(rest)
+	}
+	return argument, rest[size:], nil
+}
@@ -0,0 +1,68 @@
+package webauthn
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// test vectors are taken from RFC 8949 Appendix A
+func TestDecodeCbor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		encoded  string
+		expected interface{}
+	}{
+		{name: "small_uint", encoded: "17", expected: int64(23)},
+		{name: "one_byte_uint", encoded: "1818", expected: int64(24)},
+		{name: "four_bytes_uint", encoded: "1a000f4240", expected: int64(1000000)},
+		{name: "negative_int", encoded: "3863", expected: int64(-100)},
+		{name: "cose_rs256_algorithm", encoded: "390100", expected: int64(-257)},
+		{name: "bytes", encoded: "4401020304", expected: []byte{1, 2, 3, 4}},
+		{name: "text", encoded: "6449455446", expected: "IETF"},
+		{name: "array", encoded: "8301820203820405", expected: []interface{}{int64(1), []interface{}{int64(2), int64(3)},
+			[]interface{}{int64(4), int64(5)}}},
+		{name: "map", encoded: "a26161016162820203", expected: map[interface{}]interface{}{"a": int64(1),
+			"b": []interface{}{int64(2), int64(3)}}},
+		{name: "simple_values", encoded: "83f4f5f6", expected: []interface{}{false, true, nil}},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := hex.DecodeString(tc.encoded)
+			require.NoError(t, err)
+			// trailing data is returned as is
+			value, rest, err := decodeCbor(append(encoded, 0xff))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, value)
+			assert.Equal(t, []byte{0xff}, rest)
+		})
+	}
+}
+
+func TestDecodeInvalidCbor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "empty", encoded: ""},
+		{name: "truncated_bytes", encoded: "4401"},
+		{name: "huge_array", encoded: "9bffffffffffffffff"},
+		{name: "huge_map", encoded: "bbffffffffffffffff"},
+		{name: "array_map_key", encoded: "a1800102"},
+		{name: "float", encoded: "f93c00"},
+		{name: "indefinite_length", encoded: "5f42010243030405ff"},
+		{name: "too_deep", encoded: "818181818181818181818101"},
+	}
+	for _, tCase := range testCases {
+		tc := tCase
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := hex.DecodeString(tc.encoded)
+			require.NoError(t, err)
+			_, _, err = decodeCbor(encoded)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// COSE algorithms (RFC 9053) of credential public keys that are supported
+const (
+	AlgorithmES256 = -7
+	AlgorithmEdDSA = -8
+	AlgorithmRS256 = -257
+)
+
+// SupportedAlgorithms are COSE algorithms in order of preference, they are passed to authenticator as pubKeyCredParams
+var SupportedAlgorithms = []int{AlgorithmES256, AlgorithmEdDSA, AlgorithmRS256}
+
+// Authenticator data flags (WebAuthn Level 2 section 6.1)
+const (
+	FlagUserPresent            byte = 0x01
+	FlagUserVerified           byte = 0x04
+	FlagBackupEligible         byte = 0x08
+	FlagBackupState            byte = 0x10
+	FlagAttestedCredentialData byte = 0x40
+	FlagExtensionData          byte = 0x80
+)
+
+// Client data types of registration and authentication ceremonies
+const (
+	CreateCeremonyType = "webauthn.create"
+	GetCeremonyType    = "webauthn.get"
+)
+
+// NoneAttestationFormat is the only supported attestation statement format (authenticator model is not verified)
+const NoneAttestationFormat = "none"
+
+// minAuthenticatorDataSize is a size of rpIdHash, flags and signCount
+const minAuthenticatorDataSize = 37
+
+// COSE key parameters (RFC 9052 section 7 and RFC 9053 section 7)
+const (
+	coseKeyType      = 1
+	coseKeyAlgorithm = 3
+	coseCurve        = -1
+	coseX            = -2
+	coseY            = -3
+	coseRsaN         = -1
+	coseRsaE         = -2
+	coseKeyTypeOkp   = 1
+	coseKeyTypeEc2   = 2
+	coseKeyTypeRsa   = 3
+	coseCurveP256    = 1
+	coseCurveEd25519 = 6
+)
+
+// CollectedClientData is a client data that browser passes to authenticator (clientDataJSON)
+type CollectedClientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin,omitempty"`
+}
+
+// AttestedCredential is a new credential that authenticator created on registration
+type AttestedCredential struct {
+	Aaguid       []byte
+	CredentialId []byte
+	// PublicKey is a COSE encoded credential public key
+	PublicKey []byte
+}
+
+// AuthenticatorData is a parsed authenticator data (WebAuthn Level 2 section 6.1)
+type AuthenticatorData struct {
+	RpIdHash           []byte
+	Flags              byte
+	SignCount          uint32
+	AttestedCredential *AttestedCredential
+}
+
+// HasFlag checks whether authenticator data flag is set
+func (authData *AuthenticatorData) HasFlag(flag byte) bool {
+	return authData.Flags&flag == flag
+}
+
+// CheckRpId checks that authenticator data belongs to relying party rpId
+func (authData *AuthenticatorData) CheckRpId(rpId string) bool {
+	rpIdHash := sha256.Sum256([]byte(rpId))
+	return bytes.Equal(rpIdHash[:], authData.RpIdHash)
+}
+
+// DecodeBase64Url decodes base64url value with or without padding (browsers send values without padding)
+func DecodeBase64Url(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// EncodeBase64Url encodes value to base64url without padding
+func EncodeBase64Url(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// ParseClientData parses clientDataJSON and checks that it belongs to expected ceremony type
+func ParseClientData(clientDataJson []byte, ceremonyType string) (*CollectedClientData, error) {
+	var clientData CollectedClientData
+	if err := json.Unmarshal(clientDataJson, &clientData); err != nil {
+		return nil, fmt.Errorf("clientDataJSON is not a valid json: %w", err)
+	}
+	if clientData.Type != ceremonyType {
+		return nil, fmt.Errorf("unexpected client data type \"%s\"", clientData.Type)
+	}
+	return &clientData, nil
+}
+
+// ParseAuthenticatorData parses authenticator data, attested credential data is parsed only if FlagAttestedCredentialData is set
+func ParseAuthenticatorData(raw []byte) (*AuthenticatorData, error) {
+	if len(raw) < minAuthenticatorDataSize {
+		return nil, errors.New("authenticator data is too short")
+	}
+	authData := AuthenticatorData{RpIdHash: raw[:32], Flags: raw[32], SignCount: binary.BigEndian.Uint32(raw[33:37])}
+	rest := raw[minAuthenticatorDataSize:]
+	if authData.HasFlag(FlagAttestedCredentialData) {
+		// aaguid (16 bytes) and credential id length (2 bytes)
+		if len(rest) < 18 {
+			return nil, errors.New("attested credential data is too short")
+		}
+		credentialIdLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		if len(rest) < 18+credentialIdLength {
+			return nil, errors.New("attested credential data is too short")
+		}
+		credential := AttestedCredential{Aaguid: rest[:16], CredentialId: rest[18 : 18+credentialIdLength]}
+		keyData := rest[18+credentialIdLength:]
+		_, rest, err := decodeCbor(keyData)
+		if err != nil {
+			return nil, fmt.Errorf("credential public key is invalid: %w", err)
+		}
+		credential.PublicKey = keyData[:len(keyData)-len(rest)]
+		authData.AttestedCredential = &credential
+		if !authData.HasFlag(FlagExtensionData) && len(rest) > 0 {
+			return nil, errors.New("authenticator data has unexpected trailing bytes")
+		}
+	}
+	return &authData, nil
+}
+
+// ParseAttestationObject parses attestationObject of registration response and returns authenticator data
+/* Only "none" attestation is supported: relying party requests attestation "none" and doesn't check authenticator model, that is
+ * a common choice for passkeys (platform authenticators and browsers return "none" attestation in this case)
+ * Parameters:
+ *    - raw - CBOR encoded attestation object
+ * Returns: parsed authenticator data (with attested credential) or error
+ */
+func ParseAttestationObject(raw []byte) (*AuthenticatorData, error) {
+	decoded, _, err := decodeCbor(raw)
+	if err != nil {
+		return nil, fmt.Errorf("attestation object is invalid: %w", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("attestation object is not a map")
+	}
+	format, _ := attestation["fmt"].(string)
+	if format != NoneAttestationFormat {
+		return nil, fmt.Errorf("attestation format \"%s\" is not supported", format)
+	}
+	if statement, ok := attestation["attStmt"].(map[interface{}]interface{}); !ok || len(statement) > 0 {
+		return nil, errors.New("none attestation must have empty statement")
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object has no authenticator data")
+	}
+	authData, err := ParseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.AttestedCredential == nil {
+		return nil, errors.New("authenticator data has no attested credential")
+	}
+	return authData, nil
+}
+
+// ParsePublicKey parses COSE encoded credential public key
+/* Parameters:
+ *    - coseKey - COSE_Key (CBOR map)
+ * Returns: public key (*ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey), COSE algorithm and error if key is invalid or
+ *          algorithm is not supported
+ */
+func ParsePublicKey(coseKey []byte) (crypto.PublicKey, int, error) {
+	decoded, _, err := decodeCbor(coseKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("public key is invalid: %w", err)
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, 0, errors.New("public key is not a map")
+	}
+	keyType, _ := key[int64(coseKeyType)].(int64)
+	algorithm, _ := key[int64(coseKeyAlgorithm)].(int64)
+	switch {
+	case keyType == coseKeyTypeEc2 && algorithm == AlgorithmES256:
+		curve, _ := key[int64(coseCurve)].(int64)
+		x, _ := key[int64(coseX)].([]byte)
+		y, _ := key[int64(coseY)].([]byte)
+		if curve != coseCurveP256 || len(x) != 32 || len(y) != 32 {
+			return nil, 0, errors.New("ES256 public key must have P-256 curve coordinates")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, 0, errors.New("ES256 public key point is not on curve")
+		}
+		return publicKey, AlgorithmES256, nil
+	case keyType == coseKeyTypeOkp && algorithm == AlgorithmEdDSA:
+		curve, _ := key[int64(coseCurve)].(int64)
+		x, _ := key[int64(coseX)].([]byte)
+		if curve != coseCurveEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("EdDSA public key must be Ed25519 key")
+		}
+		return ed25519.PublicKey(x), AlgorithmEdDSA, nil
+	case keyType == coseKeyTypeRsa && algorithm == AlgorithmRS256:
+		n, _ := key[int64(coseRsaN)].([]byte)
+		e, _ := key[int64(coseRsaE)].([]byte)
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) < 256 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, 0, errors.New("RS256 public key must be at least 2048 bits long")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, AlgorithmRS256, nil
+	}
+	return nil, 0, fmt.Errorf("public key type %d with algorithm %d is not supported", keyType, algorithm)
+}
+
+// VerifySignature checks assertion signature, authenticator signs concatenation of authenticator data and SHA-256 hash of clientDataJSON
+/* Parameters:
+ *    - coseKey - COSE encoded credential public key (stored on registration)
+ *    - authData - raw authenticator data
+ *    - clientDataJson - raw clientDataJSON
+ *    - signature - assertion signature (ASN.1 DER for ES256)
+ * Returns: nil if signature is valid
+ */
+func VerifySignature(coseKey []byte, authData []byte, clientDataJson []byte, signature []byte) error {
+	publicKey, algorithm, err := ParsePublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+	clientDataHash := sha256.Sum256(clientDataJson)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	valid := false
+	switch algorithm {
+	case AlgorithmES256:
+		digest := sha256.Sum256(signed)
+		valid = ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature)
+	case AlgorithmEdDSA:
+		valid = ed25519.Verify(publicKey.(ed25519.PublicKey), signed, signature)
+	case AlgorithmRS256:
+		digest := sha256.Sum256(signed)
+		valid = rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+	if !valid {
+		return errors.New("signature is invalid")
+	}
+	return nil
+}
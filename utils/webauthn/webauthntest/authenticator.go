@@ -0,0 +1,216 @@
+// Package webauthntest provides software WebAuthn authenticator that allows to test passkey registration and login in-process
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/wissance/Ferrum/utils/webauthn"
+)
+
+// AuthenticatorResponse is a response part of PublicKeyCredential (values are base64url encoded as PublicKeyCredential toJSON does)
+type AuthenticatorResponse struct {
+	ClientDataJson    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject,omitempty"`
+	AuthenticatorData string `json:"authenticatorData,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// PublicKeyCredential is a result of navigator.credentials.create() or navigator.credentials.get() serialized to json
+type PublicKeyCredential struct {
+	Id       string                `json:"id"`
+	RawId    string                `json:"rawId"`
+	Type     string                `json:"type"`
+	Response AuthenticatorResponse `json:"response"`
+}
+
+type credential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// Authenticator is a software authenticator (together with a browser client part) with ES256 discoverable credentials
+/* Origin is passed in client data, RpId is used in authenticator data, UserVerified sets UV flag, all of them could be changed
+ * between ceremonies to emulate invalid responses
+ */
+type Authenticator struct {
+	Origin       string
+	RpId         string
+	UserVerified bool
+	credentials  []*credential
+}
+
+// NewAuthenticator creates authenticator for relying party rpId that is used from origin
+func NewAuthenticator(rpId string, origin string) *Authenticator {
+	return &Authenticator{Origin: origin, RpId: rpId, UserVerified: true}
+}
+
+// Register creates new credential (navigator.credentials.create() with "none" attestation)
+/* Parameters:
+ *    - challenge - base64url encoded challenge from creation options
+ *    - userHandle - user.id from creation options (decoded)
+ * Returns: registration response
+ */
+func (authenticator *Authenticator) Register(challenge string, userHandle []byte) (*PublicKeyCredential, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, key: key, userHandle: userHandle}
+	authenticator.credentials = append(authenticator.credentials, cred)
+
+	clientData, err := authenticator.createClientData(webauthn.CreateCeremonyType, challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := authenticator.createAuthenticatorData(webauthn.FlagAttestedCredentialData, cred.signCount)
+	// attested credential data: zero aaguid (as authenticators do with "none" attestation), credential id and COSE key
+	authData = append(authData, make([]byte, 16)...)
+	authData = append(authData, byte(len(id)>>8), byte(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, encodeCoseKey(&key.PublicKey)...)
+	attestationObject := encodeCborMap([]cborMapItem{
+		{key: encodeCborText("fmt"), value: encodeCborText(webauthn.NoneAttestationFormat)},
+		{key: encodeCborText("attStmt"), value: encodeCborMap(nil)},
+		{key: encodeCborText("authData"), value: encodeCborBytes(authData)},
+	})
+	encodedId := webauthn.EncodeBase64Url(id)
+	return &PublicKeyCredential{Id: encodedId, RawId: encodedId, Type: "public-key", Response: AuthenticatorResponse{
+		ClientDataJson: webauthn.EncodeBase64Url(clientData), AttestationObject: webauthn.EncodeBase64Url(attestationObject),
+	}}, nil
+}
+
+// Login creates assertion (navigator.credentials.get()), every assertion increments credential signature counter
+/* Parameters:
+ *    - challenge - base64url encoded challenge from request options
+ *    - credentialId - base64url encoded id of credential, empty value selects the last registered (discoverable) credential
+ * Returns: authentication response
+ */
+func (authenticator *Authenticator) Login(challenge string, credentialId string) (*PublicKeyCredential, error) {
+	var cred *credential
+	for _, c := range authenticator.credentials {
+		if len(credentialId) == 0 || webauthn.EncodeBase64Url(c.id) == credentialId {
+			cred = c
+		}
+	}
+	if cred == nil {
+		return nil, errors.New("credential was not found")
+	}
+	cred.signCount++
+	clientData, err := authenticator.createClientData(webauthn.GetCeremonyType, challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := authenticator.createAuthenticatorData(0, cred.signCount)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	encodedId := webauthn.EncodeBase64Url(cred.id)
+	return &PublicKeyCredential{Id: encodedId, RawId: encodedId, Type: "public-key", Response: AuthenticatorResponse{
+		ClientDataJson: webauthn.EncodeBase64Url(clientData), AuthenticatorData: webauthn.EncodeBase64Url(authData),
+		Signature: webauthn.EncodeBase64Url(signature), UserHandle: webauthn.EncodeBase64Url(cred.userHandle),
+	}}, nil
+}
+
+// SetSignCount sets credential signature counter (i.e. to emulate cloned authenticator)
+func (authenticator *Authenticator) SetSignCount(credentialId string, signCount uint32) {
+	for _, c := range authenticator.credentials {
+		if webauthn.EncodeBase64Url(c.id) == credentialId {
+			c.signCount = signCount
+		}
+	}
+}
+
+func (authenticator *Authenticator) createClientData(ceremonyType string, challenge string) ([]byte, error) {
+	return json.Marshal(webauthn.CollectedClientData{Type: ceremonyType, Challenge: challenge, Origin: authenticator.Origin})
+}
+
+func (authenticator *Authenticator) createAuthenticatorData(flags byte, signCount uint32) []byte {
+	rpIdHash := sha256.Sum256([]byte(authenticator.RpId))
+	flags |= webauthn.FlagUserPresent
+	if authenticator.UserVerified {
+		flags |= webauthn.FlagUserVerified
+	}
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, signCount)
+	return append(append(rpIdHash[:], flags), counter...)
+}
+
+func encodeCoseKey(publicKey *ecdsa.PublicKey) []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	publicKey.X.FillBytes(x)
+	publicKey.Y.FillBytes(y)
+	// kty: EC2, alg: ES256, crv: P-256
+	return encodeCborMap([]cborMapItem{
+		{key: encodeCborInt(1), value: encodeCborInt(2)},
+		{key: encodeCborInt(3), value: encodeCborInt(webauthn.AlgorithmES256)},
+		{key: encodeCborInt(-1), value: encodeCborInt(1)},
+		{key: encodeCborInt(-2), value: encodeCborBytes(x)},
+		{key: encodeCborInt(-3), value: encodeCborBytes(y)},
+	})
+}
+
+type cborMapItem struct {
+	key   []byte
+	value []byte
+}
+
+func encodeCborInt(value int) []byte {
+	if value < 0 {
+		return encodeCborHead(1, uint64(-1-value))
+	}
+	return encodeCborHead(0, uint64(value))
+}
+
+func encodeCborBytes(value []byte) []byte {
+	return append(encodeCborHead(2, uint64(len(value))), value...)
+}
+
+func encodeCborText(value string) []byte {
+	return append(encodeCborHead(3, uint64(len(value))), value...)
+}
+
+func encodeCborMap(items []cborMapItem) []byte {
+	encoded := encodeCborHead(5, uint64(len(items)))
+	for _, item := range items {
+		encoded = append(encoded, item.key...)
+		encoded = append(encoded, item.value...)
+	}
+	return encoded
+}
+
+func encodeCborHead(majorType byte, argument uint64) []byte {
+	if argument < 24 {
+		return []byte{majorType<<5 | byte(argument)}
+	}
+	// argument is encoded in 1, 2, 4 or 8 bytes (additional information 24, 25, 26 or 27)
+	size, info := 8, byte(27)
+	switch {
+	case argument <= 0xff:
+		size, info = 1, 24
+	case argument <= 0xffff:
+		size, info = 2, 25
+	case argument <= 0xffffffff:
+		size, info = 4, 26
+	}
+	encoded := make([]byte, 9)
+	encoded[0] = majorType<<5 | info
+	binary.BigEndian.PutUint64(encoded[1:], argument)
+	return append(encoded[:1], encoded[9-size:]...)
+}